@@ -99,6 +99,7 @@ The dumpgenesis command dumps the genesis block configuration in JSON format to
 			utils.MetricsInfluxDBBucketFlag,
 			utils.MetricsInfluxDBOrganizationFlag,
 			utils.TxLookupLimitFlag,
+			utils.ParallelImportFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
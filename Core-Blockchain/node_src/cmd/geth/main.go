@@ -101,6 +101,7 @@ var (
 		utils.GCModeFlag,
 		utils.SnapshotFlag,
 		utils.TxLookupLimitFlag,
+		utils.ParallelImportFlag,
 		utils.LightServeFlag,
 		utils.LightIngressFlag,
 		utils.LightEgressFlag,
@@ -135,9 +136,11 @@ var (
 		utils.MinerExtraDataFlag,
 		utils.MinerRecommitIntervalFlag,
 		utils.MinerNoVerifyFlag,
+		utils.MinerPQKeyFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
+		utils.PQKEMFlag,
 		utils.NetrestrictFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
@@ -513,6 +516,7 @@ func initializeGPUAcceleration(ctx *cli.Context) {
 		MaxGPUUtilization:     getEnvFloat("MAX_GPU_UTILIZATION", 0.95),
 		ThroughputTarget:      getEnvUint64("THROUGHPUT_TARGET", 8000000),
 		GPUConfig: &gpu.GPUConfig{
+			Accelerator:      strings.TrimSpace(os.Getenv("GPU_ACCELERATOR")), // e.g. "software" on GPU-less hosts
 			PreferredGPUType: gpuType,
 			MaxBatchSize:     getEnvInt("GPU_MAX_BATCH_SIZE", 80000),
 			MaxMemoryUsage:   getEnvUint64("GPU_MAX_MEMORY_USAGE", 17179869184), // 16GB default
@@ -223,6 +223,10 @@ var (
 		Usage: "Number of recent blocks to maintain transactions index for (default = about one year, 0 = entire chain)",
 		Value: ethconfig.Defaults.TxLookupLimit,
 	}
+	ParallelImportFlag = cli.BoolFlag{
+		Name:  "parallelimport",
+		Usage: "Execute the transactions of imported blocks in parallel, retrying serially on divergence",
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -465,6 +469,10 @@ var (
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	MinerPQKeyFlag = cli.StringFlag{
+		Name:  "miner.pqkey",
+		Usage: "Comma separated list of ML-DSA key files to seal Congress blocks with after the PQT fork (current and pending key during a rotation)",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -658,6 +666,10 @@ var (
 		Name:  "v5disc",
 		Usage: "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
 	}
+	PQKEMFlag = cli.BoolFlag{
+		Name:  "pqkem",
+		Usage: "Enables the hybrid ML-KEM + ECDH RLPx handshake with peers supporting it",
+	}
 	NetrestrictFlag = cli.StringFlag{
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
@@ -1189,6 +1201,9 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	} else if forceV5Discovery {
 		cfg.DiscoveryV5 = true
 	}
+	if ctx.GlobalIsSet(PQKEMFlag.Name) {
+		cfg.HybridKEM = ctx.GlobalBool(PQKEMFlag.Name)
+	}
 
 	if netrestrict := ctx.GlobalString(NetrestrictFlag.Name); netrestrict != "" {
 		list, err := netutil.ParseNetlist(netrestrict)
@@ -1384,6 +1399,9 @@ func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	if ctx.GlobalIsSet(MinerNoVerifyFlag.Name) {
 		cfg.Noverify = ctx.GlobalBool(MinerNoVerifyFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerPQKeyFlag.Name) {
+		cfg.PQKeyFile = ctx.GlobalString(MinerPQKeyFlag.Name)
+	}
 	if ctx.GlobalIsSet(LegacyMinerGasTargetFlag.Name) {
 		log.Warn("The generic --miner.gastarget flag is deprecated and will be removed in the future!")
 	}
@@ -1530,6 +1548,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(TxLookupLimitFlag.Name) {
 		cfg.TxLookupLimit = ctx.GlobalUint64(TxLookupLimitFlag.Name)
 	}
+	if ctx.GlobalIsSet(ParallelImportFlag.Name) {
+		cfg.ParallelImport = ctx.GlobalBool(ParallelImportFlag.Name)
+	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
 	}
@@ -1876,7 +1897,11 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 
 	// TODO(rjl493456442) disable snapshot generation/wiping if the chain is read only.
 	// Disable transaction indexing/unindexing by default.
-	chain, err = core.NewBlockChain(chainDb, cache, config, engine, vmcfg, nil, nil)
+	var options []core.BlockChainOption
+	if ctx.GlobalBool(ParallelImportFlag.Name) {
+		options = append(options, core.EnableParallelImport(nil))
+	}
+	chain, err = core.NewBlockChain(chainDb, cache, config, engine, vmcfg, nil, nil, options...)
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
 	}
@@ -31,6 +31,7 @@ type AILoadBalancer struct {
 	decisionHistory []AIDecision
 	confidence      float64
 	learningRate    float64
+	threshold       float64 // Min prediction confidence to retune
 	
 	// Hybrid Processor Reference
 	hybridProcessor *hybrid.HybridProcessor
@@ -77,6 +78,8 @@ type PerformanceMetrics struct {
 	BatchSize       int       `json:"batchSize"`
 	CurrentStrategy string    `json:"currentStrategy"`
 	QueueDepth      int       `json:"queueDepth"`
+	CPUGPURatio     float64   `json:"cpuGpuRatio"`
+	GPUThreshold    int       `json:"gpuThreshold"`
 }
 
 // LoadPrediction holds AI predictions for load balancing
@@ -85,6 +88,8 @@ type LoadPrediction struct {
 	PredictedTPS      uint64    `json:"predictedTps"`
 	RecommendedRatio  float64   `json:"recommendedRatio"`
 	RecommendedStrategy string  `json:"recommendedStrategy"`
+	RecommendedBatchSize int    `json:"recommendedBatchSize"` // 0 keeps the current batch size
+	RecommendedThreshold int    `json:"recommendedThreshold"` // 0 keeps the current GPU threshold
 	Confidence        float64   `json:"confidence"`
 	Reasoning         string    `json:"reasoning"`
 }
@@ -97,6 +102,8 @@ type AIDecision struct {
 	ActualOutcome   PerformanceMetrics `json:"actualOutcome"`
 	Success         bool       `json:"success"`
 	PerformanceGain float64    `json:"performanceGain"`
+	Generation      uint64     `json:"generation"` // Retune applied for the decision, 0 if none
+	settled         bool       // Outcome of the retune known
 }
 
 // VLLMChatRequest represents a chat request to vLLM (OpenAI-compatible)
@@ -140,6 +147,7 @@ func NewAILoadBalancer(config *AIConfig, hybridProcessor *hybrid.HybridProcessor
 		decisionHistory: make([]AIDecision, 0, config.HistorySize),
 		confidence:      0.5, // Start with neutral confidence
 		learningRate:    config.LearningRate,
+		threshold:       config.ConfidenceThreshold,
 		hybridProcessor: hybridProcessor,
 		ctx:             ctx,
 		cancel:          cancel,
@@ -293,17 +301,13 @@ func (ai *AILoadBalancer) makeAIDecision() {
 		return
 	}
 	
-	// Apply AI decision if confidence is high enough
-	if prediction.Confidence >= 0.7 {
-		ai.applyAIDecision(prediction, metrics)
-	} else {
-		log.Debug("AI confidence too low, skipping decision", "confidence", prediction.Confidence)
-	}
+	ai.applyAIDecision(prediction, metrics)
 }
 
 // collectCurrentMetrics gathers current system performance metrics
 func (ai *AILoadBalancer) collectCurrentMetrics() PerformanceMetrics {
 	stats := ai.hybridProcessor.GetStats()
+	tuning := ai.hybridProcessor.Tuning()
 	
 	return PerformanceMetrics{
 		Timestamp:       time.Now(),
@@ -313,9 +317,11 @@ func (ai *AILoadBalancer) collectCurrentMetrics() PerformanceMetrics {
 		MemoryUsage:     stats.MemoryUsage,
 		GPUMemoryUsage:  stats.GPUMemoryUsage,
 		AvgLatency:      float64(stats.AvgLatency.Milliseconds()),
-		BatchSize:       ai.estimateCurrentBatchSize(),
-		CurrentStrategy: ai.getCurrentStrategy(),
-		QueueDepth:      ai.getQueueDepth(),
+		BatchSize:       estimateCurrentBatchSize(stats, tuning),
+		CurrentStrategy: getCurrentStrategy(stats, tuning),
+		QueueDepth:      int(stats.QueueDepth),
+		CPUGPURatio:     tuning.CPUGPURatio,
+		GPUThreshold:    tuning.GPUThreshold,
 	}
 }
 
@@ -346,6 +352,8 @@ CURRENT PERFORMANCE:
 - Batch Size: %d (optimal: 100K-200K)
 - Current Strategy: %s
 - Queue Depth: %d (capacity: 2.5M)
+- CPU/GPU Ratio: %.2f
+- GPU Threshold: %d (minimum batch size sent to the GPU)
 
 RECENT TRENDS (last %d measurements):
 %s
@@ -353,9 +361,12 @@ RECENT TRENDS (last %d measurements):
 AI DECISION REQUIRED:
 With massive TPS optimizations in place, aggressively optimize for:
 1. CPU/GPU ratio (0.0 = all CPU, 1.0 = all GPU) - favor GPU heavily
-2. Processing strategy (CPU_ONLY, GPU_ONLY, HYBRID) - prefer GPU/HYBRID
-3. Confidence level (0.0-1.0) - be more aggressive with high confidence
-4. Brief reasoning focused on maximizing TPS
+2. Processing strategy (CPU_ONLY, GPU_ONLY, HYBRID, AUTO) - prefer GPU/HYBRID
+3. Batch size and GPU threshold (0 keeps the current value)
+4. Confidence level (0.0-1.0) - be more aggressive with high confidence
+5. Brief reasoning focused on maximizing TPS
+
+Changes are applied in bounded steps and rolled back if TPS or latency regress.
 
 OPTIMIZATION PRIORITIES:
 - Push GPU to 95-98%% utilization (RTX 4000 SFF Ada sweet spot)
@@ -367,6 +378,8 @@ Respond in JSON format:
 {
   "ratio": 0.92,
   "strategy": "GPU_ONLY",
+  "batchSize": 100000,
+  "gpuThreshold": 1000,
   "confidence": 0.95,
   "reasoning": "GPU underutilized at X%%, can push to 95%% for massive TPS gain"
 }`,
@@ -377,6 +390,8 @@ Respond in JSON format:
 		current.BatchSize,
 		current.CurrentStrategy,
 		current.QueueDepth,
+		current.CPUGPURatio,
+		current.GPUThreshold,
 		historySize,
 		ai.formatHistoryForPrompt(recentHistory),
 	)
@@ -413,10 +428,12 @@ func (ai *AILoadBalancer) parseAIResponse(response string) (LoadPrediction, erro
 	jsonStr := response[start : end+1]
 	
 	var aiResponse struct {
-		Ratio      float64 `json:"ratio"`
-		Strategy   string  `json:"strategy"`
-		Confidence float64 `json:"confidence"`
-		Reasoning  string  `json:"reasoning"`
+		Ratio        float64 `json:"ratio"`
+		Strategy     string  `json:"strategy"`
+		BatchSize    int     `json:"batchSize"`
+		GPUThreshold int     `json:"gpuThreshold"`
+		Confidence   float64 `json:"confidence"`
+		Reasoning    string  `json:"reasoning"`
 	}
 	
 	if err := json.Unmarshal([]byte(jsonStr), &aiResponse); err != nil {
@@ -433,10 +450,20 @@ func (ai *AILoadBalancer) parseAIResponse(response string) (LoadPrediction, erro
 		aiResponse.Confidence = 0.5 // Default confidence
 	}
 	
+	if aiResponse.BatchSize < 0 {
+		aiResponse.BatchSize = 0 // Keep current batch size
+	}
+	
+	if aiResponse.GPUThreshold < 0 {
+		aiResponse.GPUThreshold = 0 // Keep current threshold
+	}
+	
 	return LoadPrediction{
 		Timestamp:         time.Now(),
 		RecommendedRatio:  aiResponse.Ratio,
 		RecommendedStrategy: aiResponse.Strategy,
+		RecommendedBatchSize: aiResponse.BatchSize,
+		RecommendedThreshold: aiResponse.GPUThreshold,
 		Confidence:        aiResponse.Confidence,
 		Reasoning:         aiResponse.Reasoning,
 	}, nil
@@ -493,8 +520,14 @@ func (ai *AILoadBalancer) parseTextResponse(response string) (LoadPrediction, er
 	}, nil
 }
 
-// applyAIDecision applies the AI recommendation to the hybrid processor
+// applyAIDecision retunes the hybrid processor towards the AI recommendation
+// if its confidence is high enough. The hybrid processor bounds every retune
+// and rolls it back if the performance regresses, the outcome is recorded on
+// the decision once known.
 func (ai *AILoadBalancer) applyAIDecision(prediction LoadPrediction, currentMetrics PerformanceMetrics) {
+	// Learn from the outcome of the previous retunes
+	ai.settleDecisions()
+	
 	// Store the decision
 	decision := AIDecision{
 		Timestamp: time.Now(),
@@ -502,6 +535,12 @@ func (ai *AILoadBalancer) applyAIDecision(prediction LoadPrediction, currentMetr
 		Decision:  prediction,
 	}
 	
+	if prediction.Confidence >= ai.threshold {
+		decision.Generation = ai.retune(prediction)
+	} else {
+		log.Debug("AI confidence too low, skipping decision", "confidence", prediction.Confidence)
+	}
+	
 	ai.mu.Lock()
 	ai.decisionHistory = append(ai.decisionHistory, decision)
 	if len(ai.decisionHistory) > 50 {
@@ -513,15 +552,83 @@ func (ai *AILoadBalancer) applyAIDecision(prediction LoadPrediction, currentMetr
 		ai.predictions = ai.predictions[1:]
 	}
 	ai.mu.Unlock()
+}
+
+// retune moves the hybrid processor tuning towards the recommendation,
+// returning the generation of the retune or 0 if nothing was retuned.
+func (ai *AILoadBalancer) retune(prediction LoadPrediction) uint64 {
+	current := ai.hybridProcessor.Tuning()
 	
-	// Apply the decision to hybrid processor
-	// Note: This would require extending the hybrid processor to accept AI recommendations
-	log.Info("AI load balancing decision applied",
-		"ratio", prediction.RecommendedRatio,
-		"strategy", prediction.RecommendedStrategy,
-		"confidence", prediction.Confidence,
-		"reasoning", prediction.Reasoning,
-	)
+	target := current
+	target.CPUGPURatio = prediction.RecommendedRatio
+	if prediction.RecommendedBatchSize > 0 {
+		target.BatchSize = prediction.RecommendedBatchSize
+	}
+	if prediction.RecommendedThreshold > 0 {
+		target.GPUThreshold = prediction.RecommendedThreshold
+	}
+	if strategy, err := hybrid.ParseProcessingStrategy(prediction.RecommendedStrategy); err == nil {
+		target.StrategyOverride = strategy
+	} else {
+		log.Debug("Ignoring AI recommended strategy", "error", err)
+	}
+	
+	generation := ai.hybridProcessor.TuningResult().Generation
+	applied, err := ai.hybridProcessor.Retune(target)
+	if err != nil {
+		log.Debug("AI load balancing decision not applied", "error", err)
+		return 0
+	}
+	if result := ai.hybridProcessor.TuningResult(); result.Generation != generation {
+		log.Info("AI load balancing decision applied",
+			"ratio", applied.CPUGPURatio,
+			"threshold", applied.GPUThreshold,
+			"batchSize", applied.BatchSize,
+			"strategy", applied.StrategyOverride,
+			"confidence", prediction.Confidence,
+			"reasoning", prediction.Reasoning,
+		)
+		return result.Generation
+	}
+	return 0
+}
+
+// settleDecisions records the outcome of the retunes applied for previous
+// decisions, adjusting the confidence towards the observed success rate.
+func (ai *AILoadBalancer) settleDecisions() {
+	result := ai.hybridProcessor.TuningResult()
+	
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+	
+	for i := range ai.decisionHistory {
+		decision := &ai.decisionHistory[i]
+		if decision.Generation == 0 || decision.settled {
+			continue
+		}
+		if decision.Generation == result.Generation && result.Pending {
+			continue
+		}
+		decision.settled = true
+		
+		// Retunes superseded or applied without a baseline have no outcome
+		if decision.Generation != result.Generation || result.Measured.Batches == 0 {
+			continue
+		}
+		decision.Success = !result.RolledBack
+		decision.ActualOutcome = decision.Input
+		decision.ActualOutcome.TotalTPS = result.Measured.TPS
+		decision.ActualOutcome.AvgLatency = float64(result.Measured.Latency.Milliseconds())
+		if result.Baseline.TPS > 0 {
+			decision.PerformanceGain = (float64(result.Measured.TPS) - float64(result.Baseline.TPS)) / float64(result.Baseline.TPS)
+		}
+		
+		outcome := 0.0
+		if decision.Success {
+			outcome = 1.0
+		}
+		ai.confidence += ai.learningRate * (outcome - ai.confidence)
+	}
 }
 
 // fallbackDecision makes a simple rule-based decision when AI fails
@@ -542,7 +649,7 @@ func (ai *AILoadBalancer) fallbackDecision(metrics PerformanceMetrics) {
 		Timestamp:         time.Now(),
 		RecommendedRatio:  ratio,
 		RecommendedStrategy: strategy,
-		Confidence:        0.6, // Lower confidence for fallback
+		Confidence:        0.6, // Lower confidence for fallback, below the default threshold
 		Reasoning:         "Fallback rule-based decision",
 	}
 	
@@ -550,19 +657,20 @@ func (ai *AILoadBalancer) fallbackDecision(metrics PerformanceMetrics) {
 }
 
 // Helper functions
-func (ai *AILoadBalancer) estimateCurrentBatchSize() int {
-	// This would be implemented based on current transaction queue
-	return 10000 // Placeholder
-}
-
-func (ai *AILoadBalancer) getCurrentStrategy() string {
-	// This would query the hybrid processor for current strategy
-	return "HYBRID" // Placeholder
+func estimateCurrentBatchSize(stats hybrid.HybridStats, tuning hybrid.Tuning) int {
+	// Prefer the tuned batch size over the observed one
+	if tuning.BatchSize > 0 {
+		return tuning.BatchSize
+	}
+	return int(stats.AvgBatchSize)
 }
 
-func (ai *AILoadBalancer) getQueueDepth() int {
-	// This would query the current queue depth
-	return 1000 // Placeholder
+func getCurrentStrategy(stats hybrid.HybridStats, tuning hybrid.Tuning) string {
+	// Overridden strategies apply to every batch reaching the GPU threshold
+	if tuning.StrategyOverride != hybrid.ProcessingStrategyAuto {
+		return tuning.StrategyOverride.String()
+	}
+	return stats.LastStrategy.String()
 }
 
 // GetStats returns current AI load balancer statistics
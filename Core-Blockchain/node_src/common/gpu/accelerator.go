@@ -0,0 +1,134 @@
+// Copyright 2024 The Splendor Authors
+// Pluggable accelerator backends running the batch kernels
+
+package gpu
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Kernel identifies one of the batch kernels of an accelerator.
+type Kernel uint8
+
+const (
+	KernelHashes       Kernel = iota // Keccak256 over 256 byte input slots
+	KernelSignatures                 // secp256k1 signature verification
+	KernelTransactions               // transaction decoding over 1024 byte input slots
+)
+
+// String implements fmt.Stringer.
+func (k Kernel) String() string {
+	switch k {
+	case KernelHashes:
+		return "hashes"
+	case KernelSignatures:
+		return "signatures"
+	case KernelTransactions:
+		return "transactions"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(k))
+	}
+}
+
+// Buffer layouts of the kernels.
+const (
+	hashSlotSize     = 256 // input bytes per hash
+	hashInputSize    = 32  // input bytes hashed by the kernels, the slot is not length prefixed
+	hashResultSize   = 32  // output bytes per hash
+	sigSignatureSize = 65  // R || S || V
+	sigMessageSize   = 32  // signed hash
+	sigPublicKeySize = 64  // uncompressed X || Y, as read by the kernels
+	sigItemSize      = sigSignatureSize + sigMessageSize + sigPublicKeySize
+	txSlotSize       = 1024 // input bytes per transaction
+	txOutputSize     = 64   // output bytes per transaction
+
+	// CUDA transaction results: validity flag, checksum of the encoding and
+	// the little endian gas limit
+	cudaTxValidOffset    = 0
+	cudaTxChecksumOffset = 1
+	cudaTxGasOffset      = 2
+
+	// OpenCL transaction results: hash and validity flag, no gas is reported
+	openclTxHashOffset  = 0
+	openclTxValidOffset = 32
+)
+
+// Accelerator is a device backend running the batch kernels over raw buffers.
+// The buffer layouts follow the kernel family reported by Type: both take the
+// inputs in fixed size slots, but they differ in the signature input and the
+// transaction output layouts. The GPUProcessor packs and decodes the buffers,
+// falling back to the CPU whenever a kernel fails.
+type Accelerator interface {
+	// Type reports the kernel family, which determines the buffer layouts.
+	Type() GPUType
+
+	// Init initialises the backend, returning the number of devices.
+	Init() (int, error)
+
+	// ProcessHashes hashes count inputs packed in 256 byte slots into 32 byte
+	// outputs. Only the leading 32 bytes of every slot are hashed.
+	ProcessHashes(in []byte, count int, out []byte) error
+
+	// VerifySignatures checks count signatures, writing one 0/1 byte per
+	// signature. CUDA kernels read the signatures, messages and public keys
+	// from separate buffers with fixed strides, OpenCL ones read them packed
+	// in 161 byte items from sigs, leaving msgs and keys nil.
+	VerifySignatures(sigs, msgs, keys []byte, count int, out []byte) error
+
+	// ProcessTransactions decodes count transactions packed in 1024 byte slots
+	// into 64 byte results.
+	ProcessTransactions(in []byte, count int, out []byte) error
+
+	// Close releases the devices.
+	Close()
+}
+
+// AcceleratorFactory creates an accelerator backend for a configuration.
+type AcceleratorFactory func(config *GPUConfig) Accelerator
+
+var (
+	acceleratorsLock sync.RWMutex
+	accelerators     = make(map[string]AcceleratorFactory)
+)
+
+// RegisterAccelerator makes an accelerator backend available under the given
+// name. It panics if the name is already taken.
+func RegisterAccelerator(name string, factory AcceleratorFactory) {
+	acceleratorsLock.Lock()
+	defer acceleratorsLock.Unlock()
+
+	if factory == nil {
+		panic("gpu: nil accelerator factory for " + name)
+	}
+	if _, ok := accelerators[name]; ok {
+		panic("gpu: accelerator registered twice: " + name)
+	}
+	accelerators[name] = factory
+}
+
+// Accelerators returns the names of the registered accelerator backends.
+func Accelerators() []string {
+	acceleratorsLock.RLock()
+	defer acceleratorsLock.RUnlock()
+
+	names := make([]string, 0, len(accelerators))
+	for name := range accelerators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newAccelerator creates the accelerator backend registered under name.
+func newAccelerator(name string, config *GPUConfig) (Accelerator, error) {
+	acceleratorsLock.RLock()
+	factory, ok := accelerators[name]
+	acceleratorsLock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown accelerator %q", name)
+	}
+	return factory(config), nil
+}
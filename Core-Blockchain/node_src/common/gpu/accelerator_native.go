@@ -0,0 +1,155 @@
+//go:build cgo && gpu
+// +build cgo,gpu
+
+// Copyright 2024 The Splendor Authors
+// CUDA and OpenCL accelerator backends
+
+package gpu
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+/*
+#cgo LDFLAGS: -L${SRCDIR} -lcuda_kernels -lOpenCL -L/usr/local/cuda/lib64 -lcudart -Wl,-rpath,${SRCDIR} -Wl,-rpath,/usr/local/cuda/lib64
+
+#include <stdlib.h>
+#include <string.h>
+
+// CUDA function declarations (implemented in cuda_kernels.cu)
+int cuda_init_device();
+int cuda_process_transactions(void* txs, int count, void* results);
+int cuda_process_hashes(void* hashes, int count, void* results);
+int cuda_verify_signatures(void* sigs, void* msgs, void* keys, int count, void* results);
+void cuda_cleanup();
+
+// CUDA function declarations (stubs for now - can be replaced with real implementations)
+int initCUDA();
+int processTxBatchCUDA(void* txData, int txCount, void* results);
+int processHashesCUDA(void* hashes, int count, void* results);
+int verifySignaturesCUDA(void* signatures, int count, void* results);
+void cleanupCUDA();
+
+// OpenCL function declarations (implemented in opencl_kernels.c)
+int initOpenCL();
+int processTxBatchOpenCL(void* txData, int txCount, void* results);
+int processHashesOpenCL(void* hashes, int count, void* results);
+int verifySignaturesOpenCL(void* signatures, int count, void* results);
+void cleanupOpenCL();
+
+// Working stub implementations for CUDA (can be replaced when CUDA is properly configured)
+int initCUDA() {
+    // Return -1 to indicate CUDA not available, system will fall back to OpenCL or CPU
+    return -1;
+}
+
+int processHashesCUDA(void* hashes, int count, void* results) {
+    return -1; // Not implemented, will fall back to OpenCL or CPU
+}
+
+int verifySignaturesCUDA(void* signatures, int count, void* results) {
+    return -1; // Not implemented, will fall back to OpenCL or CPU
+}
+
+int processTxBatchCUDA(void* txData, int txCount, void* results) {
+    return -1; // Not implemented, will fall back to OpenCL or CPU
+}
+
+void cleanupCUDA() {
+    // No-op for stub implementation
+}
+*/
+import "C"
+
+func init() {
+	RegisterAccelerator("cuda", func(*GPUConfig) Accelerator { return cudaAccelerator{} })
+	RegisterAccelerator("opencl", func(*GPUConfig) Accelerator { return openclAccelerator{} })
+}
+
+// kernelError converts the status code of a native kernel into an error.
+func kernelError(kernel Kernel, code C.int) error {
+	if code != 0 {
+		return fmt.Errorf("%v kernel failed: %d", kernel, int(code))
+	}
+	return nil
+}
+
+// cudaAccelerator runs the batch kernels of cuda_kernels.cu.
+type cudaAccelerator struct{}
+
+func (cudaAccelerator) Type() GPUType { return GPUTypeCUDA }
+
+func (cudaAccelerator) Init() (int, error) {
+	if result := C.cuda_init_device(); result > 0 {
+		return int(result), nil
+	}
+	return 0, errors.New("no CUDA device")
+}
+
+func (cudaAccelerator) ProcessHashes(in []byte, count int, out []byte) error {
+	return kernelError(KernelHashes, C.cuda_process_hashes(
+		unsafe.Pointer(&in[0]),
+		C.int(count),
+		unsafe.Pointer(&out[0]),
+	))
+}
+
+func (cudaAccelerator) VerifySignatures(sigs, msgs, keys []byte, count int, out []byte) error {
+	return kernelError(KernelSignatures, C.cuda_verify_signatures(
+		unsafe.Pointer(&sigs[0]),
+		unsafe.Pointer(&msgs[0]),
+		unsafe.Pointer(&keys[0]),
+		C.int(count),
+		unsafe.Pointer(&out[0]),
+	))
+}
+
+func (cudaAccelerator) ProcessTransactions(in []byte, count int, out []byte) error {
+	return kernelError(KernelTransactions, C.cuda_process_transactions(
+		unsafe.Pointer(&in[0]),
+		C.int(count),
+		unsafe.Pointer(&out[0]),
+	))
+}
+
+func (cudaAccelerator) Close() { C.cuda_cleanup() }
+
+// openclAccelerator runs the batch kernels of opencl_kernels.c.
+type openclAccelerator struct{}
+
+func (openclAccelerator) Type() GPUType { return GPUTypeOpenCL }
+
+func (openclAccelerator) Init() (int, error) {
+	if result := C.initOpenCL(); result > 0 {
+		return int(result), nil
+	}
+	return 0, errors.New("no OpenCL device")
+}
+
+func (openclAccelerator) ProcessHashes(in []byte, count int, out []byte) error {
+	return kernelError(KernelHashes, C.processHashesOpenCL(
+		unsafe.Pointer(&in[0]),
+		C.int(count),
+		unsafe.Pointer(&out[0]),
+	))
+}
+
+func (openclAccelerator) VerifySignatures(sigs, msgs, keys []byte, count int, out []byte) error {
+	return kernelError(KernelSignatures, C.verifySignaturesOpenCL(
+		unsafe.Pointer(&sigs[0]),
+		C.int(count),
+		unsafe.Pointer(&out[0]),
+	))
+}
+
+func (openclAccelerator) ProcessTransactions(in []byte, count int, out []byte) error {
+	return kernelError(KernelTransactions, C.processTxBatchOpenCL(
+		unsafe.Pointer(&in[0]),
+		C.int(count),
+		unsafe.Pointer(&out[0]),
+	))
+}
+
+func (openclAccelerator) Close() { C.cleanupOpenCL() }
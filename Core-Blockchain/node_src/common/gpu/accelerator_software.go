@@ -0,0 +1,238 @@
+// Copyright 2024 The Splendor Authors
+// Deterministic software accelerator emulating the GPU kernels
+
+package gpu
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func init() {
+	RegisterAccelerator("software", func(config *GPUConfig) Accelerator {
+		return NewSoftwareAccelerator(config.PreferredGPUType, 1)
+	})
+}
+
+// Fault is a failure injected into a kernel of the software accelerator.
+type Fault uint8
+
+const (
+	FaultNone   Fault = iota // kernel runs normally
+	FaultError               // kernel returns an error
+	FaultPanic               // kernel panics
+	FaultReject              // kernel reports every signature and transaction invalid
+)
+
+// String implements fmt.Stringer.
+func (f Fault) String() string {
+	switch f {
+	case FaultNone:
+		return "none"
+	case FaultError:
+		return "error"
+	case FaultPanic:
+		return "panic"
+	case FaultReject:
+		return "reject"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(f))
+	}
+}
+
+// errInjectedFault is returned by kernels failing with FaultError.
+var errInjectedFault = errors.New("injected kernel fault")
+
+// SoftwareAccelerator is an accelerator running the batch kernels on the CPU.
+// It reads and writes the same buffer layouts as the CUDA or OpenCL kernels,
+// but computes exact results: Keccak256 hashes, secp256k1 signature checks and
+// full transaction decoding. Faults can be injected into every kernel to
+// exercise the fallback paths of its users.
+type SoftwareAccelerator struct {
+	gpuType GPUType
+	devices int
+
+	lock   sync.Mutex
+	faults map[Kernel]Fault
+	calls  map[Kernel]int
+}
+
+// NewSoftwareAccelerator creates a software accelerator laid out like the given
+// kernel family, CUDA unless OpenCL is requested. Its initialisation fails if
+// it is given no devices.
+func NewSoftwareAccelerator(gpuType GPUType, devices int) *SoftwareAccelerator {
+	if gpuType != GPUTypeOpenCL {
+		gpuType = GPUTypeCUDA
+	}
+	return &SoftwareAccelerator{
+		gpuType: gpuType,
+		devices: devices,
+		faults:  make(map[Kernel]Fault),
+		calls:   make(map[Kernel]int),
+	}
+}
+
+// InjectFault makes all subsequent runs of a kernel fail with the given fault,
+// FaultNone restores it.
+func (a *SoftwareAccelerator) InjectFault(kernel Kernel, fault Fault) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.faults[kernel] = fault
+}
+
+// Calls returns the number of times a kernel was run, including failed runs.
+func (a *SoftwareAccelerator) Calls(kernel Kernel) int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.calls[kernel]
+}
+
+// run accounts for a kernel run and applies the injected fault. It reports
+// whether the kernel should reject its whole batch.
+func (a *SoftwareAccelerator) run(kernel Kernel) (bool, error) {
+	a.lock.Lock()
+	a.calls[kernel]++
+	fault := a.faults[kernel]
+	a.lock.Unlock()
+
+	switch fault {
+	case FaultError:
+		return false, fmt.Errorf("%v kernel failed: %w", kernel, errInjectedFault)
+	case FaultPanic:
+		panic(fmt.Sprintf("%v kernel: %v", kernel, errInjectedFault))
+	case FaultReject:
+		return true, nil
+	}
+	return false, nil
+}
+
+// Type implements Accelerator.
+func (a *SoftwareAccelerator) Type() GPUType { return a.gpuType }
+
+// Init implements Accelerator.
+func (a *SoftwareAccelerator) Init() (int, error) {
+	if a.devices <= 0 {
+		return 0, errors.New("no software devices")
+	}
+	return a.devices, nil
+}
+
+// ProcessHashes implements Accelerator.
+func (a *SoftwareAccelerator) ProcessHashes(in []byte, count int, out []byte) error {
+	if _, err := a.run(KernelHashes); err != nil {
+		return err
+	}
+	if len(in) < count*hashSlotSize || len(out) < count*hashResultSize {
+		return fmt.Errorf("%v kernel: short buffers for %d items", KernelHashes, count)
+	}
+	for i := 0; i < count; i++ {
+		slot := in[i*hashSlotSize:]
+		copy(out[i*hashResultSize:], crypto.Keccak256(slot[:hashInputSize]))
+	}
+	return nil
+}
+
+// VerifySignatures implements Accelerator.
+func (a *SoftwareAccelerator) VerifySignatures(sigs, msgs, keys []byte, count int, out []byte) error {
+	reject, err := a.run(KernelSignatures)
+	if err != nil {
+		return err
+	}
+	// Locate the inputs of an item in the layout of the kernel family
+	item := func(i int) (sig, msg, key []byte) {
+		if a.gpuType == GPUTypeOpenCL {
+			packed := sigs[i*sigItemSize:]
+			return packed[:sigSignatureSize], packed[sigSignatureSize : sigSignatureSize+sigMessageSize],
+				packed[sigSignatureSize+sigMessageSize : sigItemSize]
+		}
+		return sigs[i*sigSignatureSize : (i+1)*sigSignatureSize], msgs[i*sigMessageSize : (i+1)*sigMessageSize],
+			keys[i*sigPublicKeySize : (i+1)*sigPublicKeySize]
+	}
+	if a.gpuType == GPUTypeOpenCL {
+		if len(sigs) < count*sigItemSize || len(out) < count {
+			return fmt.Errorf("%v kernel: short buffers for %d items", KernelSignatures, count)
+		}
+	} else if len(sigs) < count*sigSignatureSize || len(msgs) < count*sigMessageSize || len(keys) < count*sigPublicKeySize || len(out) < count {
+		return fmt.Errorf("%v kernel: short buffers for %d items", KernelSignatures, count)
+	}
+	for i := 0; i < count; i++ {
+		sig, msg, key := item(i)
+		out[i] = 0
+		if !reject && crypto.VerifySignature(append([]byte{0x04}, key...), msg, sig[:64]) {
+			out[i] = 1
+		}
+	}
+	return nil
+}
+
+// ProcessTransactions implements Accelerator.
+func (a *SoftwareAccelerator) ProcessTransactions(in []byte, count int, out []byte) error {
+	reject, err := a.run(KernelTransactions)
+	if err != nil {
+		return err
+	}
+	if len(in) < count*txSlotSize || len(out) < count*txOutputSize {
+		return fmt.Errorf("%v kernel: short buffers for %d items", KernelTransactions, count)
+	}
+	for i := 0; i < count; i++ {
+		result := out[i*txOutputSize : (i+1)*txOutputSize]
+		for j := range result {
+			result[j] = 0
+		}
+		tx, enc := decodeTxSlot(in[i*txSlotSize : (i+1)*txSlotSize])
+		valid := tx != nil && !reject
+
+		if a.gpuType == GPUTypeOpenCL {
+			if enc != nil {
+				copy(result[openclTxHashOffset:], crypto.Keccak256(enc))
+			}
+			if valid {
+				result[openclTxValidOffset] = 1
+			}
+			continue
+		}
+		if !valid {
+			continue
+		}
+		var checksum byte
+		for _, b := range enc {
+			checksum ^= b
+		}
+		result[cudaTxValidOffset] = 1
+		result[cudaTxChecksumOffset] = checksum
+		binary.LittleEndian.PutUint64(result[cudaTxGasOffset:], tx.Gas())
+	}
+	return nil
+}
+
+// Close implements Accelerator.
+func (a *SoftwareAccelerator) Close() {}
+
+// decodeTxSlot decodes the transaction packed into a zero padded slot,
+// returning it along with its encoding. The encoding is also returned if it
+// is well framed but doesn't decode into a transaction.
+func decodeTxSlot(slot []byte) (*types.Transaction, []byte) {
+	body := slot
+	if len(slot) > 0 && slot[0] < 0x80 {
+		body = slot[1:] // typed transaction envelope
+	}
+	_, _, rest, err := rlp.Split(body)
+	if err != nil {
+		return nil, nil
+	}
+	enc := slot[:len(slot)-len(rest)]
+
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(enc); err != nil {
+		return nil, enc
+	}
+	return tx, enc
+}
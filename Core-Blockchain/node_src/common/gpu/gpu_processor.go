@@ -1,72 +1,26 @@
- //go:build cgo && gpu
-// +build cgo,gpu
-
 package gpu
 
 import (
 	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
-	"unsafe"
 
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 )
 
-/*
-#cgo LDFLAGS: -L${SRCDIR} -lcuda_kernels -lOpenCL -L/usr/local/cuda/lib64 -lcudart -Wl,-rpath,${SRCDIR} -Wl,-rpath,/usr/local/cuda/lib64
-
-#include <stdlib.h>
-#include <string.h>
-
-// CUDA function declarations (implemented in cuda_kernels.cu)
-int cuda_init_device();
-int cuda_process_transactions(void* txs, int count, void* results);
-int cuda_process_hashes(void* hashes, int count, void* results);
-int cuda_verify_signatures(void* sigs, void* msgs, void* keys, int count, void* results);
-void cuda_cleanup();
-
-// CUDA function declarations (stubs for now - can be replaced with real implementations)
-int initCUDA();
-int processTxBatchCUDA(void* txData, int txCount, void* results);
-int processHashesCUDA(void* hashes, int count, void* results);
-int verifySignaturesCUDA(void* signatures, int count, void* results);
-void cleanupCUDA();
-
-// OpenCL function declarations (implemented in opencl_kernels.c)
-int initOpenCL();
-int processTxBatchOpenCL(void* txData, int txCount, void* results);
-int processHashesOpenCL(void* hashes, int count, void* results);
-int verifySignaturesOpenCL(void* signatures, int count, void* results);
-void cleanupOpenCL();
-
-// Working stub implementations for CUDA (can be replaced when CUDA is properly configured)
-int initCUDA() { 
-    // Return -1 to indicate CUDA not available, system will fall back to OpenCL or CPU
-    return -1; 
-}
-
-int processHashesCUDA(void* hashes, int count, void* results) { 
-    return -1; // Not implemented, will fall back to OpenCL or CPU
-}
+var (
+	// errMissingPrevalidator is returned when a transaction batch is submitted
+	// without the context needed to validate it.
+	errMissingPrevalidator = errors.New("missing transaction prevalidator")
 
-int verifySignaturesCUDA(void* signatures, int count, void* results) {
-    return -1; // Not implemented, will fall back to OpenCL or CPU
-}
-
-int processTxBatchCUDA(void* txData, int txCount, void* results) { 
-    return -1; // Not implemented, will fall back to OpenCL or CPU
-}
-
-void cleanupCUDA() { 
-    // No-op for stub implementation
-}
-*/
-import "C"
+	// errKernelRejected is reported for transactions the GPU kernel failed to decode.
+	errKernelRejected = errors.New("transaction rejected by GPU kernel")
+)
 
 // GPUType represents the type of GPU acceleration
 type GPUType int
@@ -77,8 +31,23 @@ const (
 	GPUTypeOpenCL
 )
 
+// String implements fmt.Stringer.
+func (t GPUType) String() string {
+	switch t {
+	case GPUTypeNone:
+		return "none"
+	case GPUTypeCUDA:
+		return "cuda"
+	case GPUTypeOpenCL:
+		return "opencl"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(t))
+	}
+}
+
 // GPUProcessor provides GPU-accelerated blockchain operations
 type GPUProcessor struct {
+	accel           Accelerator
 	gpuType         GPUType
 	deviceCount     int
 	maxBatchSize    int
@@ -105,12 +74,11 @@ type GPUProcessor struct {
 	
 	// Memory management
 	memoryPool      sync.Pool
-	cudaStreams     []unsafe.Pointer
-	openclQueues    []unsafe.Pointer
 }
 
 // GPUConfig holds configuration for GPU processing
 type GPUConfig struct {
+	Accelerator      string  `json:"accelerator"` // registered backend, empty picks the native one of PreferredGPUType
 	PreferredGPUType GPUType `json:"preferredGpuType"`
 	MaxBatchSize     int     `json:"maxBatchSize"`
 	MaxMemoryUsage   uint64  `json:"maxMemoryUsage"`
@@ -153,19 +121,11 @@ type SignatureBatch struct {
 // TransactionBatch represents a batch of transactions to process
 type TransactionBatch struct {
 	Transactions []*types.Transaction
+	Validator    *TxPrevalidator // stateless checks only, see TxPrevalidator
 	Results      []*TxResult
 	Callback     func([]*TxResult, error)
 }
 
-// TxResult holds the result of GPU transaction processing
-type TxResult struct {
-	Hash      common.Hash
-	Valid     bool
-	GasUsed   uint64
-	Error     error
-	Signature []byte
-}
-
 // NewGPUProcessor creates a new GPU processor
 func NewGPUProcessor(config *GPUConfig) (*GPUProcessor, error) {
 	if config == nil {
@@ -192,7 +152,7 @@ func NewGPUProcessor(config *GPUConfig) (*GPUProcessor, error) {
 	}
 	
 	// Try to initialize GPU
-	if err := processor.initializeGPU(config.PreferredGPUType); err != nil {
+	if err := processor.initializeGPU(config); err != nil {
 		log.Warn("GPU initialization failed, falling back to CPU", "error", err)
 		processor.gpuType = GPUTypeNone
 	}
@@ -209,31 +169,56 @@ func NewGPUProcessor(config *GPUConfig) (*GPUProcessor, error) {
 	return processor, nil
 }
 
-// initializeGPU attempts to initialize GPU acceleration
-func (p *GPUProcessor) initializeGPU(preferredType GPUType) error {
-	// Try CUDA first if preferred or if no preference
-	if preferredType == GPUTypeCUDA || preferredType == GPUTypeNone {
-		if result := C.cuda_init_device(); result > 0 {
-			p.gpuType = GPUTypeCUDA
-			p.deviceCount = int(result)
-			log.Info("CUDA GPU acceleration enabled", "devices", p.deviceCount)
-			return nil
+// initializeGPU attempts to initialize GPU acceleration, either on the configured
+// accelerator backend or on the native ones matching the preferred GPU type.
+func (p *GPUProcessor) initializeGPU(config *GPUConfig) error {
+	if config.Accelerator != "" {
+		accel, err := newAccelerator(config.Accelerator, config)
+		if err != nil {
+			return err
 		}
+		return p.initializeAccelerator(config.Accelerator, accel)
 	}
-	
-	// Try OpenCL if CUDA failed or if preferred
-	if preferredType == GPUTypeOpenCL || preferredType == GPUTypeNone {
-		if result := C.initOpenCL(); result > 0 {
-			p.gpuType = GPUTypeOpenCL
-			p.deviceCount = int(result)
-			log.Info("OpenCL GPU acceleration enabled", "devices", p.deviceCount, "type", "RTX 4000 SFF Ada")
-			return nil
+	// Try CUDA first if preferred or if no preference, then OpenCL. The native
+	// backends are only registered in builds with the 'gpu' tag.
+	var names []string
+	if config.PreferredGPUType == GPUTypeCUDA || config.PreferredGPUType == GPUTypeNone {
+		names = append(names, "cuda")
+	}
+	if config.PreferredGPUType == GPUTypeOpenCL || config.PreferredGPUType == GPUTypeNone {
+		names = append(names, "opencl")
+	}
+	for _, name := range names {
+		accel, err := newAccelerator(name, config)
+		if err != nil {
+			continue
 		}
+		if err := p.initializeAccelerator(name, accel); err != nil {
+			log.Debug("GPU accelerator unavailable", "accelerator", name, "error", err)
+			continue
+		}
+		return nil
 	}
-	
 	return errors.New("no GPU acceleration available")
 }
 
+// initializeAccelerator initializes the devices of an accelerator backend and
+// switches the processor over to it.
+func (p *GPUProcessor) initializeAccelerator(name string, accel Accelerator) error {
+	devices, err := accel.Init()
+	if err != nil {
+		return err
+	}
+	if devices <= 0 {
+		return fmt.Errorf("accelerator %q has no devices", name)
+	}
+	p.accel = accel
+	p.gpuType = accel.Type()
+	p.deviceCount = devices
+	log.Info("GPU acceleration enabled", "accelerator", name, "type", p.gpuType, "devices", p.deviceCount)
+	return nil
+}
+
 // startWorkers starts the GPU worker goroutines
 func (p *GPUProcessor) startWorkers(config *GPUConfig) {
 	// Hash processing workers
@@ -303,8 +288,13 @@ func (p *GPUProcessor) ProcessSignaturesBatch(signatures, messages, publicKeys [
 	}
 }
 
-// ProcessTransactionsBatch processes a batch of transactions using GPU acceleration
-func (p *GPUProcessor) ProcessTransactionsBatch(txs []*types.Transaction, callback func([]*TxResult, error)) error {
+// ProcessTransactionsBatch processes a batch of transactions using GPU acceleration.
+// Only the stateless prevalidation runs here, the state dependent checks need the
+// whole batch in order and are left to the caller (see PrevalidateState).
+func (p *GPUProcessor) ProcessTransactionsBatch(txs []*types.Transaction, validator *TxPrevalidator, callback func([]*TxResult, error)) error {
+	if validator == nil {
+		return errMissingPrevalidator
+	}
 	if len(txs) == 0 {
 		callback(nil, nil)
 		return nil
@@ -312,6 +302,7 @@ func (p *GPUProcessor) ProcessTransactionsBatch(txs []*types.Transaction, callba
 	
 	batch := &TransactionBatch{
 		Transactions: txs,
+		Validator:    validator,
 		Results:      make([]*TxResult, len(txs)),
 		Callback:     callback,
 	}
@@ -416,36 +407,25 @@ func (p *GPUProcessor) processHashesGPU(batch *HashBatch) {
 
 	// Allocate output buffer: 32 bytes per hash
 	count := len(batch.Hashes)
-	out := make([]byte, count*32)
+	out := make([]byte, count*hashResultSize)
 
 	// Process on GPU
-	var result int
-	switch p.gpuType {
-	case GPUTypeCUDA:
-		result = int(C.cuda_process_hashes(
-			unsafe.Pointer(&in[0]),
-			C.int(count),
-			unsafe.Pointer(&out[0]),
-		))
-	case GPUTypeOpenCL:
-		result = int(C.processHashesOpenCL(
-			unsafe.Pointer(&in[0]),
-			C.int(count),
-			unsafe.Pointer(&out[0]),
-		))
-	}
-
-	if result != 0 {
-		log.Warn("GPU hash processing failed, falling back to CPU", "error", result)
+	if err := p.accel.ProcessHashes(in, count, out); err != nil {
+		log.Warn("GPU hash processing failed, falling back to CPU", "error", err)
 		p.processHashesCPU(batch)
 		return
 	}
 
-	// Split flat output into [][]byte
+	// Split flat output into [][]byte, the kernels only hash the leading
+	// hashInputSize bytes of a slot so other inputs are hashed here
 	for i := 0; i < count; i++ {
-		start := i * 32
-		dst := make([]byte, 32)
-		copy(dst, out[start:start+32])
+		if len(batch.Hashes[i]) != hashInputSize {
+			batch.Results[i] = crypto.Keccak256(batch.Hashes[i])
+			continue
+		}
+		start := i * hashResultSize
+		dst := make([]byte, hashResultSize)
+		copy(dst, out[start:start+hashResultSize])
 		batch.Results[i] = dst
 	}
 
@@ -476,7 +456,7 @@ func (p *GPUProcessor) processSignaturesGPU(batch *SignatureBatch) {
 	}()
 
 	// Pack input as [65|32|64] per item (stride 161 bytes)
-	packed := p.prepareSignatureData(batch.Signatures, batch.Messages, batch.PublicKeys)
+	packed, wellFormed := p.prepareSignatureData(batch.Signatures, batch.Messages, batch.PublicKeys)
 	defer p.memoryPool.Put(packed)
 
 	// Output buffer: 1 byte (0/1) per signature
@@ -484,43 +464,34 @@ func (p *GPUProcessor) processSignaturesGPU(batch *SignatureBatch) {
 	out := make([]byte, count)
 
 	// Process on GPU
-	var result int
+	var err error
 	switch p.gpuType {
 	case GPUTypeCUDA: {
 		// CUDA expects separate buffers for signatures, messages, and pubkeys
-		sigs := make([]byte, count*65)
-		msgs := make([]byte, count*32)
-		keys := make([]byte, count*64)
+		sigs := make([]byte, count*sigSignatureSize)
+		msgs := make([]byte, count*sigMessageSize)
+		keys := make([]byte, count*sigPublicKeySize)
 		for i := 0; i < count; i++ {
-			copy(sigs[i*65:(i+1)*65], batch.Signatures[i])
-			copy(msgs[i*32:(i+1)*32], batch.Messages[i])
-			copy(keys[i*64:(i+1)*64], batch.PublicKeys[i])
+			item := packed[i*sigItemSize:]
+			copy(sigs[i*sigSignatureSize:], item[:sigSignatureSize])
+			copy(msgs[i*sigMessageSize:], item[sigSignatureSize:sigSignatureSize+sigMessageSize])
+			copy(keys[i*sigPublicKeySize:], item[sigSignatureSize+sigMessageSize:sigItemSize])
 		}
-		result = int(C.cuda_verify_signatures(
-			unsafe.Pointer(&sigs[0]),
-			unsafe.Pointer(&msgs[0]),
-			unsafe.Pointer(&keys[0]),
-			C.int(count),
-			unsafe.Pointer(&out[0]),
-		))
+		err = p.accel.VerifySignatures(sigs, msgs, keys, count, out)
 	}
-	case GPUTypeOpenCL:
-		result = int(C.verifySignaturesOpenCL(
-			unsafe.Pointer(&packed[0]),
-			C.int(count),
-			unsafe.Pointer(&out[0]),
-		))
+	default:
+		err = p.accel.VerifySignatures(packed, nil, nil, count, out)
 	}
 
-	if result != 0 {
-		log.Warn("GPU signature processing failed, falling back to CPU", "error", result)
+	if err != nil {
+		log.Warn("GPU signature processing failed, falling back to CPU", "error", err)
 		p.processSignaturesCPU(batch)
 		return
 	}
 
-	// Map bytes to bools
+	// Map bytes to bools, malformed items were zeroed and never verify
 	for i := 0; i < count; i++ {
-		batch.Results[i] = out[i] != 0
+		batch.Results[i] = wellFormed[i] && out[i] != 0
 	}
 
 	if batch.Callback != nil {
@@ -531,14 +502,14 @@ func (p *GPUProcessor) processSignaturesGPU(batch *SignatureBatch) {
 // processSignaturesCPU processes signature verification using CPU as fallback
 func (p *GPUProcessor) processSignaturesCPU(batch *SignatureBatch) {
 	for i := range batch.Signatures {
-		if len(batch.Signatures[i]) != 65 || len(batch.Messages[i]) != 32 || len(batch.PublicKeys[i]) != 64 {
+		pubkey := rawPublicKey(batch.PublicKeys[i]) // uncompressed 64-byte (X||Y)
+		if len(batch.Signatures[i]) != sigSignatureSize || len(batch.Messages[i]) != sigMessageSize || pubkey == nil {
 			batch.Results[i] = false
 			continue
 		}
 		sig := batch.Signatures[i][:64] // R||S only
 		hash := batch.Messages[i]
-		pubkey := batch.PublicKeys[i] // uncompressed 64-byte (X||Y)
-		batch.Results[i] = crypto.VerifySignature(pubkey, hash, sig)
+		batch.Results[i] = crypto.VerifySignature(append([]byte{0x04}, pubkey...), hash, sig)
 	}
 	if batch.Callback != nil {
 		batch.Callback(batch.Results, nil)
@@ -555,39 +526,22 @@ func (p *GPUProcessor) processTransactionsGPU(batch *TransactionBatch) {
 	}()
 
 	// Pack input into fixed 1024-byte slots per tx
-	in := p.prepareTransactionData(batch.Transactions)
+	in, packed := p.prepareTransactionData(batch.Transactions)
 	defer p.memoryPool.Put(in)
 
 	count := len(batch.Transactions)
-	// Output buffer: 64 bytes per tx: [0]=valid, [1]=checksum, [2..9]=gas (8 bytes LE), rest reserved
-	out := make([]byte, count*64)
+	out := make([]byte, count*txOutputSize)
 
 	// Process on GPU
-	var result int
-	switch p.gpuType {
-	case GPUTypeCUDA:
-		result = int(C.cuda_process_transactions(
-			unsafe.Pointer(&in[0]),
-			C.int(count),
-			unsafe.Pointer(&out[0]),
-		))
-	case GPUTypeOpenCL:
-		result = int(C.processTxBatchOpenCL(
-			unsafe.Pointer(&in[0]),
-			C.int(count),
-			unsafe.Pointer(&out[0]),
-		))
-	}
-
-	if result != 0 {
-		log.Warn("GPU transaction processing failed, falling back to CPU", "error", result)
+	if err := p.accel.ProcessTransactions(in, count, out); err != nil {
+		log.Warn("GPU transaction processing failed, falling back to CPU", "error", err)
 		p.processTransactionsCPU(batch)
 		return
 	}
 
 	// Convert results
 	for i := 0; i < count; i++ {
-		offset := i * 64
+		offset := i * txOutputSize
 
 		// Interpret result layout based on backend:
 		// - CUDA:   [0]=valid, [1]=checksum, [2..9]=gas (LE)
@@ -596,29 +550,34 @@ func (p *GPUProcessor) processTransactionsGPU(batch *TransactionBatch) {
 		var gas uint64
 		switch p.gpuType {
 		case GPUTypeOpenCL:
-			if len(out) >= offset+33 {
-				valid = out[offset+32] != 0
-			}
+			valid = out[offset+openclTxValidOffset] != 0
 			// No gas provided by OpenCL kernel, will fall back to tx.Gas()
 		default:
-			if len(out) >= offset+1 {
-				valid = out[offset] != 0
-			}
-			if len(out) >= offset+10 {
-				gas = binary.LittleEndian.Uint64(out[offset+2 : offset+10])
-			}
+			valid = out[offset+cudaTxValidOffset] != 0
+			gas = binary.LittleEndian.Uint64(out[offset+cudaTxGasOffset : offset+cudaTxGasOffset+8])
 		}
 
-		if batch.Results[i] == nil {
-			batch.Results[i] = &TxResult{}
+		result := batch.Results[i]
+		if result == nil {
+			result = &TxResult{}
+		}
+		switch {
+		case batch.Validator == nil:
+			result.Valid = valid
+		case valid || !packed[i]:
+			// Transactions that didn't fit their slot were truncated, so the
+			// kernel verdict says nothing about them
+			result = batch.Validator.PrevalidateStateless(batch.Transactions[i])
+		default:
+			result.reject(TxRejectMalformed, errKernelRejected)
 		}
-		batch.Results[i].Hash = batch.Transactions[i].Hash()
-		batch.Results[i].Valid = valid
+		result.Hash = batch.Transactions[i].Hash()
 		if gas > 0 {
-			batch.Results[i].GasUsed = gas
+			result.GasUsed = gas
 		} else {
-			batch.Results[i].GasUsed = batch.Transactions[i].Gas()
+			result.GasUsed = batch.Transactions[i].Gas()
 		}
+		batch.Results[i] = result
 	}
 
 	if batch.Callback != nil {
@@ -628,13 +587,14 @@ func (p *GPUProcessor) processTransactionsGPU(batch *TransactionBatch) {
 
 // processTransactionsCPU processes transactions using CPU as fallback
 func (p *GPUProcessor) processTransactionsCPU(batch *TransactionBatch) {
-	for i, tx := range batch.Transactions {
-		batch.Results[i] = &TxResult{
-			Hash:    tx.Hash(),
-			Valid:   true, // Simplified validation
-			GasUsed: tx.Gas(),
-			Error:   nil,
+	if batch.Validator == nil {
+		if batch.Callback != nil {
+			batch.Callback(nil, errMissingPrevalidator)
 		}
+		return
+	}
+	for i, tx := range batch.Transactions {
+		batch.Results[i] = batch.Validator.PrevalidateStateless(tx)
 	}
 	
 	if batch.Callback != nil {
@@ -643,24 +603,30 @@ func (p *GPUProcessor) processTransactionsCPU(batch *TransactionBatch) {
 }
 
 // Helper functions for data preparation with safety checks
-func (p *GPUProcessor) prepareHashData(hashes [][]byte) []byte {
-	// OpenCL/CUDA kernels expect fixed 256 bytes per input item
-	const slot = 256
-	count := len(hashes)
-	total := count * slot
 
+// poolBuffer returns a zeroed buffer of the given size, reusing the memory pool.
+func (p *GPUProcessor) poolBuffer(size int) []byte {
 	buf := p.memoryPool.Get().([]byte)
-	if cap(buf) < total {
+	if cap(buf) < size {
 		// Allocate a new buffer if the pool buffer is too small
-		buf = make([]byte, total)
+		return make([]byte, size)
 	}
-	data := buf[:total]
+	data := buf[:size]
+	for i := range data {
+		data[i] = 0
+	}
+	return data
+}
+
+func (p *GPUProcessor) prepareHashData(hashes [][]byte) []byte {
+	// OpenCL/CUDA kernels expect fixed 256 bytes per input item
+	data := p.poolBuffer(len(hashes) * hashSlotSize)
 
 	for i, h := range hashes {
-		base := i * slot
+		base := i * hashSlotSize
 		n := len(h)
-		if n > slot {
-			n = slot
+		if n > hashSlotSize {
+			n = hashSlotSize
 		}
 		copy(data[base:base+n], h[:n])
 		// Remaining bytes are already zeroed
@@ -668,40 +634,33 @@ func (p *GPUProcessor) prepareHashData(hashes [][]byte) []byte {
 	return data
 }
 
-func (p *GPUProcessor) prepareSignatureData(signatures, messages, publicKeys [][]byte) []byte {
-	data := p.memoryPool.Get().([]byte)
-	offset := 0
-	
+// prepareSignatureData packs the signatures as [65|32|64] per item, reporting
+// which items are well formed. The others are left zeroed.
+func (p *GPUProcessor) prepareSignatureData(signatures, messages, publicKeys [][]byte) ([]byte, []bool) {
+	data := p.poolBuffer(len(signatures) * sigItemSize)
+	wellFormed := make([]bool, len(signatures))
+
 	for i := range signatures {
-		totalSize := len(signatures[i]) + len(messages[i]) + len(publicKeys[i])
-		// Safety check to prevent buffer overflow
-		if offset+totalSize > len(data) {
-			log.Warn("Signature data buffer overflow, truncating batch", "offset", offset, "totalSize", totalSize, "bufferLen", len(data))
-			break
+		pubkey := rawPublicKey(publicKeys[i])
+		if len(signatures[i]) != sigSignatureSize || len(messages[i]) != sigMessageSize || pubkey == nil {
+			continue
 		}
-		
+		offset := i * sigItemSize
 		copy(data[offset:], signatures[i])
-		offset += len(signatures[i])
+		offset += sigSignatureSize
 		copy(data[offset:], messages[i])
-		offset += len(messages[i])
-		copy(data[offset:], publicKeys[i])
-		offset += len(publicKeys[i])
+		offset += sigMessageSize
+		copy(data[offset:], pubkey)
+		wellFormed[i] = true
 	}
-	
-	return data[:offset]
+	return data, wellFormed
 }
 
-func (p *GPUProcessor) prepareTransactionData(txs []*types.Transaction) []byte {
-	// Kernels expect fixed 1024 bytes per transaction
-	const slot = 1024
-	count := len(txs)
-	total := count * slot
-
-	buf := p.memoryPool.Get().([]byte)
-	if cap(buf) < total {
-		buf = make([]byte, total)
-	}
-	data := buf[:total]
+// prepareTransactionData packs the transactions into fixed 1024 byte slots,
+// reporting which transactions were packed in full.
+func (p *GPUProcessor) prepareTransactionData(txs []*types.Transaction) ([]byte, []bool) {
+	data := p.poolBuffer(len(txs) * txSlotSize)
+	packed := make([]bool, len(txs))
 
 	for i, tx := range txs {
 		txBytes, err := tx.MarshalBinary()
@@ -709,16 +668,30 @@ func (p *GPUProcessor) prepareTransactionData(txs []*types.Transaction) []byte {
 			log.Warn("Failed to marshal transaction", "hash", tx.Hash(), "error", err)
 			continue
 		}
-		base := i * slot
+		base := i * txSlotSize
 		n := len(txBytes)
-		if n > slot {
+		if n > txSlotSize {
 			// Truncate if too large for slot
-			n = slot
+			n = txSlotSize
 		}
 		copy(data[base:base+n], txBytes[:n])
+		packed[i] = n == len(txBytes)
 		// Remaining bytes are left zeroed
 	}
-	return data
+	return data, packed
+}
+
+// rawPublicKey returns the 64 byte X || Y form of an uncompressed public key,
+// accepting it with or without the 0x04 prefix. It returns nil for other keys.
+func rawPublicKey(pubkey []byte) []byte {
+	switch {
+	case len(pubkey) == sigPublicKeySize:
+		return pubkey
+	case len(pubkey) == sigPublicKeySize+1 && pubkey[0] == 0x04:
+		return pubkey[1:]
+	default:
+		return nil
+	}
 }
 
 func (p *GPUProcessor) convertHashResults(batch *HashBatch) {
@@ -832,11 +805,8 @@ func (p *GPUProcessor) Close() error {
 	p.wg.Wait()
 	
 	// Cleanup GPU resources
-	switch p.gpuType {
-	case GPUTypeCUDA:
-		C.cuda_cleanup()
-	case GPUTypeOpenCL:
-		C.cleanupOpenCL()
+	if p.accel != nil {
+		p.accel.Close()
 	}
 	
 	log.Info("GPU processor shutdown complete")
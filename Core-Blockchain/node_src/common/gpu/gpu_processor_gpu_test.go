@@ -14,6 +14,7 @@ import (
 
 func newTestGPUProcessor() *GPUProcessor {
 	p := &GPUProcessor{
+		accel:   cudaAccelerator{},
 		gpuType: GPUTypeCUDA,
 	}
 	p.memoryPool = sync.Pool{New: func() interface{} { return make([]byte, 1024*64) }}
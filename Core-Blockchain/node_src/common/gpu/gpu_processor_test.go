@@ -0,0 +1,197 @@
+// Copyright 2024 The Splendor Authors
+// Tests for the GPU processor running on the software accelerator
+
+package gpu
+
+import (
+	"bytes"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newSoftwareGPUProcessor creates a worker-less processor on a software accelerator.
+func newSoftwareGPUProcessor(accel *SoftwareAccelerator) *GPUProcessor {
+	p := &GPUProcessor{
+		accel:       accel,
+		gpuType:     accel.Type(),
+		deviceCount: 1,
+	}
+	p.memoryPool = sync.Pool{New: func() interface{} { return make([]byte, 1024*64) }}
+	return p
+}
+
+// softwareTestBatches returns hash, signature and transaction batches covering
+// the inputs the kernels can't handle, along with the expected results.
+func softwareTestBatches(t *testing.T) ([][]byte, *SignatureBatch, []bool, []*types.Transaction, *TxPrevalidator) {
+	hashes := [][]byte{
+		crypto.Keccak256([]byte("slot")),
+		[]byte(""),
+		[]byte("ethereum"),
+		bytes.Repeat([]byte{0x01, 0x02, 0x03, 0x04}, 100),
+	}
+
+	key, _ := crypto.GenerateKey()
+	msg := crypto.Keccak256([]byte("gpu-signature-test"))
+	sig, err := crypto.Sign(msg, key)
+	if err != nil {
+		t.Fatalf("failed to sign message: %v", err)
+	}
+	pub := crypto.FromECDSAPub(&key.PublicKey)
+	invalid := common.CopyBytes(sig)
+	invalid[10] ^= 0xff
+
+	sigs := &SignatureBatch{
+		Signatures: [][]byte{sig, sig, invalid, sig[:64], sig},
+		Messages:   [][]byte{msg, msg, msg, msg, msg[:31]},
+		PublicKeys: [][]byte{pub, pub[1:], pub, pub, pub},
+		Results:    make([]bool, 5),
+	}
+	verified := []bool{true, true, false, false, false}
+
+	var (
+		config = params.AllEthashProtocolChanges
+		signer = types.LatestSigner(config)
+		other  = types.LatestSignerForChainID(big.NewInt(1))
+		to     = common.HexToAddress("0x01")
+	)
+	txs := []*types.Transaction{
+		types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 0, To: &to, Gas: params.TxGas, GasPrice: big.NewInt(params.InitialBaseFee)}),
+		types.MustSignNewTx(key, other, &types.LegacyTx{Nonce: 1, To: &to, Gas: params.TxGas, GasPrice: big.NewInt(params.InitialBaseFee)}),
+		types.MustSignNewTx(key, signer, &types.DynamicFeeTx{ChainID: config.ChainID, Nonce: 2, To: &to, Gas: 100000, GasFeeCap: big.NewInt(params.InitialBaseFee), Data: make([]byte, 2*txSlotSize)}),
+		types.MustSignNewTx(key, signer, &types.DynamicFeeTx{ChainID: config.ChainID, Nonce: 3, To: &to, Gas: params.TxGas - 1, GasFeeCap: big.NewInt(params.InitialBaseFee)}),
+	}
+	validator := &TxPrevalidator{
+		Signer:  signer,
+		Rules:   config.Rules(common.Big1),
+		BaseFee: big.NewInt(params.InitialBaseFee),
+	}
+	return hashes, sigs, verified, txs, validator
+}
+
+// checkSoftwareResults runs the batches on the GPU path of a processor and
+// checks that the results match the CPU ones. Rejecting kernels are expected
+// to reject all signatures and the transactions fitting their slots.
+func checkSoftwareResults(t *testing.T, p *GPUProcessor, reject bool) {
+	t.Helper()
+
+	hashes, sigs, verified, txs, validator := softwareTestBatches(t)
+
+	hashBatch := &HashBatch{Hashes: hashes, Results: make([][]byte, len(hashes))}
+	p.processHashesGPU(hashBatch)
+	for i, input := range hashes {
+		if want := crypto.Keccak256(input); !bytes.Equal(hashBatch.Results[i], want) {
+			t.Errorf("hash %d mismatch: have %x want %x", i, hashBatch.Results[i], want)
+		}
+	}
+
+	p.processSignaturesGPU(sigs)
+	for i, want := range verified {
+		if want = want && !reject; sigs.Results[i] != want {
+			t.Errorf("signature %d: have %v want %v", i, sigs.Results[i], want)
+		}
+	}
+
+	txBatch := &TransactionBatch{Transactions: txs, Validator: validator, Results: make([]*TxResult, len(txs))}
+	p.processTransactionsGPU(txBatch)
+	for i, tx := range txs {
+		have, want := txBatch.Results[i], validator.PrevalidateStateless(tx)
+		if reject && tx.Size() < txSlotSize {
+			want.reject(TxRejectMalformed, errKernelRejected)
+		}
+		if have == nil {
+			t.Fatalf("tx %d result missing", i)
+		}
+		if have.Valid != want.Valid || have.Reason != want.Reason {
+			t.Errorf("tx %d: have valid %v reason %v, want valid %v reason %v", i, have.Valid, have.Reason, want.Valid, want.Reason)
+		}
+		if have.Hash != tx.Hash() || have.GasUsed != tx.Gas() {
+			t.Errorf("tx %d: hash or gas mismatch: have %x/%d", i, have.Hash, have.GasUsed)
+		}
+	}
+}
+
+func TestSoftwareAcceleratorParity(t *testing.T) {
+	for _, typ := range []GPUType{GPUTypeCUDA, GPUTypeOpenCL} {
+		t.Run(typ.String(), func(t *testing.T) {
+			accel := NewSoftwareAccelerator(typ, 1)
+			checkSoftwareResults(t, newSoftwareGPUProcessor(accel), false)
+
+			for _, kernel := range []Kernel{KernelHashes, KernelSignatures, KernelTransactions} {
+				if calls := accel.Calls(kernel); calls != 1 {
+					t.Errorf("%v kernel: have %d calls, want 1", kernel, calls)
+				}
+			}
+		})
+	}
+}
+
+func TestSoftwareAcceleratorFaults(t *testing.T) {
+	for _, typ := range []GPUType{GPUTypeCUDA, GPUTypeOpenCL} {
+		for _, fault := range []Fault{FaultError, FaultPanic, FaultReject} {
+			accel := NewSoftwareAccelerator(typ, 1)
+			for _, kernel := range []Kernel{KernelHashes, KernelSignatures, KernelTransactions} {
+				accel.InjectFault(kernel, fault)
+			}
+			// Failing kernels fall back to the CPU, rejecting ones are trusted
+			checkSoftwareResults(t, newSoftwareGPUProcessor(accel), fault == FaultReject)
+		}
+	}
+}
+
+func TestGPUProcessorAccelerators(t *testing.T) {
+	accel := NewSoftwareAccelerator(GPUTypeOpenCL, 2)
+	RegisterAccelerator("software-test", func(*GPUConfig) Accelerator { return accel })
+
+	config := &GPUConfig{Accelerator: "software-test", MaxBatchSize: 16, TxWorkers: 1}
+	p, err := NewGPUProcessor(config)
+	if err != nil {
+		t.Fatalf("failed to create processor: %v", err)
+	}
+	defer p.Close()
+
+	if !p.IsGPUAvailable() || p.GetGPUType() != GPUTypeOpenCL || p.GetStats().DeviceCount != 2 {
+		t.Fatalf("accelerator not in use: %+v", p.GetStats())
+	}
+	_, _, _, txs, validator := softwareTestBatches(t)
+
+	done := make(chan []*TxResult, 1)
+	if err := p.ProcessTransactionsBatch(txs, validator, func(results []*TxResult, err error) {
+		if err != nil {
+			t.Errorf("batch failed: %v", err)
+		}
+		done <- results
+	}); err != nil {
+		t.Fatalf("failed to submit batch: %v", err)
+	}
+	select {
+	case results := <-done:
+		if len(results) != len(txs) || !results[0].Valid || results[1].Valid {
+			t.Errorf("unexpected results: %v", results)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch timed out")
+	}
+	if accel.Calls(KernelTransactions) != 1 {
+		t.Errorf("transaction kernel not run")
+	}
+
+	// Accelerators without devices and unknown ones leave the processor on the CPU
+	RegisterAccelerator("software-test-nodev", func(*GPUConfig) Accelerator { return NewSoftwareAccelerator(GPUTypeCUDA, 0) })
+	for _, name := range []string{"software-test-nodev", "unknown"} {
+		p, err := NewGPUProcessor(&GPUConfig{Accelerator: name})
+		if err != nil {
+			t.Fatalf("failed to create processor: %v", err)
+		}
+		if p.IsGPUAvailable() {
+			t.Errorf("%s: GPU reported available", name)
+		}
+		p.Close()
+	}
+}
@@ -1,3 +1,5 @@
+//go:build cgo && gpu
+
 /*
   OpenCL stub implementations for environments without OpenCL kernels.
   These satisfy linker references from gpu_processor.go and allow CUDA-only builds.
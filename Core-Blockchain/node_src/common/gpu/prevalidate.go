@@ -0,0 +1,219 @@
+// Copyright 2024 The Splendor Authors
+// Batched transaction prevalidation shared by the CPU and GPU paths
+
+package gpu
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// TxRejectReason tells why prevalidation rejected a transaction.
+type TxRejectReason uint8
+
+const (
+	TxRejectNone              TxRejectReason = iota // transaction passed prevalidation
+	TxRejectMalformed                               // transaction could not be decoded
+	TxRejectChainID                                 // transaction is signed for another chain
+	TxRejectInvalidSender                           // sender could not be recovered
+	TxRejectFeeCap                                  // fee cap is below the base fee
+	TxRejectIntrinsicGas                            // gas limit is below the intrinsic gas
+	TxRejectBlacklisted                             // denied by the consensus engine
+	TxRejectNonceTooLow                             // nonce was already used
+	TxRejectNonceTooHigh                            // nonce leaves a gap
+	TxRejectInsufficientFunds                       // balance cannot cover value + gas
+)
+
+// String implements fmt.Stringer.
+func (r TxRejectReason) String() string {
+	switch r {
+	case TxRejectNone:
+		return "none"
+	case TxRejectMalformed:
+		return "malformed"
+	case TxRejectChainID:
+		return "invalid chain id"
+	case TxRejectInvalidSender:
+		return "invalid sender"
+	case TxRejectFeeCap:
+		return "fee cap too low"
+	case TxRejectIntrinsicGas:
+		return "intrinsic gas too low"
+	case TxRejectBlacklisted:
+		return "blacklisted"
+	case TxRejectNonceTooLow:
+		return "nonce too low"
+	case TxRejectNonceTooHigh:
+		return "nonce too high"
+	case TxRejectInsufficientFunds:
+		return "insufficient funds"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(r))
+	}
+}
+
+// TxResult holds the result of transaction processing
+type TxResult struct {
+	Hash      common.Hash
+	Sender    common.Address
+	Valid     bool
+	Reason    TxRejectReason
+	GasUsed   uint64
+	Error     error
+	Signature []byte
+}
+
+// reject marks the result invalid for the given reason.
+func (r *TxResult) reject(reason TxRejectReason, err error) {
+	r.Valid = false
+	r.Reason = reason
+	r.Error = err
+}
+
+// PrevalidationState is the pending state the nonces and balances are checked
+// against. It is only accessed by PrevalidateState, never concurrently.
+type PrevalidationState interface {
+	GetNonce(addr common.Address) uint64
+	GetBalance(addr common.Address) *big.Int
+}
+
+// TxPrevalidator performs the checks a transaction has to pass before it is
+// worth executing on top of the pending state. The checks are split into a
+// stateless part, which can run concurrently on any subset of a batch, and a
+// stateful part, which runs over the whole batch in order so that several
+// transactions of the same sender are checked against each other.
+type TxPrevalidator struct {
+	Signer  types.Signer
+	Rules   params.Rules
+	BaseFee *big.Int // nil before London
+	State   PrevalidationState
+
+	// ValidateTx performs the consensus engine checks (e.g. the blacklist)
+	// on the pending state. It is optional.
+	ValidateTx func(sender common.Address, tx *types.Transaction) error
+}
+
+// Prevalidate runs all checks over the batch on the calling goroutine.
+func (v *TxPrevalidator) Prevalidate(txs []*types.Transaction) []*TxResult {
+	results := make([]*TxResult, len(txs))
+	for i, tx := range txs {
+		results[i] = v.PrevalidateStateless(tx)
+	}
+	v.PrevalidateState(txs, results)
+	return results
+}
+
+// PrevalidateStateless checks the chain ID, the signature, the fee cap and the
+// intrinsic gas of a transaction. It is safe for concurrent use.
+func (v *TxPrevalidator) PrevalidateStateless(tx *types.Transaction) *TxResult {
+	result := &TxResult{
+		Hash:    tx.Hash(),
+		Valid:   true,
+		GasUsed: tx.Gas(),
+	}
+	if tx.Protected() {
+		if !v.Rules.IsEIP155 {
+			result.reject(TxRejectChainID, fmt.Errorf("%w: replay protected transaction before EIP155", types.ErrInvalidChainId))
+			return result
+		}
+		if tx.ChainId().Cmp(v.Rules.ChainID) != 0 {
+			result.reject(TxRejectChainID, fmt.Errorf("%w: have %v want %v", types.ErrInvalidChainId, tx.ChainId(), v.Rules.ChainID))
+			return result
+		}
+	}
+	sender, err := types.Sender(v.Signer, tx)
+	if err != nil {
+		result.reject(TxRejectInvalidSender, err)
+		return result
+	}
+	result.Sender = sender
+
+	// x402 envelopes are settled fee-free, so they are exempt from the base fee
+	x402 := tx.Type() == types.X402TxType
+	if v.BaseFee != nil && !x402 && tx.GasFeeCapIntCmp(v.BaseFee) < 0 {
+		result.reject(TxRejectFeeCap, fmt.Errorf("%w: address %v, maxFeePerGas: %s baseFee: %s", core.ErrFeeCapTooLow,
+			sender.Hex(), tx.GasFeeCap(), v.BaseFee))
+		return result
+	}
+	gas, err := core.IntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil, v.Rules.IsHomestead, v.Rules.IsIstanbul)
+	if err == nil {
+		gas, err = core.PQIntrinsicGas(gas, tx.PQSignatureSize())
+	}
+	if err != nil {
+		result.reject(TxRejectIntrinsicGas, err)
+		return result
+	}
+	if tx.Gas() < gas {
+		result.reject(TxRejectIntrinsicGas, fmt.Errorf("%w: have %d, want %d", core.ErrIntrinsicGas, tx.Gas(), gas))
+	}
+	return result
+}
+
+// PrevalidateState checks the transactions that passed the stateless checks
+// against the consensus engine and the pending nonces and balances, in batch
+// order. Every accepted transaction advances the nonce of its sender and is
+// charged its full cost, so a rejected transaction also rejects the later ones
+// of the same sender.
+func (v *TxPrevalidator) PrevalidateState(txs []*types.Transaction, results []*TxResult) {
+	var (
+		nonces   = make(map[common.Address]uint64)
+		balances = make(map[common.Address]*big.Int)
+	)
+	balance := func(addr common.Address) *big.Int {
+		if bal, ok := balances[addr]; ok {
+			return bal
+		}
+		bal := new(big.Int).Set(v.State.GetBalance(addr))
+		balances[addr] = bal
+		return bal
+	}
+	for i, tx := range txs {
+		result := results[i]
+		if !result.Valid {
+			continue
+		}
+		sender := result.Sender
+		if v.ValidateTx != nil {
+			if err := v.ValidateTx(sender, tx); err != nil {
+				result.reject(TxRejectBlacklisted, err)
+				continue
+			}
+		}
+		nonce, ok := nonces[sender]
+		if !ok {
+			nonce = v.State.GetNonce(sender)
+		}
+		if tx.Nonce() < nonce {
+			result.reject(TxRejectNonceTooLow, fmt.Errorf("%w: address %v, tx: %d state: %d", core.ErrNonceTooLow,
+				sender.Hex(), tx.Nonce(), nonce))
+			continue
+		}
+		if tx.Nonce() > nonce {
+			result.reject(TxRejectNonceTooHigh, fmt.Errorf("%w: address %v, tx: %d state: %d", core.ErrNonceTooHigh,
+				sender.Hex(), tx.Nonce(), nonce))
+			continue
+		}
+		// x402 envelopes only consume the nonce of the facilitator, the payment
+		// is settled natively out of the payer's balance
+		have, want := balance(sender), tx.Cost()
+		if tx.Type() == types.X402TxType {
+			want = new(big.Int)
+		}
+		if have.Cmp(want) < 0 {
+			result.reject(TxRejectInsufficientFunds, fmt.Errorf("%w: address %v have %v want %v", core.ErrInsufficientFunds,
+				sender.Hex(), have, want))
+			continue
+		}
+		nonces[sender] = nonce + 1
+		have.Sub(have, want)
+		if to := tx.To(); to != nil && tx.Type() != types.X402TxType && tx.Value().Sign() > 0 {
+			recipient := balance(*to)
+			recipient.Add(recipient, tx.Value())
+		}
+	}
+}
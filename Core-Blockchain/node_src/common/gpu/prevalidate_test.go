@@ -0,0 +1,162 @@
+// Copyright 2024 The Splendor Authors
+// Tests for the batched transaction prevalidation
+
+package gpu
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/mldsa"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// testPrevalidationState is a map backed pending state.
+type testPrevalidationState struct {
+	nonces   map[common.Address]uint64
+	balances map[common.Address]*big.Int
+}
+
+func (s *testPrevalidationState) GetNonce(addr common.Address) uint64 {
+	return s.nonces[addr]
+}
+
+func (s *testPrevalidationState) GetBalance(addr common.Address) *big.Int {
+	if bal, ok := s.balances[addr]; ok {
+		return bal
+	}
+	return new(big.Int)
+}
+
+func TestPrevalidate(t *testing.T) {
+	var (
+		config   = params.AllEthashProtocolChanges
+		signer   = types.NewPQSigner(config.ChainID)
+		other    = types.LatestSignerForChainID(big.NewInt(1))
+		baseFee  = big.NewInt(params.InitialBaseFee)
+		key1, _  = crypto.GenerateKey()
+		key2, _  = crypto.GenerateKey()
+		key3, _  = crypto.GenerateKey()
+		key4, _  = crypto.GenerateKey()
+		addr1    = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2    = crypto.PubkeyToAddress(key2.PublicKey)
+		addr3    = crypto.PubkeyToAddress(key3.PublicKey)
+		registry = types.X402RegistryAddress
+		denied   = common.HexToAddress("0xdead")
+		funds    = new(big.Int).Mul(new(big.Int).SetUint64(params.TxGas), baseFee)
+		errDeny  = errors.New("denied")
+		gasPrice = new(big.Int).Set(baseFee)
+	)
+	sign := func(s types.Signer, key *ecdsa.PrivateKey, nonce uint64, to common.Address, value *big.Int, gas uint64, price *big.Int) *types.Transaction {
+		return types.MustSignNewTx(key, s, &types.LegacyTx{Nonce: nonce, To: &to, Value: value, Gas: gas, GasPrice: price})
+	}
+	x402 := func(nonce uint64, gas uint64) *types.Transaction {
+		tx, err := types.SignTx(types.NewX402Tx(config.ChainID, nonce, &registry, gas, []byte{0x01}), signer, key4)
+		if err != nil {
+			t.Fatalf("failed to sign envelope: %v", err)
+		}
+		return tx
+	}
+	x402Gas, _ := core.IntrinsicGas([]byte{0x01}, nil, false, true, true)
+
+	pqPublic, pqSecret, err := mldsa.GenerateKeyPair(mldsa.MLDSA44)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	pqAddr := types.PQAddress(pqPublic)
+	pq := func(nonce uint64, gas uint64) *types.Transaction {
+		tx, err := types.SignPQTx(types.NewTx(&types.PQTx{Nonce: nonce, GasTipCap: common.Big0, GasFeeCap: gasPrice, Gas: gas, To: &addr1}),
+			signer, params.MLDSA44_ID, pqPublic, pqSecret)
+		if err != nil {
+			t.Fatalf("failed to sign post-quantum transaction: %v", err)
+		}
+		return tx
+	}
+	pqGas, _ := core.PQIntrinsicGas(params.TxGas, params.MLDSA44PublicKeySize+params.MLDSA44SignatureSize)
+
+	unsigned := types.NewTx(&types.LegacyTx{Nonce: 0, To: &addr2, Gas: params.TxGas, GasPrice: gasPrice, V: big.NewInt(1337*2 + 35), R: big.NewInt(1), S: crypto.S256().Params().N})
+
+	tests := []struct {
+		tx     *types.Transaction
+		reason TxRejectReason
+		err    error
+	}{
+		{sign(signer, key1, 0, addr2, big.NewInt(1), params.TxGas, gasPrice), TxRejectNone, nil},
+		{sign(signer, key1, 1, addr2, big.NewInt(1), params.TxGas, gasPrice), TxRejectNone, nil},
+		{sign(signer, key1, 1, addr2, big.NewInt(1), params.TxGas, gasPrice), TxRejectNonceTooLow, core.ErrNonceTooLow},
+		{sign(signer, key1, 3, addr2, big.NewInt(1), params.TxGas, gasPrice), TxRejectNonceTooHigh, core.ErrNonceTooHigh},
+		{sign(other, key1, 2, addr2, big.NewInt(1), params.TxGas, gasPrice), TxRejectChainID, types.ErrInvalidChainId},
+		{unsigned, TxRejectInvalidSender, nil},
+		{sign(signer, key1, 2, addr2, big.NewInt(1), params.TxGas, big.NewInt(1)), TxRejectFeeCap, core.ErrFeeCapTooLow},
+		{sign(signer, key1, 2, addr2, big.NewInt(1), params.TxGas-1, gasPrice), TxRejectIntrinsicGas, core.ErrIntrinsicGas},
+		{sign(signer, key1, 2, denied, big.NewInt(1), params.TxGas, gasPrice), TxRejectBlacklisted, errDeny},
+		{sign(signer, key1, 2, addr2, big.NewInt(1), params.TxGas, gasPrice), TxRejectNone, nil},
+
+		// The second account can only pay for its transaction with the value
+		// received from the first one, the third one is not funded at all
+		{sign(signer, key2, 0, addr1, big.NewInt(0), params.TxGas, gasPrice), TxRejectNone, nil},
+		{sign(signer, key3, 0, addr1, big.NewInt(0), params.TxGas, gasPrice), TxRejectInsufficientFunds, core.ErrInsufficientFunds},
+		{sign(signer, key3, 1, addr1, big.NewInt(0), params.TxGas, gasPrice), TxRejectNonceTooHigh, core.ErrNonceTooHigh},
+
+		// x402 envelopes are fee-free, so an unfunded facilitator can submit them
+		{x402(0, x402Gas), TxRejectNone, nil},
+		{x402(1, x402Gas-1), TxRejectIntrinsicGas, core.ErrIntrinsicGas},
+
+		// Post-quantum transactions pay for the verification of their signature
+		{pq(0, params.TxGas), TxRejectIntrinsicGas, core.ErrIntrinsicGas},
+		{pq(0, pqGas), TxRejectNone, nil},
+	}
+	validator := &TxPrevalidator{
+		Signer:  signer,
+		Rules:   config.Rules(common.Big1),
+		BaseFee: baseFee,
+		State: &testPrevalidationState{
+			nonces: map[common.Address]uint64{},
+			balances: map[common.Address]*big.Int{
+				addr1:  new(big.Int).Add(new(big.Int).Mul(funds, big.NewInt(3)), big.NewInt(3)),
+				addr2:  new(big.Int).Sub(funds, big.NewInt(3)),
+				addr3:  new(big.Int).Sub(funds, big.NewInt(1)),
+				pqAddr: new(big.Int).Mul(new(big.Int).SetUint64(pqGas), baseFee),
+			},
+		},
+		ValidateTx: func(sender common.Address, tx *types.Transaction) error {
+			if to := tx.To(); to != nil && *to == denied {
+				return errDeny
+			}
+			return nil
+		},
+	}
+	txs := make([]*types.Transaction, len(tests))
+	for i, test := range tests {
+		txs[i] = test.tx
+	}
+	results := validator.Prevalidate(txs)
+	if len(results) != len(txs) {
+		t.Fatalf("result count mismatch: have %d, want %d", len(results), len(txs))
+	}
+	for i, test := range tests {
+		result := results[i]
+		if result.Hash != test.tx.Hash() {
+			t.Errorf("tx %d: hash mismatch", i)
+		}
+		if result.Valid != (test.reason == TxRejectNone) || result.Reason != test.reason {
+			t.Errorf("tx %d: result mismatch: have valid %v reason %v (%v), want reason %v", i, result.Valid, result.Reason, result.Error, test.reason)
+			continue
+		}
+		if test.err != nil && !errors.Is(result.Error, test.err) {
+			t.Errorf("tx %d: error mismatch: have %v, want %v", i, result.Error, test.err)
+		}
+		if result.Valid && result.GasUsed != test.tx.Gas() {
+			t.Errorf("tx %d: gas mismatch: have %d, want %d", i, result.GasUsed, test.tx.Gas())
+		}
+	}
+	if results[0].Sender != addr1 {
+		t.Errorf("sender mismatch: have %x, want %x", results[0].Sender, addr1)
+	}
+}
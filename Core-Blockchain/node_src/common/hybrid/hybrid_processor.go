@@ -2,6 +2,7 @@ package hybrid
 
 import (
 	"context"
+	"errors"
 	"runtime"
 	"sync"
 	"time"
@@ -13,6 +14,13 @@ import (
 	"github.com/ethereum/go-ethereum/common/gpu"
 )
 
+// errMissingPrevalidator is returned when a batch is submitted without the
+// context needed to validate it.
+var errMissingPrevalidator = errors.New("missing transaction prevalidator")
+
+// cpuChunkSize is the number of transactions prevalidated by a single CPU task.
+const cpuChunkSize = 100
+
 // HybridProcessor combines CPU and GPU processing with intelligent load balancing
 type HybridProcessor struct {
 	// Processing components
@@ -21,6 +29,7 @@ type HybridProcessor struct {
 	
 	// Load balancing
 	loadBalancer *LoadBalancer
+	tuner        tuner
 	
 	// Configuration
 	config       *HybridConfig
@@ -59,6 +68,9 @@ type HybridConfig struct {
 	// Memory Management
 	MaxMemoryUsage         uint64  `json:"maxMemoryUsage"`         // Max total memory usage
 	GPUMemoryReservation   uint64  `json:"gpuMemoryReservation"`   // Reserved GPU memory
+	
+	// Runtime Tuning
+	TuningWindow           time.Duration `json:"tuningWindow"`     // Evaluation window of retunes before they're kept
 }
 
 // DefaultHybridConfig returns optimized hybrid configuration for NVIDIA RTX 4000 SFF Ada (20GB VRAM) and 16+ core CPUs
@@ -77,6 +89,7 @@ func DefaultHybridConfig() *HybridConfig {
 		ThroughputTarget:      3000000, // 3M TPS target (realistic for hardware)
 		MaxMemoryUsage:        64 * 1024 * 1024 * 1024, // 64GB total system memory
 		GPUMemoryReservation:  12 * 1024 * 1024 * 1024, // 12GB GPU reserved (safer limit)
+		TuningWindow:          defaultTuningWindow,
 	}
 }
 
@@ -90,6 +103,9 @@ type LoadBalancer struct {
 	cpuThroughput         uint64
 	gpuThroughput         uint64
 	adaptiveRatio         float64
+	gpuThreshold          int
+	batchSize             int
+	strategyOverride      ProcessingStrategy
 	lastAdjustment        time.Time
 	performanceHistory    []PerformanceSnapshot
 }
@@ -116,6 +132,9 @@ type HybridStats struct {
 	LoadBalancingRatio    float64       `json:"loadBalancingRatio"`
 	MemoryUsage           uint64        `json:"memoryUsage"`
 	GPUMemoryUsage        uint64        `json:"gpuMemoryUsage"`
+	AvgBatchSize          uint64        `json:"avgBatchSize"`
+	LastStrategy          ProcessingStrategy `json:"lastStrategy"`
+	QueueDepth            uint64        `json:"queueDepth"`
 }
 
 // NewHybridProcessor creates a new hybrid processor
@@ -146,6 +165,8 @@ func NewHybridProcessor(config *HybridConfig) (*HybridProcessor, error) {
 	// Initialize load balancer
 	loadBalancer := &LoadBalancer{
 		adaptiveRatio:      config.CPUGPURatio,
+		gpuThreshold:       config.GPUThreshold,
+		strategyOverride:   ProcessingStrategyAuto,
 		lastAdjustment:     time.Now(),
 		performanceHistory: make([]PerformanceSnapshot, 0, 100),
 	}
@@ -158,6 +179,11 @@ func NewHybridProcessor(config *HybridConfig) (*HybridProcessor, error) {
 		ctx:          ctx,
 		cancel:       cancel,
 	}
+	processor.tuner.length = config.TuningWindow
+	if processor.tuner.length <= 0 {
+		processor.tuner.length = defaultTuningWindow
+	}
+	processor.tuner.result.Tuning = loadBalancer.tuning()
 	
 	// Start monitoring and load balancing
 	if config.PerformanceMonitoring {
@@ -191,8 +217,14 @@ func NewHybridProcessor(config *HybridConfig) (*HybridProcessor, error) {
 	return processor, nil
 }
 
-// ProcessTransactionsBatch processes a batch of transactions using optimal CPU/GPU distribution
-func (h *HybridProcessor) ProcessTransactionsBatch(txs []*types.Transaction, callback func([]*TransactionResult, error)) error {
+// ProcessTransactionsBatch prevalidates a batch of transactions using optimal CPU/GPU
+// distribution. The stateless checks are spread over the processors, the state
+// dependent ones run over the whole batch once those are done. The callback is
+// invoked before returning, with one result per transaction in batch order.
+func (h *HybridProcessor) ProcessTransactionsBatch(txs []*types.Transaction, validator *gpu.TxPrevalidator, callback func([]*TransactionResult, error)) error {
+	if validator == nil {
+		return errMissingPrevalidator
+	}
 	if len(txs) == 0 {
 		callback(nil, nil)
 		return nil
@@ -206,13 +238,13 @@ func (h *HybridProcessor) ProcessTransactionsBatch(txs []*types.Transaction, cal
 	
 	switch strategy {
 	case ProcessingStrategyCPUOnly:
-		return h.processCPUOnly(txs, callback, start)
+		return h.processCPUOnly(txs, validator, callback, start)
 	case ProcessingStrategyGPUOnly:
-		return h.processGPUOnly(txs, callback, start)
+		return h.processGPUOnly(txs, validator, callback, start)
 	case ProcessingStrategyHybrid:
-		return h.processHybrid(txs, callback, start)
+		return h.processHybrid(txs, validator, callback, start)
 	default:
-		return h.processCPUOnly(txs, callback, start)
+		return h.processCPUOnly(txs, validator, callback, start)
 	}
 }
 
@@ -231,16 +263,23 @@ func (h *HybridProcessor) determineProcessingStrategy(batchSize int) ProcessingS
 		return ProcessingStrategyCPUOnly
 	}
 	
-	// Small batches go to CPU
-	if batchSize < h.config.GPUThreshold {
-		return ProcessingStrategyCPUOnly
-	}
-	
 	h.loadBalancer.mu.RLock()
 	cpuUtil := h.loadBalancer.cpuUtilization
 	gpuUtil := h.loadBalancer.gpuUtilization
+	threshold := h.loadBalancer.gpuThreshold
+	override := h.loadBalancer.strategyOverride
 	h.loadBalancer.mu.RUnlock()
 	
+	// Small batches go to CPU
+	if batchSize < threshold {
+		return ProcessingStrategyCPUOnly
+	}
+	
+	// Tuned strategies apply to every batch reaching the GPU threshold
+	if override != ProcessingStrategyAuto {
+		return override
+	}
+	
 	// If CPU is overloaded, prefer GPU
 	if cpuUtil > h.config.MaxCPUUtilization {
 		if gpuUtil < h.config.MaxGPUUtilization {
@@ -250,12 +289,12 @@ func (h *HybridProcessor) determineProcessingStrategy(batchSize int) ProcessingS
 	}
 	
 	// If GPU is underutilized and batch is large, use hybrid
-	if batchSize > h.config.GPUThreshold*2 && gpuUtil < 0.5 {
+	if batchSize > threshold*2 && gpuUtil < 0.5 {
 		return ProcessingStrategyHybrid
 	}
 	
 	// Default to GPU for large batches
-	if batchSize > h.config.GPUThreshold*5 {
+	if batchSize > threshold*5 {
 		return ProcessingStrategyGPUOnly
 	}
 	
@@ -263,100 +302,38 @@ func (h *HybridProcessor) determineProcessingStrategy(batchSize int) ProcessingS
 }
 
 // processCPUOnly processes transactions using CPU only
-func (h *HybridProcessor) processCPUOnly(txs []*types.Transaction, callback func([]*TransactionResult, error), start time.Time) error {
-	results := make([]*TransactionResult, len(txs))
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	
-	// Process in parallel using CPU workers
-	batchSize := 100 // Process in smaller batches
-	for i := 0; i < len(txs); i += batchSize {
-		end := i + batchSize
-		if end > len(txs) {
-			end = len(txs)
-		}
-		
-		wg.Add(1)
-		batch := txs[i:end]
-		batchStart := i
-		
-		err := h.cpuProcessor.SubmitTxTask(func() error {
-			defer wg.Done()
-			
-			// Process batch
-			batchResults := make([]*TransactionResult, len(batch))
-			for j, tx := range batch {
-				batchResults[j] = &TransactionResult{
-					Hash:      tx.Hash(),
-					Valid:     true, // Simplified validation
-					GasUsed:   tx.Gas(),
-					Processed: true,
-				}
-			}
-			
-			// Store results
-			mu.Lock()
-			copy(results[batchStart:batchStart+len(batch)], batchResults)
-			mu.Unlock()
-			
-			return nil
-		}, nil)
-		
-		if err != nil {
-			return err
-		}
-	}
-	
-	// Wait for completion
-	wg.Wait()
-	
+func (h *HybridProcessor) processCPUOnly(txs []*types.Transaction, validator *gpu.TxPrevalidator, callback func([]*TransactionResult, error), start time.Time) error {
+	results := h.processCPUBatch(txs, validator)
+	validator.PrevalidateState(txs, results)
+
 	// Update statistics
 	duration := time.Since(start)
 	h.updateStats(uint64(len(txs)), 0, duration, ProcessingStrategyCPUOnly)
-	
-	callback(results, nil)
+
+	callback(NewTransactionResults(results), nil)
 	return nil
 }
 
 // processGPUOnly processes transactions using GPU only
-func (h *HybridProcessor) processGPUOnly(txs []*types.Transaction, callback func([]*TransactionResult, error), start time.Time) error {
+func (h *HybridProcessor) processGPUOnly(txs []*types.Transaction, validator *gpu.TxPrevalidator, callback func([]*TransactionResult, error), start time.Time) error {
 	if h.gpuProcessor == nil {
-		return h.processCPUOnly(txs, callback, start)
+		return h.processCPUOnly(txs, validator, callback, start)
 	}
-	
-	// Process transactions using GPU
-	err := h.gpuProcessor.ProcessTransactionsBatch(txs, func(results []*gpu.TxResult, err error) {
-		if err != nil {
-			callback(nil, err)
-			return
-		}
-		
-		// Convert GPU results to hybrid results
-		hybridResults := make([]*TransactionResult, len(results))
-		for i, result := range results {
-			hybridResults[i] = &TransactionResult{
-				Hash:      result.Hash,
-				Valid:     result.Valid,
-				GasUsed:   result.GasUsed,
-				Error:     result.Error,
-				Processed: true,
-			}
-		}
-		
-		// Update statistics
-		duration := time.Since(start)
-		h.updateStats(0, uint64(len(txs)), duration, ProcessingStrategyGPUOnly)
-		
-		callback(hybridResults, nil)
-	})
-	
-	return err
+	results := h.processGPUBatch(txs, validator)
+	validator.PrevalidateState(txs, results)
+
+	// Update statistics
+	duration := time.Since(start)
+	h.updateStats(0, uint64(len(txs)), duration, ProcessingStrategyGPUOnly)
+
+	callback(NewTransactionResults(results), nil)
+	return nil
 }
 
 // processHybrid processes transactions using both CPU and GPU
-func (h *HybridProcessor) processHybrid(txs []*types.Transaction, callback func([]*TransactionResult, error), start time.Time) error {
+func (h *HybridProcessor) processHybrid(txs []*types.Transaction, validator *gpu.TxPrevalidator, callback func([]*TransactionResult, error), start time.Time) error {
 	if h.gpuProcessor == nil {
-		return h.processCPUOnly(txs, callback, start)
+		return h.processCPUOnly(txs, validator, callback, start)
 	}
 	
 	// Determine split ratio
@@ -371,124 +348,138 @@ func (h *HybridProcessor) processHybrid(txs []*types.Transaction, callback func(
 	gpuTxs := txs[:gpuCount]
 	cpuTxs := txs[gpuCount:]
 	
-	results := make([]*TransactionResult, len(txs))
+	results := make([]*gpu.TxResult, len(txs))
 	var wg sync.WaitGroup
-	var processingError error
-	var mu sync.Mutex
 	
 	// Process GPU batch
 	if len(gpuTxs) > 0 {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			
-			err := h.gpuProcessor.ProcessTransactionsBatch(gpuTxs, func(gpuResults []*gpu.TxResult, err error) {
-				mu.Lock()
-				defer mu.Unlock()
-				
-				if err != nil {
-					processingError = err
-					return
-				}
-				
-				// Convert and store GPU results
-				for i, result := range gpuResults {
-					results[i] = &TransactionResult{
-						Hash:      result.Hash,
-						Valid:     result.Valid,
-						GasUsed:   result.GasUsed,
-						Error:     result.Error,
-						Processed: true,
-					}
-				}
-			})
-			
-			if err != nil {
-				mu.Lock()
-				processingError = err
-				mu.Unlock()
-			}
+			copy(results, h.processGPUBatch(gpuTxs, validator))
 		}()
 	}
-	
 	// Process CPU batch
 	if len(cpuTxs) > 0 {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			
-			err := h.processCPUBatch(cpuTxs, func(cpuResults []*TransactionResult, err error) {
-				mu.Lock()
-				defer mu.Unlock()
-				
-				if err != nil {
-					processingError = err
-					return
-				}
-				
-				// Store CPU results
-				copy(results[gpuCount:], cpuResults)
-			})
-			
-			if err != nil {
-				mu.Lock()
-				processingError = err
-				mu.Unlock()
-			}
-		}()
+		copy(results[gpuCount:], h.processCPUBatch(cpuTxs, validator))
 	}
-	
-	// Wait for both to complete
+	// Wait for both to complete, the state checks need the whole batch
 	wg.Wait()
-	
-	if processingError != nil {
-		callback(nil, processingError)
-		return processingError
-	}
-	
+	validator.PrevalidateState(txs, results)
+
 	// Update statistics
 	duration := time.Since(start)
 	h.updateStats(uint64(cpuCount), uint64(gpuCount), duration, ProcessingStrategyHybrid)
 	
-	callback(results, nil)
+	callback(NewTransactionResults(results), nil)
 	return nil
 }
 
-// processCPUBatch processes a batch using CPU
-func (h *HybridProcessor) processCPUBatch(txs []*types.Transaction, callback func([]*TransactionResult, error)) error {
-	results := make([]*TransactionResult, len(txs))
+// processGPUBatch runs the stateless checks of a batch on the GPU, waiting for
+// the results. Batches the GPU fails to process are checked on the CPU.
+func (h *HybridProcessor) processGPUBatch(txs []*types.Transaction, validator *gpu.TxPrevalidator) []*gpu.TxResult {
+	var (
+		results []*gpu.TxResult
+		failure error
+		done    = make(chan struct{})
+	)
+	err := h.gpuProcessor.ProcessTransactionsBatch(txs, validator, func(gpuResults []*gpu.TxResult, err error) {
+		results, failure = gpuResults, err
+		close(done)
+	})
+	if err == nil {
+		<-done
+		err = failure
+	}
+	if err != nil {
+		log.Debug("GPU prevalidation failed, falling back to CPU", "txs", len(txs), "err", err)
+		return h.processCPUBatch(txs, validator)
+	}
+	return results
+}
+
+// processCPUBatch runs the stateless checks of a batch on the CPU workers.
+func (h *HybridProcessor) processCPUBatch(txs []*types.Transaction, validator *gpu.TxPrevalidator) []*gpu.TxResult {
+	results := make([]*gpu.TxResult, len(txs))
+	var wg sync.WaitGroup
 	
-	// Simple CPU processing
-	for i, tx := range txs {
-		results[i] = &TransactionResult{
-			Hash:      tx.Hash(),
-			Valid:     true, // Simplified validation
-			GasUsed:   tx.Gas(),
-			Processed: true,
+	// Process in parallel using CPU workers, checking the chunks the
+	// workers don't accept inline
+	for i := 0; i < len(txs); i += cpuChunkSize {
+		end := i + cpuChunkSize
+		if end > len(txs) {
+			end = len(txs)
+		}
+		chunk := func(from, to int) func() error {
+			return func() error {
+				for j := from; j < to; j++ {
+					results[j] = validator.PrevalidateStateless(txs[j])
+				}
+				return nil
+			}
+		}(i, end)
+
+		wg.Add(1)
+		err := h.cpuProcessor.SubmitTxTask(func() error {
+			defer wg.Done()
+			return chunk()
+		}, nil)
+		if err != nil {
+			wg.Done()
+			chunk()
 		}
 	}
-	
-	callback(results, nil)
-	return nil
+	wg.Wait()
+	return results
 }
 
 // TransactionResult holds the result of hybrid transaction processing
 type TransactionResult struct {
-	Hash      common.Hash `json:"hash"`
-	Valid     bool        `json:"valid"`
-	GasUsed   uint64      `json:"gasUsed"`
-	Error     error       `json:"error,omitempty"`
-	Processed bool        `json:"processed"`
+	Hash      common.Hash        `json:"hash"`
+	Sender    common.Address     `json:"sender"`
+	Valid     bool               `json:"valid"`
+	Reason    gpu.TxRejectReason `json:"reason,omitempty"`
+	GasUsed   uint64             `json:"gasUsed"`
+	Error     error              `json:"error,omitempty"`
+	Processed bool               `json:"processed"`
+}
+
+// NewTransactionResults converts the results of the prevalidation into hybrid
+// transaction results.
+func NewTransactionResults(results []*gpu.TxResult) []*TransactionResult {
+	converted := make([]*TransactionResult, len(results))
+	for i, result := range results {
+		converted[i] = &TransactionResult{
+			Hash:      result.Hash,
+			Sender:    result.Sender,
+			Valid:     result.Valid,
+			Reason:    result.Reason,
+			GasUsed:   result.GasUsed,
+			Error:     result.Error,
+			Processed: true,
+		}
+	}
+	return converted
 }
 
 // updateStats updates processing statistics
 func (h *HybridProcessor) updateStats(cpuProcessed, gpuProcessed uint64, duration time.Duration, strategy ProcessingStrategy) {
+	h.tuner.record(cpuProcessed+gpuProcessed, duration)
+	
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	
 	h.stats.TotalProcessed += cpuProcessed + gpuProcessed
 	h.stats.CPUProcessed += cpuProcessed
 	h.stats.GPUProcessed += gpuProcessed
+	h.stats.LastStrategy = strategy
+	
+	// Update average batch size
+	if h.stats.AvgBatchSize == 0 {
+		h.stats.AvgBatchSize = cpuProcessed + gpuProcessed
+	} else {
+		h.stats.AvgBatchSize = (h.stats.AvgBatchSize + cpuProcessed + gpuProcessed) / 2
+	}
 	
 	// Update average latency
 	if h.stats.AvgLatency == 0 {
@@ -520,8 +511,9 @@ func (h *HybridProcessor) performanceMonitor() {
 		select {
 		case <-h.ctx.Done():
 			return
-		case <-ticker.C:
+		case now := <-ticker.C:
 			h.collectPerformanceMetrics()
+			h.evaluateTuning(now)
 		}
 	}
 }
@@ -536,8 +528,10 @@ func (h *HybridProcessor) collectPerformanceMetrics() {
 	// Get CPU stats with nil check
 	var cpuUtil float64
 	var avgCPULatency time.Duration
+	var queueDepth uint64
 	if h.cpuProcessor != nil && h.config != nil && h.config.CPUConfig != nil {
 		cpuStats := h.cpuProcessor.GetStats()
+		queueDepth += uint64(cpuStats.TxPoolRunning)
 		if h.config.CPUConfig.TxWorkers > 0 {
 			cpuUtil = float64(cpuStats.TxPoolRunning) / float64(h.config.CPUConfig.TxWorkers)
 		}
@@ -550,6 +544,7 @@ func (h *HybridProcessor) collectPerformanceMetrics() {
 	if h.gpuProcessor != nil && h.config != nil && h.config.GPUConfig != nil {
 		if h.gpuProcessor.IsGPUAvailable() {
 			gpuStats := h.gpuProcessor.GetStats()
+			queueDepth += uint64(gpuStats.TxQueueSize)
 			if h.config.GPUConfig.TxWorkers > 0 {
 				gpuUtil = float64(gpuStats.TxQueueSize) / float64(h.config.GPUConfig.TxWorkers)
 			}
@@ -586,6 +581,7 @@ func (h *HybridProcessor) collectPerformanceMetrics() {
 	h.mu.Lock()
 	h.stats.CPUUtilization = cpuUtil
 	h.stats.GPUUtilization = gpuUtil
+	h.stats.QueueDepth = queueDepth
 	
 	// Update memory usage (simplified)
 	var m runtime.MemStats
@@ -613,11 +609,14 @@ func (h *HybridProcessor) adaptiveLoadBalancer() {
 
 // adjustLoadBalancing adjusts the load balancing ratio
 func (h *HybridProcessor) adjustLoadBalancing() {
+	h.tuner.lock.Lock()
+	defer h.tuner.lock.Unlock()
+	
 	h.loadBalancer.mu.Lock()
 	defer h.loadBalancer.mu.Unlock()
 	
-	// Don't adjust too frequently
-	if time.Since(h.loadBalancer.lastAdjustment) < 10*time.Second {
+	// Don't adjust too frequently, nor while a retune is being evaluated
+	if time.Since(h.loadBalancer.lastAdjustment) < 10*time.Second || h.tuner.result.Pending {
 		return
 	}
 	
@@ -647,6 +646,7 @@ func (h *HybridProcessor) adjustLoadBalancing() {
 	if newRatio != currentRatio {
 		h.loadBalancer.adaptiveRatio = newRatio
 		h.loadBalancer.lastAdjustment = time.Now()
+		h.tuner.result.Tuning.CPUGPURatio = newRatio
 		
 		log.Debug("Load balancing ratio adjusted",
 			"oldRatio", currentRatio,
@@ -665,6 +665,11 @@ func (h *HybridProcessor) GetStats() HybridStats {
 	return h.stats
 }
 
+// ThroughputTarget returns the TPS the hybrid processor is configured to reach
+func (h *HybridProcessor) ThroughputTarget() uint64 {
+	return h.config.ThroughputTarget
+}
+
 // Close gracefully shuts down the hybrid processor
 func (h *HybridProcessor) Close() error {
 	log.Info("Shutting down hybrid processor...")
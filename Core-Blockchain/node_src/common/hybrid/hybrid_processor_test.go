@@ -0,0 +1,105 @@
+package hybrid
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/gopool"
+	"github.com/ethereum/go-ethereum/common/gpu"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// testState is a pending state funding every account.
+type testState struct{}
+
+func (testState) GetNonce(addr common.Address) uint64 { return 0 }
+
+func (testState) GetBalance(addr common.Address) *big.Int {
+	return new(big.Int).Mul(big.NewInt(params.Ether), big.NewInt(100))
+}
+
+// newTestHybridProcessor creates a hybrid processor splitting every batch of at
+// least two transactions between the CPU and the given accelerator.
+func newTestHybridProcessor(t *testing.T, name string, accel gpu.Accelerator) *HybridProcessor {
+	gpu.RegisterAccelerator(name, func(*gpu.GPUConfig) gpu.Accelerator { return accel })
+
+	h, err := NewHybridProcessor(&HybridConfig{
+		CPUConfig: &gopool.ProcessorConfig{MaxWorkers: 2, QueueSize: 16, TxWorkers: 2, ValidationWorkers: 1,
+			ConsensusWorkers: 1, StateWorkers: 1, NetworkWorkers: 1},
+		GPUConfig:    &gpu.GPUConfig{Accelerator: name, MaxBatchSize: 64, TxWorkers: 2},
+		EnableGPU:    true,
+		GPUThreshold: 1,
+		CPUGPURatio:  0.5,
+	})
+	if err != nil {
+		t.Fatalf("failed to create hybrid processor: %v", err)
+	}
+	return h
+}
+
+// testBatch returns a batch of transactions from a single sender with every
+// third one signed for another chain, along with its prevalidator.
+func testBatch(n int) ([]*types.Transaction, *gpu.TxPrevalidator) {
+	var (
+		key, _ = crypto.GenerateKey()
+		config = params.AllEthashProtocolChanges
+		signer = types.LatestSigner(config)
+		other  = types.LatestSignerForChainID(big.NewInt(1))
+		to     = common.HexToAddress("0x01")
+	)
+	txs := make([]*types.Transaction, n)
+	for i := range txs {
+		s := signer
+		if i%3 == 2 {
+			s = other
+		}
+		txs[i] = types.MustSignNewTx(key, s, &types.LegacyTx{Nonce: uint64(i), To: &to, Gas: params.TxGas, GasPrice: big.NewInt(params.InitialBaseFee)})
+	}
+	return txs, &gpu.TxPrevalidator{
+		Signer:  signer,
+		Rules:   config.Rules(common.Big1),
+		BaseFee: big.NewInt(params.InitialBaseFee),
+		State:   testState{},
+	}
+}
+
+func TestProcessTransactionsBatchAccelerated(t *testing.T) {
+	for _, fault := range []gpu.Fault{gpu.FaultNone, gpu.FaultError, gpu.FaultPanic} {
+		accel := gpu.NewSoftwareAccelerator(gpu.GPUTypeCUDA, 1)
+		accel.InjectFault(gpu.KernelTransactions, fault)
+
+		h := newTestHybridProcessor(t, "hybrid-test-"+fault.String(), accel)
+		txs, validator := testBatch(12)
+
+		var results []*TransactionResult
+		if err := h.ProcessTransactionsBatch(txs, validator, func(batchResults []*TransactionResult, err error) {
+			if err != nil {
+				t.Errorf("fault %v: batch failed: %v", fault, err)
+			}
+			results = batchResults
+		}); err != nil {
+			t.Fatalf("fault %v: failed to process batch: %v", fault, err)
+		}
+		// The GPU half of the batch has to match the CPU prevalidation, whether
+		// it was checked on the accelerator or fell back to the CPU
+		want := validator.Prevalidate(txs)
+		if len(results) != len(want) {
+			t.Fatalf("fault %v: have %d results, want %d", fault, len(results), len(want))
+		}
+		for i, result := range results {
+			if result.Valid != want[i].Valid || result.Reason != want[i].Reason {
+				t.Errorf("fault %v: tx %d: have valid %v reason %v, want valid %v reason %v", fault, i, result.Valid, result.Reason, want[i].Valid, want[i].Reason)
+			}
+		}
+		if calls := accel.Calls(gpu.KernelTransactions); calls != 1 {
+			t.Errorf("fault %v: have %d kernel calls, want 1", fault, calls)
+		}
+		if stats := h.GetStats(); stats.GPUProcessed == 0 || stats.CPUProcessed == 0 {
+			t.Errorf("fault %v: batch not split: %+v", fault, stats)
+		}
+		h.Close()
+	}
+}
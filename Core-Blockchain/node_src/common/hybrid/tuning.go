@@ -0,0 +1,299 @@
+package hybrid
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ProcessingStrategyAuto leaves the processing strategy of every batch to the
+// load balancer. It is only used as a strategy override.
+const ProcessingStrategyAuto ProcessingStrategy = -1
+
+// String implements fmt.Stringer, using the names understood by ParseProcessingStrategy.
+func (s ProcessingStrategy) String() string {
+	switch s {
+	case ProcessingStrategyAuto:
+		return "AUTO"
+	case ProcessingStrategyCPUOnly:
+		return "CPU_ONLY"
+	case ProcessingStrategyGPUOnly:
+		return "GPU_ONLY"
+	case ProcessingStrategyHybrid:
+		return "HYBRID"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", int(s))
+	}
+}
+
+// ParseProcessingStrategy parses the name of a processing strategy.
+func ParseProcessingStrategy(name string) (ProcessingStrategy, error) {
+	switch strings.ToUpper(strings.TrimSpace(name)) {
+	case "AUTO", "":
+		return ProcessingStrategyAuto, nil
+	case "CPU_ONLY":
+		return ProcessingStrategyCPUOnly, nil
+	case "GPU_ONLY":
+		return ProcessingStrategyGPUOnly, nil
+	case "HYBRID":
+		return ProcessingStrategyHybrid, nil
+	default:
+		return ProcessingStrategyAuto, fmt.Errorf("unknown processing strategy %q", name)
+	}
+}
+
+const (
+	// defaultTuningWindow is how long a retune is evaluated before it is kept.
+	defaultTuningWindow = 10 * time.Second
+
+	maxRatioStep     = 0.1 // Max change of the CPU/GPU ratio per retune
+	maxThresholdStep = 2   // Max factor the GPU threshold changes by per retune
+	maxBatchSizeStep = 2   // Max factor the batch size changes by per retune
+
+	tuningMaxTPSDrop     = 0.10 // Rollback if the TPS drops by more than 10%
+	tuningMaxLatencyRise = 0.25 // Rollback if the latency rises by more than 25%
+)
+
+var (
+	// errTuningPending is returned when retuning while the previous retune
+	// is still being evaluated.
+	errTuningPending = errors.New("previous retune still under evaluation")
+
+	// errInvalidTuning is returned for tunings out of range.
+	errInvalidTuning = errors.New("invalid tuning")
+)
+
+// Tuning holds the knobs of a hybrid processor that can be retuned at runtime.
+type Tuning struct {
+	CPUGPURatio      float64            `json:"cpuGpuRatio"`      // 0.0 = all CPU, 1.0 = all GPU
+	GPUThreshold     int                `json:"gpuThreshold"`     // Minimum batch size for GPU
+	BatchSize        int                `json:"batchSize"`        // Preferred batch size, 0 leaves it to the caller
+	StrategyOverride ProcessingStrategy `json:"strategyOverride"` // Strategy of batches reaching the GPU threshold
+}
+
+// TuningMetrics holds the performance measured over a tuning window.
+type TuningMetrics struct {
+	TPS     uint64        `json:"tps"`     // Transactions per second of processing time
+	Latency time.Duration `json:"latency"` // Average batch latency
+	Batches int           `json:"batches"` // Number of batches measured
+}
+
+// regressedFrom reports whether the metrics regressed from the baseline beyond
+// the tolerated TPS drop or latency rise.
+func (m TuningMetrics) regressedFrom(baseline TuningMetrics) bool {
+	if float64(m.TPS) < float64(baseline.TPS)*(1-tuningMaxTPSDrop) {
+		return true
+	}
+	return float64(m.Latency) > float64(baseline.Latency)*(1+tuningMaxLatencyRise)
+}
+
+// TuningResult reports the last retune of a hybrid processor.
+type TuningResult struct {
+	Generation uint64        `json:"generation"` // Increases with every applied retune
+	Tuning     Tuning        `json:"tuning"`     // Tuning in effect
+	Pending    bool          `json:"pending"`    // Retune still under evaluation
+	RolledBack bool          `json:"rolledBack"` // Retune reverted after a regression
+	Baseline   TuningMetrics `json:"baseline"`   // Performance before the retune
+	Measured   TuningMetrics `json:"measured"`   // Performance over the evaluation window
+}
+
+// tuningWindow accumulates the batches processed since it was last reset.
+type tuningWindow struct {
+	txs     uint64
+	busy    time.Duration
+	batches int
+}
+
+// metrics returns the performance measured over the window.
+func (w *tuningWindow) metrics() TuningMetrics {
+	if w.batches == 0 || w.busy <= 0 {
+		return TuningMetrics{}
+	}
+	return TuningMetrics{
+		TPS:     uint64(float64(w.txs) / w.busy.Seconds()),
+		Latency: w.busy / time.Duration(w.batches),
+		Batches: w.batches,
+	}
+}
+
+// tuner evaluates the retunes of a hybrid processor, rolling them back if the
+// performance measured after them regresses from the one measured before.
+type tuner struct {
+	lock     sync.Mutex
+	window   tuningWindow  // Batches since the last retune or evaluation
+	length   time.Duration // Evaluation window length
+	previous Tuning        // Tuning restored on regression
+	applied  time.Time     // Time of the retune under evaluation
+	result   TuningResult
+}
+
+// record accounts for a processed batch.
+func (t *tuner) record(txs uint64, duration time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.window.txs += txs
+	t.window.busy += duration
+	t.window.batches++
+}
+
+// Tuning returns the tuning currently in effect.
+func (h *HybridProcessor) Tuning() Tuning {
+	h.loadBalancer.mu.RLock()
+	defer h.loadBalancer.mu.RUnlock()
+
+	return h.loadBalancer.tuning()
+}
+
+// TuningResult returns the outcome of the last retune.
+func (h *HybridProcessor) TuningResult() TuningResult {
+	h.evaluateTuning(time.Now())
+
+	h.tuner.lock.Lock()
+	defer h.tuner.lock.Unlock()
+
+	return h.tuner.result
+}
+
+// Retune moves the tuning of the processor towards the target and returns the
+// tuning applied. Every knob moves by a bounded step, so reaching a distant
+// target takes several retunes. The performance before and after the retune
+// is compared once the evaluation window passes, and the retune is rolled
+// back if the TPS or the latency regress. No retune is accepted while the
+// previous one is being evaluated.
+func (h *HybridProcessor) Retune(target Tuning) (Tuning, error) {
+	if math.IsNaN(target.CPUGPURatio) || target.CPUGPURatio < 0 || target.CPUGPURatio > 1 {
+		return Tuning{}, fmt.Errorf("%w: cpu/gpu ratio %v out of range", errInvalidTuning, target.CPUGPURatio)
+	}
+	if target.GPUThreshold < 0 || target.BatchSize < 0 {
+		return Tuning{}, fmt.Errorf("%w: gpu threshold %d, batch size %d", errInvalidTuning, target.GPUThreshold, target.BatchSize)
+	}
+	if target.StrategyOverride < ProcessingStrategyAuto || target.StrategyOverride > ProcessingStrategyHybrid {
+		return Tuning{}, fmt.Errorf("%w: strategy %v", errInvalidTuning, target.StrategyOverride)
+	}
+	now := time.Now()
+	h.evaluateTuning(now)
+
+	h.tuner.lock.Lock()
+	defer h.tuner.lock.Unlock()
+
+	if h.tuner.result.Pending {
+		return Tuning{}, errTuningPending
+	}
+	h.loadBalancer.mu.Lock()
+	current := h.loadBalancer.tuning()
+	applied := Tuning{
+		CPUGPURatio:      stepFloat(current.CPUGPURatio, target.CPUGPURatio, maxRatioStep),
+		GPUThreshold:     stepInt(current.GPUThreshold, target.GPUThreshold, maxThresholdStep),
+		BatchSize:        stepInt(current.BatchSize, target.BatchSize, maxBatchSizeStep),
+		StrategyOverride: target.StrategyOverride,
+	}
+	if applied == current {
+		h.loadBalancer.mu.Unlock()
+		return current, nil
+	}
+	h.loadBalancer.setTuning(applied)
+	h.loadBalancer.mu.Unlock()
+
+	// Evaluate the retune against the batches processed since the last one,
+	// retunes without a baseline can't regress
+	baseline := h.tuner.window.metrics()
+	h.tuner.previous = current
+	h.tuner.applied = now
+	h.tuner.window = tuningWindow{}
+	h.tuner.result = TuningResult{
+		Generation: h.tuner.result.Generation + 1,
+		Tuning:     applied,
+		Pending:    baseline.Batches > 0,
+		Baseline:   baseline,
+	}
+	log.Debug("Hybrid processor retuned", "ratio", applied.CPUGPURatio, "threshold", applied.GPUThreshold,
+		"batchSize", applied.BatchSize, "strategy", applied.StrategyOverride, "baselineTps", baseline.TPS,
+		"baselineLatency", baseline.Latency)
+	return applied, nil
+}
+
+// evaluateTuning concludes the evaluation of the last retune once its window
+// has passed, rolling it back if it regressed. Windows without any batches
+// are extended.
+func (h *HybridProcessor) evaluateTuning(now time.Time) {
+	h.tuner.lock.Lock()
+	defer h.tuner.lock.Unlock()
+
+	if !h.tuner.result.Pending || now.Sub(h.tuner.applied) < h.tuner.length {
+		return
+	}
+	measured := h.tuner.window.metrics()
+	if measured.Batches == 0 {
+		return
+	}
+	h.tuner.window = tuningWindow{}
+	h.tuner.result.Pending = false
+	h.tuner.result.Measured = measured
+
+	if !measured.regressedFrom(h.tuner.result.Baseline) {
+		log.Debug("Hybrid processor retune kept", "generation", h.tuner.result.Generation,
+			"tps", measured.TPS, "latency", measured.Latency)
+		return
+	}
+	h.loadBalancer.mu.Lock()
+	h.loadBalancer.setTuning(h.tuner.previous)
+	h.loadBalancer.mu.Unlock()
+
+	h.tuner.result.Tuning = h.tuner.previous
+	h.tuner.result.RolledBack = true
+	log.Info("Hybrid processor retune rolled back", "generation", h.tuner.result.Generation,
+		"tps", measured.TPS, "baselineTps", h.tuner.result.Baseline.TPS,
+		"latency", measured.Latency, "baselineLatency", h.tuner.result.Baseline.Latency)
+}
+
+// tuning returns the tuning of the load balancer, the caller holds its lock.
+func (lb *LoadBalancer) tuning() Tuning {
+	return Tuning{
+		CPUGPURatio:      lb.adaptiveRatio,
+		GPUThreshold:     lb.gpuThreshold,
+		BatchSize:        lb.batchSize,
+		StrategyOverride: lb.strategyOverride,
+	}
+}
+
+// setTuning applies a tuning to the load balancer, the caller holds its lock.
+func (lb *LoadBalancer) setTuning(tuning Tuning) {
+	lb.adaptiveRatio = tuning.CPUGPURatio
+	lb.gpuThreshold = tuning.GPUThreshold
+	lb.batchSize = tuning.BatchSize
+	lb.strategyOverride = tuning.StrategyOverride
+	lb.lastAdjustment = time.Now()
+}
+
+// stepFloat moves from current towards target by at most step.
+func stepFloat(current, target, step float64) float64 {
+	switch {
+	case target > current+step:
+		return current + step
+	case target < current-step:
+		return current - step
+	default:
+		return target
+	}
+}
+
+// stepInt moves from current towards target by at most the given factor. Unset
+// values, i.e. zeros, move to the target directly.
+func stepInt(current, target, factor int) int {
+	switch {
+	case current == 0 || target == 0:
+		return target
+	case target > current*factor:
+		return current * factor
+	case target*factor < current:
+		return (current + factor - 1) / factor
+	default:
+		return target
+	}
+}
@@ -0,0 +1,112 @@
+package hybrid
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/gpu"
+)
+
+func TestRetuneBoundedSteps(t *testing.T) {
+	h := newTestHybridProcessor(t, "hybrid-test-retune", gpu.NewSoftwareAccelerator(gpu.GPUTypeCUDA, 1))
+	defer h.Close()
+
+	if have, want := h.Tuning(), (Tuning{CPUGPURatio: 0.5, GPUThreshold: 1, StrategyOverride: ProcessingStrategyAuto}); have != want {
+		t.Fatalf("initial tuning mismatch: have %+v, want %+v", have, want)
+	}
+	for _, target := range []Tuning{
+		{CPUGPURatio: 1.5, GPUThreshold: 1},
+		{CPUGPURatio: 0.5, GPUThreshold: -1},
+		{CPUGPURatio: 0.5, GPUThreshold: 1, BatchSize: -1},
+		{CPUGPURatio: 0.5, GPUThreshold: 1, StrategyOverride: ProcessingStrategyHybrid + 1},
+	} {
+		if _, err := h.Retune(target); !errors.Is(err, errInvalidTuning) {
+			t.Errorf("tuning %+v: have error %v, want %v", target, err, errInvalidTuning)
+		}
+	}
+	// Every knob moves by at most a step towards the target
+	target := Tuning{CPUGPURatio: 1, GPUThreshold: 100, BatchSize: 5000, StrategyOverride: ProcessingStrategyGPUOnly}
+	steps := []Tuning{
+		{CPUGPURatio: 0.6, GPUThreshold: 2, BatchSize: 5000, StrategyOverride: ProcessingStrategyGPUOnly},
+		{CPUGPURatio: 0.7, GPUThreshold: 4, BatchSize: 5000, StrategyOverride: ProcessingStrategyGPUOnly},
+	}
+	for i, want := range steps {
+		applied, err := h.Retune(target)
+		if err != nil {
+			t.Fatalf("step %d: failed to retune: %v", i, err)
+		}
+		if applied.CPUGPURatio-want.CPUGPURatio > 1e-9 || want.CPUGPURatio-applied.CPUGPURatio > 1e-9 {
+			t.Errorf("step %d: have ratio %v, want %v", i, applied.CPUGPURatio, want.CPUGPURatio)
+		}
+		applied.CPUGPURatio = want.CPUGPURatio
+		if applied != want {
+			t.Errorf("step %d: have tuning %+v, want %+v", i, applied, want)
+		}
+		// Retunes without a baseline are kept right away
+		if result := h.TuningResult(); result.Generation != uint64(i+1) || result.Pending {
+			t.Errorf("step %d: unexpected result %+v", i, result)
+		}
+	}
+	// The strategy override applies to the batches reaching the threshold
+	if strategy := h.determineProcessingStrategy(3); strategy != ProcessingStrategyCPUOnly {
+		t.Errorf("small batch: have strategy %v, want %v", strategy, ProcessingStrategyCPUOnly)
+	}
+	if strategy := h.determineProcessingStrategy(4); strategy != ProcessingStrategyGPUOnly {
+		t.Errorf("large batch: have strategy %v, want %v", strategy, ProcessingStrategyGPUOnly)
+	}
+	if stepInt(1000, 10, 2) != 500 || stepInt(1001, 10, 2) != 501 || stepInt(1000, 600, 2) != 600 {
+		t.Errorf("integer steps out of bounds")
+	}
+}
+
+func TestRetuneRollback(t *testing.T) {
+	h := newTestHybridProcessor(t, "hybrid-test-rollback", gpu.NewSoftwareAccelerator(gpu.GPUTypeCUDA, 1))
+	defer h.Close()
+
+	tests := []struct {
+		txs      uint64
+		duration time.Duration
+		rollback bool
+	}{
+		{txs: 1000, duration: time.Second, rollback: false},            // unchanged
+		{txs: 950, duration: time.Second, rollback: false},             // within the tolerated drop
+		{txs: 800, duration: time.Second, rollback: true},              // TPS regressed
+		{txs: 1500, duration: 1500 * time.Millisecond, rollback: true}, // latency regressed
+	}
+	for i, tt := range tests {
+		// Establish the baseline and retune
+		h.tuner.record(1000, time.Second)
+		before := h.Tuning()
+		applied, err := h.Retune(Tuning{CPUGPURatio: before.CPUGPURatio + 0.05, GPUThreshold: before.GPUThreshold, StrategyOverride: ProcessingStrategyHybrid})
+		if err != nil {
+			t.Fatalf("test %d: failed to retune: %v", i, err)
+		}
+		if _, err := h.Retune(before); !errors.Is(err, errTuningPending) {
+			t.Errorf("test %d: have error %v, want %v", i, err, errTuningPending)
+		}
+		// Evaluation waits for the window to pass with batches measured
+		h.evaluateTuning(time.Now().Add(h.tuner.length))
+		if !h.TuningResult().Pending {
+			t.Fatalf("test %d: retune evaluated without batches", i)
+		}
+		h.tuner.record(tt.txs, tt.duration)
+		h.evaluateTuning(time.Now())
+		if !h.TuningResult().Pending {
+			t.Fatalf("test %d: retune evaluated before the window passed", i)
+		}
+		h.evaluateTuning(time.Now().Add(h.tuner.length))
+
+		result := h.TuningResult()
+		if result.Pending || result.RolledBack != tt.rollback {
+			t.Errorf("test %d: have pending %v rolled back %v, want rolled back %v", i, result.Pending, result.RolledBack, tt.rollback)
+		}
+		want := applied
+		if tt.rollback {
+			want = before
+		}
+		if have := h.Tuning(); have != want || result.Tuning != want {
+			t.Errorf("test %d: have tuning %+v, want %+v", i, have, want)
+		}
+	}
+}
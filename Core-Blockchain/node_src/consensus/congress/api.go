@@ -20,8 +20,11 @@ import (
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
@@ -90,12 +93,119 @@ func (api *API) GetValidatorsAtHash(hash common.Hash) ([]common.Address, error)
 	return snap.validators(), nil
 }
 
+// RegisteredPQKey is a key registered in the PQ key registry.
+type RegisteredPQKey struct {
+	Algorithm       byte           `json:"algorithm"`
+	PublicKey       hexutil.Bytes  `json:"publicKey"`
+	BackupAlgorithm byte           `json:"backupAlgorithm,omitempty"`
+	BackupPublicKey hexutil.Bytes  `json:"backupPublicKey,omitempty"`
+	Activation      hexutil.Uint64 `json:"activation"`
+}
+
+// PQKeyInfo is the ML-DSA key a validator seals blocks with, along with the keys
+// it registered in the PQ key registry.
+type PQKeyInfo struct {
+	Sealing    *PQKey           `json:"sealing"`
+	Registered *RegisteredPQKey `json:"registered,omitempty"`
+	Pending    *RegisteredPQKey `json:"pending,omitempty"`
+}
+
+// newRegisteredPQKey converts a key read from the registry, which may be nil.
+func newRegisteredPQKey(key *vm.PQRegistryKey) *RegisteredPQKey {
+	if key == nil {
+		return nil
+	}
+	return &RegisteredPQKey{
+		Algorithm:       key.Algorithm,
+		PublicKey:       key.PublicKey,
+		BackupAlgorithm: key.BackupAlgorithm,
+		BackupPublicKey: key.BackupPublicKey,
+		Activation:      hexutil.Uint64(key.Activation),
+	}
+}
+
+// GetPQKeys retrieves the post-quantum keys of the validators at the specified
+// block: the ML-DSA keys they seal with, and the keys they registered in the
+// PQ key registry if the state of the block is available.
+func (api *API) GetPQKeys(number *rpc.BlockNumber) (map[common.Address]*PQKeyInfo, error) {
+	// Retrieve the requested block number (or current if none requested)
+	var header *types.Header
+	if number == nil || *number == rpc.LatestBlockNumber {
+		header = api.chain.CurrentHeader()
+	} else {
+		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
+	}
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	snap, err := api.congress.snapshot(api.chain, header.Number.Uint64(), header.Hash(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var statedb *state.StateDB
+	if api.chain.Config().IsPQKeyRegistry(header.Number) && api.congress.stateFn != nil {
+		statedb, _ = api.congress.stateFn(header.Root)
+	}
+	keys := make(map[common.Address]*PQKeyInfo, len(snap.Validators))
+	for _, validator := range snap.validators() {
+		info := &PQKeyInfo{Sealing: snap.PQKeys[validator]}
+		if statedb != nil {
+			info.Registered = newRegisteredPQKey(vm.ReadPQKey(statedb, validator, header.Number.Uint64()))
+			info.Pending = newRegisteredPQKey(vm.ReadPendingPQKey(statedb, validator, header.Number.Uint64()))
+		}
+		keys[validator] = info
+	}
+	return keys, nil
+}
+
+// feeChainReader is the part of the chain needed to explain the fee shares of
+// a block.
+type feeChainReader interface {
+	GetBlock(hash common.Hash, number uint64) *types.Block
+	GetReceiptsByHash(hash common.Hash) types.Receipts
+}
+
+// GetFeeShares explains the attribution of the fees collected by the specified
+// block to the recipients of its transactions, as passed to the validators
+// contract when distributing them.
+func (api *API) GetFeeShares(number *rpc.BlockNumber) (*BlockFeeShares, error) {
+	// Retrieve the requested block number (or current if none requested)
+	var header *types.Header
+	if number == nil || *number == rpc.LatestBlockNumber {
+		header = api.chain.CurrentHeader()
+	} else {
+		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
+	}
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	chain, ok := api.chain.(feeChainReader)
+	if !ok {
+		return nil, errMissingReceipts
+	}
+	block := chain.GetBlock(header.Hash(), header.Number.Uint64())
+	if block == nil {
+		return nil, errUnknownBlock
+	}
+	receipts := chain.GetReceiptsByHash(block.Hash())
+	if receipts == nil && len(block.Transactions()) > 0 {
+		return nil, errMissingReceipts
+	}
+	return api.congress.blockFeeShares(block, receipts, types.MakeSigner(api.chain.Config(), header.Number))
+}
+
 type status struct {
 	InturnPercent float64                `json:"inturnPercent"`
 	SigningStatus map[common.Address]int `json:"sealerActivity"`
 	NumBlocks     uint64                 `json:"numBlocks"`
 }
 
+// GetDoubleSignEvidence retrieves the evidence of validators sealing two
+// different headers at the same height detected by this node.
+func (api *API) GetDoubleSignEvidence() []*DoubleSignEvidence {
+	return api.congress.evidences()
+}
+
 // Status returns the status of the last N blocks,
 // - the number of active validators,
 // - the number of validators,
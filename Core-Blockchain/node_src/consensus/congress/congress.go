@@ -20,7 +20,6 @@ package congress
 import (
 	"bytes"
 	"encoding/binary"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -208,10 +207,19 @@ type Congress struct {
 	validator common.Address // Ethereum address of the signing key
 	signFn    ValidatorFn    // Validator function to authorize hashes with
 	signTxFn  SignTxFn
+	pqSigners []PQSigner   // ML-DSA keys of the validator, sealing after the PQT fork
 	lock      sync.RWMutex // Protects the validator fields
 
 	stateFn StateFn // Function to get state by state root
 
+	seals        *lru.Cache // First seals of recent (validator, height) pairs, to detect double-signing
+	evidenceLock sync.Mutex // Makes sure double-sign evidence is only stored and reported once
+	txPool       TxPool     // Transaction pool to submit double-sign reports to
+
+	votes      *votePool   // Finality votes on recent blocks, aggregated into attestations
+	voteLock   sync.Mutex  // Makes sure the validator never casts conflicting votes
+	latestVote *types.Vote // Latest finality vote cast by the validator
+
 	abi map[string]abi.ABI // Interactive with system contracts
 
 	chain consensus.ChainHeaderReader // chain is only for reading parent headers when getting blacklist and rules
@@ -233,6 +241,7 @@ func New(chainConfig *params.ChainConfig, db ethdb.Database) *Congress {
 	signatures, _ := lru.NewARC(inmemorySignatures)
 	blacklists, _ := lru.New(inmemoryBlacklist)
 	rules, _ := lru.New(inmemoryBlacklist)
+	seals, _ := lru.New(inmemorySeals)
 
 	abi := systemcontract.GetInteractiveABI()
 
@@ -244,6 +253,8 @@ func New(chainConfig *params.ChainConfig, db ethdb.Database) *Congress {
 		signatures:      signatures,
 		blacklists:      blacklists,
 		eventCheckRules: rules,
+		seals:           seals,
+		votes:           newVotePool(),
 		proposals:       make(map[common.Address]bool),
 		abi:             abi,
 		signer:          types.LatestSignerForChainID(chainConfig.ChainID),
@@ -316,8 +327,14 @@ func (c *Congress) verifyHeader(chain consensus.ChainHeaderReader, header *types
 	// check extra data
 	isEpoch := number%c.config.Epoch == 0
 
+	// After the PQT fork the ML-DSA seal precedes the secp256k1 seal, and after
+	// the fast finality fork the attestation precedes both
+	trailer, err := extraTrailer(chain.Config(), header)
+	if err != nil {
+		return err
+	}
 	// Ensure that the extra-data contains a validator list on checkpoint, but none otherwise
-	validatorsBytes := len(header.Extra) - extraVanity - extraSeal
+	validatorsBytes := len(header.Extra) - extraVanity - trailer
 	if !isEpoch && validatorsBytes != 0 {
 		return errExtraValidators
 	}
@@ -325,6 +342,16 @@ func (c *Congress) verifyHeader(chain consensus.ChainHeaderReader, header *types
 	if isEpoch && validatorsBytes%common.AddressLength != 0 {
 		return errExtraValidators
 	}
+	// Registered keys are only activated by epoch blocks
+	if chain.Config().IsPQKeyRegistry(header.Number) && !isEpoch {
+		updatesTrailer, err := keyUpdatesTrailer(chain.Config(), header)
+		if err != nil {
+			return err
+		}
+		if updates, _, _ := decodeKeyUpdates(header, updatesTrailer); len(updates) > 0 {
+			return errInvalidKeyUpdates
+		}
+	}
 
 	// Ensure that the mix digest is zero as we don't have fork protection currently
 	if header.MixDigest != (common.Hash{}) {
@@ -427,11 +454,20 @@ func (c *Congress) snapshot(chain consensus.ChainHeaderReader, number uint64, ha
 			if checkpoint != nil {
 				hash := checkpoint.Hash()
 
-				validators := make([]common.Address, (len(checkpoint.Extra)-extraVanity-extraSeal)/common.AddressLength)
+				trailer, err := extraTrailer(chain.Config(), checkpoint)
+				if err != nil {
+					return nil, err
+				}
+				validators := make([]common.Address, (len(checkpoint.Extra)-extraVanity-trailer)/common.AddressLength)
 				for i := 0; i < len(validators); i++ {
 					copy(validators[i][:], checkpoint.Extra[extraVanity+i*common.AddressLength:])
 				}
 				snap = newSnapshot(c.config, c.signatures, number, hash, validators)
+				if isCheckpointBlock(chain.Config(), checkpoint.Number) {
+					if err := snap.restore(chain.Config(), checkpoint); err != nil {
+						return nil, err
+					}
+				}
 				if err := snap.store(c.db); err != nil {
 					return nil, err
 				}
@@ -510,7 +546,7 @@ func (c *Congress) verifySeal(chain consensus.ChainHeaderReader, header *types.H
 	}
 
 	// Resolve the authorization key and check against validators
-	signer, err := ecrecover(header, c.signatures)
+	signer, err := sealer(chain.Config(), header, c.signatures)
 	if err != nil {
 		return err
 	}
@@ -542,6 +578,39 @@ func (c *Congress) verifySeal(chain consensus.ChainHeaderReader, header *types.H
 		}
 	}
 
+	// Epoch blocks must carry the snapshot state for nodes starting from them
+	if isCheckpointBlock(chain.Config(), header.Number) {
+		if err := verifyCheckpoint(chain.Config(), snap, header); err != nil {
+			return err
+		}
+	}
+	// After the PQT fork the block must also carry a valid ML-DSA seal, and
+	// nothing but that once the transition is over
+	if chain.Config().IsPQTFork(header.Number) {
+		if chain.Config().IsPQTEnforced(header.Number) {
+			if !bytes.Equal(header.Extra[len(header.Extra)-extraSeal:], make([]byte, extraSeal)) {
+				return errUnexpectedECDSASeal
+			}
+		}
+		if err := c.verifyPQSeal(snap, header); err != nil {
+			return err
+		}
+	}
+	// After the fast finality fork the attestation, if any, must be signed by a
+	// supermajority of the validators
+	if chain.Config().IsFastFinality(header.Number) {
+		if err := c.verifyAttestation(chain, snap, header, parents); err != nil {
+			return err
+		}
+	}
+	// After the random beacon fork the validator must commit to a new secret and
+	// reveal its previous one correctly
+	if chain.Config().IsRandomBeacon(header.Number) {
+		if err := c.verifyBeacon(chain, snap, header, signer); err != nil {
+			return err
+		}
+	}
+	c.checkDoubleSign(header, signer)
 	return nil
 }
 
@@ -567,6 +636,7 @@ func (c *Congress) Prepare(chain consensus.ChainHeaderReader, header *types.Head
 	}
 	header.Extra = header.Extra[:extraVanity]
 
+	var keyUpdates []*pqKeyUpdate
 	if number%c.config.Epoch == 0 {
 		newSortedValidators, err := c.getTopValidators(chain, header)
 		if err != nil {
@@ -576,6 +646,30 @@ func (c *Congress) Prepare(chain consensus.ChainHeaderReader, header *types.Head
 		for _, validator := range newSortedValidators {
 			header.Extra = append(header.Extra, validator.Bytes()...)
 		}
+		if isCheckpointBlock(chain.Config(), header.Number) {
+			header.Extra = append(header.Extra, encodeCheckpoint(snap)...)
+		}
+		if chain.Config().IsPQKeyRegistry(header.Number) {
+			if keyUpdates, err = c.prepareKeyUpdates(chain, snap, header, newSortedValidators); err != nil {
+				return err
+			}
+		}
+	}
+	if chain.Config().IsPQKeyRegistry(header.Number) {
+		header.Extra = append(header.Extra, encodeKeyUpdates(keyUpdates)...)
+	}
+	if chain.Config().IsRandomBeacon(header.Number) {
+		if err := c.prepareBeacon(snap, header); err != nil {
+			return err
+		}
+	}
+	if chain.Config().IsFastFinality(header.Number) {
+		header.Extra = append(header.Extra, encodeAttestation(c.assembleAttestation(chain, snap, header))...)
+	}
+	if chain.Config().IsPQTFork(header.Number) {
+		if err := c.preparePQSeal(snap, header); err != nil {
+			return err
+		}
 	}
 	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
 
@@ -605,8 +699,15 @@ func (c *Congress) Finalize(chain consensus.ChainHeaderReader, header *types.Hea
 		}
 	}
 
+	punished := false
 	if header.Difficulty.Cmp(diffInTurn) != 0 {
-		if err := c.tryPunishValidator(chain, header, state); err != nil {
+		var err error
+		if punished, err = c.tryPunishValidator(chain, header, state); err != nil {
+			return err
+		}
+	}
+	if !punished && chain.Config().IsRandomBeacon(header.Number) {
+		if err := c.punishWithheldReveal(chain, header, state); err != nil {
 			return err
 		}
 	}
@@ -622,74 +723,7 @@ func (c *Congress) Finalize(chain consensus.ChainHeaderReader, header *types.Hea
 	}
 
 	// deposit block reward if any tx exists.
-	var addr [] common.Address
-	var gass [] uint64
-
-		out3, err := json.Marshal(txs)
-	    if err != nil {
-	        panic (err)
-	    }
-
-		log.Info("FULL TRANSACTION OBJECT >>> " + string(out3))
-
-
-	
-	if len(*txs) > 0 {
-				
-		var totalGasSum uint64
-
-		for i := 0; i < len(*txs); i++ {
-			TO := (*txs)[i]
-
-			if TO.To() == nil {
-				addr = append(addr, common.HexToAddress("0x0000000000000000000000000000000000000000"))
-			} else {
-				addr = append(addr, *TO.To())
-			}
-
-			gasFee := TO.Gas() * TO.GasPrice().Uint64()
-			gass = append(gass, gasFee)
-
-			// Accumulate gasFee to totalGasSum
-			totalGasSum += gasFee
-		}
-		
-	    fee := state.GetBalance(consensus.FeeRecoder)
-
-		feeUint64 := fee.Uint64()
-
-		if totalGasSum > feeUint64 {
-		
-			percentDifference := float64(totalGasSum-feeUint64) / float64(totalGasSum) * 100
-
-			for i := 0; i < len(gass); i++ {
-				decreaseAmount := uint64(float64(gass[i]) * (percentDifference / 100.0))
-				gass[i] -= decreaseAmount
-			}
-		}
-
-
-
-	    out, err := json.Marshal(addr)
-	    if err != nil {
-	        panic (err)
-	    }
-
-	    out1, err := json.Marshal(gass)
-	    if err != nil {
-	        panic (err)
-	    }
-	    
-	    log.Info("REQUIRED TO ADDRESS FOR TEST 2 >> " + string(out))
-	    log.Info("REQUIRED GAS INFO FOR TEST 2 >> " + string(out1))
-
-
-	    	
-		if err := c.trySendBlockReward(chain, header, state,addr,gass); err != nil {
-			//panic(err)
-			log.Info(err.Error())
-		}
-	}
+	c.distributeBlockFees(chain, header, state, *txs, *receipts)
 
 	// do epoch thing at the end, because it will update active validators
 	if header.Number.Uint64()%c.config.Epoch == 0 {
@@ -703,10 +737,19 @@ func (c *Congress) Finalize(chain consensus.ChainHeaderReader, header *types.Hea
 			copy(validatorsBytes[i*common.AddressLength:], validator.Bytes())
 		}
 
-		extraSuffix := len(header.Extra) - extraSeal
+		trailer, err := extraTrailer(chain.Config(), header)
+		if err != nil {
+			return err
+		}
+		extraSuffix := len(header.Extra) - trailer
 		if !bytes.Equal(header.Extra[extraVanity:extraSuffix], validatorsBytes) {
 			return errInvalidExtraValidators
 		}
+		if chain.Config().IsPQKeyRegistry(header.Number) {
+			if err := c.verifyKeyUpdates(chain, header, newValidators, state); err != nil {
+				return err
+			}
+		}
 	}
 
 	//handle system governance Proposal
@@ -747,6 +790,11 @@ func (c *Congress) Finalize(chain consensus.ChainHeaderReader, header *types.Hea
 		}
 	}
 
+	// Make the random beacon recorded by beacon blocks available to contracts
+	if err := c.storeRandomBeacon(chain, header, state); err != nil {
+		return err
+	}
+
 	// No block rewards in PoA, so the state remains as is and uncles are dropped
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
 	header.UncleHash = types.CalcUncleHash(nil)
@@ -770,79 +818,21 @@ func (c *Congress) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header
 	}
 
 	// punish validator if necessary
+	punished := false
 	if header.Difficulty.Cmp(diffInTurn) != 0 {
-		if err := c.tryPunishValidator(chain, header, state); err != nil {
+		if punished, err = c.tryPunishValidator(chain, header, state); err != nil {
 			panic(err)
 		}
 	}
-
-	// deposit block reward if any tx exists.
-	var addr [] common.Address
-	var gass [] uint64
-	//addr = new[len(txs)]
-	
-	    out3, err := json.Marshal(txs)
-	    if err != nil {
-	        panic (err)
-	    }
-
-		log.Info("FULL TRANSACTION OBJECTS >>> " + string(out3))
-		
-	
-	if len(txs) > 0 {
-				
-		var totalGasSum uint64
-
-		for i := 0; i < len(txs); i++ {
-			TO := txs[i]
-
-			if TO.To() == nil {
-				addr = append(addr, common.HexToAddress("0x0000000000000000000000000000000000000000"))
-			} else {
-				addr = append(addr, *TO.To())
-			}
-
-			gasFee := TO.Gas() * TO.GasPrice().Uint64()
-			gass = append(gass, gasFee)
-
-			// Accumulate gasFee to totalGasSum
-			totalGasSum += gasFee
-		}
-		
-	    fee := state.GetBalance(consensus.FeeRecoder)
-
-		feeUint64 := fee.Uint64()
-
-		if totalGasSum > feeUint64 {
-		
-			percentDifference := float64(totalGasSum-feeUint64) / float64(totalGasSum) * 100
-
-			for i := 0; i < len(gass); i++ {
-				decreaseAmount := uint64(float64(gass[i]) * (percentDifference / 100.0))
-				gass[i] -= decreaseAmount
-			}
-		}
-
-	    out, err := json.Marshal(addr)
-	    if err != nil {
-	        panic (err)
-	    }
-
-	    out1, err := json.Marshal(gass)
-	    if err != nil {
-	        panic (err)
-	    }
-	    
-	    log.Info("REQUIRED TO ADDRESS FOR TEST >> " + string(out))
-	    log.Info("REQUIRED GAS INFO FOR TEST >> " + string(out1))
-		
-		if err := c.trySendBlockReward(chain, header, state,addr,gass); err != nil {
-			//panic(err)
-			log.Info(err.Error())
-
+	if !punished && chain.Config().IsRandomBeacon(header.Number) {
+		if err := c.punishWithheldReveal(chain, header, state); err != nil {
+			panic(err)
 		}
 	}
 
+	// deposit block reward if any tx exists.
+	c.distributeBlockFees(chain, header, state, txs, receipts)
+
 	// do epoch thing at the end, because it will update active validators
 	if header.Number.Uint64()%c.config.Epoch == 0 {
 		if _, err := c.doSomethingAtEpoch(chain, header, state); err != nil {
@@ -891,6 +881,11 @@ func (c *Congress) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header
 		}
 	}
 
+	// Make the random beacon recorded by beacon blocks available to contracts
+	if err := c.storeRandomBeacon(chain, header, state); err != nil {
+		return nil, nil, err
+	}
+
 	// No block rewards in PoA, so the state remains as is and uncles are dropped
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
 	header.UncleHash = types.CalcUncleHash(nil)
@@ -935,11 +930,13 @@ func (c *Congress) trySendBlockReward(chain consensus.ChainHeaderReader, header
 	return nil
 }
 
-func (c *Congress) tryPunishValidator(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB) error {
+// tryPunishValidator punishes the in-turn validator of an out-of-turn block if
+// it didn't seal recently, returning whether it did.
+func (c *Congress) tryPunishValidator(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB) (bool, error) {
 	number := header.Number.Uint64()
 	snap, err := c.snapshot(chain, number-1, header.ParentHash, nil)
 	if err != nil {
-		return err
+		return false, err
 	}
 	validators := snap.validators()
 	outTurnValidator := validators[number%uint64(len(validators))]
@@ -953,11 +950,12 @@ func (c *Congress) tryPunishValidator(chain consensus.ChainHeaderReader, header
 	}
 	if !signedRecently {
 		if err := c.punishValidator(outTurnValidator, chain, header, state); err != nil {
-			return err
+			return false, err
 		}
+		return true, nil
 	}
 
-	return nil
+	return false, nil
 }
 
 func (c *Congress) doSomethingAtEpoch(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB) ([]common.Address, error) {
@@ -1245,12 +1243,20 @@ func (c *Congress) Seal(chain consensus.ChainHeaderReader, block *types.Block, r
 
 		log.Trace("Out-of-turn signing requested", "wiggle", common.PrettyDuration(wiggle))
 	}
-	// Sign all the things!
-	sighash, err := signFn(accounts.Account{Address: val}, accounts.MimetypeCongress, CongressRLP(header))
-	if err != nil {
-		return err
+	// Sign all the things! The ML-DSA seal goes first, as the secp256k1 one
+	// covers it during the PQT transition.
+	if chain.Config().IsPQTFork(header.Number) {
+		if err := c.sealPQ(snap, header); err != nil {
+			return err
+		}
+	}
+	if !chain.Config().IsPQTEnforced(header.Number) {
+		sighash, err := signFn(accounts.Account{Address: val}, accounts.MimetypeCongress, CongressRLP(header))
+		if err != nil {
+			return err
+		}
+		copy(header.Extra[len(header.Extra)-extraSeal:], sighash)
 	}
-	copy(header.Extra[len(header.Extra)-extraSeal:], sighash)
 	// Wait until sealing is terminated or delay timeout.
 	log.Trace("Waiting for slot to sign and propagate", "delay", common.PrettyDuration(delay))
 	go func() {
@@ -1289,8 +1295,14 @@ func calcDifficulty(snap *Snapshot, validator common.Address) *big.Int {
 	return new(big.Int).Set(diffNoTurn)
 }
 
-// SealHash returns the hash of a block prior to it being sealed.
+// SealHash returns the hash of a block prior to it being sealed. After the PQT
+// fork this excludes the ML-DSA seal as well.
 func (c *Congress) SealHash(header *types.Header) common.Hash {
+	if c.chainConfig.IsPQTFork(header.Number) {
+		if sealLen, err := sealLength(c.chainConfig, header); err == nil {
+			return pqSealHash(header, sealLen)
+		}
+	}
 	return SealHash(header)
 }
 
@@ -1332,6 +1344,12 @@ func CongressRLP(header *types.Header) []byte {
 }
 
 func encodeSigHeader(w io.Writer, header *types.Header) {
+	encodeSigHeaderWithExtra(w, header, header.Extra[:len(header.Extra)-crypto.SignatureLength]) // Yes, this will panic if extra is too short
+}
+
+// encodeSigHeaderWithExtra encodes the header for signing with the given
+// extra-data, which must be stripped of the seals.
+func encodeSigHeaderWithExtra(w io.Writer, header *types.Header, extra []byte) {
 	err := rlp.Encode(w, []interface{}{
 		header.ParentHash,
 		header.UncleHash,
@@ -1345,7 +1363,7 @@ func encodeSigHeader(w io.Writer, header *types.Header) {
 		header.GasLimit,
 		header.GasUsed,
 		header.Time,
-		header.Extra[:len(header.Extra)-crypto.SignatureLength], // Yes, this will panic if extra is too short
+		extra,
 		header.MixDigest,
 		header.Nonce,
 	})
@@ -1366,10 +1384,6 @@ func (c *Congress) PreHandle(chain consensus.ChainHeaderReader, header *types.He
 
 // IsSysTransaction checks whether a specific transaction is a system transaction.
 func (c *Congress) IsSysTransaction(sender common.Address, tx *types.Transaction, header *types.Header) (bool, error) {
-	// Treat typed x402 envelope as a system transaction to execute in consensus.
-	if tx.Type() == types.X402TxType {
-		return true, nil
-	}
 	if tx.To() == nil {
 		return false, nil
 	}
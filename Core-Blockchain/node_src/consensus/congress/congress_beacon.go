@@ -0,0 +1,279 @@
+// Copyright 2024 The Splendor Authors
+// This file implements the random beacon of Congress headers
+// Every validator commits to a secret derived from an ML-DSA signature when it
+// seals a block, and reveals it the next time it seals. The revealed secrets
+// are mixed into the beacon, which every beacon block records and stores for
+// contracts to read through the random beacon precompile.
+
+package congress
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/pqconsensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// After the random beacon fork the beacon data is stored in the extra-data
+// between the validator list and the finality attestation:
+//
+//	[commitment(32)] [reveal(32)] [beacon(32), in beacon blocks only]
+//
+// A zero reveal means the validator doesn't reveal a secret in the header.
+const beaconDataLength = 2 * common.HashLength
+
+// beaconSecretPrefix is the database key prefix of the secrets the local
+// validator committed to, keyed by commitment. The secrets are stored along
+// with the number of the block committing to them:
+//
+//	[secret(32)] [number(8)]
+var beaconSecretPrefix = []byte("congress-beacon-secret-")
+
+var (
+	// errMissingBeacon is returned if a header after the random beacon fork
+	// doesn't carry the beacon data.
+	errMissingBeacon = errors.New("extra-data random beacon data missing")
+
+	// errInvalidBeaconCommit is returned if a header doesn't commit to a secret.
+	errInvalidBeaconCommit = errors.New("missing random beacon commitment")
+
+	// errInvalidBeaconReveal is returned if the secret revealed by a header
+	// doesn't match the validator's commitment.
+	errInvalidBeaconReveal = errors.New("random beacon reveal doesn't match commitment")
+
+	// errInvalidRandomBeacon is returned if a beacon block doesn't record the
+	// beacon derived from the revealed secrets.
+	errInvalidRandomBeacon = errors.New("invalid random beacon")
+)
+
+// beaconData is the decoded beacon data of a header.
+type beaconData struct {
+	commitment common.Hash
+	reveal     common.Hash
+	beacon     *common.Hash // Only set in beacon blocks
+}
+
+// beaconSize returns the number of extra-data bytes taken by the beacon data
+// of a header.
+func beaconSize(config *params.ChainConfig, header *types.Header) int {
+	if !config.IsRandomBeacon(header.Number) {
+		return 0
+	}
+	if pqconsensus.IsRandomBeaconBlock(header.Number.Uint64()) {
+		return beaconDataLength + common.HashLength
+	}
+	return beaconDataLength
+}
+
+// encode returns the extra-data encoding of the beacon data.
+func (d *beaconData) encode() []byte {
+	enc := make([]byte, 0, beaconDataLength+common.HashLength)
+	enc = append(enc, d.commitment[:]...)
+	enc = append(enc, d.reveal[:]...)
+	if d.beacon != nil {
+		enc = append(enc, d.beacon[:]...)
+	}
+	return enc
+}
+
+// decodeBeacon parses the beacon data in the extra-data of a header, in front
+// of the given number of trailing bytes.
+func decodeBeacon(config *params.ChainConfig, header *types.Header, trailer int) (*beaconData, error) {
+	size := beaconSize(config, header)
+	end := len(header.Extra) - trailer
+	if end-size < extraVanity {
+		return nil, errMissingBeacon
+	}
+	enc := header.Extra[end-size : end]
+	data := &beaconData{
+		commitment: common.BytesToHash(enc[:common.HashLength]),
+		reveal:     common.BytesToHash(enc[common.HashLength:beaconDataLength]),
+	}
+	if size > beaconDataLength {
+		beacon := common.BytesToHash(enc[beaconDataLength:])
+		data.beacon = &beacon
+	}
+	return data, nil
+}
+
+// beaconSecretKey returns the database key of the secret behind a commitment.
+func beaconSecretKey(commitment common.Hash) []byte {
+	return append(append([]byte{}, beaconSecretPrefix...), commitment[:]...)
+}
+
+// mix returns the beacon resulting from mixing the revealed secret, if any,
+// into the given one.
+func (d *beaconData) mix(beacon common.Hash) common.Hash {
+	if d.reveal == (common.Hash{}) {
+		return beacon
+	}
+	return pqconsensus.MixRandomBeacon(beacon, d.reveal)
+}
+
+// apply updates the snapshot with the beacon data of a header sealed by the
+// given validator, which must have been verified.
+func (d *beaconData) apply(snap *Snapshot, validator common.Address) {
+	snap.BeaconMix = d.mix(snap.BeaconMix)
+	snap.BeaconCommits[validator] = d.commitment
+}
+
+// verifyBeacon checks the beacon data of a header against the commitments and
+// beacon of its parent snapshot. A validator may skip revealing its secret,
+// e.g. if it lost it, which gets it punished, but can't reveal anything else.
+func (c *Congress) verifyBeacon(chain consensus.ChainHeaderReader, snap *Snapshot, header *types.Header, validator common.Address) error {
+	trailer, err := beaconTrailer(chain.Config(), header)
+	if err != nil {
+		return err
+	}
+	data, err := decodeBeacon(chain.Config(), header, trailer)
+	if err != nil {
+		return err
+	}
+	if data.commitment == (common.Hash{}) {
+		return errInvalidBeaconCommit
+	}
+	if data.reveal != (common.Hash{}) {
+		if commitment, ok := snap.BeaconCommits[validator]; !ok || pqconsensus.BeaconCommitment(data.reveal) != commitment {
+			return errInvalidBeaconReveal
+		}
+	}
+	if data.beacon != nil && *data.beacon != data.mix(snap.BeaconMix) {
+		return errInvalidRandomBeacon
+	}
+	return nil
+}
+
+// prepareBeacon appends the beacon data of a new header to its extra-data:
+// the reveal of the secret committed to in the validator's previous block, a
+// commitment to a new secret and, in beacon blocks, the resulting beacon.
+func (c *Congress) prepareBeacon(snap *Snapshot, header *types.Header) error {
+	c.lock.RLock()
+	validator := c.validator
+	c.lock.RUnlock()
+
+	signer, _, err := c.pqSigner(snap, header.Number)
+	if err != nil {
+		return err
+	}
+	sig, err := signer.SignFn(pqconsensus.BeaconSigningData(c.chainConfig.ChainID, header.Number.Uint64()))
+	if err != nil {
+		return err
+	}
+	secret := pqconsensus.BeaconSecret(sig)
+	data := &beaconData{commitment: pqconsensus.BeaconCommitment(secret)}
+
+	enc := make([]byte, common.HashLength+8)
+	copy(enc, secret[:])
+	binary.BigEndian.PutUint64(enc[common.HashLength:], header.Number.Uint64())
+	if err := c.db.Put(beaconSecretKey(data.commitment), enc); err != nil {
+		return err
+	}
+	if commitment, ok := snap.BeaconCommits[validator]; ok {
+		if blob, err := c.db.Get(beaconSecretKey(commitment)); err == nil && len(blob) >= common.HashLength {
+			data.reveal = common.BytesToHash(blob[:common.HashLength])
+			c.pruneBeaconSecrets(beaconSecretNumber(blob))
+		} else {
+			log.Warn("Random beacon secret lost, skipping reveal", "commitment", commitment)
+		}
+	}
+	if pqconsensus.IsRandomBeaconBlock(header.Number.Uint64()) {
+		beacon := data.mix(snap.BeaconMix)
+		data.beacon = &beacon
+	}
+	header.Extra = append(header.Extra, data.encode()...)
+	return nil
+}
+
+// beaconSecretNumber returns the number of the block committing to a stored
+// secret, zero for secrets stored without it.
+func beaconSecretNumber(blob []byte) uint64 {
+	if len(blob) < common.HashLength+8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(blob[common.HashLength:])
+}
+
+// pruneBeaconSecrets deletes the stored secrets committed to before the given
+// block. They are either revealed already or were never included, as the
+// secret revealed next was committed to in that block.
+func (c *Congress) pruneBeaconSecrets(number uint64) {
+	it := c.db.NewIterator(beaconSecretPrefix, nil)
+	defer it.Release()
+
+	batch := c.db.NewBatch()
+	for it.Next() {
+		if beaconSecretNumber(it.Value()) < number {
+			batch.Delete(common.CopyBytes(it.Key()))
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Warn("Failed to prune random beacon secrets", "err", err)
+	}
+}
+
+// punishWithheldReveal punishes the validator of a header that doesn't reveal
+// the secret behind its previous commitment, as withholding it lets the last
+// validators before a beacon block bias the beacon. Like a missed block, it
+// counts towards the removal of the validator. The punish contract takes one
+// punishment per block, so it's skipped if the block punished one already.
+func (c *Congress) punishWithheldReveal(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB) error {
+	snap, err := c.snapshot(chain, header.Number.Uint64()-1, header.ParentHash, nil)
+	if err != nil {
+		return err
+	}
+	if _, ok := snap.BeaconCommits[header.Coinbase]; !ok {
+		return nil
+	}
+	trailer, err := beaconTrailer(chain.Config(), header)
+	if err != nil {
+		return err
+	}
+	data, err := decodeBeacon(chain.Config(), header, trailer)
+	if err != nil {
+		return err
+	}
+	if data.reveal != (common.Hash{}) {
+		return nil
+	}
+	log.Debug("Punishing withheld random beacon reveal", "number", header.Number, "validator", header.Coinbase)
+	return c.punishValidator(header.Coinbase, chain, header, state)
+}
+
+// storeRandomBeacon stores the beacon recorded by a beacon block in the state,
+// where the random beacon precompile serves it to contracts.
+func (c *Congress) storeRandomBeacon(chain consensus.ChainHeaderReader, header *types.Header, state vm.StateDB) error {
+	if !chain.Config().IsRandomBeacon(header.Number) || !pqconsensus.IsRandomBeaconBlock(header.Number.Uint64()) {
+		return nil
+	}
+	trailer, err := beaconTrailer(chain.Config(), header)
+	if err != nil {
+		return err
+	}
+	data, err := decodeBeacon(chain.Config(), header, trailer)
+	if err != nil {
+		return err
+	}
+	vm.StoreRandomBeacon(state, header.Number.Uint64(), *data.beacon)
+	return nil
+}
+
+// beaconTrailer returns the number of extra-data bytes following the beacon
+// data of a header: the seals and the finality attestation.
+func beaconTrailer(config *params.ChainConfig, header *types.Header) (int, error) {
+	trailer, err := sealLength(config, header)
+	if err != nil || !config.IsFastFinality(header.Number) {
+		return trailer, err
+	}
+	_, size, err := decodeAttestation(header, trailer)
+	if err != nil {
+		return 0, err
+	}
+	return trailer + size, nil
+}
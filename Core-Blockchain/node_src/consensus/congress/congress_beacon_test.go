@@ -0,0 +1,161 @@
+// Copyright 2024 The Splendor Authors
+// Tests of the random beacon of Congress headers
+
+package congress
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/pqconsensus"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// sealBeaconTestHeader prepares and seals a header carrying beacon data the way
+// Prepare and Seal do.
+func sealBeaconTestHeader(t *testing.T, c *Congress, snap *Snapshot, number int64) *types.Header {
+	header := &types.Header{
+		Number:     big.NewInt(number),
+		Coinbase:   c.validator,
+		Difficulty: new(big.Int).Set(diffInTurn),
+		Extra:      make([]byte, extraVanity),
+	}
+	if err := c.prepareBeacon(snap, header); err != nil {
+		t.Fatalf("failed to prepare beacon of block %d: %v", number, err)
+	}
+	if err := c.preparePQSeal(snap, header); err != nil {
+		t.Fatalf("failed to prepare block %d: %v", number, err)
+	}
+	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
+	if err := c.sealPQ(snap, header); err != nil {
+		t.Fatalf("failed to seal block %d: %v", number, err)
+	}
+	return header
+}
+
+// decodeBeaconTestHeader returns the beacon data of a sealed header.
+func decodeBeaconTestHeader(t *testing.T, c *Congress, header *types.Header) *beaconData {
+	trailer, err := beaconTrailer(c.chainConfig, header)
+	if err != nil {
+		t.Fatalf("failed to measure trailer: %v", err)
+	}
+	data, err := decodeBeacon(c.chainConfig, header, trailer)
+	if err != nil {
+		t.Fatalf("failed to decode beacon data: %v", err)
+	}
+	return data
+}
+
+func TestRandomBeacon(t *testing.T) {
+	c, chain, snap := newPQTestEngine(t)
+	c.chainConfig.PostQuantum.RandomBeaconBlock = big.NewInt(1)
+	c.db = rawdb.NewMemoryDatabase()
+	snap.PQKeys[c.validator] = c.pqSigners[0].Key
+
+	// The first block only commits to a secret
+	header := sealBeaconTestHeader(t, c, snap, 998)
+	if err := c.verifyBeacon(chain, snap, header, c.validator); err != nil {
+		t.Fatalf("failed to verify first commitment: %v", err)
+	}
+	data := decodeBeaconTestHeader(t, c, header)
+	if data.reveal != (common.Hash{}) || data.beacon != nil {
+		t.Fatalf("unexpected reveal or beacon: %x, %v", data.reveal, data.beacon)
+	}
+	data.apply(snap, c.validator)
+
+	// The next block reveals the secret behind the previous commitment
+	header = sealBeaconTestHeader(t, c, snap, 999)
+	if err := c.verifyBeacon(chain, snap, header, c.validator); err != nil {
+		t.Fatalf("failed to verify reveal: %v", err)
+	}
+	next := decodeBeaconTestHeader(t, c, header)
+	if pqconsensus.BeaconCommitment(next.reveal) != data.commitment {
+		t.Fatalf("reveal doesn't open commitment")
+	}
+	next.apply(snap, c.validator)
+	if want := pqconsensus.MixRandomBeacon(common.Hash{}, next.reveal); snap.BeaconMix != want {
+		t.Fatalf("beacon mix mismatch: have %x, want %x", snap.BeaconMix, want)
+	}
+	// Beacon blocks record the beacon mixed with their own reveal
+	header = sealBeaconTestHeader(t, c, snap, 1000)
+	if err := c.verifyBeacon(chain, snap, header, c.validator); err != nil {
+		t.Fatalf("failed to verify beacon: %v", err)
+	}
+	data = decodeBeaconTestHeader(t, c, header)
+	if data.beacon == nil || *data.beacon != data.mix(snap.BeaconMix) {
+		t.Fatalf("beacon mismatch: have %v, want %x", data.beacon, data.mix(snap.BeaconMix))
+	}
+	// Secrets committed to before the one revealed are pruned
+	secrets := 0
+	it := c.db.NewIterator(beaconSecretPrefix, nil)
+	for it.Next() {
+		if number := beaconSecretNumber(it.Value()); number != 999 && number != 1000 {
+			t.Errorf("stale secret of block %d kept", number)
+		}
+		secrets++
+	}
+	it.Release()
+	if secrets != 2 {
+		t.Errorf("stored secrets mismatch: have %d, want 2", secrets)
+	}
+	// The beacon is stored for the precompile to read
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err := c.storeRandomBeacon(chain, header, statedb); err != nil {
+		t.Fatalf("failed to store beacon: %v", err)
+	}
+	if stored := statedb.GetState(vm.RandomBeaconAddress, common.BigToHash(header.Number)); stored != *data.beacon {
+		t.Errorf("stored beacon mismatch: have %x, want %x", stored, *data.beacon)
+	}
+	if statedb.GetNonce(vm.RandomBeaconAddress) == 0 {
+		t.Errorf("beacon account left empty")
+	}
+}
+
+func TestRandomBeaconTampering(t *testing.T) {
+	c, chain, snap := newPQTestEngine(t)
+	c.chainConfig.PostQuantum.RandomBeaconBlock = big.NewInt(1)
+	c.db = rawdb.NewMemoryDatabase()
+	snap.PQKeys[c.validator] = c.pqSigners[0].Key
+
+	decodeBeaconTestHeader(t, c, sealBeaconTestHeader(t, c, snap, 999)).apply(snap, c.validator)
+	header := sealBeaconTestHeader(t, c, snap, 1000)
+
+	// Locate the beacon data in the extra-data of the header
+	trailer, err := beaconTrailer(c.chainConfig, header)
+	if err != nil {
+		t.Fatalf("failed to measure trailer: %v", err)
+	}
+	start := len(header.Extra) - trailer - beaconSize(c.chainConfig, header)
+
+	tests := []struct {
+		offset int
+		err    error
+	}{
+		{0, nil}, // Any commitment is accepted
+		{common.HashLength, errInvalidBeaconReveal},
+		{beaconDataLength, errInvalidRandomBeacon},
+	}
+	for i, tt := range tests {
+		tampered := types.CopyHeader(header)
+		tampered.Extra[start+tt.offset] ^= 0xff
+		if err := c.verifyBeacon(chain, snap, tampered, c.validator); err != tt.err {
+			t.Errorf("test %d: have %v, want %v", i, err, tt.err)
+		}
+	}
+	// The commitment can't be left out though
+	tampered := types.CopyHeader(header)
+	copy(tampered.Extra[start:], make([]byte, common.HashLength))
+	if err := c.verifyBeacon(chain, snap, tampered, c.validator); err != errInvalidBeaconCommit {
+		t.Errorf("missing commitment: have %v, want %v", err, errInvalidBeaconCommit)
+	}
+	// Neither can the beacon data
+	tampered = types.CopyHeader(header)
+	tampered.Extra = append(tampered.Extra[:extraVanity:extraVanity], tampered.Extra[len(tampered.Extra)-trailer:]...)
+	if err := c.verifyBeacon(chain, snap, tampered, c.validator); err != errMissingBeacon {
+		t.Errorf("missing beacon data: have %v, want %v", err, errMissingBeacon)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2024 The Splendor Authors
+// This file implements the consensus state carried by Congress epoch blocks
+// Besides the validator set, the snapshot tracks the validators' ML-DSA keys,
+// their random beacon commitments, the beacon mix and the finalized block.
+// Nodes starting from a trusted checkpoint don't process the headers before
+// it, so epoch blocks carry that state for them to restore.
+
+package congress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// After the PQT or fast finality fork, epoch blocks carry the snapshot state of
+// their parent in the extra-data between the validator list and the registered
+// key updates:
+//
+//	[checkpoint RLP] [length(4)]
+//
+// Other headers don't carry it, not even its length.
+const checkpointLenSize = 4
+
+// errInvalidCheckpoint is returned if the snapshot state carried by an epoch
+// block is malformed or doesn't match the snapshot of its parent.
+var errInvalidCheckpoint = errors.New("invalid checkpoint snapshot state")
+
+// checkpointState is the snapshot state carried by an epoch block, with the
+// entries of the maps sorted by validator.
+type checkpointState struct {
+	PQKeys    []*pqKeyUpdate
+	Commits   []*beaconCommit
+	Mix       common.Hash
+	Finalized *FinalizedBlock `rlp:"nil"`
+}
+
+// beaconCommit is a validator's random beacon commitment.
+type beaconCommit struct {
+	Validator  common.Address
+	Commitment common.Hash
+}
+
+// isCheckpointBlock returns whether the header at the given number carries the
+// snapshot state.
+func isCheckpointBlock(config *params.ChainConfig, number *big.Int) bool {
+	epoch := uint64(epochLength)
+	if config.Congress != nil && config.Congress.Epoch != 0 {
+		epoch = config.Congress.Epoch
+	}
+	if number.Sign() == 0 || number.Uint64()%epoch != 0 {
+		return false
+	}
+	return config.IsPQTFork(number) || config.IsFastFinality(number)
+}
+
+// encodeCheckpoint returns the extra-data encoding of the state of a snapshot.
+func encodeCheckpoint(snap *Snapshot) []byte {
+	state := &checkpointState{Mix: snap.BeaconMix, Finalized: snap.Finalized}
+	for validator, key := range snap.PQKeys {
+		state.PQKeys = append(state.PQKeys, &pqKeyUpdate{Validator: validator, Algorithm: key.Algorithm, PublicKey: key.PublicKey})
+	}
+	sort.Slice(state.PQKeys, func(i, j int) bool {
+		return bytes.Compare(state.PQKeys[i].Validator[:], state.PQKeys[j].Validator[:]) < 0
+	})
+	for validator, commitment := range snap.BeaconCommits {
+		state.Commits = append(state.Commits, &beaconCommit{Validator: validator, Commitment: commitment})
+	}
+	sort.Slice(state.Commits, func(i, j int) bool {
+		return bytes.Compare(state.Commits[i].Validator[:], state.Commits[j].Validator[:]) < 0
+	})
+	enc, _ := rlp.EncodeToBytes(state)
+
+	size := make([]byte, checkpointLenSize)
+	binary.BigEndian.PutUint32(size, uint32(len(enc)))
+	return append(enc, size...)
+}
+
+// decodeCheckpoint parses the snapshot state in the extra-data of an epoch
+// block, in front of the given number of trailing bytes, returning it along
+// with the number of bytes it takes.
+func decodeCheckpoint(header *types.Header, trailer int) (*checkpointState, int, error) {
+	end := len(header.Extra) - trailer
+	if end < extraVanity+checkpointLenSize {
+		return nil, 0, errInvalidCheckpoint
+	}
+	size := int(binary.BigEndian.Uint32(header.Extra[end-checkpointLenSize : end]))
+	start := end - checkpointLenSize - size
+	if size <= 0 || start < extraVanity {
+		return nil, 0, errInvalidCheckpoint
+	}
+	state := new(checkpointState)
+	if err := rlp.DecodeBytes(header.Extra[start:end-checkpointLenSize], state); err != nil {
+		return nil, 0, errInvalidCheckpoint
+	}
+	return state, checkpointLenSize + size, nil
+}
+
+// checkpointTrailer returns the number of extra-data bytes following the
+// snapshot state of an epoch block: the seals, the finality attestation, the
+// random beacon data and the registered key updates.
+func checkpointTrailer(config *params.ChainConfig, header *types.Header) (int, error) {
+	trailer, err := keyUpdatesTrailer(config, header)
+	if err != nil {
+		return 0, err
+	}
+	if config.IsPQKeyRegistry(header.Number) {
+		_, size, err := decodeKeyUpdates(header, trailer)
+		if err != nil {
+			return 0, err
+		}
+		trailer += size
+	}
+	return trailer, nil
+}
+
+// verifyCheckpoint checks that an epoch block carries the state of the snapshot
+// of its parent.
+func verifyCheckpoint(config *params.ChainConfig, snap *Snapshot, header *types.Header) error {
+	trailer, err := checkpointTrailer(config, header)
+	if err != nil {
+		return err
+	}
+	_, size, err := decodeCheckpoint(header, trailer)
+	if err != nil {
+		return err
+	}
+	end := len(header.Extra) - trailer
+	if !bytes.Equal(header.Extra[end-size:end], encodeCheckpoint(snap)) {
+		return errInvalidCheckpoint
+	}
+	return nil
+}
+
+// restore sets the state carried by a trusted epoch block in a snapshot created
+// for it, and applies the header on top of it like apply does.
+func (s *Snapshot) restore(config *params.ChainConfig, header *types.Header) error {
+	trailer, err := checkpointTrailer(config, header)
+	if err != nil {
+		return err
+	}
+	state, _, err := decodeCheckpoint(header, trailer)
+	if err != nil {
+		return err
+	}
+	for _, key := range state.PQKeys {
+		s.PQKeys[key.Validator] = key.key()
+	}
+	for _, commit := range state.Commits {
+		s.BeaconCommits[commit.Validator] = commit.Commitment
+	}
+	s.BeaconMix, s.Finalized = state.Mix, state.Finalized
+
+	validator, err := sealer(config, header, s.sigcache)
+	if err != nil {
+		return err
+	}
+	_, err = s.applyExtra(config, header, validator)
+	return err
+}
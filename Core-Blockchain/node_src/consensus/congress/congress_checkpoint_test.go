@@ -0,0 +1,105 @@
+// Copyright 2024 The Splendor Authors
+// Tests of the snapshot state carried by Congress epoch blocks
+
+package congress
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// checkpointTestChain is a header reader serving the given headers only, like
+// a node that starts from a trusted checkpoint without the headers before it.
+type checkpointTestChain struct {
+	*pqTestChain
+	headers map[uint64]*types.Header
+}
+
+func (c *checkpointTestChain) GetHeaderByNumber(number uint64) *types.Header {
+	return c.headers[number]
+}
+
+func (c *checkpointTestChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := c.headers[number]; header != nil && header.Hash() == hash {
+		return header
+	}
+	return nil
+}
+
+// Tests that a node starting from a trusted epoch block restores the snapshot
+// state it carries, as if it had processed the headers before it.
+func TestCheckpointRestore(t *testing.T) {
+	config := *params.AllCliqueProtocolChanges
+	config.Clique = nil
+	config.Congress = &params.CongressConfig{Period: 1, Epoch: 10}
+	config.PostQuantum = &params.PostQuantumConfig{PQTBlock: big.NewInt(0), TransitionBlocks: 1, RandomBeaconBlock: big.NewInt(0)}
+
+	c, chain, _ := newPQTestEngineWithConfig(t, &config)
+	c.db = rawdb.NewMemoryDatabase()
+
+	// Build the parent snapshot with a key, a beacon commitment and a mix
+	snap := newSnapshot(c.config, c.signatures, 8, common.Hash{}, []common.Address{c.validator})
+	snap.PQKeys[c.validator] = c.pqSigners[0].Key
+	snap.PQKeys[common.Address{1}] = &PQKey{Algorithm: params.MLDSA44_ID, PublicKey: make([]byte, len(c.pqSigners[0].Key.PublicKey))}
+	snap.BeaconMix = common.Hash{0xaa}
+
+	parent, err := snap.apply([]*types.Header{sealBeaconTestHeader(t, c, snap, 9)}, chain, nil)
+	if err != nil {
+		t.Fatalf("failed to apply parent: %v", err)
+	}
+	parent.Finalized = &FinalizedBlock{Number: 7, Hash: common.Hash{0x07}}
+
+	// Seal the epoch block carrying the parent state, the way Prepare does
+	header := &types.Header{
+		Number:     big.NewInt(10),
+		Coinbase:   c.validator,
+		Difficulty: new(big.Int).Set(diffInTurn),
+		Extra:      append(append(make([]byte, extraVanity), c.validator.Bytes()...), encodeCheckpoint(parent)...),
+	}
+	if err := c.prepareBeacon(parent, header); err != nil {
+		t.Fatalf("failed to prepare beacon: %v", err)
+	}
+	if err := c.preparePQSeal(parent, header); err != nil {
+		t.Fatalf("failed to prepare seal: %v", err)
+	}
+	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
+	if err := c.sealPQ(parent, header); err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+	if err := verifyCheckpoint(&config, parent, header); err != nil {
+		t.Fatalf("failed to verify checkpoint: %v", err)
+	}
+	if trailer, err := extraTrailer(&config, header); err != nil || len(header.Extra)-trailer != extraVanity+common.AddressLength {
+		t.Fatalf("validator list mismatch: trailer %d (%v)", trailer, err)
+	}
+	want, err := parent.apply([]*types.Header{header}, chain, nil)
+	if err != nil {
+		t.Fatalf("failed to apply epoch block: %v", err)
+	}
+	// A node without the parent header restores the same state
+	restart := &checkpointTestChain{pqTestChain: chain, headers: map[uint64]*types.Header{10: header}}
+	have, err := c.snapshot(restart, 10, header.Hash(), nil)
+	if err != nil {
+		t.Fatalf("failed to restore snapshot: %v", err)
+	}
+	if len(have.BeaconCommits) == 0 || have.BeaconMix == (common.Hash{}) || have.Finalized == nil || len(have.PQKeys) != 2 {
+		t.Fatalf("snapshot state not restored: %+v", have)
+	}
+	if !bytes.Equal(encodeCheckpoint(have), encodeCheckpoint(want)) {
+		t.Errorf("restored state mismatch:\nhave %+v\nwant %+v", have, want)
+	}
+	if _, ok := have.Validators[c.validator]; !ok || len(have.Validators) != 1 {
+		t.Errorf("validators mismatch: %v", have.Validators)
+	}
+	// Epoch blocks must carry exactly the parent state
+	parent.BeaconMix = common.Hash{0xbb}
+	if err := verifyCheckpoint(&config, parent, header); err != errInvalidCheckpoint {
+		t.Errorf("stale checkpoint: have %v, want %v", err, errInvalidCheckpoint)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 The Splendor Authors
+// This file implements the attribution of block fees to transaction recipients
+
+package congress
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	// errFeeReceiptMismatch is returned if the receipts of a block don't match
+	// its transactions.
+	errFeeReceiptMismatch = errors.New("receipts don't match transactions")
+
+	// errMissingReceipts is returned by the fee share API if the receipts of
+	// a block are not available.
+	errMissingReceipts = errors.New("block receipts not available")
+)
+
+// FeeShare is the part of the fees collected by a block attributed to the
+// recipient of one of its transactions. The fee is what the transaction paid
+// into the fee recorder: the gas it used times its effective tip. The share
+// equals the fee, unless the fee recorder collected less than the fees of the
+// block, in which case every share is scaled down proportionally.
+type FeeShare struct {
+	TxHash       common.Hash    `json:"txHash"`
+	Recipient    common.Address `json:"recipient"`
+	Creation     bool           `json:"creation"` // Recipient is the contract created by the transaction
+	GasUsed      hexutil.Uint64 `json:"gasUsed"`
+	EffectiveTip *hexutil.Big   `json:"effectiveTip"`
+	Fee          *hexutil.Big   `json:"fee"`
+	Share        *hexutil.Big   `json:"share"`
+}
+
+// BlockFeeShares explains the fee shares of a block passed to the validators
+// contract.
+type BlockFeeShares struct {
+	Number     hexutil.Uint64                  `json:"number"`
+	Hash       common.Hash                     `json:"hash"`
+	BaseFee    *hexutil.Big                    `json:"baseFee,omitempty"`
+	Total      *hexutil.Big                    `json:"total"`
+	Shares     []*FeeShare                     `json:"shares"`
+	Recipients map[common.Address]*hexutil.Big `json:"recipients"`
+}
+
+// feeShares attributes the fees of the transactions of a block to their
+// recipients, or the contracts they created. The shares are scaled down to
+// the collected fees if those don't cover the fees attributed, nil collected
+// fees leave them unscaled. The receipts have to match the transactions.
+func feeShares(txs []*types.Transaction, receipts []*types.Receipt, baseFee *big.Int, collected *big.Int) ([]*FeeShare, *big.Int, error) {
+	if len(receipts) != len(txs) {
+		return nil, nil, fmt.Errorf("%w: %d transactions, %d receipts", errFeeReceiptMismatch, len(txs), len(receipts))
+	}
+	var (
+		shares = make([]*FeeShare, len(txs))
+		total  = new(big.Int)
+	)
+	for i, tx := range txs {
+		receipt := receipts[i]
+		if receipt.TxHash != tx.Hash() {
+			return nil, nil, fmt.Errorf("%w: tx %d hash %x, receipt hash %x", errFeeReceiptMismatch, i, tx.Hash(), receipt.TxHash)
+		}
+		tip, err := tx.EffectiveGasTip(baseFee)
+		if err != nil {
+			return nil, nil, fmt.Errorf("tx %d: %w", i, err)
+		}
+		share := &FeeShare{
+			TxHash:       tx.Hash(),
+			GasUsed:      hexutil.Uint64(receipt.GasUsed),
+			EffectiveTip: (*hexutil.Big)(tip),
+		}
+		if to := tx.To(); to != nil {
+			share.Recipient = *to
+		} else {
+			share.Recipient, share.Creation = receipt.ContractAddress, true
+		}
+		fee := new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), tip)
+		share.Fee, share.Share = (*hexutil.Big)(fee), (*hexutil.Big)(fee)
+		total.Add(total, fee)
+
+		shares[i] = share
+	}
+	// Scale the shares down if the fees collected don't cover them
+	if collected != nil && total.Cmp(collected) > 0 {
+		for _, share := range shares {
+			scaled := new(big.Int).Mul(share.Fee.ToInt(), collected)
+			share.Share = (*hexutil.Big)(scaled.Quo(scaled, total))
+		}
+	}
+	return shares, total, nil
+}
+
+// feeShareArgs converts fee shares into the arguments of distributeBlockReward.
+// The contract takes the shares as uint64, larger ones are capped.
+func feeShareArgs(shares []*FeeShare) ([]common.Address, []uint64) {
+	var (
+		addrs   = make([]common.Address, len(shares))
+		amounts = make([]uint64, len(shares))
+	)
+	for i, share := range shares {
+		addrs[i] = share.Recipient
+		if amount := share.Share.ToInt(); amount.IsUint64() {
+			amounts[i] = amount.Uint64()
+		} else {
+			log.Warn("Fee share exceeds contract range, capping", "tx", share.TxHash, "share", amount)
+			amounts[i] = math.MaxUint64
+		}
+	}
+	return addrs, amounts
+}
+
+// distributeBlockFees sends the fees collected by the block to the validators
+// contract, along with their attribution to the transaction recipients. The
+// attribution only feeds the reward event of the contract, so failing to
+// compute it doesn't stop the fees from being distributed.
+func (c *Congress) distributeBlockFees(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, receipts []*types.Receipt) {
+	if len(txs) == 0 {
+		return
+	}
+	var (
+		addrs   []common.Address
+		amounts []uint64
+	)
+	shares, total, err := feeShares(txs, receipts, header.BaseFee, state.GetBalance(consensus.FeeRecoder))
+	if err != nil {
+		log.Error("Failed to attribute block fees", "number", header.Number, "err", err)
+	} else {
+		addrs, amounts = feeShareArgs(shares)
+		log.Trace("Attributed block fees", "number", header.Number, "txs", len(shares), "total", total)
+	}
+	if err := c.trySendBlockReward(chain, header, state, addrs, amounts); err != nil {
+		log.Warn("Failed to distribute block reward", "number", header.Number, "err", err)
+	}
+}
+
+// blockFeeShares explains the fee shares of a sealed block, skipping the system
+// transactions and their receipts like the block processing does.
+func (c *Congress) blockFeeShares(block *types.Block, receipts types.Receipts, signer types.Signer) (*BlockFeeShares, error) {
+	header := block.Header()
+	if len(receipts) != len(block.Transactions()) {
+		return nil, fmt.Errorf("%w: %d transactions, %d receipts", errFeeReceiptMismatch, len(block.Transactions()), len(receipts))
+	}
+	var (
+		txs      = make([]*types.Transaction, 0, len(receipts))
+		commonRs = make([]*types.Receipt, 0, len(receipts))
+	)
+	for i, tx := range block.Transactions() {
+		sender, err := types.Sender(signer, tx)
+		if err != nil {
+			return nil, err
+		}
+		if sys, err := c.IsSysTransaction(sender, tx, header); err != nil {
+			return nil, err
+		} else if sys {
+			continue
+		}
+		txs = append(txs, tx)
+		commonRs = append(commonRs, receipts[i])
+	}
+	// The fee recorder collects the tips of every transaction on top of its
+	// balance, so the shares of sealed blocks are never scaled
+	shares, total, err := feeShares(txs, commonRs, header.BaseFee, nil)
+	if err != nil {
+		return nil, err
+	}
+	recipients := make(map[common.Address]*hexutil.Big)
+	for _, share := range shares {
+		sum, ok := recipients[share.Recipient]
+		if !ok {
+			sum = new(hexutil.Big)
+			recipients[share.Recipient] = sum
+		}
+		sum.ToInt().Add(sum.ToInt(), share.Share.ToInt())
+	}
+	return &BlockFeeShares{
+		Number:     hexutil.Uint64(header.Number.Uint64()),
+		Hash:       block.Hash(),
+		BaseFee:    (*hexutil.Big)(header.BaseFee),
+		Total:      (*hexutil.Big)(total),
+		Shares:     shares,
+		Recipients: recipients,
+	}, nil
+}
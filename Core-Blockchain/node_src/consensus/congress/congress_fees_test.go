@@ -0,0 +1,143 @@
+// Copyright 2024 The Splendor Authors
+// Tests of the attribution of block fees to transaction recipients
+
+package congress
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/congress/systemcontract"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// feeTestTxs returns a legacy transfer priced below a base fee of 10, a dynamic
+// fee call capping its tip, a contract creation and a transfer with a price
+// overflowing uint64 fees, along with their receipts.
+func feeTestTxs() ([]*types.Transaction, []*types.Receipt) {
+	var (
+		key, _ = crypto.GenerateKey()
+		signer = types.LatestSigner(params.AllEthashProtocolChanges)
+		to     = common.HexToAddress("0x0102")
+		huge   = new(big.Int).Lsh(big.NewInt(1), 70)
+	)
+	txs := []*types.Transaction{
+		types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 0, To: &to, Gas: 100000, GasPrice: big.NewInt(3)}),
+		types.MustSignNewTx(key, signer, &types.DynamicFeeTx{ChainID: params.AllEthashProtocolChanges.ChainID, Nonce: 1, To: &to, Gas: 100000, GasTipCap: big.NewInt(2), GasFeeCap: big.NewInt(100)}),
+		types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 2, Gas: 500000, GasPrice: big.NewInt(15)}),
+		types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 3, To: &to, Gas: 21000, GasPrice: huge}),
+	}
+	gasUsed := []uint64{21000, 30000, 200000, 21000}
+
+	receipts := make([]*types.Receipt, len(txs))
+	for i, tx := range txs {
+		receipts[i] = &types.Receipt{TxHash: tx.Hash(), GasUsed: gasUsed[i]}
+	}
+	receipts[2].ContractAddress = crypto.CreateAddress(crypto.PubkeyToAddress(key.PublicKey), 2)
+	return txs, receipts
+}
+
+func TestFeeShares(t *testing.T) {
+	baseFee := big.NewInt(10)
+	txs, receipts := feeTestTxs()
+
+	// Fees are the gas used times the effective tips, exactly
+	huge := new(big.Int).Lsh(big.NewInt(1), 70)
+	wantFees := []*big.Int{
+		big.NewInt(30000 * 2),  // tip cap below the fee cap over the base fee
+		big.NewInt(200000 * 5), // legacy tips are the gas price over the base fee
+		new(big.Int).Mul(big.NewInt(21000), new(big.Int).Sub(huge, baseFee)),
+	}
+	shares, total, err := feeShares(txs[1:], receipts[1:], baseFee, nil)
+	if err != nil {
+		t.Fatalf("failed to attribute fees: %v", err)
+	}
+	sum := new(big.Int)
+	for i, share := range shares {
+		want := wantFees[i]
+		if share.Fee.ToInt().Cmp(want) != 0 || share.Share.ToInt().Cmp(want) != 0 {
+			t.Errorf("share %d: have fee %v share %v, want %v", i, share.Fee, share.Share, want)
+		}
+		sum.Add(sum, want)
+	}
+	if total.Cmp(sum) != 0 {
+		t.Errorf("total mismatch: have %v, want %v", total, sum)
+	}
+	// Contract creations are attributed to the created contract
+	if !shares[1].Creation || shares[1].Recipient != receipts[2].ContractAddress {
+		t.Errorf("creation attributed to %x", shares[1].Recipient)
+	}
+	if shares[0].Creation || shares[0].Recipient != *txs[1].To() {
+		t.Errorf("call attributed to %x", shares[0].Recipient)
+	}
+	// Shares not fitting the contract arguments are capped
+	addrs, amounts := feeShareArgs(shares)
+	if len(addrs) != 3 || amounts[0] != 60000 || amounts[1] != 1000000 || amounts[2] != math.MaxUint64 {
+		t.Errorf("unexpected arguments: %v %v", addrs, amounts)
+	}
+
+	// Uncovered fees are scaled down exactly, flooring every share
+	shares, total, err = feeShares(txs[1:3], receipts[1:3], baseFee, big.NewInt(530000))
+	if err != nil {
+		t.Fatalf("failed to attribute fees: %v", err)
+	}
+	if total.Uint64() != 1060000 || shares[0].Share.ToInt().Uint64() != 30000 || shares[1].Share.ToInt().Uint64() != 500000 {
+		t.Errorf("unexpected scaled shares: %v %v of %v", shares[0].Share, shares[1].Share, total)
+	}
+	shares, _, _ = feeShares(txs[1:3], receipts[1:3], baseFee, big.NewInt(7))
+	if shares[0].Share.ToInt().Uint64() != 0 || shares[1].Share.ToInt().Uint64() != 6 {
+		t.Errorf("unexpected floored shares: %v %v", shares[0].Share, shares[1].Share)
+	}
+	// Shares covered by the collected fees are not scaled
+	shares, _, _ = feeShares(txs[1:3], receipts[1:3], baseFee, big.NewInt(2000000))
+	if shares[0].Share.ToInt().Uint64() != 60000 {
+		t.Errorf("covered share scaled: %v", shares[0].Share)
+	}
+
+	// Pre-London fees are the gas price times the gas used
+	shares, _, err = feeShares(txs[:1], receipts[:1], nil, nil)
+	if err != nil || shares[0].Fee.ToInt().Uint64() != 63000 {
+		t.Errorf("unexpected legacy fee: %v, %v", shares, err)
+	}
+	// Receipts have to match the transactions
+	if _, _, err := feeShares(txs, receipts[1:], baseFee, nil); !errors.Is(err, errFeeReceiptMismatch) {
+		t.Errorf("have error %v, want %v", err, errFeeReceiptMismatch)
+	}
+	if _, _, err := feeShares(txs[1:3], []*types.Receipt{receipts[2], receipts[1]}, baseFee, nil); !errors.Is(err, errFeeReceiptMismatch) {
+		t.Errorf("have error %v, want %v", err, errFeeReceiptMismatch)
+	}
+}
+
+func TestBlockFeeShares(t *testing.T) {
+	var (
+		config    = params.AllEthashProtocolChanges
+		signer    = types.LatestSigner(config)
+		key, _    = crypto.GenerateKey()
+		coinbase  = crypto.PubkeyToAddress(key.PublicKey)
+		baseFee   = big.NewInt(10)
+		txs, rs   = feeTestTxs()
+		systemTx  = types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 0, To: &systemcontract.SysGovToAddr, Gas: 100000, GasPrice: new(big.Int)})
+		systemRcp = &types.Receipt{TxHash: systemTx.Hash(), GasUsed: 50000}
+	)
+	header := &types.Header{Number: big.NewInt(1), Coinbase: coinbase, BaseFee: baseFee}
+	block := types.NewBlockWithHeader(header).WithBody(append(txs[1:3:3], systemTx), nil)
+
+	fees, err := new(Congress).blockFeeShares(block, append(rs[1:3:3], systemRcp), signer)
+	if err != nil {
+		t.Fatalf("failed to explain fee shares: %v", err)
+	}
+	if len(fees.Shares) != 2 || fees.Total.ToInt().Uint64() != 1060000 || fees.Hash != block.Hash() {
+		t.Fatalf("unexpected fee shares: %+v", fees)
+	}
+	if len(fees.Recipients) != 2 || fees.Recipients[*txs[1].To()].ToInt().Uint64() != 60000 || fees.Recipients[rs[2].ContractAddress].ToInt().Uint64() != 1000000 {
+		t.Errorf("unexpected recipients: %v", fees.Recipients)
+	}
+	if _, err := new(Congress).blockFeeShares(block, rs[1:3], signer); !errors.Is(err, errFeeReceiptMismatch) {
+		t.Errorf("have error %v, want %v", err, errFeeReceiptMismatch)
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2024 The Splendor Authors
+// This file implements the fast finality of Congress blocks
+// Validators vote on the blocks they import, and the next validator to seal
+// aggregates a supermajority of votes on a recent ancestor into the header's
+// extra-data, which finalizes that ancestor.
+
+package congress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// After the fast finality fork the attestation is stored in the extra-data
+// between the validator list and the seals:
+//
+//	[attestation RLP] [length(4)]
+//
+// A zero length means the header carries no attestation.
+const (
+	attestationLenSize = 4  // Size of the attestation length suffix
+	maxAttestationAge  = 64 // Maximum distance between a header and the block it attests
+)
+
+var (
+	// errInvalidAttestation is returned if the finality attestation of a header
+	// is malformed or attests a block that isn't a recent ancestor.
+	errInvalidAttestation = errors.New("invalid finality attestation")
+
+	// errStaleAttestation is returned if a header attests a block that isn't
+	// newer than the block already finalized on its chain.
+	errStaleAttestation = errors.New("finality attestation not above finalized block")
+
+	// errInsufficientVotes is returned if a finality attestation isn't signed by
+	// a supermajority of the validators.
+	errInsufficientVotes = errors.New("finality attestation without supermajority")
+)
+
+// attestation is the aggregate of the validators' votes on a recent ancestor
+// of the header carrying it.
+type attestation struct {
+	Number     uint64
+	Hash       common.Hash
+	Signatures [][]byte
+}
+
+// votes returns the individual votes aggregated by the attestation.
+func (a *attestation) votes() []*types.Vote {
+	votes := make([]*types.Vote, len(a.Signatures))
+	for i, sig := range a.Signatures {
+		votes[i] = types.NewVote(a.Number, a.Hash)
+		votes[i].Signature = sig
+	}
+	return votes
+}
+
+// encodeAttestation returns the extra-data encoding of an attestation, which
+// may be nil.
+func encodeAttestation(a *attestation) []byte {
+	var enc []byte
+	if a != nil {
+		enc, _ = rlp.EncodeToBytes(a)
+	}
+	size := make([]byte, attestationLenSize)
+	binary.BigEndian.PutUint32(size, uint32(len(enc)))
+	return append(enc, size...)
+}
+
+// decodeAttestation parses the attestation in the extra-data of a header in
+// front of its seals, returning it along with the number of bytes it takes.
+func decodeAttestation(header *types.Header, sealLen int) (*attestation, int, error) {
+	end := len(header.Extra) - sealLen
+	if end < extraVanity+attestationLenSize {
+		return nil, 0, errInvalidAttestation
+	}
+	size := int(binary.BigEndian.Uint32(header.Extra[end-attestationLenSize : end]))
+	if size == 0 {
+		return nil, attestationLenSize, nil
+	}
+	start := end - attestationLenSize - size
+	if size < 0 || start < extraVanity {
+		return nil, 0, errInvalidAttestation
+	}
+	att := new(attestation)
+	if err := rlp.DecodeBytes(header.Extra[start:end-attestationLenSize], att); err != nil {
+		return nil, 0, errInvalidAttestation
+	}
+	return att, attestationLenSize + size, nil
+}
+
+// extraTrailer returns the number of extra-data bytes at the end of a header
+// that follow the validator list: the seals and, after their forks, the
+// finality attestation, the random beacon data, the registered key updates
+// and the snapshot state of epoch blocks.
+func extraTrailer(config *params.ChainConfig, header *types.Header) (int, error) {
+	trailer, err := checkpointTrailer(config, header)
+	if err != nil {
+		return 0, err
+	}
+	if isCheckpointBlock(config, header.Number) {
+		_, size, err := decodeCheckpoint(header, trailer)
+		if err != nil {
+			return 0, err
+		}
+		trailer += size
+	}
+	return trailer, nil
+}
+
+// supermajority returns whether the number of votes is more than two thirds of
+// the validators.
+func supermajority(votes int, validators int) bool {
+	return votes*3 > validators*2
+}
+
+// quorum returns the minimal number of votes making a supermajority.
+func quorum(validators int) int {
+	return validators*2/3 + 1
+}
+
+// ancestorHash returns the hash of the ancestor of a header at the given number,
+// using the batch of parents (ascending order) before looking up the database.
+func ancestorHash(chain consensus.ChainHeaderReader, header *types.Header, number uint64, parents []*types.Header) common.Hash {
+	if number >= header.Number.Uint64() {
+		return common.Hash{}
+	}
+	hash, current := header.ParentHash, header.Number.Uint64()-1
+	for current > number {
+		var parent *types.Header
+		if len(parents) > 0 && parents[len(parents)-1].Number.Uint64() == current {
+			parent, parents = parents[len(parents)-1], parents[:len(parents)-1]
+		} else {
+			parent = chain.GetHeader(hash, current)
+		}
+		if parent == nil || parent.Hash() != hash {
+			return common.Hash{}
+		}
+		hash, current = parent.ParentHash, current-1
+	}
+	return hash
+}
+
+// verifyAttestation checks that the attestation of a header, if any, is signed
+// by a supermajority of the validators of its parent snapshot on an ancestor
+// newer than the one already finalized.
+func (c *Congress) verifyAttestation(chain consensus.ChainHeaderReader, snap *Snapshot, header *types.Header, parents []*types.Header) error {
+	sealLen, err := sealLength(chain.Config(), header)
+	if err != nil {
+		return err
+	}
+	att, _, err := decodeAttestation(header, sealLen)
+	if err != nil || att == nil {
+		return err
+	}
+	number := header.Number.Uint64()
+	if att.Number >= number || att.Number+maxAttestationAge < number || !chain.Config().IsFastFinality(new(big.Int).SetUint64(att.Number)) {
+		return errInvalidAttestation
+	}
+	if snap.Finalized != nil && att.Number <= snap.Finalized.Number {
+		return errStaleAttestation
+	}
+	if ancestorHash(chain, header, att.Number, parents) != att.Hash {
+		return errInvalidAttestation
+	}
+	// Every signature must come from a distinct validator, in ascending order
+	var last common.Address
+	for i, vote := range att.votes() {
+		signer, err := vote.Signer()
+		if err != nil {
+			return errInvalidAttestation
+		}
+		if _, ok := snap.Validators[signer]; !ok {
+			return errUnauthorizedValidator
+		}
+		if i > 0 && bytes.Compare(last[:], signer[:]) >= 0 {
+			return errInvalidAttestation
+		}
+		last = signer
+	}
+	if !supermajority(len(att.Signatures), len(snap.Validators)) {
+		return errInsufficientVotes
+	}
+	return nil
+}
+
+// assembleAttestation aggregates the pooled votes on the newest ancestor of a
+// header which a supermajority of the validators voted for, if any.
+func (c *Congress) assembleAttestation(chain consensus.ChainHeaderReader, snap *Snapshot, header *types.Header) *attestation {
+	number := header.Number.Uint64()
+	for _, set := range c.votes.sets() {
+		if set.number >= number || set.number+maxAttestationAge < number {
+			continue
+		}
+		if snap.Finalized != nil && set.number <= snap.Finalized.Number {
+			break
+		}
+		// Only count the votes of the current validators
+		var signers []common.Address
+		for signer := range set.votes {
+			if _, ok := snap.Validators[signer]; ok {
+				signers = append(signers, signer)
+			}
+		}
+		if !supermajority(len(signers), len(snap.Validators)) {
+			continue
+		}
+		if ancestorHash(chain, header, set.number, nil) != set.hash {
+			continue
+		}
+		sort.Sort(validatorsAscending(signers))
+		att := &attestation{Number: set.number, Hash: set.hash}
+		for _, signer := range signers[:quorum(len(snap.Validators))] {
+			att.Signatures = append(att.Signatures, set.votes[signer].Signature)
+		}
+		return att
+	}
+	return nil
+}
+
+// FinalizedHeader implements consensus.FinalityEngine, returning the newest
+// block finalized by the attestations of the chain ending with the header.
+func (c *Congress) FinalizedHeader(chain consensus.ChainHeaderReader, header *types.Header) *types.Header {
+	if !chain.Config().IsFastFinality(header.Number) {
+		return nil
+	}
+	snap, err := c.snapshot(chain, header.Number.Uint64(), header.Hash(), nil)
+	if err != nil || snap.Finalized == nil {
+		return nil
+	}
+	return chain.GetHeader(snap.Finalized.Hash, snap.Finalized.Number)
+}
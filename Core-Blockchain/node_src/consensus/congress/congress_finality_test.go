@@ -0,0 +1,167 @@
+// Copyright 2024 The Splendor Authors
+// Tests of the finality attestations of Congress headers
+
+package congress
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// finalityTestChain is a header reader serving a single chain of headers.
+type finalityTestChain struct {
+	config  *params.ChainConfig
+	headers map[common.Hash]*types.Header
+}
+
+func (c *finalityTestChain) Config() *params.ChainConfig            { return c.config }
+func (c *finalityTestChain) CurrentHeader() *types.Header           { return nil }
+func (c *finalityTestChain) GetHeaderByNumber(uint64) *types.Header { return nil }
+func (c *finalityTestChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	return c.headers[hash]
+}
+func (c *finalityTestChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := c.headers[hash]; header != nil && header.Number.Uint64() == number {
+		return header
+	}
+	return nil
+}
+
+// newFinalityTestChain creates an engine with the fast finality fork at block 1,
+// a chain of the given length and a snapshot of four validators.
+func newFinalityTestChain(length int) (*Congress, *finalityTestChain, *Snapshot, []*ecdsa.PrivateKey, []*types.Header) {
+	config := *params.AllCliqueProtocolChanges
+	config.Clique = nil
+	config.Congress = &params.CongressConfig{Period: 1, Epoch: 30000}
+	config.FastFinalityBlock = big.NewInt(1)
+
+	c := New(&config, nil)
+	chain := &finalityTestChain{config: &config, headers: make(map[common.Hash]*types.Header)}
+
+	keys := make([]*ecdsa.PrivateKey, 4)
+	validators := make([]common.Address, len(keys))
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		validators[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	sigcache, _ := lru.NewARC(inmemorySignatures)
+	snap := newSnapshot(c.config, sigcache, 0, common.Hash{}, validators)
+
+	headers := make([]*types.Header, length)
+	for i := range headers {
+		headers[i] = &types.Header{Number: big.NewInt(int64(i)), Extra: make([]byte, extraVanity+attestationLenSize+extraSeal)}
+		if i > 0 {
+			headers[i].ParentHash = headers[i-1].Hash()
+		}
+		chain.headers[headers[i].Hash()] = headers[i]
+	}
+	return c, chain, snap, keys, headers
+}
+
+// signVote casts the vote of a validator on a header.
+func signVote(t *testing.T, key *ecdsa.PrivateKey, header *types.Header) *types.Vote {
+	vote := types.NewVote(header.Number.Uint64(), header.Hash())
+	sig, err := crypto.Sign(crypto.Keccak256(vote.SigningData()), key)
+	if err != nil {
+		t.Fatalf("failed to sign vote: %v", err)
+	}
+	vote.Signature = sig
+	return vote
+}
+
+// attest returns a header on top of the parent carrying the attestation.
+func attest(parent *types.Header, att *attestation) *types.Header {
+	extra := append(make([]byte, extraVanity), encodeAttestation(att)...)
+	return &types.Header{
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		ParentHash: parent.Hash(),
+		Extra:      append(extra, make([]byte, extraSeal)...),
+	}
+}
+
+func TestAttestationEncoding(t *testing.T) {
+	att := &attestation{Number: 7, Hash: common.Hash{0x07}, Signatures: [][]byte{{1}, {2, 3}}}
+	header := attest(&types.Header{Number: big.NewInt(9)}, att)
+
+	dec, size, err := decodeAttestation(header, extraSeal)
+	if err != nil {
+		t.Fatalf("failed to decode attestation: %v", err)
+	}
+	if dec.Number != att.Number || dec.Hash != att.Hash || len(dec.Signatures) != 2 {
+		t.Errorf("attestation mismatch: have %+v, want %+v", dec, att)
+	}
+	if size != len(header.Extra)-extraVanity-extraSeal {
+		t.Errorf("size mismatch: have %d, want %d", size, len(header.Extra)-extraVanity-extraSeal)
+	}
+	// Headers without attestation only carry the length
+	header = attest(header, nil)
+	if dec, size, err := decodeAttestation(header, extraSeal); dec != nil || size != attestationLenSize || err != nil {
+		t.Errorf("empty attestation: have %v/%d/%v", dec, size, err)
+	}
+	// Lengths beyond the extra-data are rejected
+	header.Extra[len(header.Extra)-extraSeal-1] = 0xff
+	if _, _, err := decodeAttestation(header, extraSeal); err != errInvalidAttestation {
+		t.Errorf("oversized attestation: have %v, want %v", err, errInvalidAttestation)
+	}
+}
+
+func TestAttestationAssembly(t *testing.T) {
+	c, chain, snap, keys, headers := newFinalityTestChain(6)
+	head := headers[len(headers)-1]
+
+	// Two votes out of four validators aren't a supermajority
+	for _, key := range keys[:2] {
+		vote := signVote(t, key, headers[2])
+		signer, _ := vote.Signer()
+		c.votes.add(vote, signer)
+	}
+	if att := c.assembleAttestation(chain, snap, &types.Header{Number: big.NewInt(6), ParentHash: head.Hash()}); att != nil {
+		t.Fatalf("attestation without supermajority: %+v", att)
+	}
+	// A third vote makes the block final
+	vote := signVote(t, keys[2], headers[2])
+	signer, _ := vote.Signer()
+	c.votes.add(vote, signer)
+
+	att := c.assembleAttestation(chain, snap, &types.Header{Number: big.NewInt(6), ParentHash: head.Hash()})
+	if att == nil || att.Number != 2 || att.Hash != headers[2].Hash() || len(att.Signatures) != 3 {
+		t.Fatalf("attestation mismatch: have %+v", att)
+	}
+	header := attest(head, att)
+	if err := c.verifyAttestation(chain, snap, header, nil); err != nil {
+		t.Fatalf("failed to verify attestation: %v", err)
+	}
+	// Attestations can't be re-ordered, duplicated or trimmed
+	for i, sigs := range [][][]byte{
+		{att.Signatures[1], att.Signatures[0], att.Signatures[2]},
+		{att.Signatures[0], att.Signatures[0], att.Signatures[1]},
+	} {
+		bad := attest(head, &attestation{Number: att.Number, Hash: att.Hash, Signatures: sigs})
+		if err := c.verifyAttestation(chain, snap, bad, nil); err != errInvalidAttestation {
+			t.Errorf("bad signatures %d: have %v, want %v", i, err, errInvalidAttestation)
+		}
+	}
+	bad := attest(head, &attestation{Number: att.Number, Hash: att.Hash, Signatures: att.Signatures[:2]})
+	if err := c.verifyAttestation(chain, snap, bad, nil); err != errInsufficientVotes {
+		t.Errorf("trimmed signatures: have %v, want %v", err, errInsufficientVotes)
+	}
+	// Attestations must be on an ancestor not yet finalized
+	bad = attest(head, &attestation{Number: att.Number, Hash: common.Hash{0x01}, Signatures: att.Signatures})
+	if err := c.verifyAttestation(chain, snap, bad, nil); err != errInvalidAttestation {
+		t.Errorf("foreign block: have %v, want %v", err, errInvalidAttestation)
+	}
+	snap.Finalized = &FinalizedBlock{Number: 2, Hash: headers[2].Hash()}
+	if err := c.verifyAttestation(chain, snap, header, nil); err != errStaleAttestation {
+		t.Errorf("finalized block: have %v, want %v", err, errStaleAttestation)
+	}
+	if att := c.assembleAttestation(chain, snap, &types.Header{Number: big.NewInt(6), ParentHash: head.Hash()}); att != nil {
+		t.Errorf("attestation of finalized block: %+v", att)
+	}
+}
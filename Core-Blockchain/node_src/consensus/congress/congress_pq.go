@@ -0,0 +1,335 @@
+// Copyright 2024 The Splendor Authors
+// This file implements the post-quantum seal of Congress headers
+// Validators dual-sign (ECDSA + ML-DSA) during the PQT transition and seal
+// with ML-DSA only once it is enforced.
+
+package congress
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/mldsa"
+	"github.com/ethereum/go-ethereum/params"
+	lru "github.com/hashicorp/golang-lru"
+	"golang.org/x/crypto/sha3"
+)
+
+// The ML-DSA seal is stored in the extra-data right before the secp256k1 seal:
+//
+//	[publicKey] [signature] [algorithm(1)] [flags(1)]
+//
+// The public key is only present if the pqSealFlagKey flag is set, which is how
+// validators announce their keys. The algorithm is one of the params.MLDSA*_ID
+// identifiers and determines the size of the signature and the public key.
+const (
+	pqSealTrailer = 2    // Size of the algorithm and flags bytes
+	pqSealFlagKey = 0x01 // Flag marking a seal that carries the public key
+)
+
+var (
+	// errMissingPQSeal is returned if a header after the PQT fork doesn't carry
+	// a well-formed ML-DSA seal.
+	errMissingPQSeal = errors.New("extra-data ML-DSA seal missing")
+
+	// errInvalidPQSeal is returned if the ML-DSA seal of a header doesn't verify
+	// against the key of its validator.
+	errInvalidPQSeal = errors.New("invalid ML-DSA seal")
+
+	// errUnknownPQKey is returned if a validator seals a block without its
+	// ML-DSA key being known.
+	errUnknownPQKey = errors.New("unknown validator ML-DSA key")
+
+	// errPQKeyChange is returned if a validator announces a new ML-DSA key after
+	// the PQT transition, when there's no secp256k1 seal to authenticate it.
+	errPQKeyChange = errors.New("validator ML-DSA key change after PQT transition")
+
+	// errUnexpectedECDSASeal is returned if a header carries a secp256k1 seal
+	// once ML-DSA only sealing is enforced.
+	errUnexpectedECDSASeal = errors.New("secp256k1 seal after PQT transition")
+
+	// errMissingPQSigner is returned when sealing a block after the PQT fork
+	// without an ML-DSA key.
+	errMissingPQSigner = errors.New("ML-DSA sealing key missing")
+)
+
+// pqAlgorithmNames maps the ML-DSA algorithm identifiers to their names.
+var pqAlgorithmNames = map[byte]string{
+	params.MLDSA44_ID: mldsa.MLDSA44,
+	params.MLDSA65_ID: mldsa.MLDSA65,
+	params.MLDSA87_ID: mldsa.MLDSA87,
+}
+
+// PQKey is a validator's registered ML-DSA public key.
+type PQKey struct {
+	Algorithm byte          `json:"algorithm"`
+	PublicKey hexutil.Bytes `json:"publicKey"`
+}
+
+// equal returns whether two keys are the same.
+func (k *PQKey) equal(other *PQKey) bool {
+	return other != nil && k.Algorithm == other.Algorithm && bytes.Equal(k.PublicKey, other.PublicKey)
+}
+
+// pqSeal is the decoded ML-DSA seal of a header.
+type pqSeal struct {
+	algorithm byte
+	publicKey []byte // Only set if the validator announces its key
+	signature []byte
+}
+
+// size returns the number of extra-data bytes taken by the seal.
+func (s *pqSeal) size() int {
+	return len(s.publicKey) + len(s.signature) + pqSealTrailer
+}
+
+// encode returns the extra-data encoding of the seal.
+func (s *pqSeal) encode() []byte {
+	var flags byte
+	if s.publicKey != nil {
+		flags |= pqSealFlagKey
+	}
+	enc := make([]byte, 0, s.size())
+	enc = append(enc, s.publicKey...)
+	enc = append(enc, s.signature...)
+	return append(enc, s.algorithm, flags)
+}
+
+// decodePQSeal parses the ML-DSA seal in the extra-data of a header.
+func decodePQSeal(header *types.Header) (*pqSeal, error) {
+	end := len(header.Extra) - extraSeal
+	if end < extraVanity+pqSealTrailer {
+		return nil, errMissingPQSeal
+	}
+	algorithm, flags := header.Extra[end-2], header.Extra[end-1]
+	name, ok := pqAlgorithmNames[algorithm]
+	if !ok || flags&^pqSealFlagKey != 0 {
+		return nil, errMissingPQSeal
+	}
+	sigSize, pkSize, _ := mldsa.GetMLDSALengths(name)
+	size := sigSize + pqSealTrailer
+	if flags&pqSealFlagKey != 0 {
+		size += pkSize
+	}
+	if end-size < extraVanity {
+		return nil, errMissingPQSeal
+	}
+	seal := &pqSeal{
+		algorithm: algorithm,
+		signature: header.Extra[end-sigSize-pqSealTrailer : end-pqSealTrailer],
+	}
+	if flags&pqSealFlagKey != 0 {
+		seal.publicKey = header.Extra[end-size : end-size+pkSize]
+	}
+	return seal, nil
+}
+
+// sealLength returns the number of extra-data bytes at the end of a header
+// taken by its seals: the secp256k1 seal and, after the PQT fork, the ML-DSA
+// seal in front of it.
+func sealLength(config *params.ChainConfig, header *types.Header) (int, error) {
+	if !config.IsPQTFork(header.Number) {
+		return extraSeal, nil
+	}
+	seal, err := decodePQSeal(header)
+	if err != nil {
+		return 0, err
+	}
+	return extraSeal + seal.size(), nil
+}
+
+// sealer returns the validator that sealed a header: the signer of its
+// secp256k1 seal, or its coinbase once blocks are sealed with ML-DSA only, in
+// which case the seal is checked by verifyPQSeal.
+func sealer(config *params.ChainConfig, header *types.Header, sigcache *lru.ARCCache) (common.Address, error) {
+	if config.IsPQTEnforced(header.Number) {
+		return header.Coinbase, nil
+	}
+	return ecrecover(header, sigcache)
+}
+
+// pqSealHash returns the hash signed by the ML-DSA seal of a header: the hash
+// of the header without any of its seals. The secp256k1 seal, if any, signs
+// the header including the ML-DSA seal, see SealHash.
+func pqSealHash(header *types.Header, sealLen int) (hash common.Hash) {
+	hasher := sha3.NewLegacyKeccak256()
+	encodeSigHeaderWithExtra(hasher, header, header.Extra[:len(header.Extra)-sealLen])
+	hasher.Sum(hash[:0])
+	return hash
+}
+
+// verifyPQSeal checks the ML-DSA seal of a header after the PQT fork against
+// the key registered for its validator in the snapshot, or the key the seal
+// announces during the transition, which the secp256k1 seal authenticates.
+// Announcements are ignored once keys come from the PQ key registry.
+func (c *Congress) verifyPQSeal(snap *Snapshot, header *types.Header) error {
+	seal, err := decodePQSeal(header)
+	if err != nil {
+		return err
+	}
+	key := snap.PQKeys[header.Coinbase]
+	if seal.publicKey != nil && !c.chainConfig.IsPQKeyRegistry(header.Number) {
+		announced := &PQKey{Algorithm: seal.algorithm, PublicKey: seal.publicKey}
+		if !announced.equal(key) && c.chainConfig.IsPQTEnforced(header.Number) {
+			return errPQKeyChange
+		}
+		key = announced
+	}
+	if key == nil {
+		return errUnknownPQKey
+	}
+	if key.Algorithm != seal.algorithm {
+		return errInvalidPQSeal
+	}
+	hash := pqSealHash(header, extraSeal+seal.size())
+	if err := mldsa.VerifySignature(pqAlgorithmNames[key.Algorithm], hash.Bytes(), seal.signature, key.PublicKey); err != nil {
+		return errInvalidPQSeal
+	}
+	return nil
+}
+
+// pqSigner selects the ML-DSA key to seal a header with: the one the snapshot
+// knows for the validator, so that it can hold both its current and its pending
+// key across a rotation. If the snapshot knows none of them, the first key is
+// announced during the PQT transition, unless keys come from the registry.
+func (c *Congress) pqSigner(snap *Snapshot, number *big.Int) (*PQSigner, bool, error) {
+	c.lock.RLock()
+	validator, signers := c.validator, c.pqSigners
+	c.lock.RUnlock()
+
+	if len(signers) == 0 {
+		return nil, false, errMissingPQSigner
+	}
+	known := snap.PQKeys[validator]
+	for i := range signers {
+		if signers[i].Key.equal(known) {
+			return &signers[i], false, nil
+		}
+	}
+	if c.chainConfig.IsPQKeyRegistry(number) {
+		return nil, false, errUnknownPQKey
+	}
+	if c.chainConfig.IsPQTEnforced(number) {
+		return nil, false, errPQKeyChange
+	}
+	return &signers[0], true, nil
+}
+
+// preparePQSeal reserves the space for the ML-DSA seal of a header at the end
+// of its extra-data, which must not yet contain the secp256k1 seal. The public
+// key is announced if the snapshot doesn't know it yet.
+func (c *Congress) preparePQSeal(snap *Snapshot, header *types.Header) error {
+	signer, announce, err := c.pqSigner(snap, header.Number)
+	if err != nil {
+		return err
+	}
+	sigSize, _, err := mldsa.GetMLDSALengths(pqAlgorithmNames[signer.Key.Algorithm])
+	if err != nil {
+		return err
+	}
+	seal := &pqSeal{algorithm: signer.Key.Algorithm, signature: make([]byte, sigSize)}
+	if announce {
+		seal.publicKey = signer.Key.PublicKey
+	}
+	header.Extra = append(header.Extra, seal.encode()...)
+	return nil
+}
+
+// sealPQ fills in the ML-DSA seal reserved by preparePQSeal, with the key it
+// selected from the same snapshot.
+func (c *Congress) sealPQ(snap *Snapshot, header *types.Header) error {
+	signer, _, err := c.pqSigner(snap, header.Number)
+	if err != nil {
+		return err
+	}
+	seal, err := decodePQSeal(header)
+	if err != nil {
+		return err
+	}
+	if seal.algorithm != signer.Key.Algorithm {
+		return errInvalidPQSeal
+	}
+	sig, err := signer.SignFn(pqSealHash(header, extraSeal+seal.size()).Bytes())
+	if err != nil {
+		return err
+	}
+	if len(sig) != len(seal.signature) {
+		return fmt.Errorf("invalid ML-DSA seal length: have %d, want %d", len(sig), len(seal.signature))
+	}
+	copy(seal.signature, sig)
+	return nil
+}
+
+// PQSignerFn signs a message with the ML-DSA key of the validator.
+type PQSignerFn func(message []byte) ([]byte, error)
+
+// PQSigner is an ML-DSA key of the validator along with its signing function.
+type PQSigner struct {
+	Key    *PQKey
+	SignFn PQSignerFn
+}
+
+// AuthorizePQ injects the ML-DSA keys the validator seals blocks with after the
+// PQT fork. Each block is sealed with the key its snapshot knows, so a rotating
+// validator can hold both its current and its pending key.
+func (c *Congress) AuthorizePQ(signers ...PQSigner) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.pqSigners = signers
+}
+
+// pqKeyFile is the format of the key file holding a validator's ML-DSA key.
+type pqKeyFile struct {
+	Algorithm string        `json:"algorithm"`
+	PublicKey hexutil.Bytes `json:"publicKey"`
+	SecretKey hexutil.Bytes `json:"secretKey"`
+}
+
+// LoadPQKey loads an ML-DSA key pair from a JSON key file, returning the public
+// key and a signing function for AuthorizePQ.
+func LoadPQKey(file string) (*PQKey, PQSignerFn, error) {
+	blob, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	var dec pqKeyFile
+	if err := json.Unmarshal(blob, &dec); err != nil {
+		return nil, nil, err
+	}
+	key := &PQKey{PublicKey: dec.PublicKey}
+	for id, name := range pqAlgorithmNames {
+		if name == dec.Algorithm {
+			key.Algorithm = id
+		}
+	}
+	if key.Algorithm == 0 {
+		return nil, nil, mldsa.ErrInvalidAlgorithm
+	}
+	if len(dec.PublicKey) != mldsa.MLDSAParams[dec.Algorithm].PublicKeySize {
+		return nil, nil, mldsa.ErrInvalidPublicKey
+	}
+	if len(dec.SecretKey) != mldsa.MLDSAParams[dec.Algorithm].SecretKeySize {
+		return nil, nil, mldsa.ErrInvalidSecretKey
+	}
+	signFn := func(message []byte) ([]byte, error) {
+		return mldsa.SignMessage(dec.Algorithm, message, dec.SecretKey)
+	}
+	// Make sure the secret key belongs to the public key before sealing with it
+	probe := []byte("congress ML-DSA key check")
+	sig, err := signFn(probe)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := mldsa.VerifySignature(dec.Algorithm, probe, sig, key.PublicKey); err != nil {
+		return nil, nil, errors.New("ML-DSA secret key doesn't match the public key")
+	}
+	return key, signFn, nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2024 The Splendor Authors
+// Tests of the post-quantum seal of Congress headers
+
+package congress
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/mldsa"
+	"github.com/ethereum/go-ethereum/params"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// pqTestChain is a header reader that only serves the chain config.
+type pqTestChain struct{ config *params.ChainConfig }
+
+func (c *pqTestChain) Config() *params.ChainConfig                    { return c.config }
+func (c *pqTestChain) CurrentHeader() *types.Header                   { return nil }
+func (c *pqTestChain) GetHeader(common.Hash, uint64) *types.Header    { return nil }
+func (c *pqTestChain) GetHeaderByNumber(uint64) *types.Header         { return nil }
+func (c *pqTestChain) GetHeaderByHash(hash common.Hash) *types.Header { return nil }
+
+// newPQTestEngine creates an engine whose PQT fork is at block 1 with a
+// transition of 10 blocks, authorized to seal for a fresh validator.
+func newPQTestEngine(t *testing.T) (*Congress, *pqTestChain, *Snapshot) {
+	config := *params.AllCliqueProtocolChanges
+	config.Clique = nil
+	config.Congress = &params.CongressConfig{Period: 1, Epoch: 30000}
+	config.PostQuantum = &params.PostQuantumConfig{PQTBlock: big.NewInt(1), TransitionBlocks: 10}
+
+	return newPQTestEngineWithConfig(t, &config)
+}
+
+// newPQTestEngineWithConfig creates an engine with the given chain config,
+// authorized to seal for a fresh validator.
+func newPQTestEngineWithConfig(t *testing.T, config *params.ChainConfig) (*Congress, *pqTestChain, *Snapshot) {
+	c := New(config, nil)
+	ecdsaKey, _ := crypto.GenerateKey()
+	c.validator = crypto.PubkeyToAddress(ecdsaKey.PublicKey)
+	c.signFn = func(_ accounts.Account, _ string, data []byte) ([]byte, error) {
+		return crypto.Sign(crypto.Keccak256(data), ecdsaKey)
+	}
+	publicKey, secretKey, err := mldsa.GenerateKeyPair(mldsa.MLDSA44)
+	if err != nil {
+		t.Fatalf("failed to generate ML-DSA key: %v", err)
+	}
+	c.AuthorizePQ(PQSigner{Key: &PQKey{Algorithm: params.MLDSA44_ID, PublicKey: publicKey}, SignFn: func(message []byte) ([]byte, error) {
+		return mldsa.SignMessage(mldsa.MLDSA44, message, secretKey)
+	}})
+	sigcache, _ := lru.NewARC(inmemorySignatures)
+	snap := newSnapshot(c.config, sigcache, 0, common.Hash{}, []common.Address{c.validator})
+	return c, &pqTestChain{config: config}, snap
+}
+
+// sealPQTestHeader prepares and seals a header the way Prepare and Seal do.
+func sealPQTestHeader(t *testing.T, c *Congress, snap *Snapshot, number int64) *types.Header {
+	header := &types.Header{
+		Number:     big.NewInt(number),
+		Coinbase:   c.validator,
+		Difficulty: new(big.Int).Set(diffInTurn),
+		Extra:      make([]byte, extraVanity),
+	}
+	if err := c.preparePQSeal(snap, header); err != nil {
+		t.Fatalf("failed to prepare block %d: %v", number, err)
+	}
+	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
+	sealHash := c.SealHash(header)
+
+	if err := c.sealPQ(snap, header); err != nil {
+		t.Fatalf("failed to seal block %d: %v", number, err)
+	}
+	if !c.chainConfig.IsPQTEnforced(header.Number) {
+		sig, err := c.signFn(accounts.Account{}, accounts.MimetypeCongress, CongressRLP(header))
+		if err != nil {
+			t.Fatalf("failed to sign block %d: %v", number, err)
+		}
+		copy(header.Extra[len(header.Extra)-extraSeal:], sig)
+	}
+	if c.SealHash(header) != sealHash {
+		t.Fatalf("seal hash of block %d changed by sealing", number)
+	}
+	return header
+}
+
+func TestPQSealEncoding(t *testing.T) {
+	for _, seal := range []*pqSeal{
+		{algorithm: params.MLDSA65_ID, signature: make([]byte, 3309)},
+		{algorithm: params.MLDSA87_ID, signature: make([]byte, 4627), publicKey: make([]byte, 2592)},
+	} {
+		seal.signature[0], seal.signature[len(seal.signature)-1] = 1, 2
+		header := &types.Header{Extra: append(append(make([]byte, extraVanity), seal.encode()...), make([]byte, extraSeal)...)}
+
+		dec, err := decodePQSeal(header)
+		if err != nil {
+			t.Fatalf("failed to decode seal: %v", err)
+		}
+		if dec.algorithm != seal.algorithm || string(dec.signature) != string(seal.signature) || len(dec.publicKey) != len(seal.publicKey) {
+			t.Errorf("seal mismatch: have %x/%d/%d, want %x/%d/%d", dec.algorithm, len(dec.signature), len(dec.publicKey),
+				seal.algorithm, len(seal.signature), len(seal.publicKey))
+		}
+		if dec.size() != seal.size() {
+			t.Errorf("size mismatch: have %d, want %d", dec.size(), seal.size())
+		}
+		// Truncated seals, unknown algorithms and flags are rejected
+		if _, err := decodePQSeal(&types.Header{Extra: header.Extra[1:]}); err != errMissingPQSeal {
+			t.Errorf("truncated seal: have %v, want %v", err, errMissingPQSeal)
+		}
+		end := len(header.Extra) - extraSeal
+		header.Extra[end-1] = 0x02
+		if _, err := decodePQSeal(header); err != errMissingPQSeal {
+			t.Errorf("unknown flag: have %v, want %v", err, errMissingPQSeal)
+		}
+		header.Extra[end-1], header.Extra[end-2] = 0, params.MLKEM768_ID
+		if _, err := decodePQSeal(header); err != errMissingPQSeal {
+			t.Errorf("unknown algorithm: have %v, want %v", err, errMissingPQSeal)
+		}
+	}
+}
+
+func TestPQSealTransition(t *testing.T) {
+	c, chain, snap := newPQTestEngine(t)
+
+	// The first block after the fork announces the key, authenticated by ECDSA
+	header := sealPQTestHeader(t, c, snap, 1)
+	if seal, _ := decodePQSeal(header); seal.publicKey == nil {
+		t.Fatalf("key not announced")
+	}
+	if err := c.verifyPQSeal(snap, header); err != nil {
+		t.Fatalf("failed to verify seal: %v", err)
+	}
+	if signer, err := sealer(chain.config, header, c.signatures); err != nil || signer != c.validator {
+		t.Fatalf("ECDSA signer mismatch: have %x (%v), want %x", signer, err, c.validator)
+	}
+	next, err := snap.apply([]*types.Header{header}, chain, nil)
+	if err != nil {
+		t.Fatalf("failed to apply header: %v", err)
+	}
+	if !c.pqSigners[0].Key.equal(next.PQKeys[c.validator]) {
+		t.Fatalf("key not recorded in snapshot")
+	}
+	// Once the key is known, it isn't announced anymore
+	header = sealPQTestHeader(t, c, next, 2)
+	if seal, _ := decodePQSeal(header); seal.publicKey != nil {
+		t.Errorf("known key announced again")
+	}
+	if err := c.verifyPQSeal(next, header); err != nil {
+		t.Errorf("failed to verify seal: %v", err)
+	}
+	// A seal without the key doesn't verify against an unknown validator
+	if err := c.verifyPQSeal(snap, header); err != errUnknownPQKey {
+		t.Errorf("unknown key: have %v, want %v", err, errUnknownPQKey)
+	}
+	// Any change to the sealed header invalidates the ML-DSA seal
+	header.GasUsed++
+	if err := c.verifyPQSeal(next, header); err != errInvalidPQSeal {
+		t.Errorf("tampered header: have %v, want %v", err, errInvalidPQSeal)
+	}
+}
+
+func TestPQSealEnforced(t *testing.T) {
+	c, _, snap := newPQTestEngine(t)
+
+	// Keys can't be announced without ECDSA seal
+	header := &types.Header{Number: big.NewInt(11), Extra: make([]byte, extraVanity)}
+	if err := c.preparePQSeal(snap, header); err != errPQKeyChange {
+		t.Fatalf("unregistered key: have %v, want %v", err, errPQKeyChange)
+	}
+	snap.PQKeys[c.validator] = c.pqSigners[0].Key
+
+	header = sealPQTestHeader(t, c, snap, 11)
+	if err := c.verifyPQSeal(snap, header); err != nil {
+		t.Fatalf("failed to verify seal: %v", err)
+	}
+	if signer, err := sealer(c.chainConfig, header, c.signatures); err != nil || signer != c.validator {
+		t.Errorf("signer mismatch: have %x (%v), want %x", signer, err, c.validator)
+	}
+	// Another validator can't claim the block
+	other := *header
+	other.Coinbase = common.Address{1}
+	other.Extra = common.CopyBytes(header.Extra)
+	snap.PQKeys[other.Coinbase] = &PQKey{Algorithm: params.MLDSA44_ID, PublicKey: make([]byte, len(c.pqSigners[0].Key.PublicKey))}
+	if err := c.verifyPQSeal(snap, &other); err != errInvalidPQSeal {
+		t.Errorf("foreign seal: have %v, want %v", err, errInvalidPQSeal)
+	}
+}
+
+// Tests that a validator holding several keys seals with the one its snapshot
+// knows, so that it can rotate keys without restarting.
+func TestPQSignerSelection(t *testing.T) {
+	c, _, snap := newPQTestEngine(t)
+
+	publicKey, secretKey, err := mldsa.GenerateKeyPair(mldsa.MLDSA44)
+	if err != nil {
+		t.Fatalf("failed to generate ML-DSA key: %v", err)
+	}
+	current := c.pqSigners[0]
+	pending := PQSigner{Key: &PQKey{Algorithm: params.MLDSA44_ID, PublicKey: publicKey}, SignFn: func(message []byte) ([]byte, error) {
+		return mldsa.SignMessage(mldsa.MLDSA44, message, secretKey)
+	}}
+	c.AuthorizePQ(current, pending)
+
+	for _, known := range []PQSigner{current, pending} {
+		snap.PQKeys[c.validator] = known.Key
+		header := sealPQTestHeader(t, c, snap, 11)
+		if seal, _ := decodePQSeal(header); seal.publicKey != nil {
+			t.Errorf("known key announced")
+		}
+		if err := c.verifyPQSeal(snap, header); err != nil {
+			t.Errorf("failed to verify seal with key %x: %v", known.Key.PublicKey[:4], err)
+		}
+	}
+	// None of the keys can seal once the snapshot knows another one
+	snap.PQKeys[c.validator] = &PQKey{Algorithm: params.MLDSA44_ID, PublicKey: make([]byte, len(publicKey))}
+	header := &types.Header{Number: big.NewInt(11), Extra: make([]byte, extraVanity)}
+	if err := c.preparePQSeal(snap, header); err != errPQKeyChange {
+		t.Errorf("unknown keys: have %v, want %v", err, errPQKeyChange)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2024 The Splendor Authors
+// This file implements the activation of the keys of the PQ key registry
+// Validators register and rotate their ML-DSA keys in the registry system
+// contract. Every epoch block carries the keys activated at that block, read
+// from the contract, which the snapshot installs for verifying the ML-DSA
+// seals from then on.
+
+package congress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/congress/vmcaller"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto/mldsa"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// After the PQ key registry fork the activated keys are stored in the
+// extra-data between the validator list and the random beacon data:
+//
+//	[key updates RLP] [length(4)]
+//
+// A zero length means the header activates no key, which is the case of every
+// header but epoch blocks.
+const keyUpdatesLenSize = 4
+
+// errInvalidKeyUpdates is returned if the keys activated by a header are
+// malformed, activated outside of an epoch block or don't match the registry.
+var errInvalidKeyUpdates = errors.New("invalid registered PQ key updates")
+
+// pqKeyUpdate is a validator's registered ML-DSA key activated by an epoch block.
+type pqKeyUpdate struct {
+	Validator common.Address
+	Algorithm byte
+	PublicKey []byte
+}
+
+// key returns the key installed by the update.
+func (u *pqKeyUpdate) key() *PQKey {
+	return &PQKey{Algorithm: u.Algorithm, PublicKey: common.CopyBytes(u.PublicKey)}
+}
+
+// encodeKeyUpdates returns the extra-data encoding of the key updates.
+func encodeKeyUpdates(updates []*pqKeyUpdate) []byte {
+	var enc []byte
+	if len(updates) > 0 {
+		enc, _ = rlp.EncodeToBytes(updates)
+	}
+	size := make([]byte, keyUpdatesLenSize)
+	binary.BigEndian.PutUint32(size, uint32(len(enc)))
+	return append(enc, size...)
+}
+
+// decodeKeyUpdates parses the key updates in the extra-data of a header, in
+// front of the given number of trailing bytes, returning them along with the
+// number of bytes they take.
+func decodeKeyUpdates(header *types.Header, trailer int) ([]*pqKeyUpdate, int, error) {
+	end := len(header.Extra) - trailer
+	if end < extraVanity+keyUpdatesLenSize {
+		return nil, 0, errInvalidKeyUpdates
+	}
+	size := int(binary.BigEndian.Uint32(header.Extra[end-keyUpdatesLenSize : end]))
+	if size == 0 {
+		return nil, keyUpdatesLenSize, nil
+	}
+	start := end - keyUpdatesLenSize - size
+	if size < 0 || start < extraVanity {
+		return nil, 0, errInvalidKeyUpdates
+	}
+	var updates []*pqKeyUpdate
+	if err := rlp.DecodeBytes(header.Extra[start:end-keyUpdatesLenSize], &updates); err != nil || len(updates) == 0 {
+		return nil, 0, errInvalidKeyUpdates
+	}
+	// Only well-formed sealing keys of distinct validators, in ascending order
+	for i, update := range updates {
+		name, ok := pqAlgorithmNames[update.Algorithm]
+		if !ok || len(update.PublicKey) != mldsa.MLDSAParams[name].PublicKeySize {
+			return nil, 0, errInvalidKeyUpdates
+		}
+		if i > 0 && bytes.Compare(updates[i-1].Validator[:], update.Validator[:]) >= 0 {
+			return nil, 0, errInvalidKeyUpdates
+		}
+	}
+	return updates, keyUpdatesLenSize + size, nil
+}
+
+// keyUpdatesTrailer returns the number of extra-data bytes following the key
+// updates of a header: the seals, the finality attestation and the random
+// beacon data.
+func keyUpdatesTrailer(config *params.ChainConfig, header *types.Header) (int, error) {
+	trailer, err := beaconTrailer(config, header)
+	if err != nil {
+		return 0, err
+	}
+	if size := beaconSize(config, header); size > 0 {
+		if len(header.Extra)-trailer-size < extraVanity {
+			return 0, errMissingBeacon
+		}
+		trailer += size
+	}
+	return trailer, nil
+}
+
+// pqKeyRegistryABI is the ABI of the PQ key registry system contract.
+var pqKeyRegistryABI abi.ABI
+
+func init() {
+	var err error
+	if pqKeyRegistryABI, err = abi.JSON(strings.NewReader(vm.PQKeyRegistryABI)); err != nil {
+		panic(err)
+	}
+}
+
+// pqKeyLookup returns the registered key of a validator active at the epoch
+// block being prepared or verified, or nil if it has none.
+type pqKeyLookup func(validator common.Address) (*vm.PQRegistryKey, error)
+
+// registeredPQKeys returns a lookup of the keys active at the given block in the
+// PQ key registry system contract, calling it on the state of the header, which
+// is either the block itself or its parent.
+func (c *Congress) registeredPQKeys(header *types.Header, statedb *state.StateDB, number uint64) pqKeyLookup {
+	return func(validator common.Address) (*vm.PQRegistryKey, error) {
+		if !c.chainConfig.IsPQKeyRegistry(header.Number) {
+			return nil, nil
+		}
+		// A pending key due by the block replaces the active one
+		for _, method := range []string{"getPendingKey", "getKey"} {
+			data, err := pqKeyRegistryABI.Pack(method, validator)
+			if err != nil {
+				return nil, err
+			}
+			msg := vmcaller.NewLegacyMessage(header.Coinbase, &vm.PQKeyRegistryAddress, 0, new(big.Int), math.MaxUint64, new(big.Int), data, false)
+			result, err := vmcaller.ExecuteMsg(msg, statedb, header, newMinimalChainContext(c), c.chainConfig)
+			if err != nil {
+				return nil, err
+			}
+			key := new(vm.PQRegistryKey)
+			if err := pqKeyRegistryABI.UnpackIntoInterface(key, method, result); err != nil {
+				return nil, err
+			}
+			if key.Algorithm != 0 && key.Activation <= number {
+				return key, nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+// pqKeyUpdates returns the registered keys an epoch block activates for its
+// validators: the keys whose activation is due, and the active keys of the
+// validators joining the set, as they may have registered them long before.
+func pqKeyUpdates(snap *Snapshot, header *types.Header, validators []common.Address, lookup pqKeyLookup) ([]*pqKeyUpdate, error) {
+	sorted := make([]common.Address, len(validators))
+	copy(sorted, validators)
+	sort.Sort(validatorsAscending(sorted))
+
+	var (
+		number  = header.Number.Uint64()
+		updates []*pqKeyUpdate
+	)
+	for _, validator := range sorted {
+		key, err := lookup(validator)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			continue
+		}
+		if _, ok := snap.Validators[validator]; ok && key.Activation != number {
+			continue
+		}
+		updates = append(updates, &pqKeyUpdate{Validator: validator, Algorithm: key.Algorithm, PublicKey: key.PublicKey})
+	}
+	return updates, nil
+}
+
+// prepareKeyUpdates returns the registered keys activated by a new epoch block,
+// according to the registry in the state of its parent.
+func (c *Congress) prepareKeyUpdates(chain consensus.ChainHeaderReader, snap *Snapshot, header *types.Header, validators []common.Address) ([]*pqKeyUpdate, error) {
+	parent := chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	if parent == nil {
+		return nil, consensus.ErrUnknownAncestor
+	}
+	statedb, err := c.stateFn(parent.Root)
+	if err != nil {
+		return nil, err
+	}
+	return pqKeyUpdates(snap, header, validators, c.registeredPQKeys(parent, statedb, header.Number.Uint64()))
+}
+
+// verifyKeyUpdates checks that an epoch block activates exactly the registered
+// keys due for its validators.
+func (c *Congress) verifyKeyUpdates(chain consensus.ChainHeaderReader, header *types.Header, validators []common.Address, state *state.StateDB) error {
+	snap, err := c.snapshot(chain, header.Number.Uint64()-1, header.ParentHash, nil)
+	if err != nil {
+		return err
+	}
+	trailer, err := keyUpdatesTrailer(chain.Config(), header)
+	if err != nil {
+		return err
+	}
+	updates, _, err := decodeKeyUpdates(header, trailer)
+	if err != nil {
+		return err
+	}
+	want, err := pqKeyUpdates(snap, header, validators, c.registeredPQKeys(header, state, header.Number.Uint64()))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(encodeKeyUpdates(updates), encodeKeyUpdates(want)) {
+		return errInvalidKeyUpdates
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+// Copyright 2024 The Splendor Authors
+// Tests of the activation of the keys of the PQ key registry
+
+package congress
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto/mldsa"
+	"github.com/ethereum/go-ethereum/params"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// registerTestPQKey registers a fresh ML-DSA-44 key for a validator in the
+// given block, returning the public key.
+func registerTestPQKey(t *testing.T, config *params.ChainConfig, statedb *state.StateDB, validator common.Address, number int64) []byte {
+	publicKey, secretKey, err := mldsa.GenerateKeyPair(mldsa.MLDSA44)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	key := &vm.PQRegistryKey{Algorithm: params.MLDSA44_ID, PublicKey: publicKey}
+	proof, err := mldsa.SignMessage(mldsa.MLDSA44, vm.PQKeyPossessionMessage(config.ChainID, validator, key), secretKey)
+	if err != nil {
+		t.Fatalf("failed to sign proof: %v", err)
+	}
+	input, err := pqKeyRegistryABI.Pack("registerKey", params.MLDSA44_ID, publicKey, proof, uint8(0), []byte{}, []byte{})
+	if err != nil {
+		t.Fatalf("failed to pack input: %v", err)
+	}
+	evm := newRegistryTestEVM(config, statedb, number)
+	if _, _, err := evm.Call(vm.AccountRef(validator), vm.PQKeyRegistryAddress, input, 10_000_000, new(big.Int)); err != nil {
+		t.Fatalf("failed to register key: %v", err)
+	}
+	return publicKey
+}
+
+// newRegistryTestEVM creates an EVM running the given block.
+func newRegistryTestEVM(config *params.ChainConfig, statedb *state.StateDB, number int64) *vm.EVM {
+	vmctx := vm.BlockContext{
+		CanTransfer: func(vm.StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(vm.StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: big.NewInt(number),
+	}
+	return vm.NewEVM(vmctx, vm.TxContext{}, statedb, config, vm.Config{})
+}
+
+// newRegistryTestConfig returns a chain config whose PQ key registry is active
+// from genesis, with epochs of 10 blocks.
+func newRegistryTestConfig() *params.ChainConfig {
+	config := *params.AllCliqueProtocolChanges
+	config.Clique = nil
+	config.Congress = &params.CongressConfig{Period: 1, Epoch: 10}
+	config.PostQuantum = &params.PostQuantumConfig{PQTBlock: big.NewInt(0), TransitionBlocks: 1, PQKeyRegistryBlock: big.NewInt(0)}
+	return &config
+}
+
+func TestPQKeyRegistryContract(t *testing.T) {
+	var (
+		config     = newRegistryTestConfig()
+		statedb, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		validator  = common.HexToAddress("0x1234")
+		other      = common.HexToAddress("0x5678")
+	)
+	publicKey, secretKey, err := mldsa.GenerateKeyPair(mldsa.MLDSA44)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	key := &vm.PQRegistryKey{Algorithm: params.MLDSA44_ID, PublicKey: publicKey}
+	proof, err := mldsa.SignMessage(mldsa.MLDSA44, vm.PQKeyPossessionMessage(config.ChainID, validator, key), secretKey)
+	if err != nil {
+		t.Fatalf("failed to sign proof: %v", err)
+	}
+	input, err := pqKeyRegistryABI.Pack("registerKey", params.MLDSA44_ID, publicKey, proof, uint8(0), []byte{}, []byte{})
+	if err != nil {
+		t.Fatalf("failed to pack input: %v", err)
+	}
+	// Proofs are bound to the validator, and registering requires a writable call
+	evm := newRegistryTestEVM(config, statedb, 5)
+	if _, _, err := evm.Call(vm.AccountRef(other), vm.PQKeyRegistryAddress, input, 10_000_000, new(big.Int)); err == nil {
+		t.Fatalf("foreign proof accepted")
+	}
+	if _, _, err := evm.StaticCall(vm.AccountRef(validator), vm.PQKeyRegistryAddress, input, 10_000_000); err != vm.ErrWriteProtection {
+		t.Fatalf("static registration: have %v, want %v", err, vm.ErrWriteProtection)
+	}
+	if _, _, err := evm.Call(vm.AccountRef(validator), vm.PQKeyRegistryAddress, input, 10_000_000, new(big.Int)); err != nil {
+		t.Fatalf("failed to register key: %v", err)
+	}
+	if logs := statedb.Logs(); len(logs) != 1 || logs[0].Topics[1] != common.BytesToHash(validator.Bytes()) {
+		t.Errorf("registration event missing: %v", logs)
+	}
+	// The key is pending until the next epoch block
+	if key := vm.ReadPQKey(statedb, validator, 9); key != nil {
+		t.Errorf("key active before its epoch")
+	}
+	if key := vm.ReadPendingPQKey(statedb, validator, 9); key == nil || !bytes.Equal(key.PublicKey, publicKey) || key.Activation != 10 {
+		t.Fatalf("pending key mismatch: %+v", key)
+	}
+	if key := vm.ReadPQKey(statedb, validator, 10); key == nil || !bytes.Equal(key.PublicKey, publicKey) {
+		t.Fatalf("key not active at its epoch: %+v", key)
+	}
+	// Rotating the key keeps the active one until the next epoch
+	rotated := registerTestPQKey(t, config, statedb, validator, 12)
+	if key := vm.ReadPQKey(statedb, validator, 19); key == nil || !bytes.Equal(key.PublicKey, publicKey) {
+		t.Errorf("rotated key lost the active one: %+v", key)
+	}
+	if key := vm.ReadPQKey(statedb, validator, 20); key == nil || !bytes.Equal(key.PublicKey, rotated) || key.Activation != 20 {
+		t.Errorf("rotated key not active at its epoch: %+v", key)
+	}
+	// Contracts read the keys through the ABI
+	call, _ := pqKeyRegistryABI.Pack("getPendingKey", validator)
+	ret, _, err := newRegistryTestEVM(config, statedb, 12).StaticCall(vm.AccountRef(other), vm.PQKeyRegistryAddress, call, 10_000_000)
+	if err != nil {
+		t.Fatalf("failed to read pending key: %v", err)
+	}
+	out, err := pqKeyRegistryABI.Unpack("getPendingKey", ret)
+	if err != nil {
+		t.Fatalf("failed to unpack pending key: %v", err)
+	}
+	if !bytes.Equal(out[1].([]byte), rotated) || out[4].(uint64) != 20 {
+		t.Errorf("pending key output mismatch: %v", out)
+	}
+}
+
+func TestKeyUpdatesEncoding(t *testing.T) {
+	pubkey := make([]byte, mldsa.MLDSAParams[mldsa.MLDSA44].PublicKeySize)
+	updates := []*pqKeyUpdate{
+		{Validator: common.Address{1}, Algorithm: params.MLDSA44_ID, PublicKey: pubkey},
+		{Validator: common.Address{2}, Algorithm: params.MLDSA44_ID, PublicKey: pubkey},
+	}
+	trailer := make([]byte, 10)
+	header := &types.Header{Extra: append(append(make([]byte, extraVanity), encodeKeyUpdates(updates)...), trailer...)}
+
+	dec, size, err := decodeKeyUpdates(header, len(trailer))
+	if err != nil {
+		t.Fatalf("failed to decode updates: %v", err)
+	}
+	if len(dec) != len(updates) || dec[1].Validator != updates[1].Validator || size != len(header.Extra)-extraVanity-len(trailer) {
+		t.Errorf("updates mismatch: have %d updates of %d bytes", len(dec), size)
+	}
+	// Headers without updates only carry the length
+	header = &types.Header{Extra: append(make([]byte, extraVanity), encodeKeyUpdates(nil)...)}
+	if dec, size, err := decodeKeyUpdates(header, 0); err != nil || dec != nil || size != keyUpdatesLenSize {
+		t.Errorf("empty updates: have %v/%d/%v", dec, size, err)
+	}
+	// Unordered validators and malformed keys are rejected
+	for i, invalid := range [][]*pqKeyUpdate{
+		{updates[1], updates[0]},
+		{{Validator: common.Address{1}, Algorithm: params.MLDSA44_ID, PublicKey: pubkey[1:]}},
+		{{Validator: common.Address{1}, Algorithm: params.MLKEM768_ID, PublicKey: pubkey}},
+	} {
+		header := &types.Header{Extra: append(make([]byte, extraVanity), encodeKeyUpdates(invalid)...)}
+		if _, _, err := decodeKeyUpdates(header, 0); err != errInvalidKeyUpdates {
+			t.Errorf("test %d: have %v, want %v", i, err, errInvalidKeyUpdates)
+		}
+	}
+}
+
+func TestPQKeyUpdates(t *testing.T) {
+	config := *newRegistryTestConfig()
+
+	var (
+		statedb, _  = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		sigcache, _ = lru.NewARC(inmemorySignatures)
+		member      = common.Address{1}
+		joiner      = common.Address{2}
+		unkeyed     = common.Address{3}
+	)
+	snap := newSnapshot(config.Congress, sigcache, 9, common.Hash{}, []common.Address{member, unkeyed})
+
+	memberKey := registerTestPQKey(t, &config, statedb, member, 5)
+	joinerKey := registerTestPQKey(t, &config, statedb, joiner, 5)
+
+	// The epoch block activating the keys carries them, as read from the
+	// registry on the state of its parent
+	var (
+		engine     = New(&config, nil)
+		parent     = &types.Header{Number: big.NewInt(9), Difficulty: new(big.Int)}
+		validators = []common.Address{unkeyed, joiner, member}
+	)
+	updates, err := pqKeyUpdates(snap, &types.Header{Number: big.NewInt(10)}, validators, engine.registeredPQKeys(parent, statedb, 10))
+	if err != nil {
+		t.Fatalf("failed to read updates: %v", err)
+	}
+	if len(updates) != 2 || updates[0].Validator != member || updates[1].Validator != joiner {
+		t.Fatalf("activated updates mismatch: %v", updates)
+	}
+	if string(updates[0].PublicKey) != string(memberKey) || string(updates[1].PublicKey) != string(joinerKey) {
+		t.Errorf("activated keys mismatch")
+	}
+	// Later epoch blocks only carry the keys of the validators joining the set
+	epoch := &types.Header{Number: big.NewInt(20), Difficulty: new(big.Int)}
+	if updates, err = pqKeyUpdates(snap, epoch, validators, engine.registeredPQKeys(epoch, statedb, 20)); err != nil {
+		t.Fatalf("failed to read updates: %v", err)
+	}
+	if len(updates) != 1 || updates[0].Validator != joiner {
+		t.Fatalf("joining updates mismatch: %v", updates)
+	}
+	// Installing the updates in the snapshot replaces the validators' keys
+	snap.PQKeys[member] = &PQKey{Algorithm: params.MLDSA44_ID, PublicKey: make([]byte, len(memberKey))}
+	header := &types.Header{
+		Number:     big.NewInt(10),
+		Coinbase:   member,
+		Difficulty: new(big.Int).Set(diffInTurn),
+		Extra:      append(make([]byte, extraVanity), member.Bytes()...),
+	}
+	header.Extra = append(header.Extra, encodeCheckpoint(snap)...)
+	if updates, err = pqKeyUpdates(snap, header, []common.Address{member}, engine.registeredPQKeys(parent, statedb, 10)); err != nil {
+		t.Fatalf("failed to read updates: %v", err)
+	}
+	header.Extra = append(header.Extra, encodeKeyUpdates(updates)...)
+	header.Extra = append(header.Extra, (&pqSeal{algorithm: params.MLDSA44_ID, signature: make([]byte, 2420)}).encode()...)
+	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
+
+	next, err := snap.apply([]*types.Header{header}, &pqTestChain{config: &config}, nil)
+	if err != nil {
+		t.Fatalf("failed to apply header: %v", err)
+	}
+	if key := next.PQKeys[member]; key == nil || string(key.PublicKey) != string(memberKey) {
+		t.Errorf("registered key not installed")
+	}
+	if _, ok := next.Validators[unkeyed]; ok {
+		t.Errorf("validator set not updated")
+	}
+}
+
+// Tests that keys announced in headers are ignored once keys come from the
+// registry, even during the PQT transition.
+func TestPQKeyAnnouncementAfterRegistry(t *testing.T) {
+	c, chain, snap := newPQTestEngine(t)
+	chain.config.PostQuantum.PQKeyRegistryBlock = big.NewInt(1)
+
+	signer := c.pqSigners[0]
+	if err := c.preparePQSeal(snap, &types.Header{Number: big.NewInt(1), Extra: make([]byte, extraVanity)}); err != errUnknownPQKey {
+		t.Fatalf("unregistered key: have %v, want %v", err, errUnknownPQKey)
+	}
+	// Craft a header announcing the key, authenticated by its secp256k1 seal
+	sigSize, _, _ := mldsa.GetMLDSALengths(mldsa.MLDSA44)
+	header := &types.Header{
+		Number:     big.NewInt(1),
+		Coinbase:   c.validator,
+		Difficulty: new(big.Int).Set(diffInTurn),
+		Extra:      append(make([]byte, extraVanity), encodeKeyUpdates(nil)...),
+	}
+	seal := &pqSeal{algorithm: signer.Key.Algorithm, publicKey: signer.Key.PublicKey, signature: make([]byte, sigSize)}
+	header.Extra = append(header.Extra, seal.encode()...)
+	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
+
+	sig, err := signer.SignFn(pqSealHash(header, extraSeal+seal.size()).Bytes())
+	if err != nil {
+		t.Fatalf("failed to sign header: %v", err)
+	}
+	decoded, _ := decodePQSeal(header)
+	copy(decoded.signature, sig)
+	if sig, err = c.signFn(accounts.Account{}, accounts.MimetypeCongress, CongressRLP(header)); err != nil {
+		t.Fatalf("failed to seal header: %v", err)
+	}
+	copy(header.Extra[len(header.Extra)-extraSeal:], sig)
+
+	if err := c.verifyPQSeal(snap, header); err != errUnknownPQKey {
+		t.Errorf("announced key: have %v, want %v", err, errUnknownPQKey)
+	}
+	next, err := snap.apply([]*types.Header{header}, chain, nil)
+	if err != nil {
+		t.Fatalf("failed to apply header: %v", err)
+	}
+	if key := next.PQKeys[c.validator]; key != nil {
+		t.Errorf("announced key installed: %x", key.PublicKey)
+	}
+}
+
+// Tests that a validator seals with the key it registered in the registry
+// system contract, which the epoch block activates, without announcing it.
+func TestRegisteredPQKeySeal(t *testing.T) {
+	var (
+		config       = newRegistryTestConfig()
+		c, chain, _  = newPQTestEngineWithConfig(t, config)
+		statedb, _   = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		signer       = c.pqSigners[0]
+		registration = &vm.PQRegistryKey{Algorithm: signer.Key.Algorithm, PublicKey: signer.Key.PublicKey}
+	)
+	proof, err := signer.SignFn(vm.PQKeyPossessionMessage(config.ChainID, c.validator, registration))
+	if err != nil {
+		t.Fatalf("failed to sign proof: %v", err)
+	}
+	input, err := pqKeyRegistryABI.Pack("registerKey", registration.Algorithm, registration.PublicKey, proof, uint8(0), []byte{}, []byte{})
+	if err != nil {
+		t.Fatalf("failed to pack input: %v", err)
+	}
+	if _, _, err := newRegistryTestEVM(config, statedb, 5).Call(vm.AccountRef(c.validator), vm.PQKeyRegistryAddress, input, 10_000_000, new(big.Int)); err != nil {
+		t.Fatalf("failed to register key: %v", err)
+	}
+	// The epoch block installs the key read from the contract
+	snap := newSnapshot(c.config, c.signatures, 9, common.Hash{}, []common.Address{c.validator})
+	header := &types.Header{
+		Number:     big.NewInt(10),
+		Coinbase:   c.validator,
+		Difficulty: new(big.Int).Set(diffInTurn),
+		Extra:      append(append(make([]byte, extraVanity), c.validator.Bytes()...), encodeCheckpoint(snap)...),
+	}
+	parent := &types.Header{Number: big.NewInt(9), Difficulty: new(big.Int)}
+	updates, err := pqKeyUpdates(snap, header, []common.Address{c.validator}, c.registeredPQKeys(parent, statedb, 10))
+	if err != nil || len(updates) != 1 {
+		t.Fatalf("registered key not activated: %v (%v)", updates, err)
+	}
+	header.Extra = append(header.Extra, encodeKeyUpdates(updates)...)
+	header.Extra = append(header.Extra, (&pqSeal{algorithm: params.MLDSA44_ID, signature: make([]byte, 2420)}).encode()...)
+	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
+
+	next, err := snap.apply([]*types.Header{header}, chain, nil)
+	if err != nil {
+		t.Fatalf("failed to apply header: %v", err)
+	}
+	// Blocks are sealed and verified with it from then on
+	sealed := sealPQTestHeader(t, c, next, 11)
+	if seal, _ := decodePQSeal(sealed); seal.publicKey != nil {
+		t.Errorf("registered key announced")
+	}
+	if err := c.verifyPQSeal(next, sealed); err != nil {
+		t.Errorf("failed to verify seal with the registered key: %v", err)
+	}
+}
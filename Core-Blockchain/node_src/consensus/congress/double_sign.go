@@ -0,0 +1,224 @@
+// Copyright 2024 The Splendor Authors
+// This file implements the detection of validators sealing two different
+// headers at the same height, and their reporting to the slashing contract.
+
+package congress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/congress/systemcontract"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	inmemorySeals = 8192 // Number of recent (validator, height) seals to keep in memory
+
+	doubleSignReportGas = 500000 // Gas limit of the reportDoubleSign transaction
+)
+
+// evidencePrefix is the database prefix of persisted double-sign evidence,
+// followed by the block number and the validator address.
+var evidencePrefix = []byte("congress-evidence-")
+
+// TxPool is the subset of the transaction pool used to submit the reports of
+// double-signing validators.
+type TxPool interface {
+	Nonce(addr common.Address) uint64
+	GasPrice() *big.Int
+	AddLocal(tx *types.Transaction) error
+}
+
+// sealKey identifies the seal of a validator at a given height.
+type sealKey struct {
+	validator common.Address
+	number    uint64
+}
+
+// sealRecord is the first header seen sealed by a validator at some height.
+type sealRecord struct {
+	hash      common.Hash // Hash of the header without its seals
+	sealHash  common.Hash // Hash signed by the secp256k1 seal
+	signature []byte      // The secp256k1 seal, nil if sealed with ML-DSA only
+}
+
+// DoubleSignEvidence is the proof that a validator sealed two different
+// headers at the same height, in the form accepted by the slashing contract.
+// The hashes are ordered so every node reports the same evidence.
+type DoubleSignEvidence struct {
+	Validator  common.Address `json:"validator"`
+	Number     uint64         `json:"number"`
+	SealHash1  common.Hash    `json:"sealHash1"`
+	SealHash2  common.Hash    `json:"sealHash2"`
+	Signature1 hexutil.Bytes  `json:"signature1"`
+	Signature2 hexutil.Bytes  `json:"signature2"`
+	Reported   *common.Hash   `json:"reported"` // Hash of the report transaction, if any
+}
+
+// evidenceKey returns the database key of the evidence against a validator at
+// the given height.
+func evidenceKey(validator common.Address, number uint64) []byte {
+	key := make([]byte, len(evidencePrefix)+8+common.AddressLength)
+	copy(key, evidencePrefix)
+	binary.BigEndian.PutUint64(key[len(evidencePrefix):], number)
+	copy(key[len(evidencePrefix)+8:], validator.Bytes())
+	return key
+}
+
+// loadEvidence retrieves the evidence against a validator at the given height
+// from the database.
+func (c *Congress) loadEvidence(validator common.Address, number uint64) *DoubleSignEvidence {
+	if c.db == nil {
+		return nil
+	}
+	blob, err := c.db.Get(evidenceKey(validator, number))
+	if err != nil {
+		return nil
+	}
+	evidence := new(DoubleSignEvidence)
+	if err := json.Unmarshal(blob, evidence); err != nil {
+		return nil
+	}
+	return evidence
+}
+
+// storeEvidence writes the evidence into the database.
+func (c *Congress) storeEvidence(evidence *DoubleSignEvidence) {
+	if c.db == nil {
+		return
+	}
+	blob, err := json.Marshal(evidence)
+	if err != nil {
+		return
+	}
+	if err := c.db.Put(evidenceKey(evidence.Validator, evidence.Number), blob); err != nil {
+		log.Error("Failed to store double-sign evidence", "validator", evidence.Validator, "number", evidence.Number, "err", err)
+	}
+}
+
+// evidences returns all the double-sign evidence in the database.
+func (c *Congress) evidences() []*DoubleSignEvidence {
+	if c.db == nil {
+		return nil
+	}
+	it := c.db.NewIterator(evidencePrefix, nil)
+	defer it.Release()
+
+	var evidences []*DoubleSignEvidence
+	for it.Next() {
+		evidence := new(DoubleSignEvidence)
+		if err := json.Unmarshal(it.Value(), evidence); err == nil {
+			evidences = append(evidences, evidence)
+		}
+	}
+	return evidences
+}
+
+// checkDoubleSign records the seal of a verified header and checks it against
+// the header previously sealed by the same validator at the same height. If
+// they differ, the evidence is persisted and reported to the slashing contract.
+func (c *Congress) checkDoubleSign(header *types.Header, validator common.Address) {
+	record := &sealRecord{hash: c.SealHash(header)}
+	if !c.chainConfig.IsPQTEnforced(header.Number) {
+		record.sealHash = SealHash(header)
+		record.signature = common.CopyBytes(header.Extra[len(header.Extra)-extraSeal:])
+	}
+	key := sealKey{validator: validator, number: header.Number.Uint64()}
+	if ok, _ := c.seals.ContainsOrAdd(key, record); !ok {
+		return
+	}
+	prev, ok := c.seals.Get(key)
+	if !ok || prev.(*sealRecord).hash == record.hash {
+		return
+	}
+	first := prev.(*sealRecord)
+	log.Warn("Validator sealed two headers at the same height", "validator", validator, "number", key.number,
+		"first", first.hash, "second", record.hash)
+
+	c.evidenceLock.Lock()
+	defer c.evidenceLock.Unlock()
+
+	if c.loadEvidence(key.validator, key.number) != nil {
+		return
+	}
+	evidence := &DoubleSignEvidence{Validator: key.validator, Number: key.number}
+	if bytes.Compare(first.sealHash[:], record.sealHash[:]) > 0 {
+		first, record = record, first
+	}
+	evidence.SealHash1, evidence.Signature1 = first.sealHash, first.signature
+	evidence.SealHash2, evidence.Signature2 = record.sealHash, record.signature
+	c.storeEvidence(evidence)
+
+	// The slashing contract can only verify secp256k1 seals
+	if first.signature == nil || record.signature == nil {
+		return
+	}
+	c.lock.RLock()
+	reporter := c.signTxFn != nil && c.txPool != nil
+	c.lock.RUnlock()
+
+	if reporter {
+		go c.reportDoubleSign(evidence)
+	}
+}
+
+// reportDoubleSign submits the evidence to the slashing contract from the local
+// validator account.
+func (c *Congress) reportDoubleSign(evidence *DoubleSignEvidence) {
+	c.lock.RLock()
+	validator, signTxFn, pool := c.validator, c.signTxFn, c.txPool
+	c.lock.RUnlock()
+
+	// Only validators report, and not against themselves
+	if signTxFn == nil || pool == nil || validator == evidence.Validator {
+		return
+	}
+	data, err := c.abi[systemcontract.SlashingContractName].Pack("reportDoubleSign",
+		new(big.Int).SetUint64(evidence.Number), evidence.SealHash1, evidence.SealHash2,
+		[]byte(evidence.Signature1), []byte(evidence.Signature2))
+	if err != nil {
+		log.Error("Can't pack data for reportDoubleSign", "err", err)
+		return
+	}
+	gasPrice := pool.GasPrice()
+	if c.chain != nil {
+		if head := c.chain.CurrentHeader(); head != nil && head.BaseFee != nil {
+			if fee := new(big.Int).Mul(head.BaseFee, big.NewInt(2)); fee.Cmp(gasPrice) > 0 {
+				gasPrice = fee
+			}
+		}
+	}
+	tx := types.NewTransaction(pool.Nonce(validator), systemcontract.SlashingContractAddr, new(big.Int), doubleSignReportGas, gasPrice, data)
+	tx, err = signTxFn(accounts.Account{Address: validator}, tx, c.chainConfig.ChainID)
+	if err != nil {
+		log.Error("Failed to sign double-sign report", "err", err)
+		return
+	}
+	if err := pool.AddLocal(tx); err != nil {
+		log.Error("Failed to submit double-sign report", "validator", evidence.Validator, "number", evidence.Number, "err", err)
+		return
+	}
+	log.Info("Reported double-signing validator", "validator", evidence.Validator, "number", evidence.Number, "tx", tx.Hash())
+
+	c.evidenceLock.Lock()
+	defer c.evidenceLock.Unlock()
+
+	hash := tx.Hash()
+	evidence.Reported = &hash
+	c.storeEvidence(evidence)
+}
+
+// SetTxPool sets the transaction pool used to submit double-sign reports.
+func (c *Congress) SetTxPool(pool TxPool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.txPool = pool
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 The Splendor Authors
+// Tests of the double-sign detection of Congress validators
+
+package congress
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/congress/systemcontract"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// reportTestPool is a transaction pool collecting the submitted reports.
+type reportTestPool struct{ txs []*types.Transaction }
+
+func (p *reportTestPool) Nonce(common.Address) uint64 { return uint64(len(p.txs)) }
+func (p *reportTestPool) GasPrice() *big.Int          { return big.NewInt(params.GWei) }
+func (p *reportTestPool) AddLocal(tx *types.Transaction) error {
+	p.txs = append(p.txs, tx)
+	return nil
+}
+
+func TestDoubleSignDetection(t *testing.T) {
+	config := *params.AllCliqueProtocolChanges
+	config.Clique = nil
+	config.Congress = &params.CongressConfig{Period: 1, Epoch: 30000}
+	c := New(&config, rawdb.NewMemoryDatabase())
+
+	key, _ := crypto.GenerateKey()
+	validator := crypto.PubkeyToAddress(key.PublicKey)
+	seal := func(gasUsed uint64) *types.Header {
+		header := &types.Header{
+			Number:     big.NewInt(5),
+			Coinbase:   validator,
+			Difficulty: new(big.Int).Set(diffInTurn),
+			GasUsed:    gasUsed,
+			Extra:      make([]byte, extraVanity+extraSeal),
+		}
+		sig, err := crypto.Sign(SealHash(header).Bytes(), key)
+		if err != nil {
+			t.Fatalf("failed to sign header: %v", err)
+		}
+		copy(header.Extra[extraVanity:], sig)
+		return header
+	}
+	first, second := seal(1), seal(2)
+
+	// Seeing the same header again isn't double-signing
+	c.checkDoubleSign(first, validator)
+	c.checkDoubleSign(first, validator)
+	if evidences := c.evidences(); len(evidences) != 0 {
+		t.Fatalf("evidence for a single header: %v", evidences)
+	}
+	c.checkDoubleSign(second, validator)
+	evidences := c.evidences()
+	if len(evidences) != 1 {
+		t.Fatalf("evidence count mismatch: have %d, want 1", len(evidences))
+	}
+	evidence := evidences[0]
+	if evidence.Validator != validator || evidence.Number != 5 {
+		t.Errorf("evidence mismatch: have %x/%d, want %x/5", evidence.Validator, evidence.Number, validator)
+	}
+	if bytes.Compare(evidence.SealHash1[:], evidence.SealHash2[:]) >= 0 {
+		t.Errorf("evidence hashes not ordered")
+	}
+	// The evidence must verify the way the slashing contract checks it
+	for i, proof := range [][2][]byte{{evidence.SealHash1[:], evidence.Signature1}, {evidence.SealHash2[:], evidence.Signature2}} {
+		pubkey, err := crypto.SigToPub(proof[0], proof[1])
+		if err != nil || crypto.PubkeyToAddress(*pubkey) != validator {
+			t.Errorf("evidence %d doesn't recover the validator: %v", i, err)
+		}
+	}
+	// A validator reports the evidence to the slashing contract
+	reporter, _ := crypto.GenerateKey()
+	pool := new(reportTestPool)
+	c.SetTxPool(pool)
+	c.Authorize(crypto.PubkeyToAddress(reporter.PublicKey), nil, func(_ accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+		return types.SignTx(tx, types.NewEIP155Signer(chainID), reporter)
+	})
+	c.reportDoubleSign(evidence)
+
+	if len(pool.txs) != 1 {
+		t.Fatalf("report count mismatch: have %d, want 1", len(pool.txs))
+	}
+	if to := pool.txs[0].To(); to == nil || *to != systemcontract.SlashingContractAddr {
+		t.Errorf("report sent to %v", to)
+	}
+	slashing := c.abi[systemcontract.SlashingContractName]
+	method, err := slashing.MethodById(pool.txs[0].Data())
+	if err != nil || method.Name != "reportDoubleSign" {
+		t.Errorf("report calls %v (%v)", method, err)
+	}
+	if stored := c.loadEvidence(validator, 5); stored == nil || stored.Reported == nil || *stored.Reported != pool.txs[0].Hash() {
+		t.Errorf("report not recorded")
+	}
+	// Validators don't report themselves
+	c.Authorize(validator, nil, c.signTxFn)
+	c.reportDoubleSign(evidence)
+	if len(pool.txs) != 1 {
+		t.Errorf("validator reported itself")
+	}
+}
@@ -40,6 +40,17 @@ type Snapshot struct {
 	Hash       common.Hash                 `json:"hash"`       // Block hash where the snapshot was created
 	Validators map[common.Address]struct{} `json:"validators"` // Set of authorized validators at this moment
 	Recents    map[uint64]common.Address   `json:"recents"`    // Set of recent validators for spam protections
+	PQKeys     map[common.Address]*PQKey   `json:"pqKeys"`     // ML-DSA keys announced or registered by the validators
+	Finalized  *FinalizedBlock             `json:"finalized"`  // Latest block finalized by the validators' votes
+
+	BeaconCommits map[common.Address]common.Hash `json:"beaconCommits"` // Random beacon commitments of the validators
+	BeaconMix     common.Hash                    `json:"beaconMix"`     // Random beacon mixed from the revealed secrets
+}
+
+// FinalizedBlock identifies a block finalized by a supermajority of validators.
+type FinalizedBlock struct {
+	Number uint64      `json:"number"`
+	Hash   common.Hash `json:"hash"`
 }
 
 // validatorsAscending implements the sort interface to allow sorting a list of addresses
@@ -60,6 +71,9 @@ func newSnapshot(config *params.CongressConfig, sigcache *lru.ARCCache, number u
 		Hash:       hash,
 		Validators: make(map[common.Address]struct{}),
 		Recents:    make(map[uint64]common.Address),
+		PQKeys:     make(map[common.Address]*PQKey),
+
+		BeaconCommits: make(map[common.Address]common.Hash),
 	}
 	for _, validator := range validators {
 		snap.Validators[validator] = struct{}{}
@@ -79,6 +93,12 @@ func loadSnapshot(config *params.CongressConfig, sigcache *lru.ARCCache, db ethd
 	}
 	snap.config = config
 	snap.sigcache = sigcache
+	if snap.PQKeys == nil {
+		snap.PQKeys = make(map[common.Address]*PQKey)
+	}
+	if snap.BeaconCommits == nil {
+		snap.BeaconCommits = make(map[common.Address]common.Hash)
+	}
 
 	return snap, nil
 }
@@ -101,6 +121,11 @@ func (s *Snapshot) copy() *Snapshot {
 		Hash:       s.Hash,
 		Validators: make(map[common.Address]struct{}),
 		Recents:    make(map[uint64]common.Address),
+		PQKeys:     make(map[common.Address]*PQKey),
+		Finalized:  s.Finalized,
+
+		BeaconCommits: make(map[common.Address]common.Hash),
+		BeaconMix:     s.BeaconMix,
 	}
 	for validator := range s.Validators {
 		cpy.Validators[validator] = struct{}{}
@@ -108,6 +133,12 @@ func (s *Snapshot) copy() *Snapshot {
 	for block, validator := range s.Recents {
 		cpy.Recents[block] = validator
 	}
+	for validator, key := range s.PQKeys {
+		cpy.PQKeys[validator] = key
+	}
+	for validator, commitment := range s.BeaconCommits {
+		cpy.BeaconCommits[validator] = commitment
+	}
 
 	return cpy
 }
@@ -139,7 +170,7 @@ func (s *Snapshot) apply(headers []*types.Header, chain consensus.ChainHeaderRea
 			delete(snap.Recents, number-limit)
 		}
 		// Resolve the authorization key and check against validators
-		validator, err := ecrecover(header, s.sigcache)
+		validator, err := sealer(chain.Config(), header, s.sigcache)
 		if err != nil {
 			return nil, err
 		}
@@ -153,12 +184,18 @@ func (s *Snapshot) apply(headers []*types.Header, chain consensus.ChainHeaderRea
 		}
 		snap.Recents[number] = validator
 
+		// Apply the consensus data the header carries besides the validators
+		sealLen, err := snap.applyExtra(chain.Config(), header, validator)
+		if err != nil {
+			return nil, err
+		}
+
 		// update validators at the first block at epoch
 		if number > 0 && number%s.config.Epoch == 0 {
 			checkpointHeader := header
 
 			// get validators from headers and use that for new validator set
-			validators := make([]common.Address, (len(checkpointHeader.Extra)-extraVanity-extraSeal)/common.AddressLength)
+			validators := make([]common.Address, (len(checkpointHeader.Extra)-extraVanity-sealLen)/common.AddressLength)
 			for i := 0; i < len(validators); i++ {
 				copy(validators[i][:], checkpointHeader.Extra[extraVanity+i*common.AddressLength:])
 			}
@@ -309,6 +346,69 @@ func (s *Snapshot) apply(headers []*types.Header, chain consensus.ChainHeaderRea
 	return snap, nil
 }
 
+// applyExtra updates the snapshot with the consensus data in the extra-data of
+// a header sealed by the given validator, returning the number of bytes that
+// follow the validator list.
+func (s *Snapshot) applyExtra(config *params.ChainConfig, header *types.Header, validator common.Address) (int, error) {
+	// Record the ML-DSA key announced in the header, if any. Once keys come
+	// from the PQ key registry, announcements are ignored.
+	sealLen := extraSeal
+	if config.IsPQTFork(header.Number) {
+		seal, err := decodePQSeal(header)
+		if err != nil {
+			return 0, err
+		}
+		if seal.publicKey != nil && !config.IsPQKeyRegistry(header.Number) {
+			s.PQKeys[validator] = &PQKey{Algorithm: seal.algorithm, PublicKey: common.CopyBytes(seal.publicKey)}
+		}
+		sealLen += seal.size()
+	}
+	// Advance the finalized block if the header attests a newer one. The
+	// attestation precedes the seals in the extra-data.
+	if config.IsFastFinality(header.Number) {
+		att, size, err := decodeAttestation(header, sealLen)
+		if err != nil {
+			return 0, err
+		}
+		if att != nil && (s.Finalized == nil || att.Number > s.Finalized.Number) {
+			s.Finalized = &FinalizedBlock{Number: att.Number, Hash: att.Hash}
+		}
+		sealLen += size
+	}
+	// Track the random beacon commitments and reveals, which precede the
+	// attestation in the extra-data.
+	if config.IsRandomBeacon(header.Number) {
+		data, err := decodeBeacon(config, header, sealLen)
+		if err != nil {
+			return 0, err
+		}
+		data.apply(s, validator)
+		sealLen += beaconSize(config, header)
+	}
+	// Install the registered keys activated by epoch blocks, which precede
+	// the beacon data in the extra-data.
+	if config.IsPQKeyRegistry(header.Number) {
+		updates, size, err := decodeKeyUpdates(header, sealLen)
+		if err != nil {
+			return 0, err
+		}
+		for _, update := range updates {
+			s.PQKeys[update.Validator] = update.key()
+		}
+		sealLen += size
+	}
+	// Skip the snapshot state carried by epoch blocks, which precedes the
+	// key updates in the extra-data.
+	if isCheckpointBlock(config, header.Number) {
+		_, size, err := decodeCheckpoint(header, sealLen)
+		if err != nil {
+			return 0, err
+		}
+		sealLen += size
+	}
+	return sealLen, nil
+}
+
 // validators retrieves the list of authorized validators in ascending order.
 func (s *Snapshot) validators() []common.Address {
 	sigs := make([]common.Address, 0, len(s.Validators))
@@ -2687,6 +2687,43 @@ const PunishV1InteractiveABI = `[
     }
 ]`
 
+// SlashingInteractiveABI contains the methods of the slashing contract used to report misbehaving validators.
+const SlashingInteractiveABI = `[
+    {
+      "inputs": [
+        {
+          "internalType": "uint256",
+          "name": "blockNumber",
+          "type": "uint256"
+        },
+        {
+          "internalType": "bytes32",
+          "name": "blockHash1",
+          "type": "bytes32"
+        },
+        {
+          "internalType": "bytes32",
+          "name": "blockHash2",
+          "type": "bytes32"
+        },
+        {
+          "internalType": "bytes",
+          "name": "signature1",
+          "type": "bytes"
+        },
+        {
+          "internalType": "bytes",
+          "name": "signature2",
+          "type": "bytes"
+        }
+      ],
+      "name": "reportDoubleSign",
+      "outputs": [],
+      "stateMutability": "nonpayable",
+      "type": "function"
+    }
+]`
+
 // DevMappingPosition is the position of the state variable `devs`.
 // Since the state variables are as follow:
 //    bool public initialized;
@@ -2726,6 +2763,7 @@ var (
 	AddressListContractName  = "address_list"
 	ValidatorsV1ContractName = "validators_v1"
 	PunishV1ContractName     = "punish_v1"
+	SlashingContractName     = "slashing"
 	ValidatorsContractAddr   = common.HexToAddress("0x000000000000000000000000000000000000f000")
 	PunishContractAddr       = common.HexToAddress("0x000000000000000000000000000000000000f001")
 	ProposalAddr             = common.HexToAddress("0x000000000000000000000000000000000000f002")
@@ -2733,6 +2771,7 @@ var (
 	AddressListContractAddr  = common.HexToAddress("0x000000000000000000000000000000000000F004")
 	ValidatorsV1ContractAddr = common.HexToAddress("0x000000000000000000000000000000000000F005")
 	PunishV1ContractAddr     = common.HexToAddress("0x000000000000000000000000000000000000F006")
+	SlashingContractAddr     = common.HexToAddress("0x000000000000000000000000000000000000F007")
 	// SysGovToAddr is the To address for the system governance transaction, NOT contract address
 	SysGovToAddr = common.HexToAddress("0x000000000000000000000000000000000000ffff")
 
@@ -2756,6 +2795,8 @@ func init() {
 	abiMap[ValidatorsV1ContractName] = tmpABI
 	tmpABI, _ = abi.JSON(strings.NewReader(PunishV1InteractiveABI))
 	abiMap[PunishV1ContractName] = tmpABI
+	tmpABI, _ = abi.JSON(strings.NewReader(SlashingInteractiveABI))
+	abiMap[SlashingContractName] = tmpABI
 }
 
 func GetInteractiveABI() map[string]abi.ABI {
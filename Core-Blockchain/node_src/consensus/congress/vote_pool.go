@@ -0,0 +1,267 @@
+// Copyright 2024 The Splendor Authors
+// This file implements the pool of finality votes gossiped by the validators
+
+package congress
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const maxFutureVotes = 16 // Maximum distance of the voted blocks ahead of the local head
+
+// lastVoteKey is the database key of the local validator's latest vote, which
+// makes sure it never votes twice on the same height, even across restarts.
+var lastVoteKey = []byte("congress-last-vote")
+
+var (
+	// errVoteBeforeFork is returned if a vote is cast on a block before the fast
+	// finality fork.
+	errVoteBeforeFork = errors.New("vote before fast finality fork")
+
+	// errStaleVote is returned if a vote is cast on a block too old to be
+	// attested anymore.
+	errStaleVote = errors.New("stale vote")
+
+	// errFutureVote is returned if a vote is cast on a block too far ahead of the
+	// local head.
+	errFutureVote = errors.New("vote too far in the future")
+
+	// errMissingChain is returned if votes are handled before the chain is set.
+	errMissingChain = errors.New("congress chain not set")
+)
+
+// voteSet is the set of votes on a block.
+type voteSet struct {
+	number uint64
+	hash   common.Hash
+	votes  map[common.Address]*types.Vote
+}
+
+// votePool keeps the votes on recent blocks until they are aggregated into an
+// attestation.
+type votePool struct {
+	blocks map[common.Hash]*voteSet
+	lock   sync.RWMutex
+
+	feed  event.Feed
+	scope event.SubscriptionScope
+}
+
+// newVotePool creates an empty vote pool.
+func newVotePool() *votePool {
+	return &votePool{blocks: make(map[common.Hash]*voteSet)}
+}
+
+// add inserts a vote cast by the given validator, returning whether it's new.
+func (p *votePool) add(vote *types.Vote, signer common.Address) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	set, ok := p.blocks[vote.BlockHash]
+	if !ok {
+		set = &voteSet{number: vote.BlockNumber, hash: vote.BlockHash, votes: make(map[common.Address]*types.Vote)}
+		p.blocks[vote.BlockHash] = set
+	}
+	if _, ok := set.votes[signer]; ok {
+		return false
+	}
+	set.votes[signer] = vote
+	return true
+}
+
+// prune drops the votes on blocks below the given number.
+func (p *votePool) prune(number uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for hash, set := range p.blocks {
+		if set.number < number {
+			delete(p.blocks, hash)
+		}
+	}
+}
+
+// sets returns a copy of the vote sets, newest block first.
+func (p *votePool) sets() []*voteSet {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	sets := make([]*voteSet, 0, len(p.blocks))
+	for _, set := range p.blocks {
+		cpy := &voteSet{number: set.number, hash: set.hash, votes: make(map[common.Address]*types.Vote, len(set.votes))}
+		for signer, vote := range set.votes {
+			cpy.votes[signer] = vote
+		}
+		sets = append(sets, cpy)
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i].number > sets[j].number })
+	return sets
+}
+
+// AddVote validates a vote received from the network or cast locally and adds
+// it to the pool, announcing it to the subscribers if it's new.
+func (c *Congress) AddVote(vote *types.Vote) error {
+	if c.chain == nil {
+		return errMissingChain
+	}
+	if !c.chainConfig.IsFastFinality(new(big.Int).SetUint64(vote.BlockNumber)) {
+		return errVoteBeforeFork
+	}
+	head := c.chain.CurrentHeader()
+	if vote.BlockNumber+maxAttestationAge < head.Number.Uint64() {
+		return errStaleVote
+	}
+	if vote.BlockNumber > head.Number.Uint64()+maxFutureVotes {
+		return errFutureVote
+	}
+	signer, err := vote.Signer()
+	if err != nil {
+		return err
+	}
+	snap, err := c.snapshot(c.chain, head.Number.Uint64(), head.Hash(), nil)
+	if err != nil {
+		return err
+	}
+	if _, ok := snap.Validators[signer]; !ok {
+		return errUnauthorizedValidator
+	}
+	if c.votes.add(vote, signer) {
+		if head.Number.Uint64() > maxAttestationAge {
+			c.votes.prune(head.Number.Uint64() - maxAttestationAge)
+		}
+		c.votes.feed.Send(core.NewVoteEvent{Vote: vote})
+	}
+	return nil
+}
+
+// Votes returns all the votes in the pool.
+func (c *Congress) Votes() []*types.Vote {
+	var votes []*types.Vote
+	for _, set := range c.votes.sets() {
+		for _, vote := range set.votes {
+			votes = append(votes, vote)
+		}
+	}
+	return votes
+}
+
+// SubscribeNewVoteEvent registers a subscription for the votes added to the pool.
+func (c *Congress) SubscribeNewVoteEvent(ch chan<- core.NewVoteEvent) event.Subscription {
+	return c.votes.scope.Track(c.votes.feed.Subscribe(ch))
+}
+
+// CastVote votes for a newly imported head block if the local node is an active
+// validator. A validator votes at most once per height, on increasing heights,
+// and only on descendants of its previous vote while that can still be attested,
+// so that conflicting blocks can't both gather a supermajority unless more than
+// a third of the validators misbehave.
+func (c *Congress) CastVote(header *types.Header) error {
+	if c.chain == nil {
+		return errMissingChain
+	}
+	if !c.chainConfig.IsFastFinality(header.Number) {
+		return nil
+	}
+	c.lock.RLock()
+	validator, signFn := c.validator, c.signFn
+	c.lock.RUnlock()
+
+	if signFn == nil {
+		return nil
+	}
+	number := header.Number.Uint64()
+	snap, err := c.snapshot(c.chain, number, header.Hash(), nil)
+	if err != nil {
+		return err
+	}
+	if _, ok := snap.Validators[validator]; !ok {
+		return nil
+	}
+	c.voteLock.Lock()
+	defer c.voteLock.Unlock()
+
+	last := c.lastVote()
+	if last != nil {
+		if number <= last.BlockNumber {
+			return nil
+		}
+		if last.BlockNumber+maxAttestationAge >= number && ancestorHash(c.chain, header, last.BlockNumber, nil) != last.BlockHash {
+			return nil
+		}
+	}
+	vote := types.NewVote(number, header.Hash())
+	vote.Signature, err = signFn(accounts.Account{Address: validator}, accounts.MimetypeCongressVote, vote.SigningData())
+	if err != nil {
+		return err
+	}
+	// Persist the vote before announcing it, so it's never contradicted
+	c.storeLastVote(vote)
+	log.Debug("Cast finality vote", "number", number, "hash", header.Hash())
+	return c.AddVote(vote)
+}
+
+// lastVote returns the latest vote of the local validator.
+func (c *Congress) lastVote() *types.Vote {
+	if c.latestVote == nil && c.db != nil {
+		if blob, err := c.db.Get(lastVoteKey); err == nil {
+			vote := new(types.Vote)
+			if err := rlp.DecodeBytes(blob, vote); err == nil {
+				c.latestVote = vote
+			}
+		}
+	}
+	return c.latestVote
+}
+
+// storeLastVote records the latest vote of the local validator.
+func (c *Congress) storeLastVote(vote *types.Vote) {
+	c.latestVote = vote
+	if c.db == nil {
+		return
+	}
+	blob, err := rlp.EncodeToBytes(vote)
+	if err != nil {
+		return
+	}
+	if err := c.db.Put(lastVoteKey, blob); err != nil {
+		log.Error("Failed to store finality vote", "err", err)
+	}
+}
+
+// SafeHeader returns the newest canonical block a supermajority of the current
+// validators voted for, which is unlikely to be reorged even before it's
+// finalized by an attestation.
+func (c *Congress) SafeHeader(chain consensus.ChainHeaderReader) *types.Header {
+	head := chain.CurrentHeader()
+	snap, err := c.snapshot(chain, head.Number.Uint64(), head.Hash(), nil)
+	if err != nil {
+		return nil
+	}
+	for _, set := range c.votes.sets() {
+		var votes int
+		for signer := range set.votes {
+			if _, ok := snap.Validators[signer]; ok {
+				votes++
+			}
+		}
+		if !supermajority(votes, len(snap.Validators)) {
+			continue
+		}
+		if header := chain.GetHeaderByNumber(set.number); header != nil && header.Hash() == set.hash {
+			return header
+		}
+	}
+	return nil
+}
@@ -160,6 +160,16 @@ type PoSA interface {
 	ApplySysTx(evm *vm.EVM, state *state.StateDB, txIndex int, sender common.Address, tx *types.Transaction) (ret []byte, vmerr error, err error)
 }
 
+// FinalityEngine is a consensus engine that finalizes blocks, which must never
+// be reorged out of the canonical chain.
+type FinalityEngine interface {
+	Engine
+
+	// FinalizedHeader returns the newest finalized block of the chain ending with
+	// the given header, or nil if none is known.
+	FinalizedHeader(chain ChainHeaderReader, header *types.Header) *types.Header
+}
+
 type StateReader interface {
 	GetState(addr common.Address, hash common.Hash) common.Hash
 }
@@ -0,0 +1,59 @@
+// Copyright 2024 The Splendor Authors
+// This file implements the commit-reveal random beacon run by the validators
+// Every validator commits to a secret derived from an ML-DSA signature when it
+// seals a block, and reveals it the next time it seals. The revealed secrets
+// are mixed into the beacon, which is recorded in the headers every
+// PQRandomBeaconInterval blocks.
+
+package pqconsensus
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// beaconSigningPrefix separates the data signed to derive beacon secrets
+	// from any other data signed by the validators' ML-DSA keys.
+	beaconSigningPrefix = []byte("splendor-random-beacon")
+
+	// beaconSecretPrefix separates beacon secrets from plain signature hashes.
+	beaconSecretPrefix = []byte("splendor-beacon-secret")
+)
+
+// BeaconSigningData returns the message a validator signs with its ML-DSA key
+// to derive the secret it commits to when sealing the given block.
+func BeaconSigningData(chainID *big.Int, number uint64) []byte {
+	data := make([]byte, 0, len(beaconSigningPrefix)+common.HashLength+8)
+	data = append(data, beaconSigningPrefix...)
+	data = append(data, common.BigToHash(chainID).Bytes()...)
+
+	var enc [8]byte
+	binary.BigEndian.PutUint64(enc[:], number)
+	return append(data, enc[:]...)
+}
+
+// BeaconSecret derives a beacon secret from an ML-DSA signature.
+func BeaconSecret(signature []byte) common.Hash {
+	return crypto.Keccak256Hash(beaconSecretPrefix, signature)
+}
+
+// BeaconCommitment returns the commitment to a beacon secret, which binds the
+// validator to the secret before anyone can know the beacon it contributes to.
+func BeaconCommitment(secret common.Hash) common.Hash {
+	return crypto.Keccak256Hash(secret[:])
+}
+
+// MixRandomBeacon mixes a revealed secret into the beacon.
+func MixRandomBeacon(mix common.Hash, secret common.Hash) common.Hash {
+	return crypto.Keccak256Hash(mix[:], secret[:])
+}
+
+// IsRandomBeaconBlock returns whether the header of the given block records the
+// random beacon.
+func IsRandomBeaconBlock(number uint64) bool {
+	return number > 0 && number%PQRandomBeaconInterval == 0
+}
@@ -6,8 +6,6 @@ package pqconsensus
 
 import (
 	"bytes"
-	"crypto/rand"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"sync"
@@ -15,9 +13,9 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/crypto/mldsa"
-	"github.com/ethereum/go-ethereum/crypto/mlkem"
 	"github.com/ethereum/go-ethereum/crypto/slhdsa"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
@@ -55,6 +53,14 @@ const (
 	PQSigTypeSLHDSA256F PQSignatureType = 0x15
 )
 
+// registryKeyTypes maps the algorithm identifiers of the PQ key registry to
+// signature types.
+var registryKeyTypes = map[byte]PQSignatureType{
+	params.MLDSA44_ID: PQSigTypeMLDSA44,
+	params.MLDSA65_ID: PQSigTypeMLDSA65,
+	params.MLDSA87_ID: PQSigTypeMLDSA87,
+}
+
 // PQValidatorKey represents a post-quantum validator key with metadata
 type PQValidatorKey struct {
 	Address       common.Address  // Validator address
@@ -91,11 +97,6 @@ type PQConsensusEngine struct {
 	aggregatedSigs  map[common.Hash]*PQSignatureBundle
 	sigMutex        sync.RWMutex
 
-	// Quantum-safe networking
-	kemKeys         map[common.Address][]byte // ML-KEM public keys for secure communication
-	sharedSecrets   map[common.Address][]byte // Cached shared secrets
-	networkMutex    sync.RWMutex
-
 	// Random beacon for quantum-safe randomness
 	randomBeacon    []byte
 	beaconMutex     sync.RWMutex
@@ -117,23 +118,27 @@ func NewPQConsensusEngine(config *params.PostQuantumConfig) *PQConsensusEngine {
 		keyRotationLog:    keyRotationLog,
 		signatureCache:    signatureCache,
 		aggregatedSigs:    make(map[common.Hash]*PQSignatureBundle),
-		kemKeys:           make(map[common.Address][]byte),
-		sharedSecrets:     make(map[common.Address][]byte),
 		randomBeacon:      make([]byte, 32),
 		verificationTimes: make(map[string]time.Duration),
 	}
 }
 
-// RegisterValidatorKey registers a post-quantum key for a validator
-func (pq *PQConsensusEngine) RegisterValidatorKey(validator common.Address, algorithm string, 
-	publicKey []byte, blockNumber uint64) error {
+// RegisterValidatorKey registers the post-quantum keys of a validator: its
+// ML-DSA key and optionally an SLH-DSA backup key. The keys are generated and
+// kept by the validator, which registers them in the PQ key registry.
+func (pq *PQConsensusEngine) RegisterValidatorKey(validator common.Address, algorithm string,
+	publicKey []byte, backupKey []byte, blockNumber uint64) error {
 	pq.keyMutex.Lock()
 	defer pq.keyMutex.Unlock()
 
 	// Validate the key
-	if err := mldsa.ValidateMLDSAParams(algorithm, make([]byte, 3309), publicKey); err != nil {
+	_, pkLen, err := mldsa.GetMLDSALengths(algorithm)
+	if err != nil {
 		return fmt.Errorf("invalid validator key: %v", err)
 	}
+	if len(publicKey) != pkLen {
+		return fmt.Errorf("invalid validator key: %v", mldsa.ErrInvalidLength)
+	}
 
 	// Determine signature type
 	var sigType PQSignatureType
@@ -148,13 +153,6 @@ func (pq *PQConsensusEngine) RegisterValidatorKey(validator common.Address, algo
 		return errors.New("unsupported algorithm")
 	}
 
-	// Generate backup key (SLH-DSA for long-term security)
-	backupKey, _, err := slhdsa.GenerateKeyPair(slhdsa.SLHDSA128S)
-	if err != nil {
-		log.Warn("Failed to generate backup key", "error", err)
-		backupKey = nil // Continue without backup key
-	}
-
 	validatorKey := &PQValidatorKey{
 		Address:       validator,
 		Algorithm:     algorithm,
@@ -168,12 +166,35 @@ func (pq *PQConsensusEngine) RegisterValidatorKey(validator common.Address, algo
 	pq.validatorKeys[validator] = validatorKey
 	pq.keyRotationLog.Add(blockNumber, validator)
 
-	log.Info("Registered PQ validator key", "validator", validator, "algorithm", algorithm, 
-		"keySize", len(publicKey), "expiry", validatorKey.ExpiryTime)
+	log.Info("Registered PQ validator key", "validator", validator, "algorithm", algorithm,
+		"keySize", len(publicKey), "backup", len(backupKey) > 0, "expiry", validatorKey.ExpiryTime)
 
 	return nil
 }
 
+// LoadRegisteredKeys registers the keys of the validators active in the PQ key
+// registry at the given block, replacing the ones they rotated.
+func (pq *PQConsensusEngine) LoadRegisteredKeys(state vm.StateDB, validators []common.Address, blockNumber uint64) error {
+	for _, validator := range validators {
+		key := vm.ReadPQKey(state, validator, blockNumber)
+		if key == nil {
+			continue
+		}
+		pq.keyMutex.RLock()
+		current := pq.validatorKeys[validator]
+		pq.keyMutex.RUnlock()
+
+		if current != nil && bytes.Equal(current.PublicKey, key.PublicKey) {
+			continue
+		}
+		algorithm := pq.getAlgorithmFromType(registryKeyTypes[key.Algorithm])
+		if err := pq.RegisterValidatorKey(validator, algorithm, key.PublicKey, key.BackupPublicKey, key.Activation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // VerifyPQSignatureBundle verifies a complete post-quantum signature bundle
 func (pq *PQConsensusEngine) VerifyPQSignatureBundle(bundle *PQSignatureBundle, 
 	message []byte, blockNumber uint64) error {
@@ -275,136 +296,60 @@ func (pq *PQConsensusEngine) verifyAggregationProof(bundle *PQSignatureBundle, m
 	return nil
 }
 
-// RotateValidatorKeys performs automatic key rotation for validators
+// RotateValidatorKeys reports the validator keys due for rotation. Validators
+// rotate their keys themselves by registering new ones in the PQ key registry,
+// which LoadRegisteredKeys picks up once they are active.
 func (pq *PQConsensusEngine) RotateValidatorKeys(blockNumber uint64) error {
-	pq.keyMutex.Lock()
-	defer pq.keyMutex.Unlock()
+	pq.keyMutex.RLock()
+	defer pq.keyMutex.RUnlock()
 
-	rotatedCount := 0
+	expiredCount := 0
 	for validator, key := range pq.validatorKeys {
 		if blockNumber >= key.ExpiryTime {
-			// Generate new key pair
-			newPublicKey, newSecretKey, err := mldsa.GenerateKeyPair(key.Algorithm)
-			if err != nil {
-				log.Error("Failed to rotate validator key", "validator", validator, "error", err)
-				continue
-			}
-
-			// Generate new backup key
-			newBackupKey, _, err := slhdsa.GenerateKeyPair(slhdsa.SLHDSA128S)
-			if err != nil {
-				log.Warn("Failed to generate backup key during rotation", "validator", validator, "error", err)
-				newBackupKey = nil
-			}
-
-			// Update key
-			key.PublicKey = newPublicKey
-			key.BackupKey = newBackupKey
-			key.KeyGenTime = blockNumber
-			key.ExpiryTime = blockNumber + PQKeyRotationInterval
-
-			// Log rotation
-			pq.keyRotationLog.Add(blockNumber, validator)
-			rotatedCount++
-
-			log.Info("Rotated validator key", "validator", validator, "newExpiry", key.ExpiryTime)
-
-			// In a real implementation, this would need to be coordinated
-			// with the validator to update their signing key
-			_ = newSecretKey // Placeholder for key distribution
+			log.Warn("Validator key due for rotation", "validator", validator, "expiry", key.ExpiryTime)
+			expiredCount++
 		}
 	}
 
-	if rotatedCount > 0 {
-		log.Info("Completed key rotation", "rotatedKeys", rotatedCount, "block", blockNumber)
+	if expiredCount > 0 {
+		log.Info("Validator keys must be rotated through the PQ key registry", "expiredKeys", expiredCount, "block", blockNumber)
 	}
 
 	return nil
 }
 
-// EstablishQuantumSafeChannel establishes a quantum-safe communication channel
-func (pq *PQConsensusEngine) EstablishQuantumSafeChannel(peerAddress common.Address) error {
-	pq.networkMutex.Lock()
-	defer pq.networkMutex.Unlock()
-
-	// Check if we already have a shared secret
-	if _, exists := pq.sharedSecrets[peerAddress]; exists {
-		return nil // Channel already established
-	}
-
-	// Generate ML-KEM key pair for this peer
-	publicKey, secretKey, err := mlkem.GenerateKeyPair(mlkem.MLKEM768)
-	if err != nil {
-		return fmt.Errorf("failed to generate ML-KEM key pair: %v", err)
-	}
-
-	// Store our public key
-	pq.kemKeys[peerAddress] = publicKey
-
-	// In a real implementation, this would involve:
-	// 1. Exchanging ML-KEM public keys with the peer
-	// 2. Performing key encapsulation
-	// 3. Deriving shared secret
-	// 4. Setting up encrypted communication channel
-
-	// For now, create a placeholder shared secret
-	sharedSecret := crypto.Keccak256(publicKey, secretKey[:32])
-	pq.sharedSecrets[peerAddress] = sharedSecret
-
-	log.Info("Established quantum-safe channel", "peer", peerAddress, "algorithm", mlkem.MLKEM768)
-	return nil
-}
-
-// GenerateQuantumRandomBeacon generates quantum-safe randomness for the network
-func (pq *PQConsensusEngine) GenerateQuantumRandomBeacon(blockNumber uint64, 
+// GenerateQuantumRandomBeacon derives the random beacon of a beacon block by
+// mixing the secrets behind the validators' ML-DSA signatures, in the order
+// they were revealed, into the previous beacon. The result only depends on its
+// inputs, so every node computes the same beacon.
+func (pq *PQConsensusEngine) GenerateQuantumRandomBeacon(blockNumber uint64,
 	previousBeacon []byte, validatorSignatures [][]byte) ([]byte, error) {
-	
+
 	pq.beaconMutex.Lock()
 	defer pq.beaconMutex.Unlock()
 
 	// Only generate beacon at specified intervals
-	if blockNumber%PQRandomBeaconInterval != 0 {
+	if !IsRandomBeaconBlock(blockNumber) {
 		return pq.randomBeacon, nil
 	}
+	beacon := computeRandomBeacon(previousBeacon, validatorSignatures)
 
-	// Combine multiple sources of entropy
-	hasher := crypto.NewKeccakState()
-	
-	// Previous beacon
-	hasher.Write(previousBeacon)
-	
-	// Block number
-	blockBytes := make([]byte, 8)
-	binary.BigEndian.PutUint64(blockBytes, blockNumber)
-	hasher.Write(blockBytes)
-	
-	// Validator signatures (provides distributed randomness)
-	for _, sig := range validatorSignatures {
-		hasher.Write(sig)
-	}
-	
-	// System entropy
-	systemEntropy := make([]byte, 32)
-	rand.Read(systemEntropy)
-	hasher.Write(systemEntropy)
-	
-	// Current timestamp
-	timestamp := time.Now().UnixNano()
-	timestampBytes := make([]byte, 8)
-	binary.BigEndian.PutUint64(timestampBytes, uint64(timestamp))
-	hasher.Write(timestampBytes)
-
-	// Generate new beacon
-	newBeacon := make([]byte, 32)
-	hasher.Read(newBeacon)
-	
-	pq.randomBeacon = newBeacon
+	pq.randomBeacon = beacon.Bytes()
 	pq.lastBeaconBlock = blockNumber
 
-	log.Info("Generated quantum random beacon", "block", blockNumber, 
-		"beacon", common.Bytes2Hex(newBeacon[:8]))
+	log.Info("Generated quantum random beacon", "block", blockNumber,
+		"beacon", common.Bytes2Hex(beacon[:8]))
+
+	return beacon.Bytes(), nil
+}
 
-	return newBeacon, nil
+// computeRandomBeacon mixes the secrets behind the signatures into a beacon.
+func computeRandomBeacon(previousBeacon []byte, validatorSignatures [][]byte) common.Hash {
+	mix := common.BytesToHash(previousBeacon)
+	for _, sig := range validatorSignatures {
+		mix = MixRandomBeacon(mix, BeaconSecret(sig))
+	}
+	return mix
 }
 
 // AggregateSignatures performs signature aggregation for efficiency
@@ -465,12 +410,8 @@ func (pq *PQConsensusEngine) VerifyQuantumSafeBlock(block *types.Block,
 		}
 	}
 
-	// Verify quantum random beacon if present
-	if blockNumber%PQRandomBeaconInterval == 0 {
-		if err := pq.verifyQuantumRandomBeacon(header, parentBlock); err != nil {
-			return fmt.Errorf("quantum random beacon verification failed: %v", err)
-		}
-	}
+	// The random beacon recorded in the header is verified by the sealing
+	// engine, which tracks the commitments and reveals of the validators
 
 	// Perform key rotation if needed
 	if err := pq.RotateValidatorKeys(blockNumber); err != nil {
@@ -521,20 +462,17 @@ func (pq *PQConsensusEngine) calculateSealHash(header *types.Header) common.Hash
 	return hash
 }
 
-// verifyQuantumRandomBeacon verifies the quantum random beacon
-func (pq *PQConsensusEngine) verifyQuantumRandomBeacon(header *types.Header, 
-	parentBlock *types.Block) error {
-	
-	// Extract beacon from header (would be in a specific field)
-	// For now, just validate that we have a beacon
-	if len(pq.randomBeacon) != 32 {
+// VerifyQuantumRandomBeacon checks that a beacon is the one derived from the
+// previous beacon and the validators' signatures.
+func (pq *PQConsensusEngine) VerifyQuantumRandomBeacon(blockNumber uint64, previousBeacon []byte,
+	validatorSignatures [][]byte, beacon []byte) error {
+
+	if !IsRandomBeaconBlock(blockNumber) {
+		return fmt.Errorf("block %d doesn't record a random beacon", blockNumber)
+	}
+	if !bytes.Equal(computeRandomBeacon(previousBeacon, validatorSignatures).Bytes(), beacon) {
 		return errors.New("invalid quantum random beacon")
 	}
-
-	// Verify beacon was generated correctly
-	// This would involve checking the beacon generation process
-	// against the previous block's beacon and validator signatures
-
 	return nil
 }
 
@@ -553,7 +491,6 @@ func (pq *PQConsensusEngine) GetPerformanceMetrics() map[string]interface{} {
 	// Key statistics
 	pq.keyMutex.RLock()
 	metrics["registered_validators"] = len(pq.validatorKeys)
-	metrics["active_kem_channels"] = len(pq.sharedSecrets)
 	pq.keyMutex.RUnlock()
 
 	// Signature cache statistics
@@ -578,16 +515,6 @@ func (pq *PQConsensusEngine) Close() error {
 	}
 	pq.keyMutex.Unlock()
 
-	pq.networkMutex.Lock()
-	for addr := range pq.sharedSecrets {
-		// Zero out shared secrets
-		for i := range pq.sharedSecrets[addr] {
-			pq.sharedSecrets[addr][i] = 0
-		}
-		delete(pq.sharedSecrets, addr)
-	}
-	pq.networkMutex.Unlock()
-
 	pq.beaconMutex.Lock()
 	// Zero out random beacon
 	for i := range pq.randomBeacon {
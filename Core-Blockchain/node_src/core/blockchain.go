@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"math/big"
 	mrand "math/rand"
 	"sort"
@@ -49,9 +50,10 @@ import (
 )
 
 var (
-	headBlockGauge     = metrics.NewRegisteredGauge("chain/head/block", nil)
-	headHeaderGauge    = metrics.NewRegisteredGauge("chain/head/header", nil)
-	headFastBlockGauge = metrics.NewRegisteredGauge("chain/head/receipt", nil)
+	headBlockGauge          = metrics.NewRegisteredGauge("chain/head/block", nil)
+	headHeaderGauge         = metrics.NewRegisteredGauge("chain/head/header", nil)
+	headFastBlockGauge      = metrics.NewRegisteredGauge("chain/head/receipt", nil)
+	headFinalizedBlockGauge = metrics.NewRegisteredGauge("chain/head/finalized", nil)
 
 	accountReadTimer   = metrics.NewRegisteredTimer("chain/account/reads", nil)
 	accountHashTimer   = metrics.NewRegisteredTimer("chain/account/hashes", nil)
@@ -80,6 +82,9 @@ var (
 	blockPrefetchExecuteTimer   = metrics.NewRegisteredTimer("chain/prefetch/executes", nil)
 	blockPrefetchInterruptMeter = metrics.NewRegisteredMeter("chain/prefetch/interrupts", nil)
 
+	parallelImportMeter   = metrics.NewRegisteredMeter("chain/parallel/imports", nil)
+	parallelFallbackMeter = metrics.NewRegisteredMeter("chain/parallel/fallbacks", nil)
+
 	errInsertionInterrupted = errors.New("insertion is interrupted")
 	errChainStopped         = errors.New("blockchain is stopped")
 )
@@ -189,8 +194,10 @@ type BlockChain struct {
 	// Readers don't need to take it, they can just read the database.
 	chainmu *syncx.ClosableMutex
 
-	currentBlock     atomic.Value // Current head of the block chain
-	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
+	currentBlock          atomic.Value // Current head of the block chain
+	currentFastBlock      atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
+	currentFinalizedBlock atomic.Value // Latest finalized block, which must never be reorged
+	currentSafeBlock      atomic.Value // Latest block unlikely to be reorged, at least as high as the finalized one
 
 	stateCache    state.Database // State database to reuse between imports (contains state cache)
 	bodyCache     *lru.Cache     // Cache for the most recent block bodies
@@ -209,15 +216,34 @@ type BlockChain struct {
 	validator  Validator // Block and state validator interface
 	prefetcher Prefetcher
 	processor  Processor // Block transaction processor interface
+	serial     Processor // Serial processor to fall back to if processor is a parallel one
 	vmConfig   vm.Config
 
 	shouldPreserve func(*types.Block) bool // Function used to determine whether should preserve the given block.
 }
 
+// BlockChainOption modifies a block chain while it is being created.
+type BlockChainOption func(bc *BlockChain) error
+
+// EnableParallelImport makes the block chain process imported blocks with a
+// ParallelStateProcessor. Blocks the parallel processor fails to process, or
+// whose results do not validate, are processed again serially before they are
+// rejected.
+func EnableParallelImport(config *ParallelProcessorConfig) BlockChainOption {
+	return func(bc *BlockChain) error {
+		processor, err := NewParallelStateProcessor(bc.chainConfig, bc, bc.engine, config)
+		if err != nil {
+			return err
+		}
+		bc.processor, bc.serial = processor, bc.processor
+		return nil
+	}
+}
+
 // NewBlockChain returns a fully initialised block chain using information
 // available in the database. It initialises the default Ethereum Validator and
 // Processor.
-func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config, shouldPreserve func(block *types.Block) bool, txLookupLimit *uint64) (*BlockChain, error) {
+func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config, shouldPreserve func(block *types.Block) bool, txLookupLimit *uint64, options ...BlockChainOption) (*BlockChain, error) {
 	if cacheConfig == nil {
 		cacheConfig = defaultCacheConfig
 	}
@@ -253,6 +279,11 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	bc.validator = NewBlockValidator(chainConfig, bc, engine)
 	bc.prefetcher = newStatePrefetcher(chainConfig, bc, engine)
 	bc.processor = NewStateProcessor(chainConfig, bc, engine)
+	for _, option := range options {
+		if err := option(bc); err != nil {
+			return nil, err
+		}
+	}
 
 	var err error
 	bc.hc, err = NewHeaderChain(db, chainConfig, engine, bc.insertStopped)
@@ -267,6 +298,8 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	var nilBlock *types.Block
 	bc.currentBlock.Store(nilBlock)
 	bc.currentFastBlock.Store(nilBlock)
+	bc.currentFinalizedBlock.Store(nilBlock)
+	bc.currentSafeBlock.Store(nilBlock)
 
 	// Initialize the chain with ancient data if it isn't empty.
 	var txIndexBlock uint64
@@ -463,6 +496,13 @@ func (bc *BlockChain) loadLastState() error {
 			headFastBlockGauge.Update(int64(block.NumberU64()))
 		}
 	}
+	// Restore the last known finalized block
+	if head := rawdb.ReadFinalizedBlockHash(bc.db); head != (common.Hash{}) {
+		if block := bc.GetBlockByHash(head); block != nil {
+			bc.currentFinalizedBlock.Store(block)
+			headFinalizedBlockGauge.Update(int64(block.NumberU64()))
+		}
+	}
 	// Issue a status log for the user
 	currentFastBlock := bc.CurrentFastBlock()
 
@@ -484,6 +524,15 @@ func (bc *BlockChain) loadLastState() error {
 // delete minimal data from disk whilst retaining chain consistency.
 func (bc *BlockChain) SetHead(head uint64) error {
 	_, err := bc.setHeadBeyondRoot(head, common.Hash{}, false)
+
+	// Drop the finality markers if they are beyond the new head
+	if finalized := bc.CurrentFinalizedBlock(); finalized != nil && finalized.NumberU64() > bc.CurrentBlock().NumberU64() {
+		log.Warn("SetHead invalidated finalized block", "number", finalized.NumberU64(), "hash", finalized.Hash())
+		bc.SetFinalized(nil)
+	}
+	if safe := bc.CurrentSafeBlock(); safe != nil && safe.NumberU64() > bc.CurrentBlock().NumberU64() {
+		bc.SetSafe(nil)
+	}
 	return err
 }
 
@@ -767,6 +816,61 @@ func (bc *BlockChain) writeHeadBlock(block *types.Block) {
 	headBlockGauge.Update(int64(block.NumberU64()))
 }
 
+// SetFinalized sets the finalized block, which the chain refuses to reorg.
+func (bc *BlockChain) SetFinalized(block *types.Block) {
+	bc.currentFinalizedBlock.Store(block)
+	if block != nil {
+		rawdb.WriteFinalizedBlockHash(bc.db, block.Hash())
+		headFinalizedBlockGauge.Update(int64(block.NumberU64()))
+	} else {
+		rawdb.WriteFinalizedBlockHash(bc.db, common.Hash{})
+		headFinalizedBlockGauge.Update(0)
+	}
+}
+
+// SetSafe sets the safe block. It isn't persisted, as it's re-derived from the
+// finality votes after a restart.
+func (bc *BlockChain) SetSafe(block *types.Block) {
+	bc.currentSafeBlock.Store(block)
+}
+
+// updateFinalized advances the finalized block to the one the consensus engine
+// considers final as of the given canonical head, if it's newer.
+//
+// Note, this function assumes that the `mu` mutex is held!
+func (bc *BlockChain) updateFinalized(head *types.Header) {
+	engine, ok := bc.engine.(consensus.FinalityEngine)
+	if !ok {
+		return
+	}
+	header := engine.FinalizedHeader(bc, head)
+	if header == nil {
+		return
+	}
+	if current := bc.CurrentFinalizedBlock(); current != nil && current.NumberU64() >= header.Number.Uint64() {
+		return
+	}
+	if block := bc.GetBlock(header.Hash(), header.Number.Uint64()); block != nil {
+		log.Debug("Finalized block", "number", block.Number(), "hash", block.Hash())
+		bc.SetFinalized(block)
+	}
+}
+
+// extendsFinalized returns whether the finalized block is an ancestor of the
+// given block, i.e. whether it can become the head without reverting finality.
+func (bc *BlockChain) extendsFinalized(block *types.Block) bool {
+	finalized := bc.CurrentFinalizedBlock()
+	if finalized == nil {
+		return true
+	}
+	if block.NumberU64() <= finalized.NumberU64() {
+		return false
+	}
+	maxNonCanonical := uint64(math.MaxUint64)
+	hash, _ := bc.hc.GetAncestor(block.Hash(), block.NumberU64(), block.NumberU64()-finalized.NumberU64(), &maxNonCanonical)
+	return hash == finalized.Hash()
+}
+
 // Stop stops the blockchain service. If any imports are currently in progress
 // it will abort them using the procInterrupt.
 func (bc *BlockChain) Stop() {
@@ -839,6 +943,10 @@ func (bc *BlockChain) Stop() {
 		triedb := bc.stateCache.TrieDB()
 		triedb.SaveCache(bc.cacheConfig.TrieCleanJournal)
 	}
+	// Release the workers of the block processor, if any
+	if closer, ok := bc.processor.(io.Closer); ok {
+		closer.Close()
+	}
 	log.Info("Blockchain stopped")
 }
 
@@ -1319,6 +1427,12 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 			reorg = !currentPreserve && (blockPreserve || mrand.Float64() < 0.5)
 		}
 	}
+	// Never switch to a chain that doesn't contain the finalized block
+	if reorg && block.ParentHash() != currentBlock.Hash() && !bc.extendsFinalized(block) {
+		log.Warn("Refusing to reorg below finalized block", "number", block.Number(), "hash", block.Hash(),
+			"finalized", bc.CurrentFinalizedBlock().NumberU64())
+		reorg = false
+	}
 	if reorg {
 		// Reorganise the chain if the parent is not the head block
 		if block.ParentHash() != currentBlock.Hash() {
@@ -1333,6 +1447,7 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	// Set new head.
 	if status == CanonStatTy {
 		bc.writeHeadBlock(block)
+		bc.updateFinalized(block.Header())
 	}
 	bc.futureBlocks.Remove(block.Hash())
 
@@ -1631,7 +1746,15 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 
 		// Process block using the parent state as reference point
 		substart := time.Now()
+		if bc.serial != nil {
+			parallelImportMeter.Mark(1)
+		}
 		receipts, logs, usedGas, err := bc.processor.Process(block, statedb, bc.vmConfig)
+		fallback := bc.serial != nil && err != nil
+		if fallback {
+			statedb, receipts, logs, usedGas, err = bc.processSerially(block, parent, statedb, err)
+			activeState = statedb
+		}
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			atomic.StoreUint32(&followupInterrupt, 1)
@@ -1657,7 +1780,15 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 
 		// Validate the state using the default validator
 		substart = time.Now()
-		if err := bc.validator.ValidateState(block, statedb, receipts, usedGas); err != nil {
+		err = bc.validator.ValidateState(block, statedb, receipts, usedGas)
+		if bc.serial != nil && err != nil && !fallback {
+			statedb, receipts, logs, usedGas, err = bc.processSerially(block, parent, statedb, err)
+			activeState = statedb
+			if err == nil {
+				err = bc.validator.ValidateState(block, statedb, receipts, usedGas)
+			}
+		}
+		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			atomic.StoreUint32(&followupInterrupt, 1)
 			return it.index, err
@@ -1741,6 +1872,24 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 	return it.index, err
 }
 
+// processSerially processes a block again with the serial processor after the
+// parallel processor failed it, either with an error or with results that did
+// not validate, returning the new state along with the results.
+func (bc *BlockChain) processSerially(block *types.Block, parent *types.Header, failed *state.StateDB, cause error) (*state.StateDB, types.Receipts, []*types.Log, uint64, error) {
+	parallelFallbackMeter.Mark(1)
+	log.Warn("Parallel block processing failed, retrying serially", "number", block.Number(), "hash", block.Hash(), "err", cause)
+
+	failed.StopPrefetcher()
+	statedb, err := state.New(parent.Root, bc.stateCache, bc.snaps)
+	if err != nil {
+		return failed, nil, nil, 0, err
+	}
+	statedb.StartPrefetcher("chain")
+
+	receipts, logs, usedGas, err := bc.serial.Process(block, statedb, bc.vmConfig)
+	return statedb, receipts, logs, usedGas, err
+}
+
 // insertSideChain is called when an import batch hits upon a pruned ancestor
 // error, which happens when a sidechain with a sufficiently old fork-block is
 // found.
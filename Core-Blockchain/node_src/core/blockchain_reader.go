@@ -50,6 +50,22 @@ func (bc *BlockChain) CurrentFastBlock() *types.Block {
 	return bc.currentFastBlock.Load().(*types.Block)
 }
 
+// CurrentFinalizedBlock retrieves the latest finalized block of the canonical
+// chain, or nil if no block was finalized yet.
+func (bc *BlockChain) CurrentFinalizedBlock() *types.Block {
+	return bc.currentFinalizedBlock.Load().(*types.Block)
+}
+
+// CurrentSafeBlock retrieves the latest safe block of the canonical chain, which
+// is never lower than the finalized one.
+func (bc *BlockChain) CurrentSafeBlock() *types.Block {
+	safe := bc.currentSafeBlock.Load().(*types.Block)
+	if finalized := bc.CurrentFinalizedBlock(); safe == nil || (finalized != nil && finalized.NumberU64() > safe.NumberU64()) {
+		return finalized
+	}
+	return safe
+}
+
 // HasHeader checks if a block header is present in the database or not, caching
 // it if present.
 func (bc *BlockChain) HasHeader(hash common.Hash, number uint64) bool {
@@ -42,3 +42,6 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// NewVoteEvent is posted when a validator's finality vote enters the vote pool.
+type NewVoteEvent struct{ Vote *types.Vote }
@@ -0,0 +1,167 @@
+// Copyright 2024 The Splendor Authors
+// Multi-version memory shared by the speculative transaction executions
+
+package core
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// mvKind distinguishes the state locations tracked by the multi-version memory.
+type mvKind uint8
+
+const (
+	mvAccount mvKind = iota // existence, nonce and code of an account
+	mvBalance               // balance of an account
+	mvStorage               // storage slot of an account
+)
+
+// mvKey identifies a state location in the multi-version memory.
+type mvKey struct {
+	kind mvKind
+	addr common.Address
+	slot common.Hash
+}
+
+// mvAccountValue is the account written by a transaction. Accounts deleted by
+// the transaction do not exist, and both deleted and (re)created accounts have
+// the storage of their previous incarnations cleared.
+type mvAccountValue struct {
+	exists   bool
+	nonce    uint64
+	codeHash common.Hash
+	code     []byte
+	reset    bool
+}
+
+// mvEntry is the value a transaction wrote to a state location.
+type mvEntry struct {
+	index   int
+	account *mvAccountValue
+	balance *big.Int // absolute balance, or the increment if delta is set
+	delta   bool
+	value   common.Hash
+}
+
+// mvMemory holds the values written to every state location by the executed
+// transactions of a block, ordered by transaction index. Transactions read the
+// value written by the highest transaction below them, making the results of
+// the speculative executions visible to the later transactions before they
+// are committed.
+type mvMemory struct {
+	lock    sync.RWMutex
+	entries map[mvKey][]*mvEntry
+	written map[int][]mvKey
+}
+
+func newMVMemory() *mvMemory {
+	return &mvMemory{
+		entries: make(map[mvKey][]*mvEntry),
+		written: make(map[int][]mvKey),
+	}
+}
+
+// publish records the writes of a transaction, replacing the writes of any
+// previous execution of it.
+func (mv *mvMemory) publish(index int, writes parallelWriteSet) {
+	mv.lock.Lock()
+	defer mv.lock.Unlock()
+
+	for _, key := range mv.written[index] {
+		entries := mv.entries[key]
+		if i := mv.search(entries, index); i < len(entries) && entries[i].index == index {
+			mv.entries[key] = append(entries[:i], entries[i+1:]...)
+		}
+	}
+	keys := make([]mvKey, 0, len(writes))
+	for addr, write := range writes {
+		if write.account != nil {
+			keys = append(keys, mv.insert(mvKey{kind: mvAccount, addr: addr}, &mvEntry{index: index, account: write.account}))
+		}
+		if write.balance != nil {
+			keys = append(keys, mv.insert(mvKey{kind: mvBalance, addr: addr}, &mvEntry{index: index, balance: write.balance, delta: write.delta}))
+		}
+		for slot, value := range write.storage {
+			keys = append(keys, mv.insert(mvKey{kind: mvStorage, addr: addr, slot: slot}, &mvEntry{index: index, value: value}))
+		}
+	}
+	mv.written[index] = keys
+}
+
+// insert adds an entry to a location, keeping the entries ordered.
+func (mv *mvMemory) insert(key mvKey, entry *mvEntry) mvKey {
+	entries := mv.entries[key]
+	i := mv.search(entries, entry.index)
+	entries = append(entries, nil)
+	copy(entries[i+1:], entries[i:])
+	entries[i] = entry
+	mv.entries[key] = entries
+	return key
+}
+
+// search returns the position of the first entry written by the transaction
+// with the given index or any transaction above it.
+func (mv *mvMemory) search(entries []*mvEntry, index int) int {
+	return sort.Search(len(entries), func(i int) bool { return entries[i].index >= index })
+}
+
+// account returns the account as written by the transactions below index, or
+// false if none of them modified it.
+func (mv *mvMemory) account(addr common.Address, index int) (*mvAccountValue, bool) {
+	mv.lock.RLock()
+	defer mv.lock.RUnlock()
+
+	entries := mv.entries[mvKey{kind: mvAccount, addr: addr}]
+	if i := mv.search(entries, index); i > 0 {
+		return entries[i-1].account, true
+	}
+	return nil, false
+}
+
+// balance returns the balance written by the transactions below index, along
+// with the increments added on top of it. The balance is nil if the account
+// was only credited, in which case the increments apply to the base state.
+func (mv *mvMemory) balance(addr common.Address, index int) (*big.Int, *big.Int) {
+	mv.lock.RLock()
+	defer mv.lock.RUnlock()
+
+	entries := mv.entries[mvKey{kind: mvBalance, addr: addr}]
+	delta := new(big.Int)
+	for i := mv.search(entries, index) - 1; i >= 0; i-- {
+		if !entries[i].delta {
+			return entries[i].balance, delta
+		}
+		delta.Add(delta, entries[i].balance)
+	}
+	return nil, delta
+}
+
+// storage returns the storage slot as written by the transactions below index,
+// or false if none of them modified it or cleared the storage of the account.
+func (mv *mvMemory) storage(addr common.Address, slot common.Hash, index int) (common.Hash, bool) {
+	mv.lock.RLock()
+	defer mv.lock.RUnlock()
+
+	written := -1
+	entries := mv.entries[mvKey{kind: mvStorage, addr: addr, slot: slot}]
+	if i := mv.search(entries, index); i > 0 {
+		written = i - 1
+	}
+	accounts := mv.entries[mvKey{kind: mvAccount, addr: addr}]
+	for i := mv.search(accounts, index) - 1; i >= 0; i-- {
+		if entry := accounts[i]; entry.account.reset {
+			if written < 0 || entries[written].index < entry.index {
+				return common.Hash{}, true
+			}
+			break
+		}
+	}
+	if written < 0 {
+		return common.Hash{}, false
+	}
+	return entries[written].value, true
+}
@@ -0,0 +1,680 @@
+// Copyright 2024 The Splendor Authors
+// Transaction-local state of the speculative parallel transaction execution
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ripemd is touched by a transaction of the mainnet which runs out of gas
+// afterwards, but the touch survives the revert. See state.StateDB.Finalise.
+var ripemd = common.HexToAddress("0000000000000000000000000000000000000003")
+
+// errParallelStorageIteration is returned when a transaction iterates over the
+// storage of an account, which the transaction-local state can not serve.
+var errParallelStorageIteration = errors.New("storage iteration unsupported in parallel execution")
+
+// errParallelUnsupported is returned when a transaction did something the
+// transaction-local state can not turn into a write set.
+var errParallelUnsupported = errors.New("transaction unsupported in parallel execution")
+
+// parallelSlot identifies a storage slot of an account.
+type parallelSlot struct {
+	addr common.Address
+	key  common.Hash
+}
+
+// parallelAccountRead is the account a transaction found at its start.
+type parallelAccountRead struct {
+	exists   bool
+	nonce    uint64
+	codeHash common.Hash
+	code     []byte
+	loaded   bool // whether code is loaded
+}
+
+// parallelReadSet is the state a transaction read at its start. Balances of
+// accounts are only recorded when read, blind credits (e.g. the fees) do not
+// depend on the previous balance. Accounts whose balance was only checked
+// against zero record that outcome instead of the balance.
+type parallelReadSet struct {
+	accounts map[common.Address]*parallelAccountRead
+	balances map[common.Address]*big.Int
+	zeros    map[common.Address]bool
+	storage  map[parallelSlot]common.Hash
+}
+
+// validate reports whether the state read by the transaction matches the given
+// state, i.e. whether executing the transaction on it gives the same results.
+func (rs *parallelReadSet) validate(statedb *state.StateDB) bool {
+	for addr, read := range rs.accounts {
+		if statedb.Exist(addr) != read.exists {
+			return false
+		}
+		if read.exists && (statedb.GetNonce(addr) != read.nonce || statedb.GetCodeHash(addr) != read.codeHash) {
+			return false
+		}
+	}
+	for addr, balance := range rs.balances {
+		if statedb.GetBalance(addr).Cmp(balance) != 0 {
+			return false
+		}
+	}
+	for addr, zero := range rs.zeros {
+		if (statedb.GetBalance(addr).Sign() == 0) != zero {
+			return false
+		}
+	}
+	for slot, value := range rs.storage {
+		if statedb.GetState(slot.addr, slot.key) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// parallelWrite is the change of an account by a transaction.
+type parallelWrite struct {
+	account *mvAccountValue             // nil if existence, nonce and code are unchanged
+	balance *big.Int                    // nil if unchanged
+	delta   bool                        // whether balance is credited instead of set
+	storage map[common.Hash]common.Hash // changed storage slots
+}
+
+// parallelWriteSet holds the changes of the accounts modified by a transaction.
+type parallelWriteSet map[common.Address]*parallelWrite
+
+// apply writes the changes into the state, the same way executing the
+// transaction would have.
+func (ws parallelWriteSet) apply(statedb *state.StateDB) {
+	for addr, write := range ws {
+		if account := write.account; account != nil {
+			if !account.exists {
+				// Deleted accounts are suicided, touching the ones which are
+				// not there has them created and deleted once finalised.
+				if statedb.Exist(addr) {
+					statedb.Suicide(addr)
+				} else {
+					statedb.AddBalance(addr, new(big.Int))
+				}
+				continue
+			}
+			if account.reset {
+				statedb.CreateAccount(addr)
+			}
+			statedb.SetNonce(addr, account.nonce)
+			if statedb.GetCodeHash(addr) != account.codeHash {
+				statedb.SetCode(addr, account.code)
+			}
+		}
+		if write.balance != nil {
+			if write.delta {
+				statedb.AddBalance(addr, write.balance)
+			} else {
+				statedb.SetBalance(addr, write.balance)
+			}
+		}
+		for key, value := range write.storage {
+			statedb.SetState(addr, key, value)
+		}
+	}
+}
+
+// parallelObject is the transaction-local state of an existing account. The
+// balance is nil while only credits were made, which are accumulated in delta
+// until the previous balance is read.
+type parallelObject struct {
+	nonce    uint64
+	codeHash common.Hash
+	code     []byte // nil if unchanged by the transaction
+	balance  *big.Int
+	delta    *big.Int
+	suicided bool
+	reset    bool // whether the storage of the previous incarnation is cleared
+	storage  map[common.Hash]common.Hash
+}
+
+// parallelJournalEntry is a revertible change of the transaction-local state.
+type parallelJournalEntry struct {
+	addr   *common.Address // account dirtied by the change, if any
+	revert func()
+}
+
+// parallelTxState is the vm.StateDB a single transaction is executed against.
+// It serves the state of the block as left by the transactions before it from
+// the multi-version memory, falling back to the base state for the locations
+// none of them wrote, and records the read set. Writes are kept local until
+// the transaction ends, turning them into the write set.
+//
+// The semantics follow state.StateDB post EIP-158, in particular journaling,
+// account touching and the deletion of empty accounts when finalising.
+type parallelTxState struct {
+	index int
+	base  *state.StateDB
+	mv    *mvMemory // nil if the base state already holds the lower transactions
+
+	reads   parallelReadSet
+	objects map[common.Address]*parallelObject // nil for absent accounts
+	dirties map[common.Address]int
+
+	journal   []parallelJournalEntry
+	revisions []int
+	refund    uint64
+	access    map[common.Address]map[common.Hash]struct{}
+	logs      []*types.Log
+	preimages map[common.Hash][]byte
+
+	unsupported bool // set if the transaction did something the state can not replay
+}
+
+// newParallelTxState creates the transaction-local state of the transaction
+// with the given index, reading the locations not found in mv from base.
+func newParallelTxState(index int, base *state.StateDB, mv *mvMemory) *parallelTxState {
+	return &parallelTxState{
+		index: index,
+		base:  base,
+		mv:    mv,
+		reads: parallelReadSet{
+			accounts: make(map[common.Address]*parallelAccountRead),
+			balances: make(map[common.Address]*big.Int),
+			zeros:    make(map[common.Address]bool),
+			storage:  make(map[parallelSlot]common.Hash),
+		},
+		objects:   make(map[common.Address]*parallelObject),
+		dirties:   make(map[common.Address]int),
+		access:    make(map[common.Address]map[common.Hash]struct{}),
+		preimages: make(map[common.Hash][]byte),
+	}
+}
+
+// readAccount returns the account found at the start of the transaction.
+func (s *parallelTxState) readAccount(addr common.Address) *parallelAccountRead {
+	if read, ok := s.reads.accounts[addr]; ok {
+		return read
+	}
+	read := new(parallelAccountRead)
+	if account, ok := s.mvAccount(addr); ok {
+		read.exists, read.nonce, read.codeHash = account.exists, account.nonce, account.codeHash
+		read.code, read.loaded = account.code, true
+	} else if read.exists = s.base.Exist(addr); read.exists {
+		read.nonce, read.codeHash = s.base.GetNonce(addr), s.base.GetCodeHash(addr)
+	}
+	s.reads.accounts[addr] = read
+	if read.exists {
+		s.objects[addr] = &parallelObject{
+			nonce:    read.nonce,
+			codeHash: read.codeHash,
+			storage:  make(map[common.Hash]common.Hash),
+		}
+	}
+	return read
+}
+
+func (s *parallelTxState) mvAccount(addr common.Address) (*mvAccountValue, bool) {
+	if s.mv == nil {
+		return nil, false
+	}
+	return s.mv.account(addr, s.index)
+}
+
+// readBalance returns the balance of an account at the start of the transaction.
+func (s *parallelTxState) readBalance(addr common.Address) *big.Int {
+	if balance, ok := s.reads.balances[addr]; ok {
+		return balance
+	}
+	var balance, delta *big.Int
+	if s.mv != nil {
+		balance, delta = s.mv.balance(addr, s.index)
+	}
+	if balance == nil {
+		balance = s.base.GetBalance(addr)
+	}
+	if delta != nil {
+		balance = new(big.Int).Add(balance, delta)
+	}
+	return balance
+}
+
+// readStorage returns the value of a storage slot at the start of the transaction.
+func (s *parallelTxState) readStorage(addr common.Address, key common.Hash) common.Hash {
+	slot := parallelSlot{addr, key}
+	if value, ok := s.reads.storage[slot]; ok {
+		return value
+	}
+	var (
+		value common.Hash
+		ok    bool
+	)
+	if s.mv != nil {
+		value, ok = s.mv.storage(addr, key, s.index)
+	}
+	if !ok {
+		value = s.base.GetState(addr, key)
+	}
+	s.reads.storage[slot] = value
+	return value
+}
+
+// getObject returns the transaction-local state of an account, or nil if the
+// account does not exist.
+func (s *parallelTxState) getObject(addr common.Address) *parallelObject {
+	if _, ok := s.reads.accounts[addr]; !ok {
+		s.readAccount(addr)
+	}
+	return s.objects[addr]
+}
+
+// getOrNewObject returns the transaction-local state of an account, creating
+// the account if it does not exist.
+func (s *parallelTxState) getOrNewObject(addr common.Address) *parallelObject {
+	if obj := s.getObject(addr); obj != nil {
+		return obj
+	}
+	return s.createObject(addr, nil)
+}
+
+// createObject creates a fresh account, replacing prev if it exists. Replaced
+// accounts are not dirtied by the creation, see state.StateDB.createObject.
+func (s *parallelTxState) createObject(addr common.Address, prev *parallelObject) *parallelObject {
+	obj := &parallelObject{
+		codeHash: emptyCodeHash,
+		balance:  new(big.Int),
+		reset:    true,
+		storage:  make(map[common.Hash]common.Hash),
+	}
+	if prev == nil {
+		s.append(&addr, func() { delete(s.objects, addr) })
+	} else {
+		s.append(nil, func() { s.objects[addr] = prev })
+	}
+	s.objects[addr] = obj
+	return obj
+}
+
+// balance returns the current balance of an account, reading the balance at the
+// start of the transaction if needed.
+func (s *parallelTxState) balance(addr common.Address, obj *parallelObject) *big.Int {
+	if obj.balance == nil {
+		balance := s.readBalance(addr)
+		s.reads.balances[addr] = balance
+
+		obj.balance = balance
+		if obj.delta != nil {
+			obj.balance = new(big.Int).Add(balance, obj.delta)
+		}
+		obj.delta = nil
+	}
+	return obj.balance
+}
+
+// empty reports whether an account is empty according to EIP-161. Unless the
+// balance was read or set already, only its comparison with zero is recorded.
+func (s *parallelTxState) empty(addr common.Address, obj *parallelObject) bool {
+	if obj.nonce != 0 || obj.codeHash != emptyCodeHash {
+		return false
+	}
+	if obj.balance != nil {
+		return obj.balance.Sign() == 0
+	}
+	if obj.delta != nil && obj.delta.Sign() > 0 {
+		return false
+	}
+	if zero, ok := s.reads.zeros[addr]; ok {
+		return zero
+	}
+	zero := s.readBalance(addr).Sign() == 0
+	s.reads.zeros[addr] = zero
+	return zero
+}
+
+// append adds a change to the journal, dirtying addr if not nil.
+func (s *parallelTxState) append(addr *common.Address, revert func()) {
+	s.journal = append(s.journal, parallelJournalEntry{addr: addr, revert: revert})
+	if addr != nil {
+		s.dirties[*addr]++
+	}
+}
+
+// setBalance journals and changes the balance of an account.
+func (s *parallelTxState) setBalance(addr common.Address, obj *parallelObject, balance, delta *big.Int) {
+	prevBalance, prevDelta := obj.balance, obj.delta
+	s.append(&addr, func() { obj.balance, obj.delta = prevBalance, prevDelta })
+	obj.balance, obj.delta = balance, delta
+}
+
+// touch dirties an account without changing it.
+func (s *parallelTxState) touch(addr common.Address) {
+	s.append(&addr, func() {})
+	if addr == ripemd {
+		s.dirties[addr]++
+	}
+}
+
+func (s *parallelTxState) CreateAccount(addr common.Address) {
+	prev := s.getObject(addr)
+	obj := s.createObject(addr, prev)
+	if prev != nil {
+		obj.balance, obj.delta = prev.balance, prev.delta
+	}
+}
+
+func (s *parallelTxState) SubBalance(addr common.Address, amount *big.Int) {
+	obj := s.getOrNewObject(addr)
+	if amount.Sign() == 0 {
+		if s.empty(addr, obj) {
+			s.touch(addr)
+		}
+		return
+	}
+	s.setBalance(addr, obj, new(big.Int).Sub(s.balance(addr, obj), amount), nil)
+}
+
+func (s *parallelTxState) AddBalance(addr common.Address, amount *big.Int) {
+	obj := s.getOrNewObject(addr)
+	if amount.Sign() == 0 {
+		if s.empty(addr, obj) {
+			s.touch(addr)
+		}
+		return
+	}
+	if obj.balance != nil {
+		s.setBalance(addr, obj, new(big.Int).Add(obj.balance, amount), nil)
+		return
+	}
+	delta := new(big.Int).Set(amount)
+	if obj.delta != nil {
+		delta.Add(delta, obj.delta)
+	}
+	s.setBalance(addr, obj, nil, delta)
+}
+
+func (s *parallelTxState) GetBalance(addr common.Address) *big.Int {
+	if obj := s.getObject(addr); obj != nil {
+		return s.balance(addr, obj)
+	}
+	return common.Big0
+}
+
+func (s *parallelTxState) GetNonce(addr common.Address) uint64 {
+	if obj := s.getObject(addr); obj != nil {
+		return obj.nonce
+	}
+	return 0
+}
+
+func (s *parallelTxState) SetNonce(addr common.Address, nonce uint64) {
+	obj := s.getOrNewObject(addr)
+	prev := obj.nonce
+	s.append(&addr, func() { obj.nonce = prev })
+	obj.nonce = nonce
+}
+
+func (s *parallelTxState) GetCodeHash(addr common.Address) common.Hash {
+	if obj := s.getObject(addr); obj != nil {
+		return obj.codeHash
+	}
+	return common.Hash{}
+}
+
+func (s *parallelTxState) GetCode(addr common.Address) []byte {
+	obj := s.getObject(addr)
+	if obj == nil {
+		return nil
+	}
+	if obj.code != nil {
+		return obj.code
+	}
+	if obj.codeHash == emptyCodeHash {
+		return nil
+	}
+	// Unchanged code is the one of the account at the start of the transaction
+	read := s.reads.accounts[addr]
+	if !read.loaded {
+		read.code, read.loaded = s.base.GetCode(addr), true
+	}
+	return read.code
+}
+
+func (s *parallelTxState) SetCode(addr common.Address, code []byte) {
+	obj := s.getOrNewObject(addr)
+	prevHash, prevCode := obj.codeHash, obj.code
+	s.append(&addr, func() { obj.codeHash, obj.code = prevHash, prevCode })
+	obj.codeHash, obj.code = crypto.Keccak256Hash(code), code
+}
+
+func (s *parallelTxState) GetCodeSize(addr common.Address) int {
+	return len(s.GetCode(addr))
+}
+
+func (s *parallelTxState) AddRefund(gas uint64) {
+	prev := s.refund
+	s.append(nil, func() { s.refund = prev })
+	s.refund += gas
+}
+
+func (s *parallelTxState) SubRefund(gas uint64) {
+	prev := s.refund
+	s.append(nil, func() { s.refund = prev })
+	if gas > s.refund {
+		panic(fmt.Sprintf("Refund counter below zero (gas: %d > refund: %d)", gas, s.refund))
+	}
+	s.refund -= gas
+}
+
+func (s *parallelTxState) GetRefund() uint64 {
+	return s.refund
+}
+
+func (s *parallelTxState) GetCommittedState(addr common.Address, key common.Hash) common.Hash {
+	obj := s.getObject(addr)
+	if obj == nil || obj.reset {
+		return common.Hash{}
+	}
+	return s.readStorage(addr, key)
+}
+
+func (s *parallelTxState) GetState(addr common.Address, key common.Hash) common.Hash {
+	obj := s.getObject(addr)
+	if obj == nil {
+		return common.Hash{}
+	}
+	if value, dirty := obj.storage[key]; dirty {
+		return value
+	}
+	if obj.reset {
+		return common.Hash{}
+	}
+	return s.readStorage(addr, key)
+}
+
+func (s *parallelTxState) SetState(addr common.Address, key, value common.Hash) {
+	obj := s.getOrNewObject(addr)
+	if s.GetState(addr, key) == value {
+		return
+	}
+	prev, dirty := obj.storage[key]
+	s.append(&addr, func() {
+		if dirty {
+			obj.storage[key] = prev
+		} else {
+			delete(obj.storage, key)
+		}
+	})
+	obj.storage[key] = value
+}
+
+func (s *parallelTxState) Suicide(addr common.Address) bool {
+	obj := s.getObject(addr)
+	if obj == nil {
+		return false
+	}
+	prev, prevBalance, prevDelta := obj.suicided, obj.balance, obj.delta
+	s.append(&addr, func() { obj.suicided, obj.balance, obj.delta = prev, prevBalance, prevDelta })
+	obj.suicided, obj.balance, obj.delta = true, new(big.Int), nil
+	return true
+}
+
+func (s *parallelTxState) HasSuicided(addr common.Address) bool {
+	if obj := s.getObject(addr); obj != nil {
+		return obj.suicided
+	}
+	return false
+}
+
+func (s *parallelTxState) Exist(addr common.Address) bool {
+	return s.getObject(addr) != nil
+}
+
+func (s *parallelTxState) Empty(addr common.Address) bool {
+	obj := s.getObject(addr)
+	return obj == nil || s.empty(addr, obj)
+}
+
+func (s *parallelTxState) PrepareAccessList(sender common.Address, dst *common.Address, precompiles []common.Address, list types.AccessList) {
+	s.AddAddressToAccessList(sender)
+	if dst != nil {
+		s.AddAddressToAccessList(*dst)
+	}
+	for _, addr := range precompiles {
+		s.AddAddressToAccessList(addr)
+	}
+	for _, el := range list {
+		s.AddAddressToAccessList(el.Address)
+		for _, key := range el.StorageKeys {
+			s.AddSlotToAccessList(el.Address, key)
+		}
+	}
+}
+
+func (s *parallelTxState) AddressInAccessList(addr common.Address) bool {
+	_, ok := s.access[addr]
+	return ok
+}
+
+func (s *parallelTxState) SlotInAccessList(addr common.Address, slot common.Hash) (bool, bool) {
+	slots, ok := s.access[addr]
+	if !ok {
+		return false, false
+	}
+	_, ok = slots[slot]
+	return true, ok
+}
+
+func (s *parallelTxState) AddAddressToAccessList(addr common.Address) {
+	if _, ok := s.access[addr]; !ok {
+		s.access[addr] = make(map[common.Hash]struct{})
+		s.append(nil, func() { delete(s.access, addr) })
+	}
+}
+
+func (s *parallelTxState) AddSlotToAccessList(addr common.Address, slot common.Hash) {
+	s.AddAddressToAccessList(addr)
+	slots := s.access[addr]
+	if _, ok := slots[slot]; !ok {
+		slots[slot] = struct{}{}
+		s.append(nil, func() { delete(slots, slot) })
+	}
+}
+
+func (s *parallelTxState) Snapshot() int {
+	s.revisions = append(s.revisions, len(s.journal))
+	return len(s.revisions) - 1
+}
+
+func (s *parallelTxState) RevertToSnapshot(revid int) {
+	if revid >= len(s.revisions) {
+		panic(fmt.Errorf("revision id %v cannot be reverted", revid))
+	}
+	snapshot := s.revisions[revid]
+	for i := len(s.journal) - 1; i >= snapshot; i-- {
+		entry := s.journal[i]
+		entry.revert()
+		if entry.addr != nil {
+			if s.dirties[*entry.addr]--; s.dirties[*entry.addr] == 0 {
+				delete(s.dirties, *entry.addr)
+			}
+		}
+	}
+	s.journal = s.journal[:snapshot]
+	s.revisions = s.revisions[:revid]
+}
+
+func (s *parallelTxState) AddLog(log *types.Log) {
+	size := len(s.logs)
+	s.append(nil, func() { s.logs = s.logs[:size] })
+	s.logs = append(s.logs, log)
+}
+
+func (s *parallelTxState) AddPreimage(hash common.Hash, preimage []byte) {
+	if _, ok := s.preimages[hash]; !ok {
+		s.append(nil, func() { delete(s.preimages, hash) })
+		s.preimages[hash] = common.CopyBytes(preimage)
+	}
+}
+
+func (s *parallelTxState) ForEachStorage(common.Address, func(common.Hash, common.Hash) bool) error {
+	s.unsupported = true
+	return errParallelStorageIteration
+}
+
+// finalise ends the transaction, deleting the suicided and the empty dirtied
+// accounts, and returns its write set.
+func (s *parallelTxState) finalise() parallelWriteSet {
+	writes := make(parallelWriteSet, len(s.dirties))
+	for addr, obj := range s.objects {
+		if obj.reset && s.dirties[addr] == 0 {
+			// Replaced but undirtied accounts are never finalised
+			s.unsupported = true
+		}
+	}
+	for addr := range s.dirties {
+		obj := s.objects[addr]
+		if obj == nil {
+			continue
+		}
+		if obj.suicided || s.empty(addr, obj) {
+			writes[addr] = &parallelWrite{
+				account: &mvAccountValue{reset: true},
+				balance: new(big.Int),
+			}
+			continue
+		}
+		var (
+			read  = s.reads.accounts[addr]
+			write = &parallelWrite{storage: obj.storage}
+		)
+		if !read.exists || obj.reset || obj.nonce != read.nonce || obj.codeHash != read.codeHash {
+			write.account = &mvAccountValue{
+				exists:   true,
+				nonce:    obj.nonce,
+				codeHash: obj.codeHash,
+				code:     s.GetCode(addr),
+				reset:    obj.reset || !read.exists,
+			}
+		}
+		switch {
+		case obj.balance == nil:
+			if obj.delta != nil && obj.delta.Sign() != 0 {
+				write.balance, write.delta = obj.delta, true
+			}
+		case !read.exists || obj.reset:
+			write.balance = obj.balance
+		default:
+			if prev, ok := s.reads.balances[addr]; !ok || prev.Cmp(obj.balance) != 0 {
+				write.balance = obj.balance
+			}
+		}
+		if write.account != nil || write.balance != nil || len(write.storage) > 0 {
+			writes[addr] = write
+		}
+	}
+	return writes
+}
@@ -1,9 +1,7 @@
 package core
 
 import (
-	"context"
 	"fmt"
-	"math/big"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -17,15 +15,22 @@ import (
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/params"
 )
 
+var (
+	parallelAcceptedMeter   = metrics.NewRegisteredMeter("chain/parallel/accepted", nil)
+	parallelReexecutedMeter = metrics.NewRegisteredMeter("chain/parallel/reexecuted", nil)
+	parallelSerialMeter     = metrics.NewRegisteredMeter("chain/parallel/serial", nil)
+)
+
 // ParallelStateProcessor extends StateProcessor with advanced parallel processing capabilities
 type ParallelStateProcessor struct {
 	*StateProcessor
-	processor       *gopool.ParallelProcessor
-	config          *ParallelProcessorConfig
-	
+	processor *gopool.ParallelProcessor
+	config    *ParallelProcessorConfig
+
 	// Performance metrics
 	mu              sync.RWMutex
 	processedBlocks uint64
@@ -37,27 +42,27 @@ type ParallelStateProcessor struct {
 // ParallelProcessorConfig holds configuration for parallel state processing
 type ParallelProcessorConfig struct {
 	// Transaction processing
-	MaxTxConcurrency     int           `json:"maxTxConcurrency"`
-	TxBatchSize          int           `json:"txBatchSize"`
-	TxTimeout            time.Duration `json:"txTimeout"`
-	
+	MaxTxConcurrency int           `json:"maxTxConcurrency"`
+	TxBatchSize      int           `json:"txBatchSize"`
+	TxTimeout        time.Duration `json:"txTimeout"`
+
 	// Validation settings
 	MaxValidationWorkers int           `json:"maxValidationWorkers"`
 	ValidationTimeout    time.Duration `json:"validationTimeout"`
-	
+
 	// State processing
-	StateWorkers         int           `json:"stateWorkers"`
-	StateTimeout         time.Duration `json:"stateTimeout"`
-	
+	StateWorkers int           `json:"stateWorkers"`
+	StateTimeout time.Duration `json:"stateTimeout"`
+
 	// Performance tuning
-	EnablePipelining     bool          `json:"enablePipelining"`
-	EnableTxBatching     bool          `json:"enableTxBatching"`
-	EnableBloomParallel  bool          `json:"enableBloomParallel"`
-	AdaptiveScaling      bool          `json:"adaptiveScaling"`
-	
+	EnablePipelining    bool `json:"enablePipelining"` // Execute transactions speculatively ahead of their commit
+	EnableTxBatching    bool `json:"enableTxBatching"` // Bound the speculation to TxBatchSize uncommitted transactions
+	EnableBloomParallel bool `json:"enableBloomParallel"`
+	AdaptiveScaling     bool `json:"adaptiveScaling"`
+
 	// Resource limits
-	MaxMemoryUsage       uint64        `json:"maxMemoryUsage"`
-	MaxGoroutines        int           `json:"maxGoroutines"`
+	MaxMemoryUsage uint64 `json:"maxMemoryUsage"`
+	MaxGoroutines  int    `json:"maxGoroutines"`
 }
 
 // DefaultParallelProcessorConfig returns optimized default configuration
@@ -85,10 +90,10 @@ func NewParallelStateProcessor(config *params.ChainConfig, bc *BlockChain, engin
 	if parallelConfig == nil {
 		parallelConfig = DefaultParallelProcessorConfig()
 	}
-	
+
 	// Create base state processor
 	baseProcessor := NewStateProcessor(config, bc, engine)
-	
+
 	// Initialize parallel processor
 	processorConfig := &gopool.ProcessorConfig{
 		MaxWorkers:        parallelConfig.MaxGoroutines,
@@ -97,22 +102,22 @@ func NewParallelStateProcessor(config *params.ChainConfig, bc *BlockChain, engin
 		TxWorkers:         parallelConfig.MaxTxConcurrency,
 		ValidationWorkers: parallelConfig.MaxValidationWorkers,
 		StateWorkers:      parallelConfig.StateWorkers,
-		ConsensusWorkers:  runtime.NumCPU() / 2,
+		ConsensusWorkers:  (runtime.NumCPU() + 1) / 2,
 		NetworkWorkers:    runtime.NumCPU(),
 	}
-	
+
 	processor, err := gopool.NewParallelProcessor(processorConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create parallel processor: %w", err)
 	}
-	
+
 	psp := &ParallelStateProcessor{
 		StateProcessor: baseProcessor,
 		processor:      processor,
 		config:         parallelConfig,
 		maxConcurrency: int32(parallelConfig.MaxTxConcurrency),
 	}
-	
+
 	log.Info("Parallel state processor initialized",
 		"maxTxConcurrency", parallelConfig.MaxTxConcurrency,
 		"txBatchSize", parallelConfig.TxBatchSize,
@@ -121,11 +126,21 @@ func NewParallelStateProcessor(config *params.ChainConfig, bc *BlockChain, engin
 		"pipelining", parallelConfig.EnablePipelining,
 		"batching", parallelConfig.EnableTxBatching,
 	)
-	
+
 	return psp, nil
 }
 
-// ProcessParallel processes a block using advanced parallel processing techniques
+// Process processes the state changes of a block like StateProcessor.Process,
+// executing the transactions in parallel. It makes ParallelStateProcessor a
+// Processor usable for block import.
+func (psp *ParallelStateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+	return psp.ProcessParallel(block, statedb, cfg)
+}
+
+// ProcessParallel processes a block by executing its transactions speculatively
+// in parallel and committing them in block order. The transactions whose reads
+// were invalidated by the ones before them are re-executed, so the results are
+// identical to those of StateProcessor.Process.
 func (psp *ParallelStateProcessor) ProcessParallel(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
 	start := time.Now()
 	defer func() {
@@ -138,390 +153,415 @@ func (psp *ParallelStateProcessor) ProcessParallel(block *types.Block, statedb *
 			"tps", float64(len(block.Transactions()))/duration.Seconds(),
 		)
 	}()
-	
+
 	var (
-		receipts    = make([]*types.Receipt, 0, len(block.Transactions()))
-		usedGas     = new(uint64)
-		header      = block.Header()
-		blockHash   = block.Hash()
-		blockNumber = block.Number()
-		allLogs     []*types.Log
-		gp          = new(GasPool).AddGas(block.GasLimit())
+		usedGas = new(uint64)
+		header  = block.Header()
+		gp      = new(GasPool).AddGas(block.GasLimit())
+		batch   = &parallelBatch{
+			header:  header,
+			hash:    block.Hash(),
+			statedb: statedb,
+			gp:      gp,
+			usedGas: usedGas,
+			cfg:     cfg,
+		}
 	)
-	
-	// Create EVM context
-	blockContext := NewEVMBlockContext(header, psp.bc, nil)
-	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, psp.StateProcessor.config, cfg)
-	
 	// Handle PoSA consensus if applicable
 	posa, isPoSA := psp.engine.(consensus.PoSA)
 	if isPoSA {
 		if err := posa.PreHandle(psp.bc, header, statedb); err != nil {
 			return nil, nil, 0, err
 		}
-		vmenv.Context.ExtraValidator = posa.CreateEvmExtraValidator(header, statedb)
+		batch.extraValidator = posa.CreateEvmExtraValidator(header, statedb)
+		batch.validate = func(sender common.Address, tx *types.Transaction) error {
+			return posa.ValidateTx(sender, tx, header, statedb)
+		}
 	}
-	
+
 	// Preload accounts for better performance
 	signer := types.MakeSigner(psp.StateProcessor.config, header.Number)
 	statedb.PreloadAccounts(block, signer)
-	
+
 	// Separate system and regular transactions
-	commonTxs, systemTxs, err := psp.separateTransactions(block.Transactions(), signer, header, isPoSA, posa)
+	commonTxs, systemTxs, indexes, err := psp.separateTransactions(block.Transactions(), signer, header, isPoSA, posa)
 	if err != nil {
 		return nil, nil, 0, err
 	}
-	
-	// Process transactions based on configuration
-	if psp.config.EnableTxBatching && len(commonTxs) > psp.config.TxBatchSize {
-		receipts, allLogs, err = psp.processBatchedTransactions(commonTxs, statedb, vmenv, gp, usedGas, blockNumber, blockHash)
-	} else if psp.config.EnablePipelining {
-		receipts, allLogs, err = psp.processPipelinedTransactions(commonTxs, statedb, vmenv, gp, usedGas, blockNumber, blockHash)
-	} else {
-		receipts, allLogs, err = psp.processSequentialTransactions(commonTxs, statedb, vmenv, gp, usedGas, blockNumber, blockHash)
-	}
-	
+	batch.indexes = indexes
+
+	_, receipts, err := psp.applyTransactions(batch, commonTxs)
 	if err != nil {
 		return nil, nil, 0, err
 	}
-	
+	var allLogs []*types.Log
+	for _, receipt := range receipts {
+		allLogs = append(allLogs, receipt.Logs...)
+	}
+
 	// Finalize the block
 	if err := psp.engine.Finalize(psp.bc, header, statedb, &commonTxs, block.Uncles(), &receipts, systemTxs); err != nil {
 		return nil, nil, 0, err
 	}
-	
+
 	return receipts, allLogs, *usedGas, nil
 }
 
-// separateTransactions separates system and regular transactions
-func (psp *ParallelStateProcessor) separateTransactions(txs []*types.Transaction, signer types.Signer, header *types.Header, isPoSA bool, posa consensus.PoSA) ([]*types.Transaction, []*types.Transaction, error) {
+// ApplyTransactions executes the given transactions in parallel on top of the
+// block being produced, committing them in order at the transaction indexes
+// following index. Transactions rejected by validate or failing to apply are
+// skipped without touching the state, like the miner does when committing them
+// one by one. It returns the included transactions along with their receipts.
+func (psp *ParallelStateProcessor) ApplyTransactions(header *types.Header, author *common.Address, gp *GasPool, statedb *state.StateDB, txs []*types.Transaction, index int, usedGas *uint64, cfg vm.Config, extraValidator types.EvmExtraValidator, validate func(sender common.Address, tx *types.Transaction) error) ([]*types.Transaction, []*types.Receipt) {
+	batch := &parallelBatch{
+		header:         header,
+		hash:           header.Hash(),
+		author:         author,
+		statedb:        statedb,
+		gp:             gp,
+		usedGas:        usedGas,
+		cfg:            cfg,
+		extraValidator: extraValidator,
+		validate:       validate,
+		index:          index,
+		skip:           true,
+	}
+	included, receipts, _ := psp.applyTransactions(batch, txs)
+	return included, receipts
+}
+
+// separateTransactions separates system and regular transactions, returning the
+// block positions of the regular ones as well.
+func (psp *ParallelStateProcessor) separateTransactions(txs []*types.Transaction, signer types.Signer, header *types.Header, isPoSA bool, posa consensus.PoSA) ([]*types.Transaction, []*types.Transaction, []int, error) {
 	commonTxs := make([]*types.Transaction, 0, len(txs))
 	systemTxs := make([]*types.Transaction, 0)
-	
-	for _, tx := range txs {
+	indexes := make([]int, 0, len(txs))
+
+	for i, tx := range txs {
 		if isPoSA {
 			sender, err := types.Sender(signer, tx)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, nil, err
 			}
-			
+
 			ok, err := posa.IsSysTransaction(sender, tx, header)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, nil, err
 			}
-			
+
 			if ok {
 				systemTxs = append(systemTxs, tx)
 				continue
 			}
 		}
 		commonTxs = append(commonTxs, tx)
+		indexes = append(indexes, i)
 	}
-	
-	return commonTxs, systemTxs, nil
+
+	return commonTxs, systemTxs, indexes, nil
 }
 
-// processBatchedTransactions processes transactions in parallel batches
-func (psp *ParallelStateProcessor) processBatchedTransactions(txs []*types.Transaction, statedb *state.StateDB, vmenv *vm.EVM, gp *GasPool, usedGas *uint64, blockNumber *big.Int, blockHash common.Hash) ([]*types.Receipt, []*types.Log, error) {
-	receipts := make([]*types.Receipt, len(txs))
-	allLogs := make([]*types.Log, 0)
-	
-	batchSize := psp.config.TxBatchSize
-	numBatches := (len(txs) + batchSize - 1) / batchSize
-	
-	// Process batches sequentially to maintain state consistency
-	for batchIdx := 0; batchIdx < numBatches; batchIdx++ {
-		start := batchIdx * batchSize
-		end := start + batchSize
-		if end > len(txs) {
-			end = len(txs)
-		}
-		
-		batchTxs := txs[start:end]
-		batchReceipts, batchLogs, err := psp.processBatch(batchTxs, start, statedb, vmenv, gp, usedGas, blockNumber, blockHash)
-		if err != nil {
-			return nil, nil, err
-		}
-		
-		// Copy results
-		copy(receipts[start:end], batchReceipts)
-		allLogs = append(allLogs, batchLogs...)
-	}
-	
-	return receipts, allLogs, nil
+// parallelBatch is the environment of the transactions executed together by
+// applyTransactions.
+type parallelBatch struct {
+	header         *types.Header
+	hash           common.Hash
+	author         *common.Address
+	statedb        *state.StateDB
+	gp             *GasPool
+	usedGas        *uint64
+	cfg            vm.Config
+	extraValidator types.EvmExtraValidator
+
+	// validate runs the consensus checks of a transaction against the state
+	// left by the transactions before it, if not nil.
+	validate func(sender common.Address, tx *types.Transaction) error
+
+	indexes []int // block positions of the transactions, if known upfront
+	index   int   // position of the first transaction otherwise
+	skip    bool  // whether failing transactions are skipped instead of failing the batch
+}
+
+// parallelTask is the execution of a transaction of a batch.
+type parallelTask struct {
+	tx     *types.Transaction
+	msg    types.Message
+	err    error // error converting the transaction into a message
+	serial bool  // whether the transaction can only be applied to the block state
+
+	// Outcome of the speculative execution, writes is nil if it failed
+	state  *parallelTxState
+	result *ExecutionResult
+	writes parallelWriteSet
+
+	done chan struct{} // closed when the speculative execution ended
 }
 
-// processBatch processes a batch of transactions with parallel bloom filter creation
-func (psp *ParallelStateProcessor) processBatch(txs []*types.Transaction, startIdx int, statedb *state.StateDB, vmenv *vm.EVM, gp *GasPool, usedGas *uint64, blockNumber *big.Int, blockHash common.Hash) ([]*types.Receipt, []*types.Log, error) {
-	receipts := make([]*types.Receipt, len(txs))
-	allLogs := make([]*types.Log, 0)
-	
-	var bloomWg sync.WaitGroup
-	
-	// Process transactions sequentially within batch (for state consistency)
+// applyTransactions executes the transactions of a batch. The first one is
+// applied directly, then the rest are executed speculatively by a set of
+// workers, each against its own copy of the state. The workers see each
+// other's writes through a multi-version memory, so the later transactions
+// usually observe the effects of the earlier ones already. Transactions are
+// committed in order: a transaction is only committed if all the state it read
+// is still the same in the block state, otherwise it is executed again on top
+// of the committed state. Transactions the speculative state can not handle,
+// like x402 settlements, are applied to the block state directly.
+func (psp *ParallelStateProcessor) applyTransactions(batch *parallelBatch, txs []*types.Transaction) ([]*types.Transaction, []*types.Receipt, error) {
+	var (
+		config       = psp.StateProcessor.config
+		header       = batch.header
+		statedb      = batch.statedb
+		signer       = types.MakeSigner(config, header.Number)
+		blockContext = NewEVMBlockContext(header, psp.bc, batch.author)
+	)
+	blockContext.ExtraValidator = batch.extraValidator
+	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, config, batch.cfg)
+
+	tasks := make([]*parallelTask, len(txs))
 	for i, tx := range txs {
-		msg, err := tx.AsMessage(types.MakeSigner(psp.StateProcessor.config, blockNumber), nil)
-		if err != nil {
-			return nil, nil, fmt.Errorf("could not apply tx %d [%v]: %w", startIdx+i, tx.Hash().Hex(), err)
+		task := &parallelTask{tx: tx, done: make(chan struct{})}
+		task.msg, task.err = tx.AsMessage(signer, header.BaseFee)
+		task.serial = task.err != nil || tx.Type() == types.X402TxType
+		tasks[i] = task
+	}
+	// Pre-Byzantium receipts hold intermediate roots, and the speculative state
+	// only implements the EIP-158 account deletion. Tracing needs the block state.
+	parallel := psp.config.EnablePipelining && len(txs) > 1 && config.IsByzantium(header.Number) && config.IsEIP158(header.Number) && !batch.cfg.Debug
+
+	var (
+		mv      = newMVMemory()
+		window  chan struct{}
+		quit    = make(chan struct{})
+		wg      sync.WaitGroup
+		bloomWg sync.WaitGroup
+	)
+	defer func() {
+		close(quit)
+		wg.Wait()
+		bloomWg.Wait()
+	}()
+
+	var (
+		included = make([]*types.Transaction, 0, len(txs))
+		receipts = make([]*types.Receipt, 0, len(txs))
+	)
+	for i, task := range tasks {
+		if parallel && i == 1 {
+			window = psp.speculate(batch, blockContext.Coinbase, tasks, mv, quit, &wg)
+		}
+		if batch.skip && batch.gp.Gas() < params.TxGas {
+			log.Trace("Not enough gas for further transactions", "have", batch.gp, "want", params.TxGas)
+			break
+		}
+		index := batch.index + len(included)
+		if batch.indexes != nil {
+			index = batch.indexes[i]
+		}
+		if window != nil {
+			<-task.done
+		}
+		receipt, err := psp.commitTransaction(batch, vmenv, task, i, index, window != nil, mv, &bloomWg)
+		if window != nil {
+			<-window
 		}
-		
-		statedb.Prepare(tx.Hash(), startIdx+i)
-		
-		// Apply transaction
-		receipt, err := psp.applyTransactionParallel(msg, psp.StateProcessor.config, psp.bc, nil, gp, statedb, blockNumber, blockHash, tx, usedGas, vmenv, &bloomWg)
 		if err != nil {
-			return nil, nil, fmt.Errorf("could not apply tx %d [%v]: %w", startIdx+i, tx.Hash().Hex(), err)
+			if batch.skip {
+				log.Trace("Skipping transaction", "hash", task.tx.Hash(), "err", err)
+				continue
+			}
+			return nil, nil, err
 		}
-		
-		receipts[i] = receipt
-		allLogs = append(allLogs, receipt.Logs...)
+		included = append(included, task.tx)
+		receipts = append(receipts, receipt)
 	}
-	
-	// Wait for all bloom filters to be created
-	bloomWg.Wait()
-	
-	return receipts, allLogs, nil
+	return included, receipts, nil
 }
 
-// processPipelinedTransactions processes transactions using pipelining
-func (psp *ParallelStateProcessor) processPipelinedTransactions(txs []*types.Transaction, statedb *state.StateDB, vmenv *vm.EVM, gp *GasPool, usedGas *uint64, blockNumber *big.Int, blockHash common.Hash) ([]*types.Receipt, []*types.Log, error) {
-	receipts := make([]*types.Receipt, len(txs))
-	allLogs := make([]*types.Log, 0)
-	
-	// Pipeline stages: validation -> execution -> bloom creation
-	validationCh := make(chan *txValidationResult, psp.config.MaxTxConcurrency)
-	executionCh := make(chan *txExecutionResult, psp.config.MaxTxConcurrency)
-	
-	var wg sync.WaitGroup
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	
-	// Stage 1: Transaction validation
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		defer close(validationCh)
-		psp.validateTransactionsPipeline(ctx, txs, blockNumber, validationCh)
-	}()
-	
-	// Stage 2: Transaction execution
+// speculate starts the workers executing the transactions after the first one,
+// returning the semaphore bounding how far they run ahead of the commits.
+func (psp *ParallelStateProcessor) speculate(batch *parallelBatch, coinbase common.Address, tasks []*parallelTask, mv *mvMemory, quit chan struct{}, wg *sync.WaitGroup) chan struct{} {
+	ahead := len(tasks)
+	if psp.config.EnableTxBatching && psp.config.TxBatchSize > 0 && psp.config.TxBatchSize < ahead {
+		ahead = psp.config.TxBatchSize
+	}
+	workers := int(atomic.LoadInt32(&psp.maxConcurrency))
+	if workers > len(tasks)-1 {
+		workers = len(tasks) - 1
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	var (
+		window = make(chan struct{}, ahead)
+		next   = make(chan int)
+	)
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		defer close(executionCh)
-		psp.executeTransactionsPipeline(ctx, validationCh, statedb, vmenv, gp, usedGas, blockNumber, blockHash, executionCh)
+		defer close(next)
+
+		for i := 1; i < len(tasks); i++ {
+			select {
+			case window <- struct{}{}:
+			case <-quit:
+				return
+			}
+			select {
+			case next <- i:
+			case <-quit:
+				return
+			}
+		}
 	}()
-	
-	// Stage 3: Result collection and bloom creation
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		psp.collectResultsPipeline(ctx, executionCh, receipts, &allLogs)
+	for w := 0; w < workers; w++ {
+		// The state copies have to be made before the block state is modified
+		base := batch.statedb.Copy()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			blockContext := NewEVMBlockContext(batch.header, psp.bc, &coinbase)
+			blockContext.ExtraValidator = batch.extraValidator
+			for i := range next {
+				task := tasks[i]
+				if !task.serial {
+					state := newParallelTxState(i, base, mv)
+					task.state, task.result, task.writes = state, nil, nil
+					if result, writes, err := psp.execute(blockContext, batch.cfg, task.msg, state); err == nil {
+						task.result, task.writes = result, writes
+						mv.publish(i, writes)
+					}
+				}
+				close(task.done)
+			}
+		}()
+	}
+	return window
+}
+
+// execute runs a message against the transaction-local state, returning its
+// result along with the write set. Any panic caused by executing on top of an
+// inconsistent speculative state is turned into an error.
+func (psp *ParallelStateProcessor) execute(blockContext vm.BlockContext, cfg vm.Config, msg types.Message, state *parallelTxState) (result *ExecutionResult, writes parallelWriteSet, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, writes, err = nil, nil, fmt.Errorf("parallel execution panicked: %v", r)
+		}
 	}()
-	
-	wg.Wait()
-	
-	return receipts, allLogs, nil
+	evm := vm.NewEVM(blockContext, NewEVMTxContext(msg), state, psp.StateProcessor.config, cfg)
+	result, err = ApplyMessage(evm, msg, new(GasPool).AddGas(blockContext.GasLimit))
+	if err != nil {
+		return nil, nil, err
+	}
+	writes = state.finalise()
+	if state.unsupported {
+		return nil, nil, errParallelUnsupported
+	}
+	return result, writes, nil
 }
 
-// processSequentialTransactions processes transactions sequentially (fallback)
-func (psp *ParallelStateProcessor) processSequentialTransactions(txs []*types.Transaction, statedb *state.StateDB, vmenv *vm.EVM, gp *GasPool, usedGas *uint64, blockNumber *big.Int, blockHash common.Hash) ([]*types.Receipt, []*types.Log, error) {
-	receipts := make([]*types.Receipt, len(txs))
-	allLogs := make([]*types.Log, 0)
-	
-	var bloomWg sync.WaitGroup
-	
-	for i, tx := range txs {
-		msg, err := tx.AsMessage(types.MakeSigner(psp.StateProcessor.config, blockNumber), nil)
-		if err != nil {
-			return nil, nil, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+// commitTransaction applies a transaction to the block state, using the result
+// of its speculative execution if the state it read is still valid. If it is
+// not, the transaction is executed again on top of the block state and the new
+// writes are published to the transactions after it.
+func (psp *ParallelStateProcessor) commitTransaction(batch *parallelBatch, vmenv *vm.EVM, task *parallelTask, i int, index int, speculative bool, mv *mvMemory, bloomWg *sync.WaitGroup) (*types.Receipt, error) {
+	statedb := batch.statedb
+	if batch.validate != nil {
+		if err := batch.validate(task.msg.From(), task.tx); err != nil {
+			mv.publish(i, nil)
+			return nil, err
+		}
+	}
+	if task.err != nil {
+		mv.publish(i, nil)
+		return nil, fmt.Errorf("could not apply tx %d [%v]: %w", index, task.tx.Hash().Hex(), task.err)
+	}
+	statedb.Prepare(task.tx.Hash(), index)
+
+	result, writes := task.result, task.writes
+	if speculative && !task.serial {
+		if writes != nil && task.state.reads.validate(statedb) {
+			parallelAcceptedMeter.Mark(1)
+		} else {
+			task.state = newParallelTxState(i, statedb, nil)
+			result, writes, _ = psp.execute(vmenv.Context, batch.cfg, task.msg, task.state)
+			mv.publish(i, writes)
+			parallelReexecutedMeter.Mark(1)
+		}
+	}
+	// Transactions without a valid result, or exceeding the gas left in the
+	// block, are applied directly to fail the same way as in StateProcessor.
+	if writes == nil || batch.gp.Gas() < task.msg.Gas() {
+		if speculative {
+			parallelSerialMeter.Mark(1)
 		}
-		
-		statedb.Prepare(tx.Hash(), i)
-		
-		receipt, err := psp.applyTransactionParallel(msg, psp.StateProcessor.config, psp.bc, nil, gp, statedb, blockNumber, blockHash, tx, usedGas, vmenv, &bloomWg)
+		mv.publish(i, nil)
+		snap := statedb.Snapshot()
+		receipt, err := applyTransaction(task.msg, psp.StateProcessor.config, psp.bc, batch.author, batch.gp, statedb, batch.header.Number, batch.hash, task.tx, batch.usedGas, vmenv, CreatingBloomParallel(bloomWg))
 		if err != nil {
-			return nil, nil, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+			statedb.RevertToSnapshot(snap)
+			return nil, fmt.Errorf("could not apply tx %d [%v]: %w", index, task.tx.Hash().Hex(), err)
 		}
-		
-		receipts[i] = receipt
-		allLogs = append(allLogs, receipt.Logs...)
+		return receipt, nil
 	}
-	
-	bloomWg.Wait()
-	return receipts, allLogs, nil
-}
-
-// applyTransactionParallel applies a transaction with parallel bloom filter creation
-func (psp *ParallelStateProcessor) applyTransactionParallel(msg types.Message, config *params.ChainConfig, bc ChainContext, author *common.Address, gp *GasPool, statedb *state.StateDB, blockNumber *big.Int, blockHash common.Hash, tx *types.Transaction, usedGas *uint64, evm *vm.EVM, bloomWg *sync.WaitGroup) (*types.Receipt, error) {
-	// Create a new context to be used in the EVM environment
-	txContext := NewEVMTxContext(msg)
-	evm.Reset(txContext, statedb)
-	
-	// Apply the transaction to the current state
-	result, err := ApplyMessage(evm, msg, gp)
-	if err != nil {
+	if err := batch.gp.SubGas(result.UsedGas); err != nil {
 		return nil, err
 	}
-	
-	// Update the state with pending changes
-	var root []byte
-	if config.IsByzantium(blockNumber) {
-		statedb.Finalise(true)
-	} else {
-		root = statedb.IntermediateRoot(config.IsEIP158(blockNumber)).Bytes()
+	writes.apply(statedb)
+	for _, l := range task.state.logs {
+		statedb.AddLog(l)
 	}
-	*usedGas += result.UsedGas
-	
-	// Create receipt
-	receipt := &types.Receipt{Type: tx.Type(), PostState: root, CumulativeGasUsed: *usedGas}
+	for hash, preimage := range task.state.preimages {
+		statedb.AddPreimage(hash, preimage)
+	}
+	statedb.Finalise(true)
+	*batch.usedGas += result.UsedGas
+
+	// Create a new receipt for the transaction like applyTransaction does
+	receipt := &types.Receipt{Type: task.tx.Type(), CumulativeGasUsed: *batch.usedGas}
 	if result.Failed() {
 		receipt.Status = types.ReceiptStatusFailed
 	} else {
 		receipt.Status = types.ReceiptStatusSuccessful
 	}
-	receipt.TxHash = tx.Hash()
+	receipt.TxHash = task.tx.Hash()
 	receipt.GasUsed = result.UsedGas
-	
-	// Set contract address if contract creation
-	if msg.To() == nil {
-		receipt.ContractAddress = crypto.CreateAddress(evm.TxContext.Origin, tx.Nonce())
-	}
-	
-	// Set logs and create bloom filter
-	receipt.Logs = statedb.GetLogs(tx.Hash(), blockHash)
-	receipt.BlockHash = blockHash
-	receipt.BlockNumber = blockNumber
-	receipt.TransactionIndex = uint(statedb.TxIndex())
-	
-	// Create bloom filter in parallel if enabled
-	if psp.config.EnableBloomParallel && bloomWg != nil {
-		bloomWg.Add(1)
-		psp.processor.SubmitTask(&gopool.Task{
-			Type: gopool.TaskTypeTx,
-			Fn: func() error {
-				receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
-				return nil
-			},
-			OnComplete: func(err error) {
-				bloomWg.Done()
-				if err != nil {
-					log.Error("Failed to create bloom filter", "err", err)
-				}
-			},
-		})
-	} else {
-		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	if task.msg.To() == nil {
+		receipt.ContractAddress = crypto.CreateAddress(task.msg.From(), task.tx.Nonce())
 	}
-	
-	return receipt, nil
-}
-
-// Pipeline helper types and functions
-type txValidationResult struct {
-	index int
-	tx    *types.Transaction
-	msg   types.Message
-	err   error
-}
-
-type txExecutionResult struct {
-	index   int
-	receipt *types.Receipt
-	logs    []*types.Log
-	err     error
-}
+	receipt.Logs = statedb.GetLogs(task.tx.Hash(), batch.hash)
+	receipt.BlockHash = batch.hash
+	receipt.BlockNumber = batch.header.Number
+	receipt.TransactionIndex = uint(index)
+	psp.createBloom(receipt, bloomWg)
 
-func (psp *ParallelStateProcessor) validateTransactionsPipeline(ctx context.Context, txs []*types.Transaction, blockNumber *big.Int, output chan<- *txValidationResult) {
-	signer := types.MakeSigner(psp.StateProcessor.config, blockNumber)
-	
-	for i, tx := range txs {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
-		
-		msg, err := tx.AsMessage(signer, nil)
-		result := &txValidationResult{
-			index: i,
-			tx:    tx,
-			msg:   msg,
-			err:   err,
-		}
-		
-		select {
-		case output <- result:
-		case <-ctx.Done():
-			return
-		}
-	}
+	return receipt, nil
 }
 
-func (psp *ParallelStateProcessor) executeTransactionsPipeline(ctx context.Context, input <-chan *txValidationResult, statedb *state.StateDB, vmenv *vm.EVM, gp *GasPool, usedGas *uint64, blockNumber *big.Int, blockHash common.Hash, output chan<- *txExecutionResult) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case validation, ok := <-input:
-			if !ok {
-				return
-			}
-			
-			if validation.err != nil {
-				result := &txExecutionResult{
-					index: validation.index,
-					err:   validation.err,
-				}
-				select {
-				case output <- result:
-				case <-ctx.Done():
-					return
-				}
-				continue
-			}
-			
-			statedb.Prepare(validation.tx.Hash(), validation.index)
-			
-			receipt, err := psp.applyTransactionParallel(validation.msg, psp.StateProcessor.config, psp.bc, nil, gp, statedb, blockNumber, blockHash, validation.tx, usedGas, vmenv, nil)
-			
-			result := &txExecutionResult{
-				index:   validation.index,
-				receipt: receipt,
-				err:     err,
-			}
-			
-			if receipt != nil {
-				result.logs = receipt.Logs
-			}
-			
-			select {
-			case output <- result:
-			case <-ctx.Done():
-				return
-			}
-		}
+// createBloom creates the bloom filter of a receipt, in parallel if enabled.
+func (psp *ParallelStateProcessor) createBloom(receipt *types.Receipt, bloomWg *sync.WaitGroup) {
+	if !psp.config.EnableBloomParallel {
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+		return
 	}
-}
-
-func (psp *ParallelStateProcessor) collectResultsPipeline(ctx context.Context, input <-chan *txExecutionResult, receipts []*types.Receipt, allLogs *[]*types.Log) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case result, ok := <-input:
-			if !ok {
-				return
-			}
-			
-			if result.err != nil {
-				log.Error("Transaction execution failed", "index", result.index, "err", result.err)
-				continue
+	bloomWg.Add(1)
+	err := psp.processor.SubmitTask(&gopool.Task{
+		Type: gopool.TaskTypeTx,
+		Fn: func() error {
+			receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+			return nil
+		},
+		OnComplete: func(err error) {
+			bloomWg.Done()
+			if err != nil {
+				log.Error("Failed to create bloom filter", "err", err)
 			}
-			
-			receipts[result.index] = result.receipt
-			*allLogs = append(*allLogs, result.logs...)
-		}
+		},
+	})
+	if err != nil {
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+		bloomWg.Done()
 	}
 }
 
@@ -529,14 +569,14 @@ func (psp *ParallelStateProcessor) collectResultsPipeline(ctx context.Context, i
 func (psp *ParallelStateProcessor) updateMetrics(duration time.Duration) {
 	psp.mu.Lock()
 	defer psp.mu.Unlock()
-	
+
 	psp.processedBlocks++
 	if psp.avgBlockTime == 0 {
 		psp.avgBlockTime = duration
 	} else {
 		psp.avgBlockTime = (psp.avgBlockTime + duration) / 2
 	}
-	
+
 	// Adaptive scaling based on performance
 	if psp.config.AdaptiveScaling {
 		psp.adjustConcurrency(duration)
@@ -545,14 +585,14 @@ func (psp *ParallelStateProcessor) updateMetrics(duration time.Duration) {
 
 func (psp *ParallelStateProcessor) adjustConcurrency(duration time.Duration) {
 	currentConcurrency := atomic.LoadInt32(&psp.maxConcurrency)
-	
+
 	// If processing is too slow, reduce concurrency to avoid overhead
 	if duration > 5*time.Second && currentConcurrency > 1 {
 		newConcurrency := currentConcurrency * 8 / 10 // Reduce by 20%
 		atomic.StoreInt32(&psp.maxConcurrency, newConcurrency)
 		log.Debug("Reduced concurrency due to slow processing", "old", currentConcurrency, "new", newConcurrency)
 	}
-	
+
 	// If processing is fast and we have capacity, increase concurrency
 	if duration < 1*time.Second && currentConcurrency < int32(psp.config.MaxTxConcurrency) {
 		newConcurrency := currentConcurrency * 11 / 10 // Increase by 10%
@@ -568,22 +608,22 @@ func (psp *ParallelStateProcessor) adjustConcurrency(duration time.Duration) {
 func (psp *ParallelStateProcessor) GetStats() ParallelProcessorStats {
 	psp.mu.RLock()
 	defer psp.mu.RUnlock()
-	
+
 	processorStats := psp.processor.GetStats()
-	
+
 	return ParallelProcessorStats{
-		ProcessedBlocks:   psp.processedBlocks,
-		AvgBlockTime:      psp.avgBlockTime,
+		ProcessedBlocks:    psp.processedBlocks,
+		AvgBlockTime:       psp.avgBlockTime,
 		CurrentConcurrency: atomic.LoadInt32(&psp.maxConcurrency),
-		ProcessorStats:    processorStats,
+		ProcessorStats:     processorStats,
 	}
 }
 
 type ParallelProcessorStats struct {
-	ProcessedBlocks     uint64                  `json:"processedBlocks"`
-	AvgBlockTime        time.Duration           `json:"avgBlockTime"`
-	CurrentConcurrency  int32                   `json:"currentConcurrency"`
-	ProcessorStats      gopool.ProcessorStats   `json:"processorStats"`
+	ProcessedBlocks    uint64                `json:"processedBlocks"`
+	AvgBlockTime       time.Duration         `json:"avgBlockTime"`
+	CurrentConcurrency int32                 `json:"currentConcurrency"`
+	ProcessorStats     gopool.ProcessorStats `json:"processorStats"`
 }
 
 // Close shuts down the parallel processor
@@ -0,0 +1,328 @@
+// Copyright 2024 The Splendor Authors
+// Differential tests of the parallel state processor against the serial one
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// parallelCounter bumps a shared slot and a slot of the caller, then logs
+	// the caller. Every call conflicts with every other one.
+	parallelCounter = common.HexToAddress("0xc001")
+	// parallelLedger bumps the slot of the caller only.
+	parallelLedger = common.HexToAddress("0xc002")
+	// parallelReverter writes a slot and reverts.
+	parallelReverter = common.HexToAddress("0xc003")
+	// parallelSuicider self-destructs, sending its balance to the caller.
+	parallelSuicider = common.HexToAddress("0xc004")
+	// parallelObserver stores the balance of the coinbase.
+	parallelObserver = common.HexToAddress("0xc005")
+	// parallelClearer clears a preset slot, earning a refund.
+	parallelClearer = common.HexToAddress("0xc006")
+	// parallelForwarder calls parallelCounter, forwarding all gas.
+	parallelForwarder = common.HexToAddress("0xc007")
+
+	// parallelInitCode deploys a contract made of a single STOP.
+	parallelInitCode = common.FromHex("600060005360016000f3")
+)
+
+// parallelTestGenesis returns a genesis funding the given keys and holding the
+// test contracts.
+func parallelTestGenesis(keys []*ecdsa.PrivateKey) *Genesis {
+	alloc := GenesisAlloc{
+		parallelCounter:   {Code: common.FromHex("600054600101600055335460010133553360006000a100"), Balance: common.Big0},
+		parallelLedger:    {Code: common.FromHex("33546001013355" + "00"), Balance: common.Big0},
+		parallelReverter:  {Code: common.FromHex("600160005560006000fd"), Balance: common.Big0},
+		parallelSuicider:  {Code: common.FromHex("33ff"), Balance: big.NewInt(1000)},
+		parallelObserver:  {Code: common.FromHex("413160005500"), Balance: common.Big0},
+		parallelClearer:   {Code: common.FromHex("600060005500"), Balance: common.Big0, Storage: map[common.Hash]common.Hash{{}: common.BytesToHash([]byte{1})}},
+		parallelForwarder: {Code: common.FromHex("60006000600060006000" + "61c001" + "5af100"), Balance: common.Big0},
+	}
+	for _, key := range keys {
+		alloc[crypto.PubkeyToAddress(key.PublicKey)] = GenesisAccount{Balance: new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))}
+	}
+	return &Genesis{Config: params.TestChainConfig, Alloc: alloc, GasLimit: 30_000_000, BaseFee: big.NewInt(params.InitialBaseFee)}
+}
+
+// parallelTestChain generates blocks full of transactions mixing independent
+// transfers with conflicting contract calls, creations and self-destructs.
+func parallelTestChain(t *testing.T, blocks, txs int) (*Genesis, []*types.Block) {
+	keys := make([]*ecdsa.PrivateKey, 16)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	var (
+		gspec  = parallelTestGenesis(keys)
+		gendb  = rawdb.NewMemoryDatabase()
+		signer = types.LatestSigner(gspec.Config)
+		rnd    = rand.New(rand.NewSource(1))
+	)
+	genesis := gspec.MustCommit(gendb)
+	chain, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, blocks, func(i int, b *BlockGen) {
+		b.SetCoinbase(crypto.PubkeyToAddress(keys[0].PublicKey))
+		for j := 0; j < txs; j++ {
+			var (
+				key   = keys[rnd.Intn(len(keys))]
+				nonce = b.TxNonce(crypto.PubkeyToAddress(key.PublicKey))
+				to    *common.Address
+				value = new(big.Int)
+				data  []byte
+				gas   = uint64(200_000)
+			)
+			switch rnd.Intn(10) {
+			case 0:
+				to = &parallelCounter
+			case 1:
+				to = &parallelLedger
+			case 2:
+				to = &parallelReverter
+			case 3:
+				to, value = &parallelSuicider, big.NewInt(int64(rnd.Intn(2)))
+			case 4:
+				to = &parallelObserver
+			case 5:
+				to = &parallelClearer
+			case 6:
+				to = &parallelForwarder
+			case 7:
+				data = parallelInitCode
+			case 8:
+				// Zero value transfers to fresh accounts touch and delete them
+				addr := common.BytesToAddress(crypto.Keccak256([]byte{byte(i), byte(j)}))
+				to, gas = &addr, params.TxGas
+			default:
+				addr := crypto.PubkeyToAddress(keys[rnd.Intn(len(keys))].PublicKey)
+				to, value, gas = &addr, big.NewInt(rnd.Int63n(params.Ether)), params.TxGas
+			}
+			var tx *types.Transaction
+			if rnd.Intn(2) == 0 {
+				tx = types.NewTx(&types.LegacyTx{Nonce: nonce, GasPrice: b.BaseFee(), Gas: gas, To: to, Value: value, Data: data})
+			} else {
+				tx = types.NewTx(&types.DynamicFeeTx{ChainID: gspec.Config.ChainID, Nonce: nonce, GasTipCap: big.NewInt(int64(rnd.Intn(3))), GasFeeCap: new(big.Int).Mul(b.BaseFee(), common.Big2), Gas: gas, To: to, Value: value, Data: data})
+			}
+			tx, err := types.SignTx(tx, signer, key)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			b.AddTx(tx)
+		}
+	})
+	return gspec, chain
+}
+
+// Tests that processing blocks in parallel gives exactly the same receipts,
+// logs, gas usage and state as the serial state processor.
+func TestParallelStateProcessorDifferential(t *testing.T) {
+	gspec, blocks := parallelTestChain(t, 4, 200)
+
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+	chain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	for _, tt := range []struct {
+		concurrency int
+		batching    bool
+		batchSize   int
+	}{
+		{concurrency: 1},
+		{concurrency: 4, batching: true, batchSize: 8},
+		{concurrency: 16},
+	} {
+		config := DefaultParallelProcessorConfig()
+		config.MaxTxConcurrency, config.EnableTxBatching, config.TxBatchSize = tt.concurrency, tt.batching, tt.batchSize
+		config.AdaptiveScaling = false
+
+		psp, err := NewParallelStateProcessor(gspec.Config, chain, chain.Engine(), config)
+		if err != nil {
+			t.Fatalf("failed to create parallel state processor: %v", err)
+		}
+		for _, block := range blocks {
+			parent := chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+
+			serial, _ := chain.StateAt(parent.Root())
+			want, wantLogs, wantGas, err := chain.Processor().Process(block, serial, vm.Config{})
+			if err != nil {
+				t.Fatalf("block %d: serial processing failed: %v", block.NumberU64(), err)
+			}
+			parallel, _ := chain.StateAt(parent.Root())
+			have, haveLogs, haveGas, err := psp.Process(block, parallel, vm.Config{})
+			if err != nil {
+				t.Fatalf("concurrency %d, block %d: parallel processing failed: %v", tt.concurrency, block.NumberU64(), err)
+			}
+			if haveGas != wantGas {
+				t.Errorf("concurrency %d, block %d: gas mismatch: have %d, want %d", tt.concurrency, block.NumberU64(), haveGas, wantGas)
+			}
+			if !reflect.DeepEqual(have, want) {
+				t.Errorf("concurrency %d, block %d: receipts mismatch", tt.concurrency, block.NumberU64())
+			}
+			if !reflect.DeepEqual(haveLogs, wantLogs) {
+				t.Errorf("concurrency %d, block %d: logs mismatch", tt.concurrency, block.NumberU64())
+			}
+			if root := parallel.IntermediateRoot(true); root != block.Root() {
+				t.Errorf("concurrency %d, block %d: state root mismatch: have %x, want %x", tt.concurrency, block.NumberU64(), root, block.Root())
+			}
+		}
+		psp.Close()
+	}
+}
+
+// Tests that a chain importing blocks through the parallel state processor
+// accepts them.
+func TestParallelStateProcessorImport(t *testing.T) {
+	gspec, blocks := parallelTestChain(t, 3, 100)
+
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+	chain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil, EnableParallelImport(nil))
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, ok := chain.Processor().(*ParallelStateProcessor); !ok {
+		t.Fatalf("processor mismatch: have %T, want %T", chain.Processor(), &ParallelStateProcessor{})
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	if head := chain.CurrentBlock().Hash(); head != blocks[len(blocks)-1].Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head, blocks[len(blocks)-1].Hash())
+	}
+}
+
+// divergingProcessor is a Processor whose results never validate.
+type divergingProcessor struct {
+	Processor
+	fail bool // whether to fail processing instead
+}
+
+func (p *divergingProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+	if p.fail {
+		return nil, nil, 0, errors.New("diverged")
+	}
+	receipts, logs, usedGas, err := p.Processor.Process(block, statedb, cfg)
+	return receipts, logs, usedGas + 1, err
+}
+
+// Tests that blocks the parallel processor fails are imported through the
+// serial processor, while invalid blocks are still rejected.
+func TestParallelStateProcessorImportFallback(t *testing.T) {
+	gspec, blocks := parallelTestChain(t, 3, 50)
+
+	for _, fail := range []bool{false, true} {
+		db := rawdb.NewMemoryDatabase()
+		gspec.MustCommit(db)
+		chain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil, EnableParallelImport(nil))
+		if err != nil {
+			t.Fatalf("failed to create chain: %v", err)
+		}
+		chain.processor = &divergingProcessor{Processor: chain.processor, fail: fail}
+
+		if _, err := chain.InsertChain(blocks); err != nil {
+			t.Fatalf("fail %v: failed to insert chain: %v", fail, err)
+		}
+		if head := chain.CurrentBlock().Hash(); head != blocks[len(blocks)-1].Hash() {
+			t.Fatalf("fail %v: head mismatch: have %x, want %x", fail, head, blocks[len(blocks)-1].Hash())
+		}
+		chain.Stop()
+	}
+	// Blocks failing the serial processor too are rejected
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+	chain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil, EnableParallelImport(nil))
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	chain.processor = &divergingProcessor{Processor: chain.processor}
+	chain.serial = &divergingProcessor{Processor: chain.serial}
+
+	if _, err := chain.InsertChain(blocks); err == nil {
+		t.Fatalf("diverging block imported")
+	}
+}
+
+// Tests that applying transactions for block production skips the failing ones
+// like committing them one by one does.
+func TestParallelStateProcessorApplyTransactions(t *testing.T) {
+	gspec, blocks := parallelTestChain(t, 1, 150)
+
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+	chain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer chain.Stop()
+
+	psp, err := NewParallelStateProcessor(gspec.Config, chain, chain.Engine(), nil)
+	if err != nil {
+		t.Fatalf("failed to create parallel state processor: %v", err)
+	}
+	defer psp.Close()
+
+	// Replay the transactions of the block twice, every second time with a
+	// stale nonce, and interleave transactions the block can not afford
+	var (
+		header = blocks[0].Header()
+		txs    []*types.Transaction
+	)
+	for i, tx := range blocks[0].Transactions() {
+		txs = append(txs, tx)
+		if i%3 == 0 {
+			txs = append(txs, tx)
+		}
+	}
+	header.GasUsed = 0
+
+	serial, _ := chain.StateAt(genesis.Root())
+	var (
+		gp       = new(GasPool).AddGas(header.GasLimit)
+		wantGas  uint64
+		wantTxs  []*types.Transaction
+		receipts []*types.Receipt
+	)
+	for _, tx := range txs {
+		serial.Prepare(tx.Hash(), len(wantTxs))
+		snap := serial.Snapshot()
+		receipt, err := ApplyTransaction(gspec.Config, chain, &header.Coinbase, gp, serial, header, tx, &wantGas, vm.Config{}, nil)
+		if err != nil {
+			serial.RevertToSnapshot(snap)
+			continue
+		}
+		wantTxs, receipts = append(wantTxs, tx), append(receipts, receipt)
+	}
+	parallel, _ := chain.StateAt(genesis.Root())
+	var haveGas uint64
+	haveTxs, haveReceipts := psp.ApplyTransactions(header, &header.Coinbase, new(GasPool).AddGas(header.GasLimit), parallel, txs, 0, &haveGas, vm.Config{}, nil, nil)
+
+	if len(haveTxs) != len(wantTxs) || len(haveTxs) != len(blocks[0].Transactions()) {
+		t.Fatalf("included transactions mismatch: have %d, want %d", len(haveTxs), len(wantTxs))
+	}
+	if haveGas != wantGas {
+		t.Errorf("gas mismatch: have %d, want %d", haveGas, wantGas)
+	}
+	if !reflect.DeepEqual(haveReceipts, receipts) {
+		t.Errorf("receipts mismatch")
+	}
+	if have, want := parallel.IntermediateRoot(true), serial.IntermediateRoot(true); have != want {
+		t.Errorf("state root mismatch: have %x, want %x", have, want)
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2024 Splendor Blockchain
+// Database accessors for the x402 payment index
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// X402PaymentEntry is the indexed record of an x402 envelope included in the
+// canonical chain.
+type X402PaymentEntry struct {
+	BlockHash   common.Hash
+	BlockNumber uint64
+	TxIndex     uint64
+	Time        uint64
+	From        common.Address
+	To          common.Address
+	Value       *big.Int
+	Nonce       common.Hash
+	Resource    string
+	Status      uint64
+	Asset       common.Address `rlp:"optional"` // Zero for native payments
+}
+
+// X402Totals aggregates successful native x402 payments.
+type X402Totals struct {
+	Count  uint64
+	Volume *big.Int
+	Payers uint64 // Number of distinct payers, only maintained for the global totals
+}
+
+// ReadX402IndexHead retrieves the hash of the last block indexed by the x402
+// payment indexer.
+func ReadX402IndexHead(db ethdb.KeyValueReader) common.Hash {
+	data, _ := db.Get(x402IndexHeadKey)
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteX402IndexHead stores the hash of the last block indexed by the x402
+// payment indexer.
+func WriteX402IndexHead(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Put(x402IndexHeadKey, hash.Bytes()); err != nil {
+		log.Crit("Failed to store x402 index head", "err", err)
+	}
+}
+
+// ReadX402Payment retrieves the indexed x402 payment included by the given
+// transaction.
+func ReadX402Payment(db ethdb.KeyValueReader, hash common.Hash) *X402PaymentEntry {
+	data, _ := db.Get(x402PaymentKey(hash))
+	if len(data) == 0 {
+		return nil
+	}
+	entry := new(X402PaymentEntry)
+	if err := rlp.DecodeBytes(data, entry); err != nil {
+		log.Error("Invalid x402 payment entry RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return entry
+}
+
+// WriteX402Payment stores an x402 payment entry and links it into the
+// history of its payer and payee.
+func WriteX402Payment(db ethdb.KeyValueWriter, hash common.Hash, entry *X402PaymentEntry) {
+	data, err := rlp.EncodeToBytes(entry)
+	if err != nil {
+		log.Crit("Failed to RLP encode x402 payment entry", "err", err)
+	}
+	if err := db.Put(x402PaymentKey(hash), data); err != nil {
+		log.Crit("Failed to store x402 payment entry", "err", err)
+	}
+	for _, addr := range x402Parties(entry) {
+		if err := db.Put(x402AccountKey(addr, entry.BlockNumber, uint32(entry.TxIndex)), hash.Bytes()); err != nil {
+			log.Crit("Failed to store x402 account entry", "err", err)
+		}
+	}
+}
+
+// DeleteX402Payment removes an x402 payment entry and its history links.
+func DeleteX402Payment(db ethdb.KeyValueWriter, hash common.Hash, entry *X402PaymentEntry) {
+	if err := db.Delete(x402PaymentKey(hash)); err != nil {
+		log.Crit("Failed to delete x402 payment entry", "err", err)
+	}
+	for _, addr := range x402Parties(entry) {
+		if err := db.Delete(x402AccountKey(addr, entry.BlockNumber, uint32(entry.TxIndex))); err != nil {
+			log.Crit("Failed to delete x402 account entry", "err", err)
+		}
+	}
+}
+
+// x402Parties returns the distinct accounts whose history contains the payment.
+func x402Parties(entry *X402PaymentEntry) []common.Address {
+	if entry.From == entry.To {
+		return []common.Address{entry.From}
+	}
+	return []common.Address{entry.From, entry.To}
+}
+
+// ReadX402AccountPayments retrieves the hashes of the x402 payments sent or
+// received by the given account, most recent first, skipping the first offset
+// entries and returning at most limit entries.
+func ReadX402AccountPayments(db ethdb.Iteratee, addr common.Address, offset, limit int) []common.Hash {
+	prefix := append(append([]byte{}, x402AccountPrefix...), addr.Bytes()...)
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	var hashes []common.Hash
+	for skipped := 0; it.Next() && len(hashes) < limit; {
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		hashes = append(hashes, common.BytesToHash(it.Value()))
+	}
+	return hashes
+}
+
+func readX402Totals(db ethdb.KeyValueReader, key []byte) *X402Totals {
+	totals := &X402Totals{Volume: new(big.Int)}
+	data, _ := db.Get(key)
+	if len(data) == 0 {
+		return totals
+	}
+	if err := rlp.DecodeBytes(data, totals); err != nil {
+		log.Error("Invalid x402 totals RLP", "key", key, "err", err)
+		return &X402Totals{Volume: new(big.Int)}
+	}
+	return totals
+}
+
+func writeX402Totals(db ethdb.KeyValueWriter, key []byte, totals *X402Totals) {
+	if totals.Count == 0 && totals.Payers == 0 {
+		if err := db.Delete(key); err != nil {
+			log.Crit("Failed to delete x402 totals", "err", err)
+		}
+		return
+	}
+	data, err := rlp.EncodeToBytes(totals)
+	if err != nil {
+		log.Crit("Failed to RLP encode x402 totals", "err", err)
+	}
+	if err := db.Put(key, data); err != nil {
+		log.Crit("Failed to store x402 totals", "err", err)
+	}
+}
+
+// ReadX402Totals retrieves the aggregated statistics of all x402 payments.
+func ReadX402Totals(db ethdb.KeyValueReader) *X402Totals {
+	return readX402Totals(db, x402TotalsKey)
+}
+
+// WriteX402Totals stores the aggregated statistics of all x402 payments.
+func WriteX402Totals(db ethdb.KeyValueWriter, totals *X402Totals) {
+	writeX402Totals(db, x402TotalsKey, totals)
+}
+
+// ReadX402ResourceTotals retrieves the aggregated statistics of the x402
+// payments made for the given resource.
+func ReadX402ResourceTotals(db ethdb.KeyValueReader, resource string) *X402Totals {
+	return readX402Totals(db, x402ResourceKey(resource))
+}
+
+// WriteX402ResourceTotals stores the aggregated statistics of the x402
+// payments made for the given resource.
+func WriteX402ResourceTotals(db ethdb.KeyValueWriter, resource string, totals *X402Totals) {
+	writeX402Totals(db, x402ResourceKey(resource), totals)
+}
+
+// ReadX402DayTotals retrieves the aggregated statistics of the x402 payments
+// settled on the given day (unix time / 86400).
+func ReadX402DayTotals(db ethdb.KeyValueReader, day uint64) *X402Totals {
+	return readX402Totals(db, x402DayKey(day))
+}
+
+// WriteX402DayTotals stores the aggregated statistics of the x402 payments
+// settled on the given day (unix time / 86400).
+func WriteX402DayTotals(db ethdb.KeyValueWriter, day uint64, totals *X402Totals) {
+	writeX402Totals(db, x402DayKey(day), totals)
+}
+
+// ReadX402PayerCount retrieves the number of successful x402 payments sent by
+// the given account.
+func ReadX402PayerCount(db ethdb.KeyValueReader, addr common.Address) uint64 {
+	data, _ := db.Get(x402PayerKey(addr))
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteX402PayerCount stores the number of successful x402 payments sent by
+// the given account.
+func WriteX402PayerCount(db ethdb.KeyValueWriter, addr common.Address, count uint64) {
+	if count == 0 {
+		if err := db.Delete(x402PayerKey(addr)); err != nil {
+			log.Crit("Failed to delete x402 payer count", "err", err)
+		}
+		return
+	}
+	if err := db.Put(x402PayerKey(addr), encodeBlockNumber(count)); err != nil {
+		log.Crit("Failed to store x402 payer count", "err", err)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"encoding/binary"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/metrics"
 )
 
@@ -40,6 +41,9 @@ var (
 	// headFastBlockKey tracks the latest known incomplete block's hash during fast sync.
 	headFastBlockKey = []byte("LastFast")
 
+	// headFinalizedBlockKey tracks the latest known finalized block hash.
+	headFinalizedBlockKey = []byte("LastFinalized")
+
 	// lastPivotKey tracks the last pivot block used by fast sync (to reenable on sethead).
 	lastPivotKey = []byte("LastPivot")
 
@@ -76,6 +80,12 @@ var (
 	// uncleanShutdownKey tracks the list of local crashes
 	uncleanShutdownKey = []byte("unclean-shutdown") // config prefix for the db
 
+	// x402IndexHeadKey tracks the hash of the last block indexed by the x402 payment indexer.
+	x402IndexHeadKey = []byte("X402IndexHead")
+
+	// x402TotalsKey tracks the aggregated x402 payment statistics.
+	x402TotalsKey = []byte("X402Totals")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -97,6 +107,13 @@ var (
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
 
+	// x402 payment index prefixes
+	x402PaymentPrefix  = []byte("x4p") // x402PaymentPrefix + tx hash -> x402 payment entry
+	x402AccountPrefix  = []byte("x4a") // x402AccountPrefix + address + ^num (uint64 big endian) + ^index (uint32 big endian) -> tx hash
+	x402ResourcePrefix = []byte("x4r") // x402ResourcePrefix + resource hash -> x402 totals
+	x402DayPrefix      = []byte("x4d") // x402DayPrefix + day (uint64 big endian) -> x402 totals
+	x402PayerPrefix    = []byte("x4u") // x402PayerPrefix + address -> number of payments sent (uint64 big endian)
+
 	preimageCounter    = metrics.NewRegisteredCounter("db/preimage/total", nil)
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
 )
@@ -231,3 +248,35 @@ func IsCodeKey(key []byte) (bool, []byte) {
 func configKey(hash common.Hash) []byte {
 	return append(configPrefix, hash.Bytes()...)
 }
+
+// x402PaymentKey = x402PaymentPrefix + tx hash
+func x402PaymentKey(hash common.Hash) []byte {
+	return append(append([]byte{}, x402PaymentPrefix...), hash.Bytes()...)
+}
+
+// x402AccountKey = x402AccountPrefix + address + ^num (uint64 big endian) + ^index (uint32 big endian)
+//
+// The block number and index are inverted so that iterating the prefix yields
+// the most recent payments first.
+func x402AccountKey(addr common.Address, number uint64, index uint32) []byte {
+	key := append(append([]byte{}, x402AccountPrefix...), addr.Bytes()...)
+	key = append(key, make([]byte, 12)...)
+	binary.BigEndian.PutUint64(key[len(key)-12:], ^number)
+	binary.BigEndian.PutUint32(key[len(key)-4:], ^index)
+	return key
+}
+
+// x402ResourceKey = x402ResourcePrefix + resource hash
+func x402ResourceKey(resource string) []byte {
+	return append(append([]byte{}, x402ResourcePrefix...), crypto.Keccak256([]byte(resource))...)
+}
+
+// x402DayKey = x402DayPrefix + day (uint64 big endian)
+func x402DayKey(day uint64) []byte {
+	return append(append([]byte{}, x402DayPrefix...), encodeBlockNumber(day)...)
+}
+
+// x402PayerKey = x402PayerPrefix + address
+func x402PayerKey(addr common.Address) []byte {
+	return append(append([]byte{}, x402PayerPrefix...), addr.Bytes()...)
+}
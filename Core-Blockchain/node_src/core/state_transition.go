@@ -83,6 +83,10 @@ type Message interface {
 	IsFake() bool
 	Data() []byte
 	AccessList() types.AccessList
+
+	// PQSignatureSize returns the size of the post-quantum authentication data
+	// of the message, or zero for secp256k1 signed messages.
+	PQSignatureSize() uint64
 }
 
 // ExecutionResult includes all output after executing given evm
@@ -161,6 +165,19 @@ func IntrinsicGas(data []byte, accessList types.AccessList, isContractCreation b
 	return gas, nil
 }
 
+// PQIntrinsicGas adds the cost of verifying the ML-DSA authentication of a
+// post-quantum transaction, sigSize bytes of public key and signature, to the
+// intrinsic gas of the transaction.
+func PQIntrinsicGas(gas uint64, sigSize uint64) (uint64, error) {
+	if sigSize == 0 {
+		return gas, nil
+	}
+	if (math.MaxUint64-gas-params.TxPQSignatureGas)/params.TxPQSignatureByteGas < sigSize {
+		return 0, ErrGasUintOverflow
+	}
+	return gas + params.TxPQSignatureGas + sigSize*params.TxPQSignatureByteGas, nil
+}
+
 // NewStateTransition initialises and returns a new state transition object.
 func NewStateTransition(evm *vm.EVM, msg Message, gp *GasPool) *StateTransition {
 	return &StateTransition{
@@ -290,6 +307,7 @@ func (st *StateTransition) preCheck() error {
 		return err
 	}
 	if st.isMeta {
+		st.evm.CaptureAccountAccess(st.feeAddress)
 		return st.buyGasMeta()
 	}
 	return st.buyGas()
@@ -341,6 +359,12 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 	// 5. there is no overflow when calculating intrinsic gas
 	// 6. caller has enough balance to cover asset transfer for **topmost** call
 
+	// Report the accounts changed around the EVM execution to tracers following
+	// the state, before gas is bought
+	st.evm.CaptureAccountAccess(st.msg.From(), st.feeRecipient())
+	if to := st.msg.To(); to != nil {
+		st.evm.CaptureAccountAccess(*to)
+	}
 	// Check clauses 1-3, buy gas if everything is correct
 	if err := st.preCheck(); err != nil {
 		return nil, err
@@ -357,6 +381,9 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 	if err != nil {
 		return nil, err
 	}
+	if gas, err = PQIntrinsicGas(gas, msg.PQSignatureSize()); err != nil {
+		return nil, err
+	}
 	if st.gas < gas {
 		return nil, fmt.Errorf("%w: have %d, want %d", ErrIntrinsicGas, st.gas, gas)
 	}
@@ -402,11 +429,7 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 		effectiveTip = cmath.BigMin(st.gasTipCap, new(big.Int).Sub(st.gasFeeCap, st.evm.Context.BaseFee))
 	}
 	tip := new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), effectiveTip)
-	if st.evm.ChainConfig().Congress != nil {
-		st.state.AddBalance(consensus.FeeRecoder, tip)
-	} else {
-		st.state.AddBalance(st.evm.Context.Coinbase, tip)
-	}
+	st.state.AddBalance(st.feeRecipient(), tip)
 
 	return &ExecutionResult{
 		UsedGas:    st.gasUsed(),
@@ -415,6 +438,14 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 	}, nil
 }
 
+// feeRecipient returns the account collecting the transaction tips.
+func (st *StateTransition) feeRecipient() common.Address {
+	if st.evm.ChainConfig().Congress != nil {
+		return consensus.FeeRecoder
+	}
+	return st.evm.Context.Coinbase
+}
+
 func (st *StateTransition) refundGas(refundQuotient uint64) {
 	// Apply refund counter, capped to a refund quotient
 	refund := st.gasUsed() / refundQuotient
@@ -0,0 +1,177 @@
+// Copyright 2024 The Splendor Authors
+// This file contains the ML-DSA signed post-quantum transaction type
+
+package types
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/mldsa"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// PQTxType is the EIP-2718 typed transaction ID of post-quantum transactions,
+// which are authenticated by an ML-DSA signature instead of secp256k1.
+const PQTxType = 0x51
+
+var (
+	// ErrInvalidPQSig is returned if the ML-DSA signature of a post-quantum
+	// transaction does not verify against its public key.
+	ErrInvalidPQSig = errors.New("invalid post-quantum transaction signature")
+
+	// ErrInvalidPQAlgorithm is returned if a post-quantum transaction names an
+	// unknown ML-DSA algorithm ID.
+	ErrInvalidPQAlgorithm = errors.New("invalid post-quantum signature algorithm")
+)
+
+// PQTx is an EIP-1559 style transaction signed with ML-DSA (FIPS 204). The
+// sender is not recovered from the signature but derived from the public key
+// carried in the transaction, see PQAddress.
+type PQTx struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	Gas        uint64
+	To         *common.Address `rlp:"nil"` // nil means contract creation
+	Value      *big.Int
+	Data       []byte
+	AccessList AccessList
+
+	// Post-quantum authentication: the ML-DSA algorithm ID (params.MLDSA44_ID,
+	// MLDSA65_ID or MLDSA87_ID), the public key of the sender and the signature
+	// of the signer hash.
+	Algorithm byte
+	PublicKey []byte
+	Signature []byte
+}
+
+// copy creates a deep copy of the transaction data and initializes all fields.
+func (tx *PQTx) copy() TxData {
+	cpy := &PQTx{
+		Nonce:     tx.Nonce,
+		To:        copyAddressPtr(tx.To),
+		Data:      common.CopyBytes(tx.Data),
+		Gas:       tx.Gas,
+		Algorithm: tx.Algorithm,
+		PublicKey: common.CopyBytes(tx.PublicKey),
+		Signature: common.CopyBytes(tx.Signature),
+		// These are copied below.
+		AccessList: make(AccessList, len(tx.AccessList)),
+		Value:      new(big.Int),
+		ChainID:    new(big.Int),
+		GasTipCap:  new(big.Int),
+		GasFeeCap:  new(big.Int),
+	}
+	copy(cpy.AccessList, tx.AccessList)
+	if tx.Value != nil {
+		cpy.Value.Set(tx.Value)
+	}
+	if tx.ChainID != nil {
+		cpy.ChainID.Set(tx.ChainID)
+	}
+	if tx.GasTipCap != nil {
+		cpy.GasTipCap.Set(tx.GasTipCap)
+	}
+	if tx.GasFeeCap != nil {
+		cpy.GasFeeCap.Set(tx.GasFeeCap)
+	}
+	return cpy
+}
+
+// accessors for innerTx.
+func (tx *PQTx) txType() byte           { return PQTxType }
+func (tx *PQTx) chainID() *big.Int      { return tx.ChainID }
+func (tx *PQTx) accessList() AccessList { return tx.AccessList }
+func (tx *PQTx) data() []byte           { return tx.Data }
+func (tx *PQTx) gas() uint64            { return tx.Gas }
+func (tx *PQTx) gasFeeCap() *big.Int    { return tx.GasFeeCap }
+func (tx *PQTx) gasTipCap() *big.Int    { return tx.GasTipCap }
+func (tx *PQTx) gasPrice() *big.Int     { return tx.GasFeeCap }
+func (tx *PQTx) value() *big.Int        { return tx.Value }
+func (tx *PQTx) nonce() uint64          { return tx.Nonce }
+func (tx *PQTx) to() *common.Address    { return tx.To }
+
+// rawSignatureValues returns zero V, R and S values, post-quantum transactions
+// carry no secp256k1 signature.
+func (tx *PQTx) rawSignatureValues() (v, r, s *big.Int) {
+	return new(big.Int), new(big.Int), new(big.Int)
+}
+
+func (tx *PQTx) setSignatureValues(chainID, v, r, s *big.Int) {
+	tx.ChainID = chainID
+}
+
+// PQAddress returns the address of the account controlled by an ML-DSA public
+// key: the last 20 bytes of its Keccak256 hash, like for secp256k1 keys.
+func PQAddress(publicKey []byte) common.Address {
+	return common.BytesToAddress(crypto.Keccak256(publicKey)[12:])
+}
+
+// pqAlgorithm returns the ML-DSA algorithm name of an algorithm ID.
+func pqAlgorithm(id byte) (string, error) {
+	switch id {
+	case params.MLDSA44_ID:
+		return mldsa.MLDSA44, nil
+	case params.MLDSA65_ID:
+		return mldsa.MLDSA65, nil
+	case params.MLDSA87_ID:
+		return mldsa.MLDSA87, nil
+	default:
+		return "", ErrInvalidPQAlgorithm
+	}
+}
+
+// PQSignatureSize returns the combined size of the ML-DSA public key and
+// signature of a post-quantum transaction, and zero for other transactions.
+func (tx *Transaction) PQSignatureSize() uint64 {
+	if inner, ok := tx.inner.(*PQTx); ok {
+		return uint64(len(inner.PublicKey) + len(inner.Signature))
+	}
+	return 0
+}
+
+// PQAuthorization returns the algorithm ID, public key and signature of a
+// post-quantum transaction. The return values should not be modified by the
+// caller.
+func (tx *Transaction) PQAuthorization() (algorithm byte, publicKey, signature []byte) {
+	if inner, ok := tx.inner.(*PQTx); ok {
+		return inner.Algorithm, inner.PublicKey, inner.Signature
+	}
+	return 0, nil, nil
+}
+
+// SignPQTx signs a post-quantum transaction with the ML-DSA key pair of the
+// given algorithm ID, which are both recorded in the returned transaction.
+func SignPQTx(tx *Transaction, s Signer, algorithm byte, publicKey, secretKey []byte) (*Transaction, error) {
+	if tx.Type() != PQTxType {
+		return nil, ErrTxTypeNotSupported
+	}
+	name, err := pqAlgorithm(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := s.(pqSigner); !ok {
+		return nil, ErrTxTypeNotSupported
+	}
+	cpy := tx.inner.copy().(*PQTx)
+	// Check that chain ID of tx matches the signer. We also accept ID zero here,
+	// because it indicates that the chain ID was not specified in the tx.
+	if cpy.ChainID.Sign() != 0 && cpy.ChainID.Cmp(s.ChainID()) != 0 {
+		return nil, ErrInvalidChainId
+	}
+	cpy.ChainID = new(big.Int).Set(s.ChainID())
+	cpy.Algorithm = algorithm
+	cpy.PublicKey = common.CopyBytes(publicKey)
+	cpy.Signature = nil
+
+	signed := &Transaction{inner: cpy, time: tx.time}
+	h := s.Hash(signed)
+	if cpy.Signature, err = mldsa.SignMessage(name, h[:], secretKey); err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
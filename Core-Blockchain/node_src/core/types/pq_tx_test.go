@@ -0,0 +1,131 @@
+// Copyright 2024 The Splendor Authors
+// Tests of the ML-DSA signed post-quantum transaction type
+
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto/mldsa"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func signedPQTx(t *testing.T, signer Signer) (*Transaction, []byte) {
+	publicKey, secretKey, err := mldsa.GenerateKeyPair(mldsa.MLDSA44)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	to := common.HexToAddress("0x0000000000000000000000000000000000000aaa")
+	tx := NewTx(&PQTx{
+		Nonce:     3,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(10),
+		Gas:       100000,
+		To:        &to,
+		Value:     big.NewInt(5),
+		Data:      []byte{0xde, 0xad},
+		AccessList: AccessList{
+			{Address: to, StorageKeys: []common.Hash{{1}}},
+		},
+	})
+	signed, err := SignPQTx(tx, signer, params.MLDSA44_ID, publicKey, secretKey)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	return signed, publicKey
+}
+
+func TestPQTxSender(t *testing.T) {
+	signer := NewPQSigner(big.NewInt(18))
+	tx, publicKey := signedPQTx(t, signer)
+
+	from, err := Sender(signer, tx)
+	if err != nil {
+		t.Fatalf("failed to derive sender: %v", err)
+	}
+	if want := PQAddress(publicKey); from != want {
+		t.Errorf("sender mismatch: have %x, want %x", from, want)
+	}
+	if size := tx.PQSignatureSize(); size != params.MLDSA44PublicKeySize+params.MLDSA44SignatureSize {
+		t.Errorf("signature size mismatch: have %d", size)
+	}
+	if _, err := Sender(NewPQSigner(big.NewInt(19)), tx); err != ErrInvalidChainId {
+		t.Errorf("other chain: have %v, want %v", err, ErrInvalidChainId)
+	}
+	if _, err := Sender(NewLondonSigner(big.NewInt(18)), tx); err != ErrTxTypeNotSupported {
+		t.Errorf("london signer: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+	if _, err := SignPQTx(tx, NewLondonSigner(big.NewInt(18)), params.MLDSA44_ID, nil, nil); err != ErrTxTypeNotSupported {
+		t.Errorf("signing with london signer: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+}
+
+func TestPQTxTampered(t *testing.T) {
+	signer := NewPQSigner(big.NewInt(18))
+	tx, _ := signedPQTx(t, signer)
+
+	// Change a signed field
+	inner := tx.inner.copy().(*PQTx)
+	inner.Nonce++
+	if _, err := Sender(signer, NewTx(inner)); err != ErrInvalidPQSig {
+		t.Errorf("changed nonce: have %v, want %v", err, ErrInvalidPQSig)
+	}
+	// Swap in another key, which changes the sender
+	other, _ := signedPQTx(t, signer)
+	inner = tx.inner.copy().(*PQTx)
+	_, inner.PublicKey, _ = other.PQAuthorization()
+	if _, err := Sender(signer, NewTx(inner)); err != ErrInvalidPQSig {
+		t.Errorf("changed key: have %v, want %v", err, ErrInvalidPQSig)
+	}
+	// Unknown algorithm
+	inner = tx.inner.copy().(*PQTx)
+	inner.Algorithm = params.MLKEM768_ID
+	if _, err := Sender(signer, NewTx(inner)); err != ErrInvalidPQAlgorithm {
+		t.Errorf("changed algorithm: have %v, want %v", err, ErrInvalidPQAlgorithm)
+	}
+}
+
+func TestPQTxEncoding(t *testing.T) {
+	signer := NewPQSigner(big.NewInt(18))
+	tx, _ := signedPQTx(t, signer)
+
+	// Binary (eth_sendRawTransaction) encoding
+	blob, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	if blob[0] != PQTxType {
+		t.Fatalf("wrong type prefix %#x", blob[0])
+	}
+	var decoded Transaction
+	if err := decoded.UnmarshalBinary(blob); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if decoded.Hash() != tx.Hash() {
+		t.Errorf("hash mismatch after decoding")
+	}
+	want, _ := Sender(signer, tx)
+	if from, err := Sender(signer, &decoded); err != nil || from != want {
+		t.Errorf("decoded sender: have %x (%v), want %x", from, err, want)
+	}
+	// JSON encoding
+	enc, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("failed to encode json: %v", err)
+	}
+	var parsed Transaction
+	if err := json.Unmarshal(enc, &parsed); err != nil {
+		t.Fatalf("failed to decode json: %v", err)
+	}
+	if parsed.Hash() != tx.Hash() {
+		t.Errorf("hash mismatch after json decoding")
+	}
+	_, _, sig := parsed.PQAuthorization()
+	if _, _, have := tx.PQAuthorization(); !bytes.Equal(sig, have) {
+		t.Errorf("signature mismatch after json decoding")
+	}
+}
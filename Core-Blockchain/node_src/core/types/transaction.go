@@ -192,6 +192,10 @@ func (tx *Transaction) decodeTyped(b []byte) (TxData, error) {
 		var inner X402Tx
 		err := rlp.DecodeBytes(b[1:], &inner)
 		return &inner, err
+	case PQTxType:
+		var inner PQTx
+		err := rlp.DecodeBytes(b[1:], &inner)
+		return &inner, err
 	default:
 		return nil, ErrTxTypeNotSupported
 	}
@@ -335,6 +339,10 @@ func (tx *Transaction) EffectiveGasTip(baseFee *big.Int) (*big.Int, error) {
 	if baseFee == nil {
 		return tx.GasTipCap(), nil
 	}
+	// x402 envelopes are settled fee-free, so they are exempt from the base fee.
+	if tx.Type() == X402TxType {
+		return new(big.Int), nil
+	}
 	var err error
 	gasFeeCap := tx.GasFeeCap()
 	if gasFeeCap.Cmp(baseFee) == -1 {
@@ -582,6 +590,8 @@ type Message struct {
 	data       []byte
 	accessList AccessList
 	isFake     bool
+
+	pqSignatureSize uint64
 }
 
 func NewMessage(from common.Address, to *common.Address, nonce uint64, amount *big.Int, gasLimit uint64, gasPrice, gasFeeCap, gasTipCap *big.Int, data []byte, accessList AccessList, isFake bool) Message {
@@ -613,6 +623,8 @@ func (tx *Transaction) AsMessage(s Signer, baseFee *big.Int) (Message, error) {
 		data:       tx.Data(),
 		accessList: tx.AccessList(),
 		isFake:     false,
+
+		pqSignatureSize: tx.PQSignatureSize(),
 	}
 	// If baseFee provided, set gasPrice to effectiveGasPrice.
 	if baseFee != nil {
@@ -635,6 +647,11 @@ func (m Message) Data() []byte           { return m.data }
 func (m Message) AccessList() AccessList { return m.accessList }
 func (m Message) IsFake() bool           { return m.isFake }
 
+// PQSignatureSize returns the size of the ML-DSA public key and signature of
+// the transaction the message was created from, which is charged for as
+// intrinsic gas.
+func (m Message) PQSignatureSize() uint64 { return m.pqSignatureSize }
+
 // copyAddressPtr copies an address.
 func copyAddressPtr(a *common.Address) *common.Address {
 	if a == nil {
@@ -47,6 +47,11 @@ type txJSON struct {
 	ChainID    *hexutil.Big `json:"chainId,omitempty"`
 	AccessList *AccessList  `json:"accessList,omitempty"`
 
+	// Post-quantum transaction fields:
+	PQAlgorithm *hexutil.Uint64 `json:"pqAlgorithm,omitempty"`
+	PQPublicKey *hexutil.Bytes  `json:"pqPublicKey,omitempty"`
+	PQSignature *hexutil.Bytes  `json:"pqSignature,omitempty"`
+
 	// Only used for encoding:
 	Hash common.Hash `json:"hash"`
 }
@@ -95,6 +100,20 @@ func (t *Transaction) MarshalJSON() ([]byte, error) {
 		enc.V = (*hexutil.Big)(tx.V)
 		enc.R = (*hexutil.Big)(tx.R)
 		enc.S = (*hexutil.Big)(tx.S)
+	case *PQTx:
+		enc.ChainID = (*hexutil.Big)(tx.ChainID)
+		enc.AccessList = &tx.AccessList
+		enc.Nonce = (*hexutil.Uint64)(&tx.Nonce)
+		enc.Gas = (*hexutil.Uint64)(&tx.Gas)
+		enc.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap)
+		enc.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap)
+		enc.Value = (*hexutil.Big)(tx.Value)
+		enc.Data = (*hexutil.Bytes)(&tx.Data)
+		enc.To = t.To()
+		algorithm := hexutil.Uint64(tx.Algorithm)
+		enc.PQAlgorithm = &algorithm
+		enc.PQPublicKey = (*hexutil.Bytes)(&tx.PublicKey)
+		enc.PQSignature = (*hexutil.Bytes)(&tx.Signature)
 	}
 	return json.Marshal(&enc)
 }
@@ -264,6 +283,57 @@ func (t *Transaction) UnmarshalJSON(input []byte) error {
 			}
 		}
 
+	case PQTxType:
+		var itx PQTx
+		inner = &itx
+		// Access list is optional for now.
+		if dec.AccessList != nil {
+			itx.AccessList = *dec.AccessList
+		}
+		if dec.ChainID == nil {
+			return errors.New("missing required field 'chainId' in transaction")
+		}
+		itx.ChainID = (*big.Int)(dec.ChainID)
+		if dec.To != nil {
+			itx.To = dec.To
+		}
+		if dec.Nonce == nil {
+			return errors.New("missing required field 'nonce' in transaction")
+		}
+		itx.Nonce = uint64(*dec.Nonce)
+		if dec.MaxPriorityFeePerGas == nil {
+			return errors.New("missing required field 'maxPriorityFeePerGas' for txdata")
+		}
+		itx.GasTipCap = (*big.Int)(dec.MaxPriorityFeePerGas)
+		if dec.MaxFeePerGas == nil {
+			return errors.New("missing required field 'maxFeePerGas' for txdata")
+		}
+		itx.GasFeeCap = (*big.Int)(dec.MaxFeePerGas)
+		if dec.Gas == nil {
+			return errors.New("missing required field 'gas' for txdata")
+		}
+		itx.Gas = uint64(*dec.Gas)
+		if dec.Value == nil {
+			return errors.New("missing required field 'value' in transaction")
+		}
+		itx.Value = (*big.Int)(dec.Value)
+		if dec.Data == nil {
+			return errors.New("missing required field 'input' in transaction")
+		}
+		itx.Data = *dec.Data
+		if dec.PQAlgorithm == nil || uint64(*dec.PQAlgorithm) > 0xff {
+			return errors.New("missing or invalid field 'pqAlgorithm' in transaction")
+		}
+		itx.Algorithm = byte(*dec.PQAlgorithm)
+		if dec.PQPublicKey == nil {
+			return errors.New("missing required field 'pqPublicKey' in transaction")
+		}
+		itx.PublicKey = *dec.PQPublicKey
+		if dec.PQSignature == nil {
+			return errors.New("missing required field 'pqSignature' in transaction")
+		}
+		itx.Signature = *dec.PQSignature
+
 	default:
 		return ErrTxTypeNotSupported
 	}
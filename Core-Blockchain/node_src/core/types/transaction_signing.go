@@ -25,6 +25,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/mldsa"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -41,6 +42,8 @@ type sigCache struct {
 func MakeSigner(config *params.ChainConfig, blockNumber *big.Int) Signer {
 	var signer Signer
 	switch {
+	case config.IsPQTFork(blockNumber) && config.IsLondon(blockNumber):
+		signer = NewPQSigner(config.ChainID)
 	case config.IsLondon(blockNumber):
 		signer = NewLondonSigner(config.ChainID)
 	case config.IsBerlin(blockNumber):
@@ -64,6 +67,9 @@ func MakeSigner(config *params.ChainConfig, blockNumber *big.Int) Signer {
 // have the current block number available, use MakeSigner instead.
 func LatestSigner(config *params.ChainConfig) Signer {
 	if config.ChainID != nil {
+		if config.PostQuantum != nil && config.PostQuantum.PQTBlock != nil && config.LondonBlock != nil {
+			return NewPQSigner(config.ChainID)
+		}
 		if config.LondonBlock != nil {
 			return NewLondonSigner(config.ChainID)
 		}
@@ -88,7 +94,7 @@ func LatestSignerForChainID(chainID *big.Int) Signer {
 	if chainID == nil {
 		return HomesteadSigner{}
 	}
-	return NewLondonSigner(chainID)
+	return NewPQSigner(chainID)
 }
 
 // SignTx signs the transaction using the given signer and private key.
@@ -171,9 +177,76 @@ type Signer interface {
 	Equal(Signer) bool
 }
 
+type pqSigner struct{ londonSigner }
+
+// NewPQSigner returns a signer that accepts
+// - ML-DSA signed post-quantum transactions, and
+// - all transaction types accepted by the London signer.
+func NewPQSigner(chainId *big.Int) Signer {
+	return pqSigner{londonSigner{eip2930Signer{NewEIP155Signer(chainId)}}}
+}
+
+func (s pqSigner) Sender(tx *Transaction) (common.Address, error) {
+	if tx.Type() != PQTxType {
+		return s.londonSigner.Sender(tx)
+	}
+	if tx.ChainId().Cmp(s.chainId) != 0 {
+		return common.Address{}, ErrInvalidChainId
+	}
+	id, publicKey, signature := tx.PQAuthorization()
+	algorithm, err := pqAlgorithm(id)
+	if err != nil {
+		return common.Address{}, err
+	}
+	h := s.Hash(tx)
+	if err := mldsa.VerifySignature(algorithm, h[:], signature, publicKey); err != nil {
+		return common.Address{}, ErrInvalidPQSig
+	}
+	return PQAddress(publicKey), nil
+}
+
+func (s pqSigner) Equal(s2 Signer) bool {
+	x, ok := s2.(pqSigner)
+	return ok && x.chainId.Cmp(s.chainId) == 0
+}
+
+// SignatureValues rejects post-quantum transactions, which are signed with
+// SignPQTx instead.
+func (s pqSigner) SignatureValues(tx *Transaction, sig []byte) (R, S, V *big.Int, err error) {
+	if tx.Type() == PQTxType {
+		return nil, nil, nil, ErrTxTypeNotSupported
+	}
+	return s.londonSigner.SignatureValues(tx, sig)
+}
+
+// Hash returns the hash to be signed by the sender.
+// It does not uniquely identify the transaction.
+func (s pqSigner) Hash(tx *Transaction) common.Hash {
+	if tx.Type() != PQTxType {
+		return s.londonSigner.Hash(tx)
+	}
+	algorithm, publicKey, _ := tx.PQAuthorization()
+	return prefixedRlpHash(
+		tx.Type(),
+		[]interface{}{
+			s.chainId,
+			tx.Nonce(),
+			tx.GasTipCap(),
+			tx.GasFeeCap(),
+			tx.Gas(),
+			tx.To(),
+			tx.Value(),
+			tx.Data(),
+			tx.AccessList(),
+			algorithm,
+			publicKey,
+		})
+}
+
 type londonSigner struct{ eip2930Signer }
 
 // NewLondonSigner returns a signer that accepts
+// - x402 settlement envelopes,
 // - EIP-1559 dynamic fee transactions
 // - EIP-2930 access list transactions,
 // - EIP-155 replay protected transactions, and
@@ -183,11 +256,11 @@ func NewLondonSigner(chainId *big.Int) Signer {
 }
 
 func (s londonSigner) Sender(tx *Transaction) (common.Address, error) {
-	if tx.Type() != DynamicFeeTxType {
+	if tx.Type() != DynamicFeeTxType && tx.Type() != X402TxType {
 		return s.eip2930Signer.Sender(tx)
 	}
 	V, R, S := tx.RawSignatureValues()
-	// DynamicFee txs are defined to use 0 and 1 as their recovery
+	// DynamicFee and x402 txs are defined to use 0 and 1 as their recovery
 	// id, add 27 to become equivalent to unprotected Homestead signatures.
 	V = new(big.Int).Add(V, big.NewInt(27))
 	if tx.ChainId().Cmp(s.chainId) != 0 {
@@ -202,13 +275,18 @@ func (s londonSigner) Equal(s2 Signer) bool {
 }
 
 func (s londonSigner) SignatureValues(tx *Transaction, sig []byte) (R, S, V *big.Int, err error) {
-	txdata, ok := tx.inner.(*DynamicFeeTx)
-	if !ok {
+	var chainID *big.Int
+	switch txdata := tx.inner.(type) {
+	case *DynamicFeeTx:
+		chainID = txdata.ChainID
+	case *X402Tx:
+		chainID = txdata.chainID()
+	default:
 		return s.eip2930Signer.SignatureValues(tx, sig)
 	}
 	// Check that chain ID of tx matches the signer. We also accept ID zero here,
 	// because it indicates that the chain ID was not specified in the tx.
-	if txdata.ChainID.Sign() != 0 && txdata.ChainID.Cmp(s.chainId) != 0 {
+	if chainID.Sign() != 0 && chainID.Cmp(s.chainId) != 0 {
 		return nil, nil, nil, ErrInvalidChainId
 	}
 	R, S, _ = decodeSignature(sig)
@@ -219,6 +297,21 @@ func (s londonSigner) SignatureValues(tx *Transaction, sig []byte) (R, S, V *big
 // Hash returns the hash to be signed by the sender.
 // It does not uniquely identify the transaction.
 func (s londonSigner) Hash(tx *Transaction) common.Hash {
+	if tx.Type() == X402TxType {
+		return prefixedRlpHash(
+			tx.Type(),
+			[]interface{}{
+				s.chainId,
+				tx.Nonce(),
+				tx.GasPrice(),
+				tx.GasTipCap(),
+				tx.GasFeeCap(),
+				tx.Gas(),
+				tx.To(),
+				tx.Value(),
+				tx.Data(),
+			})
+	}
 	if tx.Type() != DynamicFeeTxType {
 		return s.eip2930Signer.Hash(tx)
 	}
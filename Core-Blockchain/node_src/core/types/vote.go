@@ -0,0 +1,99 @@
+// Copyright 2024 The Splendor Authors
+// This file contains the finality votes validators gossip on recent blocks
+
+package types
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// votePrefix separates the data signed by votes from the data signed by block
+// seals, so that neither can be passed off as the other.
+var votePrefix = []byte("congress-vote")
+
+// ErrInvalidVoteSig is returned if the signature of a vote is malformed.
+var ErrInvalidVoteSig = errors.New("invalid vote signature")
+
+// Vote is a validator's attestation that a block is part of its canonical chain.
+type Vote struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	Signature   []byte // secp256k1 signature of the keccak256 hash of SigningData
+
+	// caches
+	hash   atomic.Value
+	signer atomic.Value
+}
+
+// voteData is the part of a vote covered by its signature.
+type voteData struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+}
+
+// voteRLP is the network encoding of a vote.
+type voteRLP struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	Signature   []byte
+}
+
+// NewVote creates an unsigned vote on a block.
+func NewVote(number uint64, hash common.Hash) *Vote {
+	return &Vote{BlockNumber: number, BlockHash: hash}
+}
+
+// EncodeRLP implements rlp.Encoder.
+func (v *Vote) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, &voteRLP{v.BlockNumber, v.BlockHash, v.Signature})
+}
+
+// DecodeRLP implements rlp.Decoder.
+func (v *Vote) DecodeRLP(s *rlp.Stream) error {
+	var dec voteRLP
+	if err := s.Decode(&dec); err != nil {
+		return err
+	}
+	v.BlockNumber, v.BlockHash, v.Signature = dec.BlockNumber, dec.BlockHash, dec.Signature
+	return nil
+}
+
+// SigningData returns the data the validator signs to cast the vote.
+func (v *Vote) SigningData() []byte {
+	enc, _ := rlp.EncodeToBytes(&voteData{v.BlockNumber, v.BlockHash})
+	return append(common.CopyBytes(votePrefix), enc...)
+}
+
+// Hash returns the hash identifying the vote, including its signature.
+func (v *Vote) Hash() common.Hash {
+	if hash := v.hash.Load(); hash != nil {
+		return hash.(common.Hash)
+	}
+	h := rlpHash(&voteRLP{v.BlockNumber, v.BlockHash, v.Signature})
+	v.hash.Store(h)
+	return h
+}
+
+// Signer recovers the address of the validator that cast the vote.
+func (v *Vote) Signer() (common.Address, error) {
+	if signer := v.signer.Load(); signer != nil {
+		return signer.(common.Address), nil
+	}
+	if len(v.Signature) != crypto.SignatureLength {
+		return common.Address{}, ErrInvalidVoteSig
+	}
+	pubkey, err := crypto.Ecrecover(crypto.Keccak256(v.SigningData()), v.Signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var signer common.Address
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+	v.signer.Store(signer)
+	return signer, nil
+}
@@ -1,21 +1,196 @@
 package types
 
 import (
+	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // X402TxType is the EIP-2718 typed transaction ID for x402 settlement envelopes.
 // Pick a high, unused value to avoid collisions with upstream types.
 const X402TxType = 0x50
 
+// Supported x402 payment schemes.
+const (
+	// X402SchemeExact settles exactly the signed amount.
+	X402SchemeExact = "exact"
+
+	// X402SchemeUpto settles any amount up to the signed ceiling (metered usage).
+	X402SchemeUpto = "upto"
+)
+
+var (
+	// X402RegistryAddress is the pseudo-contract whose storage records the
+	// consumed (payer, nonce) pairs and which emits settlement logs.
+	X402RegistryAddress = common.HexToAddress("0x0000000000000000000000000000000000000402")
+
+	// X402SettledTopic is the topic of the log emitted for every successful
+	// settlement: X402Settled(address indexed from, address indexed to, uint256 value, bytes32 nonce).
+	X402SettledTopic = crypto.Keccak256Hash([]byte("X402Settled(address,address,uint256,bytes32)"))
+
+	// X402DomainTypeHash is the EIP-712 type hash of the x402 signing domain.
+	X402DomainTypeHash = crypto.Keccak256Hash([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+
+	// X402PaymentAuthorizationTypeHash is the EIP-712 type hash of the
+	// PaymentAuthorization struct signed by x402 payers.
+	X402PaymentAuthorizationTypeHash = crypto.Keccak256Hash([]byte("PaymentAuthorization(string scheme,address from,address to,uint256 value,uint256 validAfter,uint256 validBefore,bytes32 nonce)"))
+
+	errX402SignatureLength = errors.New("x402: invalid signature length")
+)
+
+// EIP-712 domain of x402 payment authorizations, the verifying contract being
+// X402RegistryAddress.
+const (
+	X402DomainName    = "x402"
+	X402DomainVersion = "1"
+)
+
+// X402Payload is the RLP-encoded settlement payload carried in the Input
+// field of an X402Tx envelope.
+type X402Payload struct {
+	From        common.Address
+	To          common.Address
+	Value       *big.Int
+	ValidAfter  uint64
+	ValidBefore uint64
+	Nonce       common.Hash
+	Signature   []byte
+
+	// Resource is the URL of the paid resource, recorded for indexing only.
+	Resource string `rlp:"optional"`
+
+	// Scheme is the payment scheme, an empty scheme is treated as "exact".
+	Scheme string `rlp:"optional"`
+
+	// MaxValue is the ceiling signed by the payer for the "upto" scheme, Value
+	// is then the settled amount and must not exceed it.
+	MaxValue *big.Int `rlp:"optional"`
+
+	// Asset is the ERC-20 token of the payment, the zero address denoting the
+	// native coin. Token payments carry the payer's EIP-3009 authorization
+	// signature instead of an x402 payment signature.
+	Asset common.Address `rlp:"optional"`
+}
+
+// DecodeX402Payload decodes the settlement payload of an x402 envelope.
+func DecodeX402Payload(data []byte) (*X402Payload, error) {
+	p := new(X402Payload)
+	if err := rlp.DecodeBytes(data, p); err != nil {
+		return nil, fmt.Errorf("x402: invalid payload: %w", err)
+	}
+	if p.Value == nil {
+		p.Value = new(big.Int)
+	}
+	return p, nil
+}
+
+// X402PaymentMessage returns the canonical message signed by the payer of an
+// x402 payment. For the "upto" scheme value is the signed ceiling.
+func X402PaymentMessage(scheme string, from, to common.Address, value *big.Int, validAfter, validBefore uint64, nonce common.Hash, chainID uint64) string {
+	prefix := "x402-payment"
+	if scheme == X402SchemeUpto {
+		prefix = "x402-upto"
+	}
+	return fmt.Sprintf("%s:%s:%s:%s:%d:%d:%s:%d",
+		prefix, from.Hex(), to.Hex(), hexutil.EncodeBig(value), validAfter, validBefore, nonce.Hex(), chainID)
+}
+
+// X402DomainSeparator returns the EIP-712 domain separator of x402 payment
+// authorizations on the given chain.
+func X402DomainSeparator(chainID *big.Int) common.Hash {
+	return crypto.Keccak256Hash(
+		X402DomainTypeHash.Bytes(),
+		crypto.Keccak256([]byte(X402DomainName)),
+		crypto.Keccak256([]byte(X402DomainVersion)),
+		common.BigToHash(chainID).Bytes(),
+		common.BytesToHash(X402RegistryAddress.Bytes()).Bytes(),
+	)
+}
+
+// X402TypedDataHash returns the EIP-712 digest of a PaymentAuthorization, as
+// signed through eth_signTypedData_v4. For the "upto" scheme value is the
+// signed ceiling. An empty scheme is signed as "exact".
+func X402TypedDataHash(scheme string, from, to common.Address, value *big.Int, validAfter, validBefore uint64, nonce common.Hash, chainID *big.Int) common.Hash {
+	if scheme == "" {
+		scheme = X402SchemeExact
+	}
+	structHash := crypto.Keccak256Hash(
+		X402PaymentAuthorizationTypeHash.Bytes(),
+		crypto.Keccak256([]byte(scheme)),
+		common.BytesToHash(from.Bytes()).Bytes(),
+		common.BytesToHash(to.Bytes()).Bytes(),
+		common.BigToHash(value).Bytes(),
+		common.BigToHash(new(big.Int).SetUint64(validAfter)).Bytes(),
+		common.BigToHash(new(big.Int).SetUint64(validBefore)).Bytes(),
+		nonce.Bytes(),
+	)
+	return crypto.Keccak256Hash([]byte{0x19, 0x01}, X402DomainSeparator(chainID).Bytes(), structHash.Bytes())
+}
+
+// SignedValue returns the amount bound into the payer signature: the settled
+// amount for the "exact" scheme and the ceiling for the "upto" scheme.
+func (p *X402Payload) SignedValue() *big.Int {
+	if p.Scheme == X402SchemeUpto && p.MaxValue != nil {
+		return p.MaxValue
+	}
+	return p.Value
+}
+
+// SigHash returns the EIP-191 hash signed by the payer, binding the payment
+// to the given chain ID.
+func (p *X402Payload) SigHash(chainID *big.Int) common.Hash {
+	msg := X402PaymentMessage(p.Scheme, p.From, p.To, p.SignedValue(), p.ValidAfter, p.ValidBefore, p.Nonce, chainID.Uint64())
+	return crypto.Keccak256Hash([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(msg), msg)))
+}
+
+// TypedDataHash returns the EIP-712 PaymentAuthorization digest signed by the
+// payer, binding the payment to the given chain ID.
+func (p *X402Payload) TypedDataHash(chainID *big.Int) common.Hash {
+	return X402TypedDataHash(p.Scheme, p.From, p.To, p.SignedValue(), p.ValidAfter, p.ValidBefore, p.Nonce, chainID)
+}
+
+// Payer recovers the address that signed the payload. Both EIP-712 typed data
+// signatures and EIP-191 signatures of the canonical message are accepted:
+// the typed data signer is returned if it is the payer, the signer of the
+// canonical message otherwise.
+func (p *X402Payload) Payer(chainID *big.Int) (common.Address, error) {
+	if len(p.Signature) != crypto.SignatureLength {
+		return common.Address{}, errX402SignatureLength
+	}
+	sig := make([]byte, crypto.SignatureLength)
+	copy(sig, p.Signature)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	if pub, err := crypto.SigToPub(p.TypedDataHash(chainID).Bytes(), sig); err == nil {
+		if signer := crypto.PubkeyToAddress(*pub); signer == p.From {
+			return signer, nil
+		}
+	}
+	pub, err := crypto.SigToPub(p.SigHash(chainID).Bytes(), sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("x402: signature recover failed: %w", err)
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// X402NonceSlot returns the registry storage slot marking (from, nonce) as used.
+func X402NonceSlot(from common.Address, nonce common.Hash) common.Hash {
+	return crypto.Keccak256Hash(from.Bytes(), nonce.Bytes())
+}
+
 // X402Tx is a typed transaction envelope that carries an x402 settlement payload.
 // It implements TxData so it can be propagated via the txpool and included in blocks.
 // Notes:
 // - Gas fields are kept for EIP-1559 compatibility but x402 consensus execution ignores fees (zero-fee policy).
-// - The actual settlement logic (signature verification, balance moves, nonce registry) is executed in consensus
-//   (e.g., in the Congress engine ApplySysTx path), based on the payload bytes contained in Data.
+// - The envelope is signed by the submitting facilitator, whose account nonce it consumes like a regular tx.
+// - The actual settlement logic (signature verification, balance moves, nonce registry) is executed during
+//   block processing (core.ApplyX402Settlement), based on the X402Payload bytes contained in Input.
 type X402Tx struct {
 	// EIP-155 chain ID
 	ChainID *big.Int
@@ -154,14 +329,14 @@ func (x *X402Tx) setSignatureValues(chainID, v, r, s *big.Int) {
 }
 
 // NewX402Tx constructs an x402 typed transaction envelope.
-// gas/gasPrice/fee fields can be zero for system settlement (gasless UX).
-func NewX402Tx(chainID *big.Int, nonce uint64, to *common.Address, payload []byte) *Transaction {
+// gas must cover the intrinsic gas of the payload; price/fee fields are zero (gasless UX).
+func NewX402Tx(chainID *big.Int, nonce uint64, to *common.Address, gas uint64, payload []byte) *Transaction {
 	inner := &X402Tx{
 		ChainID:  new(big.Int).Set(chainID),
 		Nonce:    nonce,
 		To:       copyAddressPtr(to),
 		Value:    new(big.Int), // x402 amount is in payload, not here
-		Gas:      0,
+		Gas:      gas,
 		GasPrice: new(big.Int),
 		GasFeeCap: new(big.Int),
 		GasTipCap: new(big.Int),
@@ -43,6 +43,13 @@ type PrecompiledContract interface {
 	Run(input []byte) ([]byte, error) // Run runs the precompiled contract
 }
 
+// statefulPrecompiledContract is a precompiled contract acting on the state on
+// behalf of its caller, like a system contract.
+type statefulPrecompiledContract interface {
+	PrecompiledContract
+	RunAs(caller common.Address, input []byte, readOnly bool) ([]byte, error) // RunAs runs the contract for the caller
+}
+
 // PrecompiledContractsHomestead contains the default set of pre-compiled Ethereum
 // contracts used in the Frontier and Homestead releases.
 var PrecompiledContractsHomestead = map[common.Address]PrecompiledContract{
@@ -131,16 +138,32 @@ func init() {
 
 // ActivePrecompiles returns the precompiles enabled with the current configuration.
 func ActivePrecompiles(rules params.Rules) []common.Address {
+	var precompiles []common.Address
 	switch {
 	case rules.IsBerlin:
-		return PrecompiledAddressesBerlin
+		precompiles = PrecompiledAddressesBerlin
 	case rules.IsIstanbul:
-		return PrecompiledAddressesIstanbul
+		precompiles = PrecompiledAddressesIstanbul
 	case rules.IsByzantium:
-		return PrecompiledAddressesByzantium
+		precompiles = PrecompiledAddressesByzantium
 	default:
-		return PrecompiledAddressesHomestead
+		precompiles = PrecompiledAddressesHomestead
+	}
+	if rules.IsPQPrecompiles || rules.IsRandomBeacon || rules.IsPQKeyRegistry {
+		active := make([]common.Address, 0, len(precompiles)+len(PrecompiledAddressesPostQuantum)+2)
+		active = append(active, precompiles...)
+		if rules.IsPQPrecompiles {
+			active = append(active, PrecompiledAddressesPostQuantum...)
+		}
+		if rules.IsRandomBeacon {
+			active = append(active, RandomBeaconAddress)
+		}
+		if rules.IsPQKeyRegistry {
+			active = append(active, PQKeyRegistryAddress)
+		}
+		return active
 	}
+	return precompiles
 }
 
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
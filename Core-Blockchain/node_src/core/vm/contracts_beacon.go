@@ -0,0 +1,78 @@
+// Copyright 2024 The Splendor Authors
+// This file implements the precompile exposing the validators' random beacon
+// The consensus engine stores the beacon recorded in every beacon block in the
+// storage of the precompile's account, from which contracts read it.
+
+package vm
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// RandomBeaconAddress is the address of the random beacon precompile at 0x0104,
+// whose storage holds the beacons.
+var RandomBeaconAddress = common.BytesToAddress([]byte{0x01, 0x04})
+
+// randomBeaconLatestSlot is the storage slot holding the number of the latest
+// beacon block. The beacon of each block is stored in the slot of its number.
+var randomBeaconLatestSlot = common.Hash{}
+
+var errRandomBeaconInput = errors.New("invalid random beacon input")
+
+// StoreRandomBeacon records the beacon of a beacon block in the storage of the
+// random beacon precompile.
+func StoreRandomBeacon(db StateDB, number uint64, beacon common.Hash) {
+	// Give the account a nonce, so that it isn't deleted as empty when touched
+	if db.GetNonce(RandomBeaconAddress) == 0 {
+		db.SetNonce(RandomBeaconAddress, 1)
+	}
+	db.SetState(RandomBeaconAddress, randomBeaconLatestSlot, common.BigToHash(new(big.Int).SetUint64(number)))
+	db.SetState(RandomBeaconAddress, randomBeaconSlot(number), beacon)
+}
+
+// randomBeaconSlot returns the storage slot of the beacon of a block.
+func randomBeaconSlot(number uint64) common.Hash {
+	var slot common.Hash
+	binary.BigEndian.PutUint64(slot[common.HashLength-8:], number)
+	return slot
+}
+
+// randomBeacon implements the random beacon precompile. It's bound to the state
+// of the EVM it runs in.
+type randomBeacon struct {
+	db StateDB
+}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *randomBeacon) RequiredGas(input []byte) uint64 {
+	return params.RandomBeaconReadGas
+}
+
+// Run returns the beacon of a block.
+// Input format: empty for the latest beacon, or [number(32)] for the beacon of
+// the given beacon block.
+// Output format: [number(32)] + [beacon(32)], all zero if there's no beacon.
+func (c *randomBeacon) Run(input []byte) ([]byte, error) {
+	var number common.Hash
+	switch len(input) {
+	case 0:
+		number = c.db.GetState(RandomBeaconAddress, randomBeaconLatestSlot)
+	case common.HashLength:
+		number = common.BytesToHash(input)
+		if number.Big().BitLen() > 64 {
+			return nil, errRandomBeaconInput
+		}
+	default:
+		return nil, errRandomBeaconInput
+	}
+	beacon := c.db.GetState(RandomBeaconAddress, randomBeaconSlot(number.Big().Uint64()))
+	if beacon == (common.Hash{}) {
+		number = common.Hash{}
+	}
+	return append(number.Bytes(), beacon.Bytes()...), nil
+}
@@ -1,15 +1,21 @@
 // Copyright 2024 The Splendor Authors
 // This file implements post-quantum cryptographic precompiles for the EVM
-// Includes ML-DSA signature verification as specified in FIPS 204
+// Includes ML-DSA signature verification as specified in FIPS 204, SLH-DSA
+// signature verification as specified in FIPS 205, and ML-KEM input checks as
+// specified in FIPS 203
 
 package vm
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
+	"sort"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto/mldsa"
+	"github.com/ethereum/go-ethereum/crypto/mlkem"
+	"github.com/ethereum/go-ethereum/crypto/slhdsa"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -17,11 +23,36 @@ import (
 var (
 	// ML-DSA signature verification precompile at 0x0100
 	MLDSAVerifyAddress = common.BytesToAddress([]byte{0x01, 0x00})
+
+	// Compact ML-DSA-65 signature verification precompile at 0x0101
+	MLDSAVerifyCompactAddress = common.BytesToAddress([]byte{0x01, 0x01})
+
+	// SLH-DSA signature verification precompile at 0x0102
+	SLHDSAVerifyAddress = common.BytesToAddress([]byte{0x01, 0x02})
+
+	// ML-KEM encapsulation validity check precompile at 0x0103
+	MLKEMCheckAddress = common.BytesToAddress([]byte{0x01, 0x03})
 )
 
-// PostQuantumPrecompiles contains the post-quantum precompiled contracts
+// PostQuantumPrecompiles contains the post-quantum precompiled contracts,
+// active on top of the regular precompiles once the PQT fork is reached and
+// the precompiles are enabled in the chain config (params.Rules.IsPQPrecompiles).
 var PostQuantumPrecompiles = map[common.Address]PrecompiledContract{
-	MLDSAVerifyAddress: &mldsaVerify{},
+	MLDSAVerifyAddress:        &mldsaVerify{},
+	MLDSAVerifyCompactAddress: &mldsaVerifyCompact{},
+	SLHDSAVerifyAddress:       &slhdsaVerify{},
+	MLKEMCheckAddress:         &mlkemCheck{},
+}
+
+// PrecompiledAddressesPostQuantum lists the addresses of PostQuantumPrecompiles
+// in ascending order.
+var PrecompiledAddressesPostQuantum []common.Address
+
+func init() {
+	PrecompiledAddressesPostQuantum = PostQuantumPrecompileAddresses()
+	sort.Slice(PrecompiledAddressesPostQuantum, func(i, j int) bool {
+		return bytes.Compare(PrecompiledAddressesPostQuantum[i][:], PrecompiledAddressesPostQuantum[j][:]) < 0
+	})
 }
 
 // mldsaVerify implements ML-DSA signature verification precompile
@@ -47,13 +78,10 @@ func (c *mldsaVerify) RequiredGas(input []byte) uint64 {
 // Run executes the ML-DSA signature verification
 // Input format: [algorithm_id(1)] + [message_len(4)] + [signature_len(4)] + [pubkey_len(4)] + [message] + [signature] + [pubkey]
 func (c *mldsaVerify) Run(input []byte) ([]byte, error) {
-	// Minimum input: 1 + 4 + 4 + 4 = 13 bytes for headers
-	if len(input) < 13 {
-		return nil, errors.New("input too short")
+	algorithmID, fields, err := parsePQInput(input, 3)
+	if err != nil {
+		return nil, err
 	}
-
-	// Parse algorithm ID
-	algorithmID := input[0]
 	var algorithm string
 	switch algorithmID {
 	case 0x44: // ML-DSA-44
@@ -65,29 +93,10 @@ func (c *mldsaVerify) Run(input []byte) ([]byte, error) {
 	default:
 		return nil, errors.New("unsupported ML-DSA algorithm")
 	}
-
-	// Parse lengths
-	messageLen := binary.BigEndian.Uint32(input[1:5])
-	signatureLen := binary.BigEndian.Uint32(input[5:9])
-	pubkeyLen := binary.BigEndian.Uint32(input[9:13])
-
-	// Validate total length
-	expectedLen := 13 + messageLen + signatureLen + pubkeyLen
-	if uint32(len(input)) != expectedLen {
-		return nil, errors.New("input length mismatch")
-	}
-
-	// Extract components
-	offset := uint32(13)
-	message := input[offset : offset+messageLen]
-	offset += messageLen
-	signature := input[offset : offset+signatureLen]
-	offset += signatureLen
-	publicKey := input[offset : offset+pubkeyLen]
+	message, signature, publicKey := fields[0], fields[1], fields[2]
 
 	// Verify signature
-	err := mldsa.VerifySignature(algorithm, message, signature, publicKey)
-	if err != nil {
+	if err := mldsa.VerifySignature(algorithm, message, signature, publicKey); err != nil {
 		// Return false (32 bytes of zeros) for verification failure
 		return make([]byte, 32), nil
 	}
@@ -133,9 +142,9 @@ func (c *mldsaVerifyCompact) Run(input []byte) ([]byte, error) {
 
 	// Parse message length
 	messageLen := binary.BigEndian.Uint32(input[0:4])
-	expectedLen := 4 + messageLen + ML_DSA_65_SIG_LEN + ML_DSA_65_PK_LEN
+	expectedLen := 4 + uint64(messageLen) + ML_DSA_65_SIG_LEN + ML_DSA_65_PK_LEN
 	
-	if uint32(len(input)) != expectedLen {
+	if uint64(len(input)) != expectedLen {
 		return nil, errors.New("input length mismatch")
 	}
 
@@ -157,6 +166,119 @@ func (c *mldsaVerifyCompact) Run(input []byte) ([]byte, error) {
 	return result, nil
 }
 
+// slhdsaVerify implements SLH-DSA signature verification precompile
+type slhdsaVerify struct{}
+
+// RequiredGas calculates the gas cost for SLH-DSA verification
+func (c *slhdsaVerify) RequiredGas(input []byte) uint64 {
+	return params.SLHDSAVerifyBaseGas + uint64(len(input))*params.SLHDSAVerifyPerByteGas
+}
+
+// Run executes the SLH-DSA signature verification
+// Input format: [algorithm_id(1)] + [message_len(4)] + [signature_len(4)] + [pubkey_len(4)] + [message] + [signature] + [pubkey]
+func (c *slhdsaVerify) Run(input []byte) ([]byte, error) {
+	algorithmID, fields, err := parsePQInput(input, 3)
+	if err != nil {
+		return nil, err
+	}
+	var algorithm string
+	switch algorithmID {
+	case params.SLHDSA128S_ID:
+		algorithm = slhdsa.SLHDSA128S
+	case params.SLHDSA128F_ID:
+		algorithm = slhdsa.SLHDSA128F
+	case params.SLHDSA192S_ID:
+		algorithm = slhdsa.SLHDSA192S
+	case params.SLHDSA192F_ID:
+		algorithm = slhdsa.SLHDSA192F
+	case params.SLHDSA256S_ID:
+		algorithm = slhdsa.SLHDSA256S
+	case params.SLHDSA256F_ID:
+		algorithm = slhdsa.SLHDSA256F
+	default:
+		return nil, errors.New("unsupported SLH-DSA algorithm")
+	}
+	message, signature, publicKey := fields[0], fields[1], fields[2]
+
+	if err := slhdsa.VerifySignature(algorithm, message, signature, publicKey); err != nil {
+		return make([]byte, 32), nil
+	}
+	result := make([]byte, 32)
+	result[31] = 1
+	return result, nil
+}
+
+// mlkemCheck implements the ML-KEM encapsulation validity check precompile,
+// which lets contracts reject encapsulation keys and ciphertexts that an
+// ML-KEM implementation would refuse before they are stored or relayed
+type mlkemCheck struct{}
+
+// RequiredGas calculates the gas cost for the ML-KEM input checks
+func (c *mlkemCheck) RequiredGas(input []byte) uint64 {
+	return params.MLKEMEncapsBaseGas + uint64(len(input))*params.MLKEMEncapsPerByteGas
+}
+
+// Run executes the FIPS 203 encapsulation key and ciphertext checks. An empty
+// ciphertext only checks the encapsulation key.
+// Input format: [algorithm_id(1)] + [ciphertext_len(4)] + [pubkey_len(4)] + [ciphertext] + [pubkey]
+func (c *mlkemCheck) Run(input []byte) ([]byte, error) {
+	algorithmID, fields, err := parsePQInput(input, 2)
+	if err != nil {
+		return nil, err
+	}
+	var algorithm string
+	switch algorithmID {
+	case params.MLKEM512_ID:
+		algorithm = mlkem.MLKEM512
+	case params.MLKEM768_ID:
+		algorithm = mlkem.MLKEM768
+	case params.MLKEM1024_ID:
+		algorithm = mlkem.MLKEM1024
+	default:
+		return nil, errors.New("unsupported ML-KEM algorithm")
+	}
+	ciphertext, publicKey := fields[0], fields[1]
+
+	if err := mlkem.ValidateEncapsulationKey(algorithm, publicKey); err != nil {
+		return make([]byte, 32), nil
+	}
+	if len(ciphertext) > 0 {
+		if err := mlkem.ValidateCiphertext(algorithm, ciphertext); err != nil {
+			return make([]byte, 32), nil
+		}
+	}
+	result := make([]byte, 32)
+	result[31] = 1
+	return result, nil
+}
+
+// parsePQInput splits the input of a post-quantum precompile into the
+// algorithm ID and the given number of length-prefixed fields. The input is
+// laid out as [algorithm_id(1)] + [len_1(4)] + ... + [len_n(4)] + [field_1] + ... + [field_n].
+func parsePQInput(input []byte, n int) (byte, [][]byte, error) {
+	header := 1 + 4*n
+	if len(input) < header {
+		return 0, nil, errors.New("input too short")
+	}
+	var (
+		fields = make([][]byte, n)
+		total  = uint64(header)
+	)
+	for i := range fields {
+		total += uint64(binary.BigEndian.Uint32(input[1+4*i:]))
+	}
+	if uint64(len(input)) != total {
+		return 0, nil, errors.New("input length mismatch")
+	}
+	offset := header
+	for i := range fields {
+		size := int(binary.BigEndian.Uint32(input[1+4*i:]))
+		fields[i] = input[offset : offset+size]
+		offset += size
+	}
+	return input[0], fields, nil
+}
+
 // Helper function to get all post-quantum precompile addresses
 func PostQuantumPrecompileAddresses() []common.Address {
 	addresses := make([]common.Address, 0, len(PostQuantumPrecompiles))
@@ -0,0 +1,382 @@
+// Copyright 2024 The Splendor Authors
+// This file implements the PQ key registry system contract
+// Validators register and rotate their ML-DSA keys, and optional SLH-DSA
+// backup keys, by calling the registry with a proof of possession. A new key
+// becomes active at the next epoch block, from which the consensus engine
+// seals and verifies blocks with it.
+
+package vm
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/mldsa"
+	"github.com/ethereum/go-ethereum/crypto/slhdsa"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// PQKeyRegistryAddress is the address of the PQ key registry system contract,
+// next to the other system contracts.
+var PQKeyRegistryAddress = common.HexToAddress("0x000000000000000000000000000000000000F008")
+
+// PQKeyRegistryABI is the ABI of the PQ key registry system contract.
+const PQKeyRegistryABI = `[
+	{"type":"function","name":"registerKey","stateMutability":"nonpayable","inputs":[
+		{"name":"algorithm","type":"uint8"},{"name":"publicKey","type":"bytes"},{"name":"proof","type":"bytes"},
+		{"name":"backupAlgorithm","type":"uint8"},{"name":"backupPublicKey","type":"bytes"},{"name":"backupProof","type":"bytes"}],"outputs":[]},
+	{"type":"function","name":"getKey","stateMutability":"view","inputs":[{"name":"validator","type":"address"}],"outputs":[
+		{"name":"algorithm","type":"uint8"},{"name":"publicKey","type":"bytes"},
+		{"name":"backupAlgorithm","type":"uint8"},{"name":"backupPublicKey","type":"bytes"},{"name":"activation","type":"uint64"}]},
+	{"type":"function","name":"getPendingKey","stateMutability":"view","inputs":[{"name":"validator","type":"address"}],"outputs":[
+		{"name":"algorithm","type":"uint8"},{"name":"publicKey","type":"bytes"},
+		{"name":"backupAlgorithm","type":"uint8"},{"name":"backupPublicKey","type":"bytes"},{"name":"activation","type":"uint64"}]},
+	{"type":"event","name":"KeyRegistered","anonymous":false,"inputs":[
+		{"name":"validator","type":"address","indexed":true},{"name":"algorithm","type":"uint8","indexed":false},
+		{"name":"backupAlgorithm","type":"uint8","indexed":false},{"name":"activation","type":"uint64","indexed":false}]}
+]`
+
+// pqKeyRegistryEpoch is the default epoch length of the Congress engine, which
+// applies if the chain config doesn't set one.
+const pqKeyRegistryEpoch = 30000
+
+// pqKeyPossessionPrefix separates the proofs of possession of registered keys
+// from any other signed data.
+var pqKeyPossessionPrefix = []byte("splendor-pq-key-possession")
+
+var pqKeyRegistryABI abi.ABI
+
+func init() {
+	var err error
+	if pqKeyRegistryABI, err = abi.JSON(strings.NewReader(PQKeyRegistryABI)); err != nil {
+		panic(err)
+	}
+}
+
+// pqKeyAlgorithms maps the algorithm identifiers of the registrable sealing
+// keys to their names.
+var pqKeyAlgorithms = map[byte]string{
+	params.MLDSA44_ID: mldsa.MLDSA44,
+	params.MLDSA65_ID: mldsa.MLDSA65,
+	params.MLDSA87_ID: mldsa.MLDSA87,
+}
+
+// pqBackupKeyAlgorithms maps the algorithm identifiers of the registrable
+// backup keys to their names.
+var pqBackupKeyAlgorithms = map[byte]string{
+	params.SLHDSA128S_ID: slhdsa.SLHDSA128S,
+	params.SLHDSA128F_ID: slhdsa.SLHDSA128F,
+	params.SLHDSA192S_ID: slhdsa.SLHDSA192S,
+	params.SLHDSA192F_ID: slhdsa.SLHDSA192F,
+	params.SLHDSA256S_ID: slhdsa.SLHDSA256S,
+	params.SLHDSA256F_ID: slhdsa.SLHDSA256F,
+}
+
+var (
+	errPQKeyRegistryInput = errors.New("invalid PQ key registry input")
+	errPQKeyAlgorithm     = errors.New("unsupported PQ key algorithm")
+	errPQKeyLength        = errors.New("invalid PQ public key length")
+	errPQKeyProof         = errors.New("invalid PQ key proof of possession")
+)
+
+// PQRegistryKey is a key registered in the PQ key registry.
+type PQRegistryKey struct {
+	Algorithm       byte   // ML-DSA algorithm identifier
+	PublicKey       []byte // ML-DSA public key
+	BackupAlgorithm byte   // SLH-DSA algorithm identifier, zero without backup key
+	BackupPublicKey []byte // SLH-DSA public key
+	Activation      uint64 // Number of the epoch block activating the key
+}
+
+// PQKeyActivation returns the number of the epoch block activating a key
+// registered in the given block.
+func PQKeyActivation(config *params.ChainConfig, number uint64) uint64 {
+	epoch := uint64(pqKeyRegistryEpoch)
+	if config.Congress != nil && config.Congress.Epoch != 0 {
+		epoch = config.Congress.Epoch
+	}
+	return (number/epoch + 1) * epoch
+}
+
+// PQKeyPossessionMessage returns the message a validator signs with both its
+// keys to prove it possesses them. It binds the keys to the validator, so that
+// nobody else can register them.
+func PQKeyPossessionMessage(chainID *big.Int, validator common.Address, key *PQRegistryKey) []byte {
+	digest := crypto.Keccak256([]byte{key.Algorithm}, key.PublicKey, []byte{key.BackupAlgorithm}, key.BackupPublicKey)
+
+	msg := make([]byte, 0, len(pqKeyPossessionPrefix)+common.HashLength+common.AddressLength+len(digest))
+	msg = append(msg, pqKeyPossessionPrefix...)
+	msg = append(msg, common.BigToHash(chainID).Bytes()...)
+	msg = append(msg, validator.Bytes()...)
+	return append(msg, digest...)
+}
+
+// ReadPQKey returns the key of a validator active at the given block, or nil
+// if it has none.
+func ReadPQKey(db StateDB, validator common.Address, number uint64) *PQRegistryKey {
+	if pending := readPQKey(db, pqKeySlot(validator, true)); pending != nil && pending.Activation <= number {
+		return pending
+	}
+	return readPQKey(db, pqKeySlot(validator, false))
+}
+
+// ReadPendingPQKey returns the key of a validator awaiting activation at the
+// given block, or nil if it has none.
+func ReadPendingPQKey(db StateDB, validator common.Address, number uint64) *PQRegistryKey {
+	if pending := readPQKey(db, pqKeySlot(validator, true)); pending != nil && pending.Activation > number {
+		return pending
+	}
+	return nil
+}
+
+// The registry stores two keys per validator: the active one and the pending
+// one. Each key takes a header slot followed by the public keys, in 32-byte
+// words. The header slot holds:
+//
+//	[algorithm(1)] [backupAlgorithm(1)] [keyLen(2)] [backupKeyLen(2)] ... [activation(8)]
+//
+// A pending key is only promoted when the validator registers a new one, the
+// readers tell the keys apart by their activation.
+
+// pqKeySlot returns the header slot of the active or the pending key of a
+// validator.
+func pqKeySlot(validator common.Address, pending bool) common.Hash {
+	var index common.Hash
+	if pending {
+		index[common.HashLength-1] = 1
+	}
+	return crypto.Keccak256Hash(common.BytesToHash(validator.Bytes()).Bytes(), index.Bytes())
+}
+
+// pqKeyWordSlot returns the slot of the i-th word of the key at the given
+// header slot.
+func pqKeyWordSlot(slot common.Hash, i int) common.Hash {
+	return common.BigToHash(new(big.Int).Add(slot.Big(), big.NewInt(int64(i+1))))
+}
+
+// pqKeyWords returns the number of words taken by the public keys of a key.
+func pqKeyWords(keyLen, backupLen int) int {
+	return (keyLen + backupLen + common.HashLength - 1) / common.HashLength
+}
+
+// readPQKeyHeader decodes the header slot of a key.
+func readPQKeyHeader(db StateDB, slot common.Hash) (key *PQRegistryKey, keyLen int, backupLen int) {
+	header := db.GetState(PQKeyRegistryAddress, slot)
+	if header == (common.Hash{}) {
+		return nil, 0, 0
+	}
+	key = &PQRegistryKey{
+		Algorithm:       header[0],
+		BackupAlgorithm: header[1],
+		Activation:      binary.BigEndian.Uint64(header[24:]),
+	}
+	return key, int(binary.BigEndian.Uint16(header[2:4])), int(binary.BigEndian.Uint16(header[4:6]))
+}
+
+// readPQKey reads the key at the given header slot.
+func readPQKey(db StateDB, slot common.Hash) *PQRegistryKey {
+	key, keyLen, backupLen := readPQKeyHeader(db, slot)
+	if key == nil {
+		return nil
+	}
+	data := make([]byte, 0, pqKeyWords(keyLen, backupLen)*common.HashLength)
+	for i := 0; i < pqKeyWords(keyLen, backupLen); i++ {
+		word := db.GetState(PQKeyRegistryAddress, pqKeyWordSlot(slot, i))
+		data = append(data, word[:]...)
+	}
+	key.PublicKey = data[:keyLen]
+	if backupLen > 0 {
+		key.BackupPublicKey = data[keyLen : keyLen+backupLen]
+	}
+	return key
+}
+
+// writePQKey writes a key at the given header slot.
+func writePQKey(db StateDB, slot common.Hash, key *PQRegistryKey) {
+	var header common.Hash
+	header[0], header[1] = key.Algorithm, key.BackupAlgorithm
+	binary.BigEndian.PutUint16(header[2:4], uint16(len(key.PublicKey)))
+	binary.BigEndian.PutUint16(header[4:6], uint16(len(key.BackupPublicKey)))
+	binary.BigEndian.PutUint64(header[24:], key.Activation)
+	db.SetState(PQKeyRegistryAddress, slot, header)
+
+	data := append(common.CopyBytes(key.PublicKey), key.BackupPublicKey...)
+	for i := 0; i < pqKeyWords(len(key.PublicKey), len(key.BackupPublicKey)); i++ {
+		var word common.Hash
+		copy(word[:], data[i*common.HashLength:])
+		db.SetState(PQKeyRegistryAddress, pqKeyWordSlot(slot, i), word)
+	}
+}
+
+// registerKeyArgs are the arguments of the registerKey method.
+type registerKeyArgs struct {
+	Algorithm       uint8
+	PublicKey       []byte
+	Proof           []byte
+	BackupAlgorithm uint8
+	BackupPublicKey []byte
+	BackupProof     []byte
+}
+
+// pqKeyRegistry implements the PQ key registry system contract. It's bound to
+// the EVM it runs in, and acts on behalf of its caller.
+type pqKeyRegistry struct {
+	evm *EVM
+}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *pqKeyRegistry) RequiredGas(input []byte) uint64 {
+	method, args, err := c.unpack(input)
+	if err != nil {
+		return params.PQKeyReadWordGas
+	}
+	switch method.Name {
+	case "registerKey":
+		reg := args.(*registerKeyArgs)
+		gas := params.PQKeyRegisterGas + params.MLDSAVerifyBaseGas
+		if reg.BackupAlgorithm != 0 {
+			gas += params.SLHDSAVerifyBaseGas
+		}
+		return gas + uint64(1+pqKeyWords(len(reg.PublicKey), len(reg.BackupPublicKey)))*params.PQKeyRegisterWordGas
+	default:
+		// Reads take both header slots and the words of the key returned
+		validator := args.(common.Address)
+		words := 2
+		for _, pending := range []bool{false, true} {
+			if _, keyLen, backupLen := readPQKeyHeader(c.evm.StateDB, pqKeySlot(validator, pending)); keyLen > 0 {
+				words += pqKeyWords(keyLen, backupLen)
+			}
+		}
+		return uint64(words) * params.PQKeyReadWordGas
+	}
+}
+
+// Run executes the read-only methods of the registry.
+func (c *pqKeyRegistry) Run(input []byte) ([]byte, error) {
+	return c.RunAs(common.Address{}, input, true)
+}
+
+// RunAs executes a method of the registry on behalf of the caller.
+func (c *pqKeyRegistry) RunAs(caller common.Address, input []byte, readOnly bool) ([]byte, error) {
+	method, args, err := c.unpack(input)
+	if err != nil {
+		return nil, err
+	}
+	number := c.evm.Context.BlockNumber.Uint64()
+
+	switch method.Name {
+	case "registerKey":
+		if readOnly {
+			return nil, ErrWriteProtection
+		}
+		return nil, c.register(caller, args.(*registerKeyArgs), number)
+	case "getKey":
+		return packPQKey(method, ReadPQKey(c.evm.StateDB, args.(common.Address), number))
+	default:
+		return packPQKey(method, ReadPendingPQKey(c.evm.StateDB, args.(common.Address), number))
+	}
+}
+
+// unpack decodes the method and arguments of a call to the registry.
+func (c *pqKeyRegistry) unpack(input []byte) (*abi.Method, interface{}, error) {
+	if len(input) < 4 {
+		return nil, nil, errPQKeyRegistryInput
+	}
+	method, err := pqKeyRegistryABI.MethodById(input[:4])
+	if err != nil {
+		return nil, nil, errPQKeyRegistryInput
+	}
+	values, err := method.Inputs.Unpack(input[4:])
+	if err != nil {
+		return nil, nil, errPQKeyRegistryInput
+	}
+	if method.Name == "registerKey" {
+		args := new(registerKeyArgs)
+		if err := method.Inputs.Copy(args, values); err != nil {
+			return nil, nil, errPQKeyRegistryInput
+		}
+		return method, args, nil
+	}
+	return method, values[0].(common.Address), nil
+}
+
+// register checks the proofs of possession of the keys and schedules their
+// activation at the next epoch block, promoting the pending key if it's
+// active already.
+func (c *pqKeyRegistry) register(caller common.Address, args *registerKeyArgs, number uint64) error {
+	name, ok := pqKeyAlgorithms[args.Algorithm]
+	if !ok {
+		return errPQKeyAlgorithm
+	}
+	if len(args.PublicKey) != mldsa.MLDSAParams[name].PublicKeySize {
+		return errPQKeyLength
+	}
+	key := &PQRegistryKey{
+		Algorithm:       args.Algorithm,
+		PublicKey:       args.PublicKey,
+		BackupAlgorithm: args.BackupAlgorithm,
+		BackupPublicKey: args.BackupPublicKey,
+		Activation:      PQKeyActivation(c.evm.chainConfig, number),
+	}
+	message := PQKeyPossessionMessage(c.evm.chainConfig.ChainID, caller, key)
+	if err := mldsa.VerifySignature(name, message, args.Proof, args.PublicKey); err != nil {
+		return errPQKeyProof
+	}
+	if args.BackupAlgorithm != 0 {
+		backup, ok := pqBackupKeyAlgorithms[args.BackupAlgorithm]
+		if !ok {
+			return errPQKeyAlgorithm
+		}
+		if len(args.BackupPublicKey) != slhdsa.SLHDSAParams[backup].PublicKeySize {
+			return errPQKeyLength
+		}
+		if err := slhdsa.VerifySignature(backup, message, args.BackupProof, args.BackupPublicKey); err != nil {
+			return errPQKeyProof
+		}
+	} else if len(args.BackupPublicKey) != 0 || len(args.BackupProof) != 0 {
+		return errPQKeyRegistryInput
+	}
+	db := c.evm.StateDB
+
+	// Give the account a nonce, so that it isn't deleted as empty when touched
+	if db.GetNonce(PQKeyRegistryAddress) == 0 {
+		db.SetNonce(PQKeyRegistryAddress, 1)
+	}
+	if pending := readPQKey(db, pqKeySlot(caller, true)); pending != nil && pending.Activation <= number {
+		writePQKey(db, pqKeySlot(caller, false), pending)
+	}
+	writePQKey(db, pqKeySlot(caller, true), key)
+
+	event := pqKeyRegistryABI.Events["KeyRegistered"]
+	data, err := event.Inputs.NonIndexed().Pack(key.Algorithm, key.BackupAlgorithm, key.Activation)
+	if err != nil {
+		return err
+	}
+	db.AddLog(&types.Log{
+		Address:     PQKeyRegistryAddress,
+		Topics:      []common.Hash{event.ID, common.BytesToHash(caller.Bytes())},
+		Data:        data,
+		BlockNumber: number,
+	})
+	return nil
+}
+
+// packPQKey encodes a key, which may be nil, as the output of a method.
+func packPQKey(method *abi.Method, key *PQRegistryKey) ([]byte, error) {
+	if key == nil {
+		key = &PQRegistryKey{}
+	}
+	if key.PublicKey == nil {
+		key.PublicKey = []byte{}
+	}
+	if key.BackupPublicKey == nil {
+		key.BackupPublicKey = []byte{}
+	}
+	return method.Outputs.Pack(key.Algorithm, key.PublicKey, key.BackupAlgorithm, key.BackupPublicKey, key.Activation)
+}
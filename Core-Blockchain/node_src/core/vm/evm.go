@@ -58,9 +58,33 @@ func (evm *EVM) precompile(addr common.Address) (PrecompiledContract, bool) {
 		precompiles = PrecompiledContractsHomestead
 	}
 	p, ok := precompiles[addr]
+	if !ok && evm.chainRules.IsPQPrecompiles {
+		p, ok = PostQuantumPrecompiles[addr]
+	}
+	if !ok && evm.chainRules.IsRandomBeacon && addr == RandomBeaconAddress {
+		p, ok = &randomBeacon{db: evm.StateDB}, true
+	}
+	if !ok && evm.chainRules.IsPQKeyRegistry && addr == PQKeyRegistryAddress {
+		p, ok = &pqKeyRegistry{evm: evm}, true
+	}
 	return p, ok
 }
 
+// runPrecompiledContract runs a precompiled contract, on behalf of the caller
+// for the ones acting on the state.
+func (evm *EVM) runPrecompiledContract(p PrecompiledContract, caller common.Address, input []byte, gas uint64, readOnly bool) ([]byte, uint64, error) {
+	sp, ok := p.(statefulPrecompiledContract)
+	if !ok {
+		return RunPrecompiledContract(p, input, gas)
+	}
+	gasCost := sp.RequiredGas(input)
+	if gas < gasCost {
+		return nil, 0, ErrOutOfGas
+	}
+	output, err := sp.RunAs(caller, input, readOnly)
+	return output, gas - gasCost, err
+}
+
 // BlockContext provides the EVM with auxiliary information. Once provided
 // it shouldn't be modified.
 type BlockContext struct {
@@ -163,6 +187,30 @@ func (evm *EVM) Cancelled() bool {
 	return atomic.LoadInt32(&evm.abort) == 1
 }
 
+// CaptureAccountAccess reports accounts about to be changed outside of the
+// interpreter to the tracer, if it follows the state touched by transactions.
+func (evm *EVM) CaptureAccountAccess(addrs ...common.Address) {
+	if !evm.Config.Debug {
+		return
+	}
+	if tracer, ok := evm.Config.Tracer.(StateAccessLogger); ok {
+		for _, addr := range addrs {
+			tracer.CaptureAccountAccess(evm, addr)
+		}
+	}
+}
+
+// CaptureStorageAccess reports a storage slot about to be changed outside of
+// the interpreter to the tracer, if it follows the state touched by transactions.
+func (evm *EVM) CaptureStorageAccess(addr common.Address, slot common.Hash) {
+	if !evm.Config.Debug {
+		return
+	}
+	if tracer, ok := evm.Config.Tracer.(StateAccessLogger); ok {
+		tracer.CaptureStorageAccess(evm, addr, slot)
+	}
+}
+
 // Interpreter returns the current interpreter
 func (evm *EVM) Interpreter() *EVMInterpreter {
 	return evm.interpreter
@@ -231,7 +279,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	}
 
 	if isPrecompile {
-		ret, gas, err = RunPrecompiledContract(p, input, gas)
+		ret, gas, err = evm.runPrecompiledContract(p, caller.Address(), input, gas, evm.interpreter.readOnly)
 	} else {
 		// Initialise a new contract and set the code that is to be used by the EVM.
 		// The contract is a scoped environment for this execution context only.
@@ -306,7 +354,7 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 
 	// It is allowed to call precompiles, even via delegatecall
 	if p, isPrecompile := evm.precompile(addr); isPrecompile {
-		ret, gas, err = RunPrecompiledContract(p, input, gas)
+		ret, gas, err = evm.runPrecompiledContract(p, caller.Address(), input, gas, evm.interpreter.readOnly)
 	} else {
 		addrCopy := addr
 		// Initialise a new contract and set the code that is to be used by the EVM.
@@ -359,7 +407,7 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 
 	// It is allowed to call precompiles, even via delegatecall
 	if p, isPrecompile := evm.precompile(addr); isPrecompile {
-		ret, gas, err = RunPrecompiledContract(p, input, gas)
+		ret, gas, err = evm.runPrecompiledContract(p, caller.Address(), input, gas, evm.interpreter.readOnly)
 	} else {
 		addrCopy := addr
 		// Initialise a new contract and make initialise the delegate values
@@ -420,7 +468,7 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	}
 
 	if p, isPrecompile := evm.precompile(addr); isPrecompile {
-		ret, gas, err = RunPrecompiledContract(p, input, gas)
+		ret, gas, err = evm.runPrecompiledContract(p, caller.Address(), input, gas, true)
 	} else {
 		// At this point, we use a copy of address. If we don't, the go compiler will
 		// leak the 'contract' to the outer scope, and make allocation for 'contract'
@@ -113,6 +113,15 @@ type EVMLogger interface {
 	CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error)
 }
 
+// StateAccessLogger is an optional extension of EVMLogger for tracers following
+// the state touched by a transaction. Changes applied natively, outside of the
+// interpreter, are reported through it before they are made, so such tracers
+// see the state a transaction starts from.
+type StateAccessLogger interface {
+	CaptureAccountAccess(env *EVM, addr common.Address)
+	CaptureStorageAccess(env *EVM, addr common.Address, slot common.Hash)
+}
+
 // StructLogger is an EVM state logger and implements EVMLogger.
 //
 // StructLogger can capture state based on the given Log configuration and also keeps
@@ -0,0 +1,219 @@
+// Copyright 2024 Splendor Blockchain
+// Consensus-level execution of x402 settlement envelopes
+
+package core
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// List of x402 settlement errors. A settlement error does not invalidate the
+// block, the envelope is included with a failed receipt instead.
+var (
+	// ErrX402BadSignature is returned if the payload signature doesn't recover
+	// to the payer.
+	ErrX402BadSignature = errors.New("x402: signature does not match from")
+
+	// ErrX402NotYetValid is returned if the block time is before validAfter.
+	ErrX402NotYetValid = errors.New("x402: payment not yet valid")
+
+	// ErrX402Expired is returned if the block time is after validBefore.
+	ErrX402Expired = errors.New("x402: payment expired")
+
+	// ErrX402NonceUsed is returned if the (payer, nonce) pair was already settled.
+	ErrX402NonceUsed = errors.New("x402: nonce already used")
+
+	// ErrX402InsufficientBalance is returned if the payer can't cover the payment.
+	ErrX402InsufficientBalance = errors.New("x402: insufficient balance")
+
+	// ErrX402UnsupportedScheme is returned if the payload uses an unknown scheme.
+	ErrX402UnsupportedScheme = errors.New("x402: unsupported payment scheme")
+
+	// ErrX402MissingCeiling is returned if an "upto" payload has no signed ceiling.
+	ErrX402MissingCeiling = errors.New("x402: missing upto ceiling")
+
+	// ErrX402ExceedsCeiling is returned if an "upto" payload settles more than
+	// the signed ceiling.
+	ErrX402ExceedsCeiling = errors.New("x402: settled amount exceeds ceiling")
+
+	// ErrX402AssetNotContract is returned if the asset of a token payment has
+	// no code.
+	ErrX402AssetNotContract = errors.New("x402: asset is not a contract")
+
+	// ErrX402TokenTransferFailed is returned if the token rejected the
+	// transferWithAuthorization call.
+	ErrX402TokenTransferFailed = errors.New("x402: token transfer failed")
+)
+
+// ApplyX402Settlement verifies the x402 payload carried by tx against the
+// state and block context of the given EVM and, if valid, settles it.
+//
+// Native payments are moved from the payer to the payee, the payer nonce is
+// marked as consumed in the registry and an X402Settled log is emitted. Token
+// payments are settled by an EIP-3009 call to the asset with at most gas gas,
+// see applyX402TokenSettlement. No state is modified if an error is returned.
+// The gas used by the token call is returned.
+func ApplyX402Settlement(evm *vm.EVM, statedb *state.StateDB, tx *types.Transaction, gas uint64) (uint64, error) {
+	var (
+		config = evm.ChainConfig()
+		time   = evm.Context.Time.Uint64()
+	)
+	p, err := types.DecodeX402Payload(tx.Data())
+	if err != nil {
+		return 0, err
+	}
+	switch p.Scheme {
+	case "", types.X402SchemeExact:
+	case types.X402SchemeUpto:
+		if p.MaxValue == nil {
+			return 0, ErrX402MissingCeiling
+		}
+		if p.Value.Cmp(p.MaxValue) > 0 {
+			return 0, ErrX402ExceedsCeiling
+		}
+	default:
+		return 0, ErrX402UnsupportedScheme
+	}
+	if time < p.ValidAfter {
+		return 0, ErrX402NotYetValid
+	}
+	if time > p.ValidBefore {
+		return 0, ErrX402Expired
+	}
+	if p.Asset != (common.Address{}) {
+		return applyX402TokenSettlement(evm, p, gas)
+	}
+	payer, err := p.Payer(config.ChainID)
+	if err != nil {
+		return 0, err
+	}
+	if payer != p.From {
+		return 0, ErrX402BadSignature
+	}
+	slot := types.X402NonceSlot(p.From, p.Nonce)
+	if statedb.GetState(types.X402RegistryAddress, slot) != (common.Hash{}) {
+		return 0, ErrX402NonceUsed
+	}
+	if statedb.GetBalance(p.From).Cmp(p.Value) < 0 {
+		return 0, ErrX402InsufficientBalance
+	}
+	evm.CaptureAccountAccess(p.From, p.To, types.X402RegistryAddress)
+	evm.CaptureStorageAccess(types.X402RegistryAddress, slot)
+
+	// Zero-fee settlement: move the settled amount and consume the nonce
+	statedb.SubBalance(p.From, p.Value)
+	statedb.AddBalance(p.To, p.Value)
+	statedb.SetState(types.X402RegistryAddress, slot, common.BigToHash(common.Big1))
+
+	// The registry has neither code nor balance, keep it from being swept as
+	// an empty account (EIP-161) together with its storage.
+	if statedb.GetNonce(types.X402RegistryAddress) == 0 {
+		statedb.SetNonce(types.X402RegistryAddress, 1)
+	}
+
+	data := make([]byte, 0, 2*common.HashLength)
+	data = append(data, common.BigToHash(p.Value).Bytes()...)
+	data = append(data, p.Nonce.Bytes()...)
+	statedb.AddLog(&types.Log{
+		Address: types.X402RegistryAddress,
+		Topics: []common.Hash{
+			types.X402SettledTopic,
+			common.BytesToHash(p.From.Bytes()),
+			common.BytesToHash(p.To.Bytes()),
+		},
+		Data:        data,
+		BlockNumber: evm.Context.BlockNumber.Uint64(),
+	})
+	return 0, nil
+}
+
+// applyX402Transaction executes an x402 envelope as part of block processing.
+//
+// The envelope behaves like a regular transaction towards its sender (the
+// submitting facilitator): its nonce must match and is consumed, and the gas
+// it uses (intrinsic gas plus any token call) is charged against the block gas
+// pool. No fee is paid. The payment itself is applied by ApplyX402Settlement;
+// if the settlement is rejected the envelope is still included, with a failed
+// receipt.
+func applyX402Transaction(config *params.ChainConfig, gp *GasPool, statedb *state.StateDB, evm *vm.EVM, sender common.Address, blockNumber *big.Int, blockHash common.Hash, tx *types.Transaction, usedGas *uint64) (*types.Receipt, error) {
+	gas, settleErr, err := ApplyX402Envelope(evm, gp, statedb, sender, tx, true)
+	if err != nil {
+		return nil, err
+	}
+	var root []byte
+	if config.IsByzantium(blockNumber) {
+		statedb.Finalise(true)
+	} else {
+		root = statedb.IntermediateRoot(config.IsEIP158(blockNumber)).Bytes()
+	}
+	*usedGas += gas
+
+	receipt := &types.Receipt{Type: tx.Type(), PostState: root, CumulativeGasUsed: *usedGas}
+	if settleErr != nil {
+		receipt.Status = types.ReceiptStatusFailed
+		log.Debug("x402 settlement rejected", "txHash", tx.Hash().String(), "err", settleErr)
+	} else {
+		receipt.Status = types.ReceiptStatusSuccessful
+	}
+	receipt.TxHash = tx.Hash()
+	receipt.GasUsed = gas
+	receipt.Logs = statedb.GetLogs(tx.Hash(), blockHash)
+	receipt.BlockHash = blockHash
+	receipt.BlockNumber = blockNumber
+	receipt.TransactionIndex = uint(statedb.TxIndex())
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+	return receipt, nil
+}
+
+// ApplyX402Envelope executes an x402 envelope sent by sender on top of the state
+// of the given EVM, the way blocks settle them: the sender nonce is consumed,
+// the gas used is charged against the gas pool and the payload is settled by
+// ApplyX402Settlement. The envelope nonce is only checked against the state if
+// checkNonce is set, simulations skip it.
+//
+// It returns the gas used and the settlement error, which leaves the envelope
+// includable with a failed receipt. The last error makes the envelope invalid.
+func ApplyX402Envelope(evm *vm.EVM, gp *GasPool, statedb *state.StateDB, sender common.Address, tx *types.Transaction, checkNonce bool) (uint64, error, error) {
+	var (
+		config      = evm.ChainConfig()
+		blockNumber = evm.Context.BlockNumber
+	)
+	stNonce := statedb.GetNonce(sender)
+	if msgNonce := tx.Nonce(); checkNonce && stNonce < msgNonce {
+		return 0, nil, ErrNonceTooHigh
+	} else if checkNonce && stNonce > msgNonce {
+		return 0, nil, ErrNonceTooLow
+	} else if stNonce+1 < stNonce {
+		return 0, nil, ErrNonceMax
+	}
+	gas, err := IntrinsicGas(tx.Data(), nil, false, true, config.IsIstanbul(blockNumber))
+	if err != nil {
+		return 0, nil, err
+	}
+	if tx.Gas() < gas {
+		return 0, nil, ErrIntrinsicGas
+	}
+	if err := gp.SubGas(tx.Gas()); err != nil {
+		return 0, nil, err
+	}
+	statedb.SetNonce(sender, stNonce+1)
+
+	evm.Reset(vm.TxContext{Origin: sender, GasPrice: new(big.Int)}, statedb)
+	if rules := config.Rules(blockNumber); rules.IsBerlin {
+		statedb.PrepareAccessList(sender, tx.To(), vm.ActivePrecompiles(rules), nil)
+	}
+	callGas, settleErr := ApplyX402Settlement(evm, statedb, tx, tx.Gas()-gas)
+	gas += callGas
+	gp.AddGas(tx.Gas() - gas)
+
+	return gas, settleErr, nil
+}
@@ -0,0 +1,299 @@
+// Copyright 2024 Splendor Blockchain
+// Persistent index of the x402 payments included in the canonical chain
+
+package core
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// X402SecondsPerDay is the bucket size of the daily x402 volume statistics (UTC days).
+const X402SecondsPerDay = 86400
+
+// x402IndexBatch is the maximum number of blocks (un)indexed between two checks
+// for new chain head events or shutdown.
+const x402IndexBatch = 256
+
+// X402Indexer maintains the x402 payment index in the database, following the
+// canonical chain head. Blocks dropped by a reorg are unindexed before the new
+// canonical blocks are indexed, and the progress is persisted together with
+// every block so the indexer resumes where it left off after a restart.
+type X402Indexer struct {
+	db    ethdb.Database
+	chain *BlockChain
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewX402Indexer creates an x402 payment indexer and starts following the head
+// of the given chain.
+func NewX402Indexer(db ethdb.Database, chain *BlockChain) *X402Indexer {
+	indexer := &X402Indexer{
+		db:    db,
+		chain: chain,
+		quit:  make(chan struct{}),
+	}
+	indexer.wg.Add(1)
+	go indexer.loop()
+
+	return indexer
+}
+
+// Close stops the indexer and waits for the pending index update to finish.
+func (indexer *X402Indexer) Close() {
+	close(indexer.quit)
+	indexer.wg.Wait()
+}
+
+func (indexer *X402Indexer) loop() {
+	defer indexer.wg.Done()
+
+	headCh := make(chan ChainHeadEvent, 10)
+	sub := indexer.chain.SubscribeChainHeadEvent(headCh)
+	defer sub.Unsubscribe()
+
+	// The index is brought up to date in batches, draining the head events in
+	// between: a long backfill must not block the chain head feed (and with it
+	// block import). Only the latest head is kept as the target.
+	var target *types.Header
+	if head := indexer.chain.CurrentBlock(); head != nil {
+		target = head.Header()
+	}
+	for {
+		if target != nil {
+			if indexer.update(target) {
+				target = nil
+			}
+			select {
+			case ev := <-headCh:
+				target = ev.Block.Header()
+			case <-sub.Err():
+				return
+			case <-indexer.quit:
+				return
+			default:
+			}
+			continue
+		}
+		select {
+		case ev := <-headCh:
+			target = ev.Block.Header()
+		case <-sub.Err():
+			return
+		case <-indexer.quit:
+			return
+		}
+	}
+}
+
+// update moves the index towards the canonical chain ending at head, by at most
+// x402IndexBatch blocks. It returns whether the index caught up with head, or
+// gave up on an error which a later head may resolve.
+func (indexer *X402Indexer) update(head *types.Header) bool {
+	var (
+		last   = rawdb.ReadX402IndexHead(indexer.db)
+		number uint64
+		budget = x402IndexBatch
+	)
+	if last == (common.Hash{}) {
+		// Nothing indexed yet, start from the genesis block
+		if err := indexer.index(indexer.chain.GetBlockByNumber(0)); err != nil {
+			log.Error("Failed to index x402 payments", "number", 0, "err", err)
+			return true
+		}
+		budget--
+	} else {
+		num := rawdb.ReadHeaderNumber(indexer.db, last)
+		if num == nil {
+			log.Error("Missing x402 index head", "hash", last)
+			return true
+		}
+		number = *num
+
+		// Roll back the blocks that are no longer canonical
+		for number > 0 && rawdb.ReadCanonicalHash(indexer.db, number) != last {
+			if budget == 0 {
+				return false
+			}
+			block := rawdb.ReadBlock(indexer.db, last, number)
+			if block == nil {
+				log.Error("Missing x402 indexed block", "number", number, "hash", last)
+				return true
+			}
+			indexer.unindex(block)
+			last, number = block.ParentHash(), number-1
+			budget--
+		}
+	}
+	for number < head.Number.Uint64() {
+		if budget == 0 {
+			return false
+		}
+		number++
+		block := indexer.chain.GetBlockByNumber(number)
+		if block == nil {
+			log.Error("Missing canonical block for x402 index", "number", number)
+			return true
+		}
+		if err := indexer.index(block); err != nil {
+			log.Error("Failed to index x402 payments", "number", number, "err", err)
+			return true
+		}
+		budget--
+	}
+	return true
+}
+
+// index adds the x402 payments of the block to the index and advances the
+// index head to it.
+func (indexer *X402Indexer) index(block *types.Block) error {
+	batch := indexer.db.NewBatch()
+	entries := x402BlockEntries(indexer.db, block)
+	for _, entry := range entries {
+		rawdb.WriteX402Payment(batch, entry.hash, entry.X402PaymentEntry)
+	}
+	x402UpdateTotals(indexer.db, batch, entries, true)
+	rawdb.WriteX402IndexHead(batch, block.Hash())
+	return batch.Write()
+}
+
+// unindex removes the x402 payments of the block from the index and rewinds
+// the index head to its parent.
+func (indexer *X402Indexer) unindex(block *types.Block) {
+	batch := indexer.db.NewBatch()
+	entries := x402BlockEntries(indexer.db, block)
+	for _, entry := range entries {
+		rawdb.DeleteX402Payment(batch, entry.hash, entry.X402PaymentEntry)
+	}
+	x402UpdateTotals(indexer.db, batch, entries, false)
+	rawdb.WriteX402IndexHead(batch, block.ParentHash())
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to unindex x402 payments", "err", err)
+	}
+}
+
+type x402IndexedPayment struct {
+	*rawdb.X402PaymentEntry
+	hash common.Hash
+}
+
+// x402BlockEntries collects the index entries of the x402 envelopes included in
+// the block. Envelopes with an undecodable payload are skipped.
+func x402BlockEntries(db ethdb.Reader, block *types.Block) []x402IndexedPayment {
+	var (
+		entries  []x402IndexedPayment
+		receipts types.Receipts
+	)
+	for i, tx := range block.Transactions() {
+		if tx.Type() != types.X402TxType {
+			continue
+		}
+		p, err := types.DecodeX402Payload(tx.Data())
+		if err != nil {
+			continue
+		}
+		if receipts == nil {
+			receipts = rawdb.ReadRawReceipts(db, block.Hash(), block.NumberU64())
+		}
+		status := types.ReceiptStatusFailed
+		if i < len(receipts) {
+			status = receipts[i].Status
+		}
+		entries = append(entries, x402IndexedPayment{
+			X402PaymentEntry: &rawdb.X402PaymentEntry{
+				BlockHash:   block.Hash(),
+				BlockNumber: block.NumberU64(),
+				TxIndex:     uint64(i),
+				Time:        block.Time(),
+				From:        p.From,
+				To:          p.To,
+				Value:       p.Value,
+				Nonce:       p.Nonce,
+				Resource:    p.Resource,
+				Status:      status,
+				Asset:       p.Asset,
+			},
+			hash: tx.Hash(),
+		})
+	}
+	return entries
+}
+
+// x402UpdateTotals adds (or removes) the successful native payments among
+// entries to the global, per-resource and daily statistics. Token payments are
+// only indexed in the payment history, as their volumes are not comparable.
+func x402UpdateTotals(db ethdb.KeyValueReader, batch ethdb.KeyValueWriter, entries []x402IndexedPayment, add bool) {
+	var (
+		totals    *rawdb.X402Totals
+		resources = make(map[string]*rawdb.X402Totals)
+		days      = make(map[uint64]*rawdb.X402Totals)
+		payers    = make(map[common.Address]uint64)
+	)
+	apply := func(totals *rawdb.X402Totals, value *big.Int) {
+		if add {
+			totals.Count++
+			totals.Volume.Add(totals.Volume, value)
+		} else {
+			totals.Count--
+			totals.Volume.Sub(totals.Volume, value)
+		}
+	}
+	for _, entry := range entries {
+		if entry.Status != types.ReceiptStatusSuccessful || entry.Asset != (common.Address{}) {
+			continue
+		}
+		if totals == nil {
+			totals = rawdb.ReadX402Totals(db)
+		}
+		apply(totals, entry.Value)
+
+		if _, ok := resources[entry.Resource]; !ok {
+			resources[entry.Resource] = rawdb.ReadX402ResourceTotals(db, entry.Resource)
+		}
+		apply(resources[entry.Resource], entry.Value)
+
+		day := entry.Time / X402SecondsPerDay
+		if _, ok := days[day]; !ok {
+			days[day] = rawdb.ReadX402DayTotals(db, day)
+		}
+		apply(days[day], entry.Value)
+
+		count, ok := payers[entry.From]
+		if !ok {
+			count = rawdb.ReadX402PayerCount(db, entry.From)
+		}
+		if add {
+			if count == 0 {
+				totals.Payers++
+			}
+			count++
+		} else {
+			count--
+			if count == 0 {
+				totals.Payers--
+			}
+		}
+		payers[entry.From] = count
+	}
+	if totals == nil {
+		return
+	}
+	rawdb.WriteX402Totals(batch, totals)
+	for resource, resourceTotals := range resources {
+		rawdb.WriteX402ResourceTotals(batch, resource, resourceTotals)
+	}
+	for day, dayTotals := range days {
+		rawdb.WriteX402DayTotals(batch, day, dayTotals)
+	}
+	for payer, count := range payers {
+		rawdb.WriteX402PayerCount(batch, payer, count)
+	}
+}
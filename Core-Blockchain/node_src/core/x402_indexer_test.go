@@ -0,0 +1,164 @@
+// Copyright 2024 Splendor Blockchain
+// Tests for the x402 payment indexer
+
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func waitX402Indexed(t *testing.T, db ethdb.Database, head common.Hash) {
+	for start := time.Now(); rawdb.ReadX402IndexHead(db) != head; {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("x402 index head mismatch: have %x, want %x", rawdb.ReadX402IndexHead(db), head)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Tests that x402 payments are indexed as blocks are imported and rolled back
+// when their blocks are reorged out of the canonical chain.
+func TestX402Indexer(t *testing.T) {
+	var (
+		config          = params.TestChainConfig
+		payerKey, _     = crypto.GenerateKey()
+		facilitator, _  = crypto.GenerateKey()
+		payer           = crypto.PubkeyToAddress(payerKey.PublicKey)
+		facilitatorAddr = crypto.PubkeyToAddress(facilitator.PublicKey)
+		payee           = common.HexToAddress("0x000000000000000000000000000000000000beef")
+		funds           = big.NewInt(1000000000000000000)
+		db              = rawdb.NewMemoryDatabase()
+		gspec           = &Genesis{
+			Config: config,
+			Alloc: GenesisAlloc{
+				payer:           {Balance: funds},
+				facilitatorAddr: {Balance: funds},
+			},
+		}
+		gendb   = rawdb.NewMemoryDatabase()
+		genesis = gspec.MustCommit(gendb)
+	)
+	gspec.MustCommit(db)
+
+	var hashes []common.Hash
+	blocks, _ := GenerateChain(config, genesis, ethash.NewFaker(), gendb, 3, func(i int, b *BlockGen) {
+		payment := &types.X402Payload{
+			From:        payer,
+			To:          payee,
+			Value:       big.NewInt(int64(100 * (i + 1))),
+			ValidBefore: ^uint64(0),
+			Nonce:       common.BigToHash(big.NewInt(int64(i))),
+			Resource:    "https://api.example.com/llm",
+		}
+		tx := newX402Envelope(t, config, facilitator, uint64(i), signX402Payload(t, payerKey, config.ChainID, payment))
+		b.AddTx(tx)
+		hashes = append(hashes, tx.Hash())
+	})
+	chain, err := NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	indexer := NewX402Indexer(db, chain)
+	defer indexer.Close()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	waitX402Indexed(t, db, blocks[2].Hash())
+
+	if totals := rawdb.ReadX402Totals(db); totals.Count != 3 || totals.Volume.Int64() != 600 || totals.Payers != 1 {
+		t.Fatalf("totals mismatch: have %d/%v/%d, want 3/600/1", totals.Count, totals.Volume, totals.Payers)
+	}
+	if totals := rawdb.ReadX402ResourceTotals(db, "https://api.example.com/llm"); totals.Count != 3 || totals.Volume.Int64() != 600 {
+		t.Fatalf("resource totals mismatch: have %d/%v, want 3/600", totals.Count, totals.Volume)
+	}
+	// History is returned most recent first, for both payer and payee
+	for _, addr := range []common.Address{payer, payee} {
+		history := rawdb.ReadX402AccountPayments(db, addr, 1, 10)
+		if len(history) != 2 || history[0] != hashes[1] || history[1] != hashes[0] {
+			t.Fatalf("history of %x mismatch: have %x", addr, history)
+		}
+	}
+	if entry := rawdb.ReadX402Payment(db, hashes[2]); entry == nil || entry.Value.Int64() != 300 || entry.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("payment entry mismatch: %+v", entry)
+	}
+	// Reorg the payments out with a longer chain that doesn't contain them
+	fork, _ := GenerateChain(config, genesis, ethash.NewFaker(), gendb, 4, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	if n, err := chain.InsertChain(fork); err != nil {
+		t.Fatalf("block %d: failed to insert fork: %v", n, err)
+	}
+	waitX402Indexed(t, db, fork[3].Hash())
+
+	if totals := rawdb.ReadX402Totals(db); totals.Count != 0 || totals.Volume.Sign() != 0 || totals.Payers != 0 {
+		t.Fatalf("totals not rolled back: have %d/%v/%d", totals.Count, totals.Volume, totals.Payers)
+	}
+	if history := rawdb.ReadX402AccountPayments(db, payer, 0, 10); len(history) != 0 {
+		t.Fatalf("history not rolled back: have %x", history)
+	}
+	if entry := rawdb.ReadX402Payment(db, hashes[0]); entry != nil {
+		t.Fatalf("payment entry not rolled back: %+v", entry)
+	}
+}
+
+// Tests that the indexer backfills a chain longer than a single batch, while
+// following the heads imported in the meantime without stalling block import.
+func TestX402IndexerBackfill(t *testing.T) {
+	var (
+		config  = params.TestChainConfig
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: config}
+		gendb   = rawdb.NewMemoryDatabase()
+		genesis = gspec.MustCommit(gendb)
+		length  = 3*x402IndexBatch + 20
+	)
+	gspec.MustCommit(db)
+
+	blocks, _ := GenerateChain(config, genesis, ethash.NewFaker(), gendb, length, nil)
+	chain, err := NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks[:length-20]); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	indexer := NewX402Indexer(db, chain)
+	defer indexer.Close()
+
+	// Import the remaining blocks one by one, each one announcing a new head
+	done := make(chan error, 1)
+	go func() {
+		for i := length - 20; i < length; i++ {
+			if _, err := chain.InsertChain(blocks[i : i+1]); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("failed to insert block: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("block import stalled by the x402 indexer")
+	}
+	waitX402Indexed(t, db, blocks[length-1].Hash())
+}
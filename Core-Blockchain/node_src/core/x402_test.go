@@ -0,0 +1,282 @@
+// Copyright 2024 Splendor Blockchain
+// Tests for consensus-level x402 settlement execution
+
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func signX402Payload(t *testing.T, key *ecdsa.PrivateKey, chainID *big.Int, p *types.X402Payload) []byte {
+	sig, err := crypto.Sign(p.SigHash(chainID).Bytes(), key)
+	if err != nil {
+		t.Fatalf("failed to sign payload: %v", err)
+	}
+	p.Signature = sig
+	enc, err := rlp.EncodeToBytes(p)
+	if err != nil {
+		t.Fatalf("failed to encode payload: %v", err)
+	}
+	return enc
+}
+
+func newX402EVM(config *params.ChainConfig, statedb *state.StateDB) *vm.EVM {
+	context := vm.BlockContext{
+		CanTransfer: CanTransfer,
+		Transfer:    Transfer,
+		BlockNumber: common.Big1,
+		Time:        common.Big1,
+		Difficulty:  common.Big0,
+		GasLimit:    params.GenesisGasLimit,
+	}
+	return vm.NewEVM(context, vm.TxContext{GasPrice: common.Big0}, statedb, config, vm.Config{})
+}
+
+func newX402Envelope(t *testing.T, config *params.ChainConfig, key *ecdsa.PrivateKey, nonce uint64, payload []byte) *types.Transaction {
+	gas, err := IntrinsicGas(payload, nil, false, true, true)
+	if err != nil {
+		t.Fatalf("failed to compute intrinsic gas: %v", err)
+	}
+	registry := types.X402RegistryAddress
+	tx, err := types.SignTx(types.NewX402Tx(config.ChainID, nonce, &registry, gas, payload), types.LatestSigner(config), key)
+	if err != nil {
+		t.Fatalf("failed to sign envelope: %v", err)
+	}
+	return tx
+}
+
+// Tests that x402 envelopes are settled during block production and that the
+// resulting blocks are reproduced by the block processor on import.
+func TestX402SettlementProcessing(t *testing.T) {
+	var (
+		config          = params.TestChainConfig
+		payerKey, _     = crypto.GenerateKey()
+		facilitator, _  = crypto.GenerateKey()
+		payer           = crypto.PubkeyToAddress(payerKey.PublicKey)
+		facilitatorAddr = crypto.PubkeyToAddress(facilitator.PublicKey)
+		payee           = common.HexToAddress("0x000000000000000000000000000000000000beef")
+		funds           = big.NewInt(1000000000000000000)
+		amount          = big.NewInt(12345)
+		db              = rawdb.NewMemoryDatabase()
+		gspec           = &Genesis{
+			Config: config,
+			Alloc: GenesisAlloc{
+				payer:           {Balance: funds},
+				facilitatorAddr: {Balance: funds},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	payment := &types.X402Payload{
+		From:        payer,
+		To:          payee,
+		Value:       amount,
+		ValidAfter:  0,
+		ValidBefore: ^uint64(0),
+		Nonce:       common.HexToHash("0x01"),
+	}
+	enc := signX402Payload(t, payerKey, config.ChainID, payment)
+
+	blocks, receipts := GenerateChain(config, genesis, ethash.NewFaker(), db, 2, func(i int, b *BlockGen) {
+		// The second block replays the same payment, which must be rejected.
+		b.AddTx(newX402Envelope(t, config, facilitator, uint64(i), enc))
+	})
+	if status := receipts[0][0].Status; status != types.ReceiptStatusSuccessful {
+		t.Fatalf("settlement receipt status mismatch: have %d, want %d", status, types.ReceiptStatusSuccessful)
+	}
+	if logs := receipts[0][0].Logs; len(logs) != 1 || logs[0].Topics[0] != types.X402SettledTopic {
+		t.Fatalf("settlement log missing: %v", logs)
+	}
+	if status := receipts[1][0].Status; status != types.ReceiptStatusFailed {
+		t.Fatalf("replayed settlement receipt status mismatch: have %d, want %d", status, types.ReceiptStatusFailed)
+	}
+	// Import the chain into a fresh node to verify every node reproduces it
+	importDB := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(importDB)
+	chain, err := NewBlockChain(importDB, nil, config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	state, _ := chain.State()
+	if balance := state.GetBalance(payee); balance.Cmp(amount) != 0 {
+		t.Errorf("payee balance mismatch: have %v, want %v", balance, amount)
+	}
+	if balance := state.GetBalance(payer); balance.Cmp(new(big.Int).Sub(funds, amount)) != 0 {
+		t.Errorf("payer balance mismatch: have %v, want %v", balance, new(big.Int).Sub(funds, amount))
+	}
+	if balance := state.GetBalance(facilitatorAddr); balance.Cmp(funds) != 0 {
+		t.Errorf("facilitator paid fees: have %v, want %v", balance, funds)
+	}
+	if nonce := state.GetNonce(facilitatorAddr); nonce != 2 {
+		t.Errorf("facilitator nonce mismatch: have %d, want 2", nonce)
+	}
+	if used := state.GetState(types.X402RegistryAddress, types.X402NonceSlot(payer, payment.Nonce)); used == (common.Hash{}) {
+		t.Error("payment nonce not recorded in registry")
+	}
+}
+
+// Tests that settlement rejects payloads not signed by the payer.
+func TestX402SettlementBadSignature(t *testing.T) {
+	var (
+		config      = params.TestChainConfig
+		payerKey, _ = crypto.GenerateKey()
+		otherKey, _ = crypto.GenerateKey()
+		statedb, _  = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		payment     = &types.X402Payload{
+			From:        crypto.PubkeyToAddress(payerKey.PublicKey),
+			To:          common.HexToAddress("0x000000000000000000000000000000000000beef"),
+			Value:       big.NewInt(1),
+			ValidBefore: ^uint64(0),
+		}
+	)
+	statedb.AddBalance(payment.From, big.NewInt(10))
+	tx := newX402Envelope(t, config, otherKey, 0, signX402Payload(t, otherKey, config.ChainID, payment))
+
+	if _, err := ApplyX402Settlement(newX402EVM(config, statedb), statedb, tx, 0); err != ErrX402BadSignature {
+		t.Fatalf("settlement error mismatch: have %v, want %v", err, ErrX402BadSignature)
+	}
+	if balance := statedb.GetBalance(payment.To); balance.Sign() != 0 {
+		t.Fatalf("rejected settlement moved funds: %v", balance)
+	}
+}
+
+// Tests that "upto" payloads settle any amount up to the signed ceiling and
+// that the ceiling is bound into the signature.
+func TestX402SettlementUpto(t *testing.T) {
+	var (
+		config      = params.TestChainConfig
+		payerKey, _ = crypto.GenerateKey()
+		payer       = crypto.PubkeyToAddress(payerKey.PublicKey)
+		payee       = common.HexToAddress("0x000000000000000000000000000000000000beef")
+	)
+	newPayment := func(nonce int64, value int64) *types.X402Payload {
+		return &types.X402Payload{
+			From:        payer,
+			To:          payee,
+			Value:       big.NewInt(value),
+			ValidBefore: ^uint64(0),
+			Nonce:       common.BigToHash(big.NewInt(nonce)),
+			Scheme:      types.X402SchemeUpto,
+			MaxValue:    big.NewInt(100),
+		}
+	}
+	tests := []struct {
+		payment *types.X402Payload
+		tamper  func(p *types.X402Payload)
+		err     error
+		settled int64
+	}{
+		{payment: newPayment(1, 40), settled: 40},
+		{payment: newPayment(2, 100), settled: 100},
+		{payment: newPayment(3, 101), err: ErrX402ExceedsCeiling},
+		// Raising the ceiling after signing invalidates the signature
+		{payment: newPayment(4, 150), tamper: func(p *types.X402Payload) { p.MaxValue = big.NewInt(200) }, err: ErrX402BadSignature},
+		{payment: newPayment(5, 10), tamper: func(p *types.X402Payload) { p.MaxValue = nil }, err: ErrX402MissingCeiling},
+		{payment: newPayment(6, 10), tamper: func(p *types.X402Payload) { p.Scheme = "streaming" }, err: ErrX402UnsupportedScheme},
+	}
+	for i, tt := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.AddBalance(payer, big.NewInt(1000))
+
+		sig, err := crypto.Sign(tt.payment.SigHash(config.ChainID).Bytes(), payerKey)
+		if err != nil {
+			t.Fatalf("test %d: failed to sign payload: %v", i, err)
+		}
+		tt.payment.Signature = sig
+		if tt.tamper != nil {
+			tt.tamper(tt.payment)
+		}
+		enc, err := rlp.EncodeToBytes(tt.payment)
+		if err != nil {
+			t.Fatalf("test %d: failed to encode payload: %v", i, err)
+		}
+		tx := newX402Envelope(t, config, payerKey, 0, enc)
+		if _, err := ApplyX402Settlement(newX402EVM(config, statedb), statedb, tx, 0); err != tt.err {
+			t.Fatalf("test %d: settlement error mismatch: have %v, want %v", i, err, tt.err)
+		}
+		if balance := statedb.GetBalance(payee); balance.Int64() != tt.settled {
+			t.Errorf("test %d: payee balance mismatch: have %v, want %d", i, balance, tt.settled)
+		}
+	}
+}
+
+// Tests that token payments are settled through an EIP-3009
+// transferWithAuthorization call to the asset and that a rejecting token
+// yields a failed receipt.
+func TestX402TokenSettlement(t *testing.T) {
+	var (
+		config         = params.TestChainConfig
+		payerKey, _    = crypto.GenerateKey()
+		facilitator, _ = crypto.GenerateKey()
+		payer          = crypto.PubkeyToAddress(payerKey.PublicKey)
+		payee          = common.HexToAddress("0x000000000000000000000000000000000000beef")
+		funds          = big.NewInt(1000000000000000000)
+		token          = common.HexToAddress("0x00000000000000000000000000000000000a5e70")
+		badToken       = common.HexToAddress("0x00000000000000000000000000000000000bad70")
+		db             = rawdb.NewMemoryDatabase()
+		gspec          = &Genesis{
+			Config: config,
+			Alloc: GenesisAlloc{
+				crypto.PubkeyToAddress(facilitator.PublicKey): {Balance: funds},
+				// Logs its call data: CALLDATACOPY(0, 0, CALLDATASIZE) LOG0(0, CALLDATASIZE)
+				token: {Balance: common.Big0, Code: common.FromHex("0x366000600037366000a000")},
+				// Always reverts
+				badToken: {Balance: common.Big0, Code: common.FromHex("0x60006000fd")},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	payments := []*types.X402Payload{
+		{From: payer, To: payee, Value: big.NewInt(5000), ValidBefore: ^uint64(0), Nonce: common.HexToHash("0x01"), Asset: token},
+		{From: payer, To: payee, Value: big.NewInt(5000), ValidBefore: ^uint64(0), Nonce: common.HexToHash("0x02"), Asset: badToken},
+	}
+	_, receipts := GenerateChain(config, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		for j, payment := range payments {
+			sig, _ := crypto.Sign(crypto.Keccak256(payment.Nonce.Bytes()), payerKey) // authorization checked by the token
+			payment.Signature = sig
+			enc, err := rlp.EncodeToBytes(payment)
+			if err != nil {
+				t.Fatalf("failed to encode payload: %v", err)
+			}
+			gas, _ := IntrinsicGas(enc, nil, false, true, true)
+			registry := types.X402RegistryAddress
+			tx, err := types.SignTx(types.NewX402Tx(config.ChainID, uint64(j), &registry, gas+X402TokenCallGas, enc), types.LatestSigner(config), facilitator)
+			if err != nil {
+				t.Fatalf("failed to sign envelope: %v", err)
+			}
+			b.AddTx(tx)
+		}
+	})
+	good, bad := receipts[0][0], receipts[0][1]
+	if good.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("token settlement failed")
+	}
+	want, _ := X402TransferWithAuthorizationData(payments[0])
+	if len(good.Logs) != 1 || good.Logs[0].Address != token || !bytes.Equal(good.Logs[0].Data, want) {
+		t.Fatalf("token call mismatch: have %v, want data %x", good.Logs, want)
+	}
+	if bad.Status != types.ReceiptStatusFailed {
+		t.Fatalf("rejected token settlement succeeded")
+	}
+	if len(bad.Logs) != 0 {
+		t.Fatalf("rejected token settlement emitted logs: %v", bad.Logs)
+	}
+}
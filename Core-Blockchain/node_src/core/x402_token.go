@@ -0,0 +1,120 @@
+// Copyright 2024 Splendor Blockchain
+// ERC-20 (EIP-3009) settlement of x402 payments
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// X402TokenCallGas is the gas a facilitator should reserve in an x402 envelope,
+// on top of the intrinsic gas, for settling an ERC-20 payment.
+const X402TokenCallGas uint64 = 150000
+
+var (
+	// x402TransferWithAuthorizationTypeHash is the EIP-712 type hash of the
+	// EIP-3009 TransferWithAuthorization struct.
+	x402TransferWithAuthorizationTypeHash = crypto.Keccak256Hash([]byte("TransferWithAuthorization(address from,address to,uint256 value,uint256 validAfter,uint256 validBefore,bytes32 nonce)"))
+
+	// Method selectors of the token calls used by x402
+	x402TransferWithAuthorizationSelector = crypto.Keccak256([]byte("transferWithAuthorization(address,address,uint256,uint256,uint256,bytes32,uint8,bytes32,bytes32)"))[:4]
+	x402AuthorizationStateSelector        = crypto.Keccak256([]byte("authorizationState(address,bytes32)"))[:4]
+	x402BalanceOfSelector                 = crypto.Keccak256([]byte("balanceOf(address)"))[:4]
+	x402DomainSeparatorSelector           = crypto.Keccak256([]byte("DOMAIN_SEPARATOR()"))[:4]
+)
+
+// x402PackCall ABI-encodes a call of the given selector with static arguments.
+func x402PackCall(selector []byte, words ...common.Hash) []byte {
+	data := make([]byte, 0, len(selector)+len(words)*common.HashLength)
+	data = append(data, selector...)
+	for _, word := range words {
+		data = append(data, word.Bytes()...)
+	}
+	return data
+}
+
+// X402TransferWithAuthorizationData returns the call data of the EIP-3009
+// transferWithAuthorization call settling the payload, the payload signature
+// being the payer's EIP-3009 authorization.
+func X402TransferWithAuthorizationData(p *types.X402Payload) ([]byte, error) {
+	if len(p.Signature) != crypto.SignatureLength {
+		return nil, fmt.Errorf("x402: invalid signature length")
+	}
+	v := p.Signature[crypto.RecoveryIDOffset]
+	if v < 27 {
+		v += 27
+	}
+	return x402PackCall(x402TransferWithAuthorizationSelector,
+		common.BytesToHash(p.From.Bytes()),
+		common.BytesToHash(p.To.Bytes()),
+		common.BigToHash(p.Value),
+		common.BigToHash(new(big.Int).SetUint64(p.ValidAfter)),
+		common.BigToHash(new(big.Int).SetUint64(p.ValidBefore)),
+		p.Nonce,
+		common.BigToHash(big.NewInt(int64(v))),
+		common.BytesToHash(p.Signature[:32]),
+		common.BytesToHash(p.Signature[32:64]),
+	), nil
+}
+
+// X402AuthorizationStateData returns the call data of the EIP-3009
+// authorizationState(authorizer, nonce) call.
+func X402AuthorizationStateData(authorizer common.Address, nonce common.Hash) []byte {
+	return x402PackCall(x402AuthorizationStateSelector, common.BytesToHash(authorizer.Bytes()), nonce)
+}
+
+// X402BalanceOfData returns the call data of the ERC-20 balanceOf(owner) call.
+func X402BalanceOfData(owner common.Address) []byte {
+	return x402PackCall(x402BalanceOfSelector, common.BytesToHash(owner.Bytes()))
+}
+
+// X402DomainSeparatorData returns the call data of the EIP-2612/EIP-3009
+// DOMAIN_SEPARATOR() call.
+func X402DomainSeparatorData() []byte {
+	return x402PackCall(x402DomainSeparatorSelector)
+}
+
+// X402TokenAuthorizationHash returns the EIP-712 digest of the EIP-3009
+// TransferWithAuthorization signed by the payer of a token payment, given the
+// token's domain separator.
+func X402TokenAuthorizationHash(domainSeparator common.Hash, p *types.X402Payload) common.Hash {
+	structHash := crypto.Keccak256Hash(
+		x402TransferWithAuthorizationTypeHash.Bytes(),
+		common.BytesToHash(p.From.Bytes()).Bytes(),
+		common.BytesToHash(p.To.Bytes()).Bytes(),
+		common.BigToHash(p.Value).Bytes(),
+		common.BigToHash(new(big.Int).SetUint64(p.ValidAfter)).Bytes(),
+		common.BigToHash(new(big.Int).SetUint64(p.ValidBefore)).Bytes(),
+		p.Nonce.Bytes(),
+	)
+	return crypto.Keccak256Hash([]byte{0x19, 0x01}, domainSeparator.Bytes(), structHash.Bytes())
+}
+
+// applyX402TokenSettlement settles a token payment by calling the EIP-3009
+// transferWithAuthorization method of the asset from the x402 registry. The
+// token verifies the authorization, consumes its nonce and emits the standard
+// Transfer log. It returns the gas used by the call.
+func applyX402TokenSettlement(evm *vm.EVM, p *types.X402Payload, gas uint64) (uint64, error) {
+	if p.Scheme == types.X402SchemeUpto {
+		// EIP-3009 authorizations are for an exact value
+		return 0, ErrX402UnsupportedScheme
+	}
+	input, err := X402TransferWithAuthorizationData(p)
+	if err != nil {
+		return 0, err
+	}
+	if len(evm.StateDB.GetCode(p.Asset)) == 0 {
+		return 0, ErrX402AssetNotContract
+	}
+	_, left, err := evm.Call(vm.AccountRef(types.X402RegistryAddress), p.Asset, input, gas, new(big.Int))
+	if err != nil {
+		return gas - left, fmt.Errorf("%w: %v", ErrX402TokenTransferFailed, err)
+	}
+	return gas - left, nil
+}
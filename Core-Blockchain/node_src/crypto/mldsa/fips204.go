@@ -0,0 +1,500 @@
+// Copyright 2024 The Splendor Authors
+// This file implements ML-DSA (FIPS 204) key generation, signing and
+// verification in pure Go. It is used by builds without liboqs and to
+// cross-check the liboqs backend in tests. Keys and signatures use the
+// standard FIPS 204 encodings, the same as liboqs.
+
+package mldsa
+
+import (
+	"crypto/subtle"
+
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	fips204SeedSize = 32 // ξ, the key generation seed
+	fips204RndSize  = 32 // rnd, the signing randomness
+	fips204TrSize   = 64 // tr, the public key hash
+	fips204MuSize   = 64 // μ, the message representative
+)
+
+// fips204Params holds an ML-DSA parameter set (FIPS 204, Table 1).
+type fips204Params struct {
+	k, l   int    // dimensions of the matrix A
+	eta    int    // private key coefficient range
+	tau    int    // number of ±1 coefficients of the challenge
+	beta   uint32 // tau * eta
+	gamma1 uint32 // coefficient range of the mask y
+	gamma2 uint32 // low-order rounding range
+	omega  int    // maximum number of hint ones
+	lambda int    // collision strength of the commitment hash
+
+	etaBits    int // bits per packed s1, s2 coefficient
+	gamma1Bits int // bits per packed z coefficient
+	w1Bits     int // bits per packed w1 coefficient
+}
+
+var fips204ParamSets = map[string]*fips204Params{
+	MLDSA44: {
+		k: 4, l: 4, eta: 2, tau: 39, beta: 78, gamma1: 1 << 17, gamma2: (fieldQ - 1) / 88, omega: 80, lambda: 128,
+		etaBits: 3, gamma1Bits: 18, w1Bits: 6,
+	},
+	MLDSA65: {
+		k: 6, l: 5, eta: 4, tau: 49, beta: 196, gamma1: 1 << 19, gamma2: (fieldQ - 1) / 32, omega: 55, lambda: 192,
+		etaBits: 4, gamma1Bits: 20, w1Bits: 4,
+	},
+	MLDSA87: {
+		k: 8, l: 7, eta: 2, tau: 60, beta: 120, gamma1: 1 << 19, gamma2: (fieldQ - 1) / 32, omega: 75, lambda: 256,
+		etaBits: 3, gamma1Bits: 20, w1Bits: 4,
+	},
+}
+
+// Encoded sizes of the keys and signatures of the parameter set.
+func (p *fips204Params) publicKeySize() int { return 32 + p.k*polyN*t1Bits/8 }
+func (p *fips204Params) secretKeySize() int {
+	return 32 + 32 + fips204TrSize + (p.k+p.l)*polyN*p.etaBits/8 + p.k*polyN*dropBits/8
+}
+func (p *fips204Params) signatureSize() int {
+	return p.lambda/4 + p.l*polyN*p.gamma1Bits/8 + p.omega + p.k
+}
+
+// fips204KeyGen derives a key pair from the seed ξ (ML-DSA.KeyGen_internal).
+func fips204KeyGen(p *fips204Params, seed []byte) (publicKey, secretKey []byte) {
+	h := sha3.NewShake256()
+	h.Write(seed)
+	h.Write([]byte{byte(p.k), byte(p.l)})
+	var expanded [128]byte
+	h.Read(expanded[:])
+	rho, rhoPrime, key := expanded[:32], expanded[32:96], expanded[96:]
+
+	A := expandA(p, rho)
+	s1, s2 := expandS(p, rhoPrime)
+
+	s1Hat := make([]poly, p.l)
+	for i := range s1 {
+		s1Hat[i] = s1[i]
+		s1Hat[i].ntt()
+	}
+	t1 := make([]poly, p.k)
+	t0 := make([]poly, p.k)
+	for i := 0; i < p.k; i++ {
+		var t poly
+		for j := 0; j < p.l; j++ {
+			t.mulAccNTT(&A[i][j], &s1Hat[j])
+		}
+		t.invNTT()
+		t.add(&t, &s2[i])
+		for c := range t {
+			t1[i][c], t0[i][c] = power2Round(t[c])
+		}
+	}
+	publicKey = make([]byte, 0, p.publicKeySize())
+	publicKey = append(publicKey, rho...)
+	for i := range t1 {
+		publicKey = packBits(publicKey, &t1[i], t1Bits)
+	}
+	tr := shake256(fips204TrSize, publicKey)
+
+	secretKey = make([]byte, 0, p.secretKeySize())
+	secretKey = append(secretKey, rho...)
+	secretKey = append(secretKey, key...)
+	secretKey = append(secretKey, tr...)
+	for i := range s1 {
+		secretKey = packSigned(secretKey, &s1[i], uint32(p.eta), p.etaBits)
+	}
+	for i := range s2 {
+		secretKey = packSigned(secretKey, &s2[i], uint32(p.eta), p.etaBits)
+	}
+	for i := range t0 {
+		secretKey = packSigned(secretKey, &t0[i], 1<<(dropBits-1), dropBits)
+	}
+	return publicKey, secretKey
+}
+
+// fips204Sign signs the formatted message M' with the randomness rnd
+// (ML-DSA.Sign_internal). The secret key must have the size of the parameter
+// set.
+func fips204Sign(p *fips204Params, secretKey, message, rnd []byte) []byte {
+	var (
+		rho = secretKey[:32]
+		key = secretKey[32:64]
+		tr  = secretKey[64:128]
+		off = 128
+	)
+	unpack := func(bound uint32, bits int) poly {
+		var f poly
+		size := polyN * bits / 8
+		unpackSigned(&f, secretKey[off:off+size], bound, bits)
+		off += size
+		return f
+	}
+	s1Hat := make([]poly, p.l)
+	for i := range s1Hat {
+		s1Hat[i] = unpack(uint32(p.eta), p.etaBits)
+		s1Hat[i].ntt()
+	}
+	s2Hat := make([]poly, p.k)
+	for i := range s2Hat {
+		s2Hat[i] = unpack(uint32(p.eta), p.etaBits)
+		s2Hat[i].ntt()
+	}
+	t0Hat := make([]poly, p.k)
+	for i := range t0Hat {
+		t0Hat[i] = unpack(1<<(dropBits-1), dropBits)
+		t0Hat[i].ntt()
+	}
+	A := expandA(p, rho)
+	mu := shake256(fips204MuSize, tr, message)
+	rhoPrime := shake256(64, key, rnd, mu)
+
+	var (
+		y   = make([]poly, p.l)
+		z   = make([]poly, p.l)
+		w   = make([]poly, p.k)
+		w1  = make([]poly, p.k)
+		r   = make([]poly, p.k)
+		h   = make([]poly, p.k)
+		w1e = make([]byte, 0, p.k*polyN*p.w1Bits/8)
+	)
+	for kappa := 0; ; kappa += p.l {
+		expandMask(p, y, rhoPrime, kappa)
+
+		// w = A * y, w1 = HighBits(w)
+		yHat := make([]poly, p.l)
+		for i := range y {
+			yHat[i] = y[i]
+			yHat[i].ntt()
+		}
+		w1e = w1e[:0]
+		for i := 0; i < p.k; i++ {
+			w[i] = poly{}
+			for j := 0; j < p.l; j++ {
+				w[i].mulAccNTT(&A[i][j], &yHat[j])
+			}
+			w[i].invNTT()
+			for c := range w[i] {
+				w1[i][c], _ = decompose(w[i][c], p.gamma2)
+			}
+			w1e = packBits(w1e, &w1[i], p.w1Bits)
+		}
+		cTilde := shake256(p.lambda/4, mu, w1e)
+		cHat := sampleInBall(p, cTilde)
+		cHat.ntt()
+
+		// z = y + c*s1
+		reject := false
+		for i := 0; i < p.l && !reject; i++ {
+			var cs1 poly
+			cs1.mulNTT(&cHat, &s1Hat[i])
+			cs1.invNTT()
+			z[i].add(&y[i], &cs1)
+			reject = z[i].infinityNorm() >= p.gamma1-p.beta
+		}
+		if reject {
+			continue
+		}
+		// r0 = LowBits(w - c*s2)
+		for i := 0; i < p.k && !reject; i++ {
+			var cs2 poly
+			cs2.mulNTT(&cHat, &s2Hat[i])
+			cs2.invNTT()
+			r[i].sub(&w[i], &cs2)
+			for c := range r[i] {
+				if _, r0 := decompose(r[i][c], p.gamma2); abs32(r0) >= int32(p.gamma2-p.beta) {
+					reject = true
+					break
+				}
+			}
+		}
+		if reject {
+			continue
+		}
+		// h = MakeHint(-c*t0, w - c*s2 + c*t0)
+		ones := 0
+		for i := 0; i < p.k && !reject; i++ {
+			var ct0 poly
+			ct0.mulNTT(&cHat, &t0Hat[i])
+			ct0.invNTT()
+			if ct0.infinityNorm() >= p.gamma2 {
+				reject = true
+				break
+			}
+			for c := range ct0 {
+				r1, _ := decompose(r[i][c], p.gamma2)
+				v1, _ := decompose(fieldAdd(r[i][c], ct0[c]), p.gamma2)
+				h[i][c] = 0
+				if r1 != v1 {
+					h[i][c] = 1
+					ones++
+				}
+			}
+		}
+		if reject || ones > p.omega {
+			continue
+		}
+		return encodeSignature(p, cTilde, z, h)
+	}
+}
+
+// fips204Verify verifies a signature over the formatted message M'
+// (ML-DSA.Verify_internal). The public key and signature must have the sizes
+// of the parameter set.
+func fips204Verify(p *fips204Params, publicKey, message, signature []byte) bool {
+	var (
+		rho    = publicKey[:32]
+		cTilde = signature[:p.lambda/4]
+		z      = make([]poly, p.l)
+		off    = p.lambda / 4
+	)
+	for i := range z {
+		size := polyN * p.gamma1Bits / 8
+		unpackSigned(&z[i], signature[off:off+size], p.gamma1, p.gamma1Bits)
+		if z[i].infinityNorm() >= p.gamma1-p.beta {
+			return false
+		}
+		z[i].ntt()
+		off += size
+	}
+	h, ok := unpackHints(p, signature[off:])
+	if !ok {
+		return false
+	}
+	A := expandA(p, rho)
+	tr := shake256(fips204TrSize, publicKey)
+	mu := shake256(fips204MuSize, tr, message)
+	cHat := sampleInBall(p, cTilde)
+	cHat.ntt()
+
+	// w'_approx = A*z - c*t1*2^d, w1' = UseHint(h, w'_approx)
+	w1e := make([]byte, 0, p.k*polyN*p.w1Bits/8)
+	for i := 0; i < p.k; i++ {
+		var t1, w, w1 poly
+		unpackBits(&t1, publicKey[32+i*polyN*t1Bits/8:], t1Bits)
+		for c := range t1 {
+			t1[c] <<= dropBits
+		}
+		t1.ntt()
+		t1.mulNTT(&t1, &cHat)
+		for j := 0; j < p.l; j++ {
+			w.mulAccNTT(&A[i][j], &z[j])
+		}
+		w.sub(&w, &t1)
+		w.invNTT()
+		for c := range w {
+			w1[c] = useHint(h[i][c] == 1, w[c], p.gamma2)
+		}
+		w1e = packBits(w1e, &w1, p.w1Bits)
+	}
+	return subtle.ConstantTimeCompare(cTilde, shake256(p.lambda/4, mu, w1e)) == 1
+}
+
+// encodeSignature encodes a signature (sigEncode).
+func encodeSignature(p *fips204Params, cTilde []byte, z, h []poly) []byte {
+	sig := make([]byte, 0, p.signatureSize())
+	sig = append(sig, cTilde...)
+	for i := range z {
+		sig = packSigned(sig, &z[i], p.gamma1, p.gamma1Bits)
+	}
+	hints := make([]byte, p.omega+p.k)
+	index := 0
+	for i := range h {
+		for c := range h[i] {
+			if h[i][c] != 0 {
+				hints[index] = byte(c)
+				index++
+			}
+		}
+		hints[p.omega+i] = byte(index)
+	}
+	return append(sig, hints...)
+}
+
+// unpackHints decodes the hint vector of a signature (HintBitUnpack), rejecting
+// non-canonical encodings.
+func unpackHints(p *fips204Params, data []byte) ([]poly, bool) {
+	h := make([]poly, p.k)
+	index := 0
+	for i := 0; i < p.k; i++ {
+		limit := int(data[p.omega+i])
+		if limit < index || limit > p.omega {
+			return nil, false
+		}
+		for first := index; index < limit; index++ {
+			if index > first && data[index-1] >= data[index] {
+				return nil, false
+			}
+			h[i][data[index]] = 1
+		}
+	}
+	for ; index < p.omega; index++ {
+		if data[index] != 0 {
+			return nil, false
+		}
+	}
+	return h, true
+}
+
+// expandA samples the matrix A in the NTT domain from the seed rho (ExpandA).
+func expandA(p *fips204Params, rho []byte) [][]poly {
+	A := make([][]poly, p.k)
+	h := sha3.NewShake128()
+	var buf [3 * 56]byte // a multiple of 3 dividing the SHAKE128 rate
+	for i := range A {
+		A[i] = make([]poly, p.l)
+		for j := range A[i] {
+			h.Reset()
+			h.Write(rho)
+			h.Write([]byte{byte(j), byte(i)})
+			for c := 0; c < polyN; {
+				h.Read(buf[:])
+				for b := 0; b < len(buf) && c < polyN; b += 3 {
+					v := uint32(buf[b]) | uint32(buf[b+1])<<8 | uint32(buf[b+2]&0x7f)<<16
+					if v < fieldQ {
+						A[i][j][c] = v
+						c++
+					}
+				}
+			}
+		}
+	}
+	return A
+}
+
+// expandS samples the secret vectors s1 and s2 with coefficients in
+// [-eta, eta] from the seed rhoPrime (ExpandS).
+func expandS(p *fips204Params, rhoPrime []byte) (s1, s2 []poly) {
+	s := make([]poly, p.l+p.k)
+	h := sha3.NewShake256()
+	var buf [136]byte // the SHAKE256 rate
+	for r := range s {
+		h.Reset()
+		h.Write(rhoPrime)
+		h.Write([]byte{byte(r), byte(r >> 8)})
+		for c := 0; c < polyN; {
+			h.Read(buf[:])
+			for b := 0; b < len(buf) && c < polyN; b++ {
+				for _, nibble := range [2]byte{buf[b] & 0x0f, buf[b] >> 4} {
+					if c == polyN {
+						break
+					}
+					if v, ok := coeffFromHalfByte(p.eta, nibble); ok {
+						s[r][c] = v
+						c++
+					}
+				}
+			}
+		}
+	}
+	return s[:p.l], s[p.l:]
+}
+
+func coeffFromHalfByte(eta int, b byte) (uint32, bool) {
+	switch {
+	case eta == 2 && b < 15:
+		return fieldSub(2, uint32(b%5)), true
+	case eta == 4 && b < 9:
+		return fieldSub(4, uint32(b)), true
+	}
+	return 0, false
+}
+
+// expandMask samples the mask vector y with coefficients in
+// (-gamma1, gamma1] (ExpandMask).
+func expandMask(p *fips204Params, y []poly, rhoPrime []byte, kappa int) {
+	h := sha3.NewShake256()
+	buf := make([]byte, polyN*p.gamma1Bits/8)
+	for r := range y {
+		h.Reset()
+		h.Write(rhoPrime)
+		h.Write([]byte{byte(kappa + r), byte((kappa + r) >> 8)})
+		h.Read(buf)
+		unpackSigned(&y[r], buf, p.gamma1, p.gamma1Bits)
+	}
+}
+
+// sampleInBall samples the challenge polynomial with tau coefficients in
+// {-1, 1} from the commitment hash (SampleInBall).
+func sampleInBall(p *fips204Params, seed []byte) poly {
+	h := sha3.NewShake256()
+	h.Write(seed)
+	var signs [8]byte
+	h.Read(signs[:])
+
+	var (
+		c poly
+		b [1]byte
+	)
+	for i, k := polyN-p.tau, 0; i < polyN; i, k = i+1, k+1 {
+		for {
+			h.Read(b[:])
+			if int(b[0]) <= i {
+				break
+			}
+		}
+		j := int(b[0])
+		c[i] = c[j]
+		if signs[k/8]>>(k%8)&1 == 1 {
+			c[j] = fieldQ - 1
+		} else {
+			c[j] = 1
+		}
+	}
+	return c
+}
+
+// power2Round splits r into r1*2^d + r0 with r0 in (-2^(d-1), 2^(d-1)],
+// returning r1 and r0 mod q (Power2Round).
+func power2Round(r uint32) (uint32, uint32) {
+	r0 := int32(r & (1<<dropBits - 1))
+	if r0 > 1<<(dropBits-1) {
+		r0 -= 1 << dropBits
+	}
+	return uint32((int32(r) - r0) >> dropBits), fieldFromInt(r0)
+}
+
+// decompose splits r into r1*2*gamma2 + r0 with r0 in (-gamma2, gamma2]
+// (Decompose).
+func decompose(r, gamma2 uint32) (uint32, int32) {
+	r0 := int32(r % (2 * gamma2))
+	if r0 > int32(gamma2) {
+		r0 -= int32(2 * gamma2)
+	}
+	if int32(r)-r0 == fieldQ-1 {
+		return 0, r0 - 1
+	}
+	return uint32(int32(r)-r0) / (2 * gamma2), r0
+}
+
+// useHint returns the high bits of r adjusted by the hint h (UseHint).
+func useHint(h bool, r, gamma2 uint32) uint32 {
+	m := (fieldQ - 1) / (2 * gamma2)
+	r1, r0 := decompose(r, gamma2)
+	switch {
+	case !h:
+		return r1
+	case r0 > 0:
+		return (r1 + 1) % m
+	default:
+		return (r1 + m - 1) % m
+	}
+}
+
+// shake256 returns size bytes of SHAKE256 output over the inputs.
+func shake256(size int, inputs ...[]byte) []byte {
+	h := sha3.NewShake256()
+	for _, input := range inputs {
+		h.Write(input)
+	}
+	out := make([]byte, size)
+	h.Read(out)
+	return out
+}
+
+// fips204Message formats a message with an empty context string, as done by
+// ML-DSA.Sign and ML-DSA.Verify (and liboqs).
+func fips204Message(message []byte) []byte {
+	formatted := make([]byte, 0, 2+len(message))
+	formatted = append(formatted, 0, 0)
+	return append(formatted, message...)
+}
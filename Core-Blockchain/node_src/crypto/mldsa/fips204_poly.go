@@ -0,0 +1,201 @@
+// Copyright 2024 The Splendor Authors
+// Polynomial arithmetic and encodings of ML-DSA (FIPS 204)
+
+package mldsa
+
+const (
+	polyN    = 256     // number of polynomial coefficients
+	fieldQ   = 8380417 // the ML-DSA prime 2^23 - 2^13 + 1
+	dropBits = 13      // d, the number of bits dropped from t
+	t1Bits   = 10      // bitlen(q-1) - d, bits per packed t1 coefficient
+
+	nttZeta      = 1753    // 512th root of unity modulo q
+	nttInvDegree = 8347681 // 256^-1 mod q
+)
+
+// poly is a polynomial of Z_q[X]/(X^256+1), with coefficients in [0, q). It is
+// either in the normal or in the NTT domain.
+type poly [polyN]uint32
+
+// nttZetas holds zeta^BitRev8(k) mod q.
+var nttZetas [polyN]uint32
+
+func init() {
+	powers := make([]uint32, polyN)
+	powers[0] = 1
+	for i := 1; i < polyN; i++ {
+		powers[i] = fieldMul(powers[i-1], nttZeta)
+	}
+	for k := range nttZetas {
+		var rev int
+		for b := 0; b < 8; b++ {
+			rev |= (k >> b & 1) << (7 - b)
+		}
+		nttZetas[k] = powers[rev]
+	}
+}
+
+func fieldAdd(a, b uint32) uint32 {
+	r := a + b
+	if r >= fieldQ {
+		r -= fieldQ
+	}
+	return r
+}
+
+func fieldSub(a, b uint32) uint32 {
+	return fieldAdd(a, fieldQ-b)
+}
+
+func fieldMul(a, b uint32) uint32 {
+	return uint32(uint64(a) * uint64(b) % fieldQ)
+}
+
+func fieldFromInt(a int32) uint32 {
+	a %= fieldQ
+	if a < 0 {
+		a += fieldQ
+	}
+	return uint32(a)
+}
+
+// fieldCentered returns a mod± q, in [-(q-1)/2, (q-1)/2].
+func fieldCentered(a uint32) int32 {
+	if a > (fieldQ-1)/2 {
+		return int32(a) - fieldQ
+	}
+	return int32(a)
+}
+
+func abs32(a int32) int32 {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+func (f *poly) add(a, b *poly) {
+	for i := range f {
+		f[i] = fieldAdd(a[i], b[i])
+	}
+}
+
+func (f *poly) sub(a, b *poly) {
+	for i := range f {
+		f[i] = fieldSub(a[i], b[i])
+	}
+}
+
+// mulNTT sets f to the product of a and b in the NTT domain.
+func (f *poly) mulNTT(a, b *poly) {
+	for i := range f {
+		f[i] = fieldMul(a[i], b[i])
+	}
+}
+
+// mulAccNTT adds the product of a and b in the NTT domain to f.
+func (f *poly) mulAccNTT(a, b *poly) {
+	for i := range f {
+		f[i] = fieldAdd(f[i], fieldMul(a[i], b[i]))
+	}
+}
+
+// infinityNorm returns the maximum absolute value of the centered coefficients.
+func (f *poly) infinityNorm() uint32 {
+	var norm int32
+	for _, c := range f {
+		if v := abs32(fieldCentered(c)); v > norm {
+			norm = v
+		}
+	}
+	return uint32(norm)
+}
+
+// ntt transforms f to the NTT domain in place (FIPS 204, Algorithm 41).
+func (f *poly) ntt() {
+	k := 0
+	for length := 128; length >= 1; length /= 2 {
+		for start := 0; start < polyN; start += 2 * length {
+			k++
+			zeta := nttZetas[k]
+			for j := start; j < start+length; j++ {
+				t := fieldMul(zeta, f[j+length])
+				f[j+length] = fieldSub(f[j], t)
+				f[j] = fieldAdd(f[j], t)
+			}
+		}
+	}
+}
+
+// invNTT transforms f back from the NTT domain in place (FIPS 204, Algorithm 42).
+func (f *poly) invNTT() {
+	k := polyN
+	for length := 1; length < polyN; length *= 2 {
+		for start := 0; start < polyN; start += 2 * length {
+			k--
+			zeta := fieldQ - nttZetas[k]
+			for j := start; j < start+length; j++ {
+				t := f[j]
+				f[j] = fieldAdd(t, f[j+length])
+				f[j+length] = fieldMul(zeta, fieldSub(t, f[j+length]))
+			}
+		}
+	}
+	for i := range f {
+		f[i] = fieldMul(f[i], nttInvDegree)
+	}
+}
+
+// packBits appends the coefficients of f, each encoded little-endian in bits
+// bits, to out (SimpleBitPack).
+func packBits(out []byte, f *poly, bits int) []byte {
+	var (
+		acc  uint64
+		have int
+	)
+	for _, c := range f {
+		acc |= uint64(c) << have
+		for have += bits; have >= 8; have -= 8 {
+			out = append(out, byte(acc))
+			acc >>= 8
+		}
+	}
+	return out
+}
+
+// unpackBits decodes the coefficients of f from data (SimpleBitUnpack).
+func unpackBits(f *poly, data []byte, bits int) {
+	var (
+		acc  uint64
+		have int
+		mask = uint64(1)<<bits - 1
+	)
+	for i := range f {
+		for have < bits {
+			acc |= uint64(data[0]) << have
+			data = data[1:]
+			have += 8
+		}
+		f[i] = uint32(acc & mask)
+		acc >>= bits
+		have -= bits
+	}
+}
+
+// packSigned appends the coefficients c of f as bound - c in bits bits, which
+// must hold 0 <= bound - c < 2^bits (BitPack).
+func packSigned(out []byte, f *poly, bound uint32, bits int) []byte {
+	var packed poly
+	for i, c := range f {
+		packed[i] = uint32(int32(bound) - fieldCentered(c))
+	}
+	return packBits(out, &packed, bits)
+}
+
+// unpackSigned decodes coefficients packed by packSigned (BitUnpack).
+func unpackSigned(f *poly, data []byte, bound uint32, bits int) {
+	unpackBits(f, data, bits)
+	for i, c := range f {
+		f[i] = fieldFromInt(int32(bound) - int32(c))
+	}
+}
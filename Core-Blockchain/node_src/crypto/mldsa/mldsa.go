@@ -3,42 +3,16 @@
 
 // Copyright 2024 The Splendor Authors
 // This file implements ML-DSA (Dilithium) signature verification for quantum resistance
-// Based on FIPS 204 specification - Fallback implementation without liboqs
+// Based on FIPS 204 specification - Pure Go implementation without liboqs
 
 package mldsa
 
 import (
+	"crypto/rand"
 	"errors"
 )
 
-// ML-DSA algorithm variants
-const (
-	MLDSA44 = "ML-DSA-44" // Compact variant for user transactions
-	MLDSA65 = "ML-DSA-65" // Recommended variant for consensus
-	MLDSA87 = "ML-DSA-87" // High security variant
-)
-
-// ML-DSA parameter sets (approximate sizes in bytes)
-var MLDSAParams = map[string]struct {
-	PublicKeySize  int
-	SignatureSize  int
-	SecurityLevel  int
-}{
-	MLDSA44: {PublicKeySize: 1312, SignatureSize: 2420, SecurityLevel: 2},
-	MLDSA65: {PublicKeySize: 1952, SignatureSize: 3309, SecurityLevel: 3},
-	MLDSA87: {PublicKeySize: 2592, SignatureSize: 4627, SecurityLevel: 5},
-}
-
-var (
-	ErrInvalidAlgorithm    = errors.New("invalid ML-DSA algorithm")
-	ErrInvalidSignature    = errors.New("invalid signature")
-	ErrInvalidPublicKey    = errors.New("invalid public key")
-	ErrInvalidLength       = errors.New("invalid signature or public key length")
-	ErrVerificationFailed  = errors.New("signature verification failed")
-	ErrLibOQSNotAvailable  = errors.New("liboqs library not available")
-)
-
-// VerifySignature verifies an ML-DSA signature (fallback implementation)
+// VerifySignature verifies an ML-DSA signature
 func VerifySignature(algorithm string, message, signature, publicKey []byte) error {
 	if len(message) == 0 {
 		return errors.New("empty message")
@@ -51,23 +25,19 @@ func VerifySignature(algorithm string, message, signature, publicKey []byte) err
 	}
 
 	// Validate algorithm
-	if _, exists := MLDSAParams[algorithm]; !exists {
+	p, exists := fips204ParamSets[algorithm]
+	if !exists {
 		return ErrInvalidAlgorithm
 	}
 
 	// Validate lengths against expected parameters
-	params := MLDSAParams[algorithm]
-	if len(signature) != params.SignatureSize {
-		return ErrInvalidLength
+	if err := ValidateMLDSAParams(algorithm, signature, publicKey); err != nil {
+		return err
 	}
-	if len(publicKey) != params.PublicKeySize {
-		return ErrInvalidLength
+	if !fips204Verify(p, publicKey, fips204Message(message), signature) {
+		return ErrVerificationFailed
 	}
-
-	// In fallback mode, we cannot actually verify the signature
-	// This would need to be replaced with a pure Go implementation
-	// or return an error indicating liboqs is required
-	return ErrLibOQSNotAvailable
+	return nil
 }
 
 // GetMLDSALengths returns the expected signature and public key lengths for an algorithm
@@ -80,40 +50,60 @@ func GetMLDSALengths(algorithm string) (sigLen, pkLen int, err error) {
 	return params.SignatureSize, params.PublicKeySize, nil
 }
 
-// ValidateMLDSAParams validates ML-DSA signature and public key lengths
-func ValidateMLDSAParams(algorithm string, signature, publicKey []byte) error {
-	expectedSigLen, expectedPkLen, err := GetMLDSALengths(algorithm)
-	if err != nil {
-		return err
-	}
-
-	if len(signature) != expectedSigLen {
-		return ErrInvalidLength
-	}
-
-	if len(publicKey) != expectedPkLen {
-		return ErrInvalidLength
-	}
-
-	return nil
-}
-
-// IsMLDSASupported checks if ML-DSA is supported (always false in fallback mode)
+// IsMLDSASupported checks if ML-DSA is supported (all FIPS 204 parameter sets are)
 func IsMLDSASupported(algorithm string) bool {
-	return false // liboqs not available in fallback mode
+	_, exists := fips204ParamSets[algorithm]
+	return exists
 }
 
-// GenerateKeyPair generates an ML-DSA key pair (not available in fallback mode)
+// GenerateKeyPair generates an ML-DSA key pair
 func GenerateKeyPair(algorithm string) (publicKey, secretKey []byte, err error) {
-	return nil, nil, ErrLibOQSNotAvailable
+	p, exists := fips204ParamSets[algorithm]
+	if !exists {
+		return nil, nil, ErrInvalidAlgorithm
+	}
+	seed := make([]byte, fips204SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, nil, err
+	}
+	publicKey, secretKey = fips204KeyGen(p, seed)
+	return publicKey, secretKey, nil
 }
 
-// SignMessage signs a message with ML-DSA (not available in fallback mode)
+// SignMessage signs a message with ML-DSA, using hedged (randomized) signing
+// like liboqs
 func SignMessage(algorithm string, message, secretKey []byte) (signature []byte, err error) {
-	return nil, ErrLibOQSNotAvailable
+	if len(message) == 0 {
+		return nil, errors.New("empty message")
+	}
+	if len(secretKey) == 0 {
+		return nil, errors.New("empty secret key")
+	}
+	p, exists := fips204ParamSets[algorithm]
+	if !exists {
+		return nil, ErrInvalidAlgorithm
+	}
+	if len(secretKey) != MLDSAParams[algorithm].SecretKeySize {
+		return nil, ErrInvalidSecretKey
+	}
+	rnd := make([]byte, fips204RndSize)
+	if _, err := rand.Read(rnd); err != nil {
+		return nil, err
+	}
+	return fips204Sign(p, secretKey, fips204Message(message), rnd), nil
 }
 
-// BatchVerifySignatures verifies multiple ML-DSA signatures (not available in fallback mode)
+// BatchVerifySignatures verifies multiple ML-DSA signatures
 func BatchVerifySignatures(algorithm string, messages [][]byte, signatures [][]byte, publicKeys [][]byte) ([]bool, error) {
-	return nil, ErrLibOQSNotAvailable
+	if len(messages) != len(signatures) || len(signatures) != len(publicKeys) {
+		return nil, errors.New("mismatched batch sizes")
+	}
+	if _, exists := fips204ParamSets[algorithm]; !exists {
+		return nil, ErrInvalidAlgorithm
+	}
+	results := make([]bool, len(messages))
+	for i := range messages {
+		results[i] = VerifySignature(algorithm, messages[i], signatures[i], publicKeys[i]) == nil
+	}
+	return results, nil
 }
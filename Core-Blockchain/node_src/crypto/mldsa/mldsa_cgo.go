@@ -133,17 +133,10 @@ import (
 )
 
 // mapToOQSName maps Splendor ML-DSA identifiers to liboqs algorithm names.
+// liboqs names the FIPS 204 schemes like we do, the Dilithium2/3/5 round 3
+// schemes are not byte-compatible with them.
 func mapToOQSName(alg string) string {
-	switch alg {
-	case MLDSA44:
-		return "Dilithium2"
-	case MLDSA65:
-		return "Dilithium3"
-	case MLDSA87:
-		return "Dilithium5"
-	default:
-		return alg
-	}
+	return alg
 }
 
 // VerifySignature verifies an ML-DSA signature using liboqs
@@ -236,13 +229,12 @@ func GenerateKeyPair(algorithm string) (publicKey, secretKey []byte, err error)
 	}
 
 	// Get key sizes
-	sigLen, pkLen, err := GetMLDSALengths(algorithm)
+	_, pkLen, err := GetMLDSALengths(algorithm)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// Calculate secret key size (typically 2.5x signature size for ML-DSA)
-	skLen := sigLen + pkLen // Approximate secret key size
+	skLen := MLDSAParams[algorithm].SecretKeySize
 
 	publicKey = make([]byte, pkLen)
 	secretKey = make([]byte, skLen)
@@ -0,0 +1,54 @@
+//go:build cgo && !no_liboqs
+// +build cgo,!no_liboqs
+
+// Copyright 2024 The Splendor Authors
+// Cross-checks of the liboqs backend against the pure Go implementation
+
+package mldsa
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Tests that keys and signatures of liboqs and of the pure Go implementation
+// are interchangeable.
+func TestLibOQSCrossCheck(t *testing.T) {
+	message := []byte("cross-check message")
+	for _, algorithm := range []string{MLDSA44, MLDSA65, MLDSA87} {
+		if !IsMLDSASupported(algorithm) {
+			t.Skipf("%s not supported by liboqs", algorithm)
+		}
+		p := fips204ParamSets[algorithm]
+
+		// liboqs keys and signatures verify with the Go implementation
+		pk, sk, err := GenerateKeyPair(algorithm)
+		if err != nil {
+			t.Fatalf("%s: liboqs key generation failed: %v", algorithm, err)
+		}
+		sig, err := SignMessage(algorithm, message, sk)
+		if err != nil {
+			t.Fatalf("%s: liboqs signing failed: %v", algorithm, err)
+		}
+		if !fips204Verify(p, pk, fips204Message(message), sig) {
+			t.Errorf("%s: liboqs signature rejected by the Go implementation", algorithm)
+		}
+		// The Go implementation signs with liboqs secret keys
+		sig = fips204Sign(p, sk, fips204Message(message), make([]byte, fips204RndSize))
+		if err := VerifySignature(algorithm, message, sig, pk); err != nil {
+			t.Errorf("%s: Go signature with liboqs key rejected by liboqs: %v", algorithm, err)
+		}
+		// Go keys and signatures verify with liboqs
+		pk, sk = fips204KeyGen(p, bytes.Repeat([]byte{0x01}, fips204SeedSize))
+		sig = fips204Sign(p, sk, fips204Message(message), make([]byte, fips204RndSize))
+		if err := VerifySignature(algorithm, message, sig, pk); err != nil {
+			t.Errorf("%s: Go signature rejected by liboqs: %v", algorithm, err)
+		}
+		if sig, err = SignMessage(algorithm, message, sk); err != nil {
+			t.Fatalf("%s: liboqs signing with Go key failed: %v", algorithm, err)
+		}
+		if !fips204Verify(p, pk, fips204Message(message), sig) {
+			t.Errorf("%s: liboqs signature with Go key rejected by the Go implementation", algorithm)
+		}
+	}
+}
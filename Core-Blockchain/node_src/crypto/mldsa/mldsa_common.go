@@ -1,9 +1,6 @@
-//go:build cgo && !no_liboqs
-// +build cgo,!no_liboqs
-
-// Shared ML-DSA constants, parameters, and validation for CGO-enabled builds
-// This complements mldsa_cgo.go. The fallback implementation with build tag
-// !cgo || no_liboqs defines its own copies to avoid duplicate symbols.
+// Shared ML-DSA constants, parameters, and validation
+// These are used by both the liboqs (cgo) build and the pure Go build, which
+// share the FIPS 204 implementation in fips204.go.
 
 package mldsa
 
@@ -16,28 +13,29 @@ const (
 	MLDSA87 = "ML-DSA-87" // High security variant
 )
 
-// ML-DSA parameter sets (approximate sizes in bytes)
+// ML-DSA parameter sets (FIPS 204 sizes in bytes)
 var MLDSAParams = map[string]struct {
 	PublicKeySize int
+	SecretKeySize int
 	SignatureSize int
 	SecurityLevel int
 }{
-	MLDSA44: {PublicKeySize: 1312, SignatureSize: 2420, SecurityLevel: 2},
-	MLDSA65: {PublicKeySize: 1952, SignatureSize: 3293, SecurityLevel: 3},
-	MLDSA87: {PublicKeySize: 2592, SignatureSize: 4595, SecurityLevel: 5},
+	MLDSA44: {PublicKeySize: 1312, SecretKeySize: 2560, SignatureSize: 2420, SecurityLevel: 2},
+	MLDSA65: {PublicKeySize: 1952, SecretKeySize: 4032, SignatureSize: 3309, SecurityLevel: 3},
+	MLDSA87: {PublicKeySize: 2592, SecretKeySize: 4896, SignatureSize: 4627, SecurityLevel: 5},
 }
 
 var (
 	ErrInvalidAlgorithm   = errors.New("invalid ML-DSA algorithm")
 	ErrInvalidSignature   = errors.New("invalid signature")
 	ErrInvalidPublicKey   = errors.New("invalid public key")
+	ErrInvalidSecretKey   = errors.New("invalid secret key")
 	ErrInvalidLength      = errors.New("invalid signature or public key length")
 	ErrVerificationFailed = errors.New("signature verification failed")
 	ErrLibOQSNotAvailable = errors.New("liboqs library not available")
 )
 
-// ValidateMLDSAParams validates ML-DSA signature and public key lengths.
-// In CGO-enabled builds, this uses sizes provided by GetMLDSALengths from cgo.
+// ValidateMLDSAParams validates ML-DSA signature and public key lengths
 func ValidateMLDSAParams(algorithm string, signature, publicKey []byte) error {
 	expectedSigLen, expectedPkLen, err := GetMLDSALengths(algorithm)
 	if err != nil {
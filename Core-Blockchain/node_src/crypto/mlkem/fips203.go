@@ -0,0 +1,395 @@
+// Copyright 2024 The Splendor Authors
+// This file implements ML-KEM (FIPS 203) key generation, encapsulation and
+// decapsulation in pure Go. It is used by builds without liboqs and to
+// cross-check the liboqs backend in tests. Keys and ciphertexts use the
+// standard FIPS 203 encodings, the same as liboqs.
+
+package mlkem
+
+import (
+	"crypto/subtle"
+
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	polyN = 256 // number of polynomial coefficients
+
+	nttZeta      = 17   // 256th root of unity modulo q
+	nttInvDegree = 3303 // 128^-1 mod q
+
+	fips203SeedSize    = 32 // d and z, the key generation seeds
+	fips203MessageSize = 32 // m, the encapsulation randomness
+	fips203SharedSize  = 32 // K, the shared secret
+)
+
+// fips203Params holds an ML-KEM parameter set (FIPS 203, Table 2).
+type fips203Params struct {
+	k          int // dimension of the module
+	eta1, eta2 int // noise coefficient ranges
+	du, dv     int // compression bits of the ciphertext parts u and v
+}
+
+var fips203ParamSets = map[string]*fips203Params{
+	MLKEM512:  {k: 2, eta1: 3, eta2: 2, du: 10, dv: 4},
+	MLKEM768:  {k: 3, eta1: 2, eta2: 2, du: 10, dv: 4},
+	MLKEM1024: {k: 4, eta1: 2, eta2: 2, du: 11, dv: 5},
+}
+
+// Encoded sizes of the keys and ciphertexts of the parameter set.
+func (p *fips203Params) publicKeySize() int  { return p.k*polyN*12/8 + seedBytes }
+func (p *fips203Params) secretKeySize() int  { return p.k*polyN*12/8 + p.publicKeySize() + 32 + 32 }
+func (p *fips203Params) ciphertextSize() int { return (p.k*p.du + p.dv) * polyN / 8 }
+
+// poly is a polynomial of Z_q[X]/(X^256+1), with coefficients in [0, q). It is
+// either in the normal or in the NTT domain.
+type poly [polyN]uint16
+
+var (
+	nttZetas  [128]uint16 // zeta^BitRev7(i) mod q
+	nttGammas [128]uint16 // zeta^(2*BitRev7(i)+1) mod q
+)
+
+func init() {
+	powers := make([]uint16, polyN)
+	powers[0] = 1
+	for i := 1; i < polyN; i++ {
+		powers[i] = fieldMul(powers[i-1], nttZeta)
+	}
+	for i := range nttZetas {
+		var rev int
+		for b := 0; b < 7; b++ {
+			rev |= (i >> b & 1) << (6 - b)
+		}
+		nttZetas[i] = powers[rev]
+		nttGammas[i] = powers[2*rev+1]
+	}
+}
+
+func fieldAdd(a, b uint16) uint16 {
+	r := a + b
+	if r >= fieldQ {
+		r -= fieldQ
+	}
+	return r
+}
+
+func fieldSub(a, b uint16) uint16 {
+	return fieldAdd(a, fieldQ-b)
+}
+
+func fieldMul(a, b uint16) uint16 {
+	return uint16(uint32(a) * uint32(b) % fieldQ)
+}
+
+func (f *poly) add(a, b *poly) {
+	for i := range f {
+		f[i] = fieldAdd(a[i], b[i])
+	}
+}
+
+func (f *poly) sub(a, b *poly) {
+	for i := range f {
+		f[i] = fieldSub(a[i], b[i])
+	}
+}
+
+// mulAccNTT adds the product of a and b, both in the NTT domain, to f
+// (MultiplyNTTs, FIPS 203, Algorithm 11).
+func (f *poly) mulAccNTT(a, b *poly) {
+	for i := 0; i < polyN/2; i++ {
+		a0, a1, b0, b1 := a[2*i], a[2*i+1], b[2*i], b[2*i+1]
+		c0 := fieldAdd(fieldMul(a0, b0), fieldMul(fieldMul(a1, b1), nttGammas[i]))
+		c1 := fieldAdd(fieldMul(a0, b1), fieldMul(a1, b0))
+		f[2*i] = fieldAdd(f[2*i], c0)
+		f[2*i+1] = fieldAdd(f[2*i+1], c1)
+	}
+}
+
+// ntt transforms f to the NTT domain in place (FIPS 203, Algorithm 9).
+func (f *poly) ntt() {
+	k := 1
+	for length := 128; length >= 2; length /= 2 {
+		for start := 0; start < polyN; start += 2 * length {
+			zeta := nttZetas[k]
+			k++
+			for j := start; j < start+length; j++ {
+				t := fieldMul(zeta, f[j+length])
+				f[j+length] = fieldSub(f[j], t)
+				f[j] = fieldAdd(f[j], t)
+			}
+		}
+	}
+}
+
+// invNTT transforms f back from the NTT domain in place (FIPS 203, Algorithm 10).
+func (f *poly) invNTT() {
+	k := 127
+	for length := 2; length <= 128; length *= 2 {
+		for start := 0; start < polyN; start += 2 * length {
+			zeta := nttZetas[k]
+			k--
+			for j := start; j < start+length; j++ {
+				t := f[j]
+				f[j] = fieldAdd(t, f[j+length])
+				f[j+length] = fieldMul(zeta, fieldSub(f[j+length], t))
+			}
+		}
+	}
+	for i := range f {
+		f[i] = fieldMul(f[i], nttInvDegree)
+	}
+}
+
+// compress maps the coefficients of f to [0, 2^bits) (Compress_d), rounding
+// 2^bits * x / q to the nearest integer.
+func (f *poly) compress(bits int) {
+	for i, x := range f {
+		f[i] = uint16((uint32(x)<<(bits+1)+fieldQ)/(2*fieldQ)) & (1<<bits - 1)
+	}
+}
+
+// decompress maps the coefficients of f from [0, 2^bits) back to [0, q)
+// (Decompress_d), rounding q * y / 2^bits to the nearest integer.
+func (f *poly) decompress(bits int) {
+	for i, y := range f {
+		f[i] = uint16((uint32(y)*fieldQ + 1<<(bits-1)) >> bits)
+	}
+}
+
+// packBits appends the coefficients of f, each encoded little-endian in bits
+// bits, to out (ByteEncode_d).
+func packBits(out []byte, f *poly, bits int) []byte {
+	var (
+		acc  uint32
+		have int
+	)
+	for _, c := range f {
+		acc |= uint32(c) << have
+		for have += bits; have >= 8; have -= 8 {
+			out = append(out, byte(acc))
+			acc >>= 8
+		}
+	}
+	return out
+}
+
+// unpackBits decodes the coefficients of f from data (ByteDecode_d), reducing
+// them modulo q for 12-bit encodings.
+func unpackBits(f *poly, data []byte, bits int) {
+	var (
+		acc  uint32
+		have int
+		mask = uint32(1)<<bits - 1
+	)
+	for i := range f {
+		for have < bits {
+			acc |= uint32(data[0]) << have
+			data = data[1:]
+			have += 8
+		}
+		f[i] = uint16(acc & mask)
+		if bits == 12 {
+			f[i] %= fieldQ
+		}
+		acc >>= bits
+		have -= bits
+	}
+}
+
+// sampleNTT samples a uniform polynomial in the NTT domain from the seed and
+// the matrix indices (SampleNTT, FIPS 203, Algorithm 7).
+func sampleNTT(f *poly, rho []byte, j, i byte) {
+	h := sha3.NewShake128()
+	h.Write(rho)
+	h.Write([]byte{j, i})
+
+	var buf [168]byte // the SHAKE128 rate
+	for n := 0; n < polyN; {
+		h.Read(buf[:])
+		for c := 0; c < len(buf) && n < polyN; c += 3 {
+			d1 := uint16(buf[c]) | uint16(buf[c+1]&0x0f)<<8
+			d2 := uint16(buf[c+1])>>4 | uint16(buf[c+2])<<4
+			if d1 < fieldQ {
+				f[n] = d1
+				n++
+			}
+			if d2 < fieldQ && n < polyN {
+				f[n] = d2
+				n++
+			}
+		}
+	}
+}
+
+// sampleCBD samples a noise polynomial with coefficients in [-eta, eta] from
+// PRF_eta(seed, nonce) (SamplePolyCBD, FIPS 203, Algorithm 8).
+func sampleCBD(f *poly, eta int, seed []byte, nonce byte) {
+	buf := shake256(64*eta, seed, []byte{nonce})
+	bit := func(i int) uint16 { return uint16(buf[i/8] >> (i % 8) & 1) }
+
+	for i := range f {
+		var x, y uint16
+		for j := 0; j < eta; j++ {
+			x += bit(2*i*eta + j)
+			y += bit(2*i*eta + eta + j)
+		}
+		f[i] = fieldSub(x, y)
+	}
+}
+
+// expandA samples the matrix A in the NTT domain from the seed rho. With
+// transpose set, it returns the transpose of A.
+func expandA(p *fips203Params, rho []byte, transpose bool) [][]poly {
+	A := make([][]poly, p.k)
+	for i := range A {
+		A[i] = make([]poly, p.k)
+		for j := range A[i] {
+			if transpose {
+				sampleNTT(&A[i][j], rho, byte(i), byte(j))
+			} else {
+				sampleNTT(&A[i][j], rho, byte(j), byte(i))
+			}
+		}
+	}
+	return A
+}
+
+// fips203KeyGen derives a key pair from the seeds d and z
+// (ML-KEM.KeyGen_internal).
+func fips203KeyGen(p *fips203Params, d, z []byte) (publicKey, secretKey []byte) {
+	expanded := sha3.Sum512(append(append([]byte{}, d...), byte(p.k)))
+	rho, sigma := expanded[:32], expanded[32:]
+
+	A := expandA(p, rho, false)
+	s := make([]poly, p.k)
+	e := make([]poly, p.k)
+	for i := range s {
+		sampleCBD(&s[i], p.eta1, sigma, byte(i))
+		s[i].ntt()
+	}
+	for i := range e {
+		sampleCBD(&e[i], p.eta1, sigma, byte(p.k+i))
+		e[i].ntt()
+	}
+	publicKey = make([]byte, 0, p.publicKeySize())
+	for i := 0; i < p.k; i++ {
+		t := e[i]
+		for j := 0; j < p.k; j++ {
+			t.mulAccNTT(&A[i][j], &s[j])
+		}
+		publicKey = packBits(publicKey, &t, 12)
+	}
+	publicKey = append(publicKey, rho...)
+	hash := sha3.Sum256(publicKey)
+
+	secretKey = make([]byte, 0, p.secretKeySize())
+	for i := range s {
+		secretKey = packBits(secretKey, &s[i], 12)
+	}
+	secretKey = append(secretKey, publicKey...)
+	secretKey = append(secretKey, hash[:]...)
+	secretKey = append(secretKey, z...)
+	return publicKey, secretKey
+}
+
+// fips203Encrypt encrypts the message m with the randomness r (K-PKE.Encrypt).
+// The public key must have been checked with ValidateEncapsulationKey.
+func fips203Encrypt(p *fips203Params, publicKey, m, r []byte) []byte {
+	t := make([]poly, p.k)
+	for i := range t {
+		unpackBits(&t[i], publicKey[i*polyN*12/8:], 12)
+	}
+	AT := expandA(p, publicKey[p.k*polyN*12/8:], true)
+
+	y := make([]poly, p.k)
+	for i := range y {
+		sampleCBD(&y[i], p.eta1, r, byte(i))
+		y[i].ntt()
+	}
+	ciphertext := make([]byte, 0, p.ciphertextSize())
+	for i := 0; i < p.k; i++ {
+		var u, e1 poly
+		for j := 0; j < p.k; j++ {
+			u.mulAccNTT(&AT[i][j], &y[j])
+		}
+		u.invNTT()
+		sampleCBD(&e1, p.eta2, r, byte(p.k+i))
+		u.add(&u, &e1)
+		u.compress(p.du)
+		ciphertext = packBits(ciphertext, &u, p.du)
+	}
+	var v, e2, mu poly
+	for j := 0; j < p.k; j++ {
+		v.mulAccNTT(&t[j], &y[j])
+	}
+	v.invNTT()
+	sampleCBD(&e2, p.eta2, r, byte(2*p.k))
+	unpackBits(&mu, m, 1)
+	mu.decompress(1)
+	v.add(&v, &e2)
+	v.add(&v, &mu)
+	v.compress(p.dv)
+	return packBits(ciphertext, &v, p.dv)
+}
+
+// fips203Decrypt decrypts a ciphertext with the secret key s (K-PKE.Decrypt).
+func fips203Decrypt(p *fips203Params, secretKey, ciphertext []byte) []byte {
+	var w poly
+	for i := 0; i < p.k; i++ {
+		var s, u poly
+		unpackBits(&s, secretKey[i*polyN*12/8:], 12)
+		unpackBits(&u, ciphertext[i*polyN*p.du/8:], p.du)
+		u.decompress(p.du)
+		u.ntt()
+		w.mulAccNTT(&s, &u)
+	}
+	w.invNTT()
+
+	var v poly
+	unpackBits(&v, ciphertext[p.k*polyN*p.du/8:], p.dv)
+	v.decompress(p.dv)
+	w.sub(&v, &w)
+	w.compress(1)
+	return packBits(make([]byte, 0, fips203MessageSize), &w, 1)
+}
+
+// fips203Encaps derives a shared secret and its ciphertext from the message m
+// (ML-KEM.Encaps_internal). The public key must have been checked with
+// ValidateEncapsulationKey.
+func fips203Encaps(p *fips203Params, publicKey, m []byte) (ciphertext, sharedSecret []byte) {
+	hash := sha3.Sum256(publicKey)
+	kr := sha3.Sum512(append(append([]byte{}, m...), hash[:]...))
+	return fips203Encrypt(p, publicKey, m, kr[32:]), kr[:32]
+}
+
+// fips203Decaps recovers the shared secret of a ciphertext
+// (ML-KEM.Decaps_internal). Invalid ciphertexts yield a pseudorandom secret
+// (implicit rejection). The secret key and ciphertext must have the sizes of
+// the parameter set.
+func fips203Decaps(p *fips203Params, secretKey, ciphertext []byte) []byte {
+	var (
+		pkeKey    = secretKey[:p.k*polyN*12/8]
+		publicKey = secretKey[len(pkeKey) : len(pkeKey)+p.publicKeySize()]
+		hash      = secretKey[len(pkeKey)+len(publicKey) : len(secretKey)-32]
+		z         = secretKey[len(secretKey)-32:]
+	)
+	m := fips203Decrypt(p, pkeKey, ciphertext)
+	kr := sha3.Sum512(append(m, hash...))
+	reencrypted := fips203Encrypt(p, publicKey, m, kr[32:])
+
+	sharedSecret := shake256(fips203SharedSize, z, ciphertext)
+	subtle.ConstantTimeCopy(subtle.ConstantTimeCompare(ciphertext, reencrypted), sharedSecret, kr[:32])
+	return sharedSecret
+}
+
+// shake256 returns size bytes of SHAKE256 output over the inputs.
+func shake256(size int, inputs ...[]byte) []byte {
+	h := sha3.NewShake256()
+	for _, input := range inputs {
+		h.Write(input)
+	}
+	out := make([]byte, size)
+	h.Read(out)
+	return out
+}
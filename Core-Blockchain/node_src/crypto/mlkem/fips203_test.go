@@ -0,0 +1,88 @@
+// Copyright 2024 The Splendor Authors
+// Known answer tests of the pure Go ML-KEM (FIPS 203) implementation
+
+package mlkem
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Tests 100 key pairs, encapsulations and implicit rejections against the
+// accumulated test vectors of the C2SP/CCTV project,
+// https://github.com/C2SP/CCTV/tree/main/ML-KEM. ML-KEM-1024 follows the same
+// procedure, with the result of Go's crypto/mlkem.
+func TestFIPS203Accumulated(t *testing.T) {
+	for algorithm, want := range map[string]string{
+		MLKEM768:  "1114b1b6699ed191734fa339376afa7e285c9e6acf6ff0177d346696ce564415",
+		MLKEM1024: "800018fec3e2723f73f1d657fe239b4d5d8782efaade297e8cd448e54cc2ac00",
+	} {
+		var (
+			p    = fips203ParamSets[algorithm]
+			in   = sha3.NewShake128()
+			out  = sha3.NewShake128()
+			seed = make([]byte, 2*fips203SeedSize)
+			m    = make([]byte, fips203MessageSize)
+			ct   = make([]byte, p.ciphertextSize())
+		)
+		for i := 0; i < 100; i++ {
+			in.Read(seed)
+			pk, sk := fips203KeyGen(p, seed[:fips203SeedSize], seed[fips203SeedSize:])
+			out.Write(pk)
+
+			in.Read(m)
+			ciphertext, shared := fips203Encaps(p, pk, m)
+			out.Write(ciphertext)
+			out.Write(shared)
+			if !bytes.Equal(fips203Decaps(p, sk, ciphertext), shared) {
+				t.Fatalf("%s: decapsulation %d mismatch", algorithm, i)
+			}
+			// Random ciphertexts are implicitly rejected
+			in.Read(ct)
+			out.Write(fips203Decaps(p, sk, ct))
+		}
+		sum := make([]byte, 32)
+		out.Read(sum)
+		if have := hex.EncodeToString(sum); have != want {
+			t.Errorf("%s: accumulated hash mismatch: have %s, want %s", algorithm, have, want)
+		}
+	}
+}
+
+// Tests the key and ciphertext sizes of every parameter set, and that tampered
+// ciphertexts yield unrelated secrets.
+func TestFIPS203RoundTrip(t *testing.T) {
+	for algorithm, p := range fips203ParamSets {
+		pk, sk, err := GenerateKeyPair(algorithm)
+		if err != nil {
+			t.Fatalf("%s: key generation failed: %v", algorithm, err)
+		}
+		params := MLKEMParams[algorithm]
+		if len(pk) != params.PublicKeySize || len(sk) != params.SecretKeySize {
+			t.Fatalf("%s: key size mismatch: have %d/%d", algorithm, len(pk), len(sk))
+		}
+		if err := ValidateEncapsulationKey(algorithm, pk); err != nil {
+			t.Fatalf("%s: generated key rejected: %v", algorithm, err)
+		}
+		ciphertext, shared, err := Encapsulate(algorithm, pk)
+		if err != nil {
+			t.Fatalf("%s: encapsulation failed: %v", algorithm, err)
+		}
+		if len(ciphertext) != params.CiphertextSize || len(shared) != params.SharedSecretSize {
+			t.Fatalf("%s: ciphertext size mismatch: have %d/%d", algorithm, len(ciphertext), len(shared))
+		}
+		if have, err := Decapsulate(algorithm, ciphertext, sk); err != nil || !bytes.Equal(have, shared) {
+			t.Fatalf("%s: decapsulation mismatch: %v", algorithm, err)
+		}
+		ciphertext[len(ciphertext)-1] ^= 0x01
+		if have := fips203Decaps(p, sk, ciphertext); bytes.Equal(have, shared) {
+			t.Errorf("%s: tampered ciphertext yields the shared secret", algorithm)
+		}
+		if _, err := Decapsulate(algorithm, ciphertext[1:], sk); err != ErrInvalidCiphertext {
+			t.Errorf("%s: short ciphertext: have %v, want %v", algorithm, err, ErrInvalidCiphertext)
+		}
+	}
+}
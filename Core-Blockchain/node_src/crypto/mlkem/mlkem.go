@@ -3,69 +3,56 @@
 
 // Copyright 2024 The Splendor Authors
 // This file implements ML-KEM (Kyber) key encapsulation for quantum resistance
-// Based on FIPS 203 specification - Fallback implementation
+// Based on FIPS 203 specification - Pure Go implementation without liboqs
 
 package mlkem
 
-import (
-	"errors"
-)
+import "crypto/rand"
 
-// ML-KEM algorithm variants
-const (
-	MLKEM512  = "ML-KEM-512"  // 128-bit security level
-	MLKEM768  = "ML-KEM-768"  // 192-bit security level (recommended)
-	MLKEM1024 = "ML-KEM-1024" // 256-bit security level
-)
-
-// ML-KEM parameter sets
-var MLKEMParams = map[string]struct {
-	PublicKeySize    int
-	SecretKeySize    int
-	CiphertextSize   int
-	SharedSecretSize int
-	SecurityLevel    int
-}{
-	MLKEM512:  {PublicKeySize: 800, SecretKeySize: 1632, CiphertextSize: 768, SharedSecretSize: 32, SecurityLevel: 1},
-	MLKEM768:  {PublicKeySize: 1184, SecretKeySize: 2400, CiphertextSize: 1088, SharedSecretSize: 32, SecurityLevel: 3},
-	MLKEM1024: {PublicKeySize: 1568, SecretKeySize: 3168, CiphertextSize: 1568, SharedSecretSize: 32, SecurityLevel: 5},
-}
-
-var (
-	ErrInvalidKEMAlgorithm = errors.New("invalid ML-KEM algorithm")
-	ErrInvalidCiphertext   = errors.New("invalid ciphertext")
-	ErrInvalidSecretKey    = errors.New("invalid secret key")
-	ErrInvalidLength       = errors.New("invalid parameter length")
-	ErrKEMNotAvailable     = errors.New("ML-KEM not available without liboqs")
-)
-
-// GenerateKeyPair generates an ML-KEM key pair (not available in fallback mode)
+// GenerateKeyPair generates an ML-KEM key pair
 func GenerateKeyPair(algorithm string) (publicKey, secretKey []byte, err error) {
-	return nil, nil, ErrKEMNotAvailable
+	p, exists := fips203ParamSets[algorithm]
+	if !exists {
+		return nil, nil, ErrInvalidKEMAlgorithm
+	}
+	seed := make([]byte, 2*fips203SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, nil, err
+	}
+	publicKey, secretKey = fips203KeyGen(p, seed[:fips203SeedSize], seed[fips203SeedSize:])
+	return publicKey, secretKey, nil
 }
 
-// Encapsulate performs key encapsulation (not available in fallback mode)
+// Encapsulate performs key encapsulation
 func Encapsulate(algorithm string, publicKey []byte) (ciphertext, sharedSecret []byte, err error) {
-	return nil, nil, ErrKEMNotAvailable
+	p, exists := fips203ParamSets[algorithm]
+	if !exists {
+		return nil, nil, ErrInvalidKEMAlgorithm
+	}
+	if err := ValidateEncapsulationKey(algorithm, publicKey); err != nil {
+		return nil, nil, err
+	}
+	m := make([]byte, fips203MessageSize)
+	if _, err := rand.Read(m); err != nil {
+		return nil, nil, err
+	}
+	ciphertext, sharedSecret = fips203Encaps(p, publicKey, m)
+	return ciphertext, sharedSecret, nil
 }
 
-// Decapsulate performs key decapsulation (not available in fallback mode)
+// Decapsulate performs key decapsulation
 func Decapsulate(algorithm string, ciphertext, secretKey []byte) (sharedSecret []byte, err error) {
-	return nil, ErrKEMNotAvailable
-}
-
-// ValidateMLKEMParams validates ML-KEM parameters
-func ValidateMLKEMParams(algorithm string, publicKey []byte) error {
-	params, exists := MLKEMParams[algorithm]
+	p, exists := fips203ParamSets[algorithm]
 	if !exists {
-		return ErrInvalidKEMAlgorithm
+		return nil, ErrInvalidKEMAlgorithm
 	}
-
-	if len(publicKey) != params.PublicKeySize {
-		return ErrInvalidLength
+	if err := ValidateCiphertext(algorithm, ciphertext); err != nil {
+		return nil, err
 	}
-
-	return nil
+	if len(secretKey) != MLKEMParams[algorithm].SecretKeySize {
+		return nil, ErrInvalidSecretKey
+	}
+	return fips203Decaps(p, secretKey, ciphertext), nil
 }
 
 // GetMLKEMSizes returns the sizes for ML-KEM parameters
@@ -78,7 +65,8 @@ func GetMLKEMSizes(algorithm string) (pkSize, skSize, ctSize, ssSize int, err er
 	return params.PublicKeySize, params.SecretKeySize, params.CiphertextSize, params.SharedSecretSize, nil
 }
 
-// IsMLKEMSupported checks if ML-KEM is supported (always false in fallback mode)
+// IsMLKEMSupported checks if ML-KEM is supported
 func IsMLKEMSupported(algorithm string) bool {
-	return false
+	_, exists := fips203ParamSets[algorithm]
+	return exists
 }
@@ -290,17 +290,3 @@ func HybridEncapsulate(algorithm string, mlkemPublicKey, ecdhPublicKey []byte) (
 
 	return ciphertext, sharedSecret, nil
 }
-
-// ValidateMLKEMParams validates ML-KEM parameters
-func ValidateMLKEMParams(algorithm string, publicKey []byte) error {
-	expectedPkSize, _, _, _, err := GetMLKEMSizes(algorithm)
-	if err != nil {
-		return err
-	}
-
-	if len(publicKey) != expectedPkSize {
-		return ErrInvalidLength
-	}
-
-	return nil
-}
@@ -0,0 +1,41 @@
+//go:build cgo && !no_liboqs
+// +build cgo,!no_liboqs
+
+// Copyright 2024 The Splendor Authors
+// Cross-checks of the liboqs backend against the pure Go implementation
+
+package mlkem
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Tests that keys and ciphertexts of liboqs and of the pure Go implementation
+// are interchangeable.
+func TestLibOQSCrossCheck(t *testing.T) {
+	for _, algorithm := range []string{MLKEM512, MLKEM768, MLKEM1024} {
+		if !IsMLKEMSupported(algorithm) {
+			t.Skipf("%s not supported by liboqs", algorithm)
+		}
+		p := fips203ParamSets[algorithm]
+
+		// liboqs keys decapsulate Go ciphertexts
+		pk, sk, err := GenerateKeyPair(algorithm)
+		if err != nil {
+			t.Fatalf("%s: liboqs key generation failed: %v", algorithm, err)
+		}
+		ciphertext, shared := fips203Encaps(p, pk, bytes.Repeat([]byte{0x01}, fips203MessageSize))
+		if have, err := Decapsulate(algorithm, ciphertext, sk); err != nil || !bytes.Equal(have, shared) {
+			t.Errorf("%s: Go ciphertext rejected by liboqs: %v", algorithm, err)
+		}
+		// Go keys decapsulate liboqs ciphertexts
+		pk, sk = fips203KeyGen(p, bytes.Repeat([]byte{0x02}, fips203SeedSize), bytes.Repeat([]byte{0x03}, fips203SeedSize))
+		if ciphertext, shared, err = Encapsulate(algorithm, pk); err != nil {
+			t.Fatalf("%s: liboqs encapsulation with Go key failed: %v", algorithm, err)
+		}
+		if have := fips203Decaps(p, sk, ciphertext); !bytes.Equal(have, shared) {
+			t.Errorf("%s: liboqs ciphertext rejected by the Go implementation", algorithm)
+		}
+	}
+}
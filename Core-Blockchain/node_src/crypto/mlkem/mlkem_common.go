@@ -0,0 +1,89 @@
+// Shared ML-KEM constants, parameters, and validation
+// These are used by both the liboqs (cgo) build and the fallback build. The
+// input checks of FIPS 203 are implemented in pure Go so that they give the
+// same results in both builds.
+
+package mlkem
+
+import "errors"
+
+// ML-KEM algorithm variants
+const (
+	MLKEM512  = "ML-KEM-512"  // 128-bit security level
+	MLKEM768  = "ML-KEM-768"  // 192-bit security level (recommended)
+	MLKEM1024 = "ML-KEM-1024" // 256-bit security level
+)
+
+// ML-KEM parameter sets
+var MLKEMParams = map[string]struct {
+	PublicKeySize    int
+	SecretKeySize    int
+	CiphertextSize   int
+	SharedSecretSize int
+	SecurityLevel    int
+}{
+	MLKEM512:  {PublicKeySize: 800, SecretKeySize: 1632, CiphertextSize: 768, SharedSecretSize: 32, SecurityLevel: 1},
+	MLKEM768:  {PublicKeySize: 1184, SecretKeySize: 2400, CiphertextSize: 1088, SharedSecretSize: 32, SecurityLevel: 3},
+	MLKEM1024: {PublicKeySize: 1568, SecretKeySize: 3168, CiphertextSize: 1568, SharedSecretSize: 32, SecurityLevel: 5},
+}
+
+var (
+	ErrInvalidKEMAlgorithm = errors.New("invalid ML-KEM algorithm")
+	ErrInvalidPublicKey    = errors.New("invalid encapsulation key")
+	ErrInvalidCiphertext   = errors.New("invalid ciphertext")
+	ErrInvalidSecretKey    = errors.New("invalid secret key")
+	ErrInvalidLength       = errors.New("invalid parameter length")
+	ErrKEMNotAvailable     = errors.New("ML-KEM not available without liboqs")
+)
+
+const (
+	fieldQ    = 3329 // the ML-KEM prime
+	seedBytes = 32   // size of the matrix seed rho
+)
+
+// ValidateMLKEMParams validates ML-KEM parameters
+func ValidateMLKEMParams(algorithm string, publicKey []byte) error {
+	params, exists := MLKEMParams[algorithm]
+	if !exists {
+		return ErrInvalidKEMAlgorithm
+	}
+
+	if len(publicKey) != params.PublicKeySize {
+		return ErrInvalidLength
+	}
+
+	return nil
+}
+
+// ValidateEncapsulationKey performs the encapsulation key checks of FIPS 203,
+// Section 7.2: the key must have the right length and all the coefficients of
+// its encoded vector t must be reduced modulo q.
+func ValidateEncapsulationKey(algorithm string, publicKey []byte) error {
+	if err := ValidateMLKEMParams(algorithm, publicKey); err != nil {
+		return err
+	}
+	encoded := publicKey[:len(publicKey)-seedBytes]
+	for i := 0; i < len(encoded); i += 3 {
+		// Every 3 bytes hold two little-endian 12-bit coefficients
+		c0 := uint16(encoded[i]) | uint16(encoded[i+1]&0x0f)<<8
+		c1 := uint16(encoded[i+1])>>4 | uint16(encoded[i+2])<<4
+		if c0 >= fieldQ || c1 >= fieldQ {
+			return ErrInvalidPublicKey
+		}
+	}
+	return nil
+}
+
+// ValidateCiphertext performs the ciphertext check of FIPS 203, Section 7.2,
+// which only concerns its length: every byte string of the right length
+// decodes to a ciphertext.
+func ValidateCiphertext(algorithm string, ciphertext []byte) error {
+	params, exists := MLKEMParams[algorithm]
+	if !exists {
+		return ErrInvalidKEMAlgorithm
+	}
+	if len(ciphertext) != params.CiphertextSize {
+		return ErrInvalidCiphertext
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2024 The Splendor Authors
+// Tests of the FIPS 203 input checks
+
+package mlkem
+
+import "testing"
+
+func TestValidateEncapsulationKey(t *testing.T) {
+	for algorithm, params := range MLKEMParams {
+		key := make([]byte, params.PublicKeySize)
+		if err := ValidateEncapsulationKey(algorithm, key); err != nil {
+			t.Errorf("%s: zero key rejected: %v", algorithm, err)
+		}
+		// The largest reduced coefficient, q-1 = 0xd00, in both halves
+		key[0], key[1], key[2] = 0x00, 0x0d, 0xd0
+		if err := ValidateEncapsulationKey(algorithm, key); err != nil {
+			t.Errorf("%s: reduced key rejected: %v", algorithm, err)
+		}
+		// The first coefficient of the last polynomial set to q
+		last := len(key) - seedBytes - 3
+		key[last], key[last+1] = 0x01, 0x0d
+		if err := ValidateEncapsulationKey(algorithm, key); err != ErrInvalidPublicKey {
+			t.Errorf("%s: unreduced key: have %v, want %v", algorithm, err, ErrInvalidPublicKey)
+		}
+		// The seed is not subject to the modulus check
+		key[last], key[last+1] = 0, 0
+		for i := len(key) - seedBytes; i < len(key); i++ {
+			key[i] = 0xff
+		}
+		if err := ValidateEncapsulationKey(algorithm, key); err != nil {
+			t.Errorf("%s: key with arbitrary seed rejected: %v", algorithm, err)
+		}
+		if err := ValidateEncapsulationKey(algorithm, key[1:]); err != ErrInvalidLength {
+			t.Errorf("%s: short key: have %v, want %v", algorithm, err, ErrInvalidLength)
+		}
+		if err := ValidateCiphertext(algorithm, make([]byte, params.CiphertextSize)); err != nil {
+			t.Errorf("%s: ciphertext rejected: %v", algorithm, err)
+		}
+		if err := ValidateCiphertext(algorithm, make([]byte, params.CiphertextSize+1)); err != ErrInvalidCiphertext {
+			t.Errorf("%s: long ciphertext: have %v, want %v", algorithm, err, ErrInvalidCiphertext)
+		}
+	}
+	if err := ValidateEncapsulationKey("ML-KEM-2048", nil); err != ErrInvalidKEMAlgorithm {
+		t.Errorf("unknown algorithm: have %v, want %v", err, ErrInvalidKEMAlgorithm)
+	}
+}
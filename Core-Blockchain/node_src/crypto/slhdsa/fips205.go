@@ -0,0 +1,497 @@
+// Copyright 2024 The Splendor Authors
+// This file implements SLH-DSA (FIPS 205) key generation, signing and
+// verification in pure Go, for the SHAKE parameter sets.
+
+package slhdsa
+
+import (
+	"bytes"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// fips205Params holds an SLH-DSA parameter set (FIPS 205, Table 2). The
+// Winternitz parameter is fixed to lg_w = 4.
+type fips205Params struct {
+	n  int // security parameter, the hash output size
+	h  int // total height of the hypertree
+	d  int // number of hypertree layers
+	hp int // height of the XMSS trees, h'
+	a  int // height of the FORS trees
+	k  int // number of FORS trees
+	m  int // message digest size
+}
+
+var fips205ParamSets = map[string]*fips205Params{
+	SLHDSA128S: {n: 16, h: 63, d: 7, hp: 9, a: 12, k: 14, m: 30},
+	SLHDSA128F: {n: 16, h: 66, d: 22, hp: 3, a: 6, k: 33, m: 34},
+	SLHDSA192S: {n: 24, h: 63, d: 7, hp: 9, a: 14, k: 17, m: 39},
+	SLHDSA192F: {n: 24, h: 66, d: 22, hp: 3, a: 8, k: 33, m: 42},
+	SLHDSA256S: {n: 32, h: 64, d: 8, hp: 8, a: 14, k: 22, m: 47},
+	SLHDSA256F: {n: 32, h: 68, d: 17, hp: 4, a: 9, k: 35, m: 49},
+}
+
+const (
+	wotsW      = 16 // Winternitz parameter, 2^lg_w
+	wotsLogW   = 4
+	wotsLen2   = 3 // checksum chains, for lg_w = 4 and all n
+	wotsCsumSh = 4 // checksum left shift, (8 - len2*lg_w mod 8) mod 8
+)
+
+// wotsLen returns the number of WOTS+ chains.
+func (p *fips205Params) wotsLen() int { return 2*p.n + wotsLen2 }
+
+// Encoded sizes of the keys and signatures of the parameter set.
+func (p *fips205Params) publicKeySize() int { return 2 * p.n }
+func (p *fips205Params) secretKeySize() int { return 4 * p.n }
+func (p *fips205Params) signatureSize() int {
+	return (1 + p.k*(1+p.a) + p.h + p.d*p.wotsLen()) * p.n
+}
+
+// Address types
+const (
+	addrWOTSHash  = 0
+	addrWOTSPK    = 1
+	addrTree      = 2
+	addrFORSTree  = 3
+	addrFORSRoots = 4
+	addrWOTSPRF   = 5
+	addrFORSPRF   = 6
+)
+
+// address is the 32 byte hash function address ADRS (FIPS 205, Section 4.2).
+type address [32]byte
+
+func putUint32(b []byte, v uint32) {
+	b[0], b[1], b[2], b[3] = byte(v>>24), byte(v>>16), byte(v>>8), byte(v)
+}
+
+func getUint32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func (a *address) setLayer(layer uint32) { putUint32(a[0:4], layer) }
+func (a *address) setTree(tree uint64) {
+	putUint32(a[4:8], 0)
+	putUint32(a[8:12], uint32(tree>>32))
+	putUint32(a[12:16], uint32(tree))
+}
+func (a *address) setTypeAndClear(typ uint32) {
+	putUint32(a[16:20], typ)
+	for i := 20; i < len(a); i++ {
+		a[i] = 0
+	}
+}
+func (a *address) setKeyPair(i uint32)    { putUint32(a[20:24], i) }
+func (a *address) keyPair() uint32        { return getUint32(a[20:24]) }
+func (a *address) setChain(i uint32)      { putUint32(a[24:28], i) }
+func (a *address) setTreeHeight(z uint32) { putUint32(a[24:28], z) }
+func (a *address) setHash(i uint32)       { putUint32(a[28:32], i) }
+func (a *address) setTreeIndex(i uint32)  { putUint32(a[28:32], i) }
+func (a *address) treeIndex() uint32      { return getUint32(a[28:32]) }
+
+// fips205Context holds the seeds a signature is created or verified with, and
+// implements the SHAKE instantiations of the FIPS 205 hash functions.
+type fips205Context struct {
+	p      *fips205Params
+	pkSeed []byte
+	skSeed []byte // only set for key generation and signing
+	hasher sha3.ShakeHash
+}
+
+func newFIPS205Context(p *fips205Params, pkSeed, skSeed []byte) *fips205Context {
+	return &fips205Context{p: p, pkSeed: pkSeed, skSeed: skSeed, hasher: sha3.NewShake256()}
+}
+
+// thash implements F, H and T_l: SHAKE256(PK.seed || ADRS || M, 8n).
+func (c *fips205Context) thash(adrs *address, inputs ...[]byte) []byte {
+	c.hasher.Reset()
+	c.hasher.Write(c.pkSeed)
+	c.hasher.Write(adrs[:])
+	for _, input := range inputs {
+		c.hasher.Write(input)
+	}
+	out := make([]byte, c.p.n)
+	c.hasher.Read(out)
+	return out
+}
+
+// prf implements PRF: SHAKE256(PK.seed || ADRS || SK.seed, 8n).
+func (c *fips205Context) prf(adrs *address) []byte {
+	return c.thash(adrs, c.skSeed)
+}
+
+// base2b splits x into outLen big-endian b-bit integers (FIPS 205, Algorithm 4).
+func base2b(x []byte, b, outLen int) []uint32 {
+	var (
+		out   = make([]uint32, outLen)
+		in    int
+		bits  int
+		total uint64
+	)
+	for i := range out {
+		for bits < b {
+			total = total<<8 | uint64(x[in])
+			in++
+			bits += 8
+		}
+		bits -= b
+		out[i] = uint32(total>>bits) & (1<<b - 1)
+	}
+	return out
+}
+
+// toInt decodes a big-endian integer of at most 8 bytes.
+func toInt(x []byte) uint64 {
+	var v uint64
+	for _, b := range x {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+// chain computes s iterations of F on x, starting at index i (Algorithm 5).
+func (c *fips205Context) chain(x []byte, i, s uint32, adrs *address) []byte {
+	tmp := x
+	for j := i; j < i+s; j++ {
+		adrs.setHash(j)
+		tmp = c.thash(adrs, tmp)
+	}
+	return tmp
+}
+
+// wotsDigits returns the base-w digits of the message and of its checksum.
+func (c *fips205Context) wotsDigits(msg []byte) []uint32 {
+	digits := base2b(msg, wotsLogW, 2*c.p.n)
+	var csum uint32
+	for _, digit := range digits {
+		csum += wotsW - 1 - digit
+	}
+	csum <<= wotsCsumSh
+	return append(digits, base2b([]byte{byte(csum >> 8), byte(csum)}, wotsLogW, wotsLen2)...)
+}
+
+// wotsSecret derives the secret key of WOTS+ chain i.
+func (c *fips205Context) wotsSecret(adrs *address, i uint32) []byte {
+	skAdrs := *adrs
+	skAdrs.setTypeAndClear(addrWOTSPRF)
+	skAdrs.setKeyPair(adrs.keyPair())
+	skAdrs.setChain(i)
+	return c.prf(&skAdrs)
+}
+
+// wotsCompress compresses the chain ends into the WOTS+ public key.
+func (c *fips205Context) wotsCompress(adrs *address, ends [][]byte) []byte {
+	pkAdrs := *adrs
+	pkAdrs.setTypeAndClear(addrWOTSPK)
+	pkAdrs.setKeyPair(adrs.keyPair())
+	return c.thash(&pkAdrs, ends...)
+}
+
+// wotsPkGen generates a WOTS+ public key (Algorithm 6).
+func (c *fips205Context) wotsPkGen(adrs *address) []byte {
+	ends := make([][]byte, c.p.wotsLen())
+	for i := range ends {
+		sk := c.wotsSecret(adrs, uint32(i))
+		adrs.setChain(uint32(i))
+		ends[i] = c.chain(sk, 0, wotsW-1, adrs)
+	}
+	return c.wotsCompress(adrs, ends)
+}
+
+// wotsSign generates a WOTS+ signature on an n-byte message (Algorithm 7).
+func (c *fips205Context) wotsSign(msg []byte, adrs *address) []byte {
+	sig := make([]byte, 0, c.p.wotsLen()*c.p.n)
+	for i, digit := range c.wotsDigits(msg) {
+		sk := c.wotsSecret(adrs, uint32(i))
+		adrs.setChain(uint32(i))
+		sig = append(sig, c.chain(sk, 0, digit, adrs)...)
+	}
+	return sig
+}
+
+// wotsPkFromSig computes a WOTS+ public key from a signature (Algorithm 8).
+func (c *fips205Context) wotsPkFromSig(sig, msg []byte, adrs *address) []byte {
+	ends := make([][]byte, c.p.wotsLen())
+	for i, digit := range c.wotsDigits(msg) {
+		adrs.setChain(uint32(i))
+		ends[i] = c.chain(sig[i*c.p.n:(i+1)*c.p.n], digit, wotsW-1-digit, adrs)
+	}
+	return c.wotsCompress(adrs, ends)
+}
+
+// xmssNode computes the root of the XMSS subtree of height z at index i
+// (Algorithm 9).
+func (c *fips205Context) xmssNode(i, z uint32, adrs *address) []byte {
+	if z == 0 {
+		adrs.setTypeAndClear(addrWOTSHash)
+		adrs.setKeyPair(i)
+		return c.wotsPkGen(adrs)
+	}
+	left := c.xmssNode(2*i, z-1, adrs)
+	right := c.xmssNode(2*i+1, z-1, adrs)
+	adrs.setTypeAndClear(addrTree)
+	adrs.setTreeHeight(z)
+	adrs.setTreeIndex(i)
+	return c.thash(adrs, left, right)
+}
+
+// xmssSign generates an XMSS signature: a WOTS+ signature followed by the
+// authentication path (Algorithm 10).
+func (c *fips205Context) xmssSign(msg []byte, idx uint32, adrs *address) []byte {
+	auth := make([]byte, 0, c.p.hp*c.p.n)
+	for j := 0; j < c.p.hp; j++ {
+		k := idx>>j ^ 1
+		auth = append(auth, c.xmssNode(k, uint32(j), adrs)...)
+	}
+	adrs.setTypeAndClear(addrWOTSHash)
+	adrs.setKeyPair(idx)
+	return append(c.wotsSign(msg, adrs), auth...)
+}
+
+// xmssPkFromSig computes an XMSS root from a signature (Algorithm 11).
+func (c *fips205Context) xmssPkFromSig(idx uint32, sig, msg []byte, adrs *address) []byte {
+	adrs.setTypeAndClear(addrWOTSHash)
+	adrs.setKeyPair(idx)
+	wotsSize := c.p.wotsLen() * c.p.n
+	node := c.wotsPkFromSig(sig[:wotsSize], msg, adrs)
+	auth := sig[wotsSize:]
+
+	adrs.setTypeAndClear(addrTree)
+	adrs.setTreeIndex(idx)
+	for k := 0; k < c.p.hp; k++ {
+		adrs.setTreeHeight(uint32(k + 1))
+		sibling := auth[k*c.p.n : (k+1)*c.p.n]
+		if idx>>k&1 == 0 {
+			adrs.setTreeIndex(adrs.treeIndex() / 2)
+			node = c.thash(adrs, node, sibling)
+		} else {
+			adrs.setTreeIndex((adrs.treeIndex() - 1) / 2)
+			node = c.thash(adrs, sibling, node)
+		}
+	}
+	return node
+}
+
+// xmssSigSize returns the size of an XMSS signature.
+func (c *fips205Context) xmssSigSize() int {
+	return (c.p.hp + c.p.wotsLen()) * c.p.n
+}
+
+// htSign generates a hypertree signature (Algorithm 12).
+func (c *fips205Context) htSign(msg []byte, idxTree uint64, idxLeaf uint32) []byte {
+	var adrs address
+	adrs.setTree(idxTree)
+	sigTmp := c.xmssSign(msg, idxLeaf, &adrs)
+	sig := append(make([]byte, 0, c.p.d*c.xmssSigSize()), sigTmp...)
+	root := c.xmssPkFromSig(idxLeaf, sigTmp, msg, &adrs)
+	for j := 1; j < c.p.d; j++ {
+		idxLeaf = uint32(idxTree & (1<<c.p.hp - 1))
+		idxTree >>= c.p.hp
+		adrs.setLayer(uint32(j))
+		adrs.setTree(idxTree)
+		sigTmp = c.xmssSign(root, idxLeaf, &adrs)
+		sig = append(sig, sigTmp...)
+		if j < c.p.d-1 {
+			root = c.xmssPkFromSig(idxLeaf, sigTmp, root, &adrs)
+		}
+	}
+	return sig
+}
+
+// htVerify verifies a hypertree signature (Algorithm 13).
+func (c *fips205Context) htVerify(msg, sig []byte, idxTree uint64, idxLeaf uint32, root []byte) bool {
+	var adrs address
+	adrs.setTree(idxTree)
+	size := c.xmssSigSize()
+	node := c.xmssPkFromSig(idxLeaf, sig[:size], msg, &adrs)
+	for j := 1; j < c.p.d; j++ {
+		idxLeaf = uint32(idxTree & (1<<c.p.hp - 1))
+		idxTree >>= c.p.hp
+		adrs.setLayer(uint32(j))
+		adrs.setTree(idxTree)
+		node = c.xmssPkFromSig(idxLeaf, sig[j*size:(j+1)*size], node, &adrs)
+	}
+	return bytes.Equal(node, root)
+}
+
+// forsSkGen derives a FORS secret value (Algorithm 14).
+func (c *fips205Context) forsSkGen(adrs *address, idx uint32) []byte {
+	skAdrs := *adrs
+	skAdrs.setTypeAndClear(addrFORSPRF)
+	skAdrs.setKeyPair(adrs.keyPair())
+	skAdrs.setTreeIndex(idx)
+	return c.prf(&skAdrs)
+}
+
+// forsNode computes the root of the FORS subtree of height z at index i
+// (Algorithm 15).
+func (c *fips205Context) forsNode(i, z uint32, adrs *address) []byte {
+	if z == 0 {
+		sk := c.forsSkGen(adrs, i)
+		adrs.setTreeHeight(0)
+		adrs.setTreeIndex(i)
+		return c.thash(adrs, sk)
+	}
+	left := c.forsNode(2*i, z-1, adrs)
+	right := c.forsNode(2*i+1, z-1, adrs)
+	adrs.setTreeHeight(z)
+	adrs.setTreeIndex(i)
+	return c.thash(adrs, left, right)
+}
+
+// forsSign generates a FORS signature (Algorithm 16).
+func (c *fips205Context) forsSign(md []byte, adrs *address) []byte {
+	sig := make([]byte, 0, c.p.k*(c.p.a+1)*c.p.n)
+	for i, index := range base2b(md, c.p.a, c.p.k) {
+		offset := uint32(i) << c.p.a
+		sig = append(sig, c.forsSkGen(adrs, offset+index)...)
+		for j := 0; j < c.p.a; j++ {
+			s := index>>j ^ 1
+			sig = append(sig, c.forsNode(uint32(i)<<(c.p.a-j)+s, uint32(j), adrs)...)
+		}
+	}
+	return sig
+}
+
+// forsPkFromSig computes a FORS public key from a signature (Algorithm 17).
+func (c *fips205Context) forsPkFromSig(sig, md []byte, adrs *address) []byte {
+	var (
+		n     = c.p.n
+		roots = make([][]byte, c.p.k)
+	)
+	for i, index := range base2b(md, c.p.a, c.p.k) {
+		part := sig[i*(c.p.a+1)*n : (i+1)*(c.p.a+1)*n]
+		adrs.setTreeHeight(0)
+		adrs.setTreeIndex(uint32(i)<<c.p.a + index)
+		node := c.thash(adrs, part[:n])
+		for j := 0; j < c.p.a; j++ {
+			adrs.setTreeHeight(uint32(j + 1))
+			sibling := part[(j+1)*n : (j+2)*n]
+			if index>>j&1 == 0 {
+				adrs.setTreeIndex(adrs.treeIndex() / 2)
+				node = c.thash(adrs, node, sibling)
+			} else {
+				adrs.setTreeIndex((adrs.treeIndex() - 1) / 2)
+				node = c.thash(adrs, sibling, node)
+			}
+		}
+		roots[i] = node
+	}
+	pkAdrs := *adrs
+	pkAdrs.setTypeAndClear(addrFORSRoots)
+	pkAdrs.setKeyPair(adrs.keyPair())
+	return c.thash(&pkAdrs, roots...)
+}
+
+// digestIndices splits the message digest into the FORS message and the
+// hypertree indices.
+func (p *fips205Params) digestIndices(digest []byte) (md []byte, idxTree uint64, idxLeaf uint32) {
+	var (
+		mdLen   = (p.k*p.a + 7) / 8
+		treeLen = (p.h - p.h/p.d + 7) / 8
+		leafLen = (p.h/p.d + 7) / 8
+	)
+	md = digest[:mdLen]
+	idxTree = toInt(digest[mdLen : mdLen+treeLen])
+	if bits := p.h - p.h/p.d; bits < 64 {
+		idxTree &= 1<<bits - 1
+	}
+	idxLeaf = uint32(toInt(digest[mdLen+treeLen:mdLen+treeLen+leafLen])) & (1<<(p.h/p.d) - 1)
+	return md, idxTree, idxLeaf
+}
+
+// hashMessage implements H_msg: SHAKE256(R || PK.seed || PK.root || M, 8m).
+func (p *fips205Params) hashMessage(r, pkSeed, pkRoot, msg []byte) []byte {
+	h := sha3.NewShake256()
+	h.Write(r)
+	h.Write(pkSeed)
+	h.Write(pkRoot)
+	h.Write(msg)
+	digest := make([]byte, p.m)
+	h.Read(digest)
+	return digest
+}
+
+// fips205KeyGen derives a key pair from the seeds (slh_keygen_internal).
+func fips205KeyGen(p *fips205Params, skSeed, skPrf, pkSeed []byte) (publicKey, secretKey []byte) {
+	var adrs address
+	adrs.setLayer(uint32(p.d - 1))
+	root := newFIPS205Context(p, pkSeed, skSeed).xmssNode(0, uint32(p.hp), &adrs)
+
+	publicKey = append(append(make([]byte, 0, 2*p.n), pkSeed...), root...)
+	secretKey = make([]byte, 0, 4*p.n)
+	secretKey = append(secretKey, skSeed...)
+	secretKey = append(secretKey, skPrf...)
+	return publicKey, append(secretKey, publicKey...)
+}
+
+// fips205Sign signs the formatted message M' with the randomness addrnd, or
+// deterministically if addrnd is nil (slh_sign_internal). The secret key must
+// have the size of the parameter set.
+func fips205Sign(p *fips205Params, secretKey, message, addrnd []byte) []byte {
+	var (
+		n      = p.n
+		skSeed = secretKey[:n]
+		skPrf  = secretKey[n : 2*n]
+		pkSeed = secretKey[2*n : 3*n]
+		pkRoot = secretKey[3*n:]
+	)
+	if addrnd == nil {
+		addrnd = pkSeed
+	}
+	h := sha3.NewShake256()
+	h.Write(skPrf)
+	h.Write(addrnd)
+	h.Write(message)
+	r := make([]byte, n)
+	h.Read(r)
+
+	md, idxTree, idxLeaf := p.digestIndices(p.hashMessage(r, pkSeed, pkRoot, message))
+
+	c := newFIPS205Context(p, pkSeed, skSeed)
+	var adrs address
+	adrs.setTree(idxTree)
+	adrs.setTypeAndClear(addrFORSTree)
+	adrs.setKeyPair(idxLeaf)
+	sigFors := c.forsSign(md, &adrs)
+	pkFors := c.forsPkFromSig(sigFors, md, &adrs)
+
+	sig := make([]byte, 0, p.signatureSize())
+	sig = append(sig, r...)
+	sig = append(sig, sigFors...)
+	return append(sig, c.htSign(pkFors, idxTree, idxLeaf)...)
+}
+
+// fips205Verify verifies a signature over the formatted message M'
+// (slh_verify_internal). The public key must have the size of the parameter
+// set.
+func fips205Verify(p *fips205Params, publicKey, message, signature []byte) bool {
+	if len(signature) != p.signatureSize() {
+		return false
+	}
+	var (
+		n       = p.n
+		pkSeed  = publicKey[:n]
+		pkRoot  = publicKey[n:]
+		r       = signature[:n]
+		forsEnd = (1 + p.k*(1+p.a)) * n
+	)
+	md, idxTree, idxLeaf := p.digestIndices(p.hashMessage(r, pkSeed, pkRoot, message))
+
+	c := newFIPS205Context(p, pkSeed, nil)
+	var adrs address
+	adrs.setTree(idxTree)
+	adrs.setTypeAndClear(addrFORSTree)
+	adrs.setKeyPair(idxLeaf)
+	pkFors := c.forsPkFromSig(signature[n:forsEnd], md, &adrs)
+	return c.htVerify(pkFors, signature[forsEnd:], idxTree, idxLeaf, pkRoot)
+}
+
+// fips205Message formats a message with an empty context string, as done by
+// the pure SLH-DSA sign and verify functions.
+func fips205Message(message []byte) []byte {
+	formatted := make([]byte, 0, 2+len(message))
+	formatted = append(formatted, 0, 0)
+	return append(formatted, message...)
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 The Splendor Authors
+// Tests of the FIPS 205 SLH-DSA implementation
+
+package slhdsa
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+)
+
+func fromHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Tests that the parameter sets match the published sizes.
+func TestParameterSizes(t *testing.T) {
+	for algorithm, p := range fips205ParamSets {
+		sizes := SLHDSAParams[algorithm]
+		if p.publicKeySize() != sizes.PublicKeySize || p.secretKeySize() != sizes.SecretKeySize || p.signatureSize() != sizes.SignatureSize {
+			t.Errorf("%s: sizes mismatch: pk %d, sk %d, sig %d", algorithm, p.publicKeySize(), p.secretKeySize(), p.signatureSize())
+		}
+	}
+}
+
+// Tests key generation and deterministic signing against known answers. The
+// public keys were cross-checked against the SPHINCS+ reference implementation,
+// whose signatures only differ from FIPS 205 in the FORS index bit order.
+func TestKnownAnswers(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		publicKey string
+		sigHash   string // SHAKE256 of the signature
+	}{
+		{
+			algorithm: SLHDSA128F,
+			publicKey: "202122232425262728292a2b2c2d2e2fa90e4715b9a925c332801767fd786371",
+			sigHash:   "df409301d634bdc3b583a62d9d0a43b981a02215f36acb772f6e78604f8c4a83",
+		},
+	}
+	for _, tt := range tests {
+		p := fips205ParamSets[tt.algorithm]
+		seed := make([]byte, 4*p.n)
+		for i := range seed {
+			seed[i] = byte(i)
+		}
+		pk, sk := fips205KeyGen(p, seed[:p.n], seed[p.n:2*p.n], seed[2*p.n:3*p.n])
+		if !bytes.Equal(pk, fromHex(tt.publicKey)) {
+			t.Errorf("%s: public key mismatch: have %x, want %s", tt.algorithm, pk, tt.publicKey)
+		}
+		sig := fips205Sign(p, sk, fips205Message([]byte("hello")), seed[3*p.n:])
+		hash := make([]byte, 32)
+		sha3.ShakeSum256(hash, sig)
+		if !bytes.Equal(hash, fromHex(tt.sigHash)) {
+			t.Errorf("%s: signature hash mismatch: have %x, want %s", tt.algorithm, hash, tt.sigHash)
+		}
+		if err := VerifySignature(tt.algorithm, []byte("hello"), sig, pk); err != nil {
+			t.Errorf("%s: known answer signature rejected: %v", tt.algorithm, err)
+		}
+	}
+}
+
+// Tests that signatures of all parameter sets verify, and that tampered
+// messages, signatures and keys are rejected.
+func TestSignVerify(t *testing.T) {
+	algorithms := []string{SLHDSA128F, SLHDSA192F, SLHDSA256F}
+	if !testing.Short() {
+		algorithms = append(algorithms, SLHDSA128S, SLHDSA192S, SLHDSA256S)
+	}
+	message := []byte("custody withdrawal")
+	for _, algorithm := range algorithms {
+		pk, sk, err := GenerateKeyPair(algorithm)
+		if err != nil {
+			t.Fatalf("%s: key generation failed: %v", algorithm, err)
+		}
+		sig, err := SignMessage(algorithm, message, sk)
+		if err != nil {
+			t.Fatalf("%s: signing failed: %v", algorithm, err)
+		}
+		if err := VerifySignature(algorithm, message, sig, pk); err != nil {
+			t.Fatalf("%s: valid signature rejected: %v", algorithm, err)
+		}
+		if err := VerifySignature(algorithm, []byte("custody withdrawal!"), sig, pk); err != ErrVerificationFailed {
+			t.Errorf("%s: tampered message: have %v, want %v", algorithm, err, ErrVerificationFailed)
+		}
+		for _, pos := range []int{0, len(sig) / 2, len(sig) - 1} {
+			tampered := append([]byte{}, sig...)
+			tampered[pos] ^= 0x01
+			if err := VerifySignature(algorithm, message, tampered, pk); err != ErrVerificationFailed {
+				t.Errorf("%s: signature tampered at %d: have %v, want %v", algorithm, pos, err, ErrVerificationFailed)
+			}
+		}
+		tampered := append([]byte{}, pk...)
+		tampered[len(pk)-1] ^= 0x01
+		if err := VerifySignature(algorithm, message, sig, tampered); err != ErrVerificationFailed {
+			t.Errorf("%s: tampered public key: have %v, want %v", algorithm, err, ErrVerificationFailed)
+		}
+		if err := VerifySignature(algorithm, message, sig[1:], pk); err == nil {
+			t.Errorf("%s: truncated signature accepted", algorithm)
+		}
+	}
+}
+
+// Tests the big-endian base 2^b decoding of FIPS 205.
+func TestBase2b(t *testing.T) {
+	have := base2b([]byte{0xab, 0xcd, 0xef}, 6, 4)
+	want := []uint32{0x2a, 0x3c, 0x37, 0x2f}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Fatalf("base2b mismatch: have %x, want %x", have, want)
+		}
+	}
+}
@@ -1,17 +1,16 @@
-//go:build !cgo || no_liboqs
-// +build !cgo no_liboqs
-
 // Copyright 2024 The Splendor Authors
 // This file implements SLH-DSA (SPHINCS+) signature verification for quantum resistance
-// Based on FIPS 205 specification - Fallback implementation
+// Based on FIPS 205 specification - Pure Go implementation of the SHAKE parameter sets
 
 package slhdsa
 
 import (
+	"crypto/rand"
 	"errors"
 )
 
-// SLH-DSA algorithm variants
+// SLH-DSA algorithm variants. They are instantiated with SHAKE256, that is
+// SLH-DSA-128s denotes the FIPS 205 parameter set SLH-DSA-SHAKE-128s.
 const (
 	SLHDSA128S = "SLH-DSA-128s" // 128-bit security, small signatures
 	SLHDSA128F = "SLH-DSA-128f" // 128-bit security, fast verification
@@ -21,7 +20,7 @@ const (
 	SLHDSA256F = "SLH-DSA-256f" // 256-bit security, fast verification
 )
 
-// SLH-DSA parameter sets (approximate sizes in bytes)
+// SLH-DSA parameter sets (FIPS 205 sizes in bytes)
 var SLHDSAParams = map[string]struct {
 	PublicKeySize  int
 	SecretKeySize  int
@@ -39,10 +38,12 @@ var SLHDSAParams = map[string]struct {
 
 var (
 	ErrInvalidSLHDSAAlgorithm = errors.New("invalid SLH-DSA algorithm")
-	ErrSLHDSANotAvailable     = errors.New("SLH-DSA not available without liboqs")
+	ErrInvalidSecretKey       = errors.New("invalid secret key")
+	ErrVerificationFailed     = errors.New("signature verification failed")
 )
 
-// VerifySignature verifies an SLH-DSA signature (fallback implementation)
+// VerifySignature verifies an SLH-DSA signature created with an empty context
+// string
 func VerifySignature(algorithm string, message, signature, publicKey []byte) error {
 	if len(message) == 0 {
 		return errors.New("empty message")
@@ -55,12 +56,19 @@ func VerifySignature(algorithm string, message, signature, publicKey []byte) err
 	}
 
 	// Validate algorithm
-	if _, exists := SLHDSAParams[algorithm]; !exists {
+	p, exists := fips205ParamSets[algorithm]
+	if !exists {
 		return ErrInvalidSLHDSAAlgorithm
 	}
 
-	// In fallback mode, we cannot actually verify the signature
-	return ErrSLHDSANotAvailable
+	// Validate lengths against expected parameters
+	if err := ValidateSLHDSAParams(algorithm, signature, publicKey); err != nil {
+		return err
+	}
+	if !fips205Verify(p, publicKey, fips205Message(message), signature) {
+		return ErrVerificationFailed
+	}
+	return nil
 }
 
 // GetSLHDSALengths returns the expected signature and public key lengths
@@ -73,19 +81,44 @@ func GetSLHDSALengths(algorithm string) (sigLen, pkLen, skLen int, err error) {
 	return params.SignatureSize, params.PublicKeySize, params.SecretKeySize, nil
 }
 
-// GenerateKeyPair generates an SLH-DSA key pair (not available in fallback mode)
+// GenerateKeyPair generates an SLH-DSA key pair
 func GenerateKeyPair(algorithm string) (publicKey, secretKey []byte, err error) {
-	return nil, nil, ErrSLHDSANotAvailable
+	p, exists := fips205ParamSets[algorithm]
+	if !exists {
+		return nil, nil, ErrInvalidSLHDSAAlgorithm
+	}
+	seed := make([]byte, 3*p.n)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, nil, err
+	}
+	publicKey, secretKey = fips205KeyGen(p, seed[:p.n], seed[p.n:2*p.n], seed[2*p.n:])
+	return publicKey, secretKey, nil
 }
 
-// SignMessage signs a message with SLH-DSA (not available in fallback mode)
+// SignMessage signs a message with SLH-DSA, using hedged (randomized) signing
+// with an empty context string
 func SignMessage(algorithm string, message, secretKey []byte) (signature []byte, err error) {
-	return nil, ErrSLHDSANotAvailable
+	if len(message) == 0 {
+		return nil, errors.New("empty message")
+	}
+	p, exists := fips205ParamSets[algorithm]
+	if !exists {
+		return nil, ErrInvalidSLHDSAAlgorithm
+	}
+	if len(secretKey) != p.secretKeySize() {
+		return nil, ErrInvalidSecretKey
+	}
+	addrnd := make([]byte, p.n)
+	if _, err := rand.Read(addrnd); err != nil {
+		return nil, err
+	}
+	return fips205Sign(p, secretKey, fips205Message(message), addrnd), nil
 }
 
-// IsSLHDSASupported checks if SLH-DSA is supported (always false in fallback mode)
+// IsSLHDSASupported checks if SLH-DSA is supported (all SHAKE parameter sets are)
 func IsSLHDSASupported(algorithm string) bool {
-	return false
+	_, exists := fips205ParamSets[algorithm]
+	return exists
 }
 
 // ValidateSLHDSAParams validates SLH-DSA parameters
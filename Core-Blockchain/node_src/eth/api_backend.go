@@ -74,6 +74,13 @@ func (b *EthAPIBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumb
 	if number == rpc.LatestBlockNumber {
 		return b.eth.blockchain.CurrentBlock().Header(), nil
 	}
+	if number == rpc.FinalizedBlockNumber || number == rpc.SafeBlockNumber {
+		block, err := b.finalityBlock(number)
+		if err != nil {
+			return nil, err
+		}
+		return block.Header(), nil
+	}
 	return b.eth.blockchain.GetHeaderByNumber(uint64(number)), nil
 }
 
@@ -108,9 +115,26 @@ func (b *EthAPIBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumbe
 	if number == rpc.LatestBlockNumber {
 		return b.eth.blockchain.CurrentBlock(), nil
 	}
+	if number == rpc.FinalizedBlockNumber || number == rpc.SafeBlockNumber {
+		return b.finalityBlock(number)
+	}
 	return b.eth.blockchain.GetBlockByNumber(uint64(number)), nil
 }
 
+// finalityBlock resolves the finalized and safe block tags.
+func (b *EthAPIBackend) finalityBlock(number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.FinalizedBlockNumber {
+		if block := b.eth.blockchain.CurrentFinalizedBlock(); block != nil {
+			return block, nil
+		}
+		return nil, errors.New("finalized block not found")
+	}
+	if block := b.eth.blockchain.CurrentSafeBlock(); block != nil {
+		return block, nil
+	}
+	return nil, errors.New("safe block not found")
+}
+
 func (b *EthAPIBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
 	return b.eth.blockchain.GetBlockByHash(hash), nil
 }
@@ -15,35 +15,36 @@ import (
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
 	ethapi "github.com/ethereum/go-ethereum/internal/ethapi"
-	"strings"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 	// X402API provides native x402 payment functionality
 type X402API struct {
 	eth *Ethereum
 
-	// In-memory replay protection (demo-level; not durable across restarts)
-	nonceMu    sync.Mutex
-	usedNonces map[common.Address]map[common.Hash]uint64
+	// Nonces of submitted envelopes that are not yet included in the chain,
+	// mapped to their validBefore. Included nonces are tracked on-chain.
+	nonceMu       sync.Mutex
+	pendingNonces map[common.Address]map[common.Hash]uint64
 
 	// Configurable protocol treasury
 	treasuryAddr common.Address
-
-	// Strict signature verification (production): if true, only accept canonical v2 EIP-191 format
-	strictVerify bool
 }
 
 // NewX402API creates a new x402 API instance
 func NewX402API(eth *Ethereum) *X402API {
 	api := &X402API{
-		eth:          eth,
-		usedNonces:   make(map[common.Address]map[common.Hash]uint64),
-		treasuryAddr: common.Address{},
+		eth:           eth,
+		pendingNonces: make(map[common.Address]map[common.Hash]uint64),
+		treasuryAddr:  common.Address{},
 	}
 	// Load protocol treasury from env if provided
 	if env := os.Getenv("X402_TREASURY_ADDRESS"); env != "" {
@@ -54,13 +55,6 @@ func NewX402API(eth *Ethereum) *X402API {
 			log.Warn("X402: Invalid X402_TREASURY_ADDRESS, falling back to default", "value", env)
 		}
 	}
-	// Strict verify mode (production): enable with X402_STRICT_VERIFY=1|true
-	if sv := os.Getenv("X402_STRICT_VERIFY"); sv == "1" || strings.EqualFold(sv, "true") {
-		api.strictVerify = true
-		log.Info("X402: Strict signature verification ENABLED")
-	} else {
-		log.Info("X402: Strict signature verification DISABLED (dev compatibility)")
-	}
 	return api
 }
 
@@ -74,30 +68,56 @@ func (api *X402API) getProtocolTreasury() common.Address {
 	return common.HexToAddress("0xd1D6E4F8777393Ac4dE10067EF6073048da0607d")
 }
 
-func (api *X402API) isNonceUsed(from common.Address, nonce common.Hash) bool {
-	api.nonceMu.Lock()
-	defer api.nonceMu.Unlock()
-	if byFrom, ok := api.usedNonces[from]; ok {
-		_, exists := byFrom[nonce]
-		return exists
-	}
-	return false
+// isNonceSettled checks whether the payment nonce was consumed by an x402
+// envelope included in the chain, as recorded by the on-chain registry.
+func (api *X402API) isNonceSettled(statedb *state.StateDB, from common.Address, nonce common.Hash) bool {
+	return statedb.GetState(types.X402RegistryAddress, types.X402NonceSlot(from, nonce)) != (common.Hash{})
 }
 
-func (api *X402API) isNonceUsedAndMark(from common.Address, nonce common.Hash) bool {
+// markNoncePending atomically checks and marks the payment nonce as submitted.
+// It returns true if an envelope with the same nonce is already pending.
+// Entries are dropped once their payment expires, after which the envelope is
+// either settled on-chain or can no longer be included.
+func (api *X402API) markNoncePending(from common.Address, nonce common.Hash, validBefore uint64) bool {
 	api.nonceMu.Lock()
 	defer api.nonceMu.Unlock()
-	if byFrom, ok := api.usedNonces[from]; ok {
+
+	now := uint64(time.Now().Unix())
+	for addr, byFrom := range api.pendingNonces {
+		for n, expiry := range byFrom {
+			if expiry < now {
+				delete(byFrom, n)
+			}
+		}
+		if len(byFrom) == 0 {
+			delete(api.pendingNonces, addr)
+		}
+	}
+	if byFrom, ok := api.pendingNonces[from]; ok {
 		if _, exists := byFrom[nonce]; exists {
 			return true
 		}
 	} else {
-		api.usedNonces[from] = make(map[common.Hash]uint64)
+		api.pendingNonces[from] = make(map[common.Hash]uint64)
 	}
-	api.usedNonces[from][nonce] = uint64(time.Now().Unix())
+	api.pendingNonces[from][nonce] = validBefore
 	return false
 }
 
+// releaseNoncePending drops the pending mark of a payment nonce whose envelope
+// could not be submitted.
+func (api *X402API) releaseNoncePending(from common.Address, nonce common.Hash) {
+	api.nonceMu.Lock()
+	defer api.nonceMu.Unlock()
+
+	if byFrom, ok := api.pendingNonces[from]; ok {
+		delete(byFrom, nonce)
+		if len(byFrom) == 0 {
+			delete(api.pendingNonces, from)
+		}
+	}
+}
+
 // PaymentRequirements represents x402 payment requirements
 type PaymentRequirements struct {
 	Scheme              string         `json:"scheme"`
@@ -161,7 +181,7 @@ func (api *X402API) Verify(ctx context.Context, requirements PaymentRequirements
 	log.Info("X402: Verifying payment", "from", payload.Payload.From, "to", payload.Payload.To, "value", payload.Payload.Value)
 
 	// Basic validation
-	if payload.Scheme != "exact" {
+	if payload.Scheme != types.X402SchemeExact && payload.Scheme != types.X402SchemeUpto {
 		return &VerificationResponse{
 			IsValid:       false,
 			InvalidReason: "Unsupported payment scheme",
@@ -191,8 +211,13 @@ func (api *X402API) Verify(ctx context.Context, requirements PaymentRequirements
 		}, nil
 	}
 
+	// ERC-20 payments are authorized and settled through the token
+	if requirements.Asset != (common.Address{}) {
+		return api.verifyTokenPayment(ctx, requirements, payload)
+	}
+
 	// Verify signature
-	if !api.verifyPaymentSignature(payload.Payload) {
+	if !api.verifyPaymentSignature(payload.Scheme, payload.Payload) {
 		return &VerificationResponse{
 			IsValid:       false,
 			InvalidReason: "Invalid signature",
@@ -218,13 +243,24 @@ func (api *X402API) Verify(ctx context.Context, requirements PaymentRequirements
 		}, nil
 	}
 
-	// Enforce exact-amount semantics for "exact" scheme
+	// Enforce exact-amount semantics for "exact" scheme. For "upto" the signed
+	// value is the ceiling, which must not exceed the required maximum.
 	maxRequired := (*big.Int)(requirements.MaxAmountRequired)
-	if requiredAmount.Cmp(maxRequired) != 0 {
-		return &VerificationResponse{
-			IsValid:       false,
-			InvalidReason: "Payment amount must equal required amount",
-		}, nil
+	switch payload.Scheme {
+	case types.X402SchemeExact:
+		if requiredAmount.Cmp(maxRequired) != 0 {
+			return &VerificationResponse{
+				IsValid:       false,
+				InvalidReason: "Payment amount must equal required amount",
+			}, nil
+		}
+	case types.X402SchemeUpto:
+		if requiredAmount.Sign() <= 0 || requiredAmount.Cmp(maxRequired) > 0 {
+			return &VerificationResponse{
+				IsValid:       false,
+				InvalidReason: "Payment ceiling must be positive and not exceed required amount",
+			}, nil
+		}
 	}
 
 	// Verify recipient matches requirements
@@ -235,8 +271,8 @@ func (api *X402API) Verify(ctx context.Context, requirements PaymentRequirements
 		}, nil
 	}
 
-	// Check nonce replay (best-effort, in-memory)
-	if api.isNonceUsed(payload.Payload.From, payload.Payload.Nonce) {
+	// Check nonce replay against the on-chain registry
+	if api.isNonceSettled(state, payload.Payload.From, payload.Payload.Nonce) {
 		return &VerificationResponse{
 			IsValid:       false,
 			InvalidReason: "Payment nonce already used",
@@ -249,9 +285,93 @@ func (api *X402API) Verify(ctx context.Context, requirements PaymentRequirements
 	}, nil
 }
 
-// Settle executes a verified payment
-func (api *X402API) Settle(ctx context.Context, requirements PaymentRequirements, payload PaymentPayload) (*SettlementResponse, error) {
-	log.Info("X402: Settling payment", "from", payload.Payload.From, "to", payload.Payload.To, "value", payload.Payload.Value)
+// verifyTokenPayment validates an ERC-20 payment of the requirements asset. The
+// payload signature must be the payer's EIP-3009 TransferWithAuthorization
+// signature for the token, the balance and authorization state of which are
+// checked through EVM calls on the latest state.
+func (api *X402API) verifyTokenPayment(ctx context.Context, requirements PaymentRequirements, payload PaymentPayload) (*VerificationResponse, error) {
+	invalid := func(reason string) (*VerificationResponse, error) {
+		return &VerificationResponse{IsValid: false, InvalidReason: reason}, nil
+	}
+	if payload.Scheme != types.X402SchemeExact {
+		return invalid("Unsupported payment scheme for token asset")
+	}
+	amount := (*big.Int)(payload.Payload.Value)
+	if amount.Cmp((*big.Int)(requirements.MaxAmountRequired)) != 0 {
+		return invalid("Payment amount must equal required amount")
+	}
+	if payload.Payload.To != requirements.PayTo {
+		return invalid("Payment recipient mismatch")
+	}
+	asset := requirements.Asset
+	p := &types.X402Payload{
+		From:        payload.Payload.From,
+		To:          payload.Payload.To,
+		Value:       amount,
+		ValidAfter:  payload.Payload.ValidAfter,
+		ValidBefore: payload.Payload.ValidBefore,
+		Nonce:       payload.Payload.Nonce,
+		Asset:       asset,
+	}
+	// Verify the EIP-3009 authorization against the token's EIP-712 domain
+	domain, err := api.callToken(ctx, asset, core.X402DomainSeparatorData())
+	if err != nil || len(domain) != common.HashLength {
+		return invalid("Asset does not support EIP-3009 authorizations")
+	}
+	sig := make([]byte, len(payload.Payload.Signature))
+	copy(sig, payload.Payload.Signature)
+	if len(sig) != crypto.SignatureLength {
+		return invalid("Invalid signature")
+	}
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	hash := core.X402TokenAuthorizationHash(common.BytesToHash(domain), p)
+	if pub, err := crypto.SigToPub(hash.Bytes(), sig); err != nil || crypto.PubkeyToAddress(*pub) != p.From {
+		return invalid("Invalid signature")
+	}
+	// Check token balance and authorization state
+	balance, err := api.callToken(ctx, asset, core.X402BalanceOfData(p.From))
+	if err != nil || len(balance) != common.HashLength {
+		return invalid("Could not get token balance")
+	}
+	if new(big.Int).SetBytes(balance).Cmp(amount) < 0 {
+		return invalid("Insufficient balance")
+	}
+	used, err := api.callToken(ctx, asset, core.X402AuthorizationStateData(p.From, p.Nonce))
+	if err != nil || len(used) != common.HashLength {
+		return invalid("Could not get authorization state")
+	}
+	if new(big.Int).SetBytes(used).Sign() != 0 {
+		return invalid("Payment nonce already used")
+	}
+	return &VerificationResponse{
+		IsValid:      true,
+		PayerAddress: p.From.Hex(),
+	}, nil
+}
+
+// callToken executes a read-only call to a token contract on the latest state.
+func (api *X402API) callToken(ctx context.Context, token common.Address, data []byte) ([]byte, error) {
+	input := hexutil.Bytes(data)
+	args := ethapi.TransactionArgs{To: &token, Data: &input}
+	latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+
+	result, err := ethapi.DoCall(ctx, api.eth.APIBackend, args, latest, nil, 5*time.Second, api.eth.APIBackend.RPCGasCap())
+	if err != nil {
+		return nil, err
+	}
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Return(), nil
+}
+
+// Settle executes a verified payment. For the "upto" scheme amount is the
+// actual usage to settle, which may be any value up to the signed ceiling; for
+// the "exact" scheme it may be omitted and must otherwise equal the signed value.
+func (api *X402API) Settle(ctx context.Context, requirements PaymentRequirements, payload PaymentPayload, amount *hexutil.Big) (*SettlementResponse, error) {
+	log.Info("X402: Settling payment", "from", payload.Payload.From, "to", payload.Payload.To, "value", payload.Payload.Value, "amount", amount)
 
 	// First verify the payment
 	verification, err := api.Verify(ctx, requirements, payload)
@@ -269,63 +389,90 @@ func (api *X402API) Settle(ctx context.Context, requirements PaymentRequirements
 		}, nil
 	}
 
-	// Atomically check-and-mark nonce to prevent replay
-	if api.isNonceUsedAndMark(payload.Payload.From, payload.Payload.Nonce) {
+	// Determine the settled amount
+	signed := (*big.Int)(payload.Payload.Value)
+	settled := signed
+	switch payload.Scheme {
+	case types.X402SchemeExact:
+		if amount != nil && (*big.Int)(amount).Cmp(signed) != 0 {
+			return &SettlementResponse{Success: false, Error: "settlement amount must equal payment amount"}, nil
+		}
+	case types.X402SchemeUpto:
+		if amount == nil {
+			return &SettlementResponse{Success: false, Error: "settlement amount required for upto scheme"}, nil
+		}
+		settled = (*big.Int)(amount)
+		if settled.Sign() < 0 || settled.Cmp(signed) > 0 {
+			return &SettlementResponse{Success: false, Error: "settlement amount exceeds payment ceiling"}, nil
+		}
+	}
+
+	// Atomically check-and-mark nonce to prevent submitting duplicate envelopes
+	if api.markNoncePending(payload.Payload.From, payload.Payload.Nonce, payload.Payload.ValidBefore) {
 		return &SettlementResponse{
 			Success: false,
 			Error:   "payment nonce already used",
 		}, nil
 	}
+	// Release the nonce again unless the envelope made it into the txpool, so
+	// the payment can be retried after a transient failure
+	submitted := false
+	defer func() {
+		if !submitted {
+			api.releaseNoncePending(payload.Payload.From, payload.Payload.Nonce)
+		}
+	}()
 
-	// Build typed X402 consensus transaction (system tx) and submit to txpool
-	type x402Payload struct {
-		From        common.Address
-		To          common.Address
-		Value       *big.Int
-		ValidAfter  uint64
-		ValidBefore uint64
-		Nonce       common.Hash
-		Signature   []byte
-	}
-	// Prepare payload (use the same signature and fields already verified above)
-	p := x402Payload{
+	// Build the x402 settlement envelope and submit it to the txpool
+	p := types.X402Payload{
 		From:        payload.Payload.From,
 		To:          payload.Payload.To,
-		Value:       (*big.Int)(payload.Payload.Value),
+		Value:       new(big.Int).Set(settled),
 		ValidAfter:  payload.Payload.ValidAfter,
 		ValidBefore: payload.Payload.ValidBefore,
 		Nonce:       payload.Payload.Nonce,
 		Signature:   append([]byte(nil), payload.Payload.Signature...),
+		Resource:    requirements.Resource,
+		Scheme:      payload.Scheme,
+		Asset:       requirements.Asset,
+	}
+	if payload.Scheme == types.X402SchemeUpto {
+		p.MaxValue = new(big.Int).Set(signed)
 	}
 	enc, err := rlp.EncodeToBytes(&p)
 	if err != nil {
 		return &SettlementResponse{Success: false, Error: fmt.Sprintf("x402: encode payload failed: %v", err)}, nil
 	}
-	chainID := api.eth.blockchain.Config().ChainID
-	xTx := types.NewX402Tx(chainID, 0, nil, enc)
-
-	// Sign envelope with local etherbase (consensus engine will treat it as system tx)
+	// The envelope is sent from the local etherbase, it consumes an account nonce
+	// and its intrinsic gas like any other transaction, but pays no fee.
 	eb, err := api.eth.Etherbase()
 	if err != nil {
 		return &SettlementResponse{Success: false, Error: fmt.Sprintf("x402: etherbase not available: %v", err)}, nil
 	}
-	// Try to sign the envelope with local etherbase. If no wallet/signing is available,
-	// fall back to submitting the unsigned typed tx (it will still execute as a system tx).
-	var finalTx *types.Transaction = xTx
-	if wallet, werr := api.eth.AccountManager().Find(accounts.Account{Address: eb}); wallet != nil && werr == nil {
-		if signed, serr := wallet.SignTx(accounts.Account{Address: eb}, xTx, chainID); serr == nil {
-			finalTx = signed
-		} else {
-			log.Warn("X402: failed to sign X402 envelope, submitting unsigned", "error", serr)
-		}
-	} else {
-		log.Warn("X402: etherbase wallet not found, submitting unsigned X402 envelope", "error", werr)
+	wallet, err := api.eth.AccountManager().Find(accounts.Account{Address: eb})
+	if err != nil {
+		return &SettlementResponse{Success: false, Error: fmt.Sprintf("x402: etherbase wallet not found: %v", err)}, nil
+	}
+	gas, err := core.IntrinsicGas(enc, nil, false, true, true)
+	if err != nil {
+		return &SettlementResponse{Success: false, Error: fmt.Sprintf("x402: intrinsic gas: %v", err)}, nil
+	}
+	if p.Asset != (common.Address{}) {
+		gas += core.X402TokenCallGas
+	}
+	chainID := api.eth.blockchain.Config().ChainID
+	registry := types.X402RegistryAddress
+	xTx := types.NewX402Tx(chainID, api.eth.txPool.Nonce(eb), &registry, gas, enc)
+	finalTx, err := wallet.SignTx(accounts.Account{Address: eb}, xTx, chainID)
+	if err != nil {
+		return &SettlementResponse{Success: false, Error: fmt.Sprintf("x402: sign envelope failed: %v", err)}, nil
 	}
 	// Submit to txpool for inclusion; consensus engine will execute during block processing
 	txHash, addErr := ethapi.SubmitTransaction(ctx, api.eth.APIBackend, finalTx)
 	if addErr != nil {
 		return &SettlementResponse{Success: false, Error: fmt.Sprintf("x402: submit to txpool failed: %v", addErr)}, nil
 	}
+	submitted = true
 	return &SettlementResponse{
 		Success:   true,
 		TxHash:    txHash,
@@ -338,121 +485,46 @@ func (api *X402API) Supported(ctx context.Context) (*SupportedResponse, error) {
 	return &SupportedResponse{
 		Kinds: []PaymentKind{
 			{
-				Scheme:  "exact",
+				Scheme:  types.X402SchemeExact,
+				Network: "splendor",
+			},
+			{
+				Scheme:  types.X402SchemeUpto,
 				Network: "splendor",
 			},
 		},
 	}, nil
 }
 
-// verifyPaymentSignature verifies the payment signature
-func (api *X402API) verifyPaymentSignature(payload PaymentPayloadData) bool {
-	// Strict production mode: only accept canonical v2 (with chainId) and EIP-191 prefix, checksum addresses, hex value
-	chainIDStrict := api.eth.networkID
-	if api.strictVerify {
-		valHex := payload.Value.String()
-		msg := fmt.Sprintf("x402-payment:%s:%s:%s:%d:%d:%s:%d",
-			payload.From.Hex(),
-			payload.To.Hex(),
-			valHex,
-			payload.ValidAfter,
-			payload.ValidBefore,
-			payload.Nonce.Hex(),
-			chainIDStrict,
-		)
-		// Signature checks
-		sig := make([]byte, len(payload.Signature))
-		copy(sig, payload.Signature)
-		if len(sig) != 65 {
-			return false
-		}
-		if sig[64] >= 27 {
-			sig[64] -= 27
-		}
-		hash := accounts.TextHash([]byte(msg))
-		if pub, err := crypto.SigToPub(hash, sig); err == nil {
-			return crypto.PubkeyToAddress(*pub) == payload.From
-		}
-		return false
-	}
-
-	// Be permissive: try address case variants (checksum/lower), value encodings (hex/dec),
-	// message versions (v2 with chainId, v1 without), and both EIP-191 prefixed and raw hashes.
-	chainID := api.eth.networkID
-
-	// Prepare address strings (checksum and lowercase)
-	fromChecksum := payload.From.Hex()
-	toChecksum := payload.To.Hex()
-	fromLower := strings.ToLower(fromChecksum)
-	toLower := strings.ToLower(toChecksum)
-
-	// Prepare value strings
-	valHex := payload.Value.String() // hexutil.Big typically prints 0x...
-	valDec := (*big.Int)(payload.Value).String()
-
-	// Build candidate message strings
-	var msgs []string
-	addrPairs := [][2]string{
-		{fromChecksum, toChecksum},
-		{fromLower, toLower},
-	}
-	vals := []string{valHex, valDec}
-
-	for _, ap := range addrPairs {
-		for _, v := range vals {
-			// v2 (with chainId)
-			msgs = append(msgs, fmt.Sprintf("x402-payment:%s:%s:%s:%d:%d:%s:%d",
-				ap[0], ap[1], v, payload.ValidAfter, payload.ValidBefore, payload.Nonce.Hex(), chainID))
-			// v1 (without chainId)
-			msgs = append(msgs, fmt.Sprintf("x402-payment:%s:%s:%s:%d:%d:%s",
-				ap[0], ap[1], v, payload.ValidAfter, payload.ValidBefore, payload.Nonce.Hex()))
-		}
-	}
+// chainID returns the chain ID bound into x402 payment signatures.
+func (api *X402API) chainID() *big.Int {
+	return api.eth.blockchain.Config().ChainID
+}
 
-	// Signature copy and sanity
-	sig := make([]byte, len(payload.Signature))
-	copy(sig, payload.Signature)
-	if len(sig) != 65 {
-		log.Warn("X402: signature length invalid", "len", len(sig))
+// verifyPaymentSignature verifies the payment signature the same way consensus
+// does when settling the envelope: either an EIP-712 PaymentAuthorization or an
+// EIP-191 signature of the canonical message, bound to the chain ID. For the
+// "upto" scheme the signed value is the payment ceiling.
+func (api *X402API) verifyPaymentSignature(scheme string, payload PaymentPayloadData) bool {
+	p := &types.X402Payload{
+		From:        payload.From,
+		To:          payload.To,
+		Value:       (*big.Int)(payload.Value),
+		ValidAfter:  payload.ValidAfter,
+		ValidBefore: payload.ValidBefore,
+		Nonce:       payload.Nonce,
+		Signature:   payload.Signature,
+		Scheme:      scheme,
+	}
+	if p.Value == nil {
 		return false
 	}
-	if sig[64] >= 27 {
-		sig[64] -= 27
-	}
-	log.Info("X402: signature meta", "len", len(sig), "v", int(sig[64]))
-
-	recoverAddr := func(hash []byte) (common.Address, bool) {
-		var zero common.Address
-		pub, err := crypto.SigToPub(hash, sig)
-		if err != nil {
-			log.Info("X402: SigToPub error", "err", err)
-			return zero, false
-		}
-		addr := crypto.PubkeyToAddress(*pub)
-		return addr, true
-	}
-
-	for _, m := range msgs {
-		// EIP-191 prefixed
-		hashPrefixed := accounts.TextHash([]byte(m))
-		if rec, ok := recoverAddr(hashPrefixed); ok {
-			if rec == payload.From {
-				return true
-			}
-			log.Info("X402: recover mismatch (prefixed)", "msg", m, "recovered", rec, "expected", payload.From)
-		}
-		// Raw (eth_sign style)
-		hashRaw := crypto.Keccak256([]byte(m))
-		if rec, ok := recoverAddr(hashRaw); ok {
-			if rec == payload.From {
-				return true
-			}
-			log.Info("X402: recover mismatch (raw)", "msg", m, "recovered", rec, "expected", payload.From)
-		}
+	payer, err := p.Payer(api.chainID())
+	if err != nil {
+		log.Debug("X402: signature recovery failed", "from", payload.From, "err", err)
+		return false
 	}
-	log.Warn("X402: signature did not match any accepted message variants",
-		"from", fromChecksum, "to", toChecksum, "valHex", valHex, "valDec", valDec, "chainId", chainID)
-	return false
+	return payer == payload.From
 }
 
 
@@ -492,32 +564,85 @@ func (api *X402API) executeNativeTransfer(payload PaymentPayloadData) (common.Ha
 	return txHash, nil
 }
 
-// GetPaymentHistory returns payment history for an address
-func (api *X402API) GetPaymentHistory(ctx context.Context, address common.Address, limit int) ([]PaymentRecord, error) {
-	// This would be implemented with proper storage in a production system
-	return []PaymentRecord{}, nil
+// maxPaymentHistory is the maximum number of records returned by a single
+// GetPaymentHistory call.
+const maxPaymentHistory = 1000
+
+// GetPaymentHistory returns the x402 payments sent or received by an address,
+// most recent first. The optional offset skips that many records for paging.
+func (api *X402API) GetPaymentHistory(ctx context.Context, address common.Address, limit int, offset *int) ([]PaymentRecord, error) {
+	if limit <= 0 || limit > maxPaymentHistory {
+		limit = maxPaymentHistory
+	}
+	skip := 0
+	if offset != nil {
+		if *offset < 0 {
+			return nil, errors.New("negative offset")
+		}
+		skip = *offset
+	}
+	db := api.eth.ChainDb()
+	records := []PaymentRecord{}
+	for _, hash := range rawdb.ReadX402AccountPayments(db, address, skip, limit) {
+		entry := rawdb.ReadX402Payment(db, hash)
+		if entry == nil {
+			continue
+		}
+		status := "success"
+		if entry.Status != types.ReceiptStatusSuccessful {
+			status = "failed"
+		}
+		records = append(records, PaymentRecord{
+			TxHash:      hash,
+			BlockNumber: entry.BlockNumber,
+			From:        entry.From,
+			To:          entry.To,
+			Amount:      (*hexutil.Big)(entry.Value),
+			Nonce:       entry.Nonce,
+			Asset:       entry.Asset,
+			Timestamp:   entry.Time,
+			Resource:    entry.Resource,
+			Status:      status,
+		})
+	}
+	return records, nil
 }
 
 // PaymentRecord represents a historical payment record
 type PaymentRecord struct {
 	TxHash      common.Hash    `json:"txHash"`
+	BlockNumber uint64         `json:"blockNumber"`
 	From        common.Address `json:"from"`
 	To          common.Address `json:"to"`
 	Amount      *hexutil.Big   `json:"amount"`
+	Nonce       common.Hash    `json:"nonce"`
+	Asset       common.Address `json:"asset"`
 	Timestamp   uint64         `json:"timestamp"`
 	Resource    string         `json:"resource"`
 	Status      string         `json:"status"`
 }
 
+// averagePayment returns the average successful payment of the totals.
+func averagePayment(totals *rawdb.X402Totals) *hexutil.Big {
+	if totals.Count == 0 {
+		return (*hexutil.Big)(new(big.Int))
+	}
+	return (*hexutil.Big)(new(big.Int).Div(totals.Volume, new(big.Int).SetUint64(totals.Count)))
+}
+
 // GetPaymentStats returns payment statistics
 func (api *X402API) GetPaymentStats(ctx context.Context) (*PaymentStats, error) {
+	db := api.eth.ChainDb()
+	totals := rawdb.ReadX402Totals(db)
+	today := rawdb.ReadX402DayTotals(db, uint64(time.Now().Unix())/core.X402SecondsPerDay)
+
 	return &PaymentStats{
-		TotalPayments:     0,
-		TotalVolume:       (*hexutil.Big)(big.NewInt(0)),
-		AveragePayment:    (*hexutil.Big)(big.NewInt(0)),
-		ActiveUsers:       0,
-		PaymentsToday:     0,
-		VolumeToday:       (*hexutil.Big)(big.NewInt(0)),
+		TotalPayments:  totals.Count,
+		TotalVolume:    (*hexutil.Big)(totals.Volume),
+		AveragePayment: averagePayment(totals),
+		ActiveUsers:    totals.Payers,
+		PaymentsToday:  today.Count,
+		VolumeToday:    (*hexutil.Big)(today.Volume),
 	}, nil
 }
 
@@ -531,6 +656,56 @@ type PaymentStats struct {
 	VolumeToday       *hexutil.Big `json:"volumeToday"`
 }
 
+// GetResourceStats returns the statistics of the payments made for a resource
+func (api *X402API) GetResourceStats(ctx context.Context, resource string) (*ResourceStats, error) {
+	totals := rawdb.ReadX402ResourceTotals(api.eth.ChainDb(), resource)
+	return &ResourceStats{
+		Resource:       resource,
+		TotalPayments:  totals.Count,
+		TotalVolume:    (*hexutil.Big)(totals.Volume),
+		AveragePayment: averagePayment(totals),
+	}, nil
+}
+
+// ResourceStats represents the payment statistics of a single resource
+type ResourceStats struct {
+	Resource       string       `json:"resource"`
+	TotalPayments  uint64       `json:"totalPayments"`
+	TotalVolume    *hexutil.Big `json:"totalVolume"`
+	AveragePayment *hexutil.Big `json:"averagePayment"`
+}
+
+// maxDailyVolumeDays is the maximum number of days returned by GetDailyVolume.
+const maxDailyVolumeDays = 366
+
+// GetDailyVolume returns the payment volume of the last days (UTC), today first
+func (api *X402API) GetDailyVolume(ctx context.Context, days int) ([]DailyVolume, error) {
+	if days <= 0 || days > maxDailyVolumeDays {
+		days = maxDailyVolumeDays
+	}
+	var (
+		db    = api.eth.ChainDb()
+		today = uint64(time.Now().Unix()) / core.X402SecondsPerDay
+		res   = make([]DailyVolume, 0, days)
+	)
+	for i := uint64(0); i < uint64(days) && i <= today; i++ {
+		totals := rawdb.ReadX402DayTotals(db, today-i)
+		res = append(res, DailyVolume{
+			Date:     time.Unix(int64((today-i)*core.X402SecondsPerDay), 0).UTC().Format("2006-01-02"),
+			Payments: totals.Count,
+			Volume:   (*hexutil.Big)(totals.Volume),
+		})
+	}
+	return res, nil
+}
+
+// DailyVolume represents the payment volume of a single day
+type DailyVolume struct {
+	Date     string       `json:"date"`
+	Payments uint64       `json:"payments"`
+	Volume   *hexutil.Big `json:"volume"`
+}
+
 // processValidatorRevenue handles validator revenue sharing for x402 payments
 func (api *X402API) processValidatorRevenue(payload PaymentPayloadData, txHash common.Hash) {
 	// Get current block header to identify the validator
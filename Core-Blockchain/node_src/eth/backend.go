@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"math/big"
 	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -45,6 +46,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
+	"github.com/ethereum/go-ethereum/eth/protocols/vote"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
@@ -86,6 +88,7 @@ type Ethereum struct {
 
 	bloomRequests     chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer      *core.ChainIndexer             // Bloom indexer operating during block imports
+	x402Indexer       *core.X402Indexer              // x402 payment indexer following the canonical chain
 	closeBloomHandler chan struct{}
 
 	APIBackend *EthAPIBackend
@@ -194,7 +197,11 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 			Preimages:           config.Preimages,
 		}
 	)
-	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, eth.engine, vmConfig, eth.shouldPreserve, &config.TxLookupLimit)
+	var options []core.BlockChainOption
+	if config.ParallelImport {
+		options = append(options, core.EnableParallelImport(nil))
+	}
+	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, eth.engine, vmConfig, eth.shouldPreserve, &config.TxLookupLimit, options...)
 	if err != nil {
 		return nil, err
 	}
@@ -205,6 +212,7 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
+	eth.x402Indexer = core.NewX402Indexer(chainDb, eth.blockchain)
 
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
@@ -219,6 +227,8 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		eth.txPool.InitExTxValidator(congressEngine)
 		//
 		congressEngine.SetChain(eth.blockchain)
+		// submit double-sign reports through the local pool
+		congressEngine.SetTxPool(eth.txPool)
 	}
 
 	// Permit the downloader to use the trie cache allowance during fast sync
@@ -227,6 +237,15 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if checkpoint == nil {
 		checkpoint = params.TrustedCheckpoints[genesisHash]
 	}
+	// Secure the connections with the hybrid ML-KEM handshake if the chain asks for it
+	if chainConfig.PostQuantum != nil && chainConfig.PostQuantum.EnableMLKEMNetworking {
+		eth.p2pServer.HybridKEM = true
+	}
+	// Gossip finality votes if the engine supports fast finality
+	var votes votePool
+	if congressEngine, ok := eth.engine.(*congress.Congress); ok && chainConfig.FastFinalityBlock != nil {
+		votes = congressEngine
+	}
 	if eth.handler, err = newHandler(&handlerConfig{
 		Database:   chainDb,
 		Chain:      eth.blockchain,
@@ -237,6 +256,7 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		EventMux:   eth.eventMux,
 		Checkpoint: checkpoint,
 		Whitelist:  config.Whitelist,
+		VotePool:   votes,
 	}); err != nil {
 		return nil, err
 	}
@@ -545,13 +565,26 @@ func (s *Ethereum) StartMining(threads int) error {
 			}
 			clique.Authorize(eb, wallet.SignData)
 		}
-		if congress, ok := s.engine.(*congress.Congress); ok {
+		if c, ok := s.engine.(*congress.Congress); ok {
 			wallet, err := s.accountManager.Find(accounts.Account{Address: eb})
 			if wallet == nil || err != nil {
 				log.Error("Etherbase account unavailable locally", "err", err)
 				return fmt.Errorf("signer missing: %v", err)
 			}
-			congress.Authorize(eb, wallet.SignData, wallet.SignTx)
+			c.Authorize(eb, wallet.SignData, wallet.SignTx)
+
+			if files := s.config.Miner.PQKeyFile; files != "" {
+				var signers []congress.PQSigner
+				for _, file := range strings.Split(files, ",") {
+					key, signFn, err := congress.LoadPQKey(strings.TrimSpace(file))
+					if err != nil {
+						log.Error("Cannot load ML-DSA sealing key", "file", file, "err", err)
+						return fmt.Errorf("ML-DSA key missing: %v", err)
+					}
+					signers = append(signers, congress.PQSigner{Key: key, SignFn: signFn})
+				}
+				c.AuthorizePQ(signers...)
+			}
 		}
 		// If mining is started, we can disable the transaction rejection mechanism
 		// introduced to speed sync times.
@@ -598,6 +631,9 @@ func (s *Ethereum) Protocols() []p2p.Protocol {
 	if s.config.SnapshotCache > 0 {
 		protos = append(protos, snap.MakeProtocols((*snapHandler)(s.handler), s.snapDialCandidates)...)
 	}
+	if s.handler.votePool != nil {
+		protos = append(protos, vote.MakeProtocols((*voteHandler)(s.handler))...)
+	}
 	return protos
 }
 
@@ -632,6 +668,7 @@ func (s *Ethereum) Stop() error {
 
 	// Then stop everything else.
 	s.bloomIndexer.Close()
+	s.x402Indexer.Close()
 	close(s.closeBloomHandler)
 	s.txPool.Stop()
 	s.miner.Close()
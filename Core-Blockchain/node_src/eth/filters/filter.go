@@ -146,16 +146,25 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	if f.end == -1 {
 		end = head
 	}
+	// Resolve the finalized and safe block tags, which would wrap around
+	var err error
+	if f.begin, err = f.resolveFinality(ctx, f.begin); err != nil {
+		return nil, err
+	}
+	if f.end == rpc.FinalizedBlockNumber.Int64() || f.end == rpc.SafeBlockNumber.Int64() {
+		number, err := f.resolveFinality(ctx, f.end)
+		if err != nil {
+			return nil, err
+		}
+		end = uint64(number)
+	}
 
 	if (int64(end) - f.begin) > maxFilterBlockRange {
 		return nil, fmt.Errorf("exceed maximum block range: %d", maxFilterBlockRange)
 	}
 
 	// Gather all indexed logs, and finish with non indexed ones
-	var (
-		logs []*types.Log
-		err  error
-	)
+	var logs []*types.Log
 	size, sections := f.backend.BloomStatus()
 	if indexed := sections * size; indexed > uint64(f.begin) {
 		if indexed > end {
@@ -172,6 +181,24 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	return logs, err
 }
 
+// resolveFinality returns the number of the block a finalized or safe block
+// tag stands for, and any other block number as is.
+func (f *Filter) resolveFinality(ctx context.Context, number int64) (int64, error) {
+	tag := rpc.BlockNumber(number)
+	if tag != rpc.FinalizedBlockNumber && tag != rpc.SafeBlockNumber {
+		return number, nil
+	}
+	header, err := f.backend.HeaderByNumber(ctx, tag)
+	if err != nil {
+		return 0, err
+	}
+	if header == nil {
+		name, _ := tag.MarshalText()
+		return 0, fmt.Errorf("%s block not found", name)
+	}
+	return header.Number.Int64(), nil
+}
+
 // indexedLogs returns the logs matching the filter criteria based on the bloom
 // bits indexed available locally or via the network.
 func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
@@ -31,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 func makeReceipt(addr common.Address) *types.Receipt {
@@ -227,4 +228,25 @@ func TestFilters(t *testing.T) {
 	if len(logs) != 0 {
 		t.Error("expected 0 log, got", len(logs))
 	}
+
+	// The finalized and safe block tags fail before any block is finalized
+	filter = NewRangeFilter(backend, 0, rpc.FinalizedBlockNumber.Int64(), []common.Address{addr}, nil)
+	if _, err := filter.Logs(context.Background()); err == nil {
+		t.Error("expected error without finalized block")
+	}
+	rawdb.WriteFinalizedBlockHash(db, chain[998].Hash())
+
+	filter = NewRangeFilter(backend, 0, rpc.FinalizedBlockNumber.Int64(), []common.Address{addr}, nil)
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 3 {
+		t.Error("expected 3 log, got", len(logs))
+	}
+	filter = NewRangeFilter(backend, rpc.SafeBlockNumber.Int64(), -1, []common.Address{addr}, nil)
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 2 {
+		t.Error("expected 2 log, got", len(logs))
+	}
+	if len(logs) > 0 && logs[0].Topics[0] != hash3 {
+		t.Errorf("expected log[0].Topics[0] to be %x, got %x", hash3, logs[0].Topics[0])
+	}
 }
@@ -86,6 +86,7 @@ type handlerConfig struct {
 	EventMux   *event.TypeMux            // Legacy event mux, deprecate for `feed`
 	Checkpoint *params.TrustedCheckpoint // Hard coded checkpoint for sync challenges
 	Whitelist  map[uint64]common.Hash    // Hard coded whitelist for sync challenged
+	VotePool   votePool                  // Finality vote pool to gossip from (nil if not voting)
 }
 
 type handler struct {
@@ -109,11 +110,17 @@ type handler struct {
 	blockFetcher *fetcher.BlockFetcher
 	txFetcher    *fetcher.TxFetcher
 	peers        *peerSet
+	votePool     votePool
+	votePeers    *votePeerSet
 
 	eventMux      *event.TypeMux
 	txsCh         chan core.NewTxsEvent
 	txsSub        event.Subscription
 	minedBlockSub *event.TypeMuxSubscription
+	votesCh       chan core.NewVoteEvent
+	votesSub      event.Subscription
+	voteHeadCh    chan core.ChainHeadEvent
+	voteHeadSub   event.Subscription
 
 	whitelist map[uint64]common.Hash
 
@@ -139,6 +146,8 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		txpool:     config.TxPool,
 		chain:      config.Chain,
 		peers:      newPeerSet(),
+		votePool:   config.VotePool,
+		votePeers:  newVotePeerSet(),
 		whitelist:  config.Whitelist,
 		quitSync:   make(chan struct{}),
 	}
@@ -406,6 +415,17 @@ func (h *handler) Start(maxPeers int) {
 	h.minedBlockSub = h.eventMux.Subscribe(core.NewMinedBlockEvent{})
 	go h.minedBroadcastLoop()
 
+	// cast and broadcast finality votes
+	if h.votePool != nil {
+		h.wg.Add(2)
+		h.votesCh = make(chan core.NewVoteEvent, voteChanSize)
+		h.votesSub = h.votePool.SubscribeNewVoteEvent(h.votesCh)
+		h.voteHeadCh = make(chan core.ChainHeadEvent, voteHeadChanSize)
+		h.voteHeadSub = h.chain.SubscribeChainHeadEvent(h.voteHeadCh)
+		go h.voteBroadcastLoop()
+		go h.voteCastLoop()
+	}
+
 	// start sync handlers
 	h.wg.Add(1)
 	go h.chainSync.loop()
@@ -414,6 +434,10 @@ func (h *handler) Start(maxPeers int) {
 func (h *handler) Stop() {
 	h.txsSub.Unsubscribe()        // quits txBroadcastLoop
 	h.minedBlockSub.Unsubscribe() // quits blockBroadcastLoop
+	if h.votePool != nil {
+		h.votesSub.Unsubscribe()    // quits voteBroadcastLoop
+		h.voteHeadSub.Unsubscribe() // quits voteCastLoop
+	}
 
 	// Quit chainSync and txsync64.
 	// After this is done, no new peers will be accepted.
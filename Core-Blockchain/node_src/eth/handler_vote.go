@@ -0,0 +1,187 @@
+// Copyright 2024 The Splendor Authors
+// This file handles the peers and messages of the `vote` protocol
+
+package eth
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/vote"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	// voteChanSize is the size of channel listening to NewVoteEvent.
+	voteChanSize = 256
+
+	// voteHeadChanSize is the size of channel listening to ChainHeadEvent to
+	// cast the local validator's votes.
+	voteHeadChanSize = 10
+)
+
+// votePool defines the methods needed from a finality vote pool implementation
+// to support all the operations needed by the Ethereum chain protocols.
+type votePool interface {
+	// AddVote validates a vote and adds it to the pool.
+	AddVote(vote *types.Vote) error
+
+	// CastVote votes for a new head block if the local node is a validator.
+	CastVote(header *types.Header) error
+
+	// SafeHeader returns the newest canonical block a supermajority voted for.
+	SafeHeader(chain consensus.ChainHeaderReader) *types.Header
+
+	// SubscribeNewVoteEvent should return an event subscription of
+	// NewVoteEvent and send events to the given channel.
+	SubscribeNewVoteEvent(chan<- core.NewVoteEvent) event.Subscription
+}
+
+// votePeerSet is the set of peers running the `vote` protocol.
+type votePeerSet struct {
+	peers map[string]*vote.Peer
+	lock  sync.RWMutex
+}
+
+// newVotePeerSet creates an empty set of `vote` peers.
+func newVotePeerSet() *votePeerSet {
+	return &votePeerSet{peers: make(map[string]*vote.Peer)}
+}
+
+// register injects a new `vote` peer into the set.
+func (ps *votePeerSet) register(peer *vote.Peer) error {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if _, ok := ps.peers[peer.ID()]; ok {
+		return errPeerAlreadyRegistered
+	}
+	ps.peers[peer.ID()] = peer
+	return nil
+}
+
+// unregister removes a `vote` peer from the set.
+func (ps *votePeerSet) unregister(id string) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	delete(ps.peers, id)
+}
+
+// peersWithoutVote retrieves a list of peers that do not have a given vote.
+func (ps *votePeerSet) peersWithoutVote(hash common.Hash) []*vote.Peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*vote.Peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if !p.KnownVote(hash) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// voteHandler implements the vote.Backend interface to handle the finality
+// votes gossiped by the validators.
+type voteHandler handler
+
+func (h *voteHandler) Chain() *core.BlockChain { return h.chain }
+
+// RunPeer is invoked when a peer joins on the `vote` protocol.
+func (h *voteHandler) RunPeer(peer *vote.Peer, hand vote.Handler) error {
+	h.peerWG.Add(1)
+	defer h.peerWG.Done()
+
+	if err := h.votePeers.register(peer); err != nil {
+		return err
+	}
+	defer h.votePeers.unregister(peer.ID())
+
+	return hand(peer)
+}
+
+// PeerInfo retrieves all known `vote` information about a peer.
+func (h *voteHandler) PeerInfo(id enode.ID) interface{} {
+	return nil
+}
+
+// Handle is invoked from a peer's message handler when it receives a new remote
+// message that the handler couldn't consume and serve itself.
+func (h *voteHandler) Handle(peer *vote.Peer, packet vote.Packet) error {
+	switch packet := packet.(type) {
+	case *vote.VotesPacket:
+		for _, v := range *packet {
+			// Votes may be legitimately stale or ahead of us, only log failures
+			if err := h.votePool.AddVote(v); err != nil {
+				peer.Log().Trace("Discarded finality vote", "number", v.BlockNumber, "hash", v.BlockHash, "err", err)
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unexpected vote packet type: %T", packet)
+	}
+}
+
+// BroadcastVotes propagates a batch of finality votes to all the `vote` peers
+// which are not known to already have them.
+func (h *handler) BroadcastVotes(votes []*types.Vote) {
+	var (
+		batches = make(map[*vote.Peer][]*types.Vote)
+		count   int
+	)
+	for _, v := range votes {
+		for _, peer := range h.votePeers.peersWithoutVote(v.Hash()) {
+			batches[peer] = append(batches[peer], v)
+		}
+	}
+	for peer, batch := range batches {
+		count += len(batch)
+		peer.AsyncSendVotes(batch)
+	}
+	log.Trace("Vote broadcast", "votes", len(votes), "peers", len(batches), "sent", count)
+}
+
+// voteBroadcastLoop announces new finality votes to connected peers.
+func (h *handler) voteBroadcastLoop() {
+	defer h.wg.Done()
+	for {
+		select {
+		case event := <-h.votesCh:
+			h.BroadcastVotes([]*types.Vote{event.Vote})
+		case <-h.votesSub.Err():
+			return
+		}
+	}
+}
+
+// voteCastLoop casts the local validator's vote on every new head block and
+// tracks the safe block as the votes come in.
+func (h *handler) voteCastLoop() {
+	defer h.wg.Done()
+	for {
+		select {
+		case event := <-h.voteHeadCh:
+			// Don't vote on blocks while still syncing the chain
+			if !h.downloader.Synchronising() {
+				if err := h.votePool.CastVote(event.Block.Header()); err != nil {
+					log.Warn("Failed to cast finality vote", "number", event.Block.Number(), "err", err)
+				}
+			}
+			if header := h.votePool.SafeHeader(h.chain); header != nil {
+				if block := h.chain.GetBlock(header.Hash(), header.Number.Uint64()); block != nil {
+					h.chain.SetSafe(block)
+				}
+			}
+		case <-h.voteHeadSub.Err():
+			return
+		}
+	}
+}
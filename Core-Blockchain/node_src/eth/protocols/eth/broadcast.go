@@ -44,6 +44,13 @@ func (p *Peer) broadcastBlocks() {
 	for {
 		select {
 		case prop := <-p.queuedBlocks:
+			if p.version >= ETH100 {
+				if err := p.SendNewCompactBlock(prop.block, prop.td); err != nil {
+					return
+				}
+				p.Log().Trace("Propagated compact block", "number", prop.block.Number(), "hash", prop.block.Hash(), "td", prop.td)
+				continue
+			}
 			if err := p.SendNewBlock(prop.block, prop.td); err != nil {
 				return
 			}
@@ -0,0 +1,285 @@
+// Copyright 2024 The Splendor Authors
+// This file implements the compact block propagation of eth/100
+
+package eth
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+	// maxCompactBlocks is the maximum number of compact blocks to keep, both the
+	// ones sent to a peer to serve their transactions and the ones received from
+	// a peer waiting for their missing transactions.
+	maxCompactBlocks = 4
+
+	// blockTxnsResponseLimit is the target maximum size of compact block
+	// transaction replies. It is well above softResponseLimit as the transactions
+	// stall the import of the block until all of them arrived.
+	blockTxnsResponseLimit = 16 * 1024 * 1024
+
+	// maxCompactRebuilds is the maximum number of compact blocks rebuilt from the
+	// pool per second across all peers, as every rebuild scans the pending pool.
+	maxCompactRebuilds = 8
+
+	// maxCompactRebuildScan is the maximum number of pool transactions hashed
+	// while rebuilding a compact block, the unscanned ones are fetched instead.
+	maxCompactRebuildScan = 1 << 20
+
+	// compactRebuildTimeout is the time a compact block announced by several
+	// peers is only rebuilt for the first of them, unless its rebuild ends.
+	compactRebuildTimeout = 5 * time.Second
+)
+
+// compactRebuilds is a singleton tracker of the compact blocks being rebuilt.
+var compactRebuilds = newCompactRebuildTracker()
+
+// compactRebuildTracker deduplicates the rebuilds of compact blocks announced by
+// several peers and limits the rate of the pool scans they take.
+type compactRebuildTracker struct {
+	started map[common.Hash]time.Time // Compact blocks being rebuilt, by start time
+	window  time.Time                 // Start of the current rate limiting second
+	count   int                       // Rebuilds started within the current second
+	lock    sync.Mutex
+}
+
+// newCompactRebuildTracker creates an empty compact block rebuild tracker.
+func newCompactRebuildTracker() *compactRebuildTracker {
+	return &compactRebuildTracker{started: make(map[common.Hash]time.Time)}
+}
+
+// claim reports whether a compact block may be rebuilt, marking it as being
+// rebuilt if so. It returns false for blocks already being rebuilt for another
+// peer, along with whether the rate limit was hit instead.
+func (t *compactRebuildTracker) claim(hash common.Hash, now time.Time) (bool, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for started, at := range t.started {
+		if now.Sub(at) >= compactRebuildTimeout {
+			delete(t.started, started)
+		}
+	}
+	if _, ok := t.started[hash]; ok {
+		return false, false
+	}
+	if now.Sub(t.window) >= time.Second {
+		t.window, t.count = now, 0
+	}
+	if t.count >= maxCompactRebuilds {
+		return false, true
+	}
+	t.count++
+	t.started[hash] = now
+	return true, false
+}
+
+// release marks the rebuild of a compact block as ended, letting the block be
+// rebuilt again for other peers.
+func (t *compactRebuildTracker) release(hash common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.started, hash)
+}
+
+// compactHasher derives the short IDs of transactions within a compact block:
+// the first 8 bytes of the hash of the announcement salt and the transaction
+// hash. The salt is picked by the sender, so transactions can't be crafted
+// up front to collide and stall the reconstruction.
+type compactHasher struct {
+	state crypto.KeccakState
+	buf   [8 + common.HashLength]byte
+	sum   [common.HashLength]byte
+}
+
+// newCompactHasher creates a short ID hasher for the given announcement salt.
+func newCompactHasher(salt uint64) *compactHasher {
+	h := &compactHasher{state: crypto.NewKeccakState()}
+	binary.BigEndian.PutUint64(h.buf[:8], salt)
+	return h
+}
+
+// id returns the short ID of the transaction with the given hash.
+func (h *compactHasher) id(hash common.Hash) uint64 {
+	copy(h.buf[8:], hash[:])
+	h.state.Reset()
+	h.state.Write(h.buf[:])
+	h.state.Read(h.sum[:])
+	return binary.BigEndian.Uint64(h.sum[:8])
+}
+
+// newCompactBlock creates the compact announcement of a block.
+func newCompactBlock(block *types.Block, td *big.Int) *NewCompactBlockPacket {
+	var (
+		txs    = block.Transactions()
+		packet = &NewCompactBlockPacket{
+			Header:   block.Header(),
+			Uncles:   block.Uncles(),
+			TD:       td,
+			Salt:     rand.Uint64(),
+			ShortIDs: make([]uint64, len(txs)),
+		}
+		hasher = newCompactHasher(packet.Salt)
+	)
+	for i, tx := range txs {
+		packet.ShortIDs[i] = hasher.id(tx.Hash())
+	}
+	return packet
+}
+
+// compactBlock is a compact block received from a peer, being rebuilt from the
+// local transaction pool and the transactions requested from the peer.
+type compactBlock struct {
+	packet   *NewCompactBlockPacket
+	hash     common.Hash
+	received time.Time
+
+	txs      []*types.Transaction // Transactions of the block, nil if still missing
+	missing  []uint64             // Indexes of the transactions still missing
+	request  uint64               // Id of the request in flight for missing transactions
+	fallback bool                 // Whether the pool matches were dropped after a bad rebuild
+}
+
+// newCompactBlockRebuild starts rebuilding a compact block from the pending
+// transactions of the pool, scanning up to maxCompactRebuildScan of them. Short
+// IDs matched by several transactions are left missing rather than guessed.
+func newCompactBlockRebuild(packet *NewCompactBlockPacket, pool TxPool, received time.Time) *compactBlock {
+	block := &compactBlock{
+		packet:   packet,
+		hash:     packet.Header.Hash(),
+		received: received,
+		txs:      make([]*types.Transaction, len(packet.ShortIDs)),
+	}
+	if len(packet.ShortIDs) == 0 {
+		return block
+	}
+	var (
+		hasher = newCompactHasher(packet.Salt)
+		wanted = make(map[uint64]*types.Transaction, len(packet.ShortIDs))
+	)
+	for _, id := range packet.ShortIDs {
+		wanted[id] = nil
+	}
+	var (
+		collided = make(map[uint64]struct{})
+		scanned  int
+	)
+scan:
+	for _, txs := range pool.Pending(false) {
+		for _, tx := range txs {
+			if scanned >= maxCompactRebuildScan {
+				break scan
+			}
+			scanned++
+
+			id := hasher.id(tx.Hash())
+			if match, ok := wanted[id]; !ok {
+				continue
+			} else if match != nil && match.Hash() != tx.Hash() {
+				collided[id] = struct{}{}
+			}
+			wanted[id] = tx
+		}
+	}
+	for i, id := range packet.ShortIDs {
+		if _, ok := collided[id]; !ok && wanted[id] != nil {
+			block.txs[i] = wanted[id]
+		} else {
+			block.missing = append(block.missing, uint64(i))
+		}
+	}
+	return block
+}
+
+// fill inserts transactions answering the first missing indexes, checking them
+// against their short IDs.
+func (b *compactBlock) fill(txs []*types.Transaction) error {
+	if len(txs) > len(b.missing) {
+		return fmt.Errorf("%w: %d transactions for %d missing", errDecode, len(txs), len(b.missing))
+	}
+	hasher := newCompactHasher(b.packet.Salt)
+	for i, tx := range txs {
+		index := b.missing[i]
+		if hasher.id(tx.Hash()) != b.packet.ShortIDs[index] {
+			return fmt.Errorf("%w: transaction %x doesn't match short ID at index %d", errDecode, tx.Hash(), index)
+		}
+		b.txs[index] = tx
+	}
+	b.missing = b.missing[len(txs):]
+	return nil
+}
+
+// assemble builds the block once all its transactions are present. It returns
+// nil if the transactions don't match the header, which happens if a pool
+// transaction collided with the short ID of one of the block.
+func (b *compactBlock) assemble() *types.Block {
+	if hash := types.DeriveSha(types.Transactions(b.txs), trie.NewStackTrie(nil)); hash != b.packet.Header.TxHash {
+		return nil
+	}
+	block := types.NewBlockWithHeader(b.packet.Header).WithBody(b.txs, b.packet.Uncles)
+	block.ReceivedAt = b.received
+	return block
+}
+
+// reset drops the transactions matched from the pool, marking all of them to
+// be requested from the peer.
+func (b *compactBlock) reset() {
+	b.fallback = true
+	b.txs = make([]*types.Transaction, len(b.packet.ShortIDs))
+	b.missing = make([]uint64, len(b.txs))
+	for i := range b.missing {
+		b.missing[i] = uint64(i)
+	}
+}
+
+// serveBlockTxns collects the transactions of a block at the requested indexes,
+// stopping at the first invalid index or once the response limit is reached.
+func serveBlockTxns(block *types.Block, indexes []uint64) []*types.Transaction {
+	var (
+		txs   = block.Transactions()
+		reply []*types.Transaction
+		bytes common.StorageSize
+	)
+	for _, index := range indexes {
+		if index >= uint64(len(txs)) || bytes >= blockTxnsResponseLimit {
+			break
+		}
+		reply = append(reply, txs[index])
+		bytes += txs[index].Size()
+	}
+	return reply
+}
+
+// completeCompactBlock hands a compact block over to the backend once all its
+// transactions are present, or requests the missing ones from the peer.
+func completeCompactBlock(backend Backend, peer *Peer, block *compactBlock) error {
+	if len(block.missing) > 0 {
+		return peer.requestBlockTxns(block)
+	}
+	peer.dropCompactBlock(block.hash)
+
+	full := block.assemble()
+	if full == nil {
+		if block.fallback {
+			compactRebuilds.release(block.hash)
+			return fmt.Errorf("%w: compact block %x transactions don't match header", errDecode, block.hash)
+		}
+		log.Debug("Compact block rebuild failed, requesting all transactions", "number", block.packet.Header.Number, "hash", block.hash)
+		block.reset()
+		return peer.requestBlockTxns(block)
+	}
+	full.ReceivedFrom = peer
+	return backend.Handle(peer, &NewBlockPacket{Block: full, TD: block.packet.TD})
+}
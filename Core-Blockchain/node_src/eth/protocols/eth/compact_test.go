@@ -0,0 +1,332 @@
+// Copyright 2024 The Splendor Authors
+// Tests of the compact block propagation of eth/100
+
+package eth
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// testCompactPool is a transaction pool holding a fixed set of transactions.
+type testCompactPool []*types.Transaction
+
+func (p testCompactPool) Get(hash common.Hash) *types.Transaction {
+	for _, tx := range p {
+		if tx.Hash() == hash {
+			return tx
+		}
+	}
+	return nil
+}
+
+func (p testCompactPool) Pending(bool) map[common.Address]types.Transactions {
+	return map[common.Address]types.Transactions{testAddr: types.Transactions(p)}
+}
+
+// compactTestBackend is a mock backend rebuilding compact blocks from a fixed
+// pool and collecting the rebuilt blocks and the announced hashes.
+type compactTestBackend struct {
+	*testBackend
+	pool   testCompactPool
+	blocks chan *NewBlockPacket
+	hashes chan *NewBlockHashesPacket
+}
+
+func (b *compactTestBackend) TxPool() TxPool { return b.pool }
+
+func (b *compactTestBackend) Handle(peer *Peer, packet Packet) error {
+	switch packet := packet.(type) {
+	case *NewBlockPacket:
+		b.blocks <- packet
+	case *NewBlockHashesPacket:
+		b.hashes <- packet
+	}
+	return nil
+}
+
+// newCompactTestBlock creates a valid block on top of the backend chain with a
+// number of transfers from the test account.
+func newCompactTestBlock(backend *testBackend, count int) *types.Block {
+	signer := types.LatestSigner(params.TestChainConfig)
+	blocks, _ := core.GenerateChain(params.TestChainConfig, backend.chain.CurrentBlock(), ethash.NewFaker(), backend.db, 1, func(i int, b *core.BlockGen) {
+		for j := 0; j < count; j++ {
+			b.AddTx(types.MustSignNewTx(testKey, signer, &types.LegacyTx{Nonce: b.TxNonce(testAddr), To: &common.Address{1}, Gas: 21000, GasPrice: big.NewInt(params.InitialBaseFee)}))
+		}
+	})
+	return blocks[0]
+}
+
+func TestCompactBlockRebuild(t *testing.T) {
+	backend := newTestBackend(0)
+	defer backend.close()
+
+	block := newCompactTestBlock(backend, 5)
+	txs := block.Transactions()
+
+	// Transactions found in the pool are matched, the rest are missing
+	packet := newCompactBlock(block, common.Big1)
+	rebuild := newCompactBlockRebuild(packet, testCompactPool{txs[3], txs[0], txs[1]}, time.Now())
+	if len(rebuild.missing) != 2 || rebuild.missing[0] != 2 || rebuild.missing[1] != 4 {
+		t.Fatalf("missing indexes mismatch: have %v, want [2 4]", rebuild.missing)
+	}
+	// Transactions not matching their short IDs are rejected
+	if err := rebuild.fill(types.Transactions{txs[4]}); !errors.Is(err, errDecode) {
+		t.Fatalf("mismatching transaction accepted: %v", err)
+	}
+	if err := rebuild.fill(types.Transactions{txs[2], txs[4], txs[0]}); !errors.Is(err, errDecode) {
+		t.Fatalf("excess transactions accepted: %v", err)
+	}
+	if err := rebuild.fill(types.Transactions{txs[2]}); err != nil || len(rebuild.missing) != 1 {
+		t.Fatalf("failed to fill transaction: %v, missing %v", err, rebuild.missing)
+	}
+	if err := rebuild.fill(types.Transactions{txs[4]}); err != nil || len(rebuild.missing) != 0 {
+		t.Fatalf("failed to fill transaction: %v, missing %v", err, rebuild.missing)
+	}
+	if rebuilt := rebuild.assemble(); rebuilt == nil || rebuilt.Hash() != block.Hash() {
+		t.Fatalf("rebuilt block mismatch")
+	}
+	// Rebuilds not matching the header fall back to fetching everything
+	rebuild.txs[0], rebuild.txs[1] = rebuild.txs[1], rebuild.txs[0]
+	if rebuild.assemble() != nil {
+		t.Fatalf("mismatching transactions assembled")
+	}
+	rebuild.reset()
+	if !rebuild.fallback || len(rebuild.missing) != len(txs) {
+		t.Fatalf("reset kept pool matches: missing %v", rebuild.missing)
+	}
+}
+
+func TestCompactBlockPropagation100(t *testing.T) {
+	t.Parallel()
+
+	backend := newTestBackend(1)
+	defer backend.close()
+
+	block := newCompactTestBlock(backend, 5)
+	txs := block.Transactions()
+
+	compact := &compactTestBackend{
+		testBackend: backend,
+		pool:        testCompactPool{txs[0], txs[1], txs[2]},
+		blocks:      make(chan *NewBlockPacket, 1),
+	}
+	peer, _ := newTestPeer("peer", ETH100, compact)
+	defer peer.close()
+
+	// Announce the block and expect the transactions missing from the pool requested
+	if err := p2p.Send(peer.app, NewCompactBlockMsg, newCompactBlock(block, common.Big2)); err != nil {
+		t.Fatalf("failed to send compact block: %v", err)
+	}
+	msg, err := peer.app.ReadMsg()
+	if err != nil || msg.Code != GetBlockTxnsMsg {
+		t.Fatalf("expected transaction request, have %v: %v", msg.Code, err)
+	}
+	var query GetBlockTxnsPacket100
+	if err := msg.Decode(&query); err != nil {
+		t.Fatalf("failed to decode transaction request: %v", err)
+	}
+	if query.Hash != block.Hash() || len(query.Indexes) != 2 || query.Indexes[0] != 3 || query.Indexes[1] != 4 {
+		t.Fatalf("transaction request mismatch: %x %v", query.Hash, query.Indexes)
+	}
+	// Deliver them in two batches and expect the block handed to the backend
+	for _, tx := range txs[3:] {
+		if err := p2p.Send(peer.app, BlockTxnsMsg, BlockTxnsPacket100{
+			RequestId:       query.RequestId,
+			BlockTxnsPacket: BlockTxnsPacket{Hash: block.Hash(), Txs: types.Transactions{tx}},
+		}); err != nil {
+			t.Fatalf("failed to send transactions: %v", err)
+		}
+		if tx == txs[3] {
+			// The remaining transaction is requested again under a new id
+			msg, err := peer.app.ReadMsg()
+			if err != nil || msg.Code != GetBlockTxnsMsg {
+				t.Fatalf("expected transaction request, have %v: %v", msg.Code, err)
+			}
+			if err := msg.Decode(&query); err != nil || len(query.Indexes) != 1 || query.Indexes[0] != 4 {
+				t.Fatalf("transaction request mismatch: %v, %v", query.Indexes, err)
+			}
+		}
+	}
+	select {
+	case packet := <-compact.blocks:
+		if packet.Block.Hash() != block.Hash() || packet.TD.Cmp(common.Big2) != 0 {
+			t.Fatalf("rebuilt block mismatch: have %x td %v, want %x", packet.Block.Hash(), packet.TD, block.Hash())
+		}
+		if packet.Block.ReceivedFrom != peer.Peer {
+			t.Fatalf("rebuilt block not attributed to the peer")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("rebuilt block not delivered")
+	}
+}
+
+func TestServeBlockTxns100(t *testing.T) {
+	t.Parallel()
+
+	backend := newTestBackend(1)
+	defer backend.close()
+
+	peer, _ := newTestPeer("peer", ETH100, backend)
+	defer peer.close()
+
+	// Propagate a block and expect it compact on the wire
+	block := newCompactTestBlock(backend, 3)
+	txs := block.Transactions()
+
+	go peer.Peer.SendNewCompactBlock(block, common.Big2)
+
+	msg, err := peer.app.ReadMsg()
+	if err != nil || msg.Code != NewCompactBlockMsg {
+		t.Fatalf("expected compact block, have %v: %v", msg.Code, err)
+	}
+	var ann NewCompactBlockPacket
+	if err := msg.Decode(&ann); err != nil {
+		t.Fatalf("failed to decode compact block: %v", err)
+	}
+	if ann.Header.Hash() != block.Hash() || len(ann.ShortIDs) != len(txs) {
+		t.Fatalf("compact block mismatch: %x with %d ids", ann.Header.Hash(), len(ann.ShortIDs))
+	}
+	// Transactions are served up to the first invalid index, unknown blocks are empty
+	tests := []struct {
+		hash    common.Hash
+		indexes []uint64
+		txs     []*types.Transaction
+	}{
+		{block.Hash(), []uint64{2, 0}, types.Transactions{txs[2], txs[0]}},
+		{block.Hash(), []uint64{1, 9, 0}, types.Transactions{txs[1]}},
+		{common.Hash{1}, []uint64{0}, nil},
+	}
+	for i, tt := range tests {
+		p2p.Send(peer.app, GetBlockTxnsMsg, GetBlockTxnsPacket100{
+			RequestId:          uint64(i),
+			GetBlockTxnsPacket: GetBlockTxnsPacket{Hash: tt.hash, Indexes: tt.indexes},
+		})
+		if err := p2p.ExpectMsg(peer.app, BlockTxnsMsg, BlockTxnsPacket100{
+			RequestId:       uint64(i),
+			BlockTxnsPacket: BlockTxnsPacket{Hash: tt.hash, Txs: tt.txs},
+		}); err != nil {
+			t.Errorf("test %d: transactions mismatch: %v", i, err)
+		}
+	}
+}
+
+func TestCompactBlockVerification(t *testing.T) {
+	t.Parallel()
+
+	backend := newTestBackend(2)
+	defer backend.close()
+
+	compact := &compactTestBackend{
+		testBackend: backend,
+		blocks:      make(chan *NewBlockPacket, 1),
+		hashes:      make(chan *NewBlockHashesPacket, 1),
+	}
+	block := newCompactTestBlock(backend, 7)
+
+	// Blocks of unknown ancestry are announced to the block fetcher instead
+	orphan := block.Header()
+	orphan.ParentHash = common.Hash{1}
+
+	peer, errc := newTestPeer("orphan", ETH100, compact)
+	defer peer.close()
+
+	if err := p2p.Send(peer.app, NewCompactBlockMsg, newCompactBlock(types.NewBlockWithHeader(orphan).WithBody(block.Transactions(), nil), common.Big2)); err != nil {
+		t.Fatalf("failed to send compact block: %v", err)
+	}
+	select {
+	case ann := <-compact.hashes:
+		if len(*ann) != 1 || (*ann)[0].Hash != orphan.Hash() || (*ann)[0].Number != orphan.Number.Uint64() {
+			t.Fatalf("announcement mismatch: %v", *ann)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("orphan compact block not announced")
+	}
+	// Blocks with invalid headers are never rebuilt and drop the peer
+	invalid := block.Header()
+	invalid.Difficulty = big.NewInt(1)
+
+	if err := p2p.Send(peer.app, NewCompactBlockMsg, newCompactBlock(types.NewBlockWithHeader(invalid).WithBody(block.Transactions(), nil), common.Big2)); err != nil {
+		t.Fatalf("failed to send compact block: %v", err)
+	}
+	select {
+	case err := <-errc:
+		if !errors.Is(err, errInvalidHeader) {
+			t.Fatalf("peer dropped with %v, want %v", err, errInvalidHeader)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("peer not dropped for invalid compact block")
+	}
+	// Valid blocks announced by several peers are only rebuilt for the first one
+	first, _ := newTestPeer("first", ETH100, compact)
+	defer first.close()
+	second, _ := newTestPeer("second", ETH100, compact)
+	defer second.close()
+
+	for _, peer := range []*testPeer{first, second} {
+		if err := p2p.Send(peer.app, NewCompactBlockMsg, newCompactBlock(block, common.Big2)); err != nil {
+			t.Fatalf("failed to send compact block: %v", err)
+		}
+	}
+	if msg, err := first.app.ReadMsg(); err != nil || msg.Code != GetBlockTxnsMsg {
+		t.Fatalf("expected transaction request, have %v: %v", msg.Code, err)
+	}
+	// Messages are handled in order, wait for a reply to a later one
+	p2p.Send(second.app, GetBlockTxnsMsg, GetBlockTxnsPacket100{RequestId: 1, GetBlockTxnsPacket: GetBlockTxnsPacket{Hash: common.Hash{1}}})
+	if err := p2p.ExpectMsg(second.app, BlockTxnsMsg, BlockTxnsPacket100{RequestId: 1, BlockTxnsPacket: BlockTxnsPacket{Hash: common.Hash{1}}}); err != nil {
+		t.Fatalf("transactions mismatch: %v", err)
+	}
+	pending := func(peer *testPeer) int {
+		peer.lock.RLock()
+		defer peer.lock.RUnlock()
+		return len(peer.compactPending)
+	}
+	if pending(first) != 1 {
+		t.Errorf("compact block not rebuilt for the first peer")
+	}
+	if pending(second) != 0 {
+		t.Errorf("compact block rebuilt again for the second peer")
+	}
+}
+
+func TestCompactRebuildTracker(t *testing.T) {
+	var (
+		tracker = newCompactRebuildTracker()
+		now     = time.Now()
+	)
+	// Blocks being rebuilt are not rebuilt again until released or timed out
+	if ok, _ := tracker.claim(common.Hash{1}, now); !ok {
+		t.Fatalf("failed to claim fresh block")
+	}
+	if ok, limited := tracker.claim(common.Hash{1}, now); ok || limited {
+		t.Fatalf("claimed block claimed again: limited %v", limited)
+	}
+	tracker.release(common.Hash{1})
+	if ok, _ := tracker.claim(common.Hash{1}, now); !ok {
+		t.Fatalf("failed to claim released block")
+	}
+	// Rebuilds are rate limited across blocks, the released one counted twice
+	for i := 2; i < maxCompactRebuilds; i++ {
+		if ok, _ := tracker.claim(common.Hash{byte(i)}, now); !ok {
+			t.Fatalf("failed to claim block %d", i)
+		}
+	}
+	if ok, limited := tracker.claim(common.Hash{0xff}, now); ok || !limited {
+		t.Fatalf("rate limit not enforced: claimed %v, limited %v", ok, limited)
+	}
+	if ok, _ := tracker.claim(common.Hash{0xff}, now.Add(time.Second)); !ok {
+		t.Fatalf("failed to claim block in the next second")
+	}
+	if ok, _ := tracker.claim(common.Hash{1}, now.Add(compactRebuildTimeout)); !ok {
+		t.Fatalf("failed to claim timed out block")
+	}
+}
@@ -19,9 +19,12 @@ package eth
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -272,6 +275,51 @@ func handleNewBlock(backend Backend, msg Decoder, peer *Peer) error {
 	return backend.Handle(peer, ann)
 }
 
+func handleNewCompactBlock(backend Backend, msg Decoder, peer *Peer) error {
+	// Retrieve and decode the propagated compact block
+	ann := new(NewCompactBlockPacket)
+	if err := msg.Decode(ann); err != nil {
+		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+	}
+	if err := ann.sanityCheck(); err != nil {
+		return err
+	}
+	if hash := types.CalcUncleHash(ann.Uncles); hash != ann.Header.UncleHash {
+		log.Warn("Propagated compact block has invalid uncles", "have", hash, "exp", ann.Header.UncleHash)
+		return nil
+	}
+	// Mark the peer as owning the block, and skip rebuilding it if known
+	var (
+		hash   = ann.Header.Hash()
+		number = ann.Header.Number.Uint64()
+		chain  = backend.Chain()
+	)
+	peer.markBlock(hash)
+	if chain.HasBlock(hash, number) {
+		return nil
+	}
+	// Verify the header before scanning the pool for the block. Blocks that can't
+	// be verified yet are left to the block fetcher, like hash announcements.
+	if err := chain.Engine().VerifyHeader(chain, ann.Header, true); err != nil {
+		if errors.Is(err, consensus.ErrUnknownAncestor) || errors.Is(err, consensus.ErrFutureBlock) {
+			peer.Log().Debug("Announcing unverifiable compact block", "number", number, "hash", hash, "err", err)
+			return backend.Handle(peer, &NewBlockHashesPacket{{Hash: hash, Number: number}})
+		}
+		return fmt.Errorf("%w: compact block %x: %v", errInvalidHeader, hash, err)
+	}
+	// Rebuild the block once across the peers announcing it, and announce it
+	// to the block fetcher instead while rebuilds are rate limited
+	if ok, limited := compactRebuilds.claim(hash, time.Now()); !ok {
+		if limited {
+			peer.Log().Debug("Compact block rebuilds rate limited", "number", number, "hash", hash)
+			return backend.Handle(peer, &NewBlockHashesPacket{{Hash: hash, Number: number}})
+		}
+		return nil
+	}
+	// Rebuild as much of the block from the pool as possible, fetching the rest
+	return completeCompactBlock(backend, peer, newCompactBlockRebuild(ann, backend.TxPool(), msg.Time()))
+}
+
 func handleBlockHeaders66(backend Backend, msg Decoder, peer *Peer) error {
 	// A batch of headers arrived to one of our previous requests
 	res := new(BlockHeadersPacket66)
@@ -316,6 +364,55 @@ func handleReceipts66(backend Backend, msg Decoder, peer *Peer) error {
 	return backend.Handle(peer, &res.ReceiptsPacket)
 }
 
+func handleGetBlockTxns100(backend Backend, msg Decoder, peer *Peer) error {
+	// Decode the compact block transaction query
+	var query GetBlockTxnsPacket100
+	if err := msg.Decode(&query); err != nil {
+		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+	}
+	// Serve the transactions from the block sent to the peer, or the chain
+	var txs []*types.Transaction
+	block := peer.sentCompactBlock(query.Hash)
+	if block == nil {
+		block = backend.Chain().GetBlockByHash(query.Hash)
+	}
+	if block != nil {
+		txs = serveBlockTxns(block, query.Indexes)
+	}
+	return peer.ReplyBlockTxns(query.RequestId, query.Hash, txs)
+}
+
+func handleBlockTxns100(backend Backend, msg Decoder, peer *Peer) error {
+	// A batch of compact block transactions arrived to one of our previous requests
+	res := new(BlockTxnsPacket100)
+	if err := msg.Decode(res); err != nil {
+		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+	}
+	requestTracker.Fulfil(peer.id, peer.version, BlockTxnsMsg, res.RequestId)
+
+	block := peer.pendingCompactBlock(res.Hash, res.RequestId)
+	if block == nil {
+		return nil // Stale or evicted block, nothing to rebuild anymore
+	}
+	for i, tx := range res.Txs {
+		if tx == nil {
+			return fmt.Errorf("%w: transaction %d is nil", errDecode, i)
+		}
+	}
+	if len(res.Txs) == 0 {
+		// The peer doesn't have the block anymore, leave it to the announcements
+		peer.Log().Debug("Compact block transactions unavailable", "hash", res.Hash, "missing", len(block.missing))
+		peer.dropCompactBlock(res.Hash)
+		compactRebuilds.release(res.Hash)
+		return nil
+	}
+	if err := block.fill(res.Txs); err != nil {
+		compactRebuilds.release(res.Hash)
+		return err
+	}
+	return completeCompactBlock(backend, peer, block)
+}
+
 func handleNewPooledTransactionHashes(backend Backend, msg Decoder, peer *Peer) error {
 	// New transaction announcement arrived, make sure we have
 	// a valid and fresh chain to handle them
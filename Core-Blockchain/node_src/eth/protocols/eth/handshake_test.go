@@ -28,7 +28,8 @@ import (
 )
 
 // Tests that handshake failures are detected and reported correctly.
-func TestHandshake66(t *testing.T) { testHandshake(t, ETH66) }
+func TestHandshake66(t *testing.T)  { testHandshake(t, ETH66) }
+func TestHandshake100(t *testing.T) { testHandshake(t, ETH100) }
 
 func testHandshake(t *testing.T, protocol uint) {
 	t.Parallel()
@@ -80,6 +80,9 @@ type Peer struct {
 	queuedBlocks    chan *blockPropagation // Queue of blocks to broadcast to the peer
 	queuedBlockAnns chan *types.Block      // Queue of blocks to announce to the peer
 
+	compactSent    []*types.Block                // Compact blocks sent to the peer, to serve their transactions
+	compactPending map[common.Hash]*compactBlock // Compact blocks received from the peer, waiting for transactions
+
 	txpool      TxPool             // Transaction pool used by the broadcasters for liveness checks
 	knownTxs    *knownCache        // Set of transaction hashes known to be known by this peer
 	txBroadcast chan []common.Hash // Channel used to queue transaction propagation requests
@@ -101,6 +104,7 @@ func NewPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWriter, txpool TxPool) *Pe
 		knownBlocks:     newKnownCache(maxKnownBlocks),
 		queuedBlocks:    make(chan *blockPropagation, maxQueuedBlocks),
 		queuedBlockAnns: make(chan *types.Block, maxQueuedBlockAnns),
+		compactPending:  make(map[common.Hash]*compactBlock),
 		txBroadcast:     make(chan []common.Hash),
 		txAnnounce:      make(chan []common.Hash),
 		txpool:          txpool,
@@ -289,6 +293,36 @@ func (p *Peer) AsyncSendNewBlock(block *types.Block, td *big.Int) {
 	}
 }
 
+// SendNewCompactBlock propagates a block to a remote peer as a compact block,
+// leaving it to the peer to rebuild the transactions from its pool.
+func (p *Peer) SendNewCompactBlock(block *types.Block, td *big.Int) error {
+	// Mark all the block hash as known, but ensure we don't overflow our limits
+	p.knownBlocks.Add(block.Hash())
+
+	// Keep the block around to serve the transactions the peer is missing
+	p.lock.Lock()
+	if len(p.compactSent) >= maxCompactBlocks {
+		p.compactSent = p.compactSent[1:]
+	}
+	p.compactSent = append(p.compactSent, block)
+	p.lock.Unlock()
+
+	return p2p.Send(p.rw, NewCompactBlockMsg, newCompactBlock(block, td))
+}
+
+// sentCompactBlock retrieves a block recently sent to the peer as a compact block.
+func (p *Peer) sentCompactBlock(hash common.Hash) *types.Block {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	for _, block := range p.compactSent {
+		if block.Hash() == hash {
+			return block
+		}
+	}
+	return nil
+}
+
 // ReplyBlockHeaders is the eth/66 version of SendBlockHeaders.
 func (p *Peer) ReplyBlockHeaders(id uint64, headers []*types.Header) error {
 	return p2p.Send(p.rw, BlockHeadersMsg, BlockHeadersPacket66{
@@ -322,6 +356,17 @@ func (p *Peer) ReplyReceiptsRLP(id uint64, receipts []rlp.RawValue) error {
 	})
 }
 
+// ReplyBlockTxns is the eth/100 response to GetBlockTxns.
+func (p *Peer) ReplyBlockTxns(id uint64, hash common.Hash, txs []*types.Transaction) error {
+	return p2p.Send(p.rw, BlockTxnsMsg, BlockTxnsPacket100{
+		RequestId: id,
+		BlockTxnsPacket: BlockTxnsPacket{
+			Hash: hash,
+			Txs:  txs,
+		},
+	})
+}
+
 // RequestOneHeader is a wrapper around the header query functions to fetch a
 // single header. It is used solely by the fetcher.
 func (p *Peer) RequestOneHeader(hash common.Hash) error {
@@ -426,6 +471,57 @@ func (p *Peer) RequestTxs(hashes []common.Hash) error {
 	})
 }
 
+// requestBlockTxns fetches the missing transactions of a compact block from the
+// remote node, keeping the block around until they arrive.
+func (p *Peer) requestBlockTxns(block *compactBlock) error {
+	p.Log().Debug("Fetching compact block transactions", "hash", block.hash, "count", len(block.missing))
+	id := rand.Uint64()
+
+	p.lock.Lock()
+	if _, ok := p.compactPending[block.hash]; !ok && len(p.compactPending) >= maxCompactBlocks {
+		var oldest *compactBlock
+		for _, pending := range p.compactPending {
+			if oldest == nil || pending.received.Before(oldest.received) {
+				oldest = pending
+			}
+		}
+		delete(p.compactPending, oldest.hash)
+		compactRebuilds.release(oldest.hash)
+	}
+	block.request = id
+	p.compactPending[block.hash] = block
+	p.lock.Unlock()
+
+	requestTracker.Track(p.id, p.version, GetBlockTxnsMsg, BlockTxnsMsg, id)
+	return p2p.Send(p.rw, GetBlockTxnsMsg, &GetBlockTxnsPacket100{
+		RequestId: id,
+		GetBlockTxnsPacket: GetBlockTxnsPacket{
+			Hash:    block.hash,
+			Indexes: block.missing,
+		},
+	})
+}
+
+// pendingCompactBlock retrieves a compact block waiting for the transactions
+// requested with the given id.
+func (p *Peer) pendingCompactBlock(hash common.Hash, id uint64) *compactBlock {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if block := p.compactPending[hash]; block != nil && block.request == id {
+		return block
+	}
+	return nil
+}
+
+// dropCompactBlock stops waiting for the transactions of a compact block.
+func (p *Peer) dropCompactBlock(hash common.Hash) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.compactPending, hash)
+}
+
 // knownCache is a cache for known hashes.
 type knownCache struct {
 	hashes mapset.Set
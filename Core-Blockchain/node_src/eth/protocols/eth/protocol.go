@@ -26,12 +26,18 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // Constants to match up protocol versions and messages
 const (
 	ETH66 = 66
+
+	// ETH100 is eth/66 extended with compact block propagation. It is numbered
+	// well apart from the upstream versions, so peers running an upstream eth/67
+	// or later never negotiate it with a different message set.
+	ETH100 = 100
 )
 
 // ProtocolName is the official short name of the `eth` protocol used during
@@ -40,11 +46,11 @@ const ProtocolName = "eth"
 
 // ProtocolVersions are the supported versions of the `eth` protocol (first
 // is primary).
-var ProtocolVersions = []uint{ETH66}
+var ProtocolVersions = []uint{ETH100, ETH66}
 
 // protocolLengths are the number of implemented message corresponding to
 // different protocol versions.
-var protocolLengths = map[uint]uint64{ETH66: 17}
+var protocolLengths = map[uint]uint64{ETH100: 20, ETH66: 17}
 
 // maxMessageSize caps the size of protocol messages; set to 1 GiB to safely
 // envelope exceptionally large RLP-encoded blocks.
@@ -66,12 +72,18 @@ const (
 	NewPooledTransactionHashesMsg = 0x08
 	GetPooledTransactionsMsg      = 0x09
 	PooledTransactionsMsg         = 0x0a
+
+	// Protocol messages introduced in eth/100
+	NewCompactBlockMsg = 0x11
+	GetBlockTxnsMsg    = 0x12
+	BlockTxnsMsg       = 0x13
 )
 
 var (
 	errNoStatusMsg             = errors.New("no status message")
 	errMsgTooLarge             = errors.New("message too long")
 	errDecode                  = errors.New("invalid message")
+	errInvalidHeader           = errors.New("invalid block header")
 	errInvalidMsgCode          = errors.New("invalid message code")
 	errProtocolVersionMismatch = errors.New("protocol version mismatch")
 	errNetworkIDMismatch       = errors.New("network ID mismatch")
@@ -196,6 +208,59 @@ func (request *NewBlockPacket) sanityCheck() error {
 	return nil
 }
 
+// NewCompactBlockPacket is the network packet for the compact block propagation
+// message of eth/100. It carries the block without its transactions, which are
+// identified by short IDs salted per announcement, for the receiver to rebuild
+// them from its transaction pool.
+type NewCompactBlockPacket struct {
+	Header   *types.Header
+	Uncles   []*types.Header
+	TD       *big.Int
+	Salt     uint64
+	ShortIDs []uint64
+}
+
+// sanityCheck verifies that the values are reasonable, as a DoS protection
+func (request *NewCompactBlockPacket) sanityCheck() error {
+	if err := request.Header.SanityCheck(); err != nil {
+		return err
+	}
+	if tdlen := request.TD.BitLen(); tdlen > 100 {
+		return fmt.Errorf("too large block TD: bitlen %d", tdlen)
+	}
+	if count, limit := uint64(len(request.ShortIDs)), request.Header.GasLimit/params.TxGas; count > limit {
+		return fmt.Errorf("too many compact block transactions: have %d, gas limit allows %d", count, limit)
+	}
+	return nil
+}
+
+// GetBlockTxnsPacket represents a query for the transactions of a compact block
+// by their index within the block.
+type GetBlockTxnsPacket struct {
+	Hash    common.Hash
+	Indexes []uint64
+}
+
+// GetBlockTxnsPacket100 represents a compact block transaction query over eth/100.
+type GetBlockTxnsPacket100 struct {
+	RequestId uint64
+	GetBlockTxnsPacket
+}
+
+// BlockTxnsPacket is the network packet for compact block transaction
+// distribution. The transactions answer a prefix of the requested indexes.
+type BlockTxnsPacket struct {
+	Hash common.Hash
+	Txs  []*types.Transaction
+}
+
+// BlockTxnsPacket100 is the network packet for compact block transaction
+// distribution over eth/100.
+type BlockTxnsPacket100 struct {
+	RequestId uint64
+	BlockTxnsPacket
+}
+
 // GetBlockBodiesPacket represents a block body query.
 type GetBlockBodiesPacket []common.Hash
 
@@ -363,3 +428,12 @@ func (*GetPooledTransactionsPacket) Kind() byte   { return GetPooledTransactions
 
 func (*PooledTransactionsPacket) Name() string { return "PooledTransactions" }
 func (*PooledTransactionsPacket) Kind() byte   { return PooledTransactionsMsg }
+
+func (*NewCompactBlockPacket) Name() string { return "NewCompactBlock" }
+func (*NewCompactBlockPacket) Kind() byte   { return NewCompactBlockMsg }
+
+func (*GetBlockTxnsPacket) Name() string { return "GetBlockTxns" }
+func (*GetBlockTxnsPacket) Kind() byte   { return GetBlockTxnsMsg }
+
+func (*BlockTxnsPacket) Name() string { return "BlockTxns" }
+func (*BlockTxnsPacket) Kind() byte   { return BlockTxnsMsg }
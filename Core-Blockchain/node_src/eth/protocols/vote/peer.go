@@ -0,0 +1,128 @@
+// Copyright 2024 The Splendor Authors
+// This file manages the peers of the `vote` protocol
+
+package vote
+
+import (
+	mapset "github.com/deckarep/golang-set"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+const (
+	// maxKnownVotes is the maximum vote hashes to keep in the known list
+	// before starting to randomly evict them.
+	maxKnownVotes = 8192
+
+	// maxQueuedVotes is the maximum number of vote batches to queue up before
+	// dropping broadcasts.
+	maxQueuedVotes = 64
+)
+
+// Peer is a collection of relevant information we have about a `vote` peer.
+type Peer struct {
+	id string // Unique ID for the peer, cached
+
+	*p2p.Peer                   // The embedded P2P package peer
+	rw        p2p.MsgReadWriter // Input/output streams for vote
+	version   uint              // Protocol version negotiated
+
+	knownVotes  mapset.Set         // Set of vote hashes known to be known by this peer
+	queuedVotes chan []*types.Vote // Queue of votes to broadcast to the peer
+
+	term   chan struct{} // Termination channel to stop the broadcaster
+	logger log.Logger    // Contextual logger with the peer id injected
+}
+
+// NewPeer create a wrapper for a network connection and negotiated protocol
+// version.
+func NewPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWriter) *Peer {
+	id := p.ID().String()
+	peer := &Peer{
+		id:          id,
+		Peer:        p,
+		rw:          rw,
+		version:     version,
+		knownVotes:  mapset.NewSet(),
+		queuedVotes: make(chan []*types.Vote, maxQueuedVotes),
+		term:        make(chan struct{}),
+		logger:      log.New("peer", id[:8]),
+	}
+	go peer.broadcastVotes()
+	return peer
+}
+
+// Close signals the broadcast goroutine to terminate. Only ever call this if
+// you created the peer yourself via NewPeer. Otherwise let whoever created it
+// clean it up!
+func (p *Peer) Close() {
+	close(p.term)
+}
+
+// ID retrieves the peer's unique identifier.
+func (p *Peer) ID() string {
+	return p.id
+}
+
+// Version retrieves the peer's negoatiated `vote` protocol version.
+func (p *Peer) Version() uint {
+	return p.version
+}
+
+// Log overrides the P2P logget with the higher level one containing only the id.
+func (p *Peer) Log() log.Logger {
+	return p.logger
+}
+
+// KnownVote returns whether peer is known to already have a vote.
+func (p *Peer) KnownVote(hash common.Hash) bool {
+	return p.knownVotes.Contains(hash)
+}
+
+// markVotes marks votes as known for the peer, ensuring that they will never
+// be propagated to this particular peer.
+func (p *Peer) markVotes(votes []*types.Vote) {
+	for _, vote := range votes {
+		// If we reached the memory allowance, drop a previously known vote hash
+		for p.knownVotes.Cardinality() >= maxKnownVotes {
+			p.knownVotes.Pop()
+		}
+		p.knownVotes.Add(vote.Hash())
+	}
+}
+
+// SendVotes sends votes to the peer and includes their hashes in its vote hash
+// set for future reference.
+func (p *Peer) SendVotes(votes []*types.Vote) error {
+	p.markVotes(votes)
+	return p2p.Send(p.rw, VotesMsg, votes)
+}
+
+// AsyncSendVotes queues a batch of votes for propagation to a remote peer. If
+// the peer's broadcast queue is full, the event is silently dropped.
+func (p *Peer) AsyncSendVotes(votes []*types.Vote) {
+	select {
+	case p.queuedVotes <- votes:
+		p.markVotes(votes)
+	default:
+		p.Log().Debug("Dropping vote propagation", "count", len(votes))
+	}
+}
+
+// broadcastVotes is a write loop that sends the queued votes to the remote peer.
+func (p *Peer) broadcastVotes() {
+	for {
+		select {
+		case votes := <-p.queuedVotes:
+			if err := p2p.Send(p.rw, VotesMsg, votes); err != nil {
+				return
+			}
+			p.Log().Trace("Propagated votes", "count", len(votes))
+
+		case <-p.term:
+			return
+		}
+	}
+}
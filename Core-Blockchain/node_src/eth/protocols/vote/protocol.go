@@ -0,0 +1,52 @@
+// Copyright 2024 The Splendor Authors
+// This file contains the messages of the `vote` protocol gossiping finality votes
+
+package vote
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	vote1 = 1
+)
+
+// ProtocolName is the official short name of the `vote` protocol used during
+// devp2p capability negotiation.
+const ProtocolName = "vote"
+
+// ProtocolVersions are the supported versions of the `vote` protocol (first
+// is primary).
+var ProtocolVersions = []uint{vote1}
+
+// protocolLengths are the number of implemented message corresponding to
+// different protocol versions.
+var protocolLengths = map[uint]uint64{vote1: 1}
+
+// maxMessageSize is the maximum cap on the size of a protocol message.
+const maxMessageSize = 10 * 1024 * 1024
+
+const (
+	VotesMsg = 0x00
+)
+
+var (
+	errMsgTooLarge    = errors.New("message too long")
+	errDecode         = errors.New("invalid message")
+	errInvalidMsgCode = errors.New("invalid message code")
+)
+
+// Packet represents a p2p message in the `vote` protocol.
+type Packet interface {
+	Name() string // Name returns a string corresponding to the message type.
+	Kind() byte   // Kind returns the message type.
+}
+
+// VotesPacket is the network packet for propagating finality votes.
+type VotesPacket []*types.Vote
+
+func (*VotesPacket) Name() string { return "Votes" }
+func (*VotesPacket) Kind() byte   { return VotesMsg }
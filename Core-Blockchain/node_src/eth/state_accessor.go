@@ -207,7 +207,7 @@ func (eth *Ethereum) stateAtTransaction(block *types.Block, txIndex int, reexec
 		if eth.isPoSA {
 			sender, _ := types.Sender(signer, tx)
 			ok, _ := eth.posa.IsSysTransaction(sender, tx, header)
-			if ok {
+			if ok || tx.Type() == types.X402TxType {
 				context.ExtraValidator = nil
 				if _, _, err := eth.posa.ApplySysTx(vmenv, statedb, idx, sender, tx); err != nil {
 					return nil, vm.BlockContext{}, nil, fmt.Errorf("transaction %#x failed: %v", tx.Hash(), err)
@@ -21,6 +21,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -175,6 +176,9 @@ type TraceConfig struct {
 	Tracer  *string
 	Timeout *string
 	Reexec  *uint64
+	// Config specific to given tracer. Note struct logger
+	// config are historically embedded in main object.
+	TracerConfig json.RawMessage
 }
 
 type BlockOverrides struct {
@@ -195,6 +199,7 @@ type TraceCallConfig struct {
     Tracer         *string
     Timeout        *string
     Reexec         *uint64
+    TracerConfig   json.RawMessage
     StateOverrides *ethapi.StateOverride
     BlockOverrides *BlockOverrides // Add this line
 }
@@ -312,6 +317,7 @@ func (api *API) traceChain(ctx context.Context, start, end *types.Block, config
 					)
 					if api.isPoSA {
 						isSysTx, _ = api.posa.IsSysTransaction(msg.From(), tx, header)
+						isSysTx = isSysTx || tx.Type() == types.X402TxType
 					}
 					if isSysTx {
 						res, err = api.tracePoSASysTx(ctx, msg.From(), tx, txctx, blockCtx, task.statedb, config)
@@ -676,6 +682,7 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 		if api.isPoSA {
 			sender, _ := types.Sender(signer, tx)
 			isSysTx, _ = api.posa.IsSysTransaction(sender, tx, header)
+			isSysTx = isSysTx || tx.Type() == types.X402TxType
 		}
 		// Send the trace task over for execution
 		jobs <- &txTraceTask{statedb: statedb.Copy(), index: i, isSysTx: isSysTx}
@@ -813,6 +820,7 @@ func (api *API) standardTraceBlockToFile(ctx context.Context, block *types.Block
 		var isSysTx bool
 		if api.isPoSA {
 			isSysTx, _ = api.posa.IsSysTransaction(msg.From(), tx, header)
+			isSysTx = isSysTx || tx.Type() == types.X402TxType
 		}
 		if isSysTx {
 			_, _, err = api.posa.ApplySysTx(vmenv, statedb, i, msg.From(), tx)
@@ -884,7 +892,7 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 	if api.isPoSA {
 		tx := block.Transactions()[int(index)]
 		ok, _ := api.posa.IsSysTransaction(msg.From(), tx, block.Header())
-		if ok {
+		if ok || tx.Type() == types.X402TxType {
 			return api.tracePoSASysTx(ctx, msg.From(), tx, txctx, vmctx, statedb, config)
 		}
 	}
@@ -943,6 +951,8 @@ func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, bloc
 			Tracer:    config.Tracer,
 			Timeout:   config.Timeout,
 			Reexec:    config.Reexec,
+
+			TracerConfig: config.TracerConfig,
 		}
 	}
 	return api.traceTx(ctx, msg, new(Context), vmctx, statedb, traceConfig)
@@ -969,7 +979,7 @@ func (api *API) traceTx(ctx context.Context, message core.Message, txctx *Contex
 				return nil, err
 			}
 		}
-		if t, err := New(*config.Tracer, txctx); err != nil {
+		if t, err := New(*config.Tracer, txctx, config.TracerConfig); err != nil {
 			return nil, err
 		} else {
 			deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
@@ -1018,7 +1028,7 @@ func (api *API) tracePoSASysTx(ctx context.Context, sender common.Address, tx *t
 			}
 		}
 		// Constuct the JavaScript tracer to execute with
-		if tracer, err = New(*config.Tracer, txctx); err != nil {
+		if tracer, err = New(*config.Tracer, txctx, config.TracerConfig); err != nil {
 			return nil, err
 		}
 		// Handle timeouts and RPC cancellations
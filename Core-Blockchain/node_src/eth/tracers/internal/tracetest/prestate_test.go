@@ -0,0 +1,249 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/congress"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/tests"
+)
+
+// prestateAccount is an account reported by the prestateTracer.
+type prestateAccount struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Nonce   uint64                      `json:"nonce,omitempty"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// prestateDiff is the result of a prestateTracer run in diff mode.
+type prestateDiff struct {
+	Pre  map[common.Address]*prestateAccount `json:"pre"`
+	Post map[common.Address]*prestateAccount `json:"post"`
+}
+
+// equalJSON reports whether two values encode to the same JSON, as decoded
+// big integers don't compare deeply equal to constructed ones.
+func equalJSON(a, b interface{}) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
+
+// newPrestateTracer creates a prestateTracer, in diff mode if requested.
+func newPrestateTracer(t *testing.T, diff bool) tracers.Tracer {
+	cfg, _ := json.Marshal(map[string]bool{"diffMode": diff})
+	tracer, err := tracers.New("prestateTracer", new(tracers.Context), cfg)
+	if err != nil {
+		t.Fatalf("failed to create prestate tracer: %v", err)
+	}
+	return tracer
+}
+
+func TestPrestateTracer(t *testing.T) {
+	var (
+		key, _   = crypto.GenerateKey()
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0x00000000000000000000000000000000000c0de")
+		coinbase = common.HexToAddress("0x00000000000000000000000000000000000cb00")
+		funds    = big.NewInt(params.Ether)
+		value    = big.NewInt(100)
+		baseFee  = big.NewInt(params.GWei)
+		gasPrice = big.NewInt(2 * params.GWei)
+
+		// slot0 = SLOAD(0) + 1, then read slot 1
+		code  = common.FromHex("6000546001016000556001545000")
+		alloc = core.GenesisAlloc{
+			sender: {Balance: funds},
+			contract: {Balance: new(big.Int), Code: code, Storage: map[common.Hash]common.Hash{
+				common.HexToHash("0x00"): common.HexToHash("0x05"),
+				common.HexToHash("0x01"): common.HexToHash("0x07"),
+			}},
+		}
+		signer = types.LatestSigner(params.TestChainConfig)
+		tx     = types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 0, To: &contract, Value: value, Gas: 100000, GasPrice: gasPrice})
+	)
+	run := func(diff bool) (json.RawMessage, uint64) {
+		_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), alloc, false)
+		context := vm.BlockContext{
+			CanTransfer: core.CanTransfer,
+			Transfer:    core.Transfer,
+			Coinbase:    coinbase,
+			BlockNumber: big.NewInt(1),
+			Time:        big.NewInt(1),
+			Difficulty:  big.NewInt(1),
+			GasLimit:    params.GenesisGasLimit,
+			BaseFee:     baseFee,
+		}
+		tracer := newPrestateTracer(t, diff)
+		evm := vm.NewEVM(context, vm.TxContext{Origin: sender, GasPrice: gasPrice}, statedb, params.TestChainConfig, vm.Config{Debug: true, Tracer: tracer})
+		msg, err := tx.AsMessage(signer, baseFee)
+		if err != nil {
+			t.Fatalf("failed to prepare transaction for tracing: %v", err)
+		}
+		result, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+		if err != nil || result.Failed() {
+			t.Fatalf("failed to execute transaction: %v, %v", err, result.Err)
+		}
+		res, err := tracer.GetResult()
+		if err != nil {
+			t.Fatalf("failed to retrieve trace result: %v", err)
+		}
+		return res, result.UsedGas
+	}
+	// The prestate holds the accounts as they were before gas was bought
+	res, _ := run(false)
+	var have map[common.Address]*prestateAccount
+	if err := json.Unmarshal(res, &have); err != nil {
+		t.Fatalf("failed to unmarshal prestate: %v", err)
+	}
+	want := map[common.Address]*prestateAccount{
+		sender:   {Balance: (*hexutil.Big)(funds)},
+		contract: {Balance: new(hexutil.Big), Code: code, Storage: alloc[contract].Storage},
+		coinbase: {Balance: new(hexutil.Big)},
+	}
+	if !equalJSON(have, want) {
+		t.Fatalf("prestate mismatch:\nhave %s", res)
+	}
+	// Diff mode reports the changed fields and storage only
+	res, used := run(true)
+	var diff prestateDiff
+	if err := json.Unmarshal(res, &diff); err != nil {
+		t.Fatalf("failed to unmarshal state diff: %v", err)
+	}
+	var (
+		fee  = new(big.Int).Mul(new(big.Int).SetUint64(used), gasPrice)
+		tip  = new(big.Int).Mul(new(big.Int).SetUint64(used), new(big.Int).Sub(gasPrice, baseFee))
+		left = new(big.Int).Sub(new(big.Int).Sub(funds, fee), value)
+	)
+	wantDiff := prestateDiff{
+		Pre: map[common.Address]*prestateAccount{
+			sender:   {Balance: (*hexutil.Big)(funds)},
+			contract: {Balance: new(hexutil.Big), Code: code, Storage: map[common.Hash]common.Hash{common.HexToHash("0x00"): common.HexToHash("0x05")}},
+			coinbase: {Balance: new(hexutil.Big)},
+		},
+		Post: map[common.Address]*prestateAccount{
+			sender:   {Balance: (*hexutil.Big)(left), Nonce: 1},
+			contract: {Balance: (*hexutil.Big)(value), Storage: map[common.Hash]common.Hash{common.HexToHash("0x00"): common.HexToHash("0x06")}},
+			coinbase: {Balance: (*hexutil.Big)(tip)},
+		},
+	}
+	if !equalJSON(diff, wantDiff) {
+		t.Fatalf("state diff mismatch:\nhave %s", res)
+	}
+}
+
+// Tests that x402 envelopes settled natively through the congress engine are
+// reported in the state diff, although they don't run through the interpreter.
+func TestPrestateTracerX402(t *testing.T) {
+	config := *params.TestChainConfig
+	config.Congress = &params.CongressConfig{Period: 3, Epoch: 200}
+
+	var (
+		payerKey, _       = crypto.GenerateKey()
+		facilitatorKey, _ = crypto.GenerateKey()
+		payer             = crypto.PubkeyToAddress(payerKey.PublicKey)
+		facilitator       = crypto.PubkeyToAddress(facilitatorKey.PublicKey)
+		payee             = common.HexToAddress("0x000000000000000000000000000000000000beef")
+		funds             = big.NewInt(params.Ether)
+		amount            = big.NewInt(12345)
+		registry          = types.X402RegistryAddress
+	)
+	payment := &types.X402Payload{
+		From:        payer,
+		To:          payee,
+		Value:       amount,
+		ValidBefore: ^uint64(0),
+		Nonce:       common.HexToHash("0x01"),
+	}
+	sig, err := crypto.Sign(payment.SigHash(config.ChainID).Bytes(), payerKey)
+	if err != nil {
+		t.Fatalf("failed to sign payload: %v", err)
+	}
+	payment.Signature = sig
+	payload, err := rlp.EncodeToBytes(payment)
+	if err != nil {
+		t.Fatalf("failed to encode payload: %v", err)
+	}
+	gas, err := core.IntrinsicGas(payload, nil, false, true, true)
+	if err != nil {
+		t.Fatalf("failed to compute intrinsic gas: %v", err)
+	}
+	tx, err := types.SignTx(types.NewX402Tx(config.ChainID, 0, &registry, gas, payload), types.LatestSigner(&config), facilitatorKey)
+	if err != nil {
+		t.Fatalf("failed to sign envelope: %v", err)
+	}
+	_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), core.GenesisAlloc{
+		payer:       {Balance: funds},
+		facilitator: {Balance: funds},
+	}, false)
+	context := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		BlockNumber: big.NewInt(1),
+		Time:        big.NewInt(1),
+		Difficulty:  big.NewInt(1),
+		GasLimit:    params.GenesisGasLimit,
+	}
+	tracer := newPrestateTracer(t, true)
+	evm := vm.NewEVM(context, vm.TxContext{}, statedb, &config, vm.Config{Debug: true, Tracer: tracer})
+
+	engine := congress.New(&config, rawdb.NewMemoryDatabase())
+	if _, vmerr, err := engine.ApplySysTx(evm, statedb, 0, facilitator, tx); err != nil || vmerr != nil {
+		t.Fatalf("failed to apply envelope: %v, %v", err, vmerr)
+	}
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	var diff prestateDiff
+	if err := json.Unmarshal(res, &diff); err != nil {
+		t.Fatalf("failed to unmarshal state diff: %v", err)
+	}
+	slot := types.X402NonceSlot(payer, payment.Nonce)
+	want := prestateDiff{
+		Pre: map[common.Address]*prestateAccount{
+			payer:       {Balance: (*hexutil.Big)(funds)},
+			payee:       {Balance: new(hexutil.Big)},
+			facilitator: {Balance: (*hexutil.Big)(funds)},
+			registry:    {Balance: new(hexutil.Big)},
+		},
+		Post: map[common.Address]*prestateAccount{
+			payer:       {Balance: (*hexutil.Big)(new(big.Int).Sub(funds, amount))},
+			payee:       {Balance: (*hexutil.Big)(amount)},
+			facilitator: {Nonce: 1},
+			registry:    {Nonce: 1, Storage: map[common.Hash]common.Hash{slot: common.BigToHash(common.Big1)}},
+		},
+	}
+	if !equalJSON(diff, want) {
+		t.Fatalf("state diff mismatch:\nhave %s", res)
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+)
+
+func init() {
+	register("prestateTracer", newPrestateTracer)
+}
+
+// account is the state of an account as reported by the prestate tracer. In
+// diff mode, post accounts only carry the fields which changed.
+type account struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Nonce   uint64                      `json:"nonce,omitempty"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// exists reports whether the account existed, telling created accounts apart.
+func (a *account) exists() bool {
+	return a.Nonce > 0 || len(a.Code) > 0 || len(a.Storage) > 0 || (a.Balance != nil && a.Balance.ToInt().Sign() != 0)
+}
+
+type prestateTracerConfig struct {
+	DiffMode bool `json:"diffMode"` // If true, this tracer will return state modifications
+}
+
+// prestateTracer collects the state of the accounts and storage slots touched
+// by a transaction, as they were before it ran. It follows the changes applied
+// outside of the interpreter too, such as gas purchase, fee payments and native
+// x402 settlements, through vm.StateAccessLogger.
+//
+// In diff mode, it reports the accounts changed by the transaction instead,
+// with their state before and after it.
+//
+// Example:
+//
+//	> debug.traceTransaction("0x...", {tracer: "prestateTracer", tracerConfig: {diffMode: true}})
+//	{
+//	  pre: {"0x35a9...": {balance: "0x1bc16d674ec80000", nonce: 1}},
+//	  post: {"0x35a9...": {balance: "0x1bc16d674ec7fd2c", nonce: 2}}
+//	}
+type prestateTracer struct {
+	env     *vm.EVM
+	pre     map[common.Address]*account
+	created map[common.Address]bool // Accounts created by the transaction
+	config  prestateTracerConfig
+
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// newPrestateTracer returns a native go tracer collecting the state touched by
+// a transaction, and implements vm.EVMLogger and vm.StateAccessLogger.
+func newPrestateTracer(ctx *tracers.Context, cfg json.RawMessage) (tracers.Tracer, error) {
+	var config prestateTracerConfig
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, err
+		}
+	}
+	return &prestateTracer{
+		pre:     make(map[common.Address]*account),
+		created: make(map[common.Address]bool),
+		config:  config,
+	}, nil
+}
+
+// CaptureAccountAccess implements the StateAccessLogger interface to record an
+// account before it is changed outside of the interpreter.
+func (t *prestateTracer) CaptureAccountAccess(env *vm.EVM, addr common.Address) {
+	t.env = env
+	t.lookupAccount(addr)
+}
+
+// CaptureStorageAccess implements the StateAccessLogger interface to record a
+// storage slot before it is changed outside of the interpreter.
+func (t *prestateTracer) CaptureStorageAccess(env *vm.EVM, addr common.Address, slot common.Hash) {
+	t.env = env
+	t.lookupStorage(addr, slot)
+}
+
+// CaptureStart implements the EVMLogger interface to initialize the tracing operation.
+func (t *prestateTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.env = env
+
+	// The value was already transferred and, for contract creations, the
+	// sender nonce increased. Undo them for accounts not reported beforehand.
+	if _, ok := t.pre[from]; !ok {
+		t.lookupAccount(from)
+		if from != to {
+			t.pre[from].Balance = (*hexutil.Big)(new(big.Int).Add(t.pre[from].Balance.ToInt(), value))
+		}
+		if create {
+			t.pre[from].Nonce--
+		}
+	}
+	if _, ok := t.pre[to]; !ok {
+		t.lookupAccount(to)
+		t.pre[to].Balance = (*hexutil.Big)(new(big.Int).Sub(t.pre[to].Balance.ToInt(), value))
+		if create {
+			// Creation fails on accounts with a nonce or code, only a balance
+			// may have been there before
+			t.pre[to].Nonce, t.pre[to].Code = 0, nil
+		}
+	}
+	if create {
+		t.created[to] = true
+	}
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) {
+}
+
+// CaptureState implements the EVMLogger interface to trace a single step of VM execution.
+func (t *prestateTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	// Skip if tracing was interrupted
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		t.env.Cancel()
+		return
+	}
+	if err != nil {
+		return
+	}
+	var (
+		stack     = scope.Stack.Data()
+		stackLen  = len(stack)
+		caller    = scope.Contract.Address()
+		stackAddr = func(n int) common.Address {
+			return common.Address(stack[stackLen-1-n].Bytes20())
+		}
+	)
+	switch {
+	case stackLen >= 1 && (op == vm.SLOAD || op == vm.SSTORE):
+		t.lookupStorage(caller, common.Hash(stack[stackLen-1].Bytes32()))
+	case stackLen >= 1 && (op == vm.EXTCODECOPY || op == vm.EXTCODEHASH || op == vm.EXTCODESIZE || op == vm.BALANCE || op == vm.SELFDESTRUCT):
+		t.lookupAccount(stackAddr(0))
+	case stackLen >= 2 && (op == vm.DELEGATECALL || op == vm.CALL || op == vm.STATICCALL || op == vm.CALLCODE):
+		t.lookupAccount(stackAddr(1))
+	case op == vm.CREATE:
+		addr := crypto.CreateAddress(caller, t.env.StateDB.GetNonce(caller))
+		t.lookupAccount(addr)
+		t.created[addr] = true
+	case stackLen >= 4 && op == vm.CREATE2:
+		offset, size := stack[stackLen-2], stack[stackLen-3]
+		init := scope.Memory.GetCopy(int64(offset.Uint64()), int64(size.Uint64()))
+		addr := crypto.CreateAddress2(caller, stack[stackLen-4].Bytes32(), crypto.Keccak256(init))
+		t.lookupAccount(addr)
+		t.created[addr] = true
+	}
+}
+
+// CaptureFault implements the EVMLogger interface to trace an execution fault.
+func (t *prestateTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, _ *vm.ScopeContext, depth int, err error) {
+}
+
+// CaptureEnter is called when EVM enters a new scope (via call, create or selfdestruct).
+func (t *prestateTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+}
+
+// CaptureExit is called when EVM exits a scope, even if the scope didn't
+// execute any code.
+func (t *prestateTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+}
+
+// GetResult returns the json-encoded state touched by the transaction, and any
+// error arising from the encoding or forceful termination (via `Stop`). It must
+// be called right after the transaction, as diff mode reads the state it left.
+func (t *prestateTracer) GetResult() (json.RawMessage, error) {
+	var (
+		res []byte
+		err error
+	)
+	if t.config.DiffMode {
+		pre, post := t.diff()
+		res, err = json.Marshal(struct {
+			Pre  map[common.Address]*account `json:"pre"`
+			Post map[common.Address]*account `json:"post"`
+		}{pre, post})
+	} else {
+		// Accounts created by the transaction didn't exist before it
+		for addr := range t.created {
+			if acc := t.pre[addr]; acc != nil && !acc.exists() {
+				delete(t.pre, addr)
+			}
+		}
+		res, err = json.Marshal(t.pre)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(res), t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *prestateTracer) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
+
+// diff compares the recorded accounts with the state left by the transaction,
+// returning the accounts it changed before and after it. Accounts deleted by
+// the transaction are left out of the post state.
+func (t *prestateTracer) diff() (map[common.Address]*account, map[common.Address]*account) {
+	pre := make(map[common.Address]*account)
+	post := make(map[common.Address]*account)
+	if t.env == nil {
+		return pre, post
+	}
+	var (
+		db     = t.env.StateDB
+		sweep  = t.env.ChainConfig().IsEIP158(t.env.Context.BlockNumber)
+		absent = func(addr common.Address) bool {
+			return db.HasSuicided(addr) || !db.Exist(addr) || (sweep && db.Empty(addr))
+		}
+	)
+	for addr, prev := range t.pre {
+		if absent(addr) {
+			if prev.exists() {
+				pre[addr] = prev
+			}
+			continue
+		}
+		var (
+			before   = &account{Balance: prev.Balance, Nonce: prev.Nonce, Code: prev.Code, Storage: make(map[common.Hash]common.Hash)}
+			after    = &account{Storage: make(map[common.Hash]common.Hash)}
+			modified bool
+		)
+		if balance := db.GetBalance(addr); balance.Cmp(prev.Balance.ToInt()) != 0 {
+			after.Balance, modified = (*hexutil.Big)(balance), true
+		}
+		if nonce := db.GetNonce(addr); nonce != prev.Nonce {
+			after.Nonce, modified = nonce, true
+		}
+		if code := db.GetCode(addr); !bytes.Equal(code, prev.Code) {
+			after.Code, modified = code, true
+		}
+		for slot, val := range prev.Storage {
+			current := db.GetState(addr, slot)
+			if current == val {
+				continue
+			}
+			modified = true
+			if val != (common.Hash{}) {
+				before.Storage[slot] = val
+			}
+			if current != (common.Hash{}) {
+				after.Storage[slot] = current
+			}
+		}
+		if !modified {
+			continue
+		}
+		if !t.created[addr] || prev.exists() {
+			pre[addr] = before
+		}
+		post[addr] = after
+	}
+	return pre, post
+}
+
+// lookupAccount fetches the details of an account and adds it to the prestate
+// if it doesn't exist there yet.
+func (t *prestateTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.pre[addr]; ok {
+		return
+	}
+	db := t.env.StateDB
+	t.pre[addr] = &account{
+		Balance: (*hexutil.Big)(new(big.Int).Set(db.GetBalance(addr))),
+		Nonce:   db.GetNonce(addr),
+		Code:    common.CopyBytes(db.GetCode(addr)),
+		Storage: make(map[common.Hash]common.Hash),
+	}
+}
+
+// lookupStorage fetches the requested storage slot and adds it to the prestate
+// of the given account if it doesn't exist there yet.
+func (t *prestateTracer) lookupStorage(addr common.Address, key common.Hash) {
+	t.lookupAccount(addr)
+	if _, ok := t.pre[addr].Storage[key]; ok {
+		return
+	}
+	t.pre[addr].Storage[key] = t.env.StateDB.GetState(addr, key)
+}
@@ -175,6 +175,14 @@ func toBlockNumArg(number *big.Int) string {
 	if number.Cmp(pending) == 0 {
 		return "pending"
 	}
+	if number.IsInt64() {
+		switch rpc.BlockNumber(number.Int64()) {
+		case rpc.FinalizedBlockNumber:
+			return "finalized"
+		case rpc.SafeBlockNumber:
+			return "safe"
+		}
+	}
 	return hexutil.EncodeBig(number)
 }
 
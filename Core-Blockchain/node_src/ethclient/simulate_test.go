@@ -0,0 +1,235 @@
+// Copyright 2024 The Splendor Authors
+// Tests of the multi-call simulation RPC
+
+package ethclient
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// simBlock is the part of a simulated block checked by the tests.
+type simBlock struct {
+	Number    hexutil.Uint64          `json:"number"`
+	Timestamp hexutil.Uint64          `json:"timestamp"`
+	Miner     common.Address          `json:"miner"`
+	GasUsed   hexutil.Uint64          `json:"gasUsed"`
+	Calls     []*ethapi.SimCallResult `json:"calls"`
+}
+
+var (
+	// simTimeCode returns the block timestamp
+	simTimeCode = hexutil.Bytes(common.FromHex("0x4260005260206000f3"))
+
+	// simRevertCode reverts with the word 0x2a
+	simRevertCode = hexutil.Bytes(common.FromHex("0x602a60005260206000fd"))
+)
+
+func TestSimulateV1(t *testing.T) {
+	backend, _ := newTestBackend(t)
+	client, _ := backend.Attach()
+	defer backend.Close()
+	defer client.Close()
+
+	var (
+		ctx      = context.Background()
+		alice    = common.Address{0xaa}
+		bob      = common.Address{0xbb}
+		clock    = common.Address{0xcc}
+		reverter = common.Address{0xdd}
+	)
+	simulate := func(opts map[string]interface{}) ([]simBlock, error) {
+		var blocks []simBlock
+		err := client.CallContext(ctx, &blocks, "eth_simulateV1", opts, "latest")
+		return blocks, err
+	}
+	// Calls see the state left by the previous calls and blocks, and blocks
+	// the overridden header fields and state
+	blocks, err := simulate(map[string]interface{}{
+		"blockStateCalls": []interface{}{
+			map[string]interface{}{
+				"blockOverrides": map[string]interface{}{"time": hexutil.Uint64(20000), "feeRecipient": bob},
+				"stateOverrides": map[string]interface{}{
+					clock.Hex():    map[string]interface{}{"code": simTimeCode},
+					reverter.Hex(): map[string]interface{}{"code": simRevertCode},
+				},
+				"calls": []interface{}{
+					map[string]interface{}{"from": testAddr, "to": alice, "value": (*hexutil.Big)(big.NewInt(1000))},
+					map[string]interface{}{"from": alice, "to": bob, "value": (*hexutil.Big)(big.NewInt(600))},
+					map[string]interface{}{"from": alice, "to": clock},
+					map[string]interface{}{"from": alice, "to": reverter},
+				},
+			},
+			map[string]interface{}{
+				"stateOverrides": map[string]interface{}{
+					bob.Hex(): map[string]interface{}{"balance": (*hexutil.Big)(big.NewInt(50))},
+				},
+				"calls": []interface{}{
+					map[string]interface{}{"from": alice, "to": bob, "value": (*hexutil.Big)(big.NewInt(400))},
+					map[string]interface{}{"from": bob, "to": alice, "value": (*hexutil.Big)(big.NewInt(450))},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("have %d blocks, want 2", len(blocks))
+	}
+	first, second := blocks[0], blocks[1]
+	if first.Number != 3 || second.Number != 4 {
+		t.Errorf("block numbers mismatch: have %d and %d, want 3 and 4", first.Number, second.Number)
+	}
+	if first.Timestamp != 20000 || second.Timestamp != 20001 || first.Miner != bob {
+		t.Errorf("block overrides mismatch: time %d and %d, miner %x", first.Timestamp, second.Timestamp, first.Miner)
+	}
+	for i, call := range append(first.Calls[:3:3], second.Calls[:2]...) {
+		if call.Status != hexutil.Uint64(types.ReceiptStatusSuccessful) || call.Error != nil {
+			t.Errorf("call %d failed: %+v", i, call.Error)
+		}
+	}
+	if have := new(big.Int).SetBytes(first.Calls[2].ReturnValue); have.Uint64() != 20000 {
+		t.Errorf("timestamp mismatch: have %v, want 20000", have)
+	}
+	if first.GasUsed == 0 || uint64(first.Calls[0].GasUsed) != 21000 {
+		t.Errorf("gas used mismatch: block %d, call %d", first.GasUsed, first.Calls[0].GasUsed)
+	}
+	// Reverts and failures are reported per call, without failing the simulation
+	revert := first.Calls[3]
+	if revert.Status != hexutil.Uint64(types.ReceiptStatusFailed) || revert.Error == nil || revert.Error.Code != 3 {
+		t.Fatalf("revert not reported: %+v", revert.Error)
+	}
+	if have := new(big.Int).SetBytes(revert.ReturnValue); have.Uint64() != 0x2a || revert.Error.Data != hexutil.Encode(common.LeftPadBytes([]byte{0x2a}, 32)) {
+		t.Errorf("revert data mismatch: %x, %q", revert.ReturnValue, revert.Error.Data)
+	}
+
+	// Validation checks the calls like transactions of a block
+	_, err = simulate(map[string]interface{}{
+		"validation": true,
+		"blockStateCalls": []interface{}{
+			map[string]interface{}{"calls": []interface{}{
+				map[string]interface{}{"from": testAddr, "to": alice, "nonce": hexutil.Uint64(9)},
+			}},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), core.ErrNonceTooHigh.Error()) {
+		t.Errorf("validation error mismatch: have %v, want %v", err, core.ErrNonceTooHigh)
+	}
+	nonce, err := NewClient(client).NonceAt(ctx, testAddr, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve nonce: %v", err)
+	}
+	blocks, err = simulate(map[string]interface{}{
+		"validation": true,
+		"blockStateCalls": []interface{}{
+			map[string]interface{}{"calls": []interface{}{
+				map[string]interface{}{"from": testAddr, "to": alice, "nonce": hexutil.Uint64(nonce), "gas": hexutil.Uint64(21000), "maxFeePerGas": (*hexutil.Big)(big.NewInt(2 * params.InitialBaseFee))},
+			}},
+		},
+	})
+	if err != nil || blocks[0].Calls[0].Status != hexutil.Uint64(types.ReceiptStatusSuccessful) {
+		t.Errorf("valid call rejected: %v", err)
+	}
+}
+
+func TestSimulateV1X402(t *testing.T) {
+	backend, _ := newTestBackend(t)
+	client, _ := backend.Attach()
+	defer backend.Close()
+	defer client.Close()
+
+	var (
+		ctx         = context.Background()
+		chainID     = genesis.Config.ChainID
+		payee       = common.Address{0xee}
+		shop        = common.Address{0x5f}
+		facilitator = common.Address{0xfa}
+		payment     = &types.X402Payload{From: testAddr, To: payee, Value: big.NewInt(5000), ValidBefore: ^uint64(0), Nonce: common.HexToHash("0x01")}
+	)
+	sig, err := crypto.Sign(payment.SigHash(chainID).Bytes(), testKey)
+	if err != nil {
+		t.Fatalf("failed to sign payment: %v", err)
+	}
+	payment.Signature = sig
+
+	settlement := map[string]interface{}{
+		"from": facilitator,
+		"x402": map[string]interface{}{
+			"from":        payment.From,
+			"to":          payment.To,
+			"value":       (*hexutil.Big)(payment.Value),
+			"validBefore": hexutil.Uint64(payment.ValidBefore),
+			"nonce":       payment.Nonce,
+			"signature":   hexutil.Bytes(payment.Signature),
+		},
+	}
+	// Settle the payment, spend it and replay it, by an unfunded facilitator
+	var blocks []simBlock
+	err = client.CallContext(ctx, &blocks, "eth_simulateV1", map[string]interface{}{
+		"blockStateCalls": []interface{}{
+			map[string]interface{}{"calls": []interface{}{
+				settlement,
+				map[string]interface{}{"from": payee, "to": shop, "value": (*hexutil.Big)(big.NewInt(4000))},
+				settlement,
+			}},
+		},
+	}, "latest")
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	calls := blocks[0].Calls
+	if calls[0].Status != hexutil.Uint64(types.ReceiptStatusSuccessful) || calls[1].Status != hexutil.Uint64(types.ReceiptStatusSuccessful) {
+		t.Fatalf("settle and use failed: %+v, %+v", calls[0].Error, calls[1].Error)
+	}
+	if len(calls[0].Logs) != 1 || calls[0].Logs[0].Address != types.X402RegistryAddress || calls[0].Logs[0].Topics[0] != types.X402SettledTopic {
+		t.Errorf("settlement log mismatch: %v", calls[0].Logs)
+	}
+	if calls[0].GasUsed == 0 {
+		t.Errorf("settlement used no gas")
+	}
+	if calls[2].Status != hexutil.Uint64(types.ReceiptStatusFailed) || calls[2].Error == nil || calls[2].Error.Message != core.ErrX402NonceUsed.Error() {
+		t.Errorf("replayed settlement not rejected: %+v", calls[2].Error)
+	}
+
+	// Raw signed envelopes are settled the same way
+	enc, err := rlp.EncodeToBytes(payment)
+	if err != nil {
+		t.Fatalf("failed to encode payment: %v", err)
+	}
+	gas, _ := core.IntrinsicGas(enc, nil, false, true, true)
+	nonce, err := NewClient(client).NonceAt(ctx, testAddr, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve nonce: %v", err)
+	}
+	registry := types.X402RegistryAddress
+	envelope, err := types.SignTx(types.NewX402Tx(chainID, nonce, &registry, gas, enc), types.LatestSigner(genesis.Config), testKey)
+	if err != nil {
+		t.Fatalf("failed to sign envelope: %v", err)
+	}
+	raw, _ := envelope.MarshalBinary()
+	err = client.CallContext(ctx, &blocks, "eth_simulateV1", map[string]interface{}{
+		"blockStateCalls": []interface{}{
+			map[string]interface{}{"calls": []interface{}{
+				map[string]interface{}{"raw": hexutil.Bytes(raw)},
+			}},
+		},
+	}, "latest")
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	if call := blocks[0].Calls[0]; call.Status != hexutil.Uint64(types.ReceiptStatusSuccessful) || len(call.Logs) != 1 || call.Logs[0].TxHash != envelope.Hash() {
+		t.Errorf("raw envelope not settled: %+v, logs %v", call.Error, call.Logs)
+	}
+}
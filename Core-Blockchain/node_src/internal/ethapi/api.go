@@ -786,6 +786,7 @@ func decodeHash(s string) (common.Hash, error) {
 // GetHeaderByNumber returns the requested canonical block header.
 // * When blockNr is -1 the chain head is returned.
 // * When blockNr is -2 the pending chain head is returned.
+// * When blockNr is -3 the finalized block is returned, and -4 the safe block.
 func (s *PublicBlockChainAPI) GetHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (map[string]interface{}, error) {
 	header, err := s.b.HeaderByNumber(ctx, number)
 	if header != nil && err == nil {
@@ -813,6 +814,7 @@ func (s *PublicBlockChainAPI) GetHeaderByHash(ctx context.Context, hash common.H
 // GetBlockByNumber returns the requested canonical block.
 // * When blockNr is -1 the chain head is returned.
 // * When blockNr is -2 the pending chain head is returned.
+// * When blockNr is -3 the finalized block is returned, and -4 the safe block.
 // * When fullTx is true all transactions in the block are returned, otherwise
 //   only the transaction hash is returned.
 func (s *PublicBlockChainAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
@@ -881,6 +883,127 @@ func (s *PublicBlockChainAPI) getSysTransactions(block *types.Block, posa consen
 	return transactions, nil
 }
 
+// maxBlockPageLimit is the maximum number of transactions or receipts served in
+// a single page of a block.
+const maxBlockPageLimit = 10000
+
+// BlockPage describes a page of the transactions or receipts of a block. The
+// next offset is nil on the last page.
+type BlockPage struct {
+	BlockHash   common.Hash     `json:"blockHash"`
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+	Total       hexutil.Uint64  `json:"total"`
+	Offset      hexutil.Uint64  `json:"offset"`
+	NextOffset  *hexutil.Uint64 `json:"nextOffset"`
+}
+
+// BlockTransactionsPage is a page of the transactions of a block.
+type BlockTransactionsPage struct {
+	BlockPage
+	Transactions []*RPCTransaction `json:"transactions"`
+}
+
+// BlockReceiptsPage is a page of the receipts of a block.
+type BlockReceiptsPage struct {
+	BlockPage
+	Receipts []map[string]interface{} `json:"receipts"`
+}
+
+// newBlockPage computes the page of a block starting at offset, returning the
+// indexes of the transactions it covers. Limits of zero or above the maximum
+// are capped to the maximum.
+func newBlockPage(block *types.Block, offset, limit hexutil.Uint64) (BlockPage, int, int, error) {
+	total := hexutil.Uint64(len(block.Transactions()))
+	if offset > total {
+		return BlockPage{}, 0, 0, fmt.Errorf("offset %d beyond %d transactions", offset, total)
+	}
+	if limit == 0 || limit > maxBlockPageLimit {
+		limit = maxBlockPageLimit
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	page := BlockPage{
+		BlockHash:   block.Hash(),
+		BlockNumber: hexutil.Uint64(block.NumberU64()),
+		Total:       total,
+		Offset:      offset,
+	}
+	if end < total {
+		page.NextOffset = &end
+	}
+	return page, int(offset), int(end), nil
+}
+
+// blockReceipts retrieves a block along with its receipts and the parameters
+// needed to convert them to the RPC representation.
+func (s *PublicBlockChainAPI) blockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, types.Receipts, types.Signer, error) {
+	block, err := s.b.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if block == nil || err != nil {
+		return nil, nil, nil, err
+	}
+	receipts, err := s.b.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(receipts) != len(block.Transactions()) {
+		return nil, nil, nil, fmt.Errorf("receipts of block %x not available", block.Hash())
+	}
+	return block, receipts, types.MakeSigner(s.b.ChainConfig(), block.Number()), nil
+}
+
+// GetBlockReceipts returns the receipts of all the transactions of a block. The
+// receipts are converted while the response is encoded, streaming them to the
+// client.
+func (s *PublicBlockChainAPI) GetBlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*rpc.ArrayStream, error) {
+	block, receipts, signer, err := s.blockReceipts(ctx, blockNrOrHash)
+	if block == nil || err != nil {
+		return nil, err
+	}
+	txs := block.Transactions()
+	return rpc.NewArrayStream(len(receipts), func(i int) interface{} {
+		return marshalReceipt(receipts[i], block.Hash(), block.NumberU64(), uint64(i), txs[i], signer, block.BaseFee())
+	}), nil
+}
+
+// GetBlockTransactions returns a page of at most limit transactions of a block,
+// starting at offset. The next offset of the page is the cursor of the next one.
+func (s *PublicBlockChainAPI) GetBlockTransactions(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, offset hexutil.Uint64, limit hexutil.Uint64) (*BlockTransactionsPage, error) {
+	block, err := s.b.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if block == nil || err != nil {
+		return nil, err
+	}
+	page, start, end, err := newBlockPage(block, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	txs := make([]*RPCTransaction, 0, end-start)
+	for i := start; i < end; i++ {
+		txs = append(txs, newRPCTransactionFromBlockIndex(block, uint64(i), s.b.ChainConfig()))
+	}
+	return &BlockTransactionsPage{BlockPage: page, Transactions: txs}, nil
+}
+
+// GetBlockReceiptsPage returns a page of at most limit receipts of a block,
+// starting at offset. The next offset of the page is the cursor of the next one.
+func (s *PublicBlockChainAPI) GetBlockReceiptsPage(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, offset hexutil.Uint64, limit hexutil.Uint64) (*BlockReceiptsPage, error) {
+	block, receipts, signer, err := s.blockReceipts(ctx, blockNrOrHash)
+	if block == nil || err != nil {
+		return nil, err
+	}
+	page, start, end, err := newBlockPage(block, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	txs := block.Transactions()
+	fields := make([]map[string]interface{}, 0, end-start)
+	for i := start; i < end; i++ {
+		fields = append(fields, marshalReceipt(receipts[i], block.Hash(), block.NumberU64(), uint64(i), txs[i], signer, block.BaseFee()))
+	}
+	return &BlockReceiptsPage{BlockPage: page, Receipts: fields}, nil
+}
+
 // GetUncleByBlockNumberAndIndex returns the uncle block for the given block hash and index. When fullTx is true
 // all transactions in the block are returned in full detail, otherwise only the transaction hash is returned.
 func (s *PublicBlockChainAPI) GetUncleByBlockNumberAndIndex(ctx context.Context, blockNr rpc.BlockNumber, index hexutil.Uint) (map[string]interface{}, error) {
@@ -1359,29 +1482,22 @@ func RPCMarshalHeader(head *types.Header) map[string]interface{} {
 
 // RPCMarshalBlock converts the given block to the RPC output which depends on fullTx. If inclTx is true transactions are
 // returned. When fullTx is true the returned block contains full transaction details, otherwise it will only contain
-// transaction hashes.
+// transaction hashes. Transactions are only converted while the response is encoded, streaming them to the client.
 func RPCMarshalBlock(block *types.Block, inclTx bool, fullTx bool, config *params.ChainConfig) (map[string]interface{}, error) {
 	fields := RPCMarshalHeader(block.Header())
 	fields["size"] = hexutil.Uint64(block.Size())
 
 	if inclTx {
-		formatTx := func(tx *types.Transaction) (interface{}, error) {
-			return tx.Hash(), nil
+		txs := block.Transactions()
+		formatTx := func(i int) interface{} {
+			return txs[i].Hash()
 		}
 		if fullTx {
-			formatTx = func(tx *types.Transaction) (interface{}, error) {
-				return newRPCTransactionFromBlockHash(block, tx.Hash(), config), nil
-			}
-		}
-		txs := block.Transactions()
-		transactions := make([]interface{}, len(txs))
-		var err error
-		for i, tx := range txs {
-			if transactions[i], err = formatTx(tx); err != nil {
-				return nil, err
+			formatTx = func(i int) interface{} {
+				return newRPCTransactionFromBlockIndex(block, uint64(i), config)
 			}
 		}
-		fields["transactions"] = transactions
+		fields["transactions"] = rpc.NewArrayStream(len(txs), formatTx)
 	}
 	uncles := block.Uncles()
 	uncleHashes := make([]common.Hash, len(uncles))
@@ -1435,6 +1551,11 @@ type RPCTransaction struct {
 	V                *hexutil.Big      `json:"v"`
 	R                *hexutil.Big      `json:"r"`
 	S                *hexutil.Big      `json:"s"`
+
+	// Post-quantum transaction authentication
+	PQAlgorithm *hexutil.Uint64 `json:"pqAlgorithm,omitempty"`
+	PQPublicKey hexutil.Bytes   `json:"pqPublicKey,omitempty"`
+	PQSignature hexutil.Bytes   `json:"pqSignature,omitempty"`
 }
 
 // newRPCTransaction returns a transaction that will serialize to the RPC
@@ -1467,7 +1588,7 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 		al := tx.AccessList()
 		result.Accesses = &al
 		result.ChainID = (*hexutil.Big)(tx.ChainId())
-	case types.DynamicFeeTxType:
+	case types.DynamicFeeTxType, types.PQTxType:
 		al := tx.AccessList()
 		result.Accesses = &al
 		result.ChainID = (*hexutil.Big)(tx.ChainId())
@@ -1481,6 +1602,13 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 		} else {
 			result.GasPrice = (*hexutil.Big)(tx.GasFeeCap())
 		}
+		if tx.Type() == types.PQTxType {
+			algorithm, publicKey, signature := tx.PQAuthorization()
+			id := hexutil.Uint64(algorithm)
+			result.PQAlgorithm = &id
+			result.PQPublicKey = publicKey
+			result.PQSignature = signature
+		}
 	}
 	return result
 }
@@ -1515,16 +1643,6 @@ func newRPCRawTransactionFromBlockIndex(b *types.Block, index uint64) hexutil.By
 	return blob
 }
 
-// newRPCTransactionFromBlockHash returns a transaction that will serialize to the RPC representation.
-func newRPCTransactionFromBlockHash(b *types.Block, hash common.Hash, config *params.ChainConfig) *RPCTransaction {
-	for idx, tx := range b.Transactions() {
-		if tx.Hash() == hash {
-			return newRPCTransactionFromBlockIndex(b, uint64(idx), config)
-		}
-	}
-	return nil
-}
-
 // accessListResult returns an optional accesslist
 // Its the result of the `debug_createAccessList` RPC call.
 // It contains an error if the transaction itself failed.
@@ -1761,17 +1879,30 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if len(receipts) <= int(index) {
 		return nil, nil
 	}
-	receipt := receipts[index]
-
-	// Derive the sender.
 	bigblock := new(big.Int).SetUint64(blockNumber)
 	signer := types.MakeSigner(s.b.ChainConfig(), bigblock)
+
+	var baseFee *big.Int
+	if s.b.ChainConfig().IsLondon(bigblock) {
+		header, err := s.b.HeaderByHash(ctx, blockHash)
+		if err != nil {
+			return nil, err
+		}
+		baseFee = header.BaseFee
+	}
+	return marshalReceipt(receipts[index], blockHash, blockNumber, index, tx, signer, baseFee), nil
+}
+
+// marshalReceipt converts the receipt of a transaction to the RPC representation.
+// The base fee of the block is nil before London.
+func marshalReceipt(receipt *types.Receipt, blockHash common.Hash, blockNumber uint64, index uint64, tx *types.Transaction, signer types.Signer, baseFee *big.Int) map[string]interface{} {
+	// Derive the sender.
 	from, _ := types.Sender(signer, tx)
 
 	fields := map[string]interface{}{
 		"blockHash":         blockHash,
 		"blockNumber":       hexutil.Uint64(blockNumber),
-		"transactionHash":   hash,
+		"transactionHash":   tx.Hash(),
 		"transactionIndex":  hexutil.Uint64(index),
 		"from":              from,
 		"to":                tx.To(),
@@ -1783,14 +1914,10 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 		"type":              hexutil.Uint(tx.Type()),
 	}
 	// Assign the effective gas price paid
-	if !s.b.ChainConfig().IsLondon(bigblock) {
+	if baseFee == nil {
 		fields["effectiveGasPrice"] = hexutil.Uint64(tx.GasPrice().Uint64())
 	} else {
-		header, err := s.b.HeaderByHash(ctx, blockHash)
-		if err != nil {
-			return nil, err
-		}
-		gasPrice := new(big.Int).Add(header.BaseFee, tx.EffectiveGasTipValue(header.BaseFee))
+		gasPrice := new(big.Int).Add(baseFee, tx.EffectiveGasTipValue(baseFee))
 		fields["effectiveGasPrice"] = hexutil.Uint64(gasPrice.Uint64())
 	}
 	// Assign receipt status or post state.
@@ -1806,7 +1933,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
-	return fields, nil
+	return fields
 }
 
 // sign is a helper function that signs a transaction with the private key of the given address.
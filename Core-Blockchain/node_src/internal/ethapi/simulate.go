@@ -0,0 +1,511 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// maxSimulateBlocks is the maximum number of blocks a single simulation may
+	// span.
+	maxSimulateBlocks = 256
+
+	// maxSimulateCalls is the maximum number of calls a single simulation may
+	// execute, across all its blocks.
+	maxSimulateCalls = 1000
+
+	// errCodeVMError is the JSON error code of calls failing in the EVM for any
+	// other reason than a revert.
+	errCodeVMError = -32015
+)
+
+// BlockOverrides is a set of header fields to override when simulating calls.
+type BlockOverrides struct {
+	Number     *hexutil.Big    `json:"number"`
+	Difficulty *hexutil.Big    `json:"difficulty"`
+	Time       *hexutil.Uint64 `json:"time"`
+	GasLimit   *hexutil.Uint64 `json:"gasLimit"`
+	Coinbase   *common.Address `json:"feeRecipient"`
+	BaseFee    *hexutil.Big    `json:"baseFeePerGas"`
+}
+
+// Apply overrides the given header fields.
+func (o *BlockOverrides) Apply(header *types.Header) {
+	if o == nil {
+		return
+	}
+	if o.Number != nil {
+		header.Number = new(big.Int).Set(o.Number.ToInt())
+	}
+	if o.Difficulty != nil {
+		header.Difficulty = new(big.Int).Set(o.Difficulty.ToInt())
+	}
+	if o.Time != nil {
+		header.Time = uint64(*o.Time)
+	}
+	if o.GasLimit != nil {
+		header.GasLimit = uint64(*o.GasLimit)
+	}
+	if o.Coinbase != nil {
+		header.Coinbase = *o.Coinbase
+	}
+	if o.BaseFee != nil {
+		header.BaseFee = new(big.Int).Set(o.BaseFee.ToInt())
+	}
+}
+
+// SimCall is a call of a simulation. It is a message given by its transaction
+// arguments, unless it carries a raw signed transaction or an x402 payment.
+type SimCall struct {
+	TransactionArgs
+
+	// Raw is a signed transaction of any type, executed like in a block: its
+	// nonce and fees are checked even without validation.
+	Raw hexutil.Bytes `json:"raw,omitempty"`
+
+	// X402 is a payment settled natively by an x402 envelope sent from the
+	// call sender, the facilitator. Unless given, the envelope gas is its
+	// intrinsic gas, plus the token call allowance for token payments.
+	X402 *SimX402Payment `json:"x402,omitempty"`
+}
+
+// SimX402Payment is an x402 payment settled within a simulation.
+type SimX402Payment struct {
+	From        common.Address `json:"from"`
+	To          common.Address `json:"to"`
+	Value       *hexutil.Big   `json:"value"`
+	ValidAfter  hexutil.Uint64 `json:"validAfter"`
+	ValidBefore hexutil.Uint64 `json:"validBefore"`
+	Nonce       common.Hash    `json:"nonce"`
+	Signature   hexutil.Bytes  `json:"signature"`
+	Scheme      string         `json:"scheme,omitempty"`
+	MaxValue    *hexutil.Big   `json:"maxValue,omitempty"`
+	Asset       common.Address `json:"asset"`
+	Resource    string         `json:"resource,omitempty"`
+}
+
+// payload returns the settlement payload carried by the envelope of the payment.
+func (p *SimX402Payment) payload() ([]byte, error) {
+	if p.Value == nil {
+		return nil, errors.New("missing x402 payment value")
+	}
+	payload := &types.X402Payload{
+		From:        p.From,
+		To:          p.To,
+		Value:       p.Value.ToInt(),
+		ValidAfter:  uint64(p.ValidAfter),
+		ValidBefore: uint64(p.ValidBefore),
+		Nonce:       p.Nonce,
+		Signature:   p.Signature,
+		Resource:    p.Resource,
+		Scheme:      p.Scheme,
+		MaxValue:    (*big.Int)(p.MaxValue),
+		Asset:       p.Asset,
+	}
+	return rlp.EncodeToBytes(payload)
+}
+
+// SimBlock is a block of a simulation: the calls to execute in order, on top
+// of the given header and state overrides.
+type SimBlock struct {
+	BlockOverrides *BlockOverrides `json:"blockOverrides"`
+	StateOverrides *StateOverride  `json:"stateOverrides"`
+	Calls          []SimCall       `json:"calls"`
+}
+
+// SimOpts are the inputs of eth_simulateV1.
+type SimOpts struct {
+	BlockStateCalls []SimBlock `json:"blockStateCalls"`
+	Validation      bool       `json:"validation"`
+}
+
+// SimCallResult is the outcome of a single simulated call.
+type SimCallResult struct {
+	ReturnValue hexutil.Bytes  `json:"returnData"`
+	Logs        []*types.Log   `json:"logs"`
+	GasUsed     hexutil.Uint64 `json:"gasUsed"`
+	Status      hexutil.Uint64 `json:"status"`
+	Error       *SimCallError  `json:"error,omitempty"`
+}
+
+// SimCallError is the error of a simulated call failing in the EVM. Reverts
+// carry the revert data, like the errors of eth_call.
+type SimCallError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data,omitempty"`
+}
+
+// simulator executes calls in order on top of a base block, each call seeing
+// the state left by the previous ones.
+type simulator struct {
+	b        Backend
+	state    *state.StateDB
+	base     *types.Header
+	coinbase common.Address // Fee recipient of the base block, inherited by simulated blocks
+	validate bool
+	budget   uint64 // Gas left for the remaining calls, zero if uncapped
+	capped   bool
+	timeout  time.Duration
+}
+
+// newSimulator creates a simulator on top of the state of the given block.
+func newSimulator(ctx context.Context, b Backend, blockNrOrHash rpc.BlockNumberOrHash, validate bool) (*simulator, error) {
+	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	coinbase, err := b.Engine().Author(header)
+	if err != nil {
+		coinbase = header.Coinbase
+	}
+	return &simulator{
+		b:        b,
+		state:    state,
+		base:     header,
+		coinbase: coinbase,
+		validate: validate,
+		budget:   b.RPCGasCap(),
+		capped:   b.RPCGasCap() != 0,
+		timeout:  b.RPCEVMTimeout(),
+	}, nil
+}
+
+// header assembles the header of a simulated block on top of parent. Unless
+// overridden, the block follows its parent by one number and one second.
+func (sim *simulator) header(parent *types.Header, overrides *BlockOverrides) (*types.Header, error) {
+	header := &types.Header{
+		ParentHash:  parent.Hash(),
+		UncleHash:   types.EmptyUncleHash,
+		Coinbase:    sim.coinbase,
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+		Difficulty:  new(big.Int).Set(parent.Difficulty),
+		Number:      new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:    parent.GasLimit,
+		Time:        parent.Time + 1,
+	}
+	overrides.Apply(header)
+
+	if header.Number.Cmp(parent.Number) <= 0 {
+		return nil, fmt.Errorf("block number %v not above parent %v", header.Number, parent.Number)
+	}
+	if header.Time <= parent.Time {
+		return nil, fmt.Errorf("block timestamp %d not above parent %d", header.Time, parent.Time)
+	}
+	if header.BaseFee == nil && sim.b.ChainConfig().IsLondon(header.Number) {
+		header.BaseFee = misc.CalcBaseFee(sim.b.ChainConfig(), parent)
+	}
+	return header, nil
+}
+
+// message converts a call into the transaction it stands for and the message
+// to execute. Calls are hashed like their transactions, so that their logs are
+// told apart.
+func (sim *simulator) message(call *SimCall, header *types.Header, gp *core.GasPool) (*types.Transaction, types.Message, error) {
+	config := sim.b.ChainConfig()
+	switch {
+	case len(call.Raw) > 0:
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(call.Raw); err != nil {
+			return nil, types.Message{}, err
+		}
+		msg, err := tx.AsMessage(types.MakeSigner(config, header.Number), header.BaseFee)
+		return tx, msg, err
+
+	case call.X402 != nil:
+		payload, err := call.X402.payload()
+		if err != nil {
+			return nil, types.Message{}, err
+		}
+		gas, err := core.IntrinsicGas(payload, nil, false, true, config.IsIstanbul(header.Number))
+		if err != nil {
+			return nil, types.Message{}, err
+		}
+		if call.X402.Asset != (common.Address{}) {
+			gas += core.X402TokenCallGas
+		}
+		if call.Gas != nil {
+			gas = uint64(*call.Gas)
+		}
+		nonce := sim.state.GetNonce(call.from())
+		if call.Nonce != nil {
+			nonce = uint64(*call.Nonce)
+		}
+		registry := types.X402RegistryAddress
+		tx := types.NewX402Tx(config.ChainID, nonce, &registry, gas, payload)
+		msg := types.NewMessage(call.from(), tx.To(), nonce, new(big.Int), gas, new(big.Int), new(big.Int), new(big.Int), payload, nil, true)
+		return tx, msg, nil
+	}
+	args := call.TransactionArgs
+	if args.Gas == nil {
+		gas := hexutil.Uint64(gp.Gas())
+		args.Gas = &gas
+	}
+	msg, err := args.ToMessage(sim.budget, header.BaseFee)
+	if err != nil {
+		return nil, types.Message{}, err
+	}
+	nonce := sim.state.GetNonce(msg.From())
+	if args.Nonce != nil {
+		nonce = uint64(*args.Nonce)
+	}
+	msg = types.NewMessage(msg.From(), msg.To(), nonce, msg.Value(), msg.Gas(), msg.GasPrice(), msg.GasFeeCap(), msg.GasTipCap(), msg.Data(), msg.AccessList(), !sim.validate)
+
+	gas, txNonce := hexutil.Uint64(msg.Gas()), hexutil.Uint64(nonce)
+	args.Gas, args.Nonce = &gas, &txNonce
+	return args.toTransaction(), msg, nil
+}
+
+// execute runs the calls of a block in order, filling in the gas used and the
+// state root of the header. Calls failing in the EVM are reported in their
+// result, while calls which can't be included in a block fail the simulation.
+// x402 envelopes are settled natively, like in blocks.
+func (sim *simulator) execute(ctx context.Context, header *types.Header, calls []SimCall) ([]*SimCallResult, error) {
+	var (
+		config  = sim.b.ChainConfig()
+		gp      = new(core.GasPool).AddGas(header.GasLimit)
+		results = make([]*SimCallResult, len(calls))
+	)
+	for i := range calls {
+		if sim.capped && sim.budget == 0 {
+			return nil, fmt.Errorf("call %d: gas cap of %d exhausted", i, sim.b.RPCGasCap())
+		}
+		tx, msg, err := sim.message(&calls[i], header, gp)
+		if err != nil {
+			return nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		if sim.capped && msg.Gas() > sim.budget {
+			return nil, fmt.Errorf("call %d: gas limit %d above the remaining gas cap %d", i, msg.Gas(), sim.budget)
+		}
+		hash := tx.Hash()
+		sim.state.Prepare(hash, i)
+
+		// The EVM is created for the base block, to keep its consensus context,
+		// and moved over to the simulated block.
+		evm, vmError, err := sim.b.GetEVM(ctx, msg, sim.state, sim.base, &vm.Config{NoBaseFee: !sim.validate})
+		if err != nil {
+			return nil, err
+		}
+		evm.Context.Coinbase = header.Coinbase
+		evm.Context.BlockNumber = new(big.Int).Set(header.Number)
+		evm.Context.Time = new(big.Int).SetUint64(header.Time)
+		evm.Context.Difficulty = new(big.Int).Set(header.Difficulty)
+		evm.Context.GasLimit = header.GasLimit
+		evm.Context.BaseFee = nil
+		if header.BaseFee != nil {
+			evm.Context.BaseFee = new(big.Int).Set(header.BaseFee)
+
+			// Calls without fees pay no tip either, rather than a negative one
+			if !sim.validate && msg.GasFeeCap().BitLen() == 0 && msg.GasTipCap().BitLen() == 0 {
+				evm.Context.BaseFee = new(big.Int)
+			}
+		}
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				evm.Cancel()
+			case <-done:
+			}
+		}()
+		var (
+			result    *core.ExecutionResult
+			settleErr error
+		)
+		if tx.Type() == types.X402TxType {
+			// Raw envelopes were signed for the state they are checked against
+			checkNonce := sim.validate || len(calls[i].Raw) > 0
+			result = new(core.ExecutionResult)
+			result.UsedGas, settleErr, err = core.ApplyX402Envelope(evm, gp, sim.state, msg.From(), tx, checkNonce)
+		} else {
+			result, err = core.ApplyMessage(evm, msg, gp)
+		}
+		close(done)
+
+		if err := vmError(); err != nil {
+			return nil, err
+		}
+		if evm.Cancelled() {
+			return nil, fmt.Errorf("execution aborted (timeout = %v)", sim.timeout)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("call %d: %w (supplied gas %d)", i, err, msg.Gas())
+		}
+		sim.state.Finalise(config.IsEIP158(header.Number))
+
+		header.GasUsed += result.UsedGas
+		if sim.capped {
+			sim.budget -= result.UsedGas
+		}
+		results[i] = newSimCallResult(result, sim.state.GetLogs(hash, common.Hash{}))
+		if settleErr != nil {
+			results[i].Status = hexutil.Uint64(types.ReceiptStatusFailed)
+			results[i].Error = &SimCallError{Code: errCodeVMError, Message: settleErr.Error()}
+		}
+	}
+	header.Root = sim.state.IntermediateRoot(config.IsEIP158(header.Number))
+
+	hash := header.Hash()
+	for _, result := range results {
+		for _, l := range result.Logs {
+			l.BlockHash = hash
+			l.BlockNumber = header.Number.Uint64()
+		}
+	}
+	return results, nil
+}
+
+// newSimCallResult converts the outcome of a call into its RPC representation.
+func newSimCallResult(result *core.ExecutionResult, logs []*types.Log) *SimCallResult {
+	if logs == nil {
+		logs = []*types.Log{}
+	}
+	call := &SimCallResult{
+		ReturnValue: result.Return(),
+		Logs:        logs,
+		GasUsed:     hexutil.Uint64(result.UsedGas),
+		Status:      hexutil.Uint64(types.ReceiptStatusSuccessful),
+	}
+	if result.Failed() {
+		call.Status = hexutil.Uint64(types.ReceiptStatusFailed)
+		if errors.Is(result.Err, vm.ErrExecutionReverted) {
+			revert := newRevertError(result)
+			call.ReturnValue = result.Revert()
+			call.Error = &SimCallError{Code: revert.ErrorCode(), Message: revert.Error(), Data: revert.reason}
+		} else {
+			call.Error = &SimCallError{Code: errCodeVMError, Message: result.Err.Error()}
+		}
+	}
+	return call
+}
+
+// withTimeout bounds the context of a simulation by the RPC EVM timeout.
+func (sim *simulator) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if sim.timeout > 0 {
+		return context.WithTimeout(ctx, sim.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// SimulateV1 executes a series of calls over one or more simulated blocks on
+// top of the given block. Each call sees the state left by the previous ones,
+// and each block the state left by the previous blocks. Blocks may override
+// their header fields and the state they start from.
+//
+// Unless validation is requested, calls are executed like eth_call: nonces,
+// balances against fees and the base fee are not checked. Calls may also carry
+// raw signed transactions or x402 payments, and x402 envelopes are settled
+// natively like in blocks, without any fee.
+func (s *PublicBlockChainAPI) SimulateV1(ctx context.Context, opts SimOpts, blockNrOrHash *rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	if len(opts.BlockStateCalls) == 0 {
+		return nil, errors.New("empty simulation")
+	}
+	if len(opts.BlockStateCalls) > maxSimulateBlocks {
+		return nil, fmt.Errorf("too many blocks: %d > %d", len(opts.BlockStateCalls), maxSimulateBlocks)
+	}
+	var calls int
+	for _, block := range opts.BlockStateCalls {
+		calls += len(block.Calls)
+	}
+	if calls > maxSimulateCalls {
+		return nil, fmt.Errorf("too many calls: %d > %d", calls, maxSimulateCalls)
+	}
+	if blockNrOrHash == nil {
+		latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		blockNrOrHash = &latest
+	}
+	defer func(start time.Time) { log.Debug("Executing EVM simulation finished", "runtime", time.Since(start)) }(time.Now())
+
+	sim, err := newSimulator(ctx, s.b, *blockNrOrHash, opts.Validation)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := sim.withTimeout(ctx)
+	defer cancel()
+
+	var (
+		parent = sim.base
+		blocks = make([]map[string]interface{}, 0, len(opts.BlockStateCalls))
+	)
+	for i, block := range opts.BlockStateCalls {
+		header, err := sim.header(parent, block.BlockOverrides)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		if err := block.StateOverrides.Apply(sim.state); err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		results, err := sim.execute(ctx, header, block.Calls)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		fields := RPCMarshalHeader(header)
+		fields["calls"] = results
+		blocks = append(blocks, fields)
+
+		parent = header
+	}
+	return blocks, nil
+}
+
+// CallMany executes a bundle of calls in order on top of the given block, each
+// call seeing the state left by the previous ones. Like eth_call, the calls run
+// in the context of the given block itself, with its header fields optionally
+// overridden.
+func (s *PublicBlockChainAPI) CallMany(ctx context.Context, calls []SimCall, blockNrOrHash *rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides) ([]*SimCallResult, error) {
+	if len(calls) > maxSimulateCalls {
+		return nil, fmt.Errorf("too many calls: %d > %d", len(calls), maxSimulateCalls)
+	}
+	if blockNrOrHash == nil {
+		latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		blockNrOrHash = &latest
+	}
+	defer func(start time.Time) { log.Debug("Executing EVM call bundle finished", "runtime", time.Since(start)) }(time.Now())
+
+	sim, err := newSimulator(ctx, s.b, *blockNrOrHash, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := overrides.Apply(sim.state); err != nil {
+		return nil, err
+	}
+	ctx, cancel := sim.withTimeout(ctx)
+	defer cancel()
+
+	header := types.CopyHeader(sim.base)
+	header.Coinbase, header.GasUsed = sim.coinbase, 0
+	blockOverrides.Apply(header)
+
+	return sim.execute(ctx, header, calls)
+}
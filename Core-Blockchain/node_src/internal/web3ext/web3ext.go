@@ -117,6 +117,23 @@ web3._extend({
 			call: 'congress_getValidatorsAtHash',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'getDoubleSignEvidence',
+			call: 'congress_getDoubleSignEvidence',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'getPQKeys',
+			call: 'congress_getPQKeys',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getFeeShares',
+			call: 'congress_getFeeShares',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 	]
 });
 `
@@ -619,6 +636,36 @@ web3._extend({
 			call: 'eth_getSysTransactionsByBlockHash',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'getBlockReceipts',
+			call: 'eth_getBlockReceipts',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getBlockTransactions',
+			call: 'eth_getBlockTransactions',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.utils.toHex, web3._extend.utils.toHex]
+		}),
+		new web3._extend.Method({
+			name: 'getBlockReceiptsPage',
+			call: 'eth_getBlockReceiptsPage',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.utils.toHex, web3._extend.utils.toHex]
+		}),
+		new web3._extend.Method({
+			name: 'simulateV1',
+			call: 'eth_simulateV1',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'callMany',
+			call: 'eth_callMany',
+			params: 4,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter, null, null]
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -71,6 +71,10 @@ func (b *LesApiBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumb
 	if number == rpc.LatestBlockNumber {
 		return b.eth.blockchain.CurrentHeader(), nil
 	}
+	// The light client doesn't follow the finality votes
+	if number == rpc.FinalizedBlockNumber || number == rpc.SafeBlockNumber {
+		return nil, errors.New("finalized and safe blocks not available on light clients")
+	}
 	return b.eth.blockchain.GetHeaderByNumberOdr(ctx, uint64(number))
 }
 
@@ -387,6 +387,9 @@ func (pool *TxPool) validateTx(ctx context.Context, tx *types.Transaction) error
 	if err != nil {
 		return err
 	}
+	if gas, err = core.PQIntrinsicGas(gas, tx.PQSignatureSize()); err != nil {
+		return err
+	}
 	if tx.Gas() < gas {
 		return core.ErrIntrinsicGas
 	}
@@ -53,6 +53,7 @@ type Config struct {
 	GasPrice   *big.Int       // Minimum gas price for mining a transaction
 	Recommit   time.Duration  // The time interval for miner to re-create mining work.
 	Noverify   bool           // Disable remote mining solution verification(only useful in ethash).
+	PQKeyFile  string         `toml:",omitempty"` // Comma separated ML-DSA key files for sealing after the PQT fork (only useful in congress).
 }
 
 // Miner creates blocks and searches for proof-of-work values.
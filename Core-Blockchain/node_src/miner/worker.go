@@ -20,6 +20,7 @@ package miner
 import (
 	"bytes"
 	"errors"
+	"math"
 	"math/big"
 	"runtime"
 	"strconv"
@@ -38,6 +39,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/common/gpu"
 	"github.com/ethereum/go-ethereum/common/hybrid"
 	"github.com/ethereum/go-ethereum/common/ai"
 )
@@ -146,11 +148,15 @@ type worker struct {
 	aiLoadBalancer     *ai.AILoadBalancer
 	gpuEnabled         bool
 	batchThreshold     int
+	batchLock          sync.Mutex // Protects the last batch metrics, updated while mu is read locked
 	lastBatchSize      int
 	lastBatchTime      time.Duration
 	adaptiveBatching   bool
 	aiOptimization     bool
 
+	hybridThroughputTarget uint64              // TPS the batch sizing aims at, defaults to the hybrid processor's target
+	hybridStatsOverride    *hybrid.HybridStats // Stats used instead of the hybrid processor's, for tests
+
 	// Feeds
 	pendingLogsFeed event.Feed
 
@@ -247,6 +253,7 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 	hybridProcessor := hybrid.GetGlobalHybridProcessor()
 	if hybridProcessor != nil {
 		worker.hybridProcessor = hybridProcessor
+		worker.hybridThroughputTarget = hybridProcessor.ThroughputTarget()
 		// Check if GPU is enabled by looking at the hybrid processor's configuration
 		stats := hybridProcessor.GetStats()
 		worker.gpuEnabled = stats.GPUProcessed > 0 || stats.GPUUtilization >= 0
@@ -866,6 +873,79 @@ func (w *worker) commitTransaction(tx *types.Transaction, coinbase common.Addres
 	return receipt.Logs, nil
 }
 
+// commitTransactionsParallel orders the pending transactions the same way as
+// commitTransactions and executes them with the parallel state processor. The
+// transactions failing consensus validation or execution are left out.
+func (w *worker) commitTransactionsParallel(pending map[common.Address]types.Transactions, coinbase common.Address) {
+	if w.current.gasPool == nil {
+		w.current.gasPool = new(core.GasPool).AddGas(w.current.header.GasLimit)
+	}
+	// Split the pending transactions into locals and remotes
+	localTxs, remoteTxs := make(map[common.Address]types.Transactions), pending
+	for _, account := range w.eth.TxPool().Locals() {
+		if txs := remoteTxs[account]; len(txs) > 0 {
+			delete(remoteTxs, account)
+			localTxs[account] = txs
+		}
+	}
+	var ordered []*types.Transaction
+	for _, group := range []map[common.Address]types.Transactions{localTxs, remoteTxs} {
+		if len(group) == 0 {
+			continue
+		}
+		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, group, w.current.header.BaseFee)
+		for tx := txs.Peek(); tx != nil; tx = txs.Peek() {
+			// Check whether the tx is replay protected. If we're not in the EIP155 hf
+			// phase, start ignoring the sender until we do.
+			if tx.Protected() && !w.chainConfig.IsEIP155(w.current.header.Number) {
+				txs.Pop()
+				continue
+			}
+			ordered = append(ordered, tx)
+			txs.Shift()
+		}
+	}
+	// consensus related validation
+	var validate func(common.Address, *types.Transaction) error
+	if w.isPoSA {
+		validate = func(from common.Address, tx *types.Transaction) error {
+			return w.posa.ValidateTx(from, tx, w.current.header, w.current.state)
+		}
+	}
+	start := time.Now()
+	txs, receipts := w.parallelProcessor.ApplyTransactions(w.current.header, &coinbase, w.current.gasPool, w.current.state, ordered,
+		w.current.tcount, &w.current.header.GasUsed, *w.chain.GetVMConfig(), w.current.extraValidator, validate)
+
+	w.current.txs = append(w.current.txs, txs...)
+	w.current.receipts = append(w.current.receipts, receipts...)
+	w.current.tcount += len(txs)
+
+	duration := time.Since(start)
+	log.Info("Parallel transaction batch committed",
+		"candidates", len(ordered),
+		"included", len(txs),
+		"gasUsed", w.current.header.GasUsed,
+		"duration", duration,
+		"tps", float64(len(txs))/duration.Seconds())
+}
+
+// txPrevalidator returns the prevalidation context of the transactions to be
+// applied on top of the current pending state.
+func (w *worker) txPrevalidator() *gpu.TxPrevalidator {
+	validator := &gpu.TxPrevalidator{
+		Signer:  w.current.signer,
+		Rules:   w.chainConfig.Rules(w.current.header.Number),
+		BaseFee: w.current.header.BaseFee,
+		State:   w.current.state,
+	}
+	if w.isPoSA {
+		validator.ValidateTx = func(sender common.Address, tx *types.Transaction) error {
+			return w.posa.ValidateTx(sender, tx, w.current.header, w.current.state)
+		}
+	}
+	return validator
+}
+
 func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coinbase common.Address, interrupt *int32) bool {
 	// Short circuit if current is nil
 	if w.current == nil {
@@ -884,72 +964,59 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 		// Calculate optimal batch size based on performance
 		optimalBatchSize := w.calculateOptimalBatchSize()
 		
-		// Collect transactions into a batch for potential GPU processing
+		// Collect up to optimal batch size transactions for prevalidation
 		var txBatch []*types.Transaction
-		tempTxs := txs
-		
-		// Collect up to optimal batch size transactions for GPU processing
 		for len(txBatch) < optimalBatchSize {
-			tx := tempTxs.Peek()
+			tx := txs.Peek()
 			if tx == nil {
 				break
 			}
-			
-			// Basic validation before adding to batch
-			from, _ := types.Sender(w.current.signer, tx)
-			if tx.Protected() && !w.chainConfig.IsEIP155(w.current.header.Number) {
-				tempTxs.Pop()
-				continue
-			}
-			if w.isPoSA {
-				if err := w.posa.ValidateTx(from, tx, w.current.header, w.current.state); err != nil {
-					tempTxs.Pop()
-					continue
-				}
-			}
-			
 			txBatch = append(txBatch, tx)
-			tempTxs.Shift()
+			txs.Shift()
 		}
-		
-		// Process batch with GPU if we have enough transactions
+		batchStart := time.Now()
+		validator := w.txPrevalidator()
+
+		// Prevalidate the batch with GPU acceleration if we have enough transactions,
+		// small batches and failed ones are checked right here
+		var results []*hybrid.TransactionResult
 		if len(txBatch) >= w.batchThreshold/2 { // Use GPU for batches >= 500 transactions (1000/2)
-			batchStart := time.Now()
 			log.Debug("Processing transaction batch with GPU acceleration", "batchSize", len(txBatch))
-			
-			// Use hybrid processor for GPU-accelerated prevalidation
-			err := w.hybridProcessor.ProcessTransactionsBatch(txBatch, func(results []*hybrid.TransactionResult, err error) {
+			err := w.hybridProcessor.ProcessTransactionsBatch(txBatch, validator, func(batchResults []*hybrid.TransactionResult, err error) {
 				if err != nil {
-					log.Warn("GPU batch processing failed, falling back to sequential", "error", err)
+					log.Warn("GPU batch processing failed, falling back to CPU prevalidation", "error", err)
 					return
 				}
-				
-				// Apply GPU-validated transactions sequentially (EVM execution still needs to be sequential for state consistency)
-				for i, result := range results {
-					if result.Valid && i < len(txBatch) {
-						// GPU validated the transaction, now apply it to state
-						w.current.state.Prepare(txBatch[i].Hash(), w.current.tcount)
-						logs, err := w.commitTransaction(txBatch[i], coinbase)
-						if err == nil {
-							coalescedLogs = append(coalescedLogs, logs...)
-							w.current.tcount++
-						}
-					}
-				}
+				results = batchResults
 			})
-			
-			// Update batch performance metrics
-			batchDuration := time.Since(batchStart)
-			w.updateBatchPerformance(len(txBatch), batchDuration)
-			
 			if err != nil {
-				log.Warn("Failed to submit GPU batch, falling back to sequential processing", "error", err)
-			} else {
-				// GPU batch processing completed, continue with remaining transactions sequentially
-				txs = tempTxs
-				log.Debug("GPU batch processing completed", "batchSize", len(txBatch), "duration", batchDuration)
+				log.Warn("Failed to submit GPU batch, falling back to CPU prevalidation", "error", err)
+			}
+		}
+		if results == nil {
+			results = hybrid.NewTransactionResults(validator.Prevalidate(txBatch))
+		}
+		// Apply the prevalidated transactions sequentially (EVM execution still needs to be
+		// sequential for state consistency), pruning the invalid ones before they waste EVM time
+		for i, tx := range txBatch {
+			if result := results[i]; !result.Valid {
+				log.Trace("Pruning invalid transaction", "hash", tx.Hash(), "sender", result.Sender, "reason", result.Reason, "err", result.Error)
+				continue
+			}
+			w.current.state.Prepare(tx.Hash(), w.current.tcount)
+			logs, err := w.commitTransaction(tx, coinbase)
+			if err != nil {
+				log.Trace("Prevalidated transaction failed", "hash", tx.Hash(), "err", err)
+				continue
 			}
+			coalescedLogs = append(coalescedLogs, logs...)
+			w.current.tcount++
 		}
+		
+		// Update batch performance metrics
+		batchDuration := time.Since(batchStart)
+		w.updateBatchPerformance(len(txBatch), batchDuration)
+		log.Debug("GPU batch processing completed", "batchSize", len(txBatch), "duration", batchDuration)
 	}
 
 	// Continue with sequential processing for remaining transactions
@@ -1181,72 +1248,15 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 	
 	// Use parallel processor for massive transaction batches (100K+ transactions)
 	if w.parallelProcessor != nil && totalTxCount >= 100000 {
-		log.Info("Using parallel processor for massive transaction batch", 
-			"totalTxs", totalTxCount, 
-			"threshold", 100000,
-			"parallelBatchSize", 100000)
-		
-		// Combine all transactions for parallel processing
-		allTxs := make([]*types.Transaction, 0, totalTxCount)
-		for _, txList := range pending {
-			allTxs = append(allTxs, txList...)
-		}
-		
-		if len(allTxs) > 0 {
-			// Create a temporary block for parallel processing
-			tempBlock := types.NewBlock(
-				w.current.header,
-				allTxs,
-				nil, // no uncles
-				nil, // no receipts yet
-				trie.NewStackTrie(nil),
-			)
-			
-			batchStart := time.Now()
-			
-			// Process ALL transactions with parallel processor
-			receipts, logs, gasUsed, err := w.parallelProcessor.ProcessParallel(
-				tempBlock, 
-				w.current.state, 
-				*w.chain.GetVMConfig(),
-			)
-			
-			batchDuration := time.Since(batchStart)
-			
-			if err != nil {
-				log.Warn("Massive parallel processing failed, falling back to standard processing", 
-					"error", err, 
-					"txCount", len(allTxs))
-				// Fall through to standard processing
-			} else {
-				// Parallel processing succeeded for massive batch
-				log.Info("MASSIVE parallel processing completed successfully", 
-					"txCount", len(allTxs),
-					"gasUsed", gasUsed,
-					"duration", batchDuration,
-					"tps", float64(len(allTxs))/batchDuration.Seconds(),
-					"logCount", len(logs))
-				
-				// Update current state with results
-				w.current.txs = append(w.current.txs, allTxs...)
-				w.current.receipts = append(w.current.receipts, receipts...)
-				w.current.tcount += len(allTxs)
-				w.current.header.GasUsed += gasUsed
-				
-				// Ensure gasPool is initialized before using it
-				if w.current.gasPool == nil {
-					w.current.gasPool = new(core.GasPool).AddGas(w.current.header.GasLimit)
-				}
-				w.current.gasPool.SubGas(gasUsed)
-				
-				// Skip standard processing since parallel processing handled everything
-				// But we still need to commit the block with the processed transactions
-				w.commit(uncles, w.fullTaskHook, true, tstart)
-				return
-			}
-		}
+		log.Info("Using parallel processor for massive transaction batch",
+			"totalTxs", totalTxCount,
+			"threshold", 100000)
+
+		w.commitTransactionsParallel(pending, w.coinbase)
+		w.commit(uncles, w.fullTaskHook, true, tstart)
+		return
 	}
-	
+
 	// Standard processing for smaller batches or if parallel processing failed
 	// Split the pending transactions into locals and remotes
 	localTxs, remoteTxs := make(map[common.Address]types.Transactions), pending
@@ -1328,18 +1338,23 @@ func (w *worker) postSideBlock(event core.ChainSideEvent) {
 
 // calculateOptimalBatchSize determines the optimal batch size for GPU processing based on performance
 func (w *worker) calculateOptimalBatchSize() int {
-	if !w.adaptiveBatching || w.hybridProcessor == nil {
+	stats, ok := w.hybridStats()
+	if !w.adaptiveBatching || !ok {
 		return w.batchThreshold
 	}
 	
-	// Get current hybrid processor stats
-	stats := w.hybridProcessor.GetStats()
-	
 	// Base batch size
 	baseBatchSize := w.batchThreshold
 	
-	// Use AI recommendations if available
-	if w.aiOptimization && w.aiLoadBalancer != nil {
+	// Use the batch size the hybrid processor was tuned to, or else the AI
+	// recommendations if available
+	var tuned int
+	if w.hybridProcessor != nil {
+		tuned = w.hybridProcessor.Tuning().BatchSize
+	}
+	if tuned > 0 {
+		baseBatchSize = tuned
+	} else if w.aiOptimization && w.aiLoadBalancer != nil {
 		aiStats := w.aiLoadBalancer.GetStats()
 		if aiStats.TotalDecisions > 0 && aiStats.LastPrediction.Confidence > 0.7 {
 			// Use AI-recommended strategy to influence batch size
@@ -1367,24 +1382,27 @@ func (w *worker) calculateOptimalBatchSize() int {
 		baseBatchSize = int(float64(baseBatchSize) * 0.8)
 	}
 	
-	// Adjust based on current TPS vs target
-	targetTPS := uint64(100000) // Target 100K TPS for realistic performance
-	if stats.CurrentTPS < targetTPS/2 {
-		// Low TPS, try larger batches
-		baseBatchSize = int(float64(baseBatchSize) * 1.2)
-	} else if stats.CurrentTPS > targetTPS {
-		// High TPS, maintain current batch size
-		// No adjustment needed
-	}
+	// Adjust based on current TPS vs target: batches grow by up to half as the
+	// throughput reached climbs, levelling off towards the target
+	targetTPS := w.hybridThroughputTarget
+	if targetTPS == 0 {
+		targetTPS = 100000 // Target 100K TPS for realistic performance
+	}
+	progress := math.Min(float64(stats.CurrentTPS)/float64(targetTPS), 1)
+	baseBatchSize = int(float64(baseBatchSize) * (1 + progress*(1-progress/2)))
 	
 	// Adjust based on previous batch performance
-	if w.lastBatchTime > 0 && w.lastBatchSize > 0 {
+	w.batchLock.Lock()
+	lastBatchSize, lastBatchTime := w.lastBatchSize, w.lastBatchTime
+	w.batchLock.Unlock()
+
+	if lastBatchTime > 0 && lastBatchSize > 0 {
 		// If last batch was slow, reduce size
-		if w.lastBatchTime > 100*time.Millisecond {
-			baseBatchSize = int(float64(w.lastBatchSize) * 0.9)
-		} else if w.lastBatchTime < 50*time.Millisecond {
+		if lastBatchTime > 100*time.Millisecond {
+			baseBatchSize = int(float64(lastBatchSize) * 0.9)
+		} else if lastBatchTime < 50*time.Millisecond {
 			// If last batch was fast, increase size
-			baseBatchSize = int(float64(w.lastBatchSize) * 1.1)
+			baseBatchSize = int(float64(lastBatchSize) * 1.1)
 		}
 	}
 	
@@ -1401,10 +1419,21 @@ func (w *worker) calculateOptimalBatchSize() int {
 	return baseBatchSize
 }
 
+// hybridStats returns the stats of the hybrid processor, if there is one.
+func (w *worker) hybridStats() (hybrid.HybridStats, bool) {
+	if w.hybridStatsOverride != nil {
+		return *w.hybridStatsOverride, true
+	}
+	if w.hybridProcessor == nil {
+		return hybrid.HybridStats{}, false
+	}
+	return w.hybridProcessor.GetStats(), true
+}
+
 // updateBatchPerformance updates batch performance metrics for adaptive sizing
 func (w *worker) updateBatchPerformance(batchSize int, duration time.Duration) {
-	w.mu.Lock()
-	defer w.mu.Unlock()
+	w.batchLock.Lock()
+	defer w.batchLock.Unlock()
 	
 	w.lastBatchSize = batchSize
 	w.lastBatchTime = duration
@@ -1425,11 +1454,15 @@ func (w *worker) GetMinerStats() map[string]interface{} {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
 	
+	w.batchLock.Lock()
+	lastBatchSize, lastBatchTime := w.lastBatchSize, w.lastBatchTime
+	w.batchLock.Unlock()
+
 	stats := map[string]interface{}{
 		"gpu_enabled":      w.gpuEnabled,
 		"batch_threshold":  w.batchThreshold,
-		"last_batch_size":  w.lastBatchSize,
-		"last_batch_time":  w.lastBatchTime,
+		"last_batch_size":  lastBatchSize,
+		"last_batch_time":  lastBatchTime,
 		"adaptive_batching": w.adaptiveBatching,
 	}
 	
@@ -1,15 +1,22 @@
 package miner
 
 import (
+	"math/big"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/gopool"
+	"github.com/ethereum/go-ethereum/common/gpu"
 	"github.com/ethereum/go-ethereum/common/hybrid"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 func TestCalculateOptimalBatchSizeIncreasesTowardsTarget(t *testing.T) {
@@ -110,3 +117,139 @@ func TestCommitTransactionsProcessesUnderThresholdBatch(t *testing.T) {
 		t.Fatalf("missing %d transactions from block", len(expected))
 	}
 }
+
+func TestCommitTransactionsAcceleratedBatch(t *testing.T) {
+	for _, fault := range []gpu.Fault{gpu.FaultNone, gpu.FaultError, gpu.FaultPanic, gpu.FaultReject} {
+		t.Run(fault.String(), func(t *testing.T) {
+			testCommitTransactionsAcceleratedBatch(t, fault)
+		})
+	}
+}
+
+func testCommitTransactionsAcceleratedBatch(t *testing.T, fault gpu.Fault) {
+	engine := ethash.NewFaker()
+	defer engine.Close()
+
+	chainConfig := new(params.ChainConfig)
+	*chainConfig = *params.AllEthashProtocolChanges
+
+	w, backend := newTestWorker(t, chainConfig, engine, rawdb.NewMemoryDatabase(), 0)
+	defer backend.chain.Stop()
+	defer w.close()
+
+	for i := 0; i < 10; i++ {
+		if err := backend.txPool.AddLocal(backend.newRandomTx(false)); err != nil {
+			t.Fatalf("failed to add local transaction: %v", err)
+		}
+	}
+	pending, _ := backend.txPool.Stats()
+	accel := enableAcceleratedBatching(t, w, "miner-test-"+fault.String(), fault)
+
+	w.commitNewWork(nil, false, time.Now().Unix())
+
+	if calls := accel.Calls(gpu.KernelTransactions); calls == 0 {
+		t.Fatal("transaction kernel not run")
+	}
+	// Failing kernels fall back to the CPU, rejected transactions are pruned
+	// and leave a nonce gap for the rest of the sender's batch
+	want := pending
+	if fault == gpu.FaultReject {
+		want = 0
+	}
+	if len(w.current.txs) != want {
+		t.Fatalf("have %d transactions in block, want %d", len(w.current.txs), want)
+	}
+}
+
+// enableAcceleratedBatching makes the worker prevalidate the whole pending set
+// as one batch, split between the CPU and a software accelerator registered
+// under the given name.
+func enableAcceleratedBatching(t *testing.T, w *worker, name string, fault gpu.Fault) *gpu.SoftwareAccelerator {
+	accel := gpu.NewSoftwareAccelerator(gpu.GPUTypeCUDA, 1)
+	accel.InjectFault(gpu.KernelTransactions, fault)
+	gpu.RegisterAccelerator(name, func(*gpu.GPUConfig) gpu.Accelerator { return accel })
+
+	processor, err := hybrid.NewHybridProcessor(&hybrid.HybridConfig{
+		CPUConfig: &gopool.ProcessorConfig{MaxWorkers: 2, QueueSize: 16, TxWorkers: 2, ValidationWorkers: 1,
+			ConsensusWorkers: 1, StateWorkers: 1, NetworkWorkers: 1},
+		GPUConfig:    &gpu.GPUConfig{Accelerator: name, MaxBatchSize: 64, TxWorkers: 1},
+		EnableGPU:    true,
+		GPUThreshold: 1,
+		CPUGPURatio:  0.5,
+	})
+	if err != nil {
+		t.Fatalf("failed to create hybrid processor: %v", err)
+	}
+	t.Cleanup(func() { processor.Close() })
+
+	w.gpuEnabled = true
+	w.hybridProcessor = processor
+	w.adaptiveBatching = false
+	w.batchThreshold = 16
+	return accel
+}
+
+// Tests that fee-free x402 envelopes, submitted by an unfunded facilitator, pass
+// the accelerated prevalidation and get settled in the block.
+func TestCommitTransactionsAcceleratedX402(t *testing.T) {
+	engine := ethash.NewFaker()
+	defer engine.Close()
+
+	chainConfig := new(params.ChainConfig)
+	*chainConfig = *params.AllEthashProtocolChanges
+
+	w, backend := newTestWorker(t, chainConfig, engine, rawdb.NewMemoryDatabase(), 0)
+	defer backend.chain.Stop()
+	defer w.close()
+
+	for i := 0; i < 10; i++ {
+		if err := backend.txPool.AddLocal(backend.newRandomTx(false)); err != nil {
+			t.Fatalf("failed to add local transaction: %v", err)
+		}
+	}
+	payment := &types.X402Payload{From: testBankAddress, To: testUserAddress, Value: big.NewInt(1000),
+		ValidBefore: ^uint64(0), Nonce: common.HexToHash("0x01")}
+	sig, err := crypto.Sign(payment.SigHash(chainConfig.ChainID).Bytes(), testBankKey)
+	if err != nil {
+		t.Fatalf("failed to sign payment: %v", err)
+	}
+	payment.Signature = sig
+	payload, err := rlp.EncodeToBytes(payment)
+	if err != nil {
+		t.Fatalf("failed to encode payment: %v", err)
+	}
+	gas, err := core.IntrinsicGas(payload, nil, false, true, true)
+	if err != nil {
+		t.Fatalf("failed to compute intrinsic gas: %v", err)
+	}
+	facilitator, _ := crypto.GenerateKey()
+	registry := types.X402RegistryAddress
+	envelope, err := types.SignTx(types.NewX402Tx(chainConfig.ChainID, 0, &registry, gas, payload), types.LatestSigner(chainConfig), facilitator)
+	if err != nil {
+		t.Fatalf("failed to sign envelope: %v", err)
+	}
+	if err := backend.txPool.AddLocal(envelope); err != nil {
+		t.Fatalf("failed to add x402 envelope: %v", err)
+	}
+	pending, _ := backend.txPool.Stats()
+	accel := enableAcceleratedBatching(t, w, "miner-test-x402", gpu.FaultNone)
+
+	w.commitNewWork(nil, false, time.Now().Unix())
+
+	if calls := accel.Calls(gpu.KernelTransactions); calls == 0 {
+		t.Fatal("transaction kernel not run")
+	}
+	if len(w.current.txs) != pending {
+		t.Fatalf("have %d transactions in block, want %d", len(w.current.txs), pending)
+	}
+	for i, tx := range w.current.txs {
+		if tx.Hash() != envelope.Hash() {
+			continue
+		}
+		if receipt := w.current.receipts[i]; receipt.Status != types.ReceiptStatusSuccessful {
+			t.Fatalf("x402 settlement failed")
+		}
+		return
+	}
+	t.Fatal("x402 envelope missing from block")
+}
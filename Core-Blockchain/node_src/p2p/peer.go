@@ -501,6 +501,7 @@ type PeerInfo struct {
 		Inbound       bool   `json:"inbound"`
 		Trusted       bool   `json:"trusted"`
 		Static        bool   `json:"static"`
+		HybridKEM     bool   `json:"hybridKEM"` // Session keys involve ML-KEM
 	} `json:"network"`
 	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
 }
@@ -528,6 +529,7 @@ func (p *Peer) Info() *PeerInfo {
 	info.Network.Inbound = p.rw.is(inboundConn)
 	info.Network.Trusted = p.rw.is(trustedConn)
 	info.Network.Static = p.rw.is(staticDialedConn)
+	info.Network.HybridKEM = p.rw.is(hybridKEMConn)
 
 	// Gather all the running protocol infos
 	for _, proto := range p.running {
@@ -37,6 +37,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/crypto/ecies"
+	"github.com/ethereum/go-ethereum/crypto/mlkem"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/golang/snappy"
 	"golang.org/x/crypto/sha3"
@@ -53,6 +54,11 @@ type Conn struct {
 	conn     net.Conn
 	session  *sessionState
 
+	// Hybrid ML-KEM handshake settings, and whether the session uses it.
+	hybridKEM  bool
+	requireKEM bool
+	usedKEM    bool
+
 	// These are the buffers for snappy compression.
 	// Compression is enabled if they are non-nil.
 	snappyReadBuffer  []byte
@@ -112,6 +118,21 @@ func (c *Conn) SetSnappy(snappy bool) {
 	}
 }
 
+// EnableHybridKEM enables the hybrid handshake, which mixes an ML-KEM-768 shared
+// secret into the session keys, keeping them secret from an adversary recording
+// the traffic for a quantum computer. The initiator offers an ephemeral ML-KEM
+// key in its auth message, which the recipient answers with a ciphertext. Peers
+// which don't answer get the classic handshake, unless require is set, which
+// fails it instead. This must be called before the handshake.
+func (c *Conn) EnableHybridKEM(require bool) {
+	c.hybridKEM, c.requireKEM = true, require
+}
+
+// HybridKEM reports whether the session keys of the handshake involve ML-KEM.
+func (c *Conn) HybridKEM() bool {
+	return c.usedKEM
+}
+
 // SetReadDeadline sets the deadline for all future read operations.
 func (c *Conn) SetReadDeadline(time time.Time) error {
 	return c.conn.SetReadDeadline(time)
@@ -302,7 +323,7 @@ func (c *Conn) Handshake(prv *ecdsa.PrivateKey) (*ecdsa.PublicKey, error) {
 	var (
 		sec Secrets
 		err error
-		h   handshakeState
+		h   = handshakeState{hybridKEM: c.hybridKEM, requireKEM: c.requireKEM}
 	)
 	if c.dialDest != nil {
 		sec, err = h.runInitiator(c.conn, prv, c.dialDest)
@@ -315,6 +336,7 @@ func (c *Conn) Handshake(prv *ecdsa.PrivateKey) (*ecdsa.PublicKey, error) {
 	c.InitWithSecrets(sec)
 	c.session.rbuf = h.rbuf
 	c.session.wbuf = h.wbuf
+	c.usedKEM = h.kemSecret != nil
 	return sec.remote, err
 }
 
@@ -356,6 +378,9 @@ const (
 	shaLen = 32                     // hash length (for nonce etc)
 
 	eciesOverhead = 65 /* pubkey */ + 16 /* IV */ + 32 /* MAC */
+
+	kemAlgorithm = mlkem.MLKEM768 // KEM of the hybrid handshake
+	kemExtName   = "ml-kem-768"   // name of the hybrid handshake field
 )
 
 var (
@@ -366,6 +391,10 @@ var (
 	// errPlainMessageTooLarge is returned if a decompressed message length exceeds
 	// the allowed 24 bits (i.e. length >= 16MB).
 	errPlainMessageTooLarge = errors.New("message length >= 16MB")
+
+	// errHybridKEMRequired is returned by the initiator if the recipient didn't
+	// answer its ML-KEM key while the hybrid handshake is required.
+	errHybridKEMRequired = errors.New("peer doesn't support the hybrid ML-KEM handshake")
 )
 
 // Secrets represents the connection secrets which are negotiated during the handshake.
@@ -383,6 +412,11 @@ type handshakeState struct {
 	randomPrivKey        *ecies.PrivateKey // ecdhe-random
 	remoteRandomPub      *ecies.PublicKey  // ecdhe-random-pubk
 
+	hybridKEM, requireKEM bool   // hybrid handshake settings
+	kemPrivKey            []byte // initiator's ephemeral ML-KEM secret key
+	remoteKEMPub          []byte // initiator's ephemeral ML-KEM key
+	kemSecret             []byte // ML-KEM shared secret, nil in classic handshakes
+
 	rbuf readBuffer
 	wbuf writeBuffer
 }
@@ -408,6 +442,36 @@ type authRespV4 struct {
 	Rest []rlp.RawValue `rlp:"tail"`
 }
 
+// kemExtension is the additional auth and ack field of the hybrid handshake. It
+// carries the initiator's ephemeral ML-KEM key in the auth message, and the
+// recipient's ciphertext in the ack. Older peers ignore it, like any additional
+// field, and it is told apart from other fields by its name.
+type kemExtension struct {
+	Name string
+	Data []byte
+}
+
+// appendKEMExtension appends the hybrid handshake field to additional fields.
+func appendKEMExtension(rest []rlp.RawValue, data []byte) ([]rlp.RawValue, error) {
+	enc, err := rlp.EncodeToBytes(&kemExtension{Name: kemExtName, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	return append(rest, enc), nil
+}
+
+// findKEMExtension returns the data of the hybrid handshake field among
+// additional fields, or nil if there is none.
+func findKEMExtension(rest []rlp.RawValue) []byte {
+	for _, field := range rest {
+		var ext kemExtension
+		if rlp.DecodeBytes(field, &ext) == nil && ext.Name == kemExtName {
+			return ext.Data
+		}
+	}
+	return nil
+}
+
 // runRecipient negotiates a session token on conn.
 // it should be called on the listening side of the connection.
 //
@@ -445,6 +509,9 @@ func (h *handshakeState) handleAuthMsg(msg *authMsgV4, prv *ecdsa.PrivateKey) er
 	}
 	h.initNonce = msg.Nonce[:]
 	h.remote = rpub
+	if h.hybridKEM {
+		h.remoteKEMPub = findKEMExtension(msg.Rest)
+	}
 
 	// Generate random keypair for ECDH.
 	// If a private key is already set, use it instead of generating one (for testing).
@@ -476,6 +543,11 @@ func (h *handshakeState) secrets(auth, authResp []byte) (Secrets, error) {
 	if err != nil {
 		return Secrets{}, err
 	}
+	// In hybrid handshakes, all secrets derive from both the ECDH and the ML-KEM
+	// shared secrets, so they stay secret unless both are broken.
+	if h.kemSecret != nil {
+		ecdheSecret = crypto.Keccak256(ecdheSecret, h.kemSecret)
+	}
 
 	// derive base secrets from ephemeral key agreement
 	sharedSecret := crypto.Keccak256(ecdheSecret, crypto.Keccak256(h.respNonce, h.initNonce))
@@ -571,12 +643,35 @@ func (h *handshakeState) makeAuthMsg(prv *ecdsa.PrivateKey) (*authMsgV4, error)
 	copy(msg.InitiatorPubkey[:], crypto.FromECDSAPub(&prv.PublicKey)[1:])
 	copy(msg.Nonce[:], h.initNonce)
 	msg.Version = 4
+
+	// Offer the hybrid handshake with an ephemeral ML-KEM key.
+	if h.hybridKEM {
+		var pub []byte
+		if pub, h.kemPrivKey, err = mlkem.GenerateKeyPair(kemAlgorithm); err != nil {
+			return nil, err
+		}
+		if msg.Rest, err = appendKEMExtension(msg.Rest, pub); err != nil {
+			return nil, err
+		}
+	}
 	return msg, nil
 }
 
 func (h *handshakeState) handleAuthResp(msg *authRespV4) (err error) {
 	h.respNonce = msg.Nonce[:]
 	h.remoteRandomPub, err = importPublicKey(msg.RandomPubkey[:])
+	if err != nil {
+		return err
+	}
+	// Recover the ML-KEM shared secret if the recipient accepted the hybrid
+	// handshake, or fall back to the classic one.
+	ciphertext := findKEMExtension(msg.Rest)
+	switch {
+	case h.kemPrivKey != nil && ciphertext != nil:
+		h.kemSecret, err = mlkem.Decapsulate(kemAlgorithm, ciphertext, h.kemPrivKey)
+	case h.requireKEM:
+		err = errHybridKEMRequired
+	}
 	return err
 }
 
@@ -591,6 +686,17 @@ func (h *handshakeState) makeAuthResp() (msg *authRespV4, err error) {
 	copy(msg.Nonce[:], h.respNonce)
 	copy(msg.RandomPubkey[:], exportPubkey(&h.randomPrivKey.PublicKey))
 	msg.Version = 4
+
+	// Accept the hybrid handshake offered by the initiator.
+	if h.remoteKEMPub != nil {
+		var ciphertext []byte
+		if ciphertext, h.kemSecret, err = mlkem.Encapsulate(kemAlgorithm, h.remoteKEMPub); err != nil {
+			return nil, err
+		}
+		if msg.Rest, err = appendKEMExtension(msg.Rest, ciphertext); err != nil {
+			return nil, err
+		}
+	}
 	return msg, nil
 }
 
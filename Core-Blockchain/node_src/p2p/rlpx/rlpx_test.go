@@ -65,6 +65,50 @@ func TestReadWriteMsg(t *testing.T) {
 	checkMsgReadWrite(t, peer1, peer2, testCode, testData)
 }
 
+// This test checks that the hybrid ML-KEM handshake is used when both peers
+// enable it, and that it falls back to the classic handshake otherwise.
+func TestHybridHandshake(t *testing.T) {
+	tests := []struct {
+		dialer, listener, require bool
+		want                      bool
+		fail                      bool
+	}{
+		{dialer: true, listener: true, want: true},
+		{dialer: true, listener: true, require: true, want: true},
+		{dialer: true, listener: false},
+		{dialer: false, listener: true},
+		{dialer: true, listener: false, require: true, fail: true},
+	}
+	for i, test := range tests {
+		conn1, conn2 := net.Pipe()
+		key1, key2 := newkey(), newkey()
+		peer1 := NewConn(conn1, &key2.PublicKey)
+		peer2 := NewConn(conn2, nil)
+		if test.dialer {
+			peer1.EnableHybridKEM(test.require)
+		}
+		if test.listener {
+			peer2.EnableHybridKEM(false)
+		}
+		if test.fail {
+			go peer2.Handshake(key2)
+			if _, err := peer1.Handshake(key1); err != errHybridKEMRequired {
+				t.Errorf("test %d: have error %v, want %v", i, err, errHybridKEMRequired)
+			}
+			peer1.Close()
+			peer2.Close()
+			continue
+		}
+		doHandshake(t, peer1, peer2, key1, key2)
+		if peer1.HybridKEM() != test.want || peer2.HybridKEM() != test.want {
+			t.Errorf("test %d: have hybrid %t/%t, want %t", i, peer1.HybridKEM(), peer2.HybridKEM(), test.want)
+		}
+		checkMsgReadWrite(t, peer1, peer2, 23, []byte("test"))
+		peer1.Close()
+		peer2.Close()
+	}
+}
+
 func checkMsgReadWrite(t *testing.T, p1, p2 *Conn, msgCode uint64, msgData []byte) {
 	// Set up the reader.
 	ch := make(chan message, 1)
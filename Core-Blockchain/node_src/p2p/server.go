@@ -153,6 +153,12 @@ type Config struct {
 	// whenever a message is sent to or received from a peer
 	EnableMsgEvents bool
 
+	// HybridKEM enables the hybrid ML-KEM + ECDH RLPx handshake, which keeps the
+	// session keys secret from an adversary recording the traffic for a quantum
+	// computer. It is advertised in the local node record and required with the
+	// peers advertising it, other peers get the classic handshake.
+	HybridKEM bool `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 
@@ -215,6 +221,7 @@ const (
 	staticDialedConn
 	inboundConn
 	trustedConn
+	hybridKEMConn
 )
 
 // conn wraps a network connection with information gathered
@@ -266,6 +273,9 @@ func (f connFlag) String() string {
 	if f&inboundConn != 0 {
 		s += "-inbound"
 	}
+	if f&hybridKEMConn != 0 {
+		s += "-pqkem"
+	}
 	if s != "" {
 		s = s[1:]
 	}
@@ -512,6 +522,9 @@ func (srv *Server) setupLocalNode() error {
 			srv.localnode.Set(e)
 		}
 	}
+	if srv.HybridKEM {
+		srv.localnode.Set(pqkemEntry{})
+	}
 	switch srv.NAT.(type) {
 	case nil:
 		// No NAT interface, do nothing.
@@ -954,12 +967,21 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) erro
 		}
 	}
 
+	// Offer the hybrid handshake, which peers advertising it must accept.
+	hybrid, ok := c.transport.(hybridTransport)
+	if ok && srv.HybridKEM {
+		hybrid.enableHybridKEM(dialDest != nil && dialDest.Load(&pqkemEntry{}) == nil)
+	}
+
 	// Run the RLPx handshake.
 	remotePubkey, err := c.doEncHandshake(srv.PrivateKey)
 	if err != nil {
 		srv.log.Trace("Failed RLPx handshake", "addr", c.fd.RemoteAddr(), "conn", c.flags, "err", err)
 		return err
 	}
+	if ok && hybrid.hybridKEM() {
+		c.set(hybridKEMConn, true)
+	}
 	if dialDest != nil {
 		c.node = dialDest
 	} else {
@@ -622,3 +622,55 @@ func syncAddPeer(srv *Server, node *enode.Node) bool {
 		}
 	}
 }
+
+// This test checks that servers advertising the hybrid ML-KEM handshake use it
+// with each other, and the classic handshake with other servers.
+func TestServerHybridKEM(t *testing.T) {
+	start := func(hybrid bool) *Server {
+		srv := &Server{Config: Config{
+			PrivateKey:  newkey(),
+			ListenAddr:  "127.0.0.1:0",
+			MaxPeers:    10,
+			NoDiscovery: true,
+			Protocols:   []Protocol{discard},
+			HybridKEM:   hybrid,
+			Logger:      testlog.Logger(t, log.LvlTrace),
+		}}
+		if err := srv.Start(); err != nil {
+			t.Fatal("can't start: ", err)
+		}
+		return srv
+	}
+	var (
+		srv1    = start(true)
+		srv2    = start(true)
+		classic = start(false)
+	)
+	defer srv1.Stop()
+	defer srv2.Stop()
+	defer classic.Stop()
+
+	if srv1.Self().Load(&pqkemEntry{}) != nil || classic.Self().Load(&pqkemEntry{}) == nil {
+		t.Fatal("hybrid handshake not advertised in the node records")
+	}
+	for _, test := range []struct {
+		remote *Server
+		want   bool
+	}{
+		{srv2, true},
+		{classic, false},
+	} {
+		if !syncAddPeer(srv1, test.remote.Self()) {
+			t.Fatal("peer not connected")
+		}
+		var info *PeerInfo
+		for _, peer := range srv1.PeersInfo() {
+			if peer.ID == test.remote.Self().ID().String() {
+				info = peer
+			}
+		}
+		if info == nil || info.Network.HybridKEM != test.want {
+			t.Errorf("peer %s: have info %+v, want hybrid %t", test.remote.Self().ID(), info, test.want)
+		}
+	}
+}
@@ -44,6 +44,24 @@ const (
 	discWriteTimeout = 1 * time.Second
 )
 
+// hybridTransport is implemented by transports supporting the hybrid ML-KEM
+// handshake.
+type hybridTransport interface {
+	enableHybridKEM(require bool)
+	hybridKEM() bool
+}
+
+// pqkemEntry is the ENR entry which advertises the hybrid ML-KEM handshake.
+type pqkemEntry struct {
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e pqkemEntry) ENRKey() string {
+	return "pqkem"
+}
+
 // rlpxTransport is the transport used by actual (non-test) connections.
 // It wraps an RLPx connection with locks and read/write deadlines.
 type rlpxTransport struct {
@@ -127,6 +145,14 @@ func (t *rlpxTransport) close(err error) {
 	t.conn.Close()
 }
 
+func (t *rlpxTransport) enableHybridKEM(require bool) {
+	t.conn.EnableHybridKEM(require)
+}
+
+func (t *rlpxTransport) hybridKEM() bool {
+	return t.conn.HybridKEM()
+}
+
 func (t *rlpxTransport) doEncHandshake(prv *ecdsa.PrivateKey) (*ecdsa.PublicKey, error) {
 	t.conn.SetDeadline(time.Now().Add(handshakeTimeout))
 	return t.conn.Handshake(prv)
@@ -28,17 +28,41 @@ const (
 	// ML-DSA signature verification per-byte gas cost
 	MLDSAVerifyPerByteGas uint64 = 3
 
-	// ML-KEM key encapsulation base gas cost (for future use)
+	// ML-KEM encapsulation validity check base gas cost
 	MLKEMEncapsBaseGas uint64 = 8000
 
-	// ML-KEM key encapsulation per-byte gas cost (for future use)
+	// ML-KEM encapsulation validity check per-byte gas cost
 	MLKEMEncapsPerByteGas uint64 = 2
 
-	// SLH-DSA signature verification base gas cost (for future use)
+	// SLH-DSA signature verification base gas cost
 	SLHDSAVerifyBaseGas uint64 = 25000
 
-	// SLH-DSA signature verification per-byte gas cost (for future use)
+	// SLH-DSA signature verification per-byte gas cost
 	SLHDSAVerifyPerByteGas uint64 = 5
+
+	// Random beacon read gas cost, priced like a cold storage read
+	RandomBeaconReadGas uint64 = 2100
+
+	// PQ key registry base gas cost of registering a key
+	PQKeyRegisterGas uint64 = 50000
+
+	// PQ key registry gas cost per 32-byte word of registered keys, priced
+	// like a storage write plus the later promotion to the active key
+	PQKeyRegisterWordGas uint64 = 25000
+
+	// PQ key registry gas cost per 32-byte word of keys read, priced like a
+	// cold storage read
+	PQKeyReadWordGas uint64 = 2100
+)
+
+// Post-quantum transaction intrinsic gas costs
+const (
+	// Base cost of verifying the ML-DSA signature of a post-quantum transaction
+	TxPQSignatureGas uint64 = 15000
+
+	// Per byte cost of the ML-DSA public key and signature of a post-quantum
+	// transaction, priced like non-zero calldata
+	TxPQSignatureByteGas uint64 = 16
 )
 
 // Post-quantum algorithm identifiers for precompiles
@@ -48,12 +72,12 @@ const (
 	MLDSA65_ID byte = 0x65
 	MLDSA87_ID byte = 0x87
 
-	// ML-KEM algorithm IDs (for future use)
+	// ML-KEM algorithm IDs
 	MLKEM512_ID byte = 0x12
 	MLKEM768_ID byte = 0x18
 	MLKEM1024_ID byte = 0x24
 
-	// SLH-DSA algorithm IDs (for future use)
+	// SLH-DSA algorithm IDs
 	SLHDSA128S_ID byte = 0x81
 	SLHDSA128F_ID byte = 0x82
 	SLHDSA192S_ID byte = 0x91
@@ -76,11 +100,18 @@ type PostQuantumConfig struct {
 	// Enable ML-DSA precompiles
 	EnableMLDSAPrecompiles bool `json:"enableMLDSAPrecompiles,omitempty"`
 
-	// Enable ML-KEM for networking (future use)
+	// Enable the hybrid ML-KEM RLPx handshake on every node of the chain
 	EnableMLKEMNetworking bool `json:"enableMLKEMNetworking,omitempty"`
 
 	// Default ML-DSA algorithm for consensus (44, 65, or 87)
 	DefaultMLDSAAlgorithm int `json:"defaultMLDSAAlgorithm,omitempty"`
+
+	// Fork block from which validators run the commit-reveal random beacon
+	RandomBeaconBlock *big.Int `json:"randomBeaconBlock,omitempty"`
+
+	// Fork block from which validators register their keys in the PQ key
+	// registry system contract
+	PQKeyRegistryBlock *big.Int `json:"pqKeyRegistryBlock,omitempty"`
 }
 
 // String returns the string representation of PostQuantumConfig
@@ -101,6 +132,25 @@ func (c *ChainConfig) IsPQTFork(num *big.Int) bool {
 	return isForked(c.PostQuantum.PQTBlock, num)
 }
 
+// IsPQPrecompiles returns whether the post-quantum precompiles are active at
+// block num, i.e. after the PQT fork if they are enabled in the config.
+func (c *ChainConfig) IsPQPrecompiles(num *big.Int) bool {
+	return c.IsPQTFork(num) && c.PostQuantum.EnableMLDSAPrecompiles
+}
+
+// IsRandomBeacon returns whether the random beacon is active at block num. The
+// beacon relies on the validators' ML-DSA keys, so it's only active after the
+// PQT fork too.
+func (c *ChainConfig) IsRandomBeacon(num *big.Int) bool {
+	return c.IsPQTFork(num) && isForked(c.PostQuantum.RandomBeaconBlock, num)
+}
+
+// IsPQKeyRegistry returns whether the PQ key registry is active at block num,
+// which is only after the PQT fork.
+func (c *ChainConfig) IsPQKeyRegistry(num *big.Int) bool {
+	return c.IsPQTFork(num) && isForked(c.PostQuantum.PQKeyRegistryBlock, num)
+}
+
 // IsPQTTransition returns whether num is in the dual-signing transition period
 func (c *ChainConfig) IsPQTTransition(num *big.Int) bool {
 	if !c.IsPQTFork(num) {
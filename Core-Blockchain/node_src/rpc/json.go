@@ -56,6 +56,8 @@ type jsonrpcMessage struct {
 	Params  json.RawMessage `json:"params,omitempty"`
 	Error   *jsonError      `json:"error,omitempty"`
 	Result  json.RawMessage `json:"result,omitempty"`
+
+	stream interface{} // Result to stream instead of Result, if it holds array streams
 }
 
 func (msg *jsonrpcMessage) isNotification() bool {
@@ -99,6 +101,9 @@ func (msg *jsonrpcMessage) errorResponse(err error) *jsonrpcMessage {
 }
 
 func (msg *jsonrpcMessage) response(result interface{}) *jsonrpcMessage {
+	if streamable(result) {
+		return &jsonrpcMessage{Version: vsn, ID: msg.ID, stream: result}
+	}
 	enc, err := json.Marshal(result)
 	if err != nil {
 		// TODO: wrap with 'internal server error'
@@ -107,6 +112,19 @@ func (msg *jsonrpcMessage) response(result interface{}) *jsonrpcMessage {
 	return &jsonrpcMessage{Version: vsn, ID: msg.ID, Result: enc}
 }
 
+// buffered encodes the result of a response holding array streams, for codecs
+// unable to stream it.
+func (msg *jsonrpcMessage) buffered() *jsonrpcMessage {
+	if msg.stream == nil {
+		return msg
+	}
+	enc, err := json.Marshal(msg.stream)
+	if err != nil {
+		return msg.errorResponse(err)
+	}
+	return &jsonrpcMessage{Version: vsn, ID: msg.ID, Result: enc}
+}
+
 func errorMessage(err error) *jsonrpcMessage {
 	msg := &jsonrpcMessage{Version: vsn, ID: null, Error: &jsonError{
 		Code:    defaultErrorCode,
@@ -166,11 +184,12 @@ type ConnRemoteAddr interface {
 // support for parsing arguments and serializing (result) objects.
 type jsonCodec struct {
 	remote  string
-	closer  sync.Once                 // close closed channel once
-	closeCh chan interface{}          // closed on Close
-	decode  func(v interface{}) error // decoder to allow multiple transports
-	encMu   sync.Mutex                // guards the encoder
-	encode  func(v interface{}) error // encoder to allow multiple transports
+	closer  sync.Once                      // close closed channel once
+	closeCh chan interface{}               // closed on Close
+	decode  func(v interface{}) error      // decoder to allow multiple transports
+	encMu   sync.Mutex                     // guards the encoder
+	encode  func(v interface{}) error      // encoder to allow multiple transports
+	stream  func() (io.WriteCloser, error) // writer of streamed responses, nil if unsupported
 	conn    deadlineCloser
 }
 
@@ -196,7 +215,10 @@ func NewCodec(conn Conn) ServerCodec {
 	enc := json.NewEncoder(conn)
 	dec := json.NewDecoder(conn)
 	dec.UseNumber()
-	return NewFuncCodec(conn, enc.Encode, dec.Decode)
+
+	codec := NewFuncCodec(conn, enc.Encode, dec.Decode).(*jsonCodec)
+	codec.stream = func() (io.WriteCloser, error) { return nopWriteCloser{conn}, nil }
+	return codec
 }
 
 func (c *jsonCodec) remoteAddr() string {
@@ -230,6 +252,21 @@ func (c *jsonCodec) writeJSON(ctx context.Context, v interface{}) error {
 		deadline = time.Now().Add(defaultWriteTimeout)
 	}
 	c.conn.SetWriteDeadline(deadline)
+
+	// Stream results holding array streams if possible, encode them otherwise
+	switch msg := v.(type) {
+	case *jsonrpcMessage:
+		if msg.stream != nil {
+			if c.stream != nil {
+				return c.writeStream(msg, !ok)
+			}
+			v = msg.buffered()
+		}
+	case []*jsonrpcMessage:
+		for i := range msg {
+			msg[i] = msg[i].buffered()
+		}
+	}
 	return c.encode(v)
 }
 
@@ -0,0 +1,186 @@
+// Copyright 2024 The Splendor Authors
+// This file implements the streaming encoding of large array results
+
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+)
+
+// streamBufferSize is the size of the buffer between the encoder of a streamed
+// response and the connection.
+const streamBufferSize = 64 * 1024
+
+// ArrayStream is a JSON array result whose elements are only encoded when the
+// response is written. Codecs writing to a connection stream the elements to
+// the client one by one, whether the stream is the result itself or a field of
+// a map[string]interface{} result, instead of buffering the whole response.
+// Anywhere else it is marshaled as a regular JSON array.
+//
+// Once streaming started, errors can't be reported to the client anymore, so
+// an element failing to encode leaves the client with a truncated response.
+type ArrayStream struct {
+	length int
+	elem   func(i int) interface{}
+}
+
+// NewArrayStream creates an array of length elements, the ith of which is
+// produced by elem when encoded.
+func NewArrayStream(length int, elem func(i int) interface{}) *ArrayStream {
+	return &ArrayStream{length: length, elem: elem}
+}
+
+// Len returns the number of elements of the array.
+func (s *ArrayStream) Len() int {
+	return s.length
+}
+
+// MarshalJSON implements json.Marshaler, encoding the whole array at once.
+func (s *ArrayStream) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := s.encode(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encode writes the array to w, encoding the elements one by one.
+func (s *ArrayStream) encode(w io.Writer) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	for i := 0; i < s.length; i++ {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		enc, err := json.Marshal(s.elem(i))
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(enc); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
+// streamable reports whether a result contains array streams, either being one
+// or holding one as a field.
+func streamable(result interface{}) bool {
+	switch result := result.(type) {
+	case *ArrayStream:
+		return result != nil
+	case map[string]interface{}:
+		for _, field := range result {
+			if stream, ok := field.(*ArrayStream); ok && stream != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// encodeStream writes a result to w, streaming the array streams in it. Maps are
+// written with sorted keys, like json.Marshal does.
+func encodeStream(w io.Writer, result interface{}) error {
+	switch result := result.(type) {
+	case *ArrayStream:
+		if result != nil {
+			return result.encode(w)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(result))
+		for key := range result {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		if _, err := io.WriteString(w, "{"); err != nil {
+			return err
+		}
+		for i, key := range keys {
+			if i > 0 {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			enc, err := json.Marshal(key)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(append(enc, ':')); err != nil {
+				return err
+			}
+			if err := encodeStream(w, result[key]); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(w, "}")
+		return err
+	}
+	enc, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(enc)
+	return err
+}
+
+// nopWriteCloser turns a connection into the writer of a single streamed message.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// deadlineWriter extends the write deadline of a connection on every write, so
+// long streams only time out if the client stops reading.
+type deadlineWriter struct {
+	io.Writer
+	conn deadlineCloser
+}
+
+func (w *deadlineWriter) Write(p []byte) (int, error) {
+	w.conn.SetWriteDeadline(time.Now().Add(defaultWriteTimeout))
+	return w.Writer.Write(p)
+}
+
+// writeStream writes a response with a streamed result to the connection. The
+// write deadline is extended as the stream progresses if refresh is set.
+func (c *jsonCodec) writeStream(msg *jsonrpcMessage, refresh bool) error {
+	out, err := c.stream()
+	if err != nil {
+		return err
+	}
+	var w io.Writer = out
+	if refresh {
+		w = &deadlineWriter{Writer: out, conn: c.conn}
+	}
+	buf := bufio.NewWriterSize(w, streamBufferSize)
+
+	buf.WriteString(`{"jsonrpc":"` + vsn + `",`)
+	if len(msg.ID) > 0 {
+		buf.WriteString(`"id":`)
+		buf.Write(msg.ID)
+		buf.WriteString(",")
+	}
+	buf.WriteString(`"result":`)
+	if err := encodeStream(buf, msg.stream); err != nil {
+		out.Close()
+		return err
+	}
+	buf.WriteString("}\n")
+	if err := buf.Flush(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 The Splendor Authors
+// Tests of the streaming encoding of large array results
+
+package rpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArrayStreamTransports(t *testing.T) {
+	for _, transport := range []string{"http", "ws", "inproc"} {
+		transport := transport
+		t.Run(transport, func(t *testing.T) {
+			t.Parallel()
+
+			server := newTestServer()
+			defer server.Stop()
+
+			var client *Client
+			if transport == "inproc" {
+				client = DialInProc(server)
+			} else {
+				c, hs := httpTestClient(server, transport, nil)
+				defer hs.Close()
+				client = c
+			}
+			defer client.Close()
+
+			// Stream more than the stream buffer holds, as a result and as a field
+			const n = 50000
+			var items []echoResult
+			if err := client.Call(&items, "test_stream", n); err != nil {
+				t.Fatalf("stream call failed: %v", err)
+			}
+			if len(items) != n || items[n-1].Int != n-1 || items[n-1].String != "x" {
+				t.Fatalf("stream mismatch: %d items", len(items))
+			}
+			var fields struct {
+				Count int          `json:"count"`
+				Items []echoResult `json:"items"`
+			}
+			if err := client.Call(&fields, "test_streamField", n); err != nil {
+				t.Fatalf("stream field call failed: %v", err)
+			}
+			if fields.Count != n || len(fields.Items) != n || fields.Items[n/2].Int != n/2 {
+				t.Fatalf("stream field mismatch: count %d, %d items", fields.Count, len(fields.Items))
+			}
+		})
+	}
+}
+
+// Tests that codecs unable to stream responses still encode array streams.
+func TestArrayStreamBuffered(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	enc, dec := json.NewEncoder(serverConn), json.NewDecoder(serverConn)
+	go server.ServeCodec(NewFuncCodec(serverConn, enc.Encode, dec.Decode), 0)
+
+	clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := clientConn.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"test_stream","params":[2]}` + "\n")); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	line, err := bufio.NewReader(clientConn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	want := `{"jsonrpc":"2.0","id":1,"result":[{"String":"x","Int":0,"Args":null},{"String":"x","Int":1,"Args":null}]}`
+	if have := strings.TrimSpace(line); have != want {
+		t.Fatalf("buffered stream mismatch\nhave: %s\nwant: %s", have, want)
+	}
+}
@@ -0,0 +1,14 @@
+// This test checks that array streams are encoded like regular arrays, whether
+// streamed in single responses or buffered in batches.
+
+--> {"jsonrpc": "2.0", "id": 2, "method": "test_stream", "params": [2]}
+<-- {"jsonrpc":"2.0","id":2,"result":[{"String":"x","Int":0,"Args":null},{"String":"x","Int":1,"Args":null}]}
+
+--> {"jsonrpc": "2.0", "id": 3, "method": "test_stream", "params": [0]}
+<-- {"jsonrpc":"2.0","id":3,"result":[]}
+
+--> {"jsonrpc": "2.0", "id": 4, "method": "test_streamField", "params": [1]}
+<-- {"jsonrpc":"2.0","id":4,"result":{"count":1,"items":[{"String":"x","Int":0,"Args":null}]}}
+
+--> [{"jsonrpc": "2.0", "id": 5, "method": "test_stream", "params": [1]}, {"jsonrpc": "2.0", "id": 6, "method": "test_streamField", "params": [0]}]
+<-- [{"jsonrpc":"2.0","id":5,"result":[{"String":"x","Int":0,"Args":null}]},{"jsonrpc":"2.0","id":6,"result":{"count":0,"items":[]}}]
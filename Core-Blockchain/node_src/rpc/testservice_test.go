@@ -94,6 +94,16 @@ func (s *testService) Block(ctx context.Context) error {
 	return errors.New("context canceled in testservice_block")
 }
 
+func (s *testService) Stream(n int) *ArrayStream {
+	return NewArrayStream(n, func(i int) interface{} {
+		return echoResult{String: "x", Int: i}
+	})
+}
+
+func (s *testService) StreamField(n int) map[string]interface{} {
+	return map[string]interface{}{"count": n, "items": s.Stream(n)}
+}
+
 func (s *testService) Rets() (string, error) {
 	return "", nil
 }
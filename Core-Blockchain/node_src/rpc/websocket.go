@@ -21,6 +21,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -252,6 +253,9 @@ func newWebsocketCodec(conn *websocket.Conn) ServerCodec {
 		conn:      conn,
 		pingReset: make(chan struct{}, 1),
 	}
+	wc.jsonCodec.stream = func() (io.WriteCloser, error) {
+		return conn.NextWriter(websocket.TextMessage)
+	}
 	wc.wg.Add(1)
 	go wc.pingLoop()
 	return wc
@@ -0,0 +1,155 @@
+// Copyright 2024 Splendor Blockchain
+// State tests of the post-quantum precompiles
+
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/mlkem"
+	"github.com/ethereum/go-ethereum/crypto/slhdsa"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the post-quantum precompiles are only reachable once the PQT fork
+// is active. The contract calls the ML-DSA, SLH-DSA and ML-KEM precompiles with
+// empty input and
+// stores the call results: the precompiles reject the input, while the same
+// addresses are plain empty accounts before the fork.
+func TestStatePostQuantumPrecompiles(t *testing.T) {
+	var (
+		key, _   = crypto.HexToECDSA("45a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8")
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0x00000000000000000000000000000000000c0de0")
+		// CALL(50000, addr, 0, 0, 0, 0, 0) for 0x0100 to 0x0103, storing the
+		// results at slots 0 to 3.
+		code = common.FromHex("6000600060006000600061010061c350f1600055" + "6000600060006000600061010161c350f1600155" +
+			"6000600060006000600061010261c350f1600255" + "6000600060006000600061010361c350f1600355" + "00")
+	)
+	test := &StateTest{json: stJSON{
+		Env: stEnv{
+			Coinbase:   common.HexToAddress("0x2adc25665018aa1fe0e6bc666dac8fc2697ff9ba"),
+			Difficulty: big.NewInt(0x20000),
+			GasLimit:   10000000,
+			Number:     1,
+			Timestamp:  1000,
+		},
+		Pre: core.GenesisAlloc{
+			sender:   {Balance: big.NewInt(1000000000000000000)},
+			contract: {Balance: common.Big0, Code: code},
+		},
+		Tx: stTransaction{
+			GasPrice:   big.NewInt(10),
+			To:         contract.Hex(),
+			Data:       []string{"0x"},
+			GasLimit:   []uint64{400000},
+			Value:      []string{"0x"},
+			PrivateKey: crypto.FromECDSA(key),
+		},
+		Post: map[string][]stPostState{
+			"London":   {{}},
+			"LondonPQ": {{}},
+		},
+	}}
+	for fork, want := range map[string]common.Hash{
+		"London":   common.BigToHash(common.Big1),
+		"LondonPQ": {},
+	} {
+		_, statedb, _, err := test.RunNoVerify(StateSubtest{Fork: fork}, vm.Config{}, false)
+		if err != nil {
+			t.Fatalf("%s: failed to run state test: %v", fork, err)
+		}
+		for i := int64(0); i < 4; i++ {
+			slot := common.BigToHash(big.NewInt(i))
+			if have := statedb.GetState(contract, slot); have != want {
+				t.Errorf("%s: call result at slot %x mismatch: have %x, want %x", fork, slot, have, want)
+			}
+		}
+	}
+	// The precompiles are warm (EIP-2929) once active
+	rules := Forks["LondonPQ"].Rules(common.Big1)
+	active := vm.ActivePrecompiles(rules)
+	if n := len(vm.ActivePrecompiles(params.Rules{IsBerlin: true})); len(active) != n+4 {
+		t.Fatalf("active precompile count mismatch: have %d, want %d", len(active), n+4)
+	}
+	for i, addr := range []common.Address{vm.MLDSAVerifyAddress, vm.MLDSAVerifyCompactAddress, vm.SLHDSAVerifyAddress, vm.MLKEMCheckAddress} {
+		if active[len(active)-4+i] != addr {
+			t.Fatalf("post-quantum precompiles not active: %x", active)
+		}
+	}
+}
+
+// pqInput frames precompile input as the algorithm ID followed by the lengths
+// and the contents of the given fields.
+func pqInput(algorithmID byte, fields ...[]byte) []byte {
+	input := []byte{algorithmID}
+	for _, field := range fields {
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(len(field)))
+		input = append(input, size[:]...)
+	}
+	for _, field := range fields {
+		input = append(input, field...)
+	}
+	return input
+}
+
+// Tests the results of the SLH-DSA and ML-KEM precompiles on valid and invalid
+// inputs.
+func TestPostQuantumPrecompileResults(t *testing.T) {
+	var (
+		valid   = common.LeftPadBytes([]byte{1}, 32)
+		invalid = make([]byte, 32)
+		message = []byte("release custody funds")
+	)
+	pk, sk, err := slhdsa.GenerateKeyPair(slhdsa.SLHDSA128F)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := slhdsa.SignMessage(slhdsa.SLHDSA128F, message, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kemKey := make([]byte, mlkem.MLKEMParams[mlkem.MLKEM768].PublicKeySize)
+	unreducedKey := append([]byte{0xff, 0xff}, kemKey[2:]...)
+	ciphertext := make([]byte, mlkem.MLKEMParams[mlkem.MLKEM768].CiphertextSize)
+
+	tests := []struct {
+		addr  common.Address
+		input []byte
+		want  []byte // nil if the call fails
+	}{
+		{vm.SLHDSAVerifyAddress, pqInput(params.SLHDSA128F_ID, message, sig, pk), valid},
+		{vm.SLHDSAVerifyAddress, pqInput(params.SLHDSA128F_ID, []byte("release all funds"), sig, pk), invalid},
+		{vm.SLHDSAVerifyAddress, pqInput(params.SLHDSA128S_ID, message, sig, pk), invalid},
+		{vm.SLHDSAVerifyAddress, pqInput(0x65, message, sig, pk), nil},
+		{vm.SLHDSAVerifyAddress, pqInput(params.SLHDSA128F_ID, message, sig, pk)[1:], nil},
+		{vm.MLKEMCheckAddress, pqInput(params.MLKEM768_ID, ciphertext, kemKey), valid},
+		{vm.MLKEMCheckAddress, pqInput(params.MLKEM768_ID, nil, kemKey), valid},
+		{vm.MLKEMCheckAddress, pqInput(params.MLKEM768_ID, ciphertext, unreducedKey), invalid},
+		{vm.MLKEMCheckAddress, pqInput(params.MLKEM768_ID, ciphertext[1:], kemKey), invalid},
+		{vm.MLKEMCheckAddress, pqInput(params.MLKEM512_ID, ciphertext, kemKey), invalid},
+		{vm.MLKEMCheckAddress, pqInput(params.MLKEM768_ID, ciphertext, kemKey, nil), nil},
+	}
+	for i, tt := range tests {
+		have, _, err := vm.RunPostQuantumPrecompile(tt.addr, tt.input, 10000000)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("test %d: expected failure, have %x", i, have)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: precompile failed: %v", i, err)
+		} else if !bytes.Equal(have, tt.want) {
+			t.Errorf("test %d: result mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+}
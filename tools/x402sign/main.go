@@ -3,14 +3,22 @@ package main
 import (
 	"crypto/ecdsa"
 	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"math/big"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 )
 
 func usage() {
@@ -23,10 +31,18 @@ Usage:
   # Sign a canonical x402 message string (text) with EIP-191 prefix
   x402sign -key 0x<privatekey> sign "x402-payment:{from}:{to}:{value}:{validAfter}:{validBefore}:{nonce}:{chainId}"
 
+  # Print the EIP-712 PaymentAuthorization typed data (eth_signTypedData_v4 JSON)
+  # for a payment from the key's address
+  x402sign -key 0x<privatekey> [-scheme upto] typed {to} {value} {validAfter} {validBefore} {nonce} {chainId}
+
+  # Sign the EIP-712 PaymentAuthorization typed data of a payment
+  x402sign -key 0x<privatekey> [-scheme upto] sign-typed {to} {value} {validAfter} {validBefore} {nonce} {chainId}
+
 Notes:
   - The -key must be a 32-byte hex string (with or without 0x).
   - The "sign" command expects the exact message text the server reconstructs.
   - Output signature is 0x-prefixed hex, 65 bytes (r||s||v) with v in {27,28}.
+  - For typed data, {value} is hex or decimal wei (the ceiling for -scheme upto).
 
 `)
 	os.Exit(2)
@@ -44,9 +60,89 @@ func loadPriv(hexkey string) (*ecdsa.PrivateKey, error) {
 	return crypto.ToECDSA(b)
 }
 
+// typedData builds the EIP-712 PaymentAuthorization of a payment from the
+// command line arguments {to} {value} {validAfter} {validBefore} {nonce} {chainId}.
+func typedData(scheme string, from common.Address, args []string) (*apitypes.TypedData, error) {
+	if len(args) != 6 {
+		return nil, fmt.Errorf("want 6 arguments, got %d", len(args))
+	}
+	if !common.IsHexAddress(args[0]) {
+		return nil, fmt.Errorf("invalid recipient %q", args[0])
+	}
+	value, ok := math.ParseBig256(args[1])
+	if !ok {
+		return nil, fmt.Errorf("invalid value %q", args[1])
+	}
+	validAfter, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid validAfter: %w", err)
+	}
+	validBefore, err := strconv.ParseUint(args[3], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid validBefore: %w", err)
+	}
+	nonce, err := hexutil.Decode(args[4])
+	if err != nil || len(nonce) != common.HashLength {
+		return nil, fmt.Errorf("invalid nonce %q, want 32 bytes hex", args[4])
+	}
+	chainID, ok := math.ParseBig256(args[5])
+	if !ok {
+		return nil, fmt.Errorf("invalid chainId %q", args[5])
+	}
+	return &apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"PaymentAuthorization": {
+				{Name: "scheme", Type: "string"},
+				{Name: "from", Type: "address"},
+				{Name: "to", Type: "address"},
+				{Name: "value", Type: "uint256"},
+				{Name: "validAfter", Type: "uint256"},
+				{Name: "validBefore", Type: "uint256"},
+				{Name: "nonce", Type: "bytes32"},
+			},
+		},
+		PrimaryType: "PaymentAuthorization",
+		Domain: apitypes.TypedDataDomain{
+			Name:              types.X402DomainName,
+			Version:           types.X402DomainVersion,
+			ChainId:           (*math.HexOrDecimal256)(chainID),
+			VerifyingContract: types.X402RegistryAddress.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"scheme":      scheme,
+			"from":        from.Hex(),
+			"to":          common.HexToAddress(args[0]).Hex(),
+			"value":       value.String(),
+			"validAfter":  new(big.Int).SetUint64(validAfter).String(),
+			"validBefore": new(big.Int).SetUint64(validBefore).String(),
+			"nonce":       hexutil.Encode(nonce),
+		},
+	}, nil
+}
+
+// typedDataHash returns the EIP-712 digest of the typed data.
+func typedDataHash(data *apitypes.TypedData) ([]byte, error) {
+	domain, err := data.HashStruct("EIP712Domain", data.Domain.Map())
+	if err != nil {
+		return nil, err
+	}
+	message, err := data.HashStruct(data.PrimaryType, data.Message)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256([]byte{0x19, 0x01}, domain, message), nil
+}
+
 func main() {
 	log.SetFlags(0)
 	key := flag.String("key", "", "hex private key (0x...)")
+	scheme := flag.String("scheme", types.X402SchemeExact, "payment scheme for typed data (exact or upto)")
 	flag.Usage = usage
 	flag.Parse()
 
@@ -79,6 +175,32 @@ func main() {
 			sig[64] += 27
 		}
 		fmt.Printf("0x%x\n", sig)
+	case "typed", "sign-typed":
+		data, err := typedData(*scheme, addr, flag.Args()[1:])
+		if err != nil {
+			log.Fatalf("typed data: %v", err)
+		}
+		if cmd == "typed" {
+			out, err := json.MarshalIndent(data, "", "  ")
+			if err != nil {
+				log.Fatalf("encode typed data: %v", err)
+			}
+			fmt.Println(string(out))
+			return
+		}
+		hash, err := typedDataHash(data)
+		if err != nil {
+			log.Fatalf("hash typed data: %v", err)
+		}
+		sig, err := crypto.Sign(hash, priv)
+		if err != nil {
+			log.Fatalf("sign: %v", err)
+		}
+		// Normalize v to {27,28}
+		if sig[64] < 27 {
+			sig[64] += 27
+		}
+		fmt.Printf("0x%x\n", sig)
 	default:
 		usage()
 	}